@@ -78,6 +78,7 @@ def _run_task_in_subprocess(
     progress_queue: Any,
     status_proxy: Optional[Any],
     db_queue: Optional[Any],
+    metadata: Optional[Dict[str, str]] = None,
 ) -> None:
     """Run the algorithm task in a subprocess."""
 
@@ -137,7 +138,7 @@ def _run_task_in_subprocess(
                 }
             )
         ctx.log(logging.INFO, "Task Completed")
-        reporter.send_result(task_id, status="SUCCESS", data=result)
+        reporter.send_result(task_id, status="SUCCESS", data=result, metadata=metadata)
     except TaskCancelled as exc:
         message = str(exc) or "Cancelled"
         if status_proxy is not None:
@@ -169,7 +170,7 @@ def _run_task_in_subprocess(
                 }
             )
         ctx.log(logging.INFO, "Task Cancelled")
-        reporter.send_result(task_id, status="CANCELLED", error=message)
+        reporter.send_result(task_id, status="CANCELLED", error=message, metadata=metadata)
     except Exception as exc:
         err_msg = str(exc)
         stack = traceback.format_exc()
@@ -203,7 +204,7 @@ def _run_task_in_subprocess(
                 }
             )
         ctx.log(logging.ERROR, "Task Failed")
-        reporter.send_result(task_id, status="FAILED", error=err_msg)
+        reporter.send_result(task_id, status="FAILED", error=err_msg, metadata=metadata)
 
 
 class TaskDispatcher:
@@ -284,12 +285,22 @@ class TaskDispatcher:
 
         threading.Thread(target=_watcher, daemon=True, name=f"TermWatcher-{task_id[:8]}").start()
 
-    def dispatch(self, task_id: str, scheme_code: str, data_ref: str, params: Dict[str, Any]) -> None:
-        """Dispatch the algorithm task to the appropriate executor."""
+    def dispatch(
+        self,
+        task_id: str,
+        scheme_code: str,
+        data_ref: str,
+        params: Dict[str, Any],
+        metadata: Optional[Dict[str, str]] = None,
+    ) -> None:
+        """Dispatch the algorithm task to the appropriate executor.
+
+        metadata is opaque caller context echoed back with the task result.
+        """
 
         algo = AlgorithmRegistry.get_algorithm(scheme_code)
         if not algo:
-            self._report_error(task_id, f"Scheme {scheme_code} not found")
+            self._report_error(task_id, f"Scheme {scheme_code} not found", metadata=metadata)
             return
 
         manager = ProgressManager.get_instance()
@@ -302,7 +313,7 @@ class TaskDispatcher:
 
         executor = self.hardware.get_executor(algo.meta_info["resource_type"])
         if executor is None:
-            self._report_error(task_id, "Executor not available for resource type")
+            self._report_error(task_id, "Executor not available for resource type", metadata=metadata)
             return
 
         try:
@@ -320,6 +331,7 @@ class TaskDispatcher:
                     progress_queue,
                     status_proxy,
                     db_queue,
+                    metadata,
                 )
                 # No future for direct process management
                 future = None
@@ -333,7 +345,8 @@ class TaskDispatcher:
                     params,
                     progress_queue,
                     status_proxy,
-                    db_queue
+                    db_queue,
+                    metadata,
                 )
             if future is not None:
                 with self._tasks_lock:
@@ -351,7 +364,7 @@ class TaskDispatcher:
             )
             TaskStore().finish_task(task_id, status="FAILED", message="Failed", error_message=err_msg)
             manager.mark_finished(task_id, "FAILED", message="Failed")
-            self.reporter.send_result(task_id, status="FAILED", error=err_msg)
+            self.reporter.send_result(task_id, status="FAILED", error=err_msg, metadata=metadata)
 
     def _safe_runner(
         self,
@@ -362,6 +375,7 @@ class TaskDispatcher:
         progress_queue: Any,
         status_proxy: Optional[Any],
         db_queue: Optional[Any],
+        metadata: Optional[Dict[str, str]] = None,
     ) -> None:
         """Run the algorithm task safely within the current process."""
 
@@ -413,7 +427,7 @@ class TaskDispatcher:
                     }
                 )
             ctx.log(logging.INFO, "Task Completed")
-            self.reporter.send_result(task_id, status="SUCCESS", data=result)
+            self.reporter.send_result(task_id, status="SUCCESS", data=result, metadata=metadata)
         except TaskCancelled as exc:
             message = str(exc) or "Cancelled"
             if status_proxy is not None:
@@ -445,7 +459,7 @@ class TaskDispatcher:
                     }
                 )
             ctx.log(logging.INFO, "Task Cancelled")
-            self.reporter.send_result(task_id, status="CANCELLED", error=message)
+            self.reporter.send_result(task_id, status="CANCELLED", error=message, metadata=metadata)
         except Exception as exc:
             err_msg = str(exc)
             stack = traceback.format_exc()
@@ -479,16 +493,16 @@ class TaskDispatcher:
                     }
                 )
             ctx.log(logging.ERROR, "Task Failed")
-            self.reporter.send_result(task_id, status="FAILED", error=err_msg)
+            self.reporter.send_result(task_id, status="FAILED", error=err_msg, metadata=metadata)
 
-    def _report_error(self, task_id: str, message: str) -> None:
+    def _report_error(self, task_id: str, message: str, metadata: Optional[Dict[str, str]] = None) -> None:
         """Report an error for the given task."""
 
         logging.error("[Dispatcher] %s", message)
         TaskStore().upsert_task_start(task_id, scheme_code="", data_ref="")
         TaskStore().finish_task(task_id, status="FAILED", message="Failed", error_message=message)
         logging.error("[Dispatcher] Task Failed: %s", task_id)
-        self.reporter.send_result(task_id, status="FAILED", error=message)
+        self.reporter.send_result(task_id, status="FAILED", error=message, metadata=metadata)
 
     def _cleanup_task(self, task_id: str) -> None:
         with self._tasks_lock:
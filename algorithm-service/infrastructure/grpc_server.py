@@ -35,6 +35,7 @@ class AlgoControlService(algorithm_pb2_grpc.AlgoControlServiceServicer):
                 resource_type=meta.get("resource_type", "CPU"),
                 model=meta.get("model", ""),
                 class_name=meta.get("class", ""),
+                supports_checkpoint=bool(meta.get("supports_checkpoint", False)),
             )
             for meta in AlgorithmRegistry.get_all_schemes()
         ]
@@ -47,8 +48,14 @@ class AlgoControlService(algorithm_pb2_grpc.AlgoControlServiceServicer):
             params = json.loads(request.params_json) if request.params_json else {}
         except json.JSONDecodeError:
             params = {}
-        self.dispatcher.dispatch(request.task_id, request.scheme_code, request.data_ref, params)
-        return algorithm_pb2.TaskSubmissionResponse(accepted=True, message="Task accepted") # type: ignore
+        self.dispatcher.dispatch(
+            request.task_id,
+            request.scheme_code,
+            request.data_ref,
+            params,
+            metadata=dict(request.metadata),
+        )
+        return algorithm_pb2.TaskSubmissionResponse(accepted=True, message="Task accepted", task_id=request.task_id) # type: ignore
 
     def CheckHealth(self, request: Any, context: grpc.ServicerContext) -> Any:
         """Checks the health status of the service."""
@@ -64,9 +71,13 @@ class AlgoControlService(algorithm_pb2_grpc.AlgoControlServiceServicer):
 
         manager = ProgressManager.get_instance()
         q = manager.register_watcher(request.task_id)
+        # A reconnecting client only wants what it has not seen yet
+        resume_from = int(getattr(request, "resume_from_timestamp", 0) or 0)
         try:
             while True:
                 data = q.get(timeout=60)
+                if int(data.get("timestamp", 0)) < resume_from:
+                    continue
                 yield algorithm_pb2.ProgressUpdate(**data) # type: ignore
                 status = manager.get_task(request.task_id).get("status")
                 if data["percentage"] >= 100 or status in ("SUCCESS", "FAILED"):
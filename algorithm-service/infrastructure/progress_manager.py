@@ -167,11 +167,13 @@ class ProgressManager:
 
         status = self.get_task(task_id)
         if status:
+            # Stamp the snapshot with when it was recorded so reconnecting
+            # watchers can tell whether they have already seen it
             payload = {
                 "task_id": task_id,
                 "percentage": int(status.get("percentage", 0)),
                 "message": status.get("message", ""),
-                "timestamp": int(time.time() * 1000),
+                "timestamp": int(status.get("updated_at") or time.time() * 1000),
             }
             q.put(payload)
         return q
@@ -7,7 +7,7 @@ gRPC client for reporting algorithm task results.
 import json
 import logging
 from pathlib import Path
-from typing import Any, Optional
+from typing import Any, Dict, Optional
 
 import grpc
 
@@ -17,6 +17,10 @@ from proto import algorithm_pb2, algorithm_pb2_grpc
 class ResultReporterClient:
     """gRPC client to report algorithm task results."""
 
+    # Results whose JSON is larger than this are streamed in chunks
+    STREAM_THRESHOLD_BYTES = 32 * 1024 * 1024
+    CHUNK_SIZE_BYTES = 1024 * 1024
+
     def __init__(self, target: Optional[str] = None, grpc_stub: Optional[Any] = None) -> None:
         self._target = target
         self._channel = None
@@ -32,7 +36,14 @@ class ResultReporterClient:
         else:
             self._stub = None
 
-    def send_result(self, task_id: str, status: str, data: Any = None, error: Optional[str] = None) -> None:
+    def send_result(
+        self,
+        task_id: str,
+        status: str,
+        data: Any = None,
+        error: Optional[str] = None,
+        metadata: Optional[Dict[str, str]] = None,
+    ) -> None:
         """Send the result of an algorithm task to the result receiver service."""
 
         def _json_safe(obj: Any) -> Any:
@@ -92,6 +103,7 @@ class ResultReporterClient:
             result_json=result_json,
             error_message=error or "",
             log_path=str(result_path),
+            metadata=metadata or {},
         )
         logging.info("[Reporter] %s", payload)
 
@@ -99,7 +111,18 @@ class ResultReporterClient:
             return
 
         try:
-            self._stub.ReportResult(payload)
+            encoded = result_json.encode("utf-8")
+            if len(encoded) > self.STREAM_THRESHOLD_BYTES:
+                payload.result_json = ""
+                self._stub.ReportResultStream(self._result_chunks(payload, encoded))
+            else:
+                self._stub.ReportResult(payload)
         except Exception as exc:
             logging.error("[Reporter] Failed to send result: %s", exc)
 
+    def _result_chunks(self, result: Any, data: bytes):
+        """Yield a streamed result: the result without its JSON first, then the JSON in chunks."""
+        yield algorithm_pb2.ResultChunk(result=result)  # type: ignore
+        for start in range(0, len(data), self.CHUNK_SIZE_BYTES):
+            yield algorithm_pb2.ResultChunk(data=data[start:start + self.CHUNK_SIZE_BYTES])  # type: ignore
+
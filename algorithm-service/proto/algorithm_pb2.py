@@ -24,59 +24,69 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0f\x61lgorithm.proto\x12\talgorithm\"\xca\x01\n\nSchemeList\x12-\n\x07schemes\x18\x01 \x03(\x0b\x32\x1c.algorithm.SchemeList.Scheme\x1a\x8c\x01\n\x06Scheme\x12\r\n\x05model\x18\x01 \x01(\t\x12\x0c\n\x04\x63ode\x18\x02 \x01(\t\x12\x0c\n\x04name\x18\x03 \x01(\t\x12\x12\n\nclass_name\x18\x04 \x01(\t\x12\x15\n\rresource_type\x18\x05 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x06 \x01(\t\x12\x17\n\x0frequired_params\x18\x07 \x03(\t\"\x9b\x01\n\x0bTaskRequest\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x13\n\x0bscheme_code\x18\x02 \x01(\t\x12\x10\n\x08\x64\x61ta_ref\x18\x03 \x01(\t\x12\x13\n\x0bparams_json\x18\x04 \x01(\t\x12\x10\n\x08priority\x18\x05 \x01(\x05\x12\x17\n\x0ftimeout_seconds\x18\x06 \x01(\x05\x12\x14\n\x0c\x63\x61llback_url\x18\x07 \x01(\t\"l\n\x16TaskSubmissionResponse\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x01 \x01(\x08\x12\x0f\n\x07message\x18\x02 \x01(\t\x12\x16\n\x0equeue_position\x18\x03 \x01(\x05\x12\x17\n\x0f\x65stimated_start\x18\x04 \x01(\x03\"C\n\x0e\x43\x61ncelResponse\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x01 \x01(\x08\x12\x0f\n\x07message\x18\x02 \x01(\t\x12\x0e\n\x06status\x18\x03 \x01(\t\"/\n\rCancelRequest\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\r\n\x05\x66orce\x18\x02 \x01(\x08\"\xd1\x01\n\x0eProgressUpdate\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x12\n\npercentage\x18\x02 \x01(\x05\x12\x0f\n\x07message\x18\x03 \x01(\t\x12\x11\n\ttimestamp\x18\x04 \x01(\x03\x12\r\n\x05stage\x18\x05 \x01(\t\x12\x37\n\x07metrics\x18\x06 \x03(\x0b\x32&.algorithm.ProgressUpdate.MetricsEntry\x1a.\n\x0cMetricsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\xc2\x02\n\nTaskResult\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12,\n\x06status\x18\x02 \x01(\x0e\x32\x1c.algorithm.TaskResult.Status\x12\x13\n\x0bresult_json\x18\x03 \x01(\t\x12\x15\n\rerror_message\x18\x04 \x01(\t\x12\x10\n\x08log_path\x18\x05 \x01(\t\x12\x13\n\x0b\x64uration_ms\x18\x06 \x01(\x03\x12\x33\n\x07metrics\x18\x07 \x03(\x0b\x32\".algorithm.TaskResult.MetricsEntry\x1a.\n\x0cMetricsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"=\n\x06Status\x12\x0b\n\x07SUCCESS\x10\x00\x12\n\n\x06\x46\x41ILED\x10\x01\x12\r\n\tCANCELLED\x10\x02\x12\x0b\n\x07TIMEOUT\x10\x03\"\xd4\x02\n\x0cHealthStatus\x12\x35\n\x06status\x18\x01 \x01(\x0e\x32%.algorithm.HealthStatus.ServingStatus\x12\x35\n\x07metrics\x18\x02 \x03(\x0b\x32$.algorithm.HealthStatus.MetricsEntry\x12\x14\n\x0c\x61\x63tive_tasks\x18\x03 \x01(\x05\x12\x14\n\x0cqueue_length\x18\x04 \x01(\x05\x12\x11\n\tcpu_usage\x18\x05 \x01(\x01\x12\x14\n\x0cmemory_usage\x18\x06 \x01(\x01\x12\x15\n\rgpu_available\x18\x07 \x01(\x08\x1a.\n\x0cMetricsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\":\n\rServingStatus\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0b\n\x07SERVING\x10\x01\x12\x0f\n\x0bNOT_SERVING\x10\x02\"\x07\n\x05\x45mpty\"\x1f\n\x0cTaskIdentity\x12\x0f\n\x07task_id\x18\x01 \x01(\t\"\'\n\x03\x41\x63k\x12\x0f\n\x07success\x18\x01 \x01(\x08\x12\x0f\n\x07message\x18\x02 \x01(\t\"\xbb\x01\n\nTaskStatus\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x13\n\x0bscheme_code\x18\x02 \x01(\t\x12\x0e\n\x06status\x18\x03 \x01(\t\x12\x12\n\npercentage\x18\x04 \x01(\x05\x12\x0f\n\x07message\x18\x05 \x01(\t\x12\x15\n\rerror_message\x18\x06 \x01(\t\x12\x12\n\ncreated_at\x18\x07 \x01(\x03\x12\x12\n\nupdated_at\x18\x08 \x01(\x03\x12\x13\n\x0b\x66inished_at\x18\t \x01(\x03\"t\n\x08TaskList\x12$\n\x05tasks\x18\x01 \x03(\x0b\x32\x15.algorithm.TaskStatus\x12\r\n\x05total\x18\x02 \x01(\x05\x12\x0f\n\x07pending\x18\x03 \x01(\x05\x12\x0f\n\x07running\x18\x04 \x01(\x05\x12\x11\n\tcompleted\x18\x05 \x01(\x05\x32\xda\x03\n\x12\x41lgoControlService\x12>\n\x13GetAvailableSchemes\x12\x10.algorithm.Empty\x1a\x15.algorithm.SchemeList\x12G\n\nSubmitTask\x12\x16.algorithm.TaskRequest\x1a!.algorithm.TaskSubmissionResponse\x12\x38\n\x0b\x43heckHealth\x12\x10.algorithm.Empty\x1a\x17.algorithm.HealthStatus\x12I\n\x11WatchTaskProgress\x12\x17.algorithm.TaskIdentity\x1a\x19.algorithm.ProgressUpdate0\x01\x12\x32\n\tListTasks\x12\x10.algorithm.Empty\x1a\x13.algorithm.TaskList\x12?\n\rGetTaskStatus\x12\x17.algorithm.TaskIdentity\x1a\x15.algorithm.TaskStatus\x12\x41\n\nCancelTask\x12\x18.algorithm.CancelRequest\x1a\x19.algorithm.CancelResponse2N\n\x15ResultReceiverService\x12\x35\n\x0cReportResult\x12\x15.algorithm.TaskResult\x1a\x0e.algorithm.Ackb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0f\x61lgorithm.proto\x12\talgorithm\"\xe7\x01\n\nSchemeList\x12-\n\x07schemes\x18\x01 \x03(\x0b\x32\x1c.algorithm.SchemeList.Scheme\x1a\xa9\x01\n\x06Scheme\x12\r\n\x05model\x18\x01 \x01(\t\x12\x0c\n\x04\x63ode\x18\x02 \x01(\t\x12\x0c\n\x04name\x18\x03 \x01(\t\x12\x12\n\nclass_name\x18\x04 \x01(\t\x12\x15\n\rresource_type\x18\x05 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x06 \x01(\t\x12\x17\n\x0frequired_params\x18\x07 \x03(\t\x12\x1b\n\x13supports_checkpoint\x18\x08 \x01(\x08\"\x84\x02\n\x0bTaskRequest\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x13\n\x0bscheme_code\x18\x02 \x01(\t\x12\x10\n\x08\x64\x61ta_ref\x18\x03 \x01(\t\x12\x13\n\x0bparams_json\x18\x04 \x01(\t\x12\x10\n\x08priority\x18\x05 \x01(\x05\x12\x17\n\x0ftimeout_seconds\x18\x06 \x01(\x05\x12\x14\n\x0c\x63\x61llback_url\x18\x07 \x01(\t\x12\x36\n\x08metadata\x18\x08 \x03(\x0b\x32$.algorithm.TaskRequest.MetadataEntry\x1a/\n\rMetadataEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"}\n\x16TaskSubmissionResponse\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x01 \x01(\x08\x12\x0f\n\x07message\x18\x02 \x01(\t\x12\x16\n\x0equeue_position\x18\x03 \x01(\x05\x12\x17\n\x0f\x65stimated_start\x18\x04 \x01(\x03\x12\x0f\n\x07task_id\x18\x05 \x01(\t\"C\n\x0e\x43\x61ncelResponse\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x01 \x01(\x08\x12\x0f\n\x07message\x18\x02 \x01(\t\x12\x0e\n\x06status\x18\x03 \x01(\t\"/\n\rCancelRequest\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\r\n\x05\x66orce\x18\x02 \x01(\x08\"\xe9\x01\n\x0eProgressUpdate\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x12\n\npercentage\x18\x02 \x01(\x05\x12\x0f\n\x07message\x18\x03 \x01(\t\x12\x11\n\ttimestamp\x18\x04 \x01(\x03\x12\r\n\x05stage\x18\x05 \x01(\t\x12\x37\n\x07metrics\x18\x06 \x03(\x0b\x32&.algorithm.ProgressUpdate.MetricsEntry\x12\x16\n\x0e\x63heckpoint_ref\x18\x07 \x01(\t\x1a.\n\x0cMetricsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\xc2\x03\n\nTaskResult\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12,\n\x06status\x18\x02 \x01(\x0e\x32\x1c.algorithm.TaskResult.Status\x12\x13\n\x0bresult_json\x18\x03 \x01(\t\x12\x15\n\rerror_message\x18\x04 \x01(\t\x12\x10\n\x08log_path\x18\x05 \x01(\t\x12\x13\n\x0b\x64uration_ms\x18\x06 \x01(\x03\x12\x33\n\x07metrics\x18\x07 \x03(\x0b\x32\".algorithm.TaskResult.MetricsEntry\x12\x16\n\x0e\x63heckpoint_ref\x18\x08 \x01(\t\x12\x35\n\x08metadata\x18\t \x03(\x0b\x32#.algorithm.TaskResult.MetadataEntry\x1a.\n\x0cMetricsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\x1a/\n\rMetadataEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"=\n\x06Status\x12\x0b\n\x07SUCCESS\x10\x00\x12\n\n\x06\x46\x41ILED\x10\x01\x12\r\n\tCANCELLED\x10\x02\x12\x0b\n\x07TIMEOUT\x10\x03\"B\n\x0bResultChunk\x12%\n\x06result\x18\x01 \x01(\x0b\x32\x15.algorithm.TaskResult\x12\x0c\n\x04\x64\x61ta\x18\x02 \x01(\x0c\"\xd4\x02\n\x0cHealthStatus\x12\x35\n\x06status\x18\x01 \x01(\x0e\x32%.algorithm.HealthStatus.ServingStatus\x12\x35\n\x07metrics\x18\x02 \x03(\x0b\x32$.algorithm.HealthStatus.MetricsEntry\x12\x14\n\x0c\x61\x63tive_tasks\x18\x03 \x01(\x05\x12\x14\n\x0cqueue_length\x18\x04 \x01(\x05\x12\x11\n\tcpu_usage\x18\x05 \x01(\x01\x12\x14\n\x0cmemory_usage\x18\x06 \x01(\x01\x12\x15\n\rgpu_available\x18\x07 \x01(\x08\x1a.\n\x0cMetricsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\":\n\rServingStatus\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0b\n\x07SERVING\x10\x01\x12\x0f\n\x0bNOT_SERVING\x10\x02\"\x07\n\x05\x45mpty\">\n\x0cTaskIdentity\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x1d\n\x15resume_from_timestamp\x18\x02 \x01(\x03\"\'\n\x03\x41\x63k\x12\x0f\n\x07success\x18\x01 \x01(\x08\x12\x0f\n\x07message\x18\x02 \x01(\t\"\xbb\x01\n\nTaskStatus\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x13\n\x0bscheme_code\x18\x02 \x01(\t\x12\x0e\n\x06status\x18\x03 \x01(\t\x12\x12\n\npercentage\x18\x04 \x01(\x05\x12\x0f\n\x07message\x18\x05 \x01(\t\x12\x15\n\rerror_message\x18\x06 \x01(\t\x12\x12\n\ncreated_at\x18\x07 \x01(\x03\x12\x12\n\nupdated_at\x18\x08 \x01(\x03\x12\x13\n\x0b\x66inished_at\x18\t \x01(\x03\"t\n\x08TaskList\x12$\n\x05tasks\x18\x01 \x03(\x0b\x32\x15.algorithm.TaskStatus\x12\r\n\x05total\x18\x02 \x01(\x05\x12\x0f\n\x07pending\x18\x03 \x01(\x05\x12\x0f\n\x07running\x18\x04 \x01(\x05\x12\x11\n\tcompleted\x18\x05 \x01(\x05\x32\xda\x03\n\x12\x41lgoControlService\x12>\n\x13GetAvailableSchemes\x12\x10.algorithm.Empty\x1a\x15.algorithm.SchemeList\x12G\n\nSubmitTask\x12\x16.algorithm.TaskRequest\x1a!.algorithm.TaskSubmissionResponse\x12\x38\n\x0b\x43heckHealth\x12\x10.algorithm.Empty\x1a\x17.algorithm.HealthStatus\x12I\n\x11WatchTaskProgress\x12\x17.algorithm.TaskIdentity\x1a\x19.algorithm.ProgressUpdate0\x01\x12\x32\n\tListTasks\x12\x10.algorithm.Empty\x1a\x13.algorithm.TaskList\x12?\n\rGetTaskStatus\x12\x17.algorithm.TaskIdentity\x1a\x15.algorithm.TaskStatus\x12\x41\n\nCancelTask\x12\x18.algorithm.CancelRequest\x1a\x19.algorithm.CancelResponse2\x8e\x01\n\x15ResultReceiverService\x12\x35\n\x0cReportResult\x12\x15.algorithm.TaskResult\x1a\x0e.algorithm.Ack\x12>\n\x12ReportResultStream\x12\x16.algorithm.ResultChunk\x1a\x0e.algorithm.Ack(\x01\x62\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
 _builder.BuildTopDescriptorsAndMessages(DESCRIPTOR, 'algorithm_pb2', _globals)
 if not _descriptor._USE_C_DESCRIPTORS:
   DESCRIPTOR._loaded_options = None
+  _globals['_TASKREQUEST_METADATAENTRY']._loaded_options = None
+  _globals['_TASKREQUEST_METADATAENTRY']._serialized_options = b'8\001'
   _globals['_PROGRESSUPDATE_METRICSENTRY']._loaded_options = None
   _globals['_PROGRESSUPDATE_METRICSENTRY']._serialized_options = b'8\001'
   _globals['_TASKRESULT_METRICSENTRY']._loaded_options = None
   _globals['_TASKRESULT_METRICSENTRY']._serialized_options = b'8\001'
+  _globals['_TASKRESULT_METADATAENTRY']._loaded_options = None
+  _globals['_TASKRESULT_METADATAENTRY']._serialized_options = b'8\001'
   _globals['_HEALTHSTATUS_METRICSENTRY']._loaded_options = None
   _globals['_HEALTHSTATUS_METRICSENTRY']._serialized_options = b'8\001'
   _globals['_SCHEMELIST']._serialized_start=31
-  _globals['_SCHEMELIST']._serialized_end=233
+  _globals['_SCHEMELIST']._serialized_end=262
   _globals['_SCHEMELIST_SCHEME']._serialized_start=93
-  _globals['_SCHEMELIST_SCHEME']._serialized_end=233
-  _globals['_TASKREQUEST']._serialized_start=236
-  _globals['_TASKREQUEST']._serialized_end=391
-  _globals['_TASKSUBMISSIONRESPONSE']._serialized_start=393
-  _globals['_TASKSUBMISSIONRESPONSE']._serialized_end=501
-  _globals['_CANCELRESPONSE']._serialized_start=503
-  _globals['_CANCELRESPONSE']._serialized_end=570
-  _globals['_CANCELREQUEST']._serialized_start=572
-  _globals['_CANCELREQUEST']._serialized_end=619
-  _globals['_PROGRESSUPDATE']._serialized_start=622
-  _globals['_PROGRESSUPDATE']._serialized_end=831
-  _globals['_PROGRESSUPDATE_METRICSENTRY']._serialized_start=785
-  _globals['_PROGRESSUPDATE_METRICSENTRY']._serialized_end=831
-  _globals['_TASKRESULT']._serialized_start=834
-  _globals['_TASKRESULT']._serialized_end=1156
-  _globals['_TASKRESULT_METRICSENTRY']._serialized_start=785
-  _globals['_TASKRESULT_METRICSENTRY']._serialized_end=831
-  _globals['_TASKRESULT_STATUS']._serialized_start=1095
-  _globals['_TASKRESULT_STATUS']._serialized_end=1156
-  _globals['_HEALTHSTATUS']._serialized_start=1159
-  _globals['_HEALTHSTATUS']._serialized_end=1499
-  _globals['_HEALTHSTATUS_METRICSENTRY']._serialized_start=785
-  _globals['_HEALTHSTATUS_METRICSENTRY']._serialized_end=831
-  _globals['_HEALTHSTATUS_SERVINGSTATUS']._serialized_start=1441
-  _globals['_HEALTHSTATUS_SERVINGSTATUS']._serialized_end=1499
-  _globals['_EMPTY']._serialized_start=1501
-  _globals['_EMPTY']._serialized_end=1508
-  _globals['_TASKIDENTITY']._serialized_start=1510
-  _globals['_TASKIDENTITY']._serialized_end=1541
-  _globals['_ACK']._serialized_start=1543
-  _globals['_ACK']._serialized_end=1582
-  _globals['_TASKSTATUS']._serialized_start=1585
-  _globals['_TASKSTATUS']._serialized_end=1772
-  _globals['_TASKLIST']._serialized_start=1774
-  _globals['_TASKLIST']._serialized_end=1890
-  _globals['_ALGOCONTROLSERVICE']._serialized_start=1893
-  _globals['_ALGOCONTROLSERVICE']._serialized_end=2367
-  _globals['_RESULTRECEIVERSERVICE']._serialized_start=2369
-  _globals['_RESULTRECEIVERSERVICE']._serialized_end=2447
+  _globals['_SCHEMELIST_SCHEME']._serialized_end=262
+  _globals['_TASKREQUEST']._serialized_start=265
+  _globals['_TASKREQUEST']._serialized_end=525
+  _globals['_TASKREQUEST_METADATAENTRY']._serialized_start=478
+  _globals['_TASKREQUEST_METADATAENTRY']._serialized_end=525
+  _globals['_TASKSUBMISSIONRESPONSE']._serialized_start=527
+  _globals['_TASKSUBMISSIONRESPONSE']._serialized_end=652
+  _globals['_CANCELRESPONSE']._serialized_start=654
+  _globals['_CANCELRESPONSE']._serialized_end=721
+  _globals['_CANCELREQUEST']._serialized_start=723
+  _globals['_CANCELREQUEST']._serialized_end=770
+  _globals['_PROGRESSUPDATE']._serialized_start=773
+  _globals['_PROGRESSUPDATE']._serialized_end=1006
+  _globals['_PROGRESSUPDATE_METRICSENTRY']._serialized_start=960
+  _globals['_PROGRESSUPDATE_METRICSENTRY']._serialized_end=1006
+  _globals['_TASKRESULT']._serialized_start=1009
+  _globals['_TASKRESULT']._serialized_end=1459
+  _globals['_TASKRESULT_METRICSENTRY']._serialized_start=960
+  _globals['_TASKRESULT_METRICSENTRY']._serialized_end=1006
+  _globals['_TASKRESULT_METADATAENTRY']._serialized_start=478
+  _globals['_TASKRESULT_METADATAENTRY']._serialized_end=525
+  _globals['_TASKRESULT_STATUS']._serialized_start=1398
+  _globals['_TASKRESULT_STATUS']._serialized_end=1459
+  _globals['_RESULTCHUNK']._serialized_start=1461
+  _globals['_RESULTCHUNK']._serialized_end=1527
+  _globals['_HEALTHSTATUS']._serialized_start=1530
+  _globals['_HEALTHSTATUS']._serialized_end=1870
+  _globals['_HEALTHSTATUS_METRICSENTRY']._serialized_start=960
+  _globals['_HEALTHSTATUS_METRICSENTRY']._serialized_end=1006
+  _globals['_HEALTHSTATUS_SERVINGSTATUS']._serialized_start=1812
+  _globals['_HEALTHSTATUS_SERVINGSTATUS']._serialized_end=1870
+  _globals['_EMPTY']._serialized_start=1872
+  _globals['_EMPTY']._serialized_end=1879
+  _globals['_TASKIDENTITY']._serialized_start=1881
+  _globals['_TASKIDENTITY']._serialized_end=1943
+  _globals['_ACK']._serialized_start=1945
+  _globals['_ACK']._serialized_end=1984
+  _globals['_TASKSTATUS']._serialized_start=1987
+  _globals['_TASKSTATUS']._serialized_end=2174
+  _globals['_TASKLIST']._serialized_start=2176
+  _globals['_TASKLIST']._serialized_end=2292
+  _globals['_ALGOCONTROLSERVICE']._serialized_start=2295
+  _globals['_ALGOCONTROLSERVICE']._serialized_end=2769
+  _globals['_RESULTRECEIVERSERVICE']._serialized_start=2772
+  _globals['_RESULTRECEIVERSERVICE']._serialized_end=2914
 # @@protoc_insertion_point(module_scope)
@@ -380,6 +380,11 @@ class ResultReceiverServiceStub(object):
                 request_serializer=algorithm__pb2.TaskResult.SerializeToString,
                 response_deserializer=algorithm__pb2.Ack.FromString,
                 _registered_method=True)
+        self.ReportResultStream = channel.stream_unary(
+                '/algorithm.ResultReceiverService/ReportResultStream',
+                request_serializer=algorithm__pb2.ResultChunk.SerializeToString,
+                response_deserializer=algorithm__pb2.Ack.FromString,
+                _registered_method=True)
 
 
 class ResultReceiverServiceServicer(object):
@@ -393,6 +398,14 @@ class ResultReceiverServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def ReportResultStream(self, request_iterator, context):
+        """ReportResultStream reports a result too large for one message, sending
+        its result JSON in chunks; the result is acked once complete
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_ResultReceiverServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -401,6 +414,11 @@ def add_ResultReceiverServiceServicer_to_server(servicer, server):
                     request_deserializer=algorithm__pb2.TaskResult.FromString,
                     response_serializer=algorithm__pb2.Ack.SerializeToString,
             ),
+            'ReportResultStream': grpc.stream_unary_rpc_method_handler(
+                    servicer.ReportResultStream,
+                    request_deserializer=algorithm__pb2.ResultChunk.FromString,
+                    response_serializer=algorithm__pb2.Ack.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'algorithm.ResultReceiverService', rpc_method_handlers)
@@ -439,3 +457,30 @@ class ResultReceiverService(object):
             timeout,
             metadata,
             _registered_method=True)
+
+    @staticmethod
+    def ReportResultStream(request_iterator,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.stream_unary(
+            request_iterator,
+            target,
+            '/algorithm.ResultReceiverService/ReportResultStream',
+            algorithm__pb2.ResultChunk.SerializeToString,
+            algorithm__pb2.Ack.FromString,
+            options,
+            channel_credentials,
+            insecure,
+            call_credentials,
+            compression,
+            wait_for_ready,
+            timeout,
+            metadata,
+            _registered_method=True)
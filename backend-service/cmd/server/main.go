@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,6 +15,8 @@ import (
 	"github.com/electric-power/backend-service/internal/grpcclient"
 	"github.com/electric-power/backend-service/internal/grpcserver"
 	httpHandler "github.com/electric-power/backend-service/internal/http"
+	"github.com/electric-power/backend-service/internal/lifecycle"
+	"github.com/electric-power/backend-service/internal/middleware"
 	"github.com/electric-power/backend-service/internal/scheduler"
 	"github.com/electric-power/backend-service/internal/services"
 	"github.com/electric-power/backend-service/internal/storage"
@@ -43,7 +47,15 @@ import (
 // @in header
 // @name X-API-Key
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Bearer <JWT>
+
 func main() {
+	selfTest := flag.Bool("selftest", false, "check MySQL, Redis and the algorithm service, print a report and exit without starting the servers")
+	flag.Parse()
+
 	// Initialize logger
 	logger, err := zap.NewProduction()
 	if err != nil {
@@ -52,13 +64,26 @@ func main() {
 	defer logger.Sync()
 
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+
+	if *selfTest {
+		code := runSelfTest(cfg, logger)
+		logger.Sync()
+		os.Exit(code)
+	}
+
+	// Components register their shutdown hooks as they are created
+	shutdown := lifecycle.NewShutdownManager(logger)
 
 	// Initialize MySQL store
 	store, err := storage.NewMySQLStore(cfg.MySQLDSN)
 	if err != nil {
 		logger.Fatal("MySQL connect failed", zap.Error(err))
 	}
-	defer store.Close()
+	shutdown.RegisterCloser("mysql", lifecycle.PriorityStorages, store.Close)
+	store.EnableJobCache(time.Duration(cfg.JobCacheTTLMs)*time.Millisecond, time.Duration(cfg.JobCacheTerminalTTLSec)*time.Second)
 
 	if err := store.InitSchema(context.Background()); err != nil {
 		logger.Fatal("MySQL init schema failed", zap.Error(err))
@@ -66,30 +91,122 @@ func main() {
 	logger.Info("MySQL connected and schema initialized")
 
 	// Initialize Redis cache
-	cache := storage.NewRedisCache(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
-	if err := cache.Ping(context.Background()); err != nil {
-		logger.Warn("Redis ping failed, continuing without cache", zap.Error(err))
+	cache := storage.NewRedisCacheWithConfig(storage.RedisCacheConfig{
+		Addr:      cfg.RedisAddr,
+		Password:  cfg.RedisPassword,
+		DB:        cfg.RedisDB,
+		KeyPrefix: cfg.RedisKeyPrefix,
+	})
+	if err := cache.CheckHealth(context.Background()); err != nil {
+		logger.Warn("Redis ping failed, continuing in degraded mode without rate limiting or idempotency", zap.Error(err))
 	} else {
 		logger.Info("Redis connected")
 	}
-	defer cache.Close()
+	cache.MonitorHealth(time.Duration(cfg.RedisHealthIntervalSec)*time.Second, func(st storage.RedisStatus) {
+		if st.Available {
+			logger.Info("Redis available again, leaving degraded mode")
+		} else {
+			logger.Warn("Redis unavailable, entering degraded mode", zap.String("error", st.LastError))
+		}
+	})
+	shutdown.RegisterCloser("redis", lifecycle.PriorityStorages, cache.Close)
 
 	// Initialize WebSocket hub
-	hub := ws.NewHubWithLogger(logger)
-	defer hub.Close()
+	hubCfg := ws.DefaultHubConfig()
+	hubCfg.HeartbeatInterval = time.Duration(cfg.WSHeartbeatIntervalSec) * time.Second
+	hubCfg.MaxConnectionLifetime = time.Duration(cfg.WSMaxConnectionLifetimeSec) * time.Second
+	hubCfg.MaxClientsPerJob = cfg.WSMaxClientsPerJob
+	hubCfg.MaxClients = cfg.WSMaxClients
+	hubCfg.ReconnectBackoff = time.Duration(cfg.WSReconnectBackoffMs) * time.Millisecond
+	hub := ws.NewHubWithConfig(hubCfg, logger)
+	shutdown.Register("websocket-hub", lifecycle.PriorityHub, func(context.Context) error {
+		hub.Close()
+		return nil
+	})
 
 	// Initialize job service
 	jobs := services.NewJobService(store, cache, hub, cfg.SchemeCacheKey, cfg.ProgressCacheKeyNS)
+	jobs.SetLogger(logger)
+	jobs.SetMaxResultBytes(cfg.MaxResultBytes)
+	if len(cfg.SchemeConcurrencyLimits) > 0 {
+		jobs.SetSchemeLimiter(services.NewSchemeLimiter(cfg.SchemeConcurrencyLimits))
+	}
+	if cfg.WSProgressFanout {
+		fanout := services.NewProgressFanout(cache, hub, logger)
+		jobs.SetProgressFanout(fanout)
+		shutdown.Register("progress-fanout", lifecycle.PriorityHub, func(context.Context) error {
+			fanout.Close()
+			return nil
+		})
+	}
+	webhookCfg := services.DefaultWebhookConfig()
+	webhookCfg.Secret = cfg.WebhookSecret
+	webhookCfg.Timeout = time.Duration(cfg.WebhookTimeoutSec) * time.Second
+	webhookCfg.MaxAttempts = cfg.WebhookMaxAttempts
+	webhookCfg.AllowPrivateTargets = cfg.WebhookAllowPrivateTargets
+	webhooks := services.NewWebhookSender(webhookCfg, logger)
+	jobs.SetWebhookSender(webhooks)
+	shutdown.Register("webhooks", lifecycle.PriorityWorkers, webhooks.Close)
+	callbackCfg := services.DefaultCallbackWorkerConfig()
+	callbackCfg.PollInterval = time.Duration(cfg.WebhookPollIntervalSec) * time.Second
+	callbackWorker := services.NewCallbackWorker(store, webhooks, callbackCfg, logger)
+	callbackWorker.Start()
+	shutdown.Register("callback-worker", lifecycle.PriorityWorkers, callbackWorker.Close)
+	if cfg.EventsStream != "" {
+		eventCfg := services.DefaultAsyncEventConfig()
+		eventCfg.BufferSize = cfg.EventsBufferSize
+		eventCfg.Overflow = cfg.EventsOverflow
+		events := services.NewAsyncEventPublisher(
+			services.NewRedisStreamPublisher(cache, cfg.EventsStream, int64(cfg.EventsStreamMaxLen)), eventCfg, logger)
+		jobs.SetEventPublisher(events, cfg.EventsProgressMilestones)
+		// Publish buffered events while Redis is still open
+		shutdown.Register("job-events", lifecycle.PriorityClients, events.Close)
+	}
+	if cfg.TerminalBroadcastRate > 0 {
+		terminals := ws.NewThrottle(hub, ws.ThrottleConfig{
+			PerSecond: cfg.TerminalBroadcastRate,
+			Burst:     cfg.TerminalBroadcastBurst,
+			QueueSize: cfg.TerminalBroadcastQueue,
+		})
+		jobs.SetTerminalThrottle(terminals)
+		// Flush queued events before the hub closes
+		shutdown.Register("terminal-broadcasts", lifecycle.PriorityWorkers, func(context.Context) error {
+			terminals.Close()
+			return nil
+		})
+	}
 
 	// Initialize algorithm gRPC client with resilience
 	algoClientCfg := grpcclient.DefaultAlgoClientConfig(cfg.GRPCAlgoAddr)
+	algoClientCfg.BreakerThreshold = cfg.AlgoBreakerThreshold
+	algoClientCfg.BreakerWindow = time.Duration(cfg.AlgoBreakerWindowSec) * time.Second
+	algoClientCfg.BreakerCooldown = time.Duration(cfg.AlgoBreakerCooldownSec) * time.Second
+	algoClientCfg.Insecure = cfg.AlgoGRPCInsecure
+	algoClientCfg.TLSCAFile = cfg.AlgoGRPCTLSCAFile
+	algoClientCfg.TLSCertFile = cfg.AlgoGRPCTLSCertFile
+	algoClientCfg.TLSKeyFile = cfg.AlgoGRPCTLSKeyFile
+	algoClientCfg.TLSServerName = cfg.AlgoGRPCTLSServerName
 	algoClient, err := grpcclient.NewAlgoClientWithConfig(algoClientCfg, logger)
 	if err != nil {
 		logger.Fatal("Algorithm gRPC client connect failed", zap.Error(err))
 	}
-	defer algoClient.Close()
+	shutdown.RegisterCloser("algo-client", lifecycle.PriorityClients, algoClient.Close)
 	logger.Info("Algorithm gRPC client connected", zap.String("addr", cfg.GRPCAlgoAddr))
 
+	// Alternate algorithm services admins can route submissions to
+	algoTargets := make(map[string]*grpcclient.AlgoClient, len(cfg.AlgoTargets))
+	for name, addr := range cfg.AlgoTargets {
+		targetCfg := algoClientCfg
+		targetCfg.Address = addr
+		client, err := grpcclient.NewAlgoClientWithConfig(targetCfg, logger)
+		if err != nil {
+			logger.Fatal("Algorithm target connect failed", zap.String("target", name), zap.Error(err))
+		}
+		shutdown.RegisterCloser("algo-target-"+name, lifecycle.PriorityClients, client.Close)
+		algoTargets[name] = client
+		logger.Info("Algorithm target connected", zap.String("target", name), zap.String("addr", addr))
+	}
+
 	// Pre-cache algorithm schemes
 	if schemes, err := algoClient.GetSchemes(context.Background()); err == nil {
 		_ = jobs.CacheSchemes(context.Background(), schemes)
@@ -97,9 +214,49 @@ func main() {
 	}
 
 	// Initialize scheduler for background tasks
-	sched := scheduler.NewScheduler(store, cache, algoClient, logger)
+	schedCfg := scheduler.DefaultSchedulerConfig()
+	schedCfg.ZombieTimeout = time.Duration(cfg.ZombieTimeoutMin) * time.Minute
+	schedCfg.ZombieTimeoutOverrides = cfg.ZombieTimeoutOverrides
+	schedCfg.ZombieStrategy = storage.ZombieStrategy(cfg.ZombieStrategy)
+	schedCfg.ConfirmZombies = cfg.ZombieConfirm
+	schedCfg.ZombieBatchSize = cfg.ZombieBatchSize
+	schedCfg.MaxJitter = time.Duration(cfg.SchedulerJitterSec) * time.Second
+	schedCfg.ZombieCleanupSpec = cfg.ZombieCleanupSpec
+	schedCfg.AlgoHealthSpec = cfg.AlgoHealthSpec
+	schedCfg.SchemeRefreshSpec = cfg.SchemeRefreshSpec
+	schedCfg.RefreshSchemes = jobs.RefreshSchemes
+	schedCfg.SLACheckSpec = cfg.SLACheckSpec
+	schedCfg.SLAWarnWindow = time.Duration(cfg.SLAWarnWindowMin) * time.Minute
+	schedCfg.DeletedJobPurgeSpec = cfg.DeletedJobPurgeSpec
+	schedCfg.JobTimeoutSpec = cfg.JobTimeoutSpec
+	schedCfg.DeletedJobRetention = time.Duration(cfg.DeletedJobRetentionHours) * time.Hour
+	schedCfg.SchemeCacheKey = cfg.SchemeCacheKey
+	schedCfg.DisableZombieCleanup = cfg.DisableZombieCleanup
+	schedCfg.DisableAlgoHealth = cfg.DisableAlgoHealth
+	schedCfg.DisableSchemeRefresh = cfg.DisableSchemeRefresh
+	schedCfg.DisableSLACheck = cfg.DisableSLACheck
+	schedCfg.DisableDeletedJobPurge = cfg.DisableDeletedJobPurge
+	schedCfg.DisableJobTimeout = cfg.DisableJobTimeout
+	schedCfg.OnZombiesFailed = jobs.ZombiesFailed
+	schedCfg.OnJobsTimedOut = jobs.JobsTimedOut
+	schedCfg.AlgoTargets = algoTargets
+	schedCfg.OnSLAFlagged = jobs.SLAFlagged
+	schedCfg.OnAlgoHealthChange = func(status, previous string) {
+		hub.PublishSystem(ws.EventAlgoHealth, map[string]string{"status": status, "previous": previous})
+	}
+	sched, err := scheduler.NewSchedulerWithConfig(store, cache, algoClient, logger, schedCfg)
+	if err != nil {
+		logger.Fatal("Invalid scheduler configuration", zap.Error(err))
+	}
 	sched.Start()
-	defer sched.Stop()
+	shutdown.Register("scheduler", lifecycle.PriorityWorkers, func(ctx context.Context) error {
+		select {
+		case <-sched.Stop().Done():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
 	logger.Info("Background scheduler started")
 
 	// Start gRPC callback server for receiving results from algorithm service
@@ -112,7 +269,9 @@ func main() {
 		grpc.MaxRecvMsgSize(100*1024*1024), // 100MB for large results
 		grpc.MaxSendMsgSize(100*1024*1024),
 	)
-	pb.RegisterResultReceiverServiceServer(grpcServer, grpcserver.NewResultServer(jobs))
+	resultCfg := grpcserver.DefaultResultServerConfig()
+	resultCfg.MaxStreamedResultBytes = cfg.MaxStreamedResultBytes
+	pb.RegisterResultReceiverServiceServer(grpcServer, grpcserver.NewResultServerWithConfig(jobs, resultCfg))
 
 	go func() {
 		logger.Info("gRPC result server starting", zap.String("addr", cfg.GRPCResultAddr))
@@ -120,23 +279,152 @@ func main() {
 			logger.Error("gRPC serve failed", zap.Error(err))
 		}
 	}()
+	shutdown.Register("grpc-result-server", lifecycle.PriorityServers, func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			// Drain deadline hit, drop remaining RPCs
+			grpcServer.Stop()
+			return ctx.Err()
+		}
+	})
 
 	// Initialize HTTP handler and router
-	h := httpHandler.NewHandler(jobs, algoClient, store, cache)
+	schemeFilter, err := services.NewSchemeFilter(cfg.SchemeAllowlist, cfg.SchemeDenylist)
+	if err != nil {
+		logger.Fatal("Invalid scheme allow/deny list", zap.Error(err))
+	}
+	var dataStore storage.DataStore
+	if cfg.DataStoreBackend == "s3" {
+		s3Cfg := storage.DefaultS3DataStoreConfig()
+		s3Cfg.Endpoint = cfg.S3Endpoint
+		s3Cfg.Bucket = cfg.S3Bucket
+		s3Cfg.Region = cfg.S3Region
+		s3Cfg.AccessKey = cfg.S3AccessKey
+		s3Cfg.SecretKey = cfg.S3SecretKey
+		dataStore, err = storage.NewS3DataStore(s3Cfg)
+	} else {
+		dataStore, err = storage.NewLocalDataStore(cfg.DataDir)
+	}
+	if err != nil {
+		logger.Fatal("Failed to prepare data store", zap.Error(err))
+	}
+	handlerCfg := httpHandler.DefaultHandlerConfig()
+	handlerCfg.SchemeFilter = schemeFilter
+	handlerCfg.DataStore = dataStore
+	handlerCfg.InlineDataMaxBytes = int64(cfg.InlineDataMaxBytes)
+	handlerCfg.DataUploadMaxBytes = int64(cfg.DataUploadMaxBytes)
+	if len(cfg.DataUploadContentTypes) > 0 {
+		handlerCfg.DataUploadContentTypes = cfg.DataUploadContentTypes
+	}
+	handlerCfg.MaxExportRows = cfg.MaxExportRows
+	handlerCfg.MaxParamsBytes = cfg.MaxParamsBytes
+	handlerCfg.MaxBatchParamsBytes = cfg.MaxBatchParamsBytes
+	handlerCfg.MaxParamsDepth = cfg.MaxParamsDepth
+	handlerCfg.MaxParamsElements = cfg.MaxParamsElements
+	handlerCfg.UserIDStrategy = cfg.UserIDStrategy
+	handlerCfg.DefaultUserID = cfg.DefaultUserID
+	handlerCfg.HealthCheckTimeout = time.Duration(cfg.HealthCheckTimeoutMs) * time.Millisecond
+	handlerCfg.SchedulerStatus = sched.Status
+	handlerCfg.ResultServerAddr = grpcLis.Addr().String()
+	handlerCfg.ListCountTimeout = time.Duration(cfg.ListCountTimeoutMs) * time.Millisecond
+	handlerCfg.ListQueryTimeout = time.Duration(cfg.ListQueryTimeoutMs) * time.Millisecond
+	handlerCfg.SubmitWaitTimeout = time.Duration(cfg.SubmitWaitTimeoutSec) * time.Second
+	handlerCfg.BulkCancelConcurrency = cfg.BulkCancelConcurrency
+	handlerCfg.BatchDispatchConcurrency = cfg.BatchDispatchConcurrency
+	handlerCfg.DispatchMaxInFlight = cfg.DispatchMaxInFlight
+	handlerCfg.DispatchQueueCapacity = cfg.DispatchQueueCapacity
+	handlerCfg.DispatchSchemeLimits = cfg.DispatchSchemeLimits
+	handlerCfg.DispatchResourceLimits = cfg.DispatchResourceLimits
+	handlerCfg.AlgoTargets = algoTargets
+	handlerCfg.ColdSchemeCache = cfg.ColdSchemeCache
+	handlerCfg.ResultPathAllowlist = cfg.ResultPathAllowlist
+	handlerCfg.WatchHandoffTTL = time.Duration(cfg.WatchHandoffTTLSec) * time.Second
+	handlerCfg.ZombieStrategy = schedCfg.ZombieStrategy
+	handlerCfg.ZombieTimeout = schedCfg.ZombieTimeout
+	handlerCfg.ZombieTimeoutOverrides = schedCfg.ZombieTimeoutOverrides
+	h := httpHandler.NewHandlerWithConfig(jobs, algoClient, store, cache, handlerCfg)
+	h.SetLogger(logger)
+	// Terminal hooks such as pipeline advancement finish before the dispatch
+	// queue they may submit to closes
+	shutdown.Register("job-hooks", lifecycle.PriorityWorkers, jobs.WaitHooks)
+	shutdown.Register("dispatch-queue", lifecycle.PriorityWorkers, h.CloseDispatchQueue)
+	// Resume the watches of replicas that shut down, and hand ours over once
+	// the dispatch queue has drained
+	h.StartWatchAdoption()
+	shutdown.Register("watch-handoff", lifecycle.PriorityWorkers, func(ctx context.Context) error {
+		n, err := h.HandOffWatches(ctx)
+		if n > 0 {
+			logger.Info("Handed off progress watches", zap.Int("count", n))
+		}
+		return err
+	})
+	routeLimits := make(map[string]middleware.RouteRateLimit, len(cfg.RateLimitRoutes))
+	for pattern, limit := range cfg.RateLimitRoutes {
+		routeLimits[pattern] = middleware.RouteRateLimit(limit)
+	}
 	routerCfg := httpHandler.RouterConfig{
-		EnableSwagger:  true,
+		EnableSwagger:  cfg.EnableSwagger,
 		RateLimitRPS:   cfg.RateLimitRPS,
 		RequestTimeout: time.Duration(cfg.RequestTimeoutSec) * time.Second,
+
+		RateLimitTiers:       cfg.RateLimitTiers,
+		RateLimitClientTiers: cfg.RateLimitClientTiers,
+		RateLimitRoutes:      routeLimits,
+		RateLimitKeyNS:       cfg.RateLimitKeyNS,
+
+		MaxInFlightRequests: cfg.MaxInFlightRequests,
+
+		WSHandshakeTimeout: time.Duration(cfg.WSHandshakeTimeoutSec) * time.Second,
+		WSAllowedOrigins:   cfg.WSAllowedOrigins,
+
+		LogRequestBody:         cfg.LogRequestBody,
+		LogRequestBodyMaxBytes: cfg.LogRequestBodyMaxBytes,
+
+		ResponseEnvelope:    cfg.ResponseEnvelope,
+		IdempotencyHeader:   cfg.IdempotencyHeader,
+		IdempotencyTTL:      time.Duration(cfg.IdempotencyTTLSec) * time.Second,
+		IdempotencyClaimTTL: time.Duration(cfg.IdempotencyClaimTTLSec) * time.Second,
+		IdempotencyKeyNS:    cfg.IdempotencyKeyNS,
+
+		AdminAPIKey: cfg.AdminAPIKey,
+		JWTSecret:   cfg.JWTSecret,
+		JWTIssuer:   cfg.JWTIssuer,
+
+		TLSCertFile: cfg.TLSCertFile,
+		TLSKeyFile:  cfg.TLSKeyFile,
 	}
 	r := httpHandler.NewRouterWithConfig(h, hub, cache, logger, routerCfg)
+	httpServer := httpHandler.NewServer(cfg.HTTPAddr, r, routerCfg)
 
 	// Start HTTP server in goroutine
 	go func() {
-		logger.Info("HTTP server starting", zap.String("addr", cfg.HTTPAddr))
-		if err := r.Run(cfg.HTTPAddr); err != nil {
+		logger.Info("HTTP server starting", zap.String("addr", cfg.HTTPAddr), zap.Bool("tls", routerCfg.TLSEnabled()))
+		if err := httpHandler.ListenAndServe(httpServer, routerCfg); err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP serve failed", zap.Error(err))
 		}
 	}()
+	// Stop accepting connections and let in-flight requests finish
+	shutdown.Register("http-server", lifecycle.PriorityHTTP, httpServer.Shutdown)
+
+	// Optional plaintext listener redirecting to HTTPS
+	if routerCfg.TLSEnabled() && cfg.TLSRedirectAddr != "" {
+		_, httpsPort, _ := net.SplitHostPort(cfg.HTTPAddr)
+		redirectServer := httpHandler.NewRedirectServer(cfg.TLSRedirectAddr, httpsPort, routerCfg)
+		go func() {
+			logger.Info("HTTP->HTTPS redirect starting", zap.String("addr", cfg.TLSRedirectAddr))
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("HTTP redirect serve failed", zap.Error(err))
+			}
+		}()
+		shutdown.Register("http-redirect", lifecycle.PriorityHTTP, redirectServer.Shutdown)
+	}
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -145,19 +433,12 @@ func main() {
 
 	logger.Info("Shutting down...")
 
-	// Stop accepting new connections
-	grpcServer.GracefulStop()
-
-	// Wait for in-flight requests
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Stop scheduler
-	<-sched.Stop().Done()
-
-	// Close hub
-	hub.Close()
+	if err := shutdown.Shutdown(ctx); err != nil {
+		logger.Warn("Shutdown completed with errors", zap.Error(err))
+	}
 
 	logger.Info("Server shutdown complete")
-	_ = ctx
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/electric-power/backend-service/internal/config"
+	"github.com/electric-power/backend-service/internal/grpcclient"
+	"github.com/electric-power/backend-service/internal/selftest"
+	"github.com/electric-power/backend-service/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// runSelfTest checks MySQL, Redis and the algorithm service without starting
+// any server, prints a report to stdout and returns the process exit code
+func runSelfTest(cfg config.Config, logger *zap.Logger) int {
+	var checks []selftest.Check
+
+	if store, err := storage.NewMySQLStore(cfg.MySQLDSN); err != nil {
+		checks = append(checks, selftest.Failed("mysql", err))
+	} else {
+		defer store.Close()
+		checks = append(checks, selftest.StoreCheck("mysql", store))
+	}
+
+	cache := storage.NewRedisCacheWithConfig(storage.RedisCacheConfig{
+		Addr:      cfg.RedisAddr,
+		Password:  cfg.RedisPassword,
+		DB:        cfg.RedisDB,
+		KeyPrefix: cfg.RedisKeyPrefix,
+	})
+	defer cache.Close()
+	checks = append(checks, selftest.StoreCheck("redis", cache))
+
+	algoCfg := grpcclient.DefaultAlgoClientConfig(cfg.GRPCAlgoAddr)
+	algoCfg.MaxRetries = 0
+	if algo, err := grpcclient.NewAlgoClientWithConfig(algoCfg, logger); err != nil {
+		checks = append(checks, selftest.Failed("algo", err))
+	} else {
+		defer algo.Close()
+		checks = append(checks, selftest.AlgoCheck("algo", algo))
+	}
+
+	report := selftest.Run(context.Background(), selftest.DefaultTimeout, checks...)
+	_ = report.Write(os.Stdout)
+	return report.ExitCode()
+}
@@ -51,7 +51,7 @@ const docTemplate = `{
         },
         "/api/v1/jobs": {
             "get": {
-                "description": "Returns a paginated list of jobs with optional filters",
+                "description": "Returns a paginated list of jobs with optional filters. When counting the matching jobs times out, the page is still returned with total and pages null and count_unavailable true. With cursor or limit the list is paged by cursor instead: follow next_cursor, present while has_more is true; no total is returned.",
                 "consumes": ["application/json"],
                 "produces": ["application/json"],
                 "tags": ["jobs"],
@@ -60,7 +60,10 @@ const docTemplate = `{
                     {"type": "integer", "default": 1, "description": "Page number", "name": "page", "in": "query"},
                     {"type": "integer", "default": 20, "description": "Items per page", "name": "page_size", "in": "query"},
                     {"type": "string", "description": "Filter by user ID", "name": "user_id", "in": "query"},
-                    {"type": "string", "description": "Filter by status", "name": "status", "in": "query", "enum": ["PENDING", "RUNNING", "SUCCESS", "FAILED"]}
+                    {"type": "string", "description": "Filter by status", "name": "status", "in": "query", "enum": ["PENDING", "RUNNING", "SUCCESS", "FAILED"]},
+                    {"type": "boolean", "description": "Only jobs with a non-empty error_log, regardless of status", "name": "has_error", "in": "query"},
+                    {"type": "string", "description": "Cursor mode: next_cursor of the previous page, empty for the first page", "name": "cursor", "in": "query"},
+                    {"type": "integer", "default": 20, "description": "Cursor mode: items per page", "name": "limit", "in": "query"}
                 ],
                 "responses": {
                     "200": {
@@ -72,20 +75,24 @@ const docTemplate = `{
                                 "total": {"type": "integer"},
                                 "page": {"type": "integer"},
                                 "page_size": {"type": "integer"},
-                                "pages": {"type": "integer"}
+                                "pages": {"type": "integer"},
+                                "limit": {"type": "integer"},
+                                "has_more": {"type": "boolean"},
+                                "next_cursor": {"type": "string"}
                             }
                         }
                     }
                 }
             },
             "post": {
-                "description": "Creates a new job and dispatches it to the algorithm service for processing",
+                "description": "Creates a new job and dispatches it to the algorithm service for processing. With wait=accepted the response is held until the job reports progress or finishes; if that takes too long the job is returned as PENDING with a note and keeps running.",
                 "consumes": ["application/json"],
                 "produces": ["application/json"],
                 "tags": ["jobs"],
                 "summary": "Submit a new algorithm job",
                 "parameters": [
                     {"type": "string", "description": "Idempotency key", "name": "X-Request-ID", "in": "header"},
+                    {"type": "string", "description": "Set to accepted to wait until the job has started", "name": "wait", "in": "query", "enum": ["accepted"]},
                     {
                         "description": "Job submission request",
                         "name": "request",
@@ -103,12 +110,13 @@ const docTemplate = `{
                             "type": "object",
                             "properties": {
                                 "job_id": {"type": "string"},
-                                "status": {"type": "string"}
+                                "status": {"type": "string"},
+                                "note": {"type": "string"}
                             }
                         }
                     },
                     "400": {"description": "Bad Request", "schema": {"$ref": "#/definitions/ErrorResponse"}},
-                    "409": {"description": "Conflict - Duplicate request", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "409": {"description": "Conflict - A request with the same idempotency key is still being processed", "schema": {"$ref": "#/definitions/ErrorResponse"}},
                     "500": {"description": "Internal Server Error", "schema": {"$ref": "#/definitions/ErrorResponse"}}
                 }
             }
@@ -127,22 +135,42 @@ const docTemplate = `{
                     "200": {"description": "OK", "schema": {"type": "object", "properties": {"job": {"$ref": "#/definitions/Job"}}}},
                     "404": {"description": "Not Found", "schema": {"$ref": "#/definitions/ErrorResponse"}}
                 }
+            },
+            "delete": {
+                "description": "Soft-deletes a finished (SUCCESS, FAILED or CANCELLED) job: it disappears from job queries and statistics and is purged together with its result once the deleted job retention has passed.",
+                "consumes": ["application/json"],
+                "produces": ["application/json"],
+                "tags": ["jobs"],
+                "summary": "Delete a job",
+                "security": [{"BearerAuth": []}],
+                "parameters": [
+                    {"type": "string", "description": "Job ID", "name": "id", "in": "path", "required": true}
+                ],
+                "responses": {
+                    "200": {"description": "OK", "schema": {"type": "object"}},
+                    "400": {"description": "Job is still PENDING or RUNNING", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "401": {"description": "Unauthorized", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "403": {"description": "Forbidden", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "404": {"description": "Not Found", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "500": {"description": "Internal Server Error", "schema": {"$ref": "#/definitions/ErrorResponse"}}
+                }
             }
         },
         "/api/v1/jobs/{id}/result": {
             "get": {
-                "description": "Returns the result data for a completed job",
+                "description": "Returns the result data for a completed job. With path, only that part of the result is returned.",
                 "consumes": ["application/json"],
                 "produces": ["application/json"],
                 "tags": ["jobs"],
                 "summary": "Get job result",
                 "parameters": [
-                    {"type": "string", "description": "Job ID", "name": "id", "in": "path", "required": true}
+                    {"type": "string", "description": "Job ID", "name": "id", "in": "path", "required": true},
+                    {"type": "string", "description": "Dot-separated path into the result, e.g. summary.metrics or rows.0", "name": "path", "in": "query"}
                 ],
                 "responses": {
                     "200": {"description": "OK", "schema": {"type": "object"}},
-                    "400": {"description": "Job not completed", "schema": {"$ref": "#/definitions/ErrorResponse"}},
-                    "404": {"description": "Not Found", "schema": {"$ref": "#/definitions/ErrorResponse"}}
+                    "400": {"description": "Job not completed or invalid path", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "404": {"description": "Job or path not found", "schema": {"$ref": "#/definitions/ErrorResponse"}}
                 }
             }
         },
@@ -156,22 +184,26 @@ const docTemplate = `{
                 "parameters": [
                     {"type": "string", "description": "Job ID", "name": "id", "in": "path", "required": true}
                 ],
+                "security": [{"BearerAuth": []}],
                 "responses": {
                     "200": {"description": "OK", "schema": {"$ref": "#/definitions/SuccessResponse"}},
                     "400": {"description": "Cannot cancel", "schema": {"$ref": "#/definitions/ErrorResponse"}},
-                    "404": {"description": "Not Found", "schema": {"$ref": "#/definitions/ErrorResponse"}}
+                    "401": {"description": "Missing or invalid token", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "403": {"description": "Admin role required", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "404": {"description": "Not Found", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "409": {"description": "Job already completed", "schema": {"$ref": "#/definitions/ErrorResponse"}}
                 }
             }
         },
         "/api/v1/system/health": {
             "get": {
-                "description": "Returns the health status of the backend service and its dependencies",
+                "description": "Returns the health status of the backend service and its components; 503 when MySQL or the gRPC result listener is down",
                 "produces": ["application/json"],
                 "tags": ["system"],
                 "summary": "Health check",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Healthy or degraded",
                         "schema": {
                             "type": "object",
                             "properties": {
@@ -179,6 +211,16 @@ const docTemplate = `{
                                 "checks": {"type": "object"}
                             }
                         }
+                    },
+                    "503": {
+                        "description": "Unhealthy",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "status": {"type": "string", "enum": ["unhealthy"]},
+                                "checks": {"type": "object"}
+                            }
+                        }
                     }
                 }
             }
@@ -189,8 +231,11 @@ const docTemplate = `{
                 "produces": ["application/json"],
                 "tags": ["system"],
                 "summary": "Get system statistics",
+                "security": [{"BearerAuth": []}],
                 "responses": {
-                    "200": {"description": "OK", "schema": {"type": "object"}}
+                    "200": {"description": "OK", "schema": {"type": "object"}},
+                    "401": {"description": "Missing or invalid token", "schema": {"$ref": "#/definitions/ErrorResponse"}},
+                    "403": {"description": "Admin role required", "schema": {"$ref": "#/definitions/ErrorResponse"}}
                 }
             }
         },
@@ -210,6 +255,10 @@ const docTemplate = `{
             }
         }
     },
+    "securityDefinitions": {
+        "ApiKeyAuth": {"type": "apiKey", "name": "X-API-Key", "in": "header"},
+        "BearerAuth": {"type": "apiKey", "name": "Authorization", "in": "header", "description": "Bearer <JWT>"}
+    },
     "definitions": {
         "Scheme": {
             "type": "object",
@@ -261,7 +310,7 @@ const docTemplate = `{
             "properties": {
                 "error": {"type": "string", "example": "Invalid request"},
                 "message": {"type": "string", "example": "Detailed error message"},
-                "code": {"type": "integer", "example": 400}
+                "code": {"type": "string", "example": "VALIDATION_ERROR", "description": "Stable application error code"}
             }
         },
         "SuccessResponse": {
@@ -0,0 +1,123 @@
+// Package apperr defines the typed errors handlers report to clients. Each
+// error has a kind, which fixes both its HTTP status and its application
+// code; clients should match on the code, which is stable, rather than on
+// the status or the human-readable title.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies an error
+type Kind int
+
+const (
+	// KindInternal is a failure of this service, e.g. a database error
+	KindInternal Kind = iota
+	// KindValidation is a malformed or invalid request
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindMethodNotAllowed
+	// KindConflict is a request clashing with the resource's current state
+	KindConflict
+	KindTooLarge
+	KindUnsupportedMedia
+	KindRateLimited
+	// KindUpstream is a failure of a service this one depends on, e.g. the
+	// algorithm service
+	KindUpstream
+	// KindUnavailable is a feature that is disabled or temporarily out of
+	// capacity
+	KindUnavailable
+	// KindTimeout is a request that did not finish within its deadline
+	KindTimeout
+)
+
+var kinds = map[Kind]struct {
+	status int
+	code   string
+}{
+	KindInternal:         {http.StatusInternalServerError, "INTERNAL_ERROR"},
+	KindValidation:       {http.StatusBadRequest, "VALIDATION_ERROR"},
+	KindUnauthorized:     {http.StatusUnauthorized, "UNAUTHORIZED"},
+	KindForbidden:        {http.StatusForbidden, "FORBIDDEN"},
+	KindNotFound:         {http.StatusNotFound, "NOT_FOUND"},
+	KindMethodNotAllowed: {http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+	KindConflict:         {http.StatusConflict, "CONFLICT"},
+	KindTooLarge:         {http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE"},
+	KindUnsupportedMedia: {http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE"},
+	KindRateLimited:      {http.StatusTooManyRequests, "RATE_LIMITED"},
+	KindUpstream:         {http.StatusBadGateway, "UPSTREAM_ERROR"},
+	KindUnavailable:      {http.StatusServiceUnavailable, "UNAVAILABLE"},
+	KindTimeout:          {http.StatusGatewayTimeout, "TIMEOUT"},
+}
+
+// Status returns the HTTP status of the kind
+func (k Kind) Status() int {
+	if v, ok := kinds[k]; ok {
+		return v.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Code returns the application error code of the kind
+func (k Kind) Code() string {
+	if v, ok := kinds[k]; ok {
+		return v.code
+	}
+	return kinds[KindInternal].code
+}
+
+// Error is an error reported to clients: a short title and a detail message
+type Error struct {
+	Kind    Kind
+	Title   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Title
+	}
+	return e.Title + ": " + e.Message
+}
+
+// Status returns the HTTP status of the error
+func (e *Error) Status() int { return e.Kind.Status() }
+
+// Code returns the application error code of the error
+func (e *Error) Code() string { return e.Kind.Code() }
+
+// New returns an error of kind; message may be empty
+func New(kind Kind, title, message string) *Error {
+	return &Error{Kind: kind, Title: title, Message: message}
+}
+
+// Shorthands for New with each kind
+
+func Internal(title, message string) *Error         { return New(KindInternal, title, message) }
+func Validation(title, message string) *Error       { return New(KindValidation, title, message) }
+func Unauthorized(title, message string) *Error     { return New(KindUnauthorized, title, message) }
+func Forbidden(title, message string) *Error        { return New(KindForbidden, title, message) }
+func NotFound(title, message string) *Error         { return New(KindNotFound, title, message) }
+func MethodNotAllowed(title, message string) *Error { return New(KindMethodNotAllowed, title, message) }
+func Conflict(title, message string) *Error         { return New(KindConflict, title, message) }
+func TooLarge(title, message string) *Error         { return New(KindTooLarge, title, message) }
+func UnsupportedMedia(title, message string) *Error { return New(KindUnsupportedMedia, title, message) }
+func RateLimited(title, message string) *Error      { return New(KindRateLimited, title, message) }
+func Upstream(title, message string) *Error         { return New(KindUpstream, title, message) }
+func Unavailable(title, message string) *Error      { return New(KindUnavailable, title, message) }
+func Timeout(title, message string) *Error          { return New(KindTimeout, title, message) }
+
+// As returns err as an *Error; errors of other types are internal errors
+// titled "Internal error"
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal("Internal error", err.Error())
+}
@@ -0,0 +1,53 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindStatusAndCode(t *testing.T) {
+	tests := []struct {
+		err    *Error
+		status int
+		code   string
+	}{
+		{Internal("Failed to list jobs", "db down"), http.StatusInternalServerError, "INTERNAL_ERROR"},
+		{Validation("Invalid request", "scheme is required"), http.StatusBadRequest, "VALIDATION_ERROR"},
+		{Unauthorized("Unauthorized", ""), http.StatusUnauthorized, "UNAUTHORIZED"},
+		{Forbidden("Forbidden", ""), http.StatusForbidden, "FORBIDDEN"},
+		{NotFound("Job not found", ""), http.StatusNotFound, "NOT_FOUND"},
+		{MethodNotAllowed("Method not allowed", ""), http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+		{Conflict("Job not resumable", ""), http.StatusConflict, "CONFLICT"},
+		{TooLarge("Data too large", ""), http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE"},
+		{UnsupportedMedia("Unsupported media type", ""), http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE"},
+		{RateLimited("Scheme at capacity", ""), http.StatusTooManyRequests, "RATE_LIMITED"},
+		{Upstream("Failed to submit job", "connection refused"), http.StatusBadGateway, "UPSTREAM_ERROR"},
+		{Unavailable("Dispatch queue full", ""), http.StatusServiceUnavailable, "UNAVAILABLE"},
+		{Timeout("Request timeout", ""), http.StatusGatewayTimeout, "TIMEOUT"},
+	}
+	codes := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			assert.Equal(t, tt.status, tt.err.Status())
+			assert.Equal(t, tt.code, tt.err.Code())
+		})
+		assert.False(t, codes[tt.code], "code %s is used by one kind only", tt.code)
+		codes[tt.code] = true
+	}
+	assert.Equal(t, http.StatusInternalServerError, Kind(99).Status())
+	assert.Equal(t, "INTERNAL_ERROR", Kind(99).Code())
+}
+
+func TestAs(t *testing.T) {
+	notFound := NotFound("Job not found", "no job job-1")
+	assert.Same(t, notFound, As(fmt.Errorf("lookup: %w", notFound)))
+	assert.Equal(t, "Job not found: no job job-1", notFound.Error())
+
+	other := As(errors.New("boom"))
+	assert.Equal(t, KindInternal, other.Kind)
+	assert.Equal(t, "boom", other.Message)
+}
@@ -1,90 +1,601 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the backend service
 type Config struct {
 	// HTTP Server
-	HTTPAddr          string
-	RateLimitRPS      int
-	RequestTimeoutSec int
+	HTTPAddr             string
+	RateLimitRPS         int
+	RequestTimeoutSec    int
+	HealthCheckTimeoutMs int
+	ListCountTimeoutMs   int
+	ListQueryTimeoutMs   int
+	MaxInFlightRequests  int
+	SubmitWaitTimeoutSec int
+	// RateLimitTiers caps requests per minute by tier; RateLimitClientTiers
+	// assigns users or IPs to a tier
+	RateLimitTiers       map[string]int
+	RateLimitClientTiers map[string]string
+	// RateLimitRoutes limits each client per route pattern, e.g.
+	// {"POST /api/v1/jobs": {"rps": 2, "burst": 5}, "*": {"rps": 50, "burst": 100}}
+	RateLimitRoutes map[string]RouteRateLimit
+
+	// HTTPS: served directly when both files are set; TLSRedirectAddr, if
+	// set, listens in plaintext and redirects to HTTPS
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSRedirectAddr string
+
+	// WebSocket
+	WSHandshakeTimeoutSec      int
+	WSHeartbeatIntervalSec     int
+	WSMaxConnectionLifetimeSec int
+	// WSAllowedOrigins lists browser origins allowed to open WebSockets
+	WSAllowedOrigins []string
+	// WSMaxClientsPerJob and WSMaxClients cap WebSocket connections (0 means
+	// no cap); refused clients are told to reconnect after a jittered backoff
+	WSMaxClientsPerJob   int
+	WSMaxClients         int
+	WSReconnectBackoffMs int
+	// WSProgressFanout relays job progress between replicas over Redis
+	// pub/sub, for deployments running more than one instance
+	WSProgressFanout bool
+	// TerminalBroadcastRate smooths job terminal events to at most this many
+	// per second after a burst of TerminalBroadcastBurst, queueing up to
+	// TerminalBroadcastQueue of them (rate 0 broadcasts immediately)
+	TerminalBroadcastRate  int
+	TerminalBroadcastBurst int
+	TerminalBroadcastQueue int
 
 	// gRPC
 	GRPCAlgoAddr   string
 	GRPCResultAddr string
+	// Algorithm client circuit breaker: AlgoBreakerThreshold failures within
+	// AlgoBreakerWindowSec open it for AlgoBreakerCooldownSec (0 disables)
+	AlgoBreakerThreshold   int
+	AlgoBreakerWindowSec   int
+	AlgoBreakerCooldownSec int
+	// AlgoTargets maps names admins can pick with X-Algo-Target to alternate
+	// algorithm service addresses, e.g. for canary versions. Commas separate
+	// the pairs, so a target cannot be a comma-separated address list; a
+	// balanced target uses a dns:/// address instead.
+	AlgoTargets map[string]string
+	// Algorithm client TLS: AlgoGRPCInsecure dials in plaintext; otherwise
+	// the server is verified against AlgoGRPCTLSCAFile (system roots when
+	// empty) and the cert/key pair, when set, enables mutual TLS
+	AlgoGRPCInsecure      bool
+	AlgoGRPCTLSCAFile     string
+	AlgoGRPCTLSCertFile   string
+	AlgoGRPCTLSKeyFile    string
+	AlgoGRPCTLSServerName string
+
+	// Webhooks: bodies are signed with WebhookSecret (HMAC-SHA256) and each
+	// delivery is attempted up to WebhookMaxAttempts times. Completion and
+	// milestone callbacks wait in an outbox polled every
+	// WebhookPollIntervalSec. Private and loopback targets are refused
+	// unless WebhookAllowPrivateTargets is set.
+	WebhookSecret              string
+	WebhookTimeoutSec          int
+	WebhookMaxAttempts         int
+	WebhookPollIntervalSec     int
+	WebhookAllowPrivateTargets bool
+
+	// Job lifecycle events are appended to the Redis stream EventsStream
+	// (empty disables them), trimmed to about EventsStreamMaxLen entries.
+	// Up to EventsBufferSize events wait to be published; once full, new
+	// ones are dropped or wait, per EventsOverflow ("drop" or "block").
+	// A milestone event is sent at each of EventsProgressMilestones.
+	EventsStream             string
+	EventsStreamMaxLen       int
+	EventsBufferSize         int
+	EventsOverflow           string
+	EventsProgressMilestones []int
 
 	// Database
 	MySQLDSN string
+	// JobCacheTTLMs caches single-job reads in memory (0 disables);
+	// finished jobs are kept for JobCacheTerminalTTLSec instead
+	JobCacheTTLMs          int
+	JobCacheTerminalTTLSec int
 
 	// Redis
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
+	// RedisHealthIntervalSec is how often Redis is pinged; while it is down,
+	// rate limiting and idempotency let requests through
+	RedisHealthIntervalSec int
+	// WatchHandoffTTLSec is how long the progress watches an instance hands
+	// off at shutdown wait in Redis for another instance to adopt them
+	WatchHandoffTTLSec int
 
-	// Cache Keys
+	// Cache Keys: RedisKeyPrefix is prepended to every key and pub/sub
+	// channel, so environments sharing a Redis instance do not collide; the
+	// namespaces below come after it
+	RedisKeyPrefix     string
 	SchemeCacheKey     string
 	ProgressCacheKeyNS string
+	IdempotencyKeyNS   string
+	RateLimitKeyNS     string
+
+	// Job input data is stored by DataStoreBackend: "local" writes files
+	// under DataDir, "s3" uploads them to S3Bucket at S3Endpoint
+	DataStoreBackend string
+	DataDir          string
+	S3Endpoint       string
+	S3Bucket         string
+	S3Region         string
+	S3AccessKey      string
+	S3SecretKey      string
+	// InlineDataMaxBytes caps inline job data and DataUploadMaxBytes uploaded
+	// files, whose media type must be one of DataUploadContentTypes (empty
+	// keeps the built-in list)
+	InlineDataMaxBytes     int
+	DataUploadMaxBytes     int
+	DataUploadContentTypes []string
+
+	// Export
+	MaxExportRows int
+
+	// Serialized params caps per job and per batch (0 means no cap)
+	MaxParamsBytes      int
+	MaxBatchParamsBytes int
+	// Params shape caps: nesting depth and values across all levels (0 means no cap)
+	MaxParamsDepth    int
+	MaxParamsElements int
+
+	// MaxResultBytes fails jobs whose reported result is larger (0 means no cap)
+	MaxResultBytes int
+	// MaxStreamedResultBytes refuses ReportResultStream calls whose result
+	// grows larger (0 means no cap)
+	MaxStreamedResultBytes int
+
+	// Submission user: "default" falls back to DefaultUserID, "require"
+	// rejects anonymous submissions, "auth" always uses the caller identity
+	UserIDStrategy string
+	DefaultUserID  string
+
+	// ColdSchemeCache decides submissions arriving before schemes are cached:
+	// "strict" fetches them to validate, "optimistic" dispatches unvalidated
+	ColdSchemeCache string
+
+	// Zombie detection: RUNNING jobs without updates for longer than the
+	// timeout are failed. Overrides are per scheme code, e.g. "SCM-WF01=4h".
+	ZombieTimeoutMin       int
+	ZombieTimeoutOverrides map[string]time.Duration
+	// ZombieStrategy is "updated_at" (no updates at all) or "progress"
+	// (progress percentage not advancing, even with heartbeats)
+	ZombieStrategy string
+	// ZombieConfirm asks the algorithm service before failing a zombie and
+	// spares jobs it still reports as active
+	ZombieConfirm bool
+	// ZombieBatchSize is how many zombies one cleanup step finds and marks
+	ZombieBatchSize int
+	// SLAWarnWindowMin is how many minutes before its sla_deadline an active
+	// job is flagged as at risk
+	SLAWarnWindowMin int
+	// DeletedJobRetentionHours is how long soft-deleted jobs are kept
+	// before they are purged along with their results
+	DeletedJobRetentionHours int
+	// SchedulerJitterSec is the upper bound of the random delay before each scheduled task
+	SchedulerJitterSec int
+	// Cron specs (with a seconds field) of the scheduled tasks, and switches
+	// to turn each off
+	ZombieCleanupSpec      string
+	AlgoHealthSpec         string
+	SchemeRefreshSpec      string
+	SLACheckSpec           string
+	DeletedJobPurgeSpec    string
+	JobTimeoutSpec         string
+	DisableZombieCleanup   bool
+	DisableAlgoHealth      bool
+	DisableSchemeRefresh   bool
+	DisableSLACheck        bool
+	DisableDeletedJobPurge bool
+	DisableJobTimeout      bool
+
+	// Per-scheme cap on in-flight jobs, e.g. "KBM-WF03=2"
+	SchemeConcurrencyLimits map[string]int
+
+	// Result paths shown to non-admins per scheme code, "|"-separated, with
+	// "*" for other schemes, e.g. "KBM-WF01=summary|metrics.rmse,*=summary"
+	ResultPathAllowlist map[string][]string
+
+	// Scheme visibility (comma-separated glob patterns, e.g. "KBM-*,SCM-WF0?")
+	SchemeAllowlist []string
+	SchemeDenylist  []string
+
+	// AdminAPIKey guards admin-only endpoints; empty disables them
+	AdminAPIKey string
+	// JWTSecret enables Bearer token auth on the API; JWTIssuer restricts the issuer
+	JWTSecret string
+	JWTIssuer string
+	// BulkCancelConcurrency caps concurrent cancels of admin bulk cancellation
+	BulkCancelConcurrency int
+	// BatchDispatchConcurrency caps concurrent dispatches within one batch submission
+	BatchDispatchConcurrency int
+
+	// DispatchMaxInFlight caps concurrent submissions to the algorithm service
+	// (0 dispatches inline); DispatchQueueCapacity caps the jobs waiting
+	DispatchMaxInFlight   int
+	DispatchQueueCapacity int
+	// Per-scheme and per-resource-type caps on dispatches in flight, e.g.
+	// "SCM-WF01=4" and "GPU=8"; they need the dispatch queue
+	DispatchSchemeLimits   map[string]int
+	DispatchResourceLimits map[string]int
 
 	// Feature Flags
 	EnableSwagger bool
+	// ResponseEnvelope wraps responses in {data, error, meta} by default
+	ResponseEnvelope bool
+	// IdempotencyHeader carries client idempotency keys on job submissions
+	IdempotencyHeader string
+	// IdempotencyTTLSec is how long a submission's response is replayed;
+	// IdempotencyClaimTTLSec how long a key is held while it is processed
+	IdempotencyTTLSec      int
+	IdempotencyClaimTTLSec int
+
+	// Debugging
+	LogRequestBody         bool
+	LogRequestBodyMaxBytes int
+
+	// malformed lists the variables Load ignored because they did not parse
+	malformed []string
+}
+
+// RouteRateLimit is a token bucket of RATE_LIMIT_ROUTES: clients may make
+// RPS requests per second on average, and up to Burst at once
+type RouteRateLimit struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
 }
 
-// Load reads configuration from environment variables with sensible defaults
+// envReader reads environment variables, collecting the ones that do not
+// parse so Validate can report them
+type envReader struct {
+	malformed []string
+}
+
+func (e *envReader) reject(format string, args ...any) {
+	e.malformed = append(e.malformed, fmt.Sprintf(format, args...))
+}
+
+// Load reads configuration from environment variables with sensible
+// defaults. Malformed values fall back to the default and are reported by
+// Validate.
 func Load() Config {
-	return Config{
+	e := &envReader{}
+	cfg := Config{
 		// HTTP
-		HTTPAddr:          getEnv("HTTP_ADDR", ":8080"),
-		RateLimitRPS:      getEnvInt("RATE_LIMIT_RPS", 100),
-		RequestTimeoutSec: getEnvInt("REQUEST_TIMEOUT_SEC", 30),
+		HTTPAddr:             e.getEnv("HTTP_ADDR", ":8080"),
+		RateLimitRPS:         e.getEnvInt("RATE_LIMIT_RPS", 100),
+		RateLimitTiers:       e.getEnvIntMap("RATE_LIMIT_TIERS"),
+		RateLimitClientTiers: e.getEnvStringMap("RATE_LIMIT_CLIENT_TIERS"),
+		RateLimitRoutes:      e.getEnvRouteRateLimits("RATE_LIMIT_ROUTES"),
+		RequestTimeoutSec:    e.getEnvInt("REQUEST_TIMEOUT_SEC", 30),
+		HealthCheckTimeoutMs: e.getEnvInt("HEALTH_CHECK_TIMEOUT_MS", 2000),
+		ListCountTimeoutMs:   e.getEnvInt("LIST_COUNT_TIMEOUT_MS", 3000),
+		ListQueryTimeoutMs:   e.getEnvInt("LIST_QUERY_TIMEOUT_MS", 10000),
+		MaxInFlightRequests:  e.getEnvInt("MAX_IN_FLIGHT_REQUESTS", 0),
+		SubmitWaitTimeoutSec: e.getEnvInt("SUBMIT_WAIT_TIMEOUT_SEC", 10),
+
+		// HTTPS
+		TLSCertFile:     e.getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:      e.getEnv("TLS_KEY_FILE", ""),
+		TLSRedirectAddr: e.getEnv("TLS_REDIRECT_ADDR", ""),
+
+		// WebSocket
+		WSHandshakeTimeoutSec:      e.getEnvInt("WS_HANDSHAKE_TIMEOUT_SEC", 10),
+		WSHeartbeatIntervalSec:     e.getEnvInt("WS_HEARTBEAT_INTERVAL_SEC", 30),
+		WSMaxConnectionLifetimeSec: e.getEnvInt("WS_MAX_CONNECTION_LIFETIME_SEC", 0),
+		WSAllowedOrigins:           e.getEnvList("WS_ALLOWED_ORIGINS"),
+		WSMaxClientsPerJob:         e.getEnvInt("WS_MAX_CLIENTS_PER_JOB", 0),
+		WSMaxClients:               e.getEnvInt("WS_MAX_CLIENTS", 0),
+		WSReconnectBackoffMs:       e.getEnvInt("WS_RECONNECT_BACKOFF_MS", 2000),
+		WSProgressFanout:           e.getEnvBool("WS_PROGRESS_FANOUT", false),
+		TerminalBroadcastRate:      e.getEnvInt("TERMINAL_BROADCAST_RATE", 200),
+		TerminalBroadcastBurst:     e.getEnvInt("TERMINAL_BROADCAST_BURST", 50),
+		TerminalBroadcastQueue:     e.getEnvInt("TERMINAL_BROADCAST_QUEUE", 1000),
 
 		// gRPC
-		GRPCAlgoAddr:   getEnv("ALGO_GRPC_ADDR", "127.0.0.1:50051"),
-		GRPCResultAddr: getEnv("RESULT_GRPC_ADDR", ":9090"),
+		GRPCAlgoAddr:   e.getEnv("ALGO_GRPC_ADDR", "127.0.0.1:50051"),
+		GRPCResultAddr: e.getEnv("RESULT_GRPC_ADDR", ":9090"),
+
+		AlgoBreakerThreshold:   e.getEnvInt("ALGO_BREAKER_THRESHOLD", 5),
+		AlgoBreakerWindowSec:   e.getEnvInt("ALGO_BREAKER_WINDOW_SEC", 60),
+		AlgoBreakerCooldownSec: e.getEnvInt("ALGO_BREAKER_COOLDOWN_SEC", 30),
+		AlgoTargets:            e.getEnvStringMap("ALGO_TARGETS"),
+		AlgoGRPCInsecure:       e.getEnvBool("ALGO_GRPC_INSECURE", true),
+		AlgoGRPCTLSCAFile:      e.getEnv("ALGO_GRPC_TLS_CA_FILE", ""),
+		AlgoGRPCTLSCertFile:    e.getEnv("ALGO_GRPC_TLS_CERT_FILE", ""),
+		AlgoGRPCTLSKeyFile:     e.getEnv("ALGO_GRPC_TLS_KEY_FILE", ""),
+		AlgoGRPCTLSServerName:  e.getEnv("ALGO_GRPC_TLS_SERVER_NAME", ""),
+
+		// Webhooks
+		WebhookSecret:              e.getEnv("WEBHOOK_SECRET", ""),
+		WebhookTimeoutSec:          e.getEnvInt("WEBHOOK_TIMEOUT_SEC", 10),
+		WebhookMaxAttempts:         e.getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookPollIntervalSec:     e.getEnvInt("WEBHOOK_POLL_INTERVAL_SEC", 2),
+		WebhookAllowPrivateTargets: e.getEnvBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+
+		// Lifecycle events
+		EventsStream:             e.getEnv("EVENTS_STREAM", ""),
+		EventsStreamMaxLen:       e.getEnvInt("EVENTS_STREAM_MAXLEN", 100000),
+		EventsBufferSize:         e.getEnvInt("EVENTS_BUFFER_SIZE", 1024),
+		EventsOverflow:           e.getEnv("EVENTS_OVERFLOW", "drop"),
+		EventsProgressMilestones: e.getEnvIntList("EVENTS_PROGRESS_MILESTONES", []int{25, 50, 75}),
 
 		// MySQL
-		MySQLDSN: getEnv("MYSQL_DSN", "root:password@tcp(127.0.0.1:3306)/epdd_db?parseTime=true"),
+		MySQLDSN:               e.getEnv("MYSQL_DSN", "root:password@tcp(127.0.0.1:3306)/epdd_db?parseTime=true"),
+		JobCacheTTLMs:          e.getEnvInt("JOB_CACHE_TTL_MS", 1000),
+		JobCacheTerminalTTLSec: e.getEnvInt("JOB_CACHE_TERMINAL_TTL_SEC", 30),
 
 		// Redis
-		RedisAddr:     getEnv("REDIS_ADDR", "127.0.0.1:6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getEnvInt("REDIS_DB", 0),
+		RedisAddr:     e.getEnv("REDIS_ADDR", "127.0.0.1:6379"),
+		RedisPassword: e.getEnv("REDIS_PASSWORD", ""),
+		RedisDB:       e.getEnvInt("REDIS_DB", 0),
+
+		RedisHealthIntervalSec: e.getEnvInt("REDIS_HEALTH_INTERVAL_SEC", 5),
+		WatchHandoffTTLSec:     e.getEnvInt("WATCH_HANDOFF_TTL_SEC", 600),
 
 		// Cache
-		SchemeCacheKey:     getEnv("SCHEME_CACHE_KEY", "sys:algo:schemes"),
-		ProgressCacheKeyNS: getEnv("PROGRESS_KEY_NS", "job:progress:"),
+		RedisKeyPrefix:     e.getEnv("REDIS_KEY_PREFIX", ""),
+		SchemeCacheKey:     e.getEnv("SCHEME_CACHE_KEY", "sys:algo:schemes"),
+		ProgressCacheKeyNS: e.getEnv("PROGRESS_KEY_NS", "job:progress:"),
+		IdempotencyKeyNS:   e.getEnv("IDEMPOTENCY_KEY_NS", "idempotency:"),
+		RateLimitKeyNS:     e.getEnv("RATE_LIMIT_KEY_NS", "ratelimit:"),
+
+		// Job input data
+		DataStoreBackend:       e.getEnv("DATA_STORE_BACKEND", "local"),
+		DataDir:                e.getEnv("DATA_DIR", "./data/uploads"),
+		S3Endpoint:             e.getEnv("S3_ENDPOINT", ""),
+		S3Bucket:               e.getEnv("S3_BUCKET", ""),
+		S3Region:               e.getEnv("S3_REGION", "us-east-1"),
+		S3AccessKey:            e.getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:            e.getEnv("S3_SECRET_KEY", ""),
+		InlineDataMaxBytes:     e.getEnvInt("INLINE_DATA_MAX_BYTES", 1<<20),
+		DataUploadMaxBytes:     e.getEnvInt("DATA_UPLOAD_MAX_BYTES", 256<<20),
+		DataUploadContentTypes: e.getEnvList("DATA_UPLOAD_CONTENT_TYPES"),
+
+		// Export
+		MaxExportRows: e.getEnvInt("MAX_EXPORT_ROWS", 100000),
+
+		// Params caps
+		MaxParamsBytes:      e.getEnvInt("MAX_PARAMS_BYTES", 256<<10),
+		MaxBatchParamsBytes: e.getEnvInt("MAX_BATCH_PARAMS_BYTES", 4<<20),
+		MaxParamsDepth:      e.getEnvInt("MAX_PARAMS_DEPTH", 32),
+		MaxParamsElements:   e.getEnvInt("MAX_PARAMS_ELEMENTS", 100000),
+
+		// Result cap
+		MaxResultBytes:         e.getEnvInt("MAX_RESULT_BYTES", 64<<20),
+		MaxStreamedResultBytes: e.getEnvInt("MAX_STREAMED_RESULT_BYTES", 512<<20),
+
+		// Submission user
+		UserIDStrategy: e.getEnv("USER_ID_STRATEGY", "default"),
+		DefaultUserID:  e.getEnv("DEFAULT_USER_ID", "anonymous"),
+
+		// Scheme validation
+		ColdSchemeCache: e.getEnv("COLD_SCHEME_CACHE", "optimistic"),
+
+		// Zombie detection
+		ZombieTimeoutMin:         e.getEnvInt("ZOMBIE_TIMEOUT_MIN", 30),
+		ZombieTimeoutOverrides:   e.getEnvDurationMap("ZOMBIE_TIMEOUT_OVERRIDES"),
+		ZombieStrategy:           e.getEnv("ZOMBIE_STRATEGY", "updated_at"),
+		ZombieConfirm:            e.getEnvBool("ZOMBIE_CONFIRM", false),
+		ZombieBatchSize:          e.getEnvInt("ZOMBIE_BATCH_SIZE", 500),
+		SLAWarnWindowMin:         e.getEnvInt("SLA_WARN_WINDOW_MIN", 15),
+		DeletedJobRetentionHours: e.getEnvInt("DELETED_JOB_RETENTION_HOURS", 30*24),
+		SchedulerJitterSec:       e.getEnvInt("SCHEDULER_JITTER_SEC", 0),
+		ZombieCleanupSpec:        e.getEnv("ZOMBIE_CLEANUP_CRON", "0 */5 * * * *"),
+		AlgoHealthSpec:           e.getEnv("ALGO_HEALTH_CRON", "*/30 * * * * *"),
+		SchemeRefreshSpec:        e.getEnv("SCHEME_REFRESH_CRON", "0 * * * * *"),
+		SLACheckSpec:             e.getEnv("SLA_CHECK_CRON", "30 * * * * *"),
+		DeletedJobPurgeSpec:      e.getEnv("DELETED_JOB_PURGE_CRON", "0 15 * * * *"),
+		JobTimeoutSpec:           e.getEnv("JOB_TIMEOUT_CRON", "*/10 * * * * *"),
+		DisableZombieCleanup:     e.getEnvBool("DISABLE_ZOMBIE_CLEANUP", false),
+		DisableAlgoHealth:        e.getEnvBool("DISABLE_ALGO_HEALTH_CHECK", false),
+		DisableSchemeRefresh:     e.getEnvBool("DISABLE_SCHEME_REFRESH", false),
+		DisableSLACheck:          e.getEnvBool("DISABLE_SLA_CHECK", false),
+		DisableDeletedJobPurge:   e.getEnvBool("DISABLE_DELETED_JOB_PURGE", false),
+		DisableJobTimeout:        e.getEnvBool("DISABLE_JOB_TIMEOUT_CHECK", false),
+
+		// Scheme concurrency
+		SchemeConcurrencyLimits: e.getEnvIntMap("SCHEME_CONCURRENCY_LIMITS"),
+		ResultPathAllowlist:     e.getEnvListMap("RESULT_PATH_ALLOWLIST"),
+
+		// Scheme visibility
+		SchemeAllowlist: e.getEnvList("SCHEME_ALLOWLIST"),
+		SchemeDenylist:  e.getEnvList("SCHEME_DENYLIST"),
+
+		// Admin
+		AdminAPIKey:              e.getEnv("ADMIN_API_KEY", ""),
+		JWTSecret:                e.getEnv("JWT_SECRET", ""),
+		JWTIssuer:                e.getEnv("JWT_ISSUER", ""),
+		BulkCancelConcurrency:    e.getEnvInt("BULK_CANCEL_CONCURRENCY", 8),
+		BatchDispatchConcurrency: e.getEnvInt("BATCH_DISPATCH_CONCURRENCY", 8),
+
+		// Dispatch
+		DispatchMaxInFlight:    e.getEnvInt("DISPATCH_MAX_IN_FLIGHT", 32),
+		DispatchQueueCapacity:  e.getEnvInt("DISPATCH_QUEUE_CAPACITY", 10000),
+		DispatchSchemeLimits:   e.getEnvIntMap("DISPATCH_SCHEME_LIMITS"),
+		DispatchResourceLimits: e.getEnvIntMap("DISPATCH_RESOURCE_LIMITS"),
 
 		// Features
-		EnableSwagger: getEnvBool("ENABLE_SWAGGER", true),
+		EnableSwagger:          e.getEnvBool("ENABLE_SWAGGER", true),
+		ResponseEnvelope:       e.getEnvBool("RESPONSE_ENVELOPE", false),
+		IdempotencyHeader:      e.getEnv("IDEMPOTENCY_HEADER", "X-Request-ID"),
+		IdempotencyTTLSec:      e.getEnvInt("IDEMPOTENCY_TTL_SEC", 600),
+		IdempotencyClaimTTLSec: e.getEnvInt("IDEMPOTENCY_CLAIM_TTL_SEC", 60),
+
+		// Debugging
+		LogRequestBody:         e.getEnvBool("LOG_REQUEST_BODY", false),
+		LogRequestBodyMaxBytes: e.getEnvInt("LOG_REQUEST_BODY_MAX_BYTES", 4096),
 	}
+	cfg.malformed = e.malformed
+	return cfg
 }
 
-func getEnv(key, fallback string) string {
+func (e *envReader) getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
 
-func getEnvInt(key string, fallback int) int {
+func (e *envReader) getEnvInt(key string, fallback int) int {
 	v := os.Getenv(key)
 	if v == "" {
 		return fallback
 	}
-	var out int
-	_, err := fmt.Sscanf(v, "%d", &out)
+	out, err := strconv.Atoi(strings.TrimSpace(v))
 	if err != nil {
+		e.reject("%s=%q is not an integer", key, v)
 		return fallback
 	}
 	return out
 }
 
-func getEnvBool(key string, fallback bool) bool {
+func (e *envReader) getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	switch v {
+	case "true", "1", "yes":
+		return true
+	case "false", "0", "no":
+		return false
+	}
+	e.reject("%s=%q is not a boolean (true/false, 1/0, yes/no)", key, v)
+	return fallback
+}
+
+func (e *envReader) getEnvList(key string) []string {
 	v := os.Getenv(key)
 	if v == "" {
+		return nil
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// getEnvIntList parses comma-separated integers
+func (e *envReader) getEnvIntList(key string, fallback []int) []int {
+	items := e.getEnvList(key)
+	if items == nil {
 		return fallback
 	}
-	return v == "true" || v == "1" || v == "yes"
+	out := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			e.reject("%s=%q is not a list of integers", key, os.Getenv(key))
+			return fallback
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// getEnvDurationMap parses "key=duration" pairs separated by commas; invalid
+// pairs are skipped and reported
+func (e *envReader) getEnvDurationMap(key string) map[string]time.Duration {
+	out := map[string]time.Duration{}
+	for _, item := range e.getEnvList(key) {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			e.reject("%s pair %q is not key=duration", key, item)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || d <= 0 {
+			e.reject("%s pair %q needs a positive duration", key, item)
+			continue
+		}
+		out[strings.TrimSpace(k)] = d
+	}
+	return out
+}
+
+// getEnvStringMap parses "key=value" pairs separated by commas; pairs with an
+// empty key or value are skipped and reported
+func (e *envReader) getEnvStringMap(key string) map[string]string {
+	out := map[string]string{}
+	for _, item := range e.getEnvList(key) {
+		k, v, ok := strings.Cut(item, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			e.reject("%s pair %q is not key=value", key, item)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// getEnvIntMap parses "key=N" pairs separated by commas; invalid pairs are
+// skipped and reported
+func (e *envReader) getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for _, item := range e.getEnvList(key) {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			e.reject("%s pair %q is not key=N", key, item)
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			e.reject("%s pair %q needs a positive integer", key, item)
+			continue
+		}
+		out[strings.TrimSpace(k)] = n
+	}
+	return out
+}
+
+// getEnvRouteRateLimits parses a JSON object of route pattern to limit
+func (e *envReader) getEnvRouteRateLimits(key string) map[string]RouteRateLimit {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	var out map[string]RouteRateLimit
+	if err := json.Unmarshal([]byte(v), &out); err != nil {
+		e.reject("%s is not a JSON object of {\"rps\", \"burst\"} limits: %v", key, err)
+		return nil
+	}
+	return out
+}
+
+// getEnvListMap parses "key=a|b" pairs separated by commas; pairs with an
+// empty key or no values are skipped and reported
+func (e *envReader) getEnvListMap(key string) map[string][]string {
+	out := map[string][]string{}
+	for k, v := range e.getEnvStringMap(key) {
+		var values []string
+		for _, item := range strings.Split(v, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		if len(values) == 0 {
+			e.reject("%s pair %q has no values", key, k+"="+v)
+			continue
+		}
+		out[k] = values
+	}
+	return out
 }
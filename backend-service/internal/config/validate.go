@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Validate reports every problem with the configuration at once: malformed
+// variables, a MySQL DSN that does not parse, a Redis address that is not
+// host:port, non-positive rate limit, rate limit tier, request timeout or
+// health check timeout, route rate limits
+// with a malformed pattern or non-positive limits, missing gRPC
+// addresses, an algorithm client certificate without its key or vice versa,
+// an unknown or incomplete data store backend, a bad event buffer,
+// overflow policy or milestone, an unknown user ID strategy or cold scheme
+// cache policy and dispatch limits without a dispatch queue
+func (c Config) Validate() error {
+	problems := append([]string(nil), c.malformed...)
+
+	if _, err := mysql.ParseDSN(c.MySQLDSN); err != nil {
+		problems = append(problems, fmt.Sprintf("MYSQL_DSN does not parse: %v", err))
+	}
+	if err := checkHostPort(c.RedisAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("REDIS_ADDR %q is not host:port: %v", c.RedisAddr, err))
+	}
+	if c.RateLimitRPS <= 0 {
+		problems = append(problems, fmt.Sprintf("RATE_LIMIT_RPS must be positive, got %d", c.RateLimitRPS))
+	}
+	tiers := make([]string, 0, len(c.RateLimitTiers))
+	for tier := range c.RateLimitTiers {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+	for _, tier := range tiers {
+		if limit := c.RateLimitTiers[tier]; limit <= 0 {
+			problems = append(problems, fmt.Sprintf("RATE_LIMIT_TIERS %q must be positive, got %d", tier, limit))
+		}
+	}
+	if c.RequestTimeoutSec <= 0 {
+		problems = append(problems, fmt.Sprintf("REQUEST_TIMEOUT_SEC must be positive, got %d", c.RequestTimeoutSec))
+	}
+	if c.HealthCheckTimeoutMs <= 0 {
+		problems = append(problems, fmt.Sprintf("HEALTH_CHECK_TIMEOUT_MS must be positive, got %d", c.HealthCheckTimeoutMs))
+	}
+	if strings.TrimSpace(c.GRPCAlgoAddr) == "" {
+		problems = append(problems, "ALGO_GRPC_ADDR must be set")
+	}
+	if strings.TrimSpace(c.GRPCResultAddr) == "" {
+		problems = append(problems, "RESULT_GRPC_ADDR must be set")
+	}
+	if (c.AlgoGRPCTLSCertFile == "") != (c.AlgoGRPCTLSKeyFile == "") {
+		problems = append(problems, "ALGO_GRPC_TLS_CERT_FILE and ALGO_GRPC_TLS_KEY_FILE must be set together")
+	}
+	patterns := make([]string, 0, len(c.RateLimitRoutes))
+	for pattern := range c.RateLimitRoutes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		limit := c.RateLimitRoutes[pattern]
+		if err := checkRoutePattern(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("RATE_LIMIT_ROUTES pattern %q: %v", pattern, err))
+		}
+		if limit.RPS <= 0 || limit.Burst <= 0 {
+			problems = append(problems, fmt.Sprintf("RATE_LIMIT_ROUTES %q needs positive rps and burst", pattern))
+		}
+	}
+	if c.EventsStream != "" {
+		if c.EventsBufferSize <= 0 {
+			problems = append(problems, fmt.Sprintf("EVENTS_BUFFER_SIZE must be positive, got %d", c.EventsBufferSize))
+		}
+		if c.EventsOverflow != "drop" && c.EventsOverflow != "block" {
+			problems = append(problems, fmt.Sprintf("EVENTS_OVERFLOW must be drop or block, got %q", c.EventsOverflow))
+		}
+		for _, m := range c.EventsProgressMilestones {
+			if m < 1 || m > 100 {
+				problems = append(problems, fmt.Sprintf("EVENTS_PROGRESS_MILESTONES must be between 1 and 100, got %d", m))
+			}
+		}
+	}
+	switch c.UserIDStrategy {
+	case "default", "require", "auth":
+	default:
+		problems = append(problems, fmt.Sprintf("USER_ID_STRATEGY must be default, require or auth, got %q", c.UserIDStrategy))
+	}
+	if c.ColdSchemeCache != "strict" && c.ColdSchemeCache != "optimistic" {
+		problems = append(problems, fmt.Sprintf("COLD_SCHEME_CACHE must be strict or optimistic, got %q", c.ColdSchemeCache))
+	}
+	if (len(c.DispatchSchemeLimits) > 0 || len(c.DispatchResourceLimits) > 0) && c.DispatchMaxInFlight <= 0 {
+		problems = append(problems, "DISPATCH_SCHEME_LIMITS and DISPATCH_RESOURCE_LIMITS need DISPATCH_MAX_IN_FLIGHT to be positive")
+	}
+	switch c.DataStoreBackend {
+	case "local":
+	case "s3":
+		if c.S3Endpoint == "" || c.S3Bucket == "" {
+			problems = append(problems, "S3_ENDPOINT and S3_BUCKET must be set when DATA_STORE_BACKEND=s3")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("DATA_STORE_BACKEND must be local or s3, got %q", c.DataStoreBackend))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+// routeMethods are the methods a route rate limit pattern may name
+var routeMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// checkRoutePattern checks a route rate limit pattern is "*", a route path
+// or a method and a route path
+func checkRoutePattern(pattern string) error {
+	if pattern == "*" {
+		return nil
+	}
+	path := pattern
+	if method, rest, ok := strings.Cut(pattern, " "); ok {
+		if !routeMethods[method] {
+			return fmt.Errorf("unknown method %q", method)
+		}
+		path = rest
+	}
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("path must start with /")
+	}
+	return nil
+}
+
+// checkHostPort checks addr has a non-empty host and a numeric port
+func checkHostPort(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return errors.New("missing host")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
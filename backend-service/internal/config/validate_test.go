@@ -0,0 +1,131 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validConfig returns the defaults, which must validate
+func validConfig(t *testing.T) Config {
+	t.Helper()
+	cfg := Load()
+	require.NoError(t, cfg.Validate())
+	return cfg
+}
+
+func TestValidateBranches(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"unparsable MySQL DSN", func(c *Config) { c.MySQLDSN = "root:password@tcp(127.0.0.1:3306" }, "MYSQL_DSN does not parse"},
+		{"Redis address without port", func(c *Config) { c.RedisAddr = "127.0.0.1" }, `REDIS_ADDR "127.0.0.1" is not host:port`},
+		{"Redis address without host", func(c *Config) { c.RedisAddr = ":6379" }, "missing host"},
+		{"Redis address with bad port", func(c *Config) { c.RedisAddr = "redis:http" }, `invalid port "http"`},
+		{"zero rate limit", func(c *Config) { c.RateLimitRPS = 0 }, "RATE_LIMIT_RPS must be positive, got 0"},
+		{"zero rate limit tier", func(c *Config) { c.RateLimitTiers = map[string]int{"batch": 0} }, `RATE_LIMIT_TIERS "batch" must be positive, got 0`},
+		{"negative request timeout", func(c *Config) { c.RequestTimeoutSec = -1 }, "REQUEST_TIMEOUT_SEC must be positive, got -1"},
+		{"zero health check timeout", func(c *Config) { c.HealthCheckTimeoutMs = 0 }, "HEALTH_CHECK_TIMEOUT_MS must be positive, got 0"},
+		{"missing algorithm address", func(c *Config) { c.GRPCAlgoAddr = " " }, "ALGO_GRPC_ADDR must be set"},
+		{"missing result address", func(c *Config) { c.GRPCResultAddr = "" }, "RESULT_GRPC_ADDR must be set"},
+		{"algorithm client cert without key", func(c *Config) { c.AlgoGRPCTLSCertFile = "client.pem" }, "ALGO_GRPC_TLS_CERT_FILE and ALGO_GRPC_TLS_KEY_FILE must be set together"},
+		{"unknown data store backend", func(c *Config) { c.DataStoreBackend = "nfs" }, `DATA_STORE_BACKEND must be local or s3, got "nfs"`},
+		{"route rate limit with unknown method", func(c *Config) {
+			c.RateLimitRoutes = map[string]RouteRateLimit{"FETCH /api/v1/jobs": {RPS: 1, Burst: 1}}
+		}, `RATE_LIMIT_ROUTES pattern "FETCH /api/v1/jobs": unknown method "FETCH"`},
+		{"route rate limit without burst", func(c *Config) {
+			c.RateLimitRoutes = map[string]RouteRateLimit{"/api/v1/jobs/:id": {RPS: 10}}
+		}, `RATE_LIMIT_ROUTES "/api/v1/jobs/:id" needs positive rps and burst`},
+		{"unknown event overflow policy", func(c *Config) { c.EventsStream, c.EventsOverflow = "job:events", "spill" }, `EVENTS_OVERFLOW must be drop or block, got "spill"`},
+		{"event milestone out of range", func(c *Config) {
+			c.EventsStream, c.EventsProgressMilestones = "job:events", []int{50, 150}
+		}, "EVENTS_PROGRESS_MILESTONES must be between 1 and 100, got 150"},
+		{"unknown user ID strategy", func(c *Config) { c.UserIDStrategy = "header" }, `USER_ID_STRATEGY must be default, require or auth, got "header"`},
+		{"unknown cold scheme cache policy", func(c *Config) { c.ColdSchemeCache = "Strict" }, `COLD_SCHEME_CACHE must be strict or optimistic, got "Strict"`},
+		{"dispatch limits without a dispatch queue", func(c *Config) {
+			c.DispatchMaxInFlight, c.DispatchResourceLimits = 0, map[string]int{"GPU": 2}
+		}, "DISPATCH_SCHEME_LIMITS and DISPATCH_RESOURCE_LIMITS need DISPATCH_MAX_IN_FLIGHT to be positive"},
+		{"S3 data store without bucket", func(c *Config) { c.DataStoreBackend, c.S3Endpoint = "s3", "http://minio:9000" }, "S3_ENDPOINT and S3_BUCKET must be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestValidateReportsMalformedVariables(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "lots")
+	t.Setenv("ZOMBIE_CONFIRM", "maybe")
+	t.Setenv("EVENTS_PROGRESS_MILESTONES", "25,half")
+	cfg := Load()
+
+	// The defaults are kept, but startup is refused
+	assert.Equal(t, 100, cfg.RateLimitRPS)
+	assert.False(t, cfg.ZombieConfirm)
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `RATE_LIMIT_RPS="lots" is not an integer`)
+	assert.Contains(t, err.Error(), `ZOMBIE_CONFIRM="maybe" is not a boolean`)
+	assert.Contains(t, err.Error(), `EVENTS_PROGRESS_MILESTONES="25,half" is not a list of integers`)
+}
+
+func TestValidateReportsMalformedMapPairs(t *testing.T) {
+	t.Setenv("SCHEME_CONCURRENCY_LIMITS", "KBM-WF01=4,SCM-WF01=many,STM-WF02")
+	t.Setenv("ZOMBIE_TIMEOUT_OVERRIDES", "KBM-WF01=2h,SCM-WF01=soon")
+	t.Setenv("RESULT_PATH_ALLOWLIST", "KBM-WF01=summary,SCM-WF01=|")
+	cfg := Load()
+
+	// Good pairs are kept, bad ones are reported
+	assert.Equal(t, map[string]int{"KBM-WF01": 4}, cfg.SchemeConcurrencyLimits)
+	assert.Len(t, cfg.ZombieTimeoutOverrides, 1)
+	assert.Equal(t, map[string][]string{"KBM-WF01": {"summary"}}, cfg.ResultPathAllowlist)
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `SCHEME_CONCURRENCY_LIMITS pair "SCM-WF01=many" needs a positive integer`)
+	assert.Contains(t, err.Error(), `SCHEME_CONCURRENCY_LIMITS pair "STM-WF02" is not key=N`)
+	assert.Contains(t, err.Error(), `ZOMBIE_TIMEOUT_OVERRIDES pair "SCM-WF01=soon" needs a positive duration`)
+	assert.Contains(t, err.Error(), `RESULT_PATH_ALLOWLIST pair "SCM-WF01=|" has no values`)
+
+	// A target cannot be an address list, as commas separate the targets
+	t.Setenv("ALGO_TARGETS", "canary=10.0.0.8:50051,10.0.0.9:50051")
+	err = Load().Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `ALGO_TARGETS pair "10.0.0.9:50051" is not key=value`)
+}
+
+func TestLoadRouteRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ROUTES", `{"POST /api/v1/jobs": {"rps": 0.5, "burst": 5}, "*": {"rps": 50, "burst": 100}}`)
+	cfg := Load()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, map[string]RouteRateLimit{
+		"POST /api/v1/jobs": {RPS: 0.5, Burst: 5},
+		"*":                 {RPS: 50, Burst: 100},
+	}, cfg.RateLimitRoutes)
+
+	t.Setenv("RATE_LIMIT_ROUTES", `POST /api/v1/jobs=5`)
+	err := Load().Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RATE_LIMIT_ROUTES is not a JSON object")
+}
+
+func TestValidateCombinesProblems(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.RedisAddr = "redis"
+	cfg.RateLimitRPS = -5
+	cfg.GRPCAlgoAddr = ""
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Equal(t,
+		`invalid configuration: REDIS_ADDR "redis" is not host:port: address redis: missing port in address; `+
+			`RATE_LIMIT_RPS must be positive, got -5; ALGO_GRPC_ADDR must be set`,
+		err.Error())
+}
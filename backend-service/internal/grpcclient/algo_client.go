@@ -13,12 +13,14 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 )
 
 // AlgoClientConfig holds configuration for the algorithm gRPC client
 type AlgoClientConfig struct {
+	// Address is a host:port, a comma-separated list of them, or a dns:///
+	// target; calls are balanced round robin across the backends of a list
+	// or of a name resolving to several addresses
 	Address            string
 	MaxRetries         int
 	InitialBackoff     time.Duration
@@ -28,6 +30,30 @@ type AlgoClientConfig struct {
 	KeepAliveInterval  time.Duration
 	KeepAliveTimeout   time.Duration
 	MaxConcurrentCalls int
+
+	// BreakerThreshold consecutive failures within BreakerWindow open the
+	// circuit breaker (0 disables it; a zero window never expires failures)
+	BreakerThreshold int
+	BreakerWindow    time.Duration
+	// BreakerCooldown is how long the breaker stays open before probing again
+	BreakerCooldown time.Duration
+
+	// WatchInitialBackoff and WatchMaxBackoff pace progress stream reconnects
+	WatchInitialBackoff time.Duration
+	WatchMaxBackoff     time.Duration
+	// WatchMaxRetries consecutive reconnects without a new update end the
+	// watch with an error (0 retries forever)
+	WatchMaxRetries int
+
+	// Insecure dials in plaintext. Otherwise the connection uses TLS verified
+	// against TLSCAFile (the system roots when empty), checking TLSServerName
+	// instead of the dialed host when set; TLSCertFile and TLSKeyFile add a
+	// client certificate for mutual TLS.
+	Insecure      bool
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSServerName string
 }
 
 // DefaultAlgoClientConfig returns sensible defaults for high-concurrency scenarios
@@ -42,6 +68,15 @@ func DefaultAlgoClientConfig(addr string) AlgoClientConfig {
 		KeepAliveInterval:  10 * time.Second,
 		KeepAliveTimeout:   3 * time.Second,
 		MaxConcurrentCalls: 100,
+		BreakerThreshold:   5,
+		BreakerWindow:      time.Minute,
+		BreakerCooldown:    30 * time.Second,
+
+		WatchInitialBackoff: 200 * time.Millisecond,
+		WatchMaxBackoff:     10 * time.Second,
+		WatchMaxRetries:     5,
+
+		Insecure: true,
 	}
 }
 
@@ -54,6 +89,7 @@ type AlgoClient struct {
 	mu      sync.RWMutex
 	sem     chan struct{} // Semaphore for concurrency control
 	healthy bool
+	breaker *circuitBreaker
 }
 
 // NewAlgoClient creates a new resilient gRPC client
@@ -67,8 +103,13 @@ func NewAlgoClientWithConfig(cfg AlgoClientConfig, logger *zap.Logger) (*AlgoCli
 		logger, _ = zap.NewProduction()
 	}
 
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:                cfg.KeepAliveInterval,
 			Timeout:             cfg.KeepAliveTimeout,
@@ -83,7 +124,8 @@ func NewAlgoClientWithConfig(cfg AlgoClientConfig, logger *zap.Logger) (*AlgoCli
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, cfg.Address, opts...)
+	target, targetOpts := dialTarget(cfg.Address)
+	conn, err := grpc.DialContext(ctx, target, append(opts, targetOpts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +138,9 @@ func NewAlgoClientWithConfig(cfg AlgoClientConfig, logger *zap.Logger) (*AlgoCli
 		sem:     make(chan struct{}, cfg.MaxConcurrentCalls),
 		healthy: true,
 	}
+	if cfg.BreakerThreshold > 0 {
+		ac.breaker = newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerWindow, cfg.BreakerCooldown)
+	}
 
 	// Start connection state watcher
 	go ac.watchConnectionState()
@@ -103,6 +148,9 @@ func NewAlgoClientWithConfig(cfg AlgoClientConfig, logger *zap.Logger) (*AlgoCli
 	return ac, nil
 }
 
+// watchConnectionState tracks the connection's state. Under round robin the
+// connection is ready while at least one backend is, so the client stays
+// healthy until every backend is down.
 func (c *AlgoClient) watchConnectionState() {
 	for {
 		state := c.conn.GetState()
@@ -116,13 +164,24 @@ func (c *AlgoClient) watchConnectionState() {
 	}
 }
 
-// IsHealthy returns true if the connection is in a healthy state
+// IsHealthy returns true if the connection is in a healthy state, i.e. at
+// least one backend is reachable
 func (c *AlgoClient) IsHealthy() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.healthy
 }
 
+// BreakerStatus reports the circuit breaker state for health checks
+func (c *AlgoClient) BreakerStatus() BreakerStatus {
+	return c.breaker.status()
+}
+
+// BreakerState returns the circuit breaker state: closed, open or half-open
+func (c *AlgoClient) BreakerState() string {
+	return c.breaker.status().State
+}
+
 // Close closes the gRPC connection
 func (c *AlgoClient) Close() error {
 	return c.conn.Close()
@@ -150,7 +209,11 @@ func (c *AlgoClient) retry(ctx context.Context, op func() error) error {
 	b.MaxElapsedTime = c.config.RequestTimeout
 
 	return backoff.Retry(func() error {
+		if err := c.breaker.allow(); err != nil {
+			return backoff.Permanent(err)
+		}
 		err := op()
+		c.breaker.record(err)
 		if err != nil {
 			c.logger.Warn("gRPC call failed, retrying", zap.Error(err))
 		}
@@ -177,11 +240,12 @@ func (c *AlgoClient) GetSchemes(ctx context.Context) ([]models.Scheme, error) {
 		schemes = make([]models.Scheme, 0, len(resp.Schemes))
 		for _, s := range resp.Schemes {
 			schemes = append(schemes, models.Scheme{
-				Model:        s.Model,
-				Code:         s.Code,
-				Name:         s.Name,
-				ClassName:    s.ClassName,
-				ResourceType: s.ResourceType,
+				Model:              s.Model,
+				Code:               s.Code,
+				Name:               s.Name,
+				ClassName:          s.ClassName,
+				ResourceType:       s.ResourceType,
+				SupportsCheckpoint: s.SupportsCheckpoint,
 			})
 		}
 		return nil
@@ -189,39 +253,56 @@ func (c *AlgoClient) GetSchemes(ctx context.Context) ([]models.Scheme, error) {
 	return schemes, err
 }
 
-// SubmitJob submits a job with retry logic
-func (c *AlgoClient) SubmitJob(ctx context.Context, schemeCode, dataRef string, params map[string]any, taskID string) error {
+// SubmitJob submits a job with retry logic and returns the task ID the
+// algorithm service will use for it, which is taskID unless the service
+// assigned its own
+func (c *AlgoClient) SubmitJob(ctx context.Context, schemeCode, dataRef string, params map[string]any, metadata map[string]string, taskID string) (string, error) {
 	if err := c.acquireSemaphore(ctx); err != nil {
-		return err
+		return "", err
 	}
 	defer c.releaseSemaphore()
 
 	payload, _ := json.Marshal(params)
-	return c.retry(ctx, func() error {
+	assigned := taskID
+	err := c.retry(ctx, func() error {
 		ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
 		defer cancel()
 
-		_, err := c.client.SubmitTask(ctx, &pb.TaskRequest{
+		resp, err := c.client.SubmitTask(ctx, &pb.TaskRequest{
 			TaskId:     taskID,
 			SchemeCode: schemeCode,
 			DataRef:    dataRef,
 			ParamsJson: string(payload),
+			Metadata:   metadata,
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		if resp.GetTaskId() != "" {
+			assigned = resp.GetTaskId()
+		}
+		return nil
 	})
+	return assigned, err
 }
 
 // WatchProgress streams progress updates for a task
-// Note: Streaming calls are not retried automatically, caller should handle reconnection
+// Note: Streaming calls are not retried automatically; use WatchProgressResilient
+// to have reconnection handled
 func (c *AlgoClient) WatchProgress(ctx context.Context, taskID string) (pb.AlgoControlService_WatchTaskProgressClient, error) {
 	return c.client.WatchTaskProgress(ctx, &pb.TaskIdentity{TaskId: taskID})
 }
 
 // Health performs a health check with timeout
 func (c *AlgoClient) Health(ctx context.Context) (*pb.HealthStatus, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
-	return c.client.CheckHealth(ctx, &pb.Empty{})
+	resp, err := c.client.CheckHealth(ctx, &pb.Empty{})
+	c.breaker.record(err)
+	return resp, err
 }
 
 // ListTasks retrieves all tasks
@@ -0,0 +1,54 @@
+package grpcclient
+
+import (
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// roundRobinServiceConfig spreads calls across every resolved backend that
+// is ready, skipping the ones that are down
+const roundRobinServiceConfig = `{"loadBalancingConfig":[{"round_robin":{}}]}`
+
+// staticScheme is the resolver scheme of comma-separated address lists; each
+// connection registers its own resolver under it
+const staticScheme = "algo-static"
+
+// dialTarget returns the gRPC target for an AlgoClientConfig address and
+// the dial options it needs. A single host:port is dialed as is, with gRPC's
+// default pick_first policy. A comma-separated list of host:port addresses,
+// or a dns:/// target, is balanced round robin across its backends.
+func dialTarget(address string) (string, []grpc.DialOption) {
+	var addrs []string
+	for _, addr := range strings.Split(address, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	switch {
+	case len(addrs) > 1:
+		state := resolver.State{Addresses: make([]resolver.Address, len(addrs))}
+		for i, addr := range addrs {
+			// Each backend is verified against its own host under TLS, as
+			// when it is dialed alone
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			state.Addresses[i] = resolver.Address{Addr: addr, ServerName: host}
+		}
+		r := manual.NewBuilderWithScheme(staticScheme)
+		r.InitialState(state)
+		return staticScheme + ":///algo-service", []grpc.DialOption{
+			grpc.WithResolvers(r),
+			grpc.WithDefaultServiceConfig(roundRobinServiceConfig),
+		}
+	case len(addrs) == 1 && strings.HasPrefix(addrs[0], "dns:"):
+		return addrs[0], []grpc.DialOption{grpc.WithDefaultServiceConfig(roundRobinServiceConfig)}
+	default:
+		return address, nil
+	}
+}
@@ -0,0 +1,101 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// countingServer counts the CheckHealth calls it answers
+type countingServer struct {
+	pb.UnimplementedAlgoControlServiceServer
+	calls atomic.Int32
+}
+
+func (s *countingServer) CheckHealth(context.Context, *pb.Empty) (*pb.HealthStatus, error) {
+	s.calls.Add(1)
+	return &pb.HealthStatus{Status: pb.HealthStatus_SERVING}, nil
+}
+
+// startCountingServer serves srv on a loopback listener and returns its
+// address and a func stopping it
+func startCountingServer(t *testing.T, srv *countingServer) (string, func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pb.RegisterAlgoControlServiceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String(), server.Stop
+}
+
+func TestAlgoClientBalancesAcrossAddresses(t *testing.T) {
+	a, b := &countingServer{}, &countingServer{}
+	addrA, stopA := startCountingServer(t, a)
+	addrB, _ := startCountingServer(t, b)
+
+	cfg := DefaultAlgoClientConfig(addrA + ", " + addrB)
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 0
+	client, err := NewAlgoClientWithConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Round robin only picks connected backends, so wait for both to take calls
+	require.Eventually(t, func() bool {
+		if _, err := client.Health(context.Background()); err != nil {
+			return false
+		}
+		return a.calls.Load() > 0 && b.calls.Load() > 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	a.calls.Store(0)
+	b.calls.Store(0)
+	for i := 0; i < 20; i++ {
+		_, err := client.Health(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(10), a.calls.Load())
+	assert.Equal(t, int32(10), b.calls.Load())
+	assert.True(t, client.IsHealthy())
+
+	// With one backend down, calls go to the other and the client stays healthy
+	stopA()
+	require.Eventually(t, func() bool {
+		for i := 0; i < 5; i++ {
+			if _, err := client.Health(context.Background()); err != nil {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.True(t, client.IsHealthy())
+	b.calls.Store(0)
+	_, err = client.Health(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), b.calls.Load())
+}
+
+func TestDialTarget(t *testing.T) {
+	target, opts := dialTarget("10.0.0.1:50051")
+	assert.Equal(t, "10.0.0.1:50051", target)
+	assert.Empty(t, opts, "a single address keeps the default policy")
+
+	target, opts = dialTarget("dns:///algo.internal:50051")
+	assert.Equal(t, "dns:///algo.internal:50051", target)
+	assert.Len(t, opts, 1)
+
+	target, opts = dialTarget("10.0.0.1:50051, 10.0.0.2:50051")
+	assert.Equal(t, staticScheme+":///algo-service", target)
+	assert.Len(t, opts, 2)
+}
@@ -0,0 +1,152 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned without contacting the algorithm service while the breaker is open
+var ErrCircuitOpen = errors.New("algorithm service circuit breaker is open")
+
+// BreakerStatus is a point-in-time view of the circuit breaker
+type BreakerStatus struct {
+	State               string
+	ConsecutiveFailures int
+	// NextProbeAt is when an open breaker will let a probe through (zero when closed)
+	NextProbeAt time.Time
+}
+
+// circuitBreaker trips after threshold consecutive failures within window
+// and stays open for cooldown, after which a single probe call decides
+// whether it closes. A zero window counts failures however far apart.
+type circuitBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu             sync.Mutex
+	state          string
+	failures       int
+	firstFailureAt time.Time
+	openedAt       time.Time
+	probing        bool
+}
+
+func newCircuitBreaker(threshold int, window, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+}
+
+// allow reports whether a call may proceed. In half-open only one probe is
+// admitted at a time; its outcome must be reported through record.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openedAt.Add(b.cooldown)) {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an admitted call
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !isBreakerFailure(err) {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	now := b.now()
+	if b.failures == 0 || b.streakExpired(now) {
+		b.failures = 0
+		b.firstFailureAt = now
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+// streakExpired reports whether the current run of failures started too
+// long ago to count towards tripping a closed breaker
+func (b *circuitBreaker) streakExpired(now time.Time) bool {
+	return b.state == BreakerClosed && b.window > 0 && now.Sub(b.firstFailureAt) > b.window
+}
+
+// status returns the current breaker state. An open breaker whose cooldown
+// has elapsed is reported as half-open since the next call will probe.
+func (b *circuitBreaker) status() BreakerStatus {
+	if b == nil {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := BreakerStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state == BreakerClosed {
+		if b.streakExpired(b.now()) {
+			st.ConsecutiveFailures = 0
+		}
+		return st
+	}
+	st.NextProbeAt = b.openedAt.Add(b.cooldown)
+	if b.state == BreakerOpen && !b.now().Before(st.NextProbeAt) {
+		st.State = BreakerHalfOpen
+	}
+	return st
+}
+
+// isBreakerFailure reports whether err indicates the service itself is
+// unavailable, as opposed to a caller or application-level error
+func isBreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(2, 0, time.Minute)
+	b.now = func() time.Time { return now }
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	// Application errors do not count
+	b.record(status.Error(codes.NotFound, "no such task"))
+	assert.Equal(t, BreakerClosed, b.status().State)
+
+	b.record(unavailable)
+	assert.Equal(t, BreakerClosed, b.status().State)
+	b.record(unavailable)
+
+	st := b.status()
+	assert.Equal(t, BreakerOpen, st.State)
+	assert.Equal(t, 2, st.ConsecutiveFailures)
+	assert.Equal(t, now.Add(time.Minute), st.NextProbeAt)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// After the cooldown exactly one probe is admitted
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, b.status().State)
+	assert.NoError(t, b.allow())
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// A failed probe reopens for another cooldown
+	b.record(unavailable)
+	st = b.status()
+	assert.Equal(t, BreakerOpen, st.State)
+	assert.Equal(t, now.Add(time.Minute), st.NextProbeAt)
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.allow())
+	b.record(nil)
+	st = b.status()
+	assert.Equal(t, BreakerClosed, st.State)
+	assert.Zero(t, st.ConsecutiveFailures)
+	assert.True(t, st.NextProbeAt.IsZero())
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	b := newCircuitBreaker(1, 0, time.Minute)
+	b.record(context.Canceled)
+	assert.Equal(t, BreakerClosed, b.status().State)
+
+	var nilBreaker *circuitBreaker
+	assert.NoError(t, nilBreaker.allow())
+	assert.Equal(t, BreakerClosed, nilBreaker.status().State)
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(3, 10*time.Second, time.Minute)
+	b.now = func() time.Time { return now }
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	// Failures spread wider than the window never trip it
+	b.record(unavailable)
+	b.record(unavailable)
+	now = now.Add(11 * time.Second)
+	assert.Zero(t, b.status().ConsecutiveFailures)
+	b.record(unavailable)
+	assert.Equal(t, BreakerClosed, b.status().State)
+	assert.Equal(t, 1, b.status().ConsecutiveFailures)
+
+	// Failures within the window do
+	now = now.Add(5 * time.Second)
+	b.record(unavailable)
+	b.record(unavailable)
+	assert.Equal(t, BreakerOpen, b.status().State)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+}
+
+func TestAlgoClientBreakerState(t *testing.T) {
+	srv := &watchServer{}
+	client := newWatchClient(t, srv, 0)
+	assert.Equal(t, BreakerClosed, client.BreakerState())
+
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+	for i := 0; i < client.config.BreakerThreshold; i++ {
+		client.breaker.record(status.Error(codes.Unavailable, "down"))
+	}
+	assert.Equal(t, BreakerOpen, client.BreakerState())
+
+	// Calls are short-circuited without reaching the service
+	_, err := client.GetSchemes(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	now = now.Add(client.config.BreakerCooldown)
+	assert.Equal(t, BreakerHalfOpen, client.BreakerState())
+}
@@ -0,0 +1,154 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/models"
+	pb "github.com/electric-power/backend-service/proto"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchProgressResilient follows a task's progress across stream failures.
+// fromSeq is the Timestamp of the last update the caller has already seen (0
+// for everything). On a transient error the stream is re-dialled asking only
+// for updates from the last one received, so progress made during the gap is
+// not lost and nothing already delivered is repeated.
+//
+// The updates channel is closed once a 100% update has been delivered, ctx is
+// cancelled, or the watch gives up; in the last case the reason is sent on
+// the error channel first. The error channel is closed after updates.
+func (c *AlgoClient) WatchProgressResilient(ctx context.Context, taskID string, fromSeq int64) (<-chan models.ProgressMsg, <-chan error) {
+	updates := make(chan models.ProgressMsg)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(updates)
+
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = c.config.WatchInitialBackoff
+		b.MaxInterval = c.config.WatchMaxBackoff
+		b.MaxElapsedTime = 0
+
+		cursor := progressCursor{last: fromSeq}
+		failures := 0
+		for {
+			fresh, done, err := c.followProgress(ctx, taskID, &cursor, updates)
+			if done || ctx.Err() != nil || watchStopped(err) {
+				return
+			}
+			if fresh {
+				failures = 0
+				b.Reset()
+			}
+			if !transientWatchError(err) {
+				errs <- err
+				return
+			}
+			failures++
+			if c.config.WatchMaxRetries > 0 && failures > c.config.WatchMaxRetries {
+				errs <- fmt.Errorf("progress watch for task %s gave up after %d reconnects: %w", taskID, failures-1, err)
+				return
+			}
+
+			wait := b.NextBackOff()
+			c.logger.Warn("Progress stream lost, reconnecting",
+				zap.String("task_id", taskID), zap.Int64("resume_from", cursor.last),
+				zap.Duration("backoff", wait), zap.Error(err))
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	return updates, errs
+}
+
+// followProgress reads one stream until it fails. fresh reports whether any
+// new update was delivered, done whether the task reached 100%.
+func (c *AlgoClient) followProgress(ctx context.Context, taskID string, cursor *progressCursor, updates chan<- models.ProgressMsg) (fresh, done bool, err error) {
+	stream, err := c.client.WatchTaskProgress(ctx, &pb.TaskIdentity{TaskId: taskID, ResumeFromTimestamp: cursor.last})
+	if err != nil {
+		return false, false, err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return fresh, false, err
+		}
+		if !cursor.advance(msg) {
+			continue
+		}
+		fresh = true
+
+		select {
+		case updates <- models.ProgressMsg{
+			TaskID:        msg.TaskId,
+			Percentage:    msg.Percentage,
+			Message:       msg.Message,
+			Timestamp:     msg.Timestamp,
+			Stage:         msg.Stage,
+			Metrics:       msg.Metrics,
+			CheckpointRef: msg.CheckpointRef,
+		}:
+		case <-ctx.Done():
+			return fresh, false, ctx.Err()
+		}
+		if msg.Percentage >= 100 {
+			return fresh, true, nil
+		}
+	}
+}
+
+// progressCursor remembers the last update delivered so replays after a
+// reconnect are dropped
+type progressCursor struct {
+	last       int64
+	percentage int32
+	message    string
+	seen       bool
+}
+
+// advance reports whether msg is new and, if so, moves the cursor to it.
+// Updates without a timestamp cannot be ordered and are always delivered.
+func (cur *progressCursor) advance(msg *pb.ProgressUpdate) bool {
+	if msg.Timestamp != 0 {
+		if msg.Timestamp < cur.last {
+			return false
+		}
+		if cur.seen && msg.Timestamp == cur.last && msg.Percentage == cur.percentage && msg.Message == cur.message {
+			return false
+		}
+		cur.last = msg.Timestamp
+	}
+	cur.percentage, cur.message, cur.seen = msg.Percentage, msg.Message, true
+	return true
+}
+
+// watchStopped reports whether a stream ended because the watch was cancelled
+func watchStopped(err error) bool {
+	return errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled
+}
+
+// transientWatchError reports whether reconnecting may help. A stream that
+// ends cleanly before 100% counts as transient: the server may have timed
+// the watcher out.
+func transientWatchError(err error) bool {
+	switch status.Code(err) {
+	case codes.NotFound, codes.InvalidArgument, codes.PermissionDenied,
+		codes.Unauthenticated, codes.Unimplemented, codes.FailedPrecondition:
+		return false
+	}
+	return true
+}
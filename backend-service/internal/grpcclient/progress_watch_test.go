@@ -0,0 +1,160 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/electric-power/backend-service/internal/models"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// watchServer serves WatchTaskProgress from a per-connection hook
+type watchServer struct {
+	pb.UnimplementedAlgoControlServiceServer
+	calls atomic.Int32
+	watch func(call int32, req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error
+}
+
+func (s *watchServer) WatchTaskProgress(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+	return s.watch(s.calls.Add(1), req, stream)
+}
+
+func newWatchClient(t *testing.T, srv *watchServer, maxRetries int) *AlgoClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pb.RegisterAlgoControlServiceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	cfg := DefaultAlgoClientConfig(lis.Addr().String())
+	cfg.WatchInitialBackoff = time.Millisecond
+	cfg.WatchMaxBackoff = 5 * time.Millisecond
+	cfg.WatchMaxRetries = maxRetries
+	client, err := NewAlgoClientWithConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// drain collects every update and the final error, failing the test if the watch hangs
+func drain(t *testing.T, updates <-chan models.ProgressMsg, errs <-chan error) ([]int32, error) {
+	t.Helper()
+	var got []int32
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-updates:
+			if !ok {
+				return got, <-errs
+			}
+			got = append(got, msg.Percentage)
+		case <-timeout:
+			t.Fatal("watch did not finish")
+		}
+	}
+}
+
+func TestWatchProgressResilientResumesAfterLastUpdate(t *testing.T) {
+	var resumedFrom atomic.Int64
+	srv := &watchServer{watch: func(call int32, req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+		send := func(ts int64, pct int32) {
+			_ = stream.Send(&pb.ProgressUpdate{TaskId: req.TaskId, Timestamp: ts, Percentage: pct, Message: "step"})
+		}
+		if call == 1 {
+			send(100, 10)
+			send(200, 30)
+			return status.Error(codes.Unavailable, "connection reset")
+		}
+		resumedFrom.Store(req.ResumeFromTimestamp)
+		// The server replays its latest snapshot before new updates
+		send(200, 30)
+		send(300, 60)
+		send(400, 100)
+		return nil
+	}}
+	client := newWatchClient(t, srv, 3)
+
+	updates, errs := client.WatchProgressResilient(context.Background(), "task-1", 0)
+	got, err := drain(t, updates, errs)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{10, 30, 60, 100}, got)
+	assert.Equal(t, int32(2), srv.calls.Load())
+	assert.Equal(t, int64(200), resumedFrom.Load())
+}
+
+func TestWatchProgressResilientSkipsUpdatesBeforeFromSeq(t *testing.T) {
+	srv := &watchServer{watch: func(_ int32, req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+		assert.Equal(t, int64(250), req.ResumeFromTimestamp)
+		for i, pct := range []int32{10, 30, 60, 100} {
+			_ = stream.Send(&pb.ProgressUpdate{Timestamp: int64(i+1) * 100, Percentage: pct})
+		}
+		return nil
+	}}
+	client := newWatchClient(t, srv, 3)
+
+	updates, errs := client.WatchProgressResilient(context.Background(), "task-1", 250)
+	got, err := drain(t, updates, errs)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{60, 100}, got)
+}
+
+func TestWatchProgressResilientGivesUp(t *testing.T) {
+	srv := &watchServer{watch: func(int32, *pb.TaskIdentity, pb.AlgoControlService_WatchTaskProgressServer) error {
+		return status.Error(codes.Unavailable, "down")
+	}}
+	client := newWatchClient(t, srv, 2)
+
+	updates, errs := client.WatchProgressResilient(context.Background(), "task-1", 0)
+	got, err := drain(t, updates, errs)
+	assert.Empty(t, got)
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(3), srv.calls.Load())
+}
+
+func TestWatchProgressResilientStopsOnPermanentError(t *testing.T) {
+	srv := &watchServer{watch: func(int32, *pb.TaskIdentity, pb.AlgoControlService_WatchTaskProgressServer) error {
+		return status.Error(codes.NotFound, "no such task")
+	}}
+	client := newWatchClient(t, srv, 5)
+
+	updates, errs := client.WatchProgressResilient(context.Background(), "task-1", 0)
+	_, err := drain(t, updates, errs)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, int32(1), srv.calls.Load())
+}
+
+func TestWatchProgressResilientStopsOnCancel(t *testing.T) {
+	sent := make(chan struct{})
+	srv := &watchServer{watch: func(_ int32, _ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+		_ = stream.Send(&pb.ProgressUpdate{Timestamp: 1, Percentage: 20})
+		close(sent)
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}}
+	client := newWatchClient(t, srv, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, errs := client.WatchProgressResilient(ctx, "task-1", 0)
+	assert.Equal(t, int32(20), (<-updates).Percentage)
+	<-sent
+	cancel()
+
+	got, err := drain(t, updates, errs)
+	assert.Empty(t, got)
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), srv.calls.Load(), "watch reconnected after cancellation")
+}
@@ -0,0 +1,179 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// testPKI is a CA with the files of a server and a client certificate it signed
+type testPKI struct {
+	caFile, serverCert, serverKey, clientCert, clientKey string
+	pool                                                 *x509.CertPool
+}
+
+// issue signs a certificate for tmpl with parent (self-signed when parent is
+// nil) and writes it and its key to dir under name
+func issue(t *testing.T, dir, name string, tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (string, string, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert, key
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	var p testPKI
+	caFile, _, ca, caKey := issue(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	p.caFile = caFile
+	p.serverCert, p.serverKey, _, _ = issue(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "algo.internal"},
+		DNSNames:     []string{"algo.internal"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	p.clientCert, p.clientKey, _, _ = issue(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "backend-service"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	p.pool = x509.NewCertPool()
+	p.pool.AddCert(ca)
+	return p
+}
+
+// healthServer answers CheckHealth so a call proves the handshake succeeded
+type healthServer struct {
+	pb.UnimplementedAlgoControlServiceServer
+}
+
+func (healthServer) CheckHealth(context.Context, *pb.Empty) (*pb.HealthStatus, error) {
+	return &pb.HealthStatus{Status: pb.HealthStatus_SERVING}, nil
+}
+
+// startMTLSServer serves over TLS and requires a client certificate signed by the CA
+func startMTLSServer(t *testing.T, p testPKI) string {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(p.serverCert, p.serverKey)
+	require.NoError(t, err)
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    p.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.Creds(creds))
+	pb.RegisterAlgoControlServiceServer(server, healthServer{})
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestAlgoClientMutualTLS(t *testing.T) {
+	p := newTestPKI(t)
+	addr := startMTLSServer(t, p)
+
+	cfg := DefaultAlgoClientConfig(addr)
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 0
+	cfg.Insecure = false
+	cfg.TLSCAFile = p.caFile
+	cfg.TLSServerName = "algo.internal"
+	cfg.TLSCertFile, cfg.TLSKeyFile = p.clientCert, p.clientKey
+	client, err := NewAlgoClientWithConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	defer client.Close()
+	resp, err := client.Health(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, pb.HealthStatus_SERVING, resp.Status)
+
+	// Without a client certificate the server refuses the handshake
+	cfg.TLSCertFile, cfg.TLSKeyFile = "", ""
+	anonymous, err := NewAlgoClientWithConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	defer anonymous.Close()
+	_, err = anonymous.Health(context.Background())
+	assert.Error(t, err)
+
+	// The server name must match the certificate
+	cfg.TLSCertFile, cfg.TLSKeyFile = p.clientCert, p.clientKey
+	cfg.TLSServerName = "other.internal"
+	mismatched, err := NewAlgoClientWithConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	defer mismatched.Close()
+	_, err = mismatched.Health(context.Background())
+	assert.Error(t, err)
+}
+
+func TestAlgoClientTLSFileErrors(t *testing.T) {
+	p := newTestPKI(t)
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name   string
+		modify func(*AlgoClientConfig)
+		want   string
+	}{
+		{"missing CA file", func(c *AlgoClientConfig) { c.TLSCAFile = "/nonexistent/ca.pem" }, "read algorithm CA file"},
+		{"unparseable CA file", func(c *AlgoClientConfig) { c.TLSCAFile = garbage }, "no PEM certificates found"},
+		{"missing client key", func(c *AlgoClientConfig) { c.TLSCertFile, c.TLSKeyFile = p.clientCert, "/nonexistent/key.pem" }, "load algorithm client certificate"},
+		{"unparseable client certificate", func(c *AlgoClientConfig) { c.TLSCertFile, c.TLSKeyFile = garbage, p.clientKey }, "load algorithm client certificate"},
+		{"certificate without key", func(c *AlgoClientConfig) { c.TLSCertFile = p.clientCert }, "must be set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultAlgoClientConfig("127.0.0.1:1")
+			cfg.Insecure = false
+			tt.modify(&cfg)
+			_, err := NewAlgoClientWithConfig(cfg, zap.NewNop())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
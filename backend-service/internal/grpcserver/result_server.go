@@ -1,32 +1,143 @@
 package grpcserver
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/electric-power/backend-service/internal/services"
 	pb "github.com/electric-power/backend-service/proto"
 )
 
+// ResultServerConfig configures a ResultServer
+type ResultServerConfig struct {
+	// MaxStreamedResultBytes caps the result JSON of a ReportResultStream
+	// call; a larger stream is refused (0 means no cap). It replaces the job
+	// service's result cap for streamed results.
+	MaxStreamedResultBytes int
+}
+
+// DefaultResultServerConfig returns the default result server configuration
+func DefaultResultServerConfig() ResultServerConfig {
+	return ResultServerConfig{
+		MaxStreamedResultBytes: 512 << 20,
+	}
+}
+
 type ResultServer struct {
 	pb.UnimplementedResultReceiverServiceServer
 	jobs *services.JobService
+	cfg  ResultServerConfig
 }
 
 func NewResultServer(jobs *services.JobService) *ResultServer {
-	return &ResultServer{jobs: jobs}
+	return NewResultServerWithConfig(jobs, DefaultResultServerConfig())
+}
+
+// NewResultServerWithConfig creates a result server with custom configuration
+func NewResultServerWithConfig(jobs *services.JobService, cfg ResultServerConfig) *ResultServer {
+	return &ResultServer{jobs: jobs, cfg: cfg}
 }
 
 func (s *ResultServer) ReportResult(ctx context.Context, req *pb.TaskResult) (*pb.Ack, error) {
-	if s.jobs.IsFinished(ctx, req.TaskId) {
+	return s.report(ctx, req, s.jobs.FinishJob)
+}
+
+// ReportResultStream reassembles a result sent in chunks and reports it like
+// ReportResult once the stream is complete. A stream that fails or grows past
+// MaxStreamedResultBytes is dropped without touching the job.
+func (s *ResultServer) ReportResultStream(stream pb.ResultReceiverService_ReportResultStreamServer) error {
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "empty result stream")
+	}
+	if err != nil {
+		return err
+	}
+	if first.Result == nil {
+		return status.Error(codes.InvalidArgument, "the first chunk must carry the result")
+	}
+
+	var resultJSON bytes.Buffer
+	for chunk := first; ; {
+		if limit := s.cfg.MaxStreamedResultBytes; limit > 0 && resultJSON.Len()+len(chunk.Data) > limit {
+			return status.Errorf(codes.ResourceExhausted, "result of task %s exceeds the limit of %d bytes", first.Result.TaskId, limit)
+		}
+		resultJSON.Write(chunk.Data)
+
+		if chunk, err = stream.Recv(); errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if chunk.Result != nil {
+			return status.Error(codes.InvalidArgument, "only the first chunk may carry the result")
+		}
+	}
+
+	req := first.Result
+	req.ResultJson = resultJSON.String()
+	ack, err := s.report(stream.Context(), req, func(ctx context.Context, jobID, resultJSON string) error {
+		return s.jobs.FinishJobWithLimit(ctx, jobID, resultJSON, s.cfg.MaxStreamedResultBytes)
+	})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(ack)
+}
+
+// report records a task's outcome, however it was delivered; finish stores a
+// successful result under the size cap of the delivery channel
+func (s *ResultServer) report(ctx context.Context, req *pb.TaskResult, finish func(ctx context.Context, jobID, resultJSON string) error) (*pb.Ack, error) {
+	// Exact duplicates of a recent callback are acknowledged straight away
+	digest := resultDigest(req)
+	if !s.jobs.ClaimResult(ctx, req.TaskId, digest) {
+		return &pb.Ack{Success: true, Message: "duplicate"}, nil
+	}
+
+	// The algorithm service may report under its own task ID
+	jobID := s.jobs.ResolveJobID(ctx, req.TaskId)
+
+	if s.jobs.IsFinished(ctx, jobID) {
 		return &pb.Ack{Success: true}, nil
 	}
+	_ = s.jobs.RecordCheckpoint(ctx, jobID, req.CheckpointRef)
 
+	// A callback that could not be stored is released so a redelivery is
+	// processed; the job service runs the job hooks once the outcome is stored
 	if req.Status == pb.TaskResult_SUCCESS {
-		_ = s.jobs.FinishJob(ctx, req.TaskId, req.ResultJson)
-		go s.jobs.OnJobSuccess(req.TaskId)
+		err := finish(ctx, jobID, req.ResultJson)
+		if errors.Is(err, services.ErrResultTooLarge) {
+			// The job was failed rather than stored
+			return &pb.Ack{Success: true, Message: err.Error()}, nil
+		}
+		if err != nil {
+			s.jobs.ReleaseResult(ctx, req.TaskId, digest)
+		}
 	} else {
-		_ = s.jobs.FailJob(ctx, req.TaskId, req.ErrorMessage)
+		if err := s.jobs.FailJob(ctx, jobID, req.ErrorMessage); err != nil {
+			s.jobs.ReleaseResult(ctx, req.TaskId, digest)
+		}
 	}
 
 	return &pb.Ack{Success: true}, nil
 }
+
+// resultDigest identifies a result callback by its outcome, so only exact
+// redeliveries count as duplicates
+func resultDigest(req *pb.TaskResult) string {
+	h := sha256.New()
+	h.Write([]byte(req.Status.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(req.ResultJson))
+	h.Write([]byte{0})
+	h.Write([]byte(req.ErrorMessage))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
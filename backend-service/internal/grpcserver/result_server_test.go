@@ -0,0 +1,385 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/electric-power/backend-service/internal/services"
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+func newTestResultServer(t *testing.T) (*ResultServer, *services.JobService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+	hub := ws.NewHub()
+	t.Cleanup(hub.Close)
+
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+	jobs := services.NewJobService(store, cache, hub, "sys:algo:schemes", "job:progress:")
+	return NewResultServer(jobs), jobs, mock
+}
+
+func TestReportResultResolvesAlgoTaskID(t *testing.T) {
+	srv, jobs, mock := newTestResultServer(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET algo_task_id`).
+		WithArgs("algo-9", "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, jobs.RecordAlgoTaskID(ctx, "job-1", "algo-9"))
+
+	// The mapping is served from cache, so the result lands on job-1 directly
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-1", "RUNNING"))
+	expectFinishJob(mock, "job-1", `{"ok":true}`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack, err := srv.ReportResult(ctx, &pb.TaskResult{TaskId: "algo-9", Status: pb.TaskResult_SUCCESS, ResultJson: `{"ok":true}`})
+	require.NoError(t, err)
+	assert.True(t, ack.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultFallsBackToTaskID(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-2", "RUNNING"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-2", Status: pb.TaskResult_FAILED, ErrorMessage: "boom"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultRecordsCheckpoint(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("job-3").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-3").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-3", "RUNNING"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET checkpoint_ref = \? WHERE job_id = \?`).
+		WithArgs("s3://ckpt/job-3/epoch-8", "job-3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := srv.ReportResult(context.Background(), &pb.TaskResult{
+		TaskId: "job-3", Status: pb.TaskResult_FAILED, ErrorMessage: "OOM", CheckpointRef: "s3://ckpt/job-3/epoch-8",
+	})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectSuccessCallback registers the queries a first-time SUCCESS callback makes
+func expectSuccessCallback(mock sqlmock.Sqlmock, jobID, resultJSON string) *sqlmock.ExpectedExec {
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow(jobID, "RUNNING"))
+	return expectFinishJob(mock, jobID, resultJSON)
+}
+
+// expectFinishJob registers the result insert of FinishJob and returns its status update
+func expectFinishJob(mock sqlmock.Sqlmock, jobID, resultJSON string) *sqlmock.ExpectedExec {
+	mock.ExpectExec(`INSERT INTO t_job_results`).
+		WithArgs(jobID, resultJSON, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	return mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'SUCCESS'`).
+		WithArgs(len(resultJSON), sqlmock.AnyArg(), sqlmock.AnyArg(), jobID)
+}
+
+func TestReportResultDuplicateIsNoop(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+	expectSuccessCallback(mock, "job-4", `{"ok":true}`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Fire the same callback twice at once; only one may reach MySQL
+	req := &pb.TaskResult{TaskId: "job-4", Status: pb.TaskResult_SUCCESS, ResultJson: `{"ok":true}`}
+	acks := make([]*pb.Ack, 2)
+	var wg sync.WaitGroup
+	for i := range acks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ack, err := srv.ReportResult(context.Background(), req)
+			assert.NoError(t, err)
+			acks[i] = ack
+		}(i)
+	}
+	wg.Wait()
+
+	duplicates := 0
+	for _, ack := range acks {
+		require.NotNil(t, ack)
+		assert.True(t, ack.Success)
+		if ack.Message == "duplicate" {
+			duplicates++
+		}
+	}
+	assert.Equal(t, 1, duplicates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A later redelivery is also acknowledged without queries
+	ack, err := srv.ReportResult(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "duplicate", ack.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultDifferentOutcomeIsNotDuplicate(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+	expectSuccessCallback(mock, "job-5", `{"v":1}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-5", Status: pb.TaskResult_SUCCESS, ResultJson: `{"v":1}`})
+	require.NoError(t, err)
+
+	// A different payload is checked against MySQL, which has the job finished
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("job-5").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-5").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-5", "SUCCESS"))
+	ack, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-5", Status: pb.TaskResult_SUCCESS, ResultJson: `{"v":2}`})
+	require.NoError(t, err)
+	assert.Empty(t, ack.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultReleasesClaimWhenStoreFails(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+	req := &pb.TaskResult{TaskId: "job-6", Status: pb.TaskResult_SUCCESS, ResultJson: `{"ok":true}`}
+
+	expectSuccessCallback(mock, "job-6", `{"ok":true}`).WillReturnError(errors.New("mysql down"))
+	_, err := srv.ReportResult(context.Background(), req)
+	require.NoError(t, err)
+
+	// The redelivery is processed rather than dropped as a duplicate
+	expectSuccessCallback(mock, "job-6", `{"ok":true}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	ack, err := srv.ReportResult(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, ack.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultRecordsResultSize(t *testing.T) {
+	srv, jobs, mock := newTestResultServer(t)
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("job-7").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-7").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-7", "RUNNING"))
+	expectFinishJob(mock, "job-7", `{"score":0.5}`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-7", Status: pb.TaskResult_SUCCESS, ResultJson: `{"score":0.5}`})
+	require.NoError(t, err)
+	assert.True(t, ack.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var m dto.Metric
+	require.NoError(t, jobs.ResultSizeCollector().(prometheus.Histogram).Write(&m))
+	assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(len(`{"score":0.5}`)), m.GetHistogram().GetSampleSum())
+}
+
+func TestReportResultFailsOversizedResult(t *testing.T) {
+	srv, jobs, mock := newTestResultServer(t)
+	jobs.SetMaxResultBytes(16)
+	failures := make(chan string, 1)
+	jobs.AddHook(func(_ context.Context, jobID string, success bool, detail string) {
+		if !success {
+			failures <- detail
+		}
+	})
+
+	result := `{"rows":[1,2,3,4,5,6,7,8,9]}`
+	reason := fmt.Sprintf("result too large: %d bytes exceeds the limit of 16 bytes", len(result))
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("job-8").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-8").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-8", "RUNNING"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED', error_log = \?, result_bytes = \?`).
+		WithArgs(reason, len(result), sqlmock.AnyArg(), sqlmock.AnyArg(), "job-8").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ack, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-8", Status: pb.TaskResult_SUCCESS, ResultJson: result})
+	require.NoError(t, err)
+	assert.True(t, ack.Success)
+	assert.Equal(t, reason, ack.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	select {
+	case detail := <-failures:
+		assert.Equal(t, reason, detail)
+	case <-time.After(2 * time.Second):
+		t.Fatal("failure hooks were not run for the oversized result")
+	}
+}
+
+// fakeResultStream replays chunks to ReportResultStream, then fails with err
+// (io.EOF when nil)
+type fakeResultStream struct {
+	pb.ResultReceiverService_ReportResultStreamServer
+	chunks []*pb.ResultChunk
+	err    error
+	ack    *pb.Ack
+}
+
+func (f *fakeResultStream) Context() context.Context { return context.Background() }
+
+func (f *fakeResultStream) Recv() (*pb.ResultChunk, error) {
+	if len(f.chunks) == 0 {
+		if f.err != nil {
+			return nil, f.err
+		}
+		return nil, io.EOF
+	}
+	chunk := f.chunks[0]
+	f.chunks = f.chunks[1:]
+	return chunk, nil
+}
+
+func (f *fakeResultStream) SendAndClose(ack *pb.Ack) error {
+	f.ack = ack
+	return nil
+}
+
+// streamChunks splits resultJSON into chunks of size bytes behind the result
+func streamChunks(result *pb.TaskResult, resultJSON string, size int) []*pb.ResultChunk {
+	chunks := []*pb.ResultChunk{{Result: result}}
+	for len(resultJSON) > 0 {
+		n := min(size, len(resultJSON))
+		chunks = append(chunks, &pb.ResultChunk{Data: []byte(resultJSON[:n])})
+		resultJSON = resultJSON[n:]
+	}
+	return chunks
+}
+
+func TestReportResultStreamReassemblesChunks(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+	result := `{"rows":[` + strings.Repeat(`"电网",`, 100) + `"end"]}`
+	expectSuccessCallback(mock, "job-9", result).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Chunk boundaries may split multi-byte characters
+	stream := &fakeResultStream{chunks: streamChunks(&pb.TaskResult{TaskId: "job-9", Status: pb.TaskResult_SUCCESS}, result, 7)}
+	require.NoError(t, srv.ReportResultStream(stream))
+	require.NotNil(t, stream.ack)
+	assert.True(t, stream.ack.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The unary call still works alongside the stream
+	expectSuccessCallback(mock, "job-10", `{"ok":true}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	ack, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-10", Status: pb.TaskResult_SUCCESS, ResultJson: `{"ok":true}`})
+	require.NoError(t, err)
+	assert.True(t, ack.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultStreamEnforcesMaxSize(t *testing.T) {
+	_, jobs, mock := newTestResultServer(t)
+	srv := NewResultServerWithConfig(jobs, ResultServerConfig{MaxStreamedResultBytes: 16})
+
+	stream := &fakeResultStream{chunks: streamChunks(&pb.TaskResult{TaskId: "job-11", Status: pb.TaskResult_SUCCESS}, `{"rows":[1,2,3,4,5,6,7,8,9]}`, 8)}
+	err := srv.ReportResultStream(stream)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Nil(t, stream.ack)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReportResultStreamUsesStreamLimit tests that a streamed result over the
+// unary result cap but within the stream cap is stored, while the same
+// result sent unary fails its job
+func TestReportResultStreamUsesStreamLimit(t *testing.T) {
+	_, jobs, mock := newTestResultServer(t)
+	jobs.SetMaxResultBytes(16)
+	srv := NewResultServerWithConfig(jobs, ResultServerConfig{MaxStreamedResultBytes: 64})
+	result := `{"rows":[1,2,3,4,5,6,7,8,9]}`
+
+	expectSuccessCallback(mock, "job-13", result).WillReturnResult(sqlmock.NewResult(0, 1))
+	stream := &fakeResultStream{chunks: streamChunks(&pb.TaskResult{TaskId: "job-13", Status: pb.TaskResult_SUCCESS}, result, 8)}
+	require.NoError(t, srv.ReportResultStream(stream))
+	require.NotNil(t, stream.ack)
+	assert.True(t, stream.ack.Success)
+	assert.Empty(t, stream.ack.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("job-14").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-14").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-14", "RUNNING"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED', error_log = \?, result_bytes = \?`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ack, err := srv.ReportResult(context.Background(), &pb.TaskResult{TaskId: "job-14", Status: pb.TaskResult_SUCCESS, ResultJson: result})
+	require.NoError(t, err)
+	assert.Contains(t, ack.Message, "result too large")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultStreamDropsPartialResultOnError(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+
+	// The algorithm service disconnects halfway through
+	stream := &fakeResultStream{
+		chunks: streamChunks(&pb.TaskResult{TaskId: "job-12", Status: pb.TaskResult_SUCCESS}, `{"rows":[1,2,3`, 4),
+		err:    status.Error(codes.Canceled, "context canceled"),
+	}
+	err := srv.ReportResultStream(stream)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Nil(t, stream.ack)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Nothing was claimed, so a retried stream is processed in full
+	expectSuccessCallback(mock, "job-12", `{"rows":[1,2,3]}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	stream = &fakeResultStream{chunks: streamChunks(&pb.TaskResult{TaskId: "job-12", Status: pb.TaskResult_SUCCESS}, `{"rows":[1,2,3]}`, 4)}
+	require.NoError(t, srv.ReportResultStream(stream))
+	assert.Empty(t, stream.ack.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportResultStreamRequiresResultFirst(t *testing.T) {
+	srv, _, mock := newTestResultServer(t)
+
+	err := srv.ReportResultStream(&fakeResultStream{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	err = srv.ReportResultStream(&fakeResultStream{chunks: []*pb.ResultChunk{{Data: []byte(`{}`)}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// CancelOutcome is the result of cancelling one job in a bulk cancellation
+// @Description Outcome for a single job of a bulk cancellation
+type CancelOutcome struct {
+	JobID  string `json:"job_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Status string `json:"status,omitempty" example:"CANCELLED"`
+	Error  string `json:"error,omitempty" example:"algorithm service unavailable"`
+}
+
+// CancelSchemeJobs godoc
+// @Summary      Cancel all active jobs of a scheme
+// @Description  Cancels every PENDING and RUNNING job of the scheme through the algorithm service,
+// @Description  a bounded number at a time, e.g. while retiring the scheme. Requires the admin API key.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        code   path      string  true   "Scheme code"
+// @Param        force  query     bool    false  "Force kill running tasks"
+// @Success      200  {object}  map[string]any  "Returns total, cancelled, pending and failed counts with per-job results"
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/admin/schemes/{code}/cancel-all [post]
+func (h *Handler) CancelSchemeJobs(c *gin.Context) {
+	schemeCode := c.Param("code")
+	forceStr := c.DefaultQuery("force", "false")
+	force := forceStr == "true" || forceStr == "1"
+
+	refs, err := h.store.ListActiveJobsByScheme(c.Request.Context(), schemeCode)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to list jobs", err.Error()))
+		return
+	}
+
+	results := h.cancelJobs(c.Request.Context(), refs, force, "Cancelled by admin: scheme "+schemeCode+" retired")
+	cancelled, pending, failed := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			failed++
+		case r.Status == "CANCELLED" || r.Status == "KILLED":
+			cancelled++
+		default:
+			pending++
+		}
+	}
+	respond(c, http.StatusOK, gin.H{
+		"scheme":    schemeCode,
+		"total":     len(results),
+		"cancelled": cancelled,
+		"pending":   pending,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// cancelJobs cancels jobs through the algorithm service with at most
+// cfg.BulkCancelConcurrency requests in flight; results keep the order of refs
+func (h *Handler) cancelJobs(ctx context.Context, refs []storage.JobRef, force bool, message string) []CancelOutcome {
+	concurrency := h.cfg.BulkCancelConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	results := make([]CancelOutcome, len(refs))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, ref storage.JobRef) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = CancelOutcome{JobID: ref.JobID}
+			resp, err := h.cancelJob(ctx, ref.JobID, ref.AlgoTaskID, force, message)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Status = resp.GetStatus()
+		}(i, ref)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/electric-power/backend-service/internal/middleware"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// newAdminTestRouter builds the production router with an admin API key
+func newAdminTestRouter(env *testEnv) http.Handler {
+	cfg := DefaultRouterConfig()
+	cfg.EnableSwagger = false
+	cfg.RateLimitRPS = 0
+	cfg.AdminAPIKey = "admin-secret"
+	return NewRouterWithConfig(env.handler, env.hub, nil, zap.NewNop(), cfg)
+}
+
+// cancelAll calls the cancel-all endpoint for a scheme with the admin API key
+func (e *testEnv) cancelAll(r http.Handler, scheme string) *cancelAllResponse {
+	req := newJSONRequest("POST", "/api/v1/admin/schemes/"+scheme+"/cancel-all", "")
+	req.Header.Set(middleware.AdminAPIKeyHeader, "admin-secret")
+	w := serve(r, req)
+	if w.Code != http.StatusOK {
+		return &cancelAllResponse{code: w.Code}
+	}
+	var resp cancelAllResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	resp.code = w.Code
+	return &resp
+}
+
+// cancelAllResponse is the decoded cancel-all summary
+type cancelAllResponse struct {
+	code      int
+	Scheme    string          `json:"scheme"`
+	Total     int             `json:"total"`
+	Cancelled int             `json:"cancelled"`
+	Pending   int             `json:"pending"`
+	Failed    int             `json:"failed"`
+	Results   []CancelOutcome `json:"results"`
+}
+
+// expectActiveJobs registers the active-job lookup for a scheme; terminal jobs are filtered by the query
+func (e *testEnv) expectActiveJobs(scheme string, refs ...[2]string) {
+	rows := sqlmock.NewRows([]string{"job_id", "algo_task_id"})
+	for _, ref := range refs {
+		rows.AddRow(ref[0], ref[1])
+	}
+	e.db.ExpectQuery(`SELECT job_id, COALESCE\(algo_task_id, job_id\) AS algo_task_id\s+FROM t_algo_jobs WHERE scheme_code = \? AND status IN \('PENDING', 'RUNNING'\)`).
+		WithArgs(scheme).
+		WillReturnRows(rows)
+}
+
+// TestCancelSchemeJobs tests that only the scheme's active jobs are cancelled and summarized
+func TestCancelSchemeJobs(t *testing.T) {
+	env := newTestEnv(t)
+	var mu sync.Mutex
+	var cancelled []string
+	env.withAlgo(t, &fakeAlgo{cancel: func(_ context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+		mu.Lock()
+		cancelled = append(cancelled, req.TaskId)
+		mu.Unlock()
+		switch req.TaskId {
+		case "algo-1":
+			return &pb.CancelResponse{Accepted: true, Status: "CANCELLED"}, nil
+		case "job-2":
+			return &pb.CancelResponse{Accepted: true, Status: "CANCELLING"}, nil
+		default:
+			return nil, fmt.Errorf("unknown task %s", req.TaskId)
+		}
+	}})
+
+	// job-done and job-failed are terminal and never listed; job-3 is unknown to the algorithm service
+	env.expectActiveJobs("KBM-WF03", [2]string{"job-1", "algo-1"}, [2]string{"job-2", "job-2"}, [2]string{"job-3", "job-3"})
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'CANCELLED'`).
+		WithArgs("Cancelled by admin: scheme KBM-WF03 retired", sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	resp := env.cancelAll(newAdminTestRouter(env), "KBM-WF03")
+	require.Equal(t, http.StatusOK, resp.code)
+	assert.Equal(t, "KBM-WF03", resp.Scheme)
+	assert.Equal(t, 3, resp.Total)
+	assert.Equal(t, 1, resp.Cancelled)
+	assert.Equal(t, 1, resp.Pending)
+	assert.Equal(t, 1, resp.Failed)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, CancelOutcome{JobID: "job-1", Status: "CANCELLED"}, resp.Results[0])
+	assert.Equal(t, CancelOutcome{JobID: "job-2", Status: "CANCELLING"}, resp.Results[1])
+	assert.Equal(t, "job-3", resp.Results[2].JobID)
+	assert.Contains(t, resp.Results[2].Error, "unknown task job-3")
+	assert.ElementsMatch(t, []string{"algo-1", "job-2", "job-3"}, cancelled)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestCancelSchemeJobsBoundsConcurrency tests that at most BulkCancelConcurrency cancels run at once
+func TestCancelSchemeJobsBoundsConcurrency(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.BulkCancelConcurrency = 2
+	env := newTestEnvWithConfig(t, cfg)
+	var inFlight, peak atomic.Int32
+	env.withAlgo(t, &fakeAlgo{cancel: func(context.Context, *pb.CancelRequest) (*pb.CancelResponse, error) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		return &pb.CancelResponse{Accepted: true, Status: "CANCELLING"}, nil
+	}})
+
+	refs := make([][2]string, 6)
+	for i := range refs {
+		id := fmt.Sprintf("job-%d", i)
+		refs[i] = [2]string{id, id}
+	}
+	env.expectActiveJobs("KBM-WF03", refs...)
+
+	resp := env.cancelAll(newAdminTestRouter(env), "KBM-WF03")
+	require.Equal(t, http.StatusOK, resp.code)
+	assert.Equal(t, 6, resp.Pending)
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestCancelSchemeJobsRequiresAdmin tests that the endpoint is refused without the admin key
+func TestCancelSchemeJobsRequiresAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	w := env.do(newAdminTestRouter(env), "POST", "/api/v1/admin/schemes/KBM-WF03/cancel-all", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
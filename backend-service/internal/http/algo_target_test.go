@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/grpcclient"
+	"github.com/electric-power/backend-service/internal/middleware"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// recordingAlgo is a fake algorithm service reporting the jobs submitted,
+// watched and cancelled on it
+type recordingAlgo struct {
+	submitted chan string
+	watched   chan string
+	cancelled chan string
+}
+
+func newRecordingAlgo() *recordingAlgo {
+	return &recordingAlgo{submitted: make(chan string, 4), watched: make(chan string, 4), cancelled: make(chan string, 4)}
+}
+
+func (a *recordingAlgo) server() *fakeAlgo {
+	return &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			a.submitted <- req.TaskId
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			a.watched <- req.TaskId
+			<-stream.Context().Done()
+			return nil
+		},
+		cancel: func(_ context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+			a.cancelled <- req.TaskId
+			return &pb.CancelResponse{Accepted: true, Status: "CANCELLED"}, nil
+		},
+	}
+}
+
+// TestAlgoTargetOverride tests that X-Algo-Target routes submission, progress
+// and cancellation to the alternate service while other jobs use the default
+func TestAlgoTargetOverride(t *testing.T) {
+	env := newTestEnv(t)
+	canary, stable := newRecordingAlgo(), newRecordingAlgo()
+	canaryClient := env.withAlgo(t, canary.server())
+	env.withAlgo(t, stable.server())
+	env.handler.cfg.AlgoTargets = map[string]*grpcclient.AlgoClient{"canary": canaryClient}
+	r := newAdminTestRouter(env)
+
+	submit := func(target, key string) (int, string) {
+		req := newJSONRequest("POST", "/api/v1/jobs", `{"scheme":"KBM-WF01","data_id":"d1"}`)
+		if target != "" {
+			req.Header.Set(AlgoTargetHeader, target)
+		}
+		if key != "" {
+			req.Header.Set(middleware.AdminAPIKeyHeader, key)
+		}
+		w := serve(r, req)
+		var resp map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp["job_id"]
+	}
+	received := func(ch chan string) string {
+		select {
+		case id := <-ch:
+			return id
+		case <-time.After(5 * time.Second):
+			t.Fatal("algorithm service was not called")
+			return ""
+		}
+	}
+
+	// Only admins may route, and only to a configured target
+	code, _ := submit("canary", "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = submit("canary", "wrong")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = submit("nightly", "admin-secret")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET algo_target = \? WHERE job_id = \?`).
+		WithArgs("canary", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	code, routed := submit("canary", "admin-secret")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, routed, received(canary.submitted))
+	assert.Equal(t, routed, received(canary.watched))
+
+	// Without the header the default service handles the job and nothing is recorded
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	code, plain := submit("", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, plain, received(stable.submitted))
+	assert.Equal(t, plain, received(stable.watched))
+
+	// Cancelling the routed job goes to the target it was persisted with
+	env.expectGetJob(jobRow{JobID: routed, Status: "RUNNING"})
+	env.db.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs`).
+		WithArgs(routed).
+		WillReturnRows(sqlmock.NewRows([]string{"algo_task_id"}).AddRow(nil))
+	env.db.ExpectQuery(`SELECT algo_target FROM t_algo_jobs`).
+		WithArgs(routed).
+		WillReturnRows(sqlmock.NewRows([]string{"algo_target"}).AddRow("canary"))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'CANCELLED'`).
+		WithArgs("Cancelled by user", sqlmock.AnyArg(), sqlmock.AnyArg(), routed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w := env.do(r, "POST", "/api/v1/jobs/"+routed+"/cancel", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, routed, received(canary.cancelled))
+
+	assert.Empty(t, stable.submitted)
+	assert.Empty(t, stable.cancelled)
+	assert.Empty(t, canary.submitted)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
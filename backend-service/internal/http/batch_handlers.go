@@ -0,0 +1,272 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxBatchSize caps the number of jobs accepted in one batch submission
+const maxBatchSize = 500
+
+// ndjsonContentType is the media type for newline-delimited JSON streams
+const ndjsonContentType = "application/x-ndjson"
+
+// SubmitBatchJobsRequest represents the request body for batch submission
+// @Description Batch job submission request payload
+type SubmitBatchJobsRequest struct {
+	Jobs []SubmitJobRequest `json:"jobs" binding:"required,min=1,dive"`
+}
+
+// BatchItemResult is the outcome of one item in a batch submission
+// @Description Result for a single batch item
+type BatchItemResult struct {
+	Index  int    `json:"index" example:"0"`
+	JobID  string `json:"job_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Status string `json:"status" example:"PENDING"`
+	Error  string `json:"error,omitempty" example:"Scheme not allowed"`
+}
+
+// SubmitBatchJobs godoc
+// @Summary      Submit a batch of algorithm jobs
+// @Description  Creates and dispatches several jobs. With Accept: application/x-ndjson each item's
+// @Description  result is streamed as one JSON line as soon as it is created; otherwise all valid items
+// @Description  are created in one transaction, dispatched concurrently, and the per-item results
+// @Description  (multi-status style: job_id with status, or error) are returned together.
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Produce      application/x-ndjson
+// @Param        request  body      SubmitBatchJobsRequest  true  "Batch submission request"
+// @Success      200  {object}  map[string]any  "Returns results array with submitted and failed counts"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Router       /api/v1/jobs/batch [post]
+func (h *Handler) SubmitBatchJobs(c *gin.Context) {
+	var req SubmitBatchJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
+		return
+	}
+	if len(req.Jobs) > maxBatchSize {
+		respondError(c, apperr.Validation("Batch too large", fmt.Sprintf("a batch may contain at most %d jobs", maxBatchSize)))
+		return
+	}
+	if total := batchParamsBytes(req.Jobs); h.cfg.MaxBatchParamsBytes > 0 && total > h.cfg.MaxBatchParamsBytes {
+		respondError(c, apperr.TooLarge(
+			"Batch params too large",
+			fmt.Sprintf("params across the batch serialize to %d bytes; at most %d are allowed", total, h.cfg.MaxBatchParamsBytes),
+		))
+		return
+	}
+
+	target := c.GetHeader(AlgoTargetHeader)
+	if !h.knownAlgoTarget(c, target) {
+		return
+	}
+	for i := range req.Jobs {
+		req.Jobs[i].algoTarget = target
+	}
+
+	caller := h.submissionCaller(c)
+	if strings.Contains(c.GetHeader("Accept"), ndjsonContentType) {
+		h.streamBatch(c, req.Jobs, caller)
+		return
+	}
+
+	results := h.submitBatch(c.Request.Context(), req.Jobs, caller)
+	failed := 0
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+	respond(c, http.StatusOK, gin.H{
+		"results":   results,
+		"submitted": len(results) - failed,
+		"failed":    failed,
+	})
+}
+
+// batchParamsBytes sums the serialized size of every item's params
+func batchParamsBytes(items []SubmitJobRequest) int {
+	total := 0
+	for _, item := range items {
+		paramsJSON, _ := json.Marshal(item.Params)
+		total += len(paramsJSON)
+	}
+	return total
+}
+
+// streamBatch writes one NDJSON line per item, flushing after each
+func (h *Handler) streamBatch(c *gin.Context, items []SubmitJobRequest, caller string) {
+	c.Header("Content-Type", ndjsonContentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	for i, item := range items {
+		if c.Request.Context().Err() != nil {
+			// Client went away; stop creating jobs it will never hear about
+			return
+		}
+		if err := enc.Encode(h.submitBatchItem(c.Request.Context(), i, item, caller)); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// batchItem is a validated batch item ready to be created
+type batchItem struct {
+	index  int
+	req    SubmitJobRequest
+	jobID  string
+	params string
+}
+
+// prepareBatchItem validates an item and resolves its user on behalf of
+// caller. The error is the reason the item is rejected.
+func (h *Handler) prepareBatchItem(ctx context.Context, index int, req SubmitJobRequest, caller string) (batchItem, error) {
+	if !h.cfg.SchemeFilter.Allowed(req.Scheme) {
+		return batchItem{}, errors.New("Scheme not allowed")
+	}
+	if err := h.checkSchemeKnown(ctx, req.Scheme); err != nil {
+		return batchItem{}, err
+	}
+	userID, err := h.resolveUserID(ctx, req.UserID, caller)
+	if err != nil {
+		return batchItem{}, err
+	}
+	req.UserID = userID
+
+	if err := h.checkParamsShape(req.Params); err != nil {
+		return batchItem{}, errors.New("Invalid params: " + err.Error())
+	}
+	if req.SLADeadline != nil && !req.SLADeadline.After(time.Now()) {
+		return batchItem{}, errors.New("Invalid sla_deadline: must be in the future")
+	}
+	if err := checkCallback(req); err != nil {
+		return batchItem{}, errors.New("Invalid callback: " + err.Error())
+	}
+	paramsJSON, _ := json.Marshal(req.Params)
+	if h.paramsTooLarge(len(paramsJSON)) {
+		return batchItem{}, fmt.Errorf("Params too large: at most %d bytes allowed", h.cfg.MaxParamsBytes)
+	}
+	return batchItem{index: index, req: req, jobID: uuid.NewString(), params: string(paramsJSON)}, nil
+}
+
+// submitBatch creates every valid item in one transaction, then dispatches
+// them to the algorithm service with at most cfg.BatchDispatchConcurrency
+// submissions in flight. Results are in item order; an item that fails to
+// dispatch is marked FAILED without affecting the others.
+func (h *Handler) submitBatch(ctx context.Context, reqs []SubmitJobRequest, caller string) []BatchItemResult {
+	results := make([]BatchItemResult, len(reqs))
+	items := make([]batchItem, 0, len(reqs))
+	for i, req := range reqs {
+		item, err := h.prepareBatchItem(ctx, i, req, caller)
+		if err != nil {
+			results[i] = BatchItemResult{Index: i, Status: "REJECTED", Error: err.Error()}
+			continue
+		}
+		items = append(items, item)
+	}
+
+	rows := make([]storage.NewJob, len(items))
+	for k, item := range items {
+		rows[k] = storage.NewJob{
+			JobID:       item.jobID,
+			SchemeCode:  item.req.Scheme,
+			UserID:      item.req.UserID,
+			DataRef:     item.req.DataID,
+			Params:      item.params,
+			Metadata:    item.req.Metadata,
+			SLADeadline: item.req.SLADeadline,
+			TimeoutAt:   item.req.timeoutAt(),
+
+			CallbackURL:        item.req.CallbackURL,
+			ProgressMilestones: item.req.ProgressMilestones,
+		}
+	}
+	rejected, err := h.jobs.CreateJobsBatch(ctx, rows)
+	created := items[:0]
+	for k, item := range items {
+		switch {
+		case err != nil:
+			results[item.index] = BatchItemResult{Index: item.index, Status: "REJECTED", Error: "Failed to create job: " + err.Error()}
+		case rejected[k] != nil:
+			results[item.index] = BatchItemResult{Index: item.index, Status: "REJECTED", Error: "Scheme at capacity: " + item.req.Scheme}
+		default:
+			created = append(created, item)
+		}
+	}
+
+	concurrency := h.cfg.BatchDispatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, item := range created {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item batchItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := BatchItemResult{Index: item.index, JobID: item.jobID, Status: "PENDING"}
+			if err := h.submitToAlgo(ctx, queuedJob(item.jobID, item.req)); err != nil {
+				res.Status, res.Error = "FAILED", "Failed to submit job: "+err.Error()
+			}
+			results[item.index] = res
+		}(item)
+	}
+	wg.Wait()
+	return results
+}
+
+// submitBatchItem creates and dispatches a single batch item on behalf of caller
+func (h *Handler) submitBatchItem(ctx context.Context, index int, req SubmitJobRequest, caller string) BatchItemResult {
+	item, err := h.prepareBatchItem(ctx, index, req, caller)
+	if err != nil {
+		return BatchItemResult{Index: index, Status: "REJECTED", Error: err.Error()}
+	}
+	req, jobID := item.req, item.jobID
+
+	if err := h.jobs.CreateJob(ctx, jobID, req.Scheme, req.UserID, req.DataID, item.params); err != nil {
+		return BatchItemResult{Index: index, Status: "REJECTED", Error: "Failed to create job: " + err.Error()}
+	}
+	if err := h.jobs.RecordMetadata(ctx, jobID, req.Metadata); err != nil {
+		_ = h.jobs.FailJob(ctx, jobID, "Failed to store metadata: "+err.Error())
+		return BatchItemResult{Index: index, JobID: jobID, Status: "FAILED", Error: "Failed to store metadata: " + err.Error()}
+	}
+	if err := h.jobs.RecordSLADeadline(ctx, jobID, req.SLADeadline); err != nil {
+		_ = h.jobs.FailJob(ctx, jobID, "Failed to store SLA deadline: "+err.Error())
+		return BatchItemResult{Index: index, JobID: jobID, Status: "FAILED", Error: "Failed to store SLA deadline: " + err.Error()}
+	}
+	if err := h.jobs.RecordTimeout(ctx, jobID, req.timeoutAt()); err != nil {
+		_ = h.jobs.FailJob(ctx, jobID, "Failed to store timeout: "+err.Error())
+		return BatchItemResult{Index: index, JobID: jobID, Status: "FAILED", Error: "Failed to store timeout: " + err.Error()}
+	}
+	if err := h.jobs.RecordCallback(ctx, jobID, req.CallbackURL, req.ProgressMilestones); err != nil {
+		_ = h.jobs.FailJob(ctx, jobID, "Failed to store callback: "+err.Error())
+		return BatchItemResult{Index: index, JobID: jobID, Status: "FAILED", Error: "Failed to store callback: " + err.Error()}
+	}
+
+	if err := h.submitToAlgo(ctx, queuedJob(jobID, req)); err != nil {
+		return BatchItemResult{Index: index, JobID: jobID, Status: "FAILED", Error: "Failed to submit job: " + err.Error()}
+	}
+	return BatchItemResult{Index: index, JobID: jobID, Status: "PENDING"}
+}
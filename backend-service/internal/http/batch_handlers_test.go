@@ -0,0 +1,329 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/electric-power/backend-service/internal/services"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+const batchBody = `{"jobs":[
+	{"scheme":"KBM-WF01","data_id":"d1"},
+	{"scheme":"KBM-WF02","data_id":"d2"},
+	{"scheme":"KBM-WF03","data_id":"d3"}]}`
+
+func expectBatchInserts(env *testEnv, n int) {
+	for i := 0; i < n; i++ {
+		env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+// expectBatchTx registers the single transaction a buffered batch creates its n jobs in
+func expectBatchTx(env *testEnv, n int) {
+	env.db.ExpectBegin()
+	prep := env.db.ExpectPrepare(`INSERT INTO t_algo_jobs \(job_id, scheme_code, user_id, status, progress, data_ref, params, metadata`)
+	for i := 0; i < n; i++ {
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	env.db.ExpectCommit()
+}
+
+// TestSubmitBatchJobsStreamsNDJSON tests that each item's result is flushed before the next is created
+func TestSubmitBatchJobsStreamsNDJSON(t *testing.T) {
+	env := newTestEnv(t)
+	release := make(chan struct{})
+	env.withAlgo(t, &fakeAlgo{submit: func(ctx context.Context, _ *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+	expectBatchInserts(env, 3)
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/api/v1/jobs/batch", strings.NewReader(batchBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", ndjsonContentType)
+
+	// Let the first item through before the response is even requested
+	go func() { release <- struct{}{} }()
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, ndjsonContentType, resp.Header.Get("Content-Type"))
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for i := 0; i < 3; i++ {
+		var line string
+		select {
+		case line = <-lines:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("item %d did not arrive while later items were still blocked", i)
+		}
+
+		var res BatchItemResult
+		require.NoError(t, json.Unmarshal([]byte(line), &res))
+		assert.Equal(t, i, res.Index)
+		assert.Equal(t, "PENDING", res.Status)
+		assert.NotEmpty(t, res.JobID)
+
+		if i < 2 {
+			// Nothing more may arrive until the next submission is released
+			select {
+			case extra := <-lines:
+				t.Fatalf("unexpected line before release: %s", extra)
+			case <-time.After(50 * time.Millisecond):
+			}
+			release <- struct{}{}
+		}
+	}
+
+	_, open := <-lines
+	assert.False(t, open)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsBuffered tests the array response for non-streaming clients
+func TestSubmitBatchJobsBuffered(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	filter, err := services.NewSchemeFilter(nil, []string{"KBM-WF03"})
+	require.NoError(t, err)
+	cfg.SchemeFilter = filter
+
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+	expectBatchTx(env, 2)
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(batchBody))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var resp struct {
+		Results   []BatchItemResult `json:"results"`
+		Submitted int               `json:"submitted"`
+		Failed    int               `json:"failed"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, 2, resp.Submitted)
+	assert.Equal(t, 1, resp.Failed)
+	assert.Equal(t, "REJECTED", resp.Results[2].Status)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsValidation tests empty and oversized batches
+func TestSubmitBatchJobsValidation(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(`{"jobs":[]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	items := make([]string, maxBatchSize+1)
+	for i := range items {
+		items[i] = `{"scheme":"KBM-WF01","data_id":"d"}`
+	}
+	w = env.do(r, "POST", "/api/v1/jobs/batch", []byte(`{"jobs":[`+strings.Join(items, ",")+`]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Batch too large")
+}
+
+// TestSubmitBatchJobsAggregateParamsCap tests that a batch whose params add up past the cap is
+// rejected before any item is created, even though each item is within the per-job cap
+func TestSubmitBatchJobsAggregateParamsCap(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.MaxParamsBytes = 100
+	cfg.MaxBatchParamsBytes = 250
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+
+	item := `{"scheme":"KBM-WF01","data_id":"d","params":{"blob":"` + strings.Repeat("x", 80) + `"}}`
+	body := `{"jobs":[` + strings.Join([]string{item, item, item, item}, ",") + `]}`
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(body))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "Batch params too large")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsRejectsOversizedItem tests that an item over the per-job cap is rejected on its own
+func TestSubmitBatchJobsRejectsOversizedItem(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.MaxParamsBytes = 50
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+	expectBatchTx(env, 1)
+
+	big := `{"scheme":"KBM-WF01","data_id":"d","params":{"blob":"` + strings.Repeat("x", 80) + `"}}`
+	body := `{"jobs":[` + big + `,{"scheme":"KBM-WF02","data_id":"d"}]}`
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(body))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Results []BatchItemResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, "REJECTED", resp.Results[0].Status)
+	assert.Contains(t, resp.Results[0].Error, "Params too large")
+	assert.Equal(t, "PENDING", resp.Results[1].Status)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsPartialDispatchFailure tests that items the algorithm service refuses are
+// marked FAILED while the rest of the batch is still dispatched, in item order
+func TestSubmitBatchJobsPartialDispatchFailure(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		if req.DataRef == "d2" {
+			return nil, status.Error(codes.InvalidArgument, "bad data")
+		}
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+	expectBatchTx(env, 3)
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(batchBody))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Results   []BatchItemResult `json:"results"`
+		Submitted int               `json:"submitted"`
+		Failed    int               `json:"failed"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 3)
+	for i, res := range resp.Results {
+		assert.Equal(t, i, res.Index)
+		assert.NotEmpty(t, res.JobID)
+	}
+	assert.Equal(t, "PENDING", resp.Results[0].Status)
+	assert.Equal(t, "FAILED", resp.Results[1].Status)
+	assert.Contains(t, resp.Results[1].Error, "bad data")
+	assert.Equal(t, "PENDING", resp.Results[2].Status)
+	assert.Equal(t, 2, resp.Submitted)
+	assert.Equal(t, 1, resp.Failed)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsTransactionFailure tests that no job is dispatched when the batch insert fails
+func TestSubmitBatchJobsTransactionFailure(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{submit: func(context.Context, *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		t.Error("job dispatched after a failed insert")
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+	env.db.ExpectBegin()
+	prep := env.db.ExpectPrepare(`INSERT INTO t_algo_jobs`)
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WillReturnError(errors.New("deadlock found"))
+	env.db.ExpectRollback()
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(batchBody))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Results []BatchItemResult `json:"results"`
+		Failed  int               `json:"failed"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Failed)
+	for _, res := range resp.Results {
+		assert.Equal(t, "REJECTED", res.Status)
+		assert.Contains(t, res.Error, "deadlock found")
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsBoundsDispatchConcurrency tests that at most
+// BatchDispatchConcurrency items are submitted at once and results keep item order
+func TestSubmitBatchJobsBoundsDispatchConcurrency(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.BatchDispatchConcurrency = 3
+	env := newTestEnvWithConfig(t, cfg)
+	var inFlight, peak atomic.Int32
+	env.withAlgo(t, &fakeAlgo{submit: func(context.Context, *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+
+	const items = 12
+	jobs := make([]string, items)
+	for i := range jobs {
+		jobs[i] = fmt.Sprintf(`{"scheme":"KBM-WF01","data_id":"d%d"}`, i)
+	}
+	expectBatchTx(env, items)
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(`{"jobs":[`+strings.Join(jobs, ",")+`]}`))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Results   []BatchItemResult `json:"results"`
+		Submitted int               `json:"submitted"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, items)
+	for i, res := range resp.Results {
+		assert.Equal(t, i, res.Index)
+		assert.Equal(t, "PENDING", res.Status)
+	}
+	assert.Equal(t, items, resp.Submitted)
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+	assert.Greater(t, peak.Load(), int32(1), "items were not dispatched concurrently")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
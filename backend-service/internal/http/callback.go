@@ -0,0 +1,53 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// checkCallback validates the callback URL of a submission and that
+// progress milestones come with one
+func checkCallback(req SubmitJobRequest) error {
+	if req.CallbackURL != "" {
+		u, err := url.Parse(req.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("callback_url must be an absolute http or https URL")
+		}
+	}
+	if len(req.ProgressMilestones) > 0 && req.CallbackURL == "" {
+		return errors.New("progress_milestones requires callback_url")
+	}
+	return nil
+}
+
+// ListJobCallbacks godoc
+// @Summary      List a job's callback deliveries
+// @Description  Returns the completion and progress milestone webhook deliveries of a job with their status (PENDING,
+// @Description  DELIVERED or FAILED), attempt count, last error and next attempt time
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Job ID"
+// @Success      200  {object}  map[string]any
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/{id}/callbacks [get]
+func (h *Handler) ListJobCallbacks(c *gin.Context) {
+	jobID := c.Param("id")
+	if _, err := h.store.GetJobTyped(c.Request.Context(), jobID); err != nil {
+		respondError(c, jobLookupError(jobID, err))
+		return
+	}
+
+	callbacks, err := h.store.ListJobCallbacks(c.Request.Context(), jobID)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to list callbacks", err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"job_id": jobID, "callbacks": callbacks})
+}
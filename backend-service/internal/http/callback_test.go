@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// TestSubmitJobStoresProgressMilestones tests that milestones are stored
+// sorted and deduplicated with their callback URL
+func TestSubmitJobStoresProgressMilestones(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET callback_url = \?, progress_milestones = \? WHERE job_id = \?`).
+		WithArgs("https://example.com/hook", "[25,50,75]", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"scheme":"KBM-WF01","data_id":"d1","callback_url":"https://example.com/hook","progress_milestones":[75,25,50,25]}`
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitJobStoresCallbackURL tests that a callback URL is stored without
+// milestones, for the completion callback
+func TestSubmitJobStoresCallbackURL(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET callback_url = \?, progress_milestones = \? WHERE job_id = \?`).
+		WithArgs("https://example.com/hook", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1","callback_url":"https://example.com/hook"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListJobCallbacks tests the delivery status of a job's callbacks
+func TestListJobCallbacks(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/:id/callbacks", env.handler.ListJobCallbacks)
+
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS"})
+	env.db.ExpectQuery(`FROM t_job_callbacks WHERE job_id = \? ORDER BY id`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "delivery_id", "job_id", "event", "url", "payload", "status", "attempts",
+			"last_error", "next_attempt_at", "delivered_at", "created_at"}).
+			AddRow(1, "delivery-1", "job-1", "job.completed", "https://example.com/hook", `{}`, "PENDING", 2,
+				"webhook returned 502 Bad Gateway", now.Add(time.Minute), nil, now))
+
+	w := env.do(r, "GET", "/api/v1/jobs/job-1/callbacks", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Callbacks []map[string]any `json:"callbacks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Callbacks, 1)
+	cb := body.Callbacks[0]
+	assert.Equal(t, "PENDING", cb["status"])
+	assert.Equal(t, float64(2), cb["attempts"])
+	assert.Equal(t, "webhook returned 502 Bad Gateway", cb["last_error"])
+	assert.Equal(t, "2026-03-01T08:01:00Z", cb["next_attempt_at"])
+	assert.NotContains(t, cb, "payload")
+	assert.NotContains(t, cb, "delivered_at")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitJobRejectsInvalidMilestones tests milestone and callback URL validation
+func TestSubmitJobRejectsInvalidMilestones(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	tests := []struct {
+		name, body, want string
+	}{
+		{"milestones without callback", `"progress_milestones":[50]`, "progress_milestones requires callback_url"},
+		{"relative callback", `"callback_url":"/hook","progress_milestones":[50]`, "absolute http or https URL"},
+		{"unsupported scheme", `"callback_url":"ftp://example.com/hook"`, "absolute http or https URL"},
+		{"milestone out of range", `"callback_url":"https://example.com/hook","progress_milestones":[0,50]`, "Invalid request"},
+		{"milestone over 100", `"callback_url":"https://example.com/hook","progress_milestones":[101]`, "Invalid request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1",`+tt.body+`}`))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.want)
+		})
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
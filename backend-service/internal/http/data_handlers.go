@@ -0,0 +1,197 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// dataFileExt matches the file extensions kept on stored uploads, which tell
+// the algorithm service how to parse them
+var dataFileExt = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// DataUploadResponse describes a stored data file
+type DataUploadResponse struct {
+	models.DataUploadMeta
+	// Deduplicated is set when the same file was uploaded before and its data_ref is reused
+	Deduplicated bool `json:"deduplicated"`
+}
+
+// UploadData godoc
+// @Summary      Upload job input data
+// @Description  Stores a data file sent as the multipart field "file" and returns the data_ref jobs pass as data_id.
+// @Description  Files are identified by their SHA-256 checksum and the uploader: uploading a file the caller
+// @Description  already stored returns its existing data_ref with status 200 instead of 201. Other users'
+// @Description  uploads of the same file are never returned.
+// @Tags         data
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file  formData  file  true  "Data file"
+// @Success      200  {object}  DataUploadResponse
+// @Success      201  {object}  DataUploadResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      415  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /api/v1/data [post]
+func (h *Handler) UploadData(c *gin.Context) {
+	if h.cfg.DataStore == nil {
+		respondError(c, apperr.Unavailable("Data upload not enabled", ""))
+		return
+	}
+
+	maxBytes := h.cfg.DataUploadMaxBytes
+	if maxBytes > 0 {
+		// Leave headroom for the multipart framing around the file
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+64<<10)
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.rejectUploadTooLarge(c, maxBytes)
+			return
+		}
+		respondError(c, apperr.Validation("Invalid request", "multipart field \"file\" is required: "+err.Error()))
+		return
+	}
+	if maxBytes > 0 && header.Size > maxBytes {
+		h.rejectUploadTooLarge(c, maxBytes)
+		return
+	}
+	contentType, ok := h.uploadContentType(header)
+	if !ok {
+		respondError(c, apperr.UnsupportedMedia(
+			"Unsupported content type",
+			fmt.Sprintf("content type %q is not allowed; allowed: %s", contentType, strings.Join(h.cfg.DataUploadContentTypes, ", ")),
+		))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to read upload", err.Error()))
+		return
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		respondError(c, apperr.Internal("Failed to read upload", err.Error()))
+		return
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	ctx := c.Request.Context()
+	caller := callerIdentity(c)
+	existing, err := h.store.GetDataUploadByChecksum(ctx, checksum, caller)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to look up upload", err.Error()))
+		return
+	}
+	if existing != nil {
+		respond(c, http.StatusOK, DataUploadResponse{DataUploadMeta: *existing, Deduplicated: true})
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondError(c, apperr.Internal("Failed to read upload", err.Error()))
+		return
+	}
+	// Stored files are named by uploader and content, so a racing upload of
+	// the same file overwrites it harmlessly
+	name := checksum
+	if caller != "" {
+		owner := sha256.Sum256([]byte(caller))
+		name = hex.EncodeToString(owner[:8]) + "-" + checksum
+	}
+	if ext := strings.ToLower(filepath.Ext(header.Filename)); dataFileExt.MatchString(ext) {
+		name += ext
+	}
+	dataRef, err := h.cfg.DataStore.Put(ctx, name, file, header.Size)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to store data", err.Error()))
+		return
+	}
+
+	meta, created, err := h.store.InsertDataUpload(ctx, models.DataUploadMeta{
+		DataRef:     dataRef,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		Checksum:    checksum,
+		UploadedAt:  time.Now(),
+		UploadedBy:  caller,
+	})
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to record upload", err.Error()))
+		return
+	}
+	code := http.StatusCreated
+	if !created {
+		code = http.StatusOK
+	}
+	respond(c, code, DataUploadResponse{DataUploadMeta: meta, Deduplicated: !created})
+}
+
+// uploadContentType returns the media type of an uploaded file and whether
+// it is allowed; files sent without one are treated as octet streams
+func (h *Handler) uploadContentType(header *multipart.FileHeader) (string, bool) {
+	contentType := "application/octet-stream"
+	if v := header.Header.Get("Content-Type"); v != "" {
+		mediaType, _, err := mime.ParseMediaType(v)
+		if err != nil {
+			return v, false
+		}
+		contentType = mediaType
+	}
+	for _, allowed := range h.cfg.DataUploadContentTypes {
+		if strings.EqualFold(allowed, contentType) {
+			return contentType, true
+		}
+	}
+	return contentType, false
+}
+
+func (h *Handler) rejectUploadTooLarge(c *gin.Context, maxBytes int64) {
+	respondError(c, apperr.TooLarge("Data file too large", fmt.Sprintf("data files must be at most %d bytes", maxBytes)))
+}
+
+// GetDataUpload godoc
+// @Summary      Get uploaded data metadata
+// @Description  Returns the metadata of an uploaded data file by its data_ref. The data_ref is a URL and
+// @Description  may be sent as is or path-escaped.
+// @Tags         data
+// @Produce      json
+// @Param        data_ref  path      string  true  "Data ref returned by the upload"
+// @Success      200  {object}  models.DataUploadMeta
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/data/{data_ref} [get]
+func (h *Handler) GetDataUpload(c *gin.Context) {
+	dataRef := strings.TrimPrefix(c.Param("data_ref"), "/")
+	meta, err := h.store.GetDataUpload(c.Request.Context(), dataRef)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to load upload", err.Error()))
+		return
+	}
+	if meta == nil {
+		respondError(c, apperr.NotFound("Data not found", "no upload with data_ref "+dataRef))
+		return
+	}
+	respond(c, http.StatusOK, meta)
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+var dataUploadRowColumns = []string{"data_ref", "filename", "content_type", "size", "checksum", "uploaded_by", "uploaded_at"}
+
+// newUploadRequest builds a multipart upload of content as filename
+func newUploadRequest(t *testing.T, filename, contentType, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest("POST", "/api/v1/data", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// decodeUpload checks the status of an upload response and decodes it
+func decodeUpload(t *testing.T, w *httptest.ResponseRecorder, code int) DataUploadResponse {
+	t.Helper()
+	require.Equal(t, code, w.Code, w.Body.String())
+	var resp DataUploadResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+// TestUploadDataDedupesByChecksum tests that a stored file gets a data_ref
+// which the uploader's identical uploads reuse, and other callers' do not
+func TestUploadDataDedupesByChecksum(t *testing.T) {
+	dataStore, err := storage.NewLocalDataStore(t.TempDir())
+	require.NoError(t, err)
+	cfg := DefaultHandlerConfig()
+	cfg.DataStore = dataStore
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	csv := "voltage,current\n220,5\n"
+	sum := sha256.Sum256([]byte(csv))
+	checksum := hex.EncodeToString(sum[:])
+	upload := func(filename, user string) *http.Request {
+		req := newUploadRequest(t, filename, "text/csv; charset=utf-8", csv)
+		if user != "" {
+			req.Header.Set("X-User-ID", user)
+		}
+		return req
+	}
+
+	env.db.ExpectQuery(`FROM t_data_uploads WHERE checksum = \? AND COALESCE\(uploaded_by, ''\) = \?`).
+		WithArgs(checksum, "user-1").
+		WillReturnRows(sqlmock.NewRows(dataUploadRowColumns))
+	env.db.ExpectExec(`INSERT IGNORE INTO t_data_uploads`).
+		WithArgs(sqlmock.AnyArg(), "readings.CSV", "text/csv", len(csv), checksum, "user-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	created := decodeUpload(t, serve(r, upload("readings.CSV", "user-1")), http.StatusCreated)
+	assert.False(t, created.Deduplicated)
+	assert.Equal(t, checksum, created.Checksum)
+	assert.Equal(t, "user-1", created.UploadedBy)
+
+	ref, err := url.Parse(created.DataRef)
+	require.NoError(t, err)
+	assert.Equal(t, "file", ref.Scheme)
+	assert.Equal(t, checksum+".csv", ref.Path[len(ref.Path)-len(checksum)-4:])
+	stored, err := os.ReadFile(ref.Path)
+	require.NoError(t, err)
+	assert.Equal(t, csv, string(stored))
+
+	// Uploading the same file again reuses its data_ref
+	uploadedAt := time.Now()
+	env.db.ExpectQuery(`FROM t_data_uploads WHERE checksum = \?`).WithArgs(checksum, "user-1").
+		WillReturnRows(sqlmock.NewRows(dataUploadRowColumns).
+			AddRow(created.DataRef, "readings.csv", "text/csv", len(csv), checksum, "user-1", uploadedAt))
+	again := decodeUpload(t, serve(r, upload("copy.csv", "user-1")), http.StatusOK)
+	assert.True(t, again.Deduplicated)
+	assert.Equal(t, created.DataRef, again.DataRef)
+	assert.Equal(t, "readings.csv", again.Filename)
+
+	// So does losing a race with a concurrent upload of it
+	env.db.ExpectQuery(`FROM t_data_uploads WHERE checksum = \?`).WithArgs(checksum, "user-1").
+		WillReturnRows(sqlmock.NewRows(dataUploadRowColumns))
+	env.db.ExpectExec(`INSERT IGNORE INTO t_data_uploads`).WillReturnResult(sqlmock.NewResult(0, 0))
+	env.db.ExpectQuery(`FROM t_data_uploads WHERE checksum = \?`).WithArgs(checksum, "user-1").
+		WillReturnRows(sqlmock.NewRows(dataUploadRowColumns).
+			AddRow(created.DataRef, "readings.csv", "text/csv", len(csv), checksum, "user-1", uploadedAt))
+	raced := decodeUpload(t, serve(r, upload("copy.csv", "user-1")), http.StatusOK)
+	assert.True(t, raced.Deduplicated)
+	assert.Equal(t, created.DataRef, raced.DataRef)
+
+	// Another caller's upload of the same file gets its own data_ref
+	for user, stored := range map[string]any{"user-2": "user-2", "": nil} {
+		env.db.ExpectQuery(`FROM t_data_uploads WHERE checksum = \?`).WithArgs(checksum, user).
+			WillReturnRows(sqlmock.NewRows(dataUploadRowColumns))
+		env.db.ExpectExec(`INSERT IGNORE INTO t_data_uploads`).
+			WithArgs(sqlmock.AnyArg(), "copy.csv", "text/csv", len(csv), checksum, stored, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		other := decodeUpload(t, serve(r, upload("copy.csv", user)), http.StatusCreated)
+		assert.False(t, other.Deduplicated)
+		assert.NotEqual(t, created.DataRef, other.DataRef)
+		assert.Equal(t, user, other.UploadedBy)
+	}
+
+	// The metadata is found by the escaped data_ref
+	env.db.ExpectQuery(`FROM t_data_uploads WHERE data_ref = \?`).WithArgs(created.DataRef).
+		WillReturnRows(sqlmock.NewRows(dataUploadRowColumns).
+			AddRow(created.DataRef, "readings.csv", "text/csv", len(csv), checksum, "", uploadedAt))
+	w := serve(r, httptest.NewRequest("GET", "/api/v1/data/"+url.PathEscape(created.DataRef), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var meta map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &meta))
+	assert.Equal(t, created.DataRef, meta["data_ref"])
+	assert.Equal(t, checksum, meta["checksum"])
+	assert.NotContains(t, meta, "uploaded_by")
+
+	env.db.ExpectQuery(`FROM t_data_uploads WHERE data_ref = \?`).WithArgs("file:///missing.csv").
+		WillReturnRows(sqlmock.NewRows(dataUploadRowColumns))
+	w = serve(r, httptest.NewRequest("GET", "/api/v1/data/file:///missing.csv", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestUploadDataRejections tests the size cap, content type allowlist and missing file
+func TestUploadDataRejections(t *testing.T) {
+	dataStore, err := storage.NewLocalDataStore(t.TempDir())
+	require.NoError(t, err)
+	cfg := DefaultHandlerConfig()
+	cfg.DataStore = dataStore
+	cfg.DataUploadMaxBytes = 16
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	w := serve(r, newUploadRequest(t, "big.csv", "text/csv", "0123456789abcdefg"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = serve(r, newUploadRequest(t, "huge.csv", "text/csv", string(make([]byte, 128<<10))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = serve(r, newUploadRequest(t, "photo.png", "image/png", "png"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), `content type \"image/png\" is not allowed`)
+
+	w = serve(r, newJSONRequest("POST", "/api/v1/data", `{}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// TestDispatchQueueCancelsQueuedJobWithoutAlgoCall tests that a job waiting
+// behind the in-flight cap is reported in stats and cancelled locally
+func TestDispatchQueueCancelsQueuedJobWithoutAlgoCall(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.DispatchMaxInFlight = 1
+	env := newTestEnvWithConfig(t, cfg)
+	t.Cleanup(func() { _ = env.handler.CloseDispatchQueue(context.Background()) })
+
+	submitted := make(chan string, 4)
+	release := make(chan struct{})
+	env.withAlgo(t, &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			submitted <- req.TaskId
+			<-release
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	r.POST("/api/v1/jobs/:id/cancel", env.handler.CancelJob)
+	r.GET("/api/v1/system/stats", env.handler.GetStats)
+
+	submit := func(body string) string {
+		env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+		w := env.do(r, "POST", "/api/v1/jobs", []byte(body))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp["job_id"]
+	}
+
+	running := submit(`{"scheme":"KBM-WF01","data_id":"d1"}`)
+	select {
+	case id := <-submitted:
+		require.Equal(t, running, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("first job was never dispatched")
+	}
+	queued := submit(`{"scheme":"KBM-WF01","data_id":"d2","priority":5}`)
+
+	env.db.ExpectQuery(`GROUP BY status`).WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow("PENDING", 2))
+	env.db.ExpectQuery(`SELECT AVG`).WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+	w := env.do(r, "GET", "/api/v1/system/stats?window=1h", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var stats struct {
+		DispatchQueue map[string]any `json:"dispatch_queue"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, map[string]any{
+		"depth": 1.0, "in_flight": 1.0, "max_in_flight": 1.0,
+		"in_flight_by_scheme": map[string]any{"KBM-WF01": 1.0}, "in_flight_by_resource": map[string]any{},
+	}, stats.DispatchQueue)
+
+	// The fake has no cancel handler, so reaching the algorithm service would fail
+	env.expectGetJob(jobRow{JobID: queued, Status: "PENDING"})
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'CANCELLED'`).
+		WithArgs("Cancelled by user", sqlmock.AnyArg(), sqlmock.AnyArg(), queued).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w = env.do(r, "POST", "/api/v1/jobs/"+queued+"/cancel", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"CANCELLED"`)
+
+	close(release)
+	select {
+	case id := <-submitted:
+		t.Fatalf("cancelled job %s was dispatched", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestDispatchQueueResourceLimitFromSchemeCache tests that a job's resource
+// type is looked up in the scheme cache, so a GPU job at the GPU limit waits
+// while a CPU job submitted after it is dispatched, and keeps waiting until
+// the running GPU job finishes rather than just its submission
+func TestDispatchQueueResourceLimitFromSchemeCache(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.DispatchMaxInFlight = 2
+	cfg.DispatchResourceLimits = map[string]int{"GPU": 1}
+	env := newTestEnvWithConfig(t, cfg)
+	t.Cleanup(func() { _ = env.handler.CloseDispatchQueue(context.Background()) })
+	require.NoError(t, env.handler.jobs.CacheSchemes(context.Background(), []models.Scheme{
+		{Model: "SCM", Code: "SCM-WF01", ResourceType: "GPU"},
+		{Model: "KBM", Code: "KBM-WF01", ResourceType: "CPU"},
+	}))
+
+	submitted := make(chan *pb.TaskRequest, 4)
+	release := make(chan struct{})
+	env.withAlgo(t, &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			submitted <- req
+			<-release
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	submit := func(body string) {
+		env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+		w := env.do(r, "POST", "/api/v1/jobs", []byte(body))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	next := func() *pb.TaskRequest {
+		select {
+		case req := <-submitted:
+			return req
+		case <-time.After(5 * time.Second):
+			t.Fatal("no job was dispatched")
+			return nil
+		}
+	}
+
+	submit(`{"scheme":"SCM-WF01","data_id":"d1"}`)
+	first := next()
+	assert.Equal(t, "SCM-WF01", first.SchemeCode)
+	submit(`{"scheme":"SCM-WF01","data_id":"d2","priority":5}`)
+	submit(`{"scheme":"KBM-WF01","data_id":"d3"}`)
+	assert.Equal(t, "KBM-WF01", next().SchemeCode)
+
+	schemes, resources := env.handler.dispatch.InFlightBySchemeAndResource()
+	assert.Equal(t, map[string]int{"SCM-WF01": 1, "KBM-WF01": 1}, schemes)
+	assert.Equal(t, map[string]int{"GPU": 1, "CPU": 1}, resources)
+	assert.Equal(t, 1, env.handler.dispatch.Depth())
+
+	// Both submissions are accepted, but the first GPU job is still RUNNING
+	close(release)
+	select {
+	case req := <-submitted:
+		t.Fatalf("%s dispatched while the GPU limit was taken", req.SchemeCode)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 1, env.handler.dispatch.Depth())
+	_, resources = env.handler.dispatch.InFlightBySchemeAndResource()
+	assert.Equal(t, 1, resources["GPU"])
+
+	env.expectFinishJob(first.TaskId, `{}`)
+	require.NoError(t, env.handler.jobs.FinishJob(context.Background(), first.TaskId, `{}`))
+	assert.Equal(t, "SCM-WF01", next().SchemeCode)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestDispatchQueueResourceTypeFetchedWhenCacheCold tests that a cold scheme
+// cache is filled from the algorithm service instead of skipping resource limits
+func TestDispatchQueueResourceTypeFetchedWhenCacheCold(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.DispatchMaxInFlight = 1
+	cfg.DispatchResourceLimits = map[string]int{"GPU": 1}
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{schemes: func(context.Context) (*pb.SchemeList, error) {
+		return &pb.SchemeList{Schemes: []*pb.SchemeList_Scheme{{Code: "SCM-WF01", Model: "SCM", ResourceType: "GPU"}}}, nil
+	}})
+
+	assert.Equal(t, "GPU", env.handler.resourceType(context.Background(), "SCM-WF01"))
+	assert.Equal(t, "", env.handler.resourceType(context.Background(), "KBM-WF09"))
+}
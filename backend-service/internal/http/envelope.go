@@ -0,0 +1,71 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// EnvelopeHeader lets a client pick the response shape per request:
+// "true" wraps responses in an Envelope, "false" returns the bare body
+const EnvelopeHeader = "X-Response-Envelope"
+
+// envelopeKey is the context key holding the response shape for a request
+const envelopeKey = "response_envelope"
+
+// Envelope wraps a response body when enveloped responses are enabled
+// @Description Enveloped response; exactly one of data and error is set
+type Envelope struct {
+	Data  any            `json:"data"`
+	Error *ErrorResponse `json:"error"`
+	Meta  EnvelopeMeta   `json:"meta"`
+}
+
+// EnvelopeMeta carries response metadata inside an Envelope
+type EnvelopeMeta struct {
+	Status    int    `json:"status" example:"200"`
+	RequestID string `json:"request_id,omitempty" example:"req-123"`
+}
+
+// responseEnvelope decides the response shape for each request: the
+// configured default, overridden by the EnvelopeHeader when present
+func responseEnvelope(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		use := enabled
+		switch c.GetHeader(EnvelopeHeader) {
+		case "true", "1":
+			use = true
+		case "false", "0":
+			use = false
+		}
+		c.Set(envelopeKey, use)
+		c.Next()
+	}
+}
+
+// respond writes a JSON response in the shape chosen for the request. Bare
+// mode writes body unchanged; envelope mode puts ErrorResponse bodies in
+// error and everything else in data.
+func respond(c *gin.Context, code int, body any) {
+	if !c.GetBool(envelopeKey) {
+		c.JSON(code, body)
+		return
+	}
+	env := Envelope{Meta: EnvelopeMeta{Status: code, RequestID: c.GetString("request_id")}}
+	switch e := body.(type) {
+	case ErrorResponse:
+		env.Error = &e
+	case *ErrorResponse:
+		env.Error = e
+	default:
+		env.Data = body
+	}
+	c.JSON(code, env)
+}
+
+// respondError writes err as an ErrorResponse carrying its status and
+// application code; errors other than *apperr.Error are internal errors
+func respondError(c *gin.Context, err error) {
+	e := apperr.As(err)
+	respond(c, e.Status(), ErrorResponse{Error: e.Title, Message: e.Message, Code: e.Code()})
+}
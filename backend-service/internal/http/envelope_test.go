@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/middleware"
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// envelopeRouter serves the schemes and job lookup endpoints with the given default shape
+func envelopeRouter(env *testEnv, enabled bool) *gin.Engine {
+	r := setupTestRouter()
+	r.Use(middleware.RequestID(), responseEnvelope(enabled))
+	r.GET("/api/v1/algorithms/schemes", env.handler.GetSchemes)
+	r.GET("/api/v1/jobs/:id", env.handler.GetJob)
+	return r
+}
+
+// getWithEnvelopeHeader issues a GET, setting X-Response-Envelope when value is not empty
+func getWithEnvelopeHeader(r http.Handler, path, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if value != "" {
+		req.Header.Set(EnvelopeHeader, value)
+	}
+	return serve(r, req)
+}
+
+// TestResponseBareMode tests that the compatibility mode keeps the current shapes
+func TestResponseBareMode(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedSchemes(t, models.Scheme{Code: "KBM-WF01"})
+	r := envelopeRouter(env, false)
+
+	w := getWithEnvelopeHeader(r, "/api/v1/algorithms/schemes", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var schemes []models.Scheme
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schemes))
+	require.Len(t, schemes, 1)
+	assert.Equal(t, "KBM-WF01", schemes[0].Code)
+
+	env.expectJobNotFound("missing")
+	w = getWithEnvelopeHeader(r, "/api/v1/jobs/missing", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	var errResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "Job not found", errResp.Error)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestResponseEnvelopeMode tests the {data, error, meta} shape for successes and errors
+func TestResponseEnvelopeMode(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedSchemes(t, models.Scheme{Code: "KBM-WF01"})
+	r := envelopeRouter(env, true)
+
+	w := getWithEnvelopeHeader(r, "/api/v1/algorithms/schemes", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var ok struct {
+		Data  []models.Scheme `json:"data"`
+		Error *ErrorResponse  `json:"error"`
+		Meta  EnvelopeMeta    `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ok))
+	require.Len(t, ok.Data, 1)
+	assert.Equal(t, "KBM-WF01", ok.Data[0].Code)
+	assert.Nil(t, ok.Error)
+	assert.Equal(t, http.StatusOK, ok.Meta.Status)
+	assert.NotEmpty(t, ok.Meta.RequestID)
+
+	env.expectJobNotFound("missing")
+	w = getWithEnvelopeHeader(r, "/api/v1/jobs/missing", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	var failed map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	assert.Nil(t, failed["data"])
+	assert.Equal(t, "Job not found", failed["error"].(map[string]any)["error"])
+	assert.Equal(t, float64(http.StatusNotFound), failed["meta"].(map[string]any)["status"])
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestResponseEnvelopeHeaderOverridesDefault tests per-request opt-in and opt-out
+func TestResponseEnvelopeHeaderOverridesDefault(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedSchemes(t, models.Scheme{Code: "KBM-WF01"})
+
+	w := getWithEnvelopeHeader(envelopeRouter(env, false), "/api/v1/algorithms/schemes", "true")
+	assert.Contains(t, w.Body.String(), `"data":[`)
+
+	w = getWithEnvelopeHeader(envelopeRouter(env, true), "/api/v1/algorithms/schemes", "false")
+	assert.True(t, json.Valid(w.Body.Bytes()))
+	assert.Equal(t, byte('['), w.Body.Bytes()[0])
+}
+
+// TestRespondErrorCodes tests that errors carry their kind's status and
+// application code in both response shapes
+func TestRespondErrorCodes(t *testing.T) {
+	r := setupTestRouter()
+	r.Use(responseEnvelope(false))
+	r.GET("/conflict", func(c *gin.Context) { respondError(c, apperr.Conflict("Job not resumable", "job is running")) })
+	r.GET("/upstream", func(c *gin.Context) {
+		respondError(c, fmt.Errorf("submit: %w", apperr.Upstream("Failed to submit job", "connection refused")))
+	})
+	r.GET("/plain", func(c *gin.Context) { respondError(c, errors.New("boom")) })
+
+	tests := []struct {
+		path   string
+		status int
+		want   ErrorResponse
+	}{
+		{"/conflict", http.StatusConflict, ErrorResponse{Error: "Job not resumable", Message: "job is running", Code: "CONFLICT"}},
+		{"/upstream", http.StatusBadGateway, ErrorResponse{Error: "Failed to submit job", Message: "connection refused", Code: "UPSTREAM_ERROR"}},
+		{"/plain", http.StatusInternalServerError, ErrorResponse{Error: "Internal error", Message: "boom", Code: "INTERNAL_ERROR"}},
+	}
+	for _, tt := range tests {
+		w := getWithEnvelopeHeader(r, tt.path, "")
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		var bare ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bare))
+		assert.Equal(t, tt.want, bare)
+
+		w = getWithEnvelopeHeader(r, tt.path, "true")
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		var env Envelope
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+		require.NotNil(t, env.Error)
+		assert.Equal(t, tt.want, *env.Error)
+		assert.Equal(t, tt.status, env.Meta.Status)
+	}
+}
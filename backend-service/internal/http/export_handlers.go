@@ -0,0 +1,122 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportColumns are the CSV columns of a job export
+var exportColumns = []string{"job_id", "scheme_code", "user_id", "status", "progress", "data_ref", "created_at", "finished_at", "error_log"}
+
+// ExportJobs godoc
+// @Summary      Export jobs as CSV or JSON
+// @Description  Streams jobs matching the filters, newest first. At most MAX_EXPORT_ROWS rows are exported;
+// @Description  when more match, X-Export-Truncated is set to true and X-Total-Count carries the full count.
+// @Tags         jobs
+// @Produce      text/csv
+// @Produce      json
+// @Param        format    query     string  false  "csv or json"  default(csv)
+// @Param        user_id   query     string  false  "Filter by user ID"
+// @Param        status    query     string  false  "Filter by status"
+// @Param        window    query     string  false  "Only jobs created within this duration (e.g. 1h, 24h, 7d)"
+// @Param        has_error query     bool    false  "Only jobs with a non-empty error_log, regardless of status"
+// @Param        scheme_code   query     string  false  "Filter by scheme code; a trailing * matches by prefix (e.g. KBM-*)"
+// @Param        created_from  query     string  false  "Only jobs created at or after this RFC3339 time"
+// @Param        created_to    query     string  false  "Only jobs created before this RFC3339 time"
+// @Success      200  {file}    file
+// @Header       200  {string}  X-Export-Truncated  "true when more jobs matched than were exported"
+// @Header       200  {int}     X-Total-Count       "Number of jobs matching the filters"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/export [get]
+func (h *Handler) ExportJobs(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		respondError(c, apperr.Validation("Invalid format", "format must be csv or json"))
+		return
+	}
+
+	filter, ok := jobFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	total, err := h.store.CountJobs(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to export jobs", err.Error()))
+		return
+	}
+
+	limit := h.cfg.MaxExportRows
+	truncated := limit > 0 && total > limit
+	if !truncated {
+		limit = total
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("X-Export-Truncated", strconv.FormatBool(truncated))
+
+	filename := "jobs-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	// Headers are committed from here on; a failure mid-stream can only
+	// cut the body short
+	if format == "csv" {
+		h.exportCSV(c, filter, limit)
+	} else {
+		h.exportJSON(c, filter, limit)
+	}
+}
+
+func (h *Handler) exportCSV(c *gin.Context, filter storage.JobFilter, limit int) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(exportColumns)
+	_ = h.store.ExportJobs(c.Request.Context(), filter, limit, func(job models.Job) error {
+		finished := ""
+		if job.FinishedAt.Valid {
+			finished = job.FinishedAt.Time.UTC().Format(time.RFC3339)
+		}
+		return w.Write([]string{
+			job.JobID,
+			job.SchemeCode,
+			job.UserID,
+			job.Status,
+			strconv.Itoa(job.Progress),
+			job.DataRef,
+			job.CreatedAt.UTC().Format(time.RFC3339),
+			finished,
+			job.ErrorLog,
+		})
+	})
+	w.Flush()
+}
+
+func (h *Handler) exportJSON(c *gin.Context, filter storage.JobFilter, limit int) {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	_, _ = c.Writer.WriteString("[")
+	first := true
+	_ = h.store.ExportJobs(c.Request.Context(), filter, limit, func(job models.Job) error {
+		if !first {
+			if _, err := c.Writer.WriteString(","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(job)
+	})
+	_, _ = c.Writer.WriteString("]")
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportRows(ids ...string) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"job_id", "scheme_code", "user_id", "status", "progress", "data_ref",
+		"params", "result_summary", "error_log", "created_at", "finished_at"})
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	for _, id := range ids {
+		rows.AddRow(id, "KBM-WF01", "user-1", "SUCCESS", 100, "d1", "{}", "", "", now, now)
+	}
+	return rows
+}
+
+// TestExportJobsEnforcesRowCap tests that exports stop at MaxExportRows and flag truncation
+func TestExportJobsEnforcesRowCap(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.MaxExportRows = 2
+	env := newTestEnvWithConfig(t, cfg)
+
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND status = \?`).
+		WithArgs("SUCCESS").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND status = \? ORDER BY created_at DESC LIMIT \?`).
+		WithArgs("SUCCESS", 2).
+		WillReturnRows(exportRows("job-1", "job-2"))
+
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/export", env.handler.ExportJobs)
+	w := env.do(r, "GET", "/api/v1/jobs/export?status=SUCCESS", nil)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Export-Truncated"))
+	assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3, "header plus capped rows")
+	assert.Equal(t, exportColumns, records[0])
+	assert.Equal(t, "job-1", records[1][0])
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestExportJobsJSONWithinCap tests a JSON export that fits under the cap
+func TestExportJobsJSONWithinCap(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	env.db.ExpectQuery(`ORDER BY created_at DESC LIMIT \?`).
+		WithArgs(2).
+		WillReturnRows(exportRows("job-1", "job-2"))
+
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/export", env.handler.ExportJobs)
+	w := env.do(r, "GET", "/api/v1/jobs/export?format=json", nil)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "false", w.Header().Get("X-Export-Truncated"))
+
+	var jobs []map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobs))
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "job-2", jobs[1]["job_id"])
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestExportJobsRejectsUnknownFormat tests format validation
+func TestExportJobsRejectsUnknownFormat(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/export", env.handler.ExportJobs)
+
+	w := env.do(r, "GET", "/api/v1/jobs/export?format=xml", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
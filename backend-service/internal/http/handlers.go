@@ -2,17 +2,29 @@ package http
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/electric-power/backend-service/internal/apperr"
 	"github.com/electric-power/backend-service/internal/grpcclient"
+	"github.com/electric-power/backend-service/internal/middleware"
 	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/scheduler"
 	"github.com/electric-power/backend-service/internal/services"
 	"github.com/electric-power/backend-service/internal/storage"
+	pb "github.com/electric-power/backend-service/proto"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type Handler struct {
@@ -20,17 +32,191 @@ type Handler struct {
 	algo  *grpcclient.AlgoClient
 	store *storage.MySQLStore
 	cache *storage.RedisCache
+	stats *services.StatsCollector
+	cfg   HandlerConfig
+
+	// watchers holds the progress watcher of each in-flight job; adoptStop
+	// ends the listener adopting watches handed off by other instances
+	watchMu   sync.Mutex
+	watchers  map[string]*progressWatch
+	adoptStop context.CancelFunc
+
+	// dispatch queues created jobs for the algorithm service (nil dispatches inline)
+	dispatch *services.DispatchQueue
+
+	logger *zap.Logger
+}
+
+// HandlerConfig holds optional behavior for the HTTP handlers
+type HandlerConfig struct {
+	// SchemeFilter hides disallowed schemes from listings and rejects their submission (nil allows all)
+	SchemeFilter *services.SchemeFilter
+
+	// DataStore receives inline job data (nil disables inline submission)
+	DataStore storage.DataStore
+	// InlineDataMaxBytes caps the decoded size of inline job data
+	InlineDataMaxBytes int64
+	// DataUploadMaxBytes caps the size of an uploaded data file (0 means no
+	// cap); DataUploadContentTypes are the media types it may have
+	DataUploadMaxBytes     int64
+	DataUploadContentTypes []string
+
+	// MaxExportRows caps the rows returned by a job export (0 means no cap)
+	MaxExportRows int
+
+	// UserIDStrategy decides the user of submissions without user_id (see UserIDStrategyDefault)
+	UserIDStrategy string
+	// DefaultUserID is stored for anonymous submissions under UserIDStrategyDefault
+	DefaultUserID string
+
+	// HealthCheckTimeout bounds each dependency check of the health endpoint
+	HealthCheckTimeout time.Duration
+	// SchedulerStatus, if set, reports the background scheduler's liveness to the health endpoint
+	SchedulerStatus func() scheduler.Status
+	// ResultServerAddr, if set, is the gRPC result listener the health endpoint dials
+	ResultServerAddr string
+
+	// MaxParamsBytes caps the serialized params of a single job and
+	// MaxBatchParamsBytes their sum across a batch (0 means no cap)
+	MaxParamsBytes      int
+	MaxBatchParamsBytes int
+	// MaxParamsDepth caps how deeply params may nest and MaxParamsElements
+	// the values they hold across all levels (0 means no cap)
+	MaxParamsDepth    int
+	MaxParamsElements int
+
+	// SubmitWaitTimeout bounds how long ?wait=accepted submissions wait for
+	// the job to start before answering PENDING
+	SubmitWaitTimeout time.Duration
+
+	// BulkCancelConcurrency caps the cancel requests a bulk cancellation has
+	// in flight at once
+	BulkCancelConcurrency int
+	// BatchDispatchConcurrency caps the submissions to the algorithm service
+	// one batch has in flight at once
+	BatchDispatchConcurrency int
+
+	// AlgoTargets are alternate algorithm services admins can route a
+	// submission to by name with the X-Algo-Target header
+	AlgoTargets map[string]*grpcclient.AlgoClient
+
+	// DispatchMaxInFlight caps the submissions to the algorithm service in
+	// flight at once; jobs beyond it wait in a priority queue holding at most
+	// DispatchQueueCapacity jobs. Zero dispatches each job inline.
+	DispatchMaxInFlight   int
+	DispatchQueueCapacity int
+	// DispatchSchemeLimits and DispatchResourceLimits cap the queued
+	// submissions in flight per scheme code and per scheme resource type;
+	// jobs at a cap wait while jobs of other schemes are dispatched
+	DispatchSchemeLimits   map[string]int
+	DispatchResourceLimits map[string]int
+
+	// ColdSchemeCache decides how submissions are validated while the scheme
+	// cache is empty (see ColdSchemeCacheStrict and ColdSchemeCacheOptimistic)
+	ColdSchemeCache string
+
+	// ListCountTimeout bounds the total count of the list endpoint; when it
+	// expires the page is returned without a total. ListQueryTimeout bounds
+	// the page query. Zero leaves a query bounded only by the request.
+	ListCountTimeout time.Duration
+	ListQueryTimeout time.Duration
+
+	// ResultPathAllowlist limits the result non-admin callers see to these
+	// dot-separated paths, per scheme code; the "*" entry applies to schemes
+	// without their own. Schemes without an entry return the full result.
+	ResultPathAllowlist map[string][]string
+
+	// WatchHandoffTTL is how long progress watches handed off at shutdown
+	// wait in Redis for another instance to adopt them
+	WatchHandoffTTL time.Duration
+
+	// ZombieStrategy, ZombieTimeout and ZombieTimeoutOverrides are the
+	// zombie detection settings of the zombie endpoints; they should match
+	// the scheduler's
+	ZombieStrategy         storage.ZombieStrategy
+	ZombieTimeout          time.Duration
+	ZombieTimeoutOverrides map[string]time.Duration
+}
+
+// DefaultHandlerConfig returns the default handler configuration
+func DefaultHandlerConfig() HandlerConfig {
+	return HandlerConfig{
+		InlineDataMaxBytes: 1 << 20,   // 1MB
+		DataUploadMaxBytes: 256 << 20, // 256MB
+		DataUploadContentTypes: []string{
+			"text/csv",
+			"application/json",
+			"application/vnd.apache.parquet",
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			"application/octet-stream",
+		},
+
+		MaxExportRows:      100000,
+		UserIDStrategy:     UserIDStrategyDefault,
+		DefaultUserID:      "anonymous",
+		HealthCheckTimeout: 2 * time.Second,
+
+		MaxParamsBytes:      256 << 10, // 256KB
+		MaxBatchParamsBytes: 4 << 20,   // 4MB
+		MaxParamsDepth:      32,
+		MaxParamsElements:   100000,
+
+		SubmitWaitTimeout: 10 * time.Second,
+
+		BulkCancelConcurrency:    8,
+		BatchDispatchConcurrency: 8,
+
+		DispatchQueueCapacity: 10000,
+
+		ColdSchemeCache: ColdSchemeCacheOptimistic,
+
+		ListCountTimeout: 3 * time.Second,
+		ListQueryTimeout: 10 * time.Second,
+
+		WatchHandoffTTL: 10 * time.Minute,
+
+		ZombieStrategy: storage.ZombieByUpdatedAt,
+		ZombieTimeout:  30 * time.Minute,
+	}
 }
 
 // SubmitJobRequest represents the request body for job submission
 // @Description Job submission request payload
 type SubmitJobRequest struct {
-	Scheme string         `json:"scheme" binding:"required" example:"KBM-WF01"`
-	DataID string         `json:"data_id" binding:"required" example:"sample_001"`
-	Params map[string]any `json:"params" example:"{\"threshold\": 0.9}"`
-	UserID string         `json:"user_id" example:"user_001"`
+	Scheme   string            `json:"scheme" binding:"required" example:"KBM-WF01"`
+	DataID   string            `json:"data_id" binding:"required" example:"sample_001"`
+	Params   map[string]any    `json:"params" example:"{\"threshold\": 0.9}"`
+	UserID   string            `json:"user_id" example:"user_001"`
+	Metadata map[string]string `json:"metadata,omitempty" binding:"omitempty,max=32" example:"{\"trace_id\": \"abc123\"}"`
+	// Priority orders dispatch when jobs are queued: higher values go first
+	Priority int `json:"priority,omitempty" example:"0"`
+	// SLADeadline is when the job is expected to have finished; jobs
+	// approaching or past it are flagged, not failed
+	SLADeadline *time.Time `json:"sla_deadline,omitempty" example:"2026-05-01T18:00:00Z"`
+	// TimeoutSeconds fails and force-cancels the job once it has run this
+	// long since submission; unset leaves it to the zombie cleanup
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" binding:"omitempty,min=1" example:"3600"`
+	// CallbackURL receives a signed POST when the job finishes and each
+	// time its progress crosses one of ProgressMilestones (percentages)
+	CallbackURL        string `json:"callback_url,omitempty" example:"https://example.com/hooks/jobs"`
+	ProgressMilestones []int  `json:"progress_milestones,omitempty" binding:"omitempty,max=20,dive,min=1,max=100" example:"25,50,75"`
+
+	// algoTarget is taken from the X-Algo-Target header
+	algoTarget string
+}
+
+// timeoutAt is when a job submitted now exceeds TimeoutSeconds, nil without one
+func (r SubmitJobRequest) timeoutAt() *time.Time {
+	if r.TimeoutSeconds <= 0 {
+		return nil
+	}
+	at := time.Now().Add(time.Duration(r.TimeoutSeconds) * time.Second)
+	return &at
 }
 
+// AlgoTargetHeader routes a submission to a named alternate algorithm service
+const AlgoTargetHeader = "X-Algo-Target"
+
 // JobResponse represents the response for job queries
 // @Description Job information response
 type JobResponse struct {
@@ -47,7 +233,8 @@ type JobResponse struct {
 type ErrorResponse struct {
 	Error   string `json:"error" example:"Invalid request"`
 	Message string `json:"message,omitempty" example:"Detailed error message"`
-	Code    int    `json:"code,omitempty" example:"400"`
+	// Code is the stable application error code, e.g. VALIDATION_ERROR
+	Code string `json:"code" example:"VALIDATION_ERROR"`
 }
 
 // SuccessResponse represents a generic success response
@@ -59,7 +246,72 @@ type SuccessResponse struct {
 
 // NewHandler creates a new HTTP handler
 func NewHandler(jobs *services.JobService, algo *grpcclient.AlgoClient, store *storage.MySQLStore, cache *storage.RedisCache) *Handler {
-	return &Handler{jobs: jobs, algo: algo, store: store, cache: cache}
+	return NewHandlerWithConfig(jobs, algo, store, cache, DefaultHandlerConfig())
+}
+
+// NewHandlerWithConfig creates a handler with custom configuration
+func NewHandlerWithConfig(jobs *services.JobService, algo *grpcclient.AlgoClient, store *storage.MySQLStore, cache *storage.RedisCache, cfg HandlerConfig) *Handler {
+	if cfg.ZombieStrategy != storage.ZombieByProgress {
+		cfg.ZombieStrategy = storage.ZombieByUpdatedAt
+	}
+	if cfg.ZombieTimeout <= 0 {
+		cfg.ZombieTimeout = DefaultHandlerConfig().ZombieTimeout
+	}
+	h := &Handler{
+		jobs:  jobs,
+		algo:  algo,
+		store: store,
+		cache: cache,
+		stats: services.NewStatsCollector(store, 5*time.Second),
+		cfg:   cfg,
+
+		watchers: make(map[string]*progressWatch),
+		logger:   zap.NewNop(),
+	}
+	if jobs != nil {
+		jobs.AddHook(h.stopWatchOnResult)
+		jobs.AddHook(h.advancePipeline)
+	}
+	if cfg.DispatchMaxInFlight > 0 {
+		h.dispatch = services.NewDispatchQueue(services.DispatchQueueConfig{
+			Capacity:       cfg.DispatchQueueCapacity,
+			MaxInFlight:    cfg.DispatchMaxInFlight,
+			SchemeLimits:   cfg.DispatchSchemeLimits,
+			ResourceLimits: cfg.DispatchResourceLimits,
+			Finished:       h.finishedJobs,
+		}, func(ctx context.Context, job services.QueuedJob) {
+			_ = h.dispatchNow(ctx, job)
+		})
+		if jobs != nil {
+			jobs.SetDispatchQueue(h.dispatch)
+		}
+	}
+	return h
+}
+
+// SetLogger sets the logger used for submission diagnostics
+func (h *Handler) SetLogger(logger *zap.Logger) {
+	if logger != nil {
+		h.logger = logger
+	}
+}
+
+// CloseDispatchQueue stops the dispatch queue, waiting for running
+// submissions until ctx expires. Jobs that never left the queue are failed.
+func (h *Handler) CloseDispatchQueue(ctx context.Context) error {
+	if h.dispatch == nil {
+		return nil
+	}
+	left, err := h.dispatch.Close(ctx)
+	for _, job := range left {
+		_ = h.jobs.FailJob(context.Background(), job.JobID, "Not dispatched before shutdown")
+	}
+	return err
+}
+
+// StatsCollector exposes the shared stats collector for metrics registration
+func (h *Handler) StatsCollector() *services.StatsCollector {
+	return h.stats
 }
 
 // GetSchemes godoc
@@ -69,59 +321,333 @@ func NewHandler(jobs *services.JobService, algo *grpcclient.AlgoClient, store *s
 // @Accept       json
 // @Produce      json
 // @Success      200  {array}   models.Scheme
-// @Failure      500  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
 // @Router       /api/v1/algorithms/schemes [get]
 func (h *Handler) GetSchemes(c *gin.Context) {
-	schemes, err := h.jobs.GetCachedSchemes(c.Request.Context())
+	schemes, err := h.visibleSchemes(c.Request.Context())
+	if err != nil {
+		respondError(c, apperr.Upstream("Failed to get schemes", err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, schemes)
+}
+
+// visibleSchemes returns the cached schemes, falling back to the algorithm
+// service on a cache miss, with the configured scheme filter applied
+func (h *Handler) visibleSchemes(ctx context.Context) ([]models.Scheme, error) {
+	schemes, err := h.jobs.GetCachedSchemes(ctx)
 	if err != nil {
 		// Try to fetch from algo service directly
-		schemes, err = h.algo.GetSchemes(c.Request.Context())
+		schemes, err = h.fetchSchemes(ctx)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get schemes", Message: err.Error()})
-			return
+			return nil, err
 		}
-		// Cache for next time
-		_ = h.jobs.CacheSchemes(c.Request.Context(), schemes)
 	}
-	c.JSON(http.StatusOK, schemes)
+	return h.cfg.SchemeFilter.Filter(schemes), nil
+}
+
+// rejectDisallowedScheme writes a 403 and returns true when the scheme is filtered out
+func (h *Handler) rejectDisallowedScheme(c *gin.Context, schemeCode string) bool {
+	if h.cfg.SchemeFilter.Allowed(schemeCode) {
+		return false
+	}
+	respondError(c, apperr.Forbidden("Scheme not allowed", "Scheme "+schemeCode+" is not available for submission"))
+	return true
+}
+
+// rejectUnknownScheme writes a 400 for a scheme the algorithm service does
+// not offer, or a 503 when the schemes can't be fetched to check, and returns
+// true in either case
+func (h *Handler) rejectUnknownScheme(c *gin.Context, schemeCode string) bool {
+	err := h.checkSchemeKnown(c.Request.Context(), schemeCode)
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errUnknownScheme):
+		respondError(c, apperr.Validation("Unknown scheme", err.Error()))
+	default:
+		respondError(c, apperr.Unavailable("Scheme validation unavailable", err.Error()))
+	}
+	return true
+}
+
+// rejectSchemeAtCapacity writes a 429 and returns true when err reports that
+// the scheme has reached its concurrency limit
+func (h *Handler) rejectSchemeAtCapacity(c *gin.Context, schemeCode string, err error) bool {
+	if !errors.Is(err, services.ErrSchemeAtCapacity) {
+		return false
+	}
+	respondError(c, apperr.RateLimited(
+		"Scheme at capacity",
+		fmt.Sprintf("Scheme %s already has %d jobs in flight; retry later", schemeCode, h.jobs.SchemeLimiter().Limit(schemeCode)),
+	))
+	return true
 }
 
 // SubmitJob godoc
 // @Summary      Submit a new algorithm job
-// @Description  Creates a new job and dispatches it to the algorithm service for processing
+// @Description  Creates a new job and dispatches it to the algorithm service for processing.
+// @Description  With wait=accepted the response is held until the job reports progress or finishes;
+// @Description  if that takes too long the job is returned as PENDING with a note and keeps running.
 // @Tags         jobs
 // @Accept       json
 // @Produce      json
-// @Param        X-Request-ID  header    string          false  "Idempotency key for duplicate prevention"
+// @Param        X-Request-ID  header    string          false  "Idempotency key; a duplicate gets the original response replayed with X-Idempotent-Replay"
+// @Param        X-Algo-Target header    string          false  "Admin only: name of an alternate algorithm service to route the job to"
+// @Param        wait          query     string          false  "Set to accepted to wait until the job has started"  Enums(accepted)
 // @Param        request       body      SubmitJobRequest  true   "Job submission request"
-// @Success      200  {object}  map[string]string  "Returns job_id"
+// @Success      200  {object}  map[string]string  "Returns job_id and status"
 // @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "A request with the same idempotency key is still being processed"
+// @Failure      413  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
 // @Router       /api/v1/jobs [post]
 func (h *Handler) SubmitJob(c *gin.Context) {
 	var req SubmitJobRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request", Message: err.Error(), Code: 400})
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
+		return
+	}
+	wait := c.Query("wait")
+	if wait != "" && wait != "accepted" {
+		respondError(c, apperr.Validation("Invalid request", "wait must be 'accepted' when set"))
+		return
+	}
+	var ok bool
+	if req.UserID, ok = h.submissionUser(c, req.UserID); !ok {
+		return
+	}
+
+	if wait == "" {
+		jobID, ok := h.dispatchJob(c, req)
+		if !ok {
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"job_id": jobID, "status": "PENDING"})
 		return
 	}
 
+	// Register before dispatch so an immediate progress update is not missed
 	jobID := uuid.NewString()
+	started, stopWaiting := h.jobs.AwaitStart(jobID)
+	defer stopWaiting()
+	if !h.dispatchJobWithID(c, jobID, req) {
+		return
+	}
+
+	timer := time.NewTimer(h.cfg.SubmitWaitTimeout)
+	defer timer.Stop()
+	select {
+	case status := <-started:
+		respond(c, http.StatusOK, gin.H{"job_id": jobID, "status": status})
+	case <-timer.C:
+		respond(c, http.StatusOK, gin.H{
+			"job_id": jobID,
+			"status": "PENDING",
+			"note":   "the job did not start within " + h.cfg.SubmitWaitTimeout.String() + "; it continues asynchronously",
+		})
+	case <-c.Request.Context().Done():
+	}
+}
+
+// dispatchJob creates the job row and hands it to the algorithm service.
+// On failure it writes the error response and returns false.
+func (h *Handler) dispatchJob(c *gin.Context, req SubmitJobRequest) (string, bool) {
+	jobID := uuid.NewString()
+	return jobID, h.dispatchJobWithID(c, jobID, req)
+}
+
+// dispatchJobWithID is dispatchJob for a job ID chosen by the caller
+func (h *Handler) dispatchJobWithID(c *gin.Context, jobID string, req SubmitJobRequest) bool {
+	if h.rejectDisallowedScheme(c, req.Scheme) {
+		return false
+	}
+	if h.rejectUnknownScheme(c, req.Scheme) {
+		return false
+	}
+	if req.algoTarget = c.GetHeader(AlgoTargetHeader); !h.knownAlgoTarget(c, req.algoTarget) {
+		return false
+	}
+
+	if err := h.checkParamsShape(req.Params); err != nil {
+		respondError(c, apperr.Validation("Invalid params", err.Error()))
+		return false
+	}
+	if req.SLADeadline != nil && !req.SLADeadline.After(time.Now()) {
+		respondError(c, apperr.Validation("Invalid sla_deadline", "sla_deadline must be in the future"))
+		return false
+	}
+	if err := checkCallback(req); err != nil {
+		respondError(c, apperr.Validation("Invalid callback", err.Error()))
+		return false
+	}
 	paramsJSON, _ := json.Marshal(req.Params)
+	if h.paramsTooLarge(len(paramsJSON)) {
+		respondError(c, apperr.TooLarge("Params too large", fmt.Sprintf("params must serialize to at most %d bytes", h.cfg.MaxParamsBytes)))
+		return false
+	}
 
 	if err := h.jobs.CreateJob(c.Request.Context(), jobID, req.Scheme, req.UserID, req.DataID, string(paramsJSON)); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create job", Message: err.Error()})
-		return
+		if !h.rejectSchemeAtCapacity(c, req.Scheme, err) {
+			respondError(c, apperr.Internal("Failed to create job", err.Error()))
+		}
+		return false
+	}
+	if err := h.jobs.RecordMetadata(c.Request.Context(), jobID, req.Metadata); err != nil {
+		_ = h.jobs.FailJob(c.Request.Context(), jobID, "Failed to store metadata: "+err.Error())
+		respondError(c, apperr.Internal("Failed to create job", err.Error()))
+		return false
+	}
+	if err := h.jobs.RecordSLADeadline(c.Request.Context(), jobID, req.SLADeadline); err != nil {
+		_ = h.jobs.FailJob(c.Request.Context(), jobID, "Failed to store SLA deadline: "+err.Error())
+		respondError(c, apperr.Internal("Failed to create job", err.Error()))
+		return false
+	}
+	if err := h.jobs.RecordTimeout(c.Request.Context(), jobID, req.timeoutAt()); err != nil {
+		_ = h.jobs.FailJob(c.Request.Context(), jobID, "Failed to store timeout: "+err.Error())
+		respondError(c, apperr.Internal("Failed to create job", err.Error()))
+		return false
+	}
+	if err := h.jobs.RecordCallback(c.Request.Context(), jobID, req.CallbackURL, req.ProgressMilestones); err != nil {
+		_ = h.jobs.FailJob(c.Request.Context(), jobID, "Failed to store callback: "+err.Error())
+		respondError(c, apperr.Internal("Failed to create job", err.Error()))
+		return false
+	}
+
+	if err := h.submitToAlgo(c.Request.Context(), queuedJob(jobID, req)); err != nil {
+		if errors.Is(err, services.ErrDispatchQueueFull) {
+			c.Header("Retry-After", "5")
+			respondError(c, apperr.Unavailable("Dispatch queue full", err.Error()))
+			return false
+		}
+		respondError(c, apperr.Upstream("Failed to submit job", err.Error()))
+		return false
+	}
+	return true
+}
+
+// paramsTooLarge reports whether serialized params exceed the per-job cap
+func (h *Handler) paramsTooLarge(size int) bool {
+	return h.cfg.MaxParamsBytes > 0 && size > h.cfg.MaxParamsBytes
+}
+
+// checkParamsShape applies the configured depth and element caps to params
+func (h *Handler) checkParamsShape(params map[string]any) error {
+	return checkParamsShape(params, h.cfg.MaxParamsDepth, h.cfg.MaxParamsElements)
+}
+
+// queuedJob describes a created job for submitToAlgo
+func queuedJob(jobID string, req SubmitJobRequest) services.QueuedJob {
+	return services.QueuedJob{
+		JobID:      jobID,
+		SchemeCode: req.Scheme,
+		DataRef:    req.DataID,
+		Params:     req.Params,
+		Metadata:   req.Metadata,
+		AlgoTarget: req.algoTarget,
+		Priority:   req.Priority,
+	}
+}
+
+// submitToAlgo sends a created job to the algorithm service, through the
+// dispatch queue when one is configured. The job is marked failed if it
+// cannot be queued or submitted.
+func (h *Handler) submitToAlgo(ctx context.Context, job services.QueuedJob) error {
+	if h.dispatch == nil {
+		return h.dispatchNow(ctx, job)
 	}
+	if len(h.cfg.DispatchResourceLimits) > 0 && job.ResourceType == "" {
+		job.ResourceType = h.resourceType(ctx, job.SchemeCode)
+	}
+	err := h.dispatch.Enqueue(job)
+	if err != nil {
+		_ = h.jobs.FailJob(ctx, job.JobID, "Failed to queue job: "+err.Error())
+	}
+	return err
+}
+
+// resourceType returns a scheme's resource type from the scheme cache,
+// fetching the schemes when the cache is cold. It returns "" for a scheme
+// the algorithm service does not describe; such jobs are not subject to
+// resource limits.
+func (h *Handler) resourceType(ctx context.Context, schemeCode string) string {
+	schemes, err := h.jobs.GetCachedSchemes(ctx)
+	if err != nil {
+		if schemes, err = h.fetchSchemes(ctx); err != nil {
+			h.logger.Warn("Scheme resource type unavailable, dispatching without resource limits",
+				zap.String("scheme", schemeCode), zap.Error(err))
+			return ""
+		}
+	}
+	for _, s := range schemes {
+		if s.Code == schemeCode {
+			return s.ResourceType
+		}
+	}
+	return ""
+}
 
-	if err := h.algo.SubmitJob(c.Request.Context(), req.Scheme, req.DataID, req.Params, jobID); err != nil {
+// finishedJobs returns those of jobIDs that have reached a terminal status or
+// no longer exist, so the dispatch queue can free slots of jobs that finished
+// on another replica
+func (h *Handler) finishedJobs(ctx context.Context, jobIDs []string) []string {
+	var finished []string
+	for _, jobID := range jobIDs {
+		job, err := h.store.GetJobTyped(ctx, jobID)
+		if errors.Is(err, sql.ErrNoRows) ||
+			err == nil && (job.Status == "SUCCESS" || job.Status == "FAILED" || job.Status == "CANCELLED") {
+			finished = append(finished, jobID)
+		}
+	}
+	return finished
+}
+
+// dispatchNow submits a job to its algorithm service and starts watching its
+// progress
+func (h *Handler) dispatchNow(ctx context.Context, job services.QueuedJob) error {
+	algo, ok := h.algoFor(job.AlgoTarget)
+	if !ok {
+		err := fmt.Errorf("unknown algorithm target %q", job.AlgoTarget)
+		_ = h.jobs.FailJob(ctx, job.JobID, err.Error())
+		return err
+	}
+	// Recorded first so a cancel racing the submission reaches the same service
+	_ = h.jobs.RecordAlgoTarget(ctx, job.JobID, job.AlgoTarget)
+
+	algoTaskID, err := algo.SubmitJob(ctx, job.SchemeCode, job.DataRef, job.Params, job.Metadata, job.JobID)
+	if err != nil {
 		// Mark job as failed since submission failed
-		_ = h.jobs.FailJob(c.Request.Context(), jobID, "Failed to submit to algorithm service: "+err.Error())
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to submit job", Message: err.Error()})
-		return
+		_ = h.jobs.FailJob(ctx, job.JobID, "Failed to submit to algorithm service: "+err.Error())
+		return err
 	}
+	_ = h.jobs.RecordAlgoTaskID(ctx, job.JobID, algoTaskID)
 
-	go h.watchProgress(jobID)
-	c.JSON(http.StatusOK, gin.H{"job_id": jobID, "status": "PENDING"})
+	go h.watchProgress(h.startWatch(job.JobID, job.AlgoTarget, algoTaskID), algo, job.JobID, algoTaskID)
+	return nil
+}
+
+// algoFor returns the client of a named algorithm target, the default client
+// for ""
+func (h *Handler) algoFor(target string) (*grpcclient.AlgoClient, bool) {
+	if target == "" {
+		return h.algo, true
+	}
+	algo, ok := h.cfg.AlgoTargets[target]
+	return algo, ok
+}
+
+// knownAlgoTarget rejects a routing override naming no configured target.
+// On failure it writes the error response and returns false.
+func (h *Handler) knownAlgoTarget(c *gin.Context, target string) bool {
+	if _, ok := h.algoFor(target); !ok {
+		respondError(c, apperr.Validation("Unknown algorithm target", fmt.Sprintf("%s %q is not configured", AlgoTargetHeader, target)))
+		return false
+	}
+	return true
 }
 
 // GetJob godoc
@@ -139,15 +665,64 @@ func (h *Handler) GetJob(c *gin.Context) {
 	jobID := c.Param("id")
 	job, err := h.jobs.GetJob(c.Request.Context(), jobID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Job not found", Message: err.Error(), Code: 404})
+		respondError(c, jobLookupError(jobID, err))
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"job": job})
+	h.filterResultSummary(c, job)
+	respond(c, http.StatusOK, gin.H{"job": job})
+}
+
+// filterResultSummary cuts the result embedded in job down to the same
+// allowlist as GetJobResult
+func (h *Handler) filterResultSummary(c *gin.Context, job map[string]any) {
+	scheme, _ := job["scheme_code"].(string)
+	if summary, ok := job["result_summary"].(string); ok && summary != "" {
+		if allowed := h.resultAllowlist(c, scheme); allowed != nil {
+			job["result_summary"] = string(filterResultJSON(summary, allowed))
+		}
+	}
+}
+
+// jobLookupError reports a failed lookup of jobID: a missing job is not
+// found, any other failure an internal error
+func jobLookupError(jobID string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperr.NotFound("Job not found", "no job with id "+jobID)
+	}
+	return apperr.Internal("Failed to get job", err.Error())
+}
+
+// GetJobByAlgoTask godoc
+// @Summary      Get job by algorithm task ID
+// @Description  Returns the job the algorithm service knows by the given task ID, for debugging from the algorithm side
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        algo_task_id  path      string  true  "Algorithm service task ID"
+// @Success      200  {object}  map[string]any
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/by-algo-task/{algo_task_id} [get]
+func (h *Handler) GetJobByAlgoTask(c *gin.Context) {
+	algoTaskID := c.Param("algo_task_id")
+	job, err := h.store.GetJobByAlgoTaskID(c.Request.Context(), algoTaskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apperr.NotFound("Job not found", "no job is mapped to algo task "+algoTaskID))
+		return
+	}
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to get job", err.Error()))
+		return
+	}
+	h.filterResultSummary(c, job)
+	respond(c, http.StatusOK, gin.H{"job": job})
 }
 
 // ListJobs godoc
 // @Summary      List jobs with pagination
-// @Description  Returns a paginated list of jobs with optional filters
+// @Description  Returns a paginated list of jobs with optional filters.
+// @Description  When counting the matching jobs times out, the page is still returned with total and pages null and count_unavailable true.
+// @Description  With cursor or limit the list is paged by cursor instead: follow next_cursor, present while has_more is true; no total is returned.
 // @Tags         jobs
 // @Accept       json
 // @Produce      json
@@ -155,73 +730,332 @@ func (h *Handler) GetJob(c *gin.Context) {
 // @Param        page_size query     int     false  "Items per page"  default(20)
 // @Param        user_id   query     string  false  "Filter by user ID"
 // @Param        status    query     string  false  "Filter by status (PENDING, RUNNING, SUCCESS, FAILED)"
+// @Param        window    query     string  false  "Only jobs created within this duration (e.g. 1h, 24h, 7d)"
+// @Param        has_error query     bool    false  "Only jobs with a non-empty error_log, regardless of status"
+// @Param        scheme_code   query     string  false  "Filter by scheme code; a trailing * matches by a non-empty prefix (e.g. KBM-*)"
+// @Param        created_from  query     string  false  "Only jobs created at or after this RFC3339 time"
+// @Param        created_to    query     string  false  "Only jobs created before this RFC3339 time"
+// @Param        cursor    query     string  false  "Cursor mode: next_cursor of the previous page, empty for the first page"
+// @Param        limit     query     int     false  "Cursor mode: items per page"  default(20)
 // @Success      200  {object}  map[string]any  "Returns jobs array, total count, and pagination info"
+// @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /api/v1/jobs [get]
 func (h *Handler) ListJobs(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	userID := c.Query("user_id")
-	status := c.Query("status")
-
-	if page < 1 {
-		page = 1
+	filter, ok := jobFilterFromQuery(c)
+	if !ok {
+		return
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	_, hasCursor := c.GetQuery("cursor")
+	_, hasLimit := c.GetQuery("limit")
+	if hasCursor || hasLimit {
+		h.listJobsByCursor(c, filter)
+		return
 	}
 
-	jobs, total, err := h.store.ListJobsWithPagination(c.Request.Context(), userID, status, page, pageSize)
+	page, pageSize, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	jobs, total, err := h.listJobsBounded(c.Request.Context(), filter, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list jobs", Message: err.Error()})
+		respondError(c, apperr.Internal("Failed to list jobs", err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"jobs":      jobs,
 		"total":     total,
 		"page":      page,
 		"page_size": pageSize,
-		"pages":     (total + pageSize - 1) / pageSize,
-	})
+		"pages":     nil,
+	}
+	if total != nil {
+		resp["pages"] = (*total + pageSize - 1) / pageSize
+	} else {
+		resp["count_unavailable"] = true
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// listJobsByCursor serves ListJobs in cursor mode: pages are found by
+// seeking past the previous page's last job instead of by offset, so deep
+// pages stay cheap. No total is counted.
+func (h *Handler) listJobsByCursor(c *gin.Context, filter storage.JobFilter) {
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 100 {
+			respondError(c, apperr.Validation("Invalid limit", "limit must be between 1 and 100"))
+			return
+		}
+		limit = n
+	}
+	var cursor *storage.JobCursor
+	if raw := c.Query("cursor"); raw != "" {
+		decoded, err := storage.DecodeJobCursor(raw)
+		if err != nil {
+			respondError(c, apperr.Validation("Invalid cursor", "cursor must be a next_cursor returned by this endpoint"))
+			return
+		}
+		cursor = &decoded
+	}
+
+	ctx, cancel := withOptionalTimeout(c.Request.Context(), h.cfg.ListQueryTimeout)
+	defer cancel()
+	jobs, next, err := h.store.ListJobsAfterCursor(ctx, filter, cursor, limit)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to list jobs", err.Error()))
+		return
+	}
+
+	resp := gin.H{
+		"jobs":     jobs,
+		"limit":    limit,
+		"has_more": next != nil,
+	}
+	if next != nil {
+		resp["next_cursor"] = next.Encode()
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// listJobsBounded fetches a page of jobs and their total concurrently, each
+// under its own timeout. A count that times out yields a nil total instead
+// of failing the listing; any other error is returned.
+func (h *Handler) listJobsBounded(ctx context.Context, filter storage.JobFilter, page, pageSize int) ([]models.Job, *int, error) {
+	countCtx, cancelCount := withOptionalTimeout(ctx, h.cfg.ListCountTimeout)
+	defer cancelCount()
+	var (
+		count    int
+		countErr error
+		counted  = make(chan struct{})
+	)
+	go func() {
+		defer close(counted)
+		count, countErr = h.store.CountJobs(countCtx, filter)
+	}()
+
+	pageCtx, cancelPage := withOptionalTimeout(ctx, h.cfg.ListQueryTimeout)
+	defer cancelPage()
+	jobs, err := h.store.ListJobsPage(pageCtx, filter, page, pageSize)
+	if err != nil {
+		cancelCount()
+		<-counted
+		return nil, nil, err
+	}
+
+	<-counted
+	switch {
+	case countErr == nil:
+		return jobs, &count, nil
+	case errors.Is(countCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+		h.logger.Warn("Job count timed out, listing without a total",
+			zap.Duration("timeout", h.cfg.ListCountTimeout), zap.Error(countErr))
+		return jobs, nil, nil
+	}
+	return nil, nil, countErr
+}
+
+// withOptionalTimeout is context.WithTimeout, except that a non-positive
+// timeout only makes the context cancelable
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// CountJobs godoc
+// @Summary      Count jobs
+// @Description  Returns the number of jobs matching the same filters as the list endpoint, without fetching any rows
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        user_id   query     string  false  "Filter by user ID"
+// @Param        status    query     string  false  "Filter by status (PENDING, RUNNING, SUCCESS, FAILED)"
+// @Param        window    query     string  false  "Only jobs created within this duration (e.g. 1h, 24h, 7d)"
+// @Param        has_error query     bool    false  "Only jobs with a non-empty error_log, regardless of status"
+// @Param        scheme_code   query     string  false  "Filter by scheme code; a trailing * matches by a non-empty prefix (e.g. KBM-*)"
+// @Param        created_from  query     string  false  "Only jobs created at or after this RFC3339 time"
+// @Param        created_to    query     string  false  "Only jobs created before this RFC3339 time"
+// @Success      200  {object}  map[string]int  "Returns count"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/count [get]
+func (h *Handler) CountJobs(c *gin.Context) {
+	filter, ok := jobFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.store.CountJobs(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to count jobs", err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"count": count})
+}
+
+// jobFilterFromQuery builds the job filter shared by list, count and export.
+// On invalid input it writes a 400 and returns false.
+func jobFilterFromQuery(c *gin.Context) (storage.JobFilter, bool) {
+	filter := storage.JobFilter{UserID: c.Query("user_id"), Status: c.Query("status")}
+	if raw := c.Query("has_error"); raw != "" {
+		hasError, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, apperr.Validation("Invalid has_error", "has_error must be true or false"))
+			return filter, false
+		}
+		filter.HasError = hasError
+	}
+	if !applyWindow(c, &filter) {
+		return filter, false
+	}
+	if code := c.Query("scheme_code"); code != "" {
+		if prefix, ok := strings.CutSuffix(code, "*"); ok {
+			// A bare * would match every scheme; omit scheme_code for that
+			if prefix == "" {
+				respondError(c, apperr.Validation("Invalid scheme_code", "scheme_code prefix must not be empty"))
+				return filter, false
+			}
+			filter.SchemePrefix = prefix
+		} else {
+			filter.SchemeCode = code
+		}
+	}
+	if !applyCreatedRange(c, &filter) {
+		return filter, false
+	}
+	return filter, true
+}
+
+// applyCreatedRange reads the optional ?created_from= and ?created_to=
+// RFC3339 query params into the filter. It writes a 400 response and
+// returns false when a value is invalid, the range is empty or
+// created_from is combined with window.
+func applyCreatedRange(c *gin.Context, filter *storage.JobFilter) bool {
+	for _, bound := range []struct {
+		param string
+		dst   *time.Time
+	}{{"created_from", &filter.CreatedFrom}, {"created_to", &filter.CreatedTo}} {
+		raw := c.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+		if bound.param == "created_from" && c.Query("window") != "" {
+			respondError(c, apperr.Validation("Invalid created_from", "created_from cannot be combined with window"))
+			return false
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondError(c, apperr.Validation(
+				"Invalid "+bound.param,
+				fmt.Sprintf("%s must be an RFC3339 time such as 2024-01-02T15:04:05Z, got %q", bound.param, raw),
+			))
+			return false
+		}
+		*bound.dst = t
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		respondError(c, apperr.Validation("Invalid created_to", "created_to must be after created_from"))
+		return false
+	}
+	return true
 }
 
 // GetJobResult godoc
 // @Summary      Get job result
-// @Description  Returns the result data for a completed job
+// @Description  Returns the result data for a completed job. With path, only that part of the result is returned.
+// @Description  Non-admin callers only see the result paths allowlisted for the job's scheme, if any.
 // @Tags         jobs
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Job ID"
+// @Param        id    path      string  true   "Job ID"
+// @Param        path  query     string  false  "Dot-separated path into the result, e.g. summary.metrics or rows.0"
 // @Success      200  {object}  map[string]any
+// @Failure      400  {object}  ErrorResponse
 // @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /api/v1/jobs/{id}/result [get]
 func (h *Handler) GetJobResult(c *gin.Context) {
 	jobID := c.Param("id")
+
+	var segments []string
+	path, projected := c.GetQuery("path")
+	if projected {
+		var err error
+		if segments, err = parseResultPath(path); err != nil {
+			respondError(c, apperr.Validation("Invalid path", err.Error()))
+			return
+		}
+	}
+
 	job, err := h.store.GetJobTyped(c.Request.Context(), jobID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Job not found", Message: err.Error(), Code: 404})
+		respondError(c, jobLookupError(jobID, err))
 		return
 	}
 
 	if job.Status != "SUCCESS" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Job not completed", Message: "Job status is " + job.Status, Code: 400})
+		respondError(c, apperr.Conflict("Job not completed", "Job status is "+job.Status))
 		return
 	}
 
+	resultJSON, err := h.store.GetResult(c.Request.Context(), jobID)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to load result", err.Error()))
+		return
+	}
 	var result any
-	if job.ResultJSON != "" {
-		_ = json.Unmarshal([]byte(job.ResultJSON), &result)
+	if resultJSON != "" {
+		_ = json.Unmarshal([]byte(resultJSON), &result)
+	}
+	if allowed := h.resultAllowlist(c, job.SchemeCode); allowed != nil {
+		result = filterResultPaths(result, allowed)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	if projected {
+		value, ok := lookupResultPath(result, segments)
+		if !ok {
+			respondError(c, apperr.NotFound("Path not found", "result has no value at "+path))
+			return
+		}
+		respond(c, http.StatusOK, gin.H{
+			"job_id": jobID,
+			"status": job.Status,
+			"path":   path,
+			"result": value,
+		})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
 		"job_id": jobID,
 		"status": job.Status,
 		"result": result,
 	})
 }
 
+// resultAllowlist is schemeAllowlist for the caller of c
+func (h *Handler) resultAllowlist(c *gin.Context, scheme string) []string {
+	return h.schemeAllowlist(scheme, middleware.IsAdmin(c))
+}
+
+// schemeAllowlist returns the result paths a caller may see for a job of
+// scheme, or nil when it may see the whole result
+func (h *Handler) schemeAllowlist(scheme string, admin bool) []string {
+	if len(h.cfg.ResultPathAllowlist) == 0 || admin {
+		return nil
+	}
+	if paths, ok := h.cfg.ResultPathAllowlist[scheme]; ok {
+		return paths
+	}
+	return h.cfg.ResultPathAllowlist["*"]
+}
+
 // CancelJob godoc
 // @Summary      Cancel a running job
 // @Description  Attempts to cancel a pending or running job. Use force=true for immediate termination.
@@ -231,8 +1065,14 @@ func (h *Handler) GetJobResult(c *gin.Context) {
 // @Param        id     path      string  true   "Job ID"
 // @Param        force  query     bool    false  "Force kill (default: false)"
 // @Success      200  {object}  SuccessResponse
+// @Security     BearerAuth
 // @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
 // @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
 // @Router       /api/v1/jobs/{id}/cancel [post]
 func (h *Handler) CancelJob(c *gin.Context) {
 	jobID := c.Param("id")
@@ -241,28 +1081,31 @@ func (h *Handler) CancelJob(c *gin.Context) {
 
 	job, err := h.store.GetJobTyped(c.Request.Context(), jobID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Job not found", Message: err.Error(), Code: 404})
+		respondError(c, jobLookupError(jobID, err))
 		return
 	}
 
 	if job.Status == "SUCCESS" || job.Status == "FAILED" || job.Status == "CANCELLED" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Cannot cancel completed job", Code: 400})
+		respondError(c, apperr.Conflict("Cannot cancel completed job", "Job status is "+job.Status))
 		return
 	}
 
 	// Request algorithm service to cancel
-	resp, err := h.algo.CancelTask(c.Request.Context(), jobID, force)
+	resp, err := h.cancelJob(c.Request.Context(), jobID, h.jobs.AlgoTaskID(c.Request.Context(), jobID), force, "Cancelled by user")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to cancel job", Message: err.Error()})
+		respondError(c, apperr.Upstream("Failed to cancel job", err.Error()))
 		return
 	}
-
-	// If cancel accepted and already cancelled, mark in DB
-	if resp.GetStatus() == "CANCELLED" || resp.GetStatus() == "KILLED" {
-		_ = h.jobs.CancelJob(c.Request.Context(), jobID, "Cancelled by user")
+	// The job finished between the status check and the cancel request
+	if finishedBeforeCancel(resp) {
+		respondError(c, apperr.Conflict(
+			"Job already completed",
+			fmt.Sprintf("job finished with status %s before it could be cancelled", resp.GetStatus()),
+		))
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	respond(c, http.StatusOK, gin.H{
 		"success":  resp.GetAccepted(),
 		"message":  resp.GetMessage(),
 		"status":   resp.GetStatus(),
@@ -271,47 +1114,125 @@ func (h *Handler) CancelJob(c *gin.Context) {
 	})
 }
 
-// HealthCheck godoc
-// @Summary      Health check
-// @Description  Returns the health status of the backend service and its dependencies
-// @Tags         system
+// DeleteJob godoc
+// @Summary      Delete a job
+// @Description  Soft-deletes a finished (SUCCESS, FAILED or CANCELLED) job: it disappears from job queries and statistics
+// @Description  and is purged together with its result once the deleted job retention has passed.
+// @Tags         jobs
 // @Accept       json
 // @Produce      json
+// @Param        id   path      string  true  "Job ID"
 // @Success      200  {object}  map[string]any
-// @Router       /api/v1/health [get]
-func (h *Handler) HealthCheck(c *gin.Context) {
-	ctx := c.Request.Context()
-
-	health := gin.H{
-		"status": "healthy",
-		"checks": gin.H{},
+// @Security     BearerAuth
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/{id} [delete]
+func (h *Handler) DeleteJob(c *gin.Context) {
+	jobID := c.Param("id")
+	job, err := h.store.GetJobTyped(c.Request.Context(), jobID)
+	if err != nil {
+		respondError(c, jobLookupError(jobID, err))
+		return
+	}
+	if job.Status != "SUCCESS" && job.Status != "FAILED" && job.Status != "CANCELLED" {
+		respondError(c, apperr.Validation(
+			"Cannot delete unfinished job",
+			fmt.Sprintf("job is %s; only SUCCESS, FAILED or CANCELLED jobs can be deleted", job.Status),
+		))
+		return
 	}
 
-	// Check MySQL
-	if err := h.store.Ping(ctx); err != nil {
-		health["checks"].(gin.H)["mysql"] = gin.H{"status": "unhealthy", "error": err.Error()}
-		health["status"] = "degraded"
-	} else {
-		health["checks"].(gin.H)["mysql"] = gin.H{"status": "healthy"}
+	deletedAt, err := h.store.SoftDeleteJob(c.Request.Context(), jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apperr.NotFound("Job not found", "job was deleted concurrently"))
+		return
+	}
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to delete job", err.Error()))
+		return
 	}
+	respond(c, http.StatusOK, gin.H{"job_id": jobID, "deleted_at": deletedAt})
+}
 
-	// Check Redis
-	if err := h.cache.Ping(ctx); err != nil {
-		health["checks"].(gin.H)["redis"] = gin.H{"status": "unhealthy", "error": err.Error()}
-		health["status"] = "degraded"
-	} else {
-		health["checks"].(gin.H)["redis"] = gin.H{"status": "healthy"}
+// cancelJob asks the algorithm service to cancel a job and, once it reports
+// the job cancelled or killed, records the cancellation with message
+func (h *Handler) cancelJob(ctx context.Context, jobID, algoTaskID string, force bool, message string) (*pb.CancelResponse, error) {
+	// A job still waiting in the dispatch queue never reached the algorithm service
+	if h.dispatch != nil && h.dispatch.Remove(jobID) {
+		_ = h.jobs.CancelJob(ctx, jobID, message)
+		h.stopWatch(jobID)
+		return &pb.CancelResponse{Accepted: true, Status: "CANCELLED", Message: "removed from dispatch queue"}, nil
 	}
+	algo := h.algo
+	if len(h.cfg.AlgoTargets) > 0 {
+		target := h.jobs.AlgoTarget(ctx, jobID)
+		var ok bool
+		if algo, ok = h.algoFor(target); !ok {
+			return nil, fmt.Errorf("algorithm target %q is no longer configured", target)
+		}
+	}
+	resp, err := algo.CancelTask(ctx, algoTaskID, force)
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetStatus() == "CANCELLED" || resp.GetStatus() == "KILLED" {
+		_ = h.jobs.CancelJob(ctx, jobID, message)
+		h.stopWatch(jobID)
+	}
+	return resp, nil
+}
 
-	// Check Algorithm Service
-	if h.algo.IsHealthy() {
-		health["checks"].(gin.H)["algorithm_service"] = gin.H{"status": "healthy"}
-	} else {
-		health["checks"].(gin.H)["algorithm_service"] = gin.H{"status": "unhealthy"}
-		health["status"] = "degraded"
+// finishedBeforeCancel reports whether the algorithm service refused a cancel
+// because the task had already succeeded or failed; the progress watcher
+// records that outcome, so the job must not be marked cancelled
+func finishedBeforeCancel(resp *pb.CancelResponse) bool {
+	return !resp.GetAccepted() && (resp.GetStatus() == "SUCCESS" || resp.GetStatus() == "FAILED")
+}
+
+// HealthCheck godoc
+// @Summary      Health check
+// @Description  Returns the health status of the backend service and its components: MySQL, Redis,
+// @Description  the algorithm service, the background scheduler and the gRPC result listener. Checks
+// @Description  run concurrently, each with its own timeout, and report their status and latency_ms.
+// @Description  The service is unhealthy (503) when MySQL or the result listener is down, and
+// @Description  degraded (200) when any other component is not healthy.
+// @Tags         system
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  models.HealthReport
+// @Failure      503  {object}  models.HealthReport
+// @Router       /api/v1/system/health [get]
+// @Router       /health [get]
+func (h *Handler) HealthCheck(c *gin.Context) {
+	report := h.healthReport(c.Request.Context())
+	code := http.StatusOK
+	if report.Status == models.HealthUnhealthy {
+		code = http.StatusServiceUnavailable
 	}
+	respond(c, code, report)
+}
 
-	c.JSON(http.StatusOK, health)
+// algoHealth reports the algorithm service, including the circuit breaker so
+// operators can see when calls are being short-circuited and when it will retry
+func (h *Handler) algoHealth(context.Context) models.HealthCheck {
+	breaker := h.algo.BreakerStatus()
+	algoCheck := models.HealthCheck{Status: models.HealthHealthy, Details: map[string]any{
+		"circuit_state":        breaker.State,
+		"consecutive_failures": breaker.ConsecutiveFailures,
+	}}
+	if !breaker.NextProbeAt.IsZero() {
+		algoCheck.Details["next_probe_at"] = breaker.NextProbeAt.UTC().Format(time.RFC3339)
+	}
+	switch {
+	case breaker.State == grpcclient.BreakerOpen || !h.algo.IsHealthy():
+		algoCheck.Status = models.HealthUnhealthy
+	case breaker.State == grpcclient.BreakerHalfOpen:
+		algoCheck.Status = models.HealthDegraded
+	}
+	return algoCheck
 }
 
 // GetStats godoc
@@ -320,44 +1241,208 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 // @Tags         system
 // @Accept       json
 // @Produce      json
+// @Param        window  query     string  false  "Only jobs created within this duration (e.g. 1h, 24h, 7d)"
 // @Success      200  {object}  map[string]any
+// @Security     BearerAuth
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
-// @Router       /api/v1/stats [get]
+// @Router       /api/v1/system/stats [get]
 func (h *Handler) GetStats(c *gin.Context) {
-	stats, err := h.store.GetStats(c.Request.Context())
+	var filter storage.JobFilter
+	if !applyWindow(c, &filter) {
+		return
+	}
+
+	// Unfiltered stats share the cached aggregates with /metrics
+	var stats map[string]any
+	var err error
+	if filter == (storage.JobFilter{}) {
+		stats, err = h.stats.Stats(c.Request.Context())
+	} else {
+		stats, err = h.store.GetStats(c.Request.Context(), filter)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get stats", Message: err.Error()})
+		respondError(c, apperr.Internal("Failed to get stats", err.Error()))
 		return
 	}
-	c.JSON(http.StatusOK, stats)
+	if h.dispatch != nil {
+		// The cached map is shared with /metrics
+		stats = maps.Clone(stats)
+		byScheme, byResource := h.dispatch.InFlightBySchemeAndResource()
+		stats["dispatch_queue"] = gin.H{
+			"depth":                 h.dispatch.Depth(),
+			"in_flight":             h.dispatch.InFlight(),
+			"max_in_flight":         h.dispatch.MaxInFlight(),
+			"in_flight_by_scheme":   byScheme,
+			"in_flight_by_resource": byResource,
+		}
+	}
+	respond(c, http.StatusOK, stats)
 }
 
-func (h *Handler) watchProgress(jobID string) {
-	ctx := context.Background()
+// maxHistogramRows caps the finished jobs read to build a duration histogram
+const maxHistogramRows = 100000
 
-	// Retry connection with backoff
-	for retries := 0; retries < 3; retries++ {
-		stream, err := h.algo.WatchProgress(ctx, jobID)
+// GetDurationHistogram godoc
+// @Summary      Get the job duration distribution
+// @Description  Buckets the durations of successful jobs and reports their p50/p95/p99, optionally for one scheme.
+// @Description  Only the most recently finished jobs are included when there are very many (truncated is then true).
+// @Tags         system
+// @Accept       json
+// @Produce      json
+// @Param        scheme  query     string  false  "Only jobs of this scheme code"
+// @Param        window  query     string  false  "Only jobs created within this duration (e.g. 1h, 24h, 7d)"
+// @Success      200  {object}  storage.DurationHistogram
+// @Security     BearerAuth
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/system/duration-histogram [get]
+func (h *Handler) GetDurationHistogram(c *gin.Context) {
+	var filter storage.JobFilter
+	if !applyWindow(c, &filter) {
+		return
+	}
+	hist, err := h.store.GetDurationHistogram(c.Request.Context(), filter, c.Query("scheme"), maxHistogramRows)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to get duration histogram", err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, hist)
+}
+
+// requestUser identifies the caller for records kept in its name: the
+// authenticated user when auth middleware has set one, otherwise
+// "anonymous". The X-User-ID header is chosen by the client and not trusted.
+func requestUser(c *gin.Context) string {
+	if userID := authenticatedUser(c); userID != "" {
+		return userID
+	}
+	return "anonymous"
+}
+
+// maxPage bounds page-based listing; deeper pages make MySQL scan huge OFFSETs
+const maxPage = 10000
+
+// parsePagination reads ?page= and ?page_size=, clamping small or malformed
+// values to defaults. Pages beyond maxPage get a 400 and ok=false.
+func parsePagination(c *gin.Context) (page, pageSize int, ok bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if errors.Is(err, strconv.ErrRange) || page > maxPage {
+		respondError(c, apperr.Validation("Invalid page", fmt.Sprintf("page must be between 1 and %d", maxPage)))
+		return 0, 0, false
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize, true
+}
+
+// applyWindow reads the optional ?window= query param into the filter.
+// It writes a 400 response and returns false when the value is invalid.
+func applyWindow(c *gin.Context, filter *storage.JobFilter) bool {
+	raw := c.Query("window")
+	if raw == "" {
+		return true
+	}
+	window, err := parseWindow(raw)
+	if err != nil {
+		respondError(c, apperr.Validation("Invalid window", err.Error()))
+		return false
+	}
+	filter.CreatedFrom = time.Now().Add(-window)
+	return true
+}
+
+// parseWindow parses a Go duration, additionally accepting whole days such as "7d"
+func parseWindow(raw string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
 		if err != nil {
-			continue
+			return 0, fmt.Errorf("invalid duration %q", raw)
 		}
-
-		for {
-			msg, err := stream.Recv()
-			if err != nil {
-				break
-			}
-			_ = h.jobs.UpdateProgress(ctx, models.ProgressMsg{
-				TaskID:     msg.TaskId,
-				Percentage: msg.Percentage,
-				Message:    msg.Message,
-				Timestamp:  msg.Timestamp,
-			})
-
-			// Check if job is finished
-			if msg.Percentage >= 100 {
-				return
-			}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", raw)
 		}
+		window = d
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be positive, got %q", raw)
+	}
+	return window, nil
+}
+
+// progressWatch is a running progress watcher and what is needed to resume
+// it elsewhere
+type progressWatch struct {
+	cancel     context.CancelFunc
+	algoTarget string
+	algoTaskID string
+}
+
+// startWatch returns the context for a job's progress watcher; stopWatch cancels it
+func (h *Handler) startWatch(jobID, algoTarget, algoTaskID string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.watchMu.Lock()
+	if prev, ok := h.watchers[jobID]; ok {
+		prev.cancel()
+	}
+	h.watchers[jobID] = &progressWatch{cancel: cancel, algoTarget: algoTarget, algoTaskID: algoTaskID}
+	h.watchMu.Unlock()
+	return ctx
+}
+
+// stopWatch cancels the progress watcher of a job, if any
+func (h *Handler) stopWatch(jobID string) {
+	h.watchMu.Lock()
+	w, ok := h.watchers[jobID]
+	delete(h.watchers, jobID)
+	h.watchMu.Unlock()
+	if ok {
+		w.cancel()
+	}
+}
+
+// releaseWatch removes the entry of a watcher that ended by itself. A
+// cancelled ctx means the entry was already removed or replaced.
+func (h *Handler) releaseWatch(ctx context.Context, jobID string) {
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+	if w, ok := h.watchers[jobID]; ok && ctx.Err() == nil {
+		w.cancel()
+		delete(h.watchers, jobID)
+	}
+}
+
+// stopWatchOnResult is a job hook stopping the watcher once the result callback arrives
+func (h *Handler) stopWatchOnResult(_ context.Context, jobID string, _ bool, _ string) {
+	h.stopWatch(jobID)
+}
+
+// watchProgress follows the algorithm service's progress stream for a job
+// until it reaches 100% or ctx is cancelled. algoTaskID is the ID the
+// algorithm service knows the job by; updates are recorded against jobID.
+// The client reconnects on transient stream errors, resuming after the last
+// update seen; cancellation stops immediately.
+func (h *Handler) watchProgress(ctx context.Context, algo *grpcclient.AlgoClient, jobID, algoTaskID string) {
+	defer h.releaseWatch(ctx, jobID)
+
+	// If the watch gives up, the result callback still finalizes the job
+	updates, _ := algo.WatchProgressResilient(ctx, algoTaskID, 0)
+	for msg := range updates {
+		msg.TaskID = jobID
+		_ = h.jobs.UpdateProgress(ctx, msg)
 	}
 }
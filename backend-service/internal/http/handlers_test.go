@@ -2,16 +2,39 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/electric-power/backend-service/internal/grpcclient"
+	"github.com/electric-power/backend-service/internal/grpcserver"
 	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/services"
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+	pb "github.com/electric-power/backend-service/proto"
 )
 
 // MockJobService implements a mock for JobService
@@ -45,6 +68,207 @@ func setupTestRouter() *gin.Engine {
 	return gin.New()
 }
 
+// testEnv bundles a real Handler wired to in-memory dependencies
+type testEnv struct {
+	handler *Handler
+	db      sqlmock.Sqlmock
+	redis   *miniredis.Miniredis
+	store   *storage.MySQLStore
+	cache   *storage.RedisCache
+	hub     *ws.Hub
+}
+
+// newTestEnv builds a Handler backed by sqlmock and miniredis (no algorithm client)
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	return newTestEnvWithConfig(t, DefaultHandlerConfig())
+}
+
+// newTestEnvWithConfig is newTestEnv with a custom handler configuration
+func newTestEnvWithConfig(t *testing.T, cfg HandlerConfig) *testEnv {
+	t.Helper()
+	return newTestEnvOnRedis(t, cfg, miniredis.RunT(t))
+}
+
+// newTestEnvOnRedis is newTestEnvWithConfig using mr, so several instances
+// can share one Redis
+func newTestEnvOnRedis(t *testing.T, cfg HandlerConfig, mr *miniredis.Miniredis) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, dbMock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	hub := ws.NewHub()
+	t.Cleanup(hub.Close)
+
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+	jobs := services.NewJobService(store, cache, hub, "sys:algo:schemes", "job:progress:")
+
+	return &testEnv{
+		handler: NewHandlerWithConfig(jobs, nil, store, cache, cfg),
+		db:      dbMock,
+		redis:   mr,
+		store:   store,
+		cache:   cache,
+		hub:     hub,
+	}
+}
+
+// withAlgo serves srv on a loopback gRPC listener and points the handler's algorithm client at it
+func (e *testEnv) withAlgo(t *testing.T, srv pb.AlgoControlServiceServer) *grpcclient.AlgoClient {
+	t.Helper()
+	return e.withAlgoConfig(t, srv, nil)
+}
+
+// withAlgoConfig is withAlgo with a hook to adjust the client configuration
+func (e *testEnv) withAlgoConfig(t *testing.T, srv pb.AlgoControlServiceServer, configure func(*grpcclient.AlgoClientConfig)) *grpcclient.AlgoClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	pb.RegisterAlgoControlServiceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	cfg := grpcclient.DefaultAlgoClientConfig(lis.Addr().String())
+	cfg.MaxRetries = 0
+	cfg.RequestTimeout = 5 * time.Second
+	if configure != nil {
+		configure(&cfg)
+	}
+	client, err := grpcclient.NewAlgoClientWithConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	// The client counts as unhealthy until its first connection is up
+	require.Eventually(t, client.IsHealthy, 5*time.Second, 5*time.Millisecond)
+
+	e.handler.algo = client
+	return client
+}
+
+// fakeAlgo is a minimal algorithm service; unset hooks fall back to Unimplemented
+type fakeAlgo struct {
+	pb.UnimplementedAlgoControlServiceServer
+	submit  func(ctx context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error)
+	watch   func(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error
+	schemes func(ctx context.Context) (*pb.SchemeList, error)
+	cancel  func(ctx context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error)
+}
+
+func (f *fakeAlgo) CancelTask(ctx context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+	if f.cancel == nil {
+		return f.UnimplementedAlgoControlServiceServer.CancelTask(ctx, req)
+	}
+	return f.cancel(ctx, req)
+}
+
+func (f *fakeAlgo) GetAvailableSchemes(ctx context.Context, req *pb.Empty) (*pb.SchemeList, error) {
+	if f.schemes == nil {
+		return f.UnimplementedAlgoControlServiceServer.GetAvailableSchemes(ctx, req)
+	}
+	return f.schemes(ctx)
+}
+
+func (f *fakeAlgo) WatchTaskProgress(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+	if f.watch == nil {
+		return f.UnimplementedAlgoControlServiceServer.WatchTaskProgress(req, stream)
+	}
+	return f.watch(req, stream)
+}
+
+func (f *fakeAlgo) SubmitTask(ctx context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+	if f.submit == nil {
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}
+	return f.submit(ctx, req)
+}
+
+// seedSchemes puts schemes into the scheme cache so handlers don't need the algorithm service
+func (e *testEnv) seedSchemes(t *testing.T, schemes ...models.Scheme) {
+	t.Helper()
+	require.NoError(t, e.cache.SetJSON(context.Background(), "sys:algo:schemes", schemes, time.Minute))
+}
+
+// jobRow describes the row GetJobTyped returns in tests
+type jobRow struct {
+	JobID      string
+	SchemeCode string
+	UserID     string
+	Status     string
+	Progress   int
+	Params     string
+	Result     string
+	ErrorLog   string
+}
+
+// expectGetJob registers a GetJobTyped lookup returning the given row
+func (e *testEnv) expectGetJob(row jobRow) {
+	if row.SchemeCode == "" {
+		row.SchemeCode = "KBM-WF01"
+	}
+	if row.Params == "" {
+		row.Params = "{}"
+	}
+	now := time.Now()
+	e.db.ExpectQuery(`SELECT job_id, scheme_code, user_id, status, progress, data_ref, params,.+FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(row.JobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "scheme_code", "user_id", "status", "progress", "data_ref",
+			"params", "result_summary", "error_log", "created_at", "finished_at"}).
+			AddRow(row.JobID, row.SchemeCode, row.UserID, row.Status, row.Progress, "data-1",
+				row.Params, row.Result, row.ErrorLog, now.Add(-time.Minute), now))
+}
+
+// expectGetResult registers a GetResult lookup returning result from t_job_results
+func (e *testEnv) expectGetResult(jobID, result string) {
+	e.db.ExpectQuery(`SELECT result_json FROM t_job_results WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"result_json"}).AddRow(result))
+}
+
+// expectFinishJob registers the result insert and status update of FinishJob
+func (e *testEnv) expectFinishJob(jobID, result string) {
+	e.db.ExpectExec(`INSERT INTO t_job_results`).
+		WithArgs(jobID, result, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	e.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'SUCCESS'`).
+		WithArgs(len(result), sqlmock.AnyArg(), sqlmock.AnyArg(), jobID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectJobNotFound registers a GetJobTyped lookup that finds nothing
+func (e *testEnv) expectJobNotFound(jobID string) {
+	e.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+}
+
+func (e *testEnv) do(r http.Handler, method, path string, body []byte) *httptest.ResponseRecorder {
+	if body == nil {
+		return serve(r, httptest.NewRequest(method, path, nil))
+	}
+	return serve(r, newJSONRequest(method, path, string(body)))
+}
+
+// newJSONRequest builds a request carrying a JSON body
+func newJSONRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// serve runs a request through a handler and returns the recorded response
+func serve(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
 // TestModuleJobRequest tests the ModuleJobRequest struct
 func TestModuleJobRequest(t *testing.T) {
 	t.Run("ValidRequest", func(t *testing.T) {
@@ -73,7 +297,7 @@ func TestErrorResponse(t *testing.T) {
 	resp := ErrorResponse{
 		Error:   "Test error",
 		Message: "Detailed message",
-		Code:    400,
+		Code:    "VALIDATION_ERROR",
 	}
 
 	data, err := json.Marshal(resp)
@@ -196,3 +420,1128 @@ func TestDynamicWorkflowRouting(t *testing.T) {
 		})
 	}
 }
+
+// TestListJobsWindowFilter tests the relative ?window= filter on the list endpoint
+func TestListJobsWindowFilter(t *testing.T) {
+	for _, window := range []string{"1h", "24h"} {
+		t.Run(window, func(t *testing.T) {
+			env := newTestEnv(t)
+			r := setupTestRouter()
+			r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+			// The count and page queries run concurrently
+			env.db.MatchExpectationsInOrder(false)
+			env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND created_at >= \?`).
+				WithArgs(sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND created_at >= \? ORDER BY`).
+				WithArgs(sqlmock.AnyArg(), 20, 0).
+				WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+
+			w := env.do(r, "GET", "/api/v1/jobs?window="+window, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NoError(t, env.db.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		env := newTestEnv(t)
+		r := setupTestRouter()
+		r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+		w := env.do(r, "GET", "/api/v1/jobs?window=yesterday", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Invalid window", resp.Error)
+		assert.NoError(t, env.db.ExpectationsWereMet())
+	})
+}
+
+// TestListJobsSlowCountReturnsRows tests that a count exceeding its timeout
+// TestListJobsCursorMode tests that limit and cursor page by cursor without counting
+func TestListJobsCursorMode(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+	t0 := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL ORDER BY created_at DESC, job_id DESC LIMIT \?`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "created_at"}).
+			AddRow("job-3", t0.Add(2*time.Minute)).
+			AddRow("job-2", t0.Add(time.Minute)).
+			AddRow("job-1", t0))
+	w := env.do(r, "GET", "/api/v1/jobs?limit=2", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp["jobs"], 2)
+	assert.Equal(t, true, resp["has_more"])
+	assert.NotContains(t, resp, "total")
+	next, _ := resp["next_cursor"].(string)
+	require.NotEmpty(t, next)
+
+	env.db.ExpectQuery(`AND \(created_at, job_id\) < \(\?, \?\) ORDER BY created_at DESC, job_id DESC LIMIT \?`).
+		WithArgs(sqlmock.AnyArg(), "job-2", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "created_at"}).AddRow("job-1", t0))
+	w = env.do(r, "GET", "/api/v1/jobs?limit=2&cursor="+next, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = map[string]any{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp["jobs"], 1)
+	assert.Equal(t, false, resp["has_more"])
+	assert.NotContains(t, resp, "next_cursor")
+
+	for _, query := range []string{"cursor=garbage", "limit=0", "limit=101", "limit=ten"} {
+		w = env.do(r, "GET", "/api/v1/jobs?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// leaves the page of rows intact, with the total marked unavailable
+func TestListJobsSlowCountReturnsRows(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ListCountTimeout = 50 * time.Millisecond
+	env := newTestEnvWithConfig(t, cfg)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+	env.db.MatchExpectationsInOrder(false)
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs`).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1000000))
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT \? OFFSET \?`).
+		WithArgs(20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow("job-1", "RUNNING").AddRow("job-2", "SUCCESS"))
+
+	start := time.Now()
+	w := env.do(r, "GET", "/api/v1/jobs", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the slow count held up the response")
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp["jobs"], 2)
+	assert.Contains(t, resp, "total")
+	assert.Nil(t, resp["total"])
+	assert.Nil(t, resp["pages"])
+	assert.Equal(t, true, resp["count_unavailable"])
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListJobsCountErrorFails tests that count failures other than a timeout still fail the request
+func TestListJobsCountErrorFails(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+	env.db.MatchExpectationsInOrder(false)
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs`).WillReturnError(errors.New("table is corrupt"))
+	env.db.ExpectQuery(`LIMIT \? OFFSET \?`).WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+
+	w := env.do(r, "GET", "/api/v1/jobs", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "count_unavailable")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListJobsHasErrorFilter tests that ?has_error=true only selects jobs with error content
+func TestListJobsHasErrorFilter(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+	env.db.MatchExpectationsInOrder(false)
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND status = \? AND error_log IS NOT NULL AND error_log != ''`).
+		WithArgs("SUCCESS").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND status = \? AND error_log IS NOT NULL AND error_log != '' ORDER BY`).
+		WithArgs("SUCCESS", 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status", "error_log"}).AddRow("job-1", "SUCCESS", "warning: fallback model used"))
+
+	w := env.do(r, "GET", "/api/v1/jobs?status=SUCCESS&has_error=true", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "warning: fallback model used")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+
+	w = env.do(r, "GET", "/api/v1/jobs?has_error=maybe", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListJobsSchemeAndDateFilters tests the scheme_code and created_from/
+// created_to filters on the list endpoint
+func TestListJobsSchemeAndDateFilters(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	env.db.MatchExpectationsInOrder(false)
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND scheme_code = \? AND created_at >= \? AND created_at < \?`).
+		WithArgs("KBM-WF01", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND scheme_code = \? AND created_at >= \? AND created_at < \? ORDER BY`).
+		WithArgs("KBM-WF01", from, to, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "scheme_code"}).AddRow("job-1", "KBM-WF01"))
+
+	w := env.do(r, "GET", "/api/v1/jobs?scheme_code=KBM-WF01&created_from=2024-03-01T00:00:00Z&created_to=2024-03-08T00:00:00Z", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"total":1`)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND scheme_code LIKE \? ESCAPE '!'`).
+		WithArgs("KBM-%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND scheme_code LIKE \? ESCAPE '!' ORDER BY`).
+		WithArgs("KBM-%", 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	w = env.do(r, "GET", "/api/v1/jobs?scheme_code=KBM-*", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+
+	for query, wantError := range map[string]string{
+		"created_from=2024-03-01": "Invalid created_from",
+		"created_to=yesterday":    "Invalid created_to",
+		"created_from=2024-03-08T00:00:00Z&created_to=2024-03-01T00:00:00Z": "Invalid created_to",
+		"created_from=2024-03-01T00:00:00Z&window=1h":                       "Invalid created_from",
+		"scheme_code=*": "Invalid scheme_code",
+	} {
+		w := env.do(r, "GET", "/api/v1/jobs?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, wantError, resp.Error, query)
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetStatsWindowFilter tests the relative ?window= filter on the stats endpoint
+// TestCountJobs tests the count endpoint across filter combinations
+func TestCountJobs(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		sql   string
+		args  []driver.Value
+	}{
+		{"NoFilter", "", `SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL$`, nil},
+		{"User", "?user_id=u1", `WHERE deleted_at IS NULL AND user_id = \?$`, []driver.Value{"u1"}},
+		{"UserAndStatus", "?user_id=u1&status=FAILED", `WHERE deleted_at IS NULL AND user_id = \? AND status = \?$`, []driver.Value{"u1", "FAILED"}},
+		{"StatusAndWindow", "?status=RUNNING&window=1h", `WHERE deleted_at IS NULL AND status = \? AND created_at >= \?$`, []driver.Value{"RUNNING", sqlmock.AnyArg()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			r := setupTestRouter()
+			r.GET("/api/v1/jobs/count", env.handler.CountJobs)
+
+			q := env.db.ExpectQuery(tt.sql)
+			if tt.args != nil {
+				q = q.WithArgs(tt.args...)
+			}
+			q.WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+			w := env.do(r, "GET", "/api/v1/jobs/count"+tt.query, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.JSONEq(t, `{"count":7}`, w.Body.String())
+			assert.NoError(t, env.db.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("InvalidWindow", func(t *testing.T) {
+		env := newTestEnv(t)
+		r := setupTestRouter()
+		r.GET("/api/v1/jobs/count", env.handler.CountJobs)
+
+		w := env.do(r, "GET", "/api/v1/jobs/count?window=soon", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, env.db.ExpectationsWereMet())
+	})
+}
+
+func TestGetStatsWindowFilter(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/system/stats", env.handler.GetStats)
+
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND created_at >= \? GROUP BY status`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow("SUCCESS", 2))
+	env.db.ExpectQuery(`SELECT AVG`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+
+	w := env.do(r, "GET", "/api/v1/system/stats?window=1h", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+
+	w = env.do(r, "GET", "/api/v1/system/stats?window=-1h", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestParseWindow tests relative window parsing
+func TestParseWindow(t *testing.T) {
+	d, err := parseWindow("7d")
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, d)
+
+	d, err = parseWindow("90m")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	for _, bad := range []string{"", "abc", "0s", "-2h", "xd"} {
+		_, err := parseWindow(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestSchemeFilterHidesAndRejects tests the scheme allow/deny lists on listing and submission
+func TestSchemeFilterHidesAndRejects(t *testing.T) {
+	filter, err := services.NewSchemeFilter([]string{"KBM-*", "SCM-*"}, []string{"KBM-WF03"})
+	require.NoError(t, err)
+
+	env := newTestEnvWithConfig(t, HandlerConfig{SchemeFilter: filter})
+	env.seedSchemes(t,
+		models.Scheme{Code: "KBM-WF01"},
+		models.Scheme{Code: "KBM-WF03"},
+		models.Scheme{Code: "SCM-WF01"},
+		models.Scheme{Code: "STM-WF01"},
+	)
+	r := newTestRouter(env)
+
+	w := env.do(r, "GET", "/api/v1/algorithms/schemes", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var schemes []models.Scheme
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schemes))
+	codes := []string{}
+	for _, s := range schemes {
+		codes = append(codes, s.Code)
+	}
+	assert.Equal(t, []string{"KBM-WF01", "SCM-WF01"}, codes)
+
+	w = env.do(r, "GET", "/api/v1/kbm/schemes", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schemes))
+	assert.Len(t, schemes, 1)
+
+	w = env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF03","data_id":"d1"}`))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = env.do(r, "POST", "/api/v1/stm/wf01/jobs", []byte(`{"data_ref":"d1"}`))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Rejected submissions never reach the database
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListJobsPaginationBounds tests clamping and rejection of page values
+func TestListJobsPaginationBounds(t *testing.T) {
+	for _, page := range []string{"0", "-5"} {
+		t.Run("page="+page, func(t *testing.T) {
+			env := newTestEnv(t)
+			r := setupTestRouter()
+			r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+			env.db.MatchExpectationsInOrder(false)
+			env.db.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			env.db.ExpectQuery(`LIMIT \? OFFSET \?`).
+				WithArgs(20, 0).
+				WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+
+			w := env.do(r, "GET", "/api/v1/jobs?page="+page, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var resp map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, float64(1), resp["page"])
+			assert.NoError(t, env.db.ExpectationsWereMet())
+		})
+	}
+
+	for _, page := range []string{"2000000000", "99999999999999999999999"} {
+		t.Run("page="+page, func(t *testing.T) {
+			env := newTestEnv(t)
+			r := setupTestRouter()
+			r.GET("/api/v1/jobs", env.handler.ListJobs)
+
+			w := env.do(r, "GET", "/api/v1/jobs?page="+page, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, env.db.ExpectationsWereMet(), "no query for rejected pages")
+		})
+	}
+}
+
+// TestSubmitJobCorrelatesAlgoAssignedTaskID tests progress correlation when the algorithm service assigns its own ID
+func TestSubmitJobCorrelatesAlgoAssignedTaskID(t *testing.T) {
+	env := newTestEnv(t)
+	watched := make(chan string, 3)
+	proceed := make(chan struct{})
+	env.withAlgo(t, &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: "algo-42"}, nil
+		},
+		watch: func(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			watched <- req.TaskId
+			if req.TaskId != "algo-42" {
+				return nil
+			}
+			// Hold progress until the test knows the job ID
+			<-proceed
+			_ = stream.Send(&pb.ProgressUpdate{TaskId: "algo-42", Percentage: 50})
+			return stream.Send(&pb.ProgressUpdate{TaskId: "algo-42", Percentage: 100})
+		},
+	})
+
+	var jobID string
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", "anonymous", "d1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET algo_task_id = \? WHERE job_id = \?`).
+		WithArgs("algo-42", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+		WithArgs(50, sqlmock.AnyArg(), 50, sqlmock.AnyArg(), jobIDArg{&jobID}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+		WithArgs(100, sqlmock.AnyArg(), 100, sqlmock.AnyArg(), jobIDArg{&jobID}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	jobID = resp["job_id"]
+	require.NotEqual(t, "algo-42", jobID)
+	close(proceed)
+
+	select {
+	case id := <-watched:
+		assert.Equal(t, "algo-42", id, "progress must be watched under the algo-assigned ID")
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress was never watched")
+	}
+	require.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, 5*time.Second, 10*time.Millisecond)
+
+	// Progress is cached and result callbacks resolve under our job ID
+	assert.True(t, env.redis.Exists("job:progress:"+jobID))
+	assert.Equal(t, jobID, env.handler.jobs.ResolveJobID(context.Background(), "algo-42"))
+}
+
+// jobIDArg matches the job ID once the handler has returned it
+type jobIDArg struct{ id *string }
+
+func (a jobIDArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && s == *a.id
+}
+
+// TestHealthCheckReportsCircuitBreaker tests the algorithm_service health detail in each breaker state
+func TestHealthCheckReportsCircuitBreaker(t *testing.T) {
+	failing := &fakeAlgo{submit: func(context.Context, *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		return nil, status.Error(codes.Unavailable, "algorithm service down")
+	}}
+
+	health := func(t *testing.T, env *testEnv) (map[string]any, map[string]any) {
+		t.Helper()
+		r := setupTestRouter()
+		r.GET("/health", env.handler.HealthCheck)
+		w := env.do(r, "GET", "/health", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body, body["checks"].(map[string]any)["algorithm_service"].(map[string]any)
+	}
+	trip := func(client *grpcclient.AlgoClient) {
+		for i := 0; i < 2; i++ {
+			_, _ = client.SubmitJob(context.Background(), "KBM-WF01", "d", nil, nil, "job-1")
+		}
+	}
+
+	t.Run("closed", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.withAlgo(t, &fakeAlgo{})
+		body, algo := health(t, env)
+		assert.Equal(t, "healthy", body["status"])
+		assert.Equal(t, "healthy", algo["status"])
+		assert.Equal(t, "closed", algo["circuit_state"])
+		assert.Equal(t, float64(0), algo["consecutive_failures"])
+		assert.NotContains(t, algo, "next_probe_at")
+	})
+
+	t.Run("open", func(t *testing.T) {
+		env := newTestEnv(t)
+		client := env.withAlgoConfig(t, failing, func(cfg *grpcclient.AlgoClientConfig) {
+			cfg.BreakerThreshold = 2
+			cfg.BreakerCooldown = time.Hour
+		})
+		trip(client)
+
+		body, algo := health(t, env)
+		assert.Equal(t, "degraded", body["status"])
+		assert.Equal(t, "unhealthy", algo["status"])
+		assert.Equal(t, "open", algo["circuit_state"])
+		assert.Equal(t, float64(2), algo["consecutive_failures"])
+		next, err := time.Parse(time.RFC3339, algo["next_probe_at"].(string))
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), next, time.Minute)
+	})
+
+	t.Run("half-open", func(t *testing.T) {
+		env := newTestEnv(t)
+		client := env.withAlgoConfig(t, failing, func(cfg *grpcclient.AlgoClientConfig) {
+			cfg.BreakerThreshold = 2
+			cfg.BreakerCooldown = 20 * time.Millisecond
+		})
+		trip(client)
+		time.Sleep(40 * time.Millisecond)
+
+		body, algo := health(t, env)
+		assert.Equal(t, "degraded", body["status"])
+		assert.Equal(t, "degraded", algo["status"])
+		assert.Equal(t, "half-open", algo["circuit_state"])
+		assert.Equal(t, float64(2), algo["consecutive_failures"])
+		assert.Contains(t, algo, "next_probe_at")
+	})
+}
+
+// TestSubmitJobSchemeConcurrencyLimit tests that a capped scheme is throttled while other schemes still flow
+func TestSubmitJobSchemeConcurrencyLimit(t *testing.T) {
+	env := newTestEnv(t)
+	env.handler.jobs.SetSchemeLimiter(services.NewSchemeLimiter(map[string]int{"KBM-WF03": 1}))
+	env.withAlgo(t, &fakeAlgo{})
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	submit := func(scheme string) *httptest.ResponseRecorder {
+		return env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"`+scheme+`","data_id":"d1"}`))
+	}
+	expectInsert := func(scheme string) {
+		env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+			WithArgs(sqlmock.AnyArg(), scheme, sqlmock.AnyArg(), "d1", "{}", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	expectInsert("KBM-WF03")
+	w := submit("KBM-WF03")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var first map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+
+	w = submit("KBM-WF03")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "RATE_LIMITED", resp.Code)
+
+	expectInsert("KBM-WF01")
+	assert.Equal(t, http.StatusOK, submit("KBM-WF01").Code)
+
+	// Finishing the capped job frees its slot
+	env.expectFinishJob(first["job_id"], "{}")
+	require.NoError(t, env.handler.jobs.FinishJob(context.Background(), first["job_id"], "{}"))
+	require.NoError(t, env.handler.jobs.WaitHooks(context.Background()))
+	expectInsert("KBM-WF03")
+	assert.Equal(t, http.StatusOK, submit("KBM-WF03").Code)
+
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetJobResultPathProjection tests that ?path returns only the selected part of the result
+func TestGetJobResultPathProjection(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/:id/result", env.handler.GetJobResult)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS",
+		Result: `{"summary":{"metrics":{"rmse":0.12},"rows":[{"v":1},{"v":2}]},"series":[1,2,3]}`})
+	env.expectGetResult("job-1", `{"summary":{"metrics":{"rmse":0.12},"rows":[{"v":1},{"v":2}]},"series":[1,2,3]}`)
+	w := env.do(r, "GET", "/api/v1/jobs/job-1/result?path=summary.metrics", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "summary.metrics", resp["path"])
+	assert.Equal(t, map[string]any{"rmse": 0.12}, resp["result"])
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS",
+		Result: `{"summary":{"rows":[{"v":1},{"v":2}]}}`})
+	env.expectGetResult("job-1", `{"summary":{"rows":[{"v":1},{"v":2}]}}`)
+	w = env.do(r, "GET", "/api/v1/jobs/job-1/result?path=summary.rows.1.v", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(2), resp["result"])
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetJobResultCachedWithinTTL tests that repeated reads of a hot job hit the store once
+func TestGetJobResultCachedWithinTTL(t *testing.T) {
+	env := newTestEnv(t)
+	env.store.EnableJobCache(time.Minute, time.Minute)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/:id/result", env.handler.GetJobResult)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: `{"rows":[1,2]}`})
+	env.expectGetResult("job-1", `{"rows":[1,2]}`)
+	for i := 0; i < 3; i++ {
+		w := env.do(r, "GET", "/api/v1/jobs/job-1/result", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetJobResultPathErrors tests 404 for a missing path and 400 for a malformed one
+func TestGetJobResultPathErrors(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/:id/result", env.handler.GetJobResult)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: `{"summary":{"metrics":{}}}`})
+	env.expectGetResult("job-1", `{"summary":{"metrics":{}}}`)
+	w := env.do(r, "GET", "/api/v1/jobs/job-1/result?path=summary.missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Path not found")
+
+	for _, path := range []string{"", "summary..metrics", ".summary", "summary.$x", "a[0]"} {
+		w = env.do(r, "GET", "/api/v1/jobs/job-1/result?path="+url.QueryEscape(path), nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "path %q", path)
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestWatchProgressStopsOnCancelWithoutReconnect tests that cancelling a job's watcher mid-stream ends it without reconnecting
+func TestWatchProgressStopsOnCancelWithoutReconnect(t *testing.T) {
+	env := newTestEnv(t)
+	var calls atomic.Int32
+	sent := make(chan struct{})
+	env.withAlgo(t, &fakeAlgo{
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			calls.Add(1)
+			if err := stream.Send(&pb.ProgressUpdate{TaskId: "algo-1", Percentage: 30}); err != nil {
+				return err
+			}
+			close(sent)
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		},
+	})
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+		WithArgs(30, sqlmock.AnyArg(), 30, sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	done := make(chan struct{})
+	go func() {
+		env.handler.watchProgress(env.handler.startWatch("job-1", "", "algo-1"), env.handler.algo, "job-1", "algo-1")
+		close(done)
+	}()
+
+	<-sent
+	assert.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+	env.handler.stopWatch("job-1")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop after cancellation")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load(), "watcher reconnected after cancellation")
+}
+
+// TestWatchProgressReconnectsAfterStreamError tests that a transient stream error reconnects
+func TestWatchProgressReconnectsAfterStreamError(t *testing.T) {
+	env := newTestEnv(t)
+	var calls atomic.Int32
+	env.withAlgo(t, &fakeAlgo{
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			if calls.Add(1) == 1 {
+				return status.Error(codes.Unavailable, "stream reset")
+			}
+			return stream.Send(&pb.ProgressUpdate{TaskId: "algo-1", Percentage: 100})
+		},
+	})
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+		WithArgs(100, sqlmock.AnyArg(), 100, sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	env.handler.watchProgress(env.handler.startWatch("job-1", "", "algo-1"), env.handler.algo, "job-1", "algo-1")
+	assert.Equal(t, int32(2), calls.Load())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+	assert.Empty(t, env.handler.watchers)
+}
+
+// TestRunHealthChecksConcurrently tests that checks run in parallel, report latency and time out individually
+func TestRunHealthChecksConcurrently(t *testing.T) {
+	sleepCheck := func(name string, d time.Duration) healthCheck {
+		return healthCheck{name: name, run: func(ctx context.Context) models.HealthCheck {
+			select {
+			case <-time.After(d):
+				return models.HealthCheck{Status: models.HealthHealthy}
+			case <-ctx.Done():
+				return models.HealthCheck{Status: models.HealthUnhealthy, Error: ctx.Err().Error()}
+			}
+		}}
+	}
+
+	start := time.Now()
+	report := runHealthChecks(context.Background(), time.Second, []healthCheck{
+		sleepCheck("a", 150*time.Millisecond),
+		sleepCheck("b", 150*time.Millisecond),
+		sleepCheck("c", 150*time.Millisecond),
+	})
+	elapsed := time.Since(start)
+
+	assert.Equal(t, models.HealthHealthy, report.Status)
+	assert.Less(t, elapsed, 300*time.Millisecond, "checks ran sequentially")
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Equal(t, models.HealthHealthy, report.Checks[name].Status)
+		assert.GreaterOrEqual(t, report.Checks[name].LatencyMs, int64(150))
+	}
+
+	// A check that ignores its context is cut off at the timeout
+	start = time.Now()
+	report = runHealthChecks(context.Background(), 50*time.Millisecond, []healthCheck{
+		{name: "stuck", run: func(context.Context) models.HealthCheck {
+			time.Sleep(time.Second)
+			return models.HealthCheck{Status: models.HealthHealthy}
+		}},
+		sleepCheck("fast", 0),
+	})
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, models.HealthDegraded, report.Status)
+	assert.Equal(t, models.HealthUnhealthy, report.Checks["stuck"].Status)
+	assert.Equal(t, models.HealthHealthy, report.Checks["fast"].Status)
+}
+
+// TestHealthCheckReportsLatency tests that every dependency in the health response carries latency_ms
+func TestHealthCheckReportsLatency(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{})
+	r := setupTestRouter()
+	r.GET("/health", env.handler.HealthCheck)
+	w := env.do(r, "GET", "/health", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Status string                    `json:"status"`
+		Checks map[string]map[string]any `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "healthy", body.Status)
+	for _, name := range []string{"mysql", "redis", "algorithm_service"} {
+		require.Contains(t, body.Checks, name)
+		assert.Equal(t, "healthy", body.Checks[name]["status"], name)
+		assert.Contains(t, body.Checks[name], "latency_ms", name)
+	}
+}
+
+// TestSubmitJobMetadataRoundTrip tests that submit metadata reaches the
+// algorithm service, is persisted, and is returned with the job
+func TestSubmitJobMetadataRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	submitted := make(chan *pb.TaskRequest, 1)
+	env.withAlgo(t, &fakeAlgo{submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		submitted <- req
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+	metadata := map[string]string{"trace_id": "abc123", "tenant": "grid-east"}
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", "anonymous", "d1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET metadata = \? WHERE job_id = \?`).
+		WithArgs(`{"tenant":"grid-east","trace_id":"abc123"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	r.GET("/api/v1/jobs/:id", env.handler.GetJob)
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1","metadata":{"trace_id":"abc123","tenant":"grid-east"}}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	jobID := resp["job_id"]
+
+	var req *pb.TaskRequest
+	select {
+	case req = <-submitted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not dispatched")
+	}
+	assert.Equal(t, metadata, req.Metadata)
+
+	// The algorithm service echoes the metadata in its result
+	env.expectResultCallback(jobID)
+	env.expectFinishJob(jobID, `{"ok":true}`)
+	env.db.ExpectQuery(`SELECT parent_job_id, step_index FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_job_id", "step_index"}))
+	_, err := grpcserver.NewResultServer(env.handler.jobs).ReportResult(context.Background(), &pb.TaskResult{
+		TaskId: jobID, Status: pb.TaskResult_SUCCESS, ResultJson: `{"ok":true}`, Metadata: req.Metadata,
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, 2*time.Second, 10*time.Millisecond)
+
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status", "metadata"}).
+			AddRow(jobID, "SUCCESS", []byte(`{"tenant":"grid-east","trace_id":"abc123"}`)))
+	w = env.do(r, "GET", "/api/v1/jobs/"+jobID, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got struct {
+		Job struct {
+			Metadata map[string]string `json:"metadata"`
+		} `json:"job"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, metadata, got.Job.Metadata)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitJobRejectsTooManyMetadataKeys tests the metadata size limit
+func TestSubmitJobRejectsTooManyMetadataKeys(t *testing.T) {
+	env := newTestEnv(t)
+	metadata := map[string]string{}
+	for i := 0; i < 33; i++ {
+		metadata["k"+strconv.Itoa(i)] = "v"
+	}
+	body, _ := json.Marshal(map[string]any{"scheme": "KBM-WF01", "data_id": "d1", "metadata": metadata})
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	w := env.do(r, "POST", "/api/v1/jobs", body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestCorruptSchemeCacheSelfHeals tests that an undecodable scheme cache entry is deleted and the
+// algorithm service is used instead
+func TestCorruptSchemeCacheSelfHeals(t *testing.T) {
+	env := newTestEnv(t)
+	calls := 0
+	env.withAlgo(t, &fakeAlgo{schemes: func(context.Context) (*pb.SchemeList, error) {
+		calls++
+		return &pb.SchemeList{Schemes: []*pb.SchemeList_Scheme{{Code: "KBM-WF01", Name: "Test"}}}, nil
+	}})
+
+	// A partial write is reported as corrupt and removed
+	env.redis.Set("sys:algo:schemes", `[{"code":"KBM-WF01","na`)
+	_, err := env.handler.jobs.GetCachedSchemes(context.Background())
+	assert.ErrorIs(t, err, services.ErrSchemeCacheCorrupt)
+	assert.False(t, env.redis.Exists("sys:algo:schemes"))
+
+	// So is a key of the wrong Redis type
+	env.redis.HSet("sys:algo:schemes", "code", "KBM-WF01")
+	_, err = env.handler.jobs.GetCachedSchemes(context.Background())
+	assert.ErrorIs(t, err, services.ErrSchemeCacheCorrupt)
+	assert.False(t, env.redis.Exists("sys:algo:schemes"))
+
+	// The next request falls back to the algorithm service and repopulates the cache
+	env.redis.Set("sys:algo:schemes", `{"not":"a list"}`)
+	r := setupTestRouter()
+	r.GET("/api/v1/algorithms/schemes", env.handler.GetSchemes)
+	w := env.do(r, "GET", "/api/v1/algorithms/schemes", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "KBM-WF01")
+	assert.Equal(t, 1, calls)
+
+	schemes, err := env.handler.jobs.GetCachedSchemes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, schemes, 1)
+	assert.Equal(t, "KBM-WF01", schemes[0].Code)
+}
+
+// TestSubmitJobWaitAccepted tests the default async submission and the wait=accepted mode
+func TestSubmitJobWaitAccepted(t *testing.T) {
+	// progressAlgo reports 10% once release is closed, then holds the stream open
+	progressAlgo := func(release <-chan struct{}) *fakeAlgo {
+		return &fakeAlgo{watch: func(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			select {
+			case <-release:
+			case <-stream.Context().Done():
+				return nil
+			}
+			if err := stream.Send(&pb.ProgressUpdate{TaskId: req.TaskId, Percentage: 10}); err != nil {
+				return err
+			}
+			<-stream.Context().Done()
+			return nil
+		}}
+	}
+	// submit expects the job insert, then any updates registered by expect
+	submit := func(t *testing.T, env *testEnv, query string, expect func()) map[string]string {
+		t.Helper()
+		env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+		if expect != nil {
+			expect()
+		}
+		r := setupTestRouter()
+		r.POST("/api/v1/jobs", env.handler.SubmitJob)
+		w := env.do(r, "POST", "/api/v1/jobs"+query, []byte(`{"scheme":"KBM-WF01","data_id":"d1"}`))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("async by default", func(t *testing.T) {
+		env := newTestEnv(t)
+		release := make(chan struct{})
+		env.withAlgo(t, progressAlgo(release))
+
+		start := time.Now()
+		resp := submit(t, env, "", nil)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, "PENDING", resp["status"])
+		assert.Empty(t, resp["note"])
+		assert.NoError(t, env.db.ExpectationsWereMet())
+	})
+
+	t.Run("waits for first progress", func(t *testing.T) {
+		env := newTestEnv(t)
+		release := make(chan struct{})
+		env.withAlgo(t, progressAlgo(release))
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+		}()
+		resp := submit(t, env, "?wait=accepted", func() {
+			env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+				WithArgs(10, sqlmock.AnyArg(), 10, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		})
+		assert.Equal(t, "RUNNING", resp["status"])
+		assert.NotEmpty(t, resp["job_id"])
+		assert.Empty(t, resp["note"])
+		assert.NoError(t, env.db.ExpectationsWereMet())
+	})
+
+	t.Run("falls back to async on timeout", func(t *testing.T) {
+		cfg := DefaultHandlerConfig()
+		cfg.SubmitWaitTimeout = 100 * time.Millisecond
+		env := newTestEnvWithConfig(t, cfg)
+		env.withAlgo(t, progressAlgo(make(chan struct{})))
+
+		resp := submit(t, env, "?wait=accepted", nil)
+		assert.Equal(t, "PENDING", resp["status"])
+		assert.Contains(t, resp["note"], "continues asynchronously")
+		assert.NoError(t, env.db.ExpectationsWereMet())
+	})
+
+	t.Run("rejects unknown wait mode", func(t *testing.T) {
+		env := newTestEnv(t)
+		r := setupTestRouter()
+		r.POST("/api/v1/jobs", env.handler.SubmitJob)
+		w := env.do(r, "POST", "/api/v1/jobs?wait=done", []byte(`{"scheme":"KBM-WF01","data_id":"d1"}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, env.db.ExpectationsWereMet())
+	})
+}
+
+// TestGetJobByAlgoTask tests the reverse lookup from an algorithm task ID
+func TestGetJobByAlgoTask(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/by-algo-task/:algo_task_id", env.handler.GetJobByAlgoTask)
+
+	env.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("algo-7").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "RUNNING", Progress: 40})
+	w := env.do(r, "GET", "/api/v1/jobs/by-algo-task/algo-7", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Job map[string]any `json:"job"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "job-1", resp.Job["job_id"])
+	assert.Equal(t, "RUNNING", resp.Job["status"])
+
+	env.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("algo-unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	w = env.do(r, "GET", "/api/v1/jobs/by-algo-task/algo-unknown", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "algo-unknown")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetDurationHistogram tests the per-scheme duration distribution endpoint
+func TestGetDurationHistogram(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/system/duration-histogram", env.handler.GetDurationHistogram)
+
+	// Twenty CPU-sized runs of 10..200 seconds
+	rows := sqlmock.NewRows([]string{"duration"})
+	for i := 1; i <= 20; i++ {
+		rows.AddRow(10 * i)
+	}
+	env.db.ExpectQuery(`SELECT TIMESTAMPDIFF\(SECOND, created_at, finished_at\) FROM t_algo_jobs WHERE deleted_at IS NULL AND created_at >= \? AND scheme_code = \?`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", maxHistogramRows).
+		WillReturnRows(rows)
+	w := env.do(r, "GET", "/api/v1/system/duration-histogram?scheme=KBM-WF01&window=7d", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var hist storage.DurationHistogram
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hist))
+	assert.Equal(t, 20, hist.Count)
+	assert.Equal(t, 100.0, hist.P50)
+	assert.Equal(t, 190.0, hist.P95)
+	assert.Equal(t, 200.0, hist.P99)
+	assert.Len(t, hist.Buckets, len(storage.DurationBucketBounds)+1)
+	assert.Nil(t, hist.Buckets[len(hist.Buckets)-1].LeSeconds)
+	assert.Contains(t, w.Body.String(), `{"le_seconds":120,"count":6}`)
+
+	w = env.do(r, "GET", "/api/v1/system/duration-histogram?window=soon", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestDeleteJobSoftDeletesFinishedJob tests that deleting a finished job hides it from later lookups
+func TestDeleteJobSoftDeletesFinishedJob(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.DELETE("/api/v1/jobs/:id", env.handler.DeleteJob)
+	r.GET("/api/v1/jobs/:id", env.handler.GetJob)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS"})
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET deleted_at = \?, updated_at = \?\s+WHERE job_id = \? AND deleted_at IS NULL AND status IN \('SUCCESS', 'FAILED', 'CANCELLED'\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := env.do(r, "DELETE", "/api/v1/jobs/job-1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "job-1", resp["job_id"])
+	assert.NotEmpty(t, resp["deleted_at"])
+
+	// Lookups skip soft-deleted rows
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \? AND deleted_at IS NULL`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	w = env.do(r, "GET", "/api/v1/jobs/job-1", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Deleting it again finds nothing
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \? AND deleted_at IS NULL`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	w = env.do(r, "DELETE", "/api/v1/jobs/job-1", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestDeleteJobRejectsUnfinishedJob tests that PENDING and RUNNING jobs can't be deleted
+func TestDeleteJobRejectsUnfinishedJob(t *testing.T) {
+	for _, status := range []string{"PENDING", "RUNNING"} {
+		t.Run(status, func(t *testing.T) {
+			env := newTestEnv(t)
+			r := setupTestRouter()
+			r.DELETE("/api/v1/jobs/:id", env.handler.DeleteJob)
+
+			env.expectGetJob(jobRow{JobID: "job-1", Status: status})
+			w := env.do(r, "DELETE", "/api/v1/jobs/job-1", nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Cannot delete unfinished job")
+			assert.NoError(t, env.db.ExpectationsWereMet(), "no update for unfinished jobs")
+		})
+	}
+}
+
+// TestCancelJobFinishedDuringCancel tests that a job the algorithm service
+// reports finished while the cancel was in flight is not marked cancelled
+func TestCancelJobFinishedDuringCancel(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{cancel: func(context.Context, *pb.CancelRequest) (*pb.CancelResponse, error) {
+		return &pb.CancelResponse{Accepted: false, Message: "Task already finished", Status: "SUCCESS"}, nil
+	}})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/:id/cancel", env.handler.CancelJob)
+
+	// Still RUNNING when checked; no cancel UPDATE may follow
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "RUNNING"})
+	env.db.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"algo_task_id"}).AddRow(nil))
+	w := env.do(r, "POST", "/api/v1/jobs/job-1/cancel", nil)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Job already completed", resp.Error)
+	assert.Contains(t, resp.Message, "SUCCESS")
+
+	// The job keeps the SUCCESS the progress watcher recorded
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS"})
+	w = env.do(r, "POST", "/api/v1/jobs/job-1/cancel", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Cannot cancel completed job")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestJobLookupFailureIsNotNotFound tests that only a missing job is 404;
+// a failing database is reported as an internal error
+func TestJobLookupFailureIsNotNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/:id/cancel", env.handler.CancelJob)
+	r.GET("/api/v1/jobs/:id/result", env.handler.GetJobResult)
+
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).WithArgs("job-1").WillReturnError(sql.ErrNoRows)
+	w := env.do(r, "POST", "/api/v1/jobs/job-1/cancel", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"NOT_FOUND"`)
+
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).WithArgs("job-1").WillReturnError(errors.New("too many connections"))
+	w = env.do(r, "GET", "/api/v1/jobs/job-1/result", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"INTERNAL_ERROR"`)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// timeBetween matches a time argument within [from, to]
+type timeBetween struct{ from, to time.Time }
+
+func (m timeBetween) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && !t.Before(m.from) && !t.After(m.to)
+}
+
+// TestSubmitJobStoresTimeout tests that timeout_seconds is stored as the time the job times out at
+func TestSubmitJobStoresTimeout(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	before := time.Now()
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET timeout_at = \? WHERE job_id = \?`).
+		WithArgs(timeBetween{before.Add(90 * time.Second), before.Add(90*time.Second + time.Minute)}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1","timeout_seconds":90}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+
+	w = env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1","timeout_seconds":-5}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// healthCheck probes one component. run returns the component's status;
+// runHealthChecks fills in its latency.
+type healthCheck struct {
+	name string
+	run  func(ctx context.Context) models.HealthCheck
+}
+
+// pingCheck reports unhealthy when ping fails
+func pingCheck(name string, critical bool, ping func(ctx context.Context) error) healthCheck {
+	return healthCheck{name: name, run: func(ctx context.Context) models.HealthCheck {
+		if err := ping(ctx); err != nil {
+			return models.HealthCheck{Status: models.HealthUnhealthy, Error: err.Error(), Critical: critical}
+		}
+		return models.HealthCheck{Status: models.HealthHealthy, Critical: critical}
+	}}
+}
+
+// dialCheck reports unhealthy when nothing accepts TCP connections on addr
+func dialCheck(name string, critical bool, addr string) healthCheck {
+	return pingCheck(name, critical, func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// runHealthChecks runs all checks concurrently, each bounded by timeout, and
+// records the latency of every check
+func runHealthChecks(ctx context.Context, timeout time.Duration, checks []healthCheck) models.HealthReport {
+	type outcome struct {
+		name  string
+		check models.HealthCheck
+	}
+	outcomes := make(chan outcome, len(checks))
+	for _, check := range checks {
+		go func(check healthCheck) {
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			done := make(chan models.HealthCheck, 1)
+			go func() { done <- check.run(checkCtx) }()
+
+			var result models.HealthCheck
+			select {
+			case result = <-done:
+			case <-checkCtx.Done():
+				result = models.HealthCheck{Status: models.HealthUnhealthy, Error: fmt.Sprintf("check timed out after %s", timeout)}
+			}
+			result.LatencyMs = time.Since(start).Milliseconds()
+			outcomes <- outcome{check.name, result}
+		}(check)
+	}
+
+	results := make(map[string]models.HealthCheck, len(checks))
+	for range checks {
+		o := <-outcomes
+		results[o.name] = o.check
+	}
+	return models.NewHealthReport(results)
+}
+
+// healthReport checks every component of the service. MySQL and the gRPC
+// result listener are critical: without them jobs can neither be recorded
+// nor completed. The other components only degrade the service.
+func (h *Handler) healthReport(ctx context.Context) models.HealthReport {
+	checks := []healthCheck{
+		pingCheck("mysql", true, h.store.Ping),
+		{name: "redis", run: h.redisHealth},
+		{name: "algorithm_service", run: h.algoHealth},
+	}
+	if h.cfg.SchedulerStatus != nil {
+		checks = append(checks, healthCheck{name: "scheduler", run: h.schedulerHealth})
+	}
+	if h.cfg.ResultServerAddr != "" {
+		checks = append(checks, dialCheck("grpc_result_server", true, h.cfg.ResultServerAddr))
+	}
+	return runHealthChecks(ctx, h.cfg.HealthCheckTimeout, checks)
+}
+
+// redisHealth reports the cache state kept by its health monitor, pinging
+// only when no monitor has checked it yet
+func (h *Handler) redisHealth(ctx context.Context) models.HealthCheck {
+	st := h.cache.Status()
+	if st.CheckedAt.IsZero() {
+		return pingCheck("redis", false, h.cache.Ping).run(ctx)
+	}
+	check := models.HealthCheck{Status: models.HealthHealthy, Details: map[string]any{
+		"checked_at": st.CheckedAt.UTC().Format(time.RFC3339),
+		"since":      st.Since.UTC().Format(time.RFC3339),
+	}}
+	if !st.Available {
+		check.Status = models.HealthUnhealthy
+		check.Error = st.LastError
+	}
+	return check
+}
+
+// schedulerHealth reports the scheduler as unhealthy when it is not running
+// and degraded when a task has stopped succeeding, with the last runs of
+// each task
+func (h *Handler) schedulerHealth(context.Context) models.HealthCheck {
+	st := h.cfg.SchedulerStatus()
+	tasks := make(map[string]any, len(st.Tasks))
+	var overdue []string
+	for _, t := range st.Tasks {
+		task := map[string]any{"overdue": t.Overdue}
+		if !t.LastRun.IsZero() {
+			task["last_run"] = t.LastRun.UTC().Format(time.RFC3339)
+		}
+		if !t.LastSuccess.IsZero() {
+			task["last_success"] = t.LastSuccess.UTC().Format(time.RFC3339)
+		}
+		if t.LastError != "" {
+			task["last_error"] = t.LastError
+		}
+		tasks[t.Name] = task
+		if t.Overdue {
+			overdue = append(overdue, t.Name)
+		}
+	}
+
+	check := models.HealthCheck{Status: models.HealthHealthy, Details: map[string]any{"tasks": tasks}}
+	switch {
+	case !st.Running:
+		check.Status = models.HealthUnhealthy
+		check.Error = "scheduler is not running"
+	case len(overdue) > 0:
+		check.Status = models.HealthDegraded
+		check.Error = "overdue tasks: " + strings.Join(overdue, ", ")
+	}
+	if st.Running {
+		check.Details["started_at"] = st.StartedAt.UTC().Format(time.RFC3339)
+	}
+	return check
+}
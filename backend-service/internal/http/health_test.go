@@ -0,0 +1,106 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/scheduler"
+)
+
+func TestNewHealthReportStatus(t *testing.T) {
+	healthy := models.HealthCheck{Status: models.HealthHealthy, Critical: true}
+	down := models.HealthCheck{Status: models.HealthUnhealthy}
+	criticalDown := models.HealthCheck{Status: models.HealthUnhealthy, Critical: true}
+
+	assert.Equal(t, models.HealthHealthy, models.NewHealthReport(map[string]models.HealthCheck{"a": healthy}).Status)
+	assert.Equal(t, models.HealthDegraded, models.NewHealthReport(map[string]models.HealthCheck{"a": healthy, "b": down}).Status)
+	assert.Equal(t, models.HealthUnhealthy, models.NewHealthReport(map[string]models.HealthCheck{"a": criticalDown, "b": down}).Status)
+}
+
+// TestHealthCheckSchedulerAndResultServer tests that the scheduler degrades
+// the service while a closed result listener makes it unhealthy
+func TestHealthCheckSchedulerAndResultServer(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+
+	lastSuccess := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	cfg := DefaultHandlerConfig()
+	cfg.ResultServerAddr = lis.Addr().String()
+	cfg.SchedulerStatus = func() scheduler.Status {
+		return scheduler.Status{Running: true, StartedAt: lastSuccess, Tasks: []scheduler.TaskStatus{
+			{Name: "SLA check", LastRun: lastSuccess.Add(time.Hour), LastSuccess: lastSuccess, LastError: "db down", Overdue: true},
+			{Name: "zombie cleanup", LastRun: lastSuccess, LastSuccess: lastSuccess},
+		}}
+	}
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+	r := setupTestRouter()
+	r.GET("/health", env.handler.HealthCheck)
+
+	health := func(code int) map[string]any {
+		t.Helper()
+		w := env.do(r, "GET", "/health", nil)
+		require.Equal(t, code, w.Code, w.Body.String())
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	body := health(http.StatusOK)
+	assert.Equal(t, "degraded", body["status"])
+	checks := body["checks"].(map[string]any)
+	assert.Equal(t, "healthy", checks["grpc_result_server"].(map[string]any)["status"])
+	sched := checks["scheduler"].(map[string]any)
+	assert.Equal(t, "degraded", sched["status"])
+	assert.Equal(t, "overdue tasks: SLA check", sched["error"])
+	assert.Equal(t, "2026-03-01T08:00:00Z", sched["started_at"])
+	sla := sched["tasks"].(map[string]any)["SLA check"].(map[string]any)
+	assert.Equal(t, "2026-03-01T08:00:00Z", sla["last_success"])
+	assert.Equal(t, "2026-03-01T09:00:00Z", sla["last_run"])
+	assert.Equal(t, "db down", sla["last_error"])
+	assert.Equal(t, true, sla["overdue"])
+
+	// A result listener that no longer accepts connections fails the service
+	require.NoError(t, lis.Close())
+	body = health(http.StatusServiceUnavailable)
+	assert.Equal(t, "unhealthy", body["status"])
+	server := body["checks"].(map[string]any)["grpc_result_server"].(map[string]any)
+	assert.Equal(t, "unhealthy", server["status"])
+	assert.NotEmpty(t, server["error"])
+}
+
+// TestHealthCheckReportsRedisStatus tests that the redis check reports the
+// cache's last recorded state instead of pinging
+func TestHealthCheckReportsRedisStatus(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{})
+	r := setupTestRouter()
+	r.GET("/health", env.handler.HealthCheck)
+
+	env.redis.Close()
+	require.Error(t, env.cache.CheckHealth(context.Background()))
+	w := env.do(r, "GET", "/health", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "degraded", body["status"])
+	redis := body["checks"].(map[string]any)["redis"].(map[string]any)
+	assert.Equal(t, "unhealthy", redis["status"])
+	assert.Equal(t, env.cache.Status().LastError, redis["error"])
+	assert.NotEmpty(t, redis["since"])
+
+	// Redis is back, but nothing has checked it since
+	require.NoError(t, env.redis.Restart())
+	w = env.do(r, "GET", "/health", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "unhealthy", body["checks"].(map[string]any)["redis"].(map[string]any)["status"])
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// inlineDataFormats are the file types the algorithm service can load
+var inlineDataFormats = map[string]bool{"csv": true, "json": true, "parquet": true, "xlsx": true}
+
+// SubmitInlineJobRequest represents a job submission carrying its input data
+// @Description Job submission with inline base64 data
+type SubmitInlineJobRequest struct {
+	Scheme string         `json:"scheme" binding:"required" example:"KBM-WF01"`
+	Data   string         `json:"data" binding:"required" example:"YSxiCjEsMgo="`
+	Format string         `json:"format" binding:"required" example:"csv"`
+	Params map[string]any `json:"params" example:"{\"threshold\": 0.9}"`
+	UserID string         `json:"user_id" example:"user_001"`
+}
+
+// SubmitInlineJob godoc
+// @Summary      Submit a job with inline data
+// @Description  Stores small base64-encoded input data, generates a data_ref for it and dispatches the job.
+// @Description  Intended for small datasets; larger inputs should be uploaded separately.
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        request  body      SubmitInlineJobRequest  true  "Inline job submission request"
+// @Success      200  {object}  map[string]string  "Returns job_id and data_ref"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /api/v1/jobs/inline [post]
+func (h *Handler) SubmitInlineJob(c *gin.Context) {
+	if h.cfg.DataStore == nil {
+		respondError(c, apperr.Unavailable("Inline data not enabled", ""))
+		return
+	}
+
+	maxBytes := h.cfg.InlineDataMaxBytes
+	// Base64 inflates by 4/3; leave headroom for the rest of the JSON body
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(base64.StdEncoding.EncodedLen(int(maxBytes)))+64<<10)
+
+	var req SubmitInlineJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.rejectInlineTooLarge(c, maxBytes)
+			return
+		}
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
+		return
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(req.Format, "."))
+	if !inlineDataFormats[format] {
+		respondError(c, apperr.Validation("Invalid request", "format must be one of csv, json, parquet, xlsx"))
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(req.Data)
+	if err != nil {
+		respondError(c, apperr.Validation("Invalid request", "data is not valid base64"))
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		h.rejectInlineTooLarge(c, maxBytes)
+		return
+	}
+
+	if h.rejectDisallowedScheme(c, req.Scheme) {
+		return
+	}
+	userID, ok := h.submissionUser(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	dataRef, err := h.cfg.DataStore.Put(c.Request.Context(), uuid.NewString()+"."+format, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to store data", err.Error()))
+		return
+	}
+
+	jobID, ok := h.dispatchJob(c, SubmitJobRequest{
+		Scheme: req.Scheme,
+		DataID: dataRef,
+		Params: req.Params,
+		UserID: userID,
+	})
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"job_id": jobID, "status": "PENDING", "data_ref": dataRef})
+}
+
+func (h *Handler) rejectInlineTooLarge(c *gin.Context, maxBytes int64) {
+	respondError(c, apperr.TooLarge(
+		"Inline data too large",
+		fmt.Sprintf("inline data must be at most %d bytes; upload larger datasets and pass data_id", maxBytes),
+	))
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+func newInlineTestEnv(t *testing.T, maxBytes int64) *testEnv {
+	t.Helper()
+	dataStore, err := storage.NewLocalDataStore(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := DefaultHandlerConfig()
+	cfg.DataStore = dataStore
+	cfg.InlineDataMaxBytes = maxBytes
+	return newTestEnvWithConfig(t, cfg)
+}
+
+// TestSubmitInlineJobCreatesDataRef tests that inline data is stored and its ref used for the job
+func TestSubmitInlineJobCreatesDataRef(t *testing.T) {
+	env := newInlineTestEnv(t, 1024)
+	env.withAlgo(t, &fakeAlgo{})
+
+	csv := "voltage,current\n220,5\n"
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", "user-1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/inline", env.handler.SubmitInlineJob)
+	body := `{"scheme":"KBM-WF01","format":"csv","user_id":"user-1","data":"` + base64.StdEncoding.EncodeToString([]byte(csv)) + `"}`
+	w := env.do(r, "POST", "/api/v1/jobs/inline", []byte(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp["job_id"])
+	require.True(t, strings.HasPrefix(resp["data_ref"], "file://"), resp["data_ref"])
+	assert.True(t, strings.HasSuffix(resp["data_ref"], ".csv"))
+
+	ref, err := url.Parse(resp["data_ref"])
+	require.NoError(t, err)
+	stored, err := os.ReadFile(ref.Path)
+	require.NoError(t, err)
+	assert.Equal(t, csv, string(stored))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitInlineJobTooLarge tests the 413 for oversized inline data
+func TestSubmitInlineJobTooLarge(t *testing.T) {
+	env := newInlineTestEnv(t, 16)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/inline", env.handler.SubmitInlineJob)
+
+	// Just over the decoded cap
+	body := `{"scheme":"KBM-WF01","format":"csv","data":"` + base64.StdEncoding.EncodeToString(make([]byte, 17)) + `"}`
+	w := env.do(r, "POST", "/api/v1/jobs/inline", []byte(body))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Far over the cap: rejected while reading the body
+	body = `{"scheme":"KBM-WF01","format":"csv","data":"` + strings.Repeat("A", 200<<10) + `"}`
+	w = env.do(r, "POST", "/api/v1/jobs/inline", []byte(body))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitInlineJobValidation tests format and encoding checks
+func TestSubmitInlineJobValidation(t *testing.T) {
+	env := newInlineTestEnv(t, 1024)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/inline", env.handler.SubmitInlineJob)
+
+	w := env.do(r, "POST", "/api/v1/jobs/inline", []byte(`{"scheme":"KBM-WF01","format":"exe","data":"YQ=="}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = env.do(r, "POST", "/api/v1/jobs/inline", []byte(`{"scheme":"KBM-WF01","format":"csv","data":"not base64!"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,15 +1,14 @@
 package http
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
+	"github.com/electric-power/backend-service/internal/apperr"
 	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // ModuleJobRequest represents a job submission for a specific module/workflow
@@ -29,6 +28,9 @@ type ModuleJobRequest struct {
 // @Param        request  body      ModuleJobRequest  true  "Job submission request"
 // @Success      200      {object}  map[string]string "Returns job_id and status"
 // @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
 func (h *Handler) SubmitModuleJob(module, workflow string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -47,16 +49,16 @@ func (h *Handler) SubmitModuleJob(module, workflow string) gin.HandlerFunc {
 // @Param        request  body      ModuleJobRequest  true  "Job submission request"
 // @Success      200      {object}  map[string]string "Returns job_id and status"
 // @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      429      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
+// @Failure      502      {object}  ErrorResponse
 func (h *Handler) SubmitDynamicWorkflowJob(module string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		workflow := strings.ToUpper(c.Param("workflow"))
 		if workflow == "" {
-			c.JSON(http.StatusBadRequest, ErrorResponse{
-				Error:   "Missing workflow",
-				Message: "workflow path parameter is required",
-				Code:    400,
-			})
+			respondError(c, apperr.Validation("Missing workflow", "workflow path parameter is required"))
 			return
 		}
 		h.submitModuleJobInternal(c, module, workflow)
@@ -67,39 +69,28 @@ func (h *Handler) SubmitDynamicWorkflowJob(module string) gin.HandlerFunc {
 func (h *Handler) submitModuleJobInternal(c *gin.Context, module, workflow string) {
 	var req ModuleJobRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-			Code:    400,
-		})
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
 		return
 	}
 
-	// Construct scheme code from module and workflow
-	schemeCode := fmt.Sprintf("%s-%s", strings.ToUpper(module), strings.ToUpper(workflow))
-
-	jobID := uuid.NewString()
-	paramsJSON, _ := json.Marshal(req.Params)
-
-	if err := h.jobs.CreateJob(c.Request.Context(), jobID, schemeCode, req.UserID, req.DataRef, string(paramsJSON)); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to create job",
-			Message: err.Error(),
-		})
+	userID, ok := h.submissionUser(c, req.UserID)
+	if !ok {
 		return
 	}
 
-	if err := h.algo.SubmitJob(c.Request.Context(), schemeCode, req.DataRef, req.Params, jobID); err != nil {
-		_ = h.jobs.FailJob(c.Request.Context(), jobID, "Failed to submit to algorithm service: "+err.Error())
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to submit job",
-			Message: err.Error(),
-		})
+	// Construct scheme code from module and workflow
+	schemeCode := fmt.Sprintf("%s-%s", strings.ToUpper(module), strings.ToUpper(workflow))
+	jobID, ok := h.dispatchJob(c, SubmitJobRequest{
+		Scheme: schemeCode,
+		DataID: req.DataRef,
+		Params: req.Params,
+		UserID: userID,
+	})
+	if !ok {
 		return
 	}
 
-	go h.watchProgress(jobID)
-	c.JSON(http.StatusOK, gin.H{
+	respond(c, http.StatusOK, gin.H{
 		"job_id":   jobID,
 		"status":   "PENDING",
 		"scheme":   schemeCode,
@@ -115,20 +106,13 @@ func (h *Handler) submitModuleJobInternal(c *gin.Context, module, workflow strin
 // @Accept       json
 // @Produce      json
 // @Success      200  {array}   models.Scheme
-// @Failure      500  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
 func (h *Handler) GetSchemesForModule(module string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		allSchemes, err := h.jobs.GetCachedSchemes(c.Request.Context())
+		allSchemes, err := h.visibleSchemes(c.Request.Context())
 		if err != nil {
-			allSchemes, err = h.algo.GetSchemes(c.Request.Context())
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
-					Error:   "Failed to get schemes",
-					Message: err.Error(),
-				})
-				return
-			}
-			_ = h.jobs.CacheSchemes(c.Request.Context(), allSchemes)
+			respondError(c, apperr.Upstream("Failed to get schemes", err.Error()))
+			return
 		}
 
 		prefix := strings.ToUpper(module) + "-"
@@ -139,13 +123,13 @@ func (h *Handler) GetSchemesForModule(module string) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, filtered)
+		respond(c, http.StatusOK, filtered)
 	}
 }
 
 // ListModuleJobs returns a handler that lists jobs filtered by module
 // @Summary      List jobs for a module
-// @Description  Returns paginated jobs filtered by module (scheme code prefix)
+// @Description  Returns paginated jobs filtered by module (scheme code prefix); total and pages count the module's jobs only
 // @Tags         modules
 // @Accept       json
 // @Produce      json
@@ -154,46 +138,29 @@ func (h *Handler) GetSchemesForModule(module string) gin.HandlerFunc {
 // @Param        user_id   query  string  false  "Filter by user"
 // @Param        status    query  string  false  "Filter by status"
 // @Success      200  {object}  map[string]any
+// @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 func (h *Handler) ListModuleJobs(module string) gin.HandlerFunc {
+	prefix := strings.ToUpper(module) + "-"
 	return func(c *gin.Context) {
-		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-		userID := c.Query("user_id")
-		status := c.Query("status")
-
-		if page < 1 {
-			page = 1
-		}
-		if pageSize < 1 || pageSize > 100 {
-			pageSize = 20
+		page, pageSize, ok := parsePagination(c)
+		if !ok {
+			return
 		}
+		filter := storage.JobFilter{UserID: c.Query("user_id"), Status: c.Query("status")}
 
-		// Get all jobs and filter by module prefix
-		// Note: For production, add module filtering to the SQL query
-		jobs, _, err := h.store.ListJobsWithPagination(c.Request.Context(), userID, status, page, pageSize)
+		jobs, total, err := h.store.ListModuleJobs(c.Request.Context(), prefix, filter, page, pageSize)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "Failed to list jobs",
-				Message: err.Error(),
-			})
+			respondError(c, apperr.Internal("Failed to list jobs", err.Error()))
 			return
 		}
 
-		// Filter by module prefix
-		prefix := strings.ToUpper(module) + "-"
-		filtered := make([]models.Job, 0)
-		for _, job := range jobs {
-			if strings.HasPrefix(strings.ToUpper(job.SchemeCode), prefix) {
-				filtered = append(filtered, job)
-			}
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"jobs":      filtered,
-			"total":     len(filtered),
+		respond(c, http.StatusOK, gin.H{
+			"jobs":      jobs,
+			"total":     total,
 			"page":      page,
 			"page_size": pageSize,
+			"pages":     (total + pageSize - 1) / pageSize,
 			"module":    module,
 		})
 	}
@@ -206,18 +173,13 @@ func (h *Handler) ListModuleJobs(module string) gin.HandlerFunc {
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  map[string]any
+// @Failure      502  {object}  ErrorResponse
 func (h *Handler) GetModuleWorkflows(module string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		allSchemes, err := h.jobs.GetCachedSchemes(c.Request.Context())
+		allSchemes, err := h.visibleSchemes(c.Request.Context())
 		if err != nil {
-			allSchemes, err = h.algo.GetSchemes(c.Request.Context())
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, ErrorResponse{
-					Error:   "Failed to get schemes",
-					Message: err.Error(),
-				})
-				return
-			}
+			respondError(c, apperr.Upstream("Failed to get schemes", err.Error()))
+			return
 		}
 
 		prefix := strings.ToUpper(module) + "-"
@@ -243,7 +205,7 @@ func (h *Handler) GetModuleWorkflows(module string) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		respond(c, http.StatusOK, gin.H{
 			"module":    module,
 			"workflows": workflows,
 		})
@@ -7,8 +7,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestGetSchemesForModuleHandler tests the GetSchemesForModule handler factory
@@ -119,67 +121,35 @@ func TestGetModuleWorkflowsHandler(t *testing.T) {
 	assert.Equal(t, "KBM-WF01", wf1["code"])
 }
 
-// TestListModuleJobsHandler tests module job listing with filtering
+// TestListModuleJobsHandler tests that module job lists are filtered and
+// counted in SQL, keeping the user and status filters
 func TestListModuleJobsHandler(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-
-	// Mock jobs data
-	type Job struct {
-		JobID      string `json:"job_id"`
-		SchemeCode string `json:"scheme_code"`
-	}
-
-	mockJobs := []Job{
-		{JobID: "job-1", SchemeCode: "KBM-WF01"},
-		{JobID: "job-2", SchemeCode: "KBM-WF02"},
-		{JobID: "job-3", SchemeCode: "SCM-WF01"},
-		{JobID: "job-4", SchemeCode: "STM-WF01"},
-	}
-
-	r.GET("/api/v1/:module/jobs", func(c *gin.Context) {
-		module := strings.ToUpper(c.Param("module"))
-		prefix := module + "-"
-
-		filtered := []Job{}
-		for _, job := range mockJobs {
-			if strings.HasPrefix(job.SchemeCode, prefix) {
-				filtered = append(filtered, job)
-			}
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"module": module,
-			"jobs":   filtered,
-			"total":  len(filtered),
-		})
-	})
-
-	tests := []struct {
-		module    string
-		wantCount int
-	}{
-		{"kbm", 2},
-		{"scm", 1},
-		{"stm", 1},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.module, func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/api/v1/"+tt.module+"/jobs", nil)
-			w := httptest.NewRecorder()
-			r.ServeHTTP(w, req)
-
-			assert.Equal(t, http.StatusOK, w.Code)
-
-			var response map[string]interface{}
-			err := json.Unmarshal(w.Body.Bytes(), &response)
-			assert.NoError(t, err)
-
-			jobs := response["jobs"].([]interface{})
-			assert.Len(t, jobs, tt.wantCount)
-		})
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/kbm/jobs", env.handler.ListModuleJobs("KBM"))
+
+	env.db.MatchExpectationsInOrder(false)
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND user_id = \? AND status = \? AND scheme_code LIKE \? ESCAPE '!'$`).
+		WithArgs("alice", "SUCCESS", "KBM-%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(45))
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND user_id = \? AND status = \? AND scheme_code LIKE \? ESCAPE '!' ORDER BY`).
+		WithArgs("alice", "SUCCESS", "KBM-%", 20, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "scheme_code"}).AddRow("job-21", "KBM-WF01").AddRow("job-22", "KBM-WF02"))
+
+	w := env.do(r, "GET", "/api/v1/kbm/jobs?page=2&user_id=alice&status=SUCCESS", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Jobs   []map[string]any `json:"jobs"`
+		Total  int              `json:"total"`
+		Pages  int              `json:"pages"`
+		Module string           `json:"module"`
 	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Jobs, 2)
+	assert.Equal(t, 45, resp.Total)
+	assert.Equal(t, 3, resp.Pages)
+	assert.Equal(t, "KBM", resp.Module)
+	assert.NoError(t, env.db.ExpectationsWereMet())
 }
 
 // TestSubmitDynamicWorkflowJobHandler tests job submission with dynamic workflow
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// maxNoteLength caps a single note, in characters
+const maxNoteLength = 4000
+
+// AddJobNoteRequest represents the request body for adding a note
+// @Description Operator note on a job
+type AddJobNoteRequest struct {
+	Note string `json:"note" binding:"required" example:"Input data_ref points at a truncated file"`
+}
+
+// AddJobNote godoc
+// @Summary      Add a note to a job
+// @Description  Attaches an investigation note to a job. The author is the authenticated user, or "anonymous"
+// @Description  without authentication; X-User-ID is ignored.
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string             true  "Job ID"
+// @Param        request  body      AddJobNoteRequest  true  "Note"
+// @Success      201  {object}  models.JobNote
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/{id}/notes [post]
+func (h *Handler) AddJobNote(c *gin.Context) {
+	jobID := c.Param("id")
+
+	var req AddJobNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		respondError(c, apperr.Validation("Invalid request", "note must not be empty"))
+		return
+	}
+	if utf8.RuneCountInString(note) > maxNoteLength {
+		respondError(c, apperr.Validation("Note too long", fmt.Sprintf("note must be at most %d characters", maxNoteLength)))
+		return
+	}
+
+	if _, err := h.store.GetJobTyped(c.Request.Context(), jobID); err != nil {
+		respondError(c, jobLookupError(jobID, err))
+		return
+	}
+
+	created, err := h.store.AddJobNote(c.Request.Context(), jobID, requestUser(c), note)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to add note", err.Error()))
+		return
+	}
+	respond(c, http.StatusCreated, created)
+}
+
+// ListJobNotes godoc
+// @Summary      List notes for a job
+// @Description  Returns a job's notes in chronological order
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        id        path   string  true   "Job ID"
+// @Param        page      query  int     false  "Page number"     default(1)
+// @Param        page_size query  int     false  "Items per page"  default(20)
+// @Success      200  {object}  map[string]any
+// @Failure      400  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/{id}/notes [get]
+func (h *Handler) ListJobNotes(c *gin.Context) {
+	jobID := c.Param("id")
+	page, pageSize, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	notes, total, err := h.store.ListJobNotes(c.Request.Context(), jobID, page, pageSize)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to list notes", err.Error()))
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"job_id":    jobID,
+		"notes":     notes,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"pages":     (total + pageSize - 1) / pageSize,
+	})
+}
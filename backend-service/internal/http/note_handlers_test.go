@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/middleware"
+)
+
+// TestAddJobNote tests adding a note with the author taken from the
+// authenticated user, whatever X-User-ID claims
+func TestAddJobNote(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.Use(func(c *gin.Context) { c.Set(middleware.ContextUserID, "operator-7") })
+	r.POST("/api/v1/jobs/:id/notes", env.handler.AddJobNote)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "FAILED"})
+	env.db.ExpectExec(`INSERT INTO t_job_notes`).
+		WithArgs("job-1", "operator-7", "Input file truncated", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(11, 1))
+
+	req := newJSONRequest("POST", "/api/v1/jobs/job-1/notes", `{"note":"  Input file truncated "}`)
+	req.Header.Set("X-User-ID", "someone-else")
+	w := serve(r, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var note map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &note))
+	assert.Equal(t, float64(11), note["id"])
+	assert.Equal(t, "operator-7", note["author"])
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestAddJobNoteValidation tests length cap, unknown job and a failing job lookup
+func TestAddJobNoteValidation(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/:id/notes", env.handler.AddJobNote)
+
+	long := strings.Repeat("x", maxNoteLength+1)
+	w := env.do(r, "POST", "/api/v1/jobs/job-1/notes", []byte(`{"note":"`+long+`"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = env.do(r, "POST", "/api/v1/jobs/job-1/notes", []byte(`{"note":"   "}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	env.expectJobNotFound("missing")
+	w = env.do(r, "POST", "/api/v1/jobs/missing/notes", []byte(`{"note":"hello"}`))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).WithArgs("job-1").WillReturnError(errors.New("connection reset"))
+	w = env.do(r, "POST", "/api/v1/jobs/job-1/notes", []byte(`{"note":"hello"}`))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListJobNotesChronological tests listing notes oldest first with pagination
+func TestListJobNotesChronological(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/:id/notes", env.handler.ListJobNotes)
+
+	t0 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	env.db.ExpectQuery(`SELECT COUNT\(\*\) FROM t_job_notes WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	env.db.ExpectQuery(`FROM t_job_notes\s+WHERE job_id = \? ORDER BY created_at ASC, id ASC LIMIT \? OFFSET \?`).
+		WithArgs("job-1", 2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "author", "note", "created_at"}).
+			AddRow(1, "job-1", "alice", "first", t0).
+			AddRow(2, "job-1", "bob", "second", t0.Add(time.Minute)))
+
+	w := env.do(r, "GET", "/api/v1/jobs/job-1/notes?page_size=2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Notes []struct {
+			Note string `json:"note"`
+		} `json:"notes"`
+		Total int `json:"total"`
+		Pages int `json:"pages"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Total)
+	assert.Equal(t, 2, resp.Pages)
+	require.Len(t, resp.Notes, 2)
+	assert.Equal(t, "first", resp.Notes[0].Note)
+	assert.Equal(t, "second", resp.Notes[1].Note)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -0,0 +1,56 @@
+package http
+
+import "fmt"
+
+// checkParamsShape rejects params nested deeper than maxDepth or holding more
+// than maxElements values in total (object members and array items at every
+// level). The top-level object is depth 1; a zero limit is not enforced.
+func checkParamsShape(params map[string]any, maxDepth, maxElements int) error {
+	if maxDepth <= 0 && maxElements <= 0 {
+		return nil
+	}
+	w := paramsWalker{maxDepth: maxDepth, maxElements: maxElements}
+	return w.walk(params, 1)
+}
+
+type paramsWalker struct {
+	maxDepth    int
+	maxElements int
+	elements    int
+}
+
+func (w *paramsWalker) walk(v any, depth int) error {
+	switch node := v.(type) {
+	case map[string]any:
+		if err := w.enter(depth, len(node)); err != nil {
+			return err
+		}
+		for _, child := range node {
+			if err := w.walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if err := w.enter(depth, len(node)); err != nil {
+			return err
+		}
+		for _, child := range node {
+			if err := w.walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enter accounts for a container at depth holding n values
+func (w *paramsWalker) enter(depth, n int) error {
+	if w.maxDepth > 0 && depth > w.maxDepth {
+		return fmt.Errorf("params are nested more than %d levels deep", w.maxDepth)
+	}
+	w.elements += n
+	if w.maxElements > 0 && w.elements > w.maxElements {
+		return fmt.Errorf("params hold more than %d elements", w.maxElements)
+	}
+	return nil
+}
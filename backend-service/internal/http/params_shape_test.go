@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nestedParams(depth int) string {
+	return strings.Repeat(`{"a":`, depth) + `1` + strings.Repeat(`}`, depth)
+}
+
+func TestCheckParamsShape(t *testing.T) {
+	var params map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{"a":{"b":[1,2,{"c":3}]},"d":"x"}`), &params))
+
+	// 5 values across depths 1..4
+	assert.NoError(t, checkParamsShape(params, 4, 7))
+	assert.ErrorContains(t, checkParamsShape(params, 3, 0), "nested more than 3 levels")
+	assert.ErrorContains(t, checkParamsShape(params, 0, 6), "more than 6 elements")
+	assert.NoError(t, checkParamsShape(params, 0, 0))
+}
+
+// TestSubmitJobParamsShapeLimits tests that deeply nested or huge params are rejected with 400
+func TestSubmitJobParamsShapeLimits(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.MaxParamsDepth = 8
+	cfg.MaxParamsElements = 1000
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+	submit := func(params string) *ErrorResponse {
+		w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1","params":`+params+`}`))
+		if w.Code == http.StatusOK {
+			return nil
+		}
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return &resp
+	}
+
+	resp := submit(nestedParams(9))
+	require.NotNil(t, resp)
+	assert.Equal(t, "Invalid params", resp.Error)
+	assert.Contains(t, resp.Message, "nested more than 8 levels")
+
+	resp = submit(`{"rows":[` + strings.TrimSuffix(strings.Repeat("0,", 1000), ",") + `]}`)
+	require.NotNil(t, resp)
+	assert.Contains(t, resp.Message, "more than 1000 elements")
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, submit(`{"threshold":0.5,"window":{"start":"2024-01-01","days":[1,2,3]},"deep":`+nestedParams(7)+`}`))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitBatchJobsRejectsDeepParamsItem tests that a batch item with deep params is rejected on its own
+func TestSubmitBatchJobsRejectsDeepParamsItem(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.MaxParamsDepth = 4
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+	expectBatchTx(env, 1)
+
+	body := `{"jobs":[{"scheme":"KBM-WF01","data_id":"d","params":` + nestedParams(5) + `},{"scheme":"KBM-WF02","data_id":"d"}]}`
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch", []byte(body))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Results []BatchItemResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, "REJECTED", resp.Results[0].Status)
+	assert.Contains(t, resp.Results[0].Error, "nested more than 4 levels")
+	assert.Equal(t, "PENDING", resp.Results[1].Status)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/services"
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// PipelineStep is one algorithm run within a pipeline
+// @Description Pipeline step; its input is the previous step's output data_ref
+type PipelineStep struct {
+	Scheme string         `json:"scheme" binding:"required" example:"KBM-WF01"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// SubmitPipelineRequest represents the request body for pipeline submission
+// @Description Ordered list of steps run one after another
+type SubmitPipelineRequest struct {
+	DataID string         `json:"data_id" binding:"required" example:"file:///data/input.csv"`
+	Steps  []PipelineStep `json:"steps" binding:"required,min=1,max=20,dive"`
+	UserID string         `json:"user_id" example:"user-001"`
+}
+
+// SubmitPipeline godoc
+// @Summary      Submit a multi-step pipeline
+// @Description  Creates a parent job and runs its steps in order. Each step starts after the previous one succeeds, using the data_ref from its result as input; the first step reads data_id.
+// @Tags         pipelines
+// @Accept       json
+// @Produce      json
+// @Param        request  body      SubmitPipelineRequest  true  "Pipeline submission request"
+// @Success      200  {object}  map[string]any  "Returns pipeline_id and the first step's job_id"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/pipelines [post]
+func (h *Handler) SubmitPipeline(c *gin.Context) {
+	var req SubmitPipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
+		return
+	}
+	for _, step := range req.Steps {
+		if h.rejectDisallowedScheme(c, step.Scheme) {
+			return
+		}
+	}
+	var ok bool
+	if req.UserID, ok = h.submissionUser(c, req.UserID); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	pipelineID := uuid.NewString()
+	stepsJSON, _ := json.Marshal(req.Steps)
+	if err := h.jobs.CreateJob(ctx, pipelineID, storage.PipelineSchemeCode, req.UserID, req.DataID, string(stepsJSON)); err != nil {
+		respondError(c, apperr.Internal("Failed to create pipeline", err.Error()))
+		return
+	}
+
+	jobID, err := h.startPipelineStep(ctx, pipelineID, req.UserID, req.Steps, 0, req.DataID)
+	if err != nil {
+		_ = h.jobs.FailJob(ctx, pipelineID, fmt.Sprintf("step 0 (%s) failed to start: %v", req.Steps[0].Scheme, err))
+		if !h.rejectSchemeAtCapacity(c, req.Steps[0].Scheme, err) {
+			respondError(c, apperr.Internal("Failed to submit pipeline", err.Error()))
+		}
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"pipeline_id": pipelineID,
+		"job_id":      jobID,
+		"steps":       len(req.Steps),
+		"status":      "RUNNING",
+	})
+}
+
+// GetPipeline godoc
+// @Summary      Get pipeline by ID
+// @Description  Returns the parent pipeline job and the child jobs of the steps started so far
+// @Tags         pipelines
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Pipeline ID"
+// @Success      200  {object}  map[string]any
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/pipelines/{id} [get]
+func (h *Handler) GetPipeline(c *gin.Context) {
+	pipelineID := c.Param("id")
+	parent, err := h.store.GetJobTyped(c.Request.Context(), pipelineID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apperr.Internal("Failed to get pipeline", err.Error()))
+		return
+	}
+	if err != nil || parent.SchemeCode != storage.PipelineSchemeCode {
+		respondError(c, apperr.NotFound("Pipeline not found", "no pipeline with id "+pipelineID))
+		return
+	}
+	children, err := h.store.ListChildJobs(c.Request.Context(), pipelineID)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to load pipeline steps", err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"pipeline": parent, "steps": children})
+}
+
+// startPipelineStep creates the child job for steps[index] and submits it
+func (h *Handler) startPipelineStep(ctx context.Context, pipelineID, userID string, steps []PipelineStep, index int, dataRef string) (string, error) {
+	step := steps[index]
+	jobID := uuid.NewString()
+	paramsJSON, _ := json.Marshal(step.Params)
+	if err := h.jobs.CreateChildJob(ctx, jobID, pipelineID, index, step.Scheme, userID, dataRef, string(paramsJSON)); err != nil {
+		return "", err
+	}
+
+	_ = h.jobs.UpdateProgress(ctx, models.ProgressMsg{
+		TaskID:     pipelineID,
+		Percentage: int32(index * 100 / len(steps)),
+		Message:    fmt.Sprintf("step %d/%d: %s", index+1, len(steps), step.Scheme),
+		Timestamp:  time.Now().UnixMilli(),
+	})
+
+	if err := h.submitToAlgo(ctx, services.QueuedJob{JobID: jobID, SchemeCode: step.Scheme, DataRef: dataRef, Params: step.Params}); err != nil {
+		return "", err
+	}
+	return jobID, nil
+}
+
+// advancePipeline runs whenever a job reaches a terminal status: it starts the
+// next step when a pipeline step succeeds and settles the parent job when the
+// pipeline ends, including when a step is cancelled or failed by the scheduler
+func (h *Handler) advancePipeline(ctx context.Context, jobID string, success bool, detail string) {
+	pipelineID, index, err := h.store.GetParentJob(ctx, jobID)
+	if err != nil || pipelineID == "" {
+		return
+	}
+	parent, err := h.store.GetJobTyped(ctx, pipelineID)
+	if err != nil || parent.Status == "SUCCESS" || parent.Status == "FAILED" || parent.Status == "CANCELLED" {
+		return
+	}
+
+	if !success {
+		_ = h.jobs.FailJob(ctx, pipelineID, fmt.Sprintf("step %d failed: %s", index, detail))
+		return
+	}
+
+	var steps []PipelineStep
+	if err := json.Unmarshal([]byte(parent.Params), &steps); err != nil {
+		_ = h.jobs.FailJob(ctx, pipelineID, "invalid pipeline definition: "+err.Error())
+		return
+	}
+	next := index + 1
+	if next >= len(steps) {
+		_ = h.jobs.FinishJob(ctx, pipelineID, detail)
+		return
+	}
+
+	dataRef := outputDataRef(detail)
+	if dataRef == "" {
+		_ = h.jobs.FailJob(ctx, pipelineID, fmt.Sprintf("step %d result has no data_ref for step %d", index, next))
+		return
+	}
+	if _, err := h.startPipelineStep(ctx, pipelineID, parent.UserID, steps, next, dataRef); err != nil {
+		_ = h.jobs.FailJob(ctx, pipelineID, fmt.Sprintf("step %d (%s) failed to start: %v", next, steps[next].Scheme, err))
+	}
+}
+
+// outputDataRef extracts the top-level data_ref a step reports in its result
+func outputDataRef(resultJSON string) string {
+	var result struct {
+		DataRef string `json:"data_ref"`
+	}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return ""
+	}
+	return result.DataRef
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/grpcserver"
+	"github.com/electric-power/backend-service/internal/storage"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+const testPipelineBody = `{"data_id":"file:///in.csv","user_id":"u1","steps":[{"scheme":"KBM-WF01"},{"scheme":"KBM-WF02","params":{"k":1}}]}`
+
+// startTestPipeline submits testPipelineBody and returns the pipeline ID and
+// the channel on which the fake algorithm service reports submissions
+func startTestPipeline(t *testing.T, env *testEnv) (string, chan *pb.TaskRequest) {
+	t.Helper()
+	submitted := make(chan *pb.TaskRequest, 4)
+	env.withAlgo(t, &fakeAlgo{submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		submitted <- req
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs(sqlmock.AnyArg(), "PIPELINE", "u1", "file:///in.csv", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs \(.+parent_job_id, step_index`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", "u1", "file:///in.csv", "{}", sqlmock.AnyArg(), 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+		WithArgs(0, sqlmock.AnyArg(), 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupTestRouter()
+	r.POST("/api/v1/pipelines", env.handler.SubmitPipeline)
+	w := env.do(r, "POST", "/api/v1/pipelines", []byte(testPipelineBody))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(2), resp["steps"])
+	return resp["pipeline_id"].(string), submitted
+}
+
+// expectResultCallback registers the queries ReportResult makes for a running job
+func (e *testEnv) expectResultCallback(jobID string) {
+	e.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	e.db.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "status"}).AddRow(jobID, "RUNNING"))
+}
+
+// expectPipelineStep registers the lookups advancePipeline makes for a finished step
+func (e *testEnv) expectPipelineStep(jobID, pipelineID string, index int) {
+	e.db.ExpectQuery(`SELECT parent_job_id, step_index FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_job_id", "step_index"}).AddRow(pipelineID, index))
+	e.expectGetJob(jobRow{
+		JobID:      pipelineID,
+		SchemeCode: "PIPELINE",
+		UserID:     "u1",
+		Status:     "RUNNING",
+		Params:     `[{"scheme":"KBM-WF01"},{"scheme":"KBM-WF02","params":{"k":1}}]`,
+	})
+}
+
+// TestPipelineDispatchesNextStepAfterSuccess tests that step 2 starts only once step 1 reports success
+func TestPipelineDispatchesNextStepAfterSuccess(t *testing.T) {
+	env := newTestEnv(t)
+	pipelineID, submitted := startTestPipeline(t, env)
+
+	first := <-submitted
+	assert.Equal(t, "KBM-WF01", first.SchemeCode)
+	assert.Equal(t, "file:///in.csv", first.DataRef)
+
+	// Nothing else is submitted while the first step runs
+	select {
+	case req := <-submitted:
+		t.Fatalf("unexpected submission of %s before step 1 finished", req.SchemeCode)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	results := grpcserver.NewResultServer(env.handler.jobs)
+
+	env.expectResultCallback(first.TaskId)
+	env.expectFinishJob(first.TaskId, `{"data_ref":"file:///out-1.csv"}`)
+	env.expectPipelineStep(first.TaskId, pipelineID, 0)
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs \(.+parent_job_id, step_index`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF02", "u1", "file:///out-1.csv", `{"k":1}`, pipelineID, 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).
+		WithArgs(50, sqlmock.AnyArg(), 50, sqlmock.AnyArg(), pipelineID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := results.ReportResult(context.Background(), &pb.TaskResult{
+		TaskId: first.TaskId, Status: pb.TaskResult_SUCCESS, ResultJson: `{"data_ref":"file:///out-1.csv"}`,
+	})
+	require.NoError(t, err)
+
+	var second *pb.TaskRequest
+	select {
+	case second = <-submitted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second step was not dispatched")
+	}
+	assert.Equal(t, "KBM-WF02", second.SchemeCode)
+	assert.Equal(t, "file:///out-1.csv", second.DataRef)
+
+	// The last step's result completes the pipeline
+	env.expectResultCallback(second.TaskId)
+	env.expectFinishJob(second.TaskId, `{"score":0.9}`)
+	env.expectPipelineStep(second.TaskId, pipelineID, 1)
+	env.expectFinishJob(pipelineID, `{"score":0.9}`)
+
+	_, err = results.ReportResult(context.Background(), &pb.TaskResult{
+		TaskId: second.TaskId, Status: pb.TaskResult_SUCCESS, ResultJson: `{"score":0.9}`,
+	})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, 2*time.Second, 10*time.Millisecond)
+	assert.Empty(t, submitted)
+}
+
+// TestPipelineStopsWhenStepFails tests that a failed step fails the pipeline without dispatching further steps
+func TestPipelineStopsWhenStepFails(t *testing.T) {
+	env := newTestEnv(t)
+	pipelineID, submitted := startTestPipeline(t, env)
+	first := <-submitted
+
+	env.expectResultCallback(first.TaskId)
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs("boom", sqlmock.AnyArg(), sqlmock.AnyArg(), first.TaskId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.expectPipelineStep(first.TaskId, pipelineID, 0)
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs("step 0 failed: boom", sqlmock.AnyArg(), sqlmock.AnyArg(), pipelineID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := grpcserver.NewResultServer(env.handler.jobs).ReportResult(context.Background(), &pb.TaskResult{
+		TaskId: first.TaskId, Status: pb.TaskResult_FAILED, ErrorMessage: "boom",
+	})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, 2*time.Second, 10*time.Millisecond)
+	assert.Empty(t, submitted)
+}
+
+// TestPipelineFailsWhenStepReapedAsZombie tests that a step failed by the zombie
+// scan fails the pipeline, which the scan itself never visits
+func TestPipelineFailsWhenStepReapedAsZombie(t *testing.T) {
+	env := newTestEnv(t)
+	pipelineID, submitted := startTestPipeline(t, env)
+	first := <-submitted
+
+	env.expectPipelineStep(first.TaskId, pipelineID, 0)
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs("step 0 failed: "+storage.ZombieErrorLog, sqlmock.AnyArg(), sqlmock.AnyArg(), pipelineID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	env.handler.jobs.ZombiesFailed([]string{first.TaskId})
+	assert.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, 2*time.Second, 10*time.Millisecond)
+	assert.Empty(t, submitted)
+}
+
+// TestSubmitPipelineValidation tests request validation
+func TestSubmitPipelineValidation(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.POST("/api/v1/pipelines", env.handler.SubmitPipeline)
+
+	w := env.do(r, "POST", "/api/v1/pipelines", []byte(`{"data_id":"d","steps":[]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = env.do(r, "POST", "/api/v1/pipelines", []byte(`{"data_id":"d","steps":[{"params":{}}]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
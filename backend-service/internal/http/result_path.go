@@ -0,0 +1,101 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxResultPathDepth bounds the number of segments in a result path
+const maxResultPathDepth = 32
+
+// resultPathSegmentRe matches one segment of a result path: an object key or an array index
+var resultPathSegmentRe = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// parseResultPath splits a dot-separated result path such as "summary.metrics"
+// or "rows.0.value" into segments
+func parseResultPath(path string) ([]string, error) {
+	segments := strings.Split(path, ".")
+	if len(segments) > maxResultPathDepth {
+		return nil, fmt.Errorf("path has more than %d segments", maxResultPathDepth)
+	}
+	for _, seg := range segments {
+		if !resultPathSegmentRe.MatchString(seg) {
+			return nil, fmt.Errorf("invalid path segment %q: use dot-separated keys and array indices, e.g. summary.metrics or rows.0", seg)
+		}
+	}
+	return segments, nil
+}
+
+// lookupResultPath walks segments through a decoded JSON value. Numeric
+// segments index arrays; ok is false when any segment does not exist.
+func lookupResultPath(v any, segments []string) (any, bool) {
+	for _, seg := range segments {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// filterResultPaths returns the parts of result at the allowed dot-separated
+// paths, nested as they are in result. Paths only descend objects and the
+// value at each path is kept whole; paths missing from result are skipped.
+func filterResultPaths(result any, allowed []string) map[string]any {
+	out := map[string]any{}
+	for _, path := range allowed {
+		segments := strings.Split(path, ".")
+		value, ok := lookupObjectPath(result, segments)
+		if !ok {
+			continue
+		}
+		dst := out
+		for _, seg := range segments[:len(segments)-1] {
+			next, ok := dst[seg].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				dst[seg] = next
+			}
+			dst = next
+		}
+		dst[segments[len(segments)-1]] = value
+	}
+	return out
+}
+
+// filterResultJSON is filterResultPaths for a result still encoded as JSON
+func filterResultJSON(result string, allowed []string) []byte {
+	var decoded any
+	_ = json.Unmarshal([]byte(result), &decoded)
+	filtered, _ := json.Marshal(filterResultPaths(decoded, allowed))
+	return filtered
+}
+
+// lookupObjectPath is lookupResultPath restricted to object keys
+func lookupObjectPath(v any, segments []string) (any, bool) {
+	for _, seg := range segments {
+		node, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = node[seg]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/middleware"
+)
+
+const allowlistedResult = `{"summary":{"score":0.9,"debug":{"trace":[1,2]}},"metrics":{"rmse":0.12,"raw":[3,4]},"intermediate":{"weights":[0.1,0.2]}}`
+
+// getResult fetches a job result through the full router, as admin when admin is set
+func getResult(t *testing.T, r http.Handler, path string, admin bool) map[string]any {
+	t.Helper()
+	req := newJSONRequest("GET", path, "")
+	if admin {
+		req.Header.Set(middleware.AdminAPIKeyHeader, "admin-secret")
+	}
+	w := serve(r, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+// TestGetJobResultAllowlist tests that non-admins only see the allowlisted
+// result paths of the job's scheme while admins see the whole result
+func TestGetJobResultAllowlist(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ResultPathAllowlist = map[string][]string{
+		"KBM-WF01": {"summary.score", "metrics.rmse", "missing.path"},
+		"*":        {"summary"},
+	}
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+	env.expectGetResult("job-1", allowlistedResult)
+	resp := getResult(t, r, "/api/v1/jobs/job-1/result", false)
+	assert.Equal(t, map[string]any{
+		"summary": map[string]any{"score": 0.9},
+		"metrics": map[string]any{"rmse": 0.12},
+	}, resp["result"])
+
+	// Projections only reach allowlisted data
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+	env.expectGetResult("job-1", allowlistedResult)
+	w := serve(r, newJSONRequest("GET", "/api/v1/jobs/job-1/result?path=intermediate.weights", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Other schemes fall back to the "*" entry
+	env.expectGetJob(jobRow{JobID: "job-2", SchemeCode: "SCM-WF01", Status: "SUCCESS", Result: allowlistedResult})
+	env.expectGetResult("job-2", allowlistedResult)
+	resp = getResult(t, r, "/api/v1/jobs/job-2/result", false)
+	assert.Equal(t, map[string]any{
+		"summary": map[string]any{"score": 0.9, "debug": map[string]any{"trace": []any{float64(1), float64(2)}}},
+	}, resp["result"])
+
+	// The result embedded in the job is filtered the same way
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+	resp = getResult(t, r, "/api/v1/jobs/job-1", false)
+	job := resp["job"].(map[string]any)
+	assert.JSONEq(t, `{"summary":{"score":0.9},"metrics":{"rmse":0.12}}`, job["result_summary"].(string))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetJobResultAllowlistAdmin tests that admins get the full result
+func TestGetJobResultAllowlistAdmin(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ResultPathAllowlist = map[string][]string{"*": {"summary.score"}}
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	var full any
+	require.NoError(t, json.Unmarshal([]byte(allowlistedResult), &full))
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+	env.expectGetResult("job-1", allowlistedResult)
+	resp := getResult(t, r, "/api/v1/jobs/job-1/result", true)
+	assert.Equal(t, full, resp["result"])
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+	env.expectGetResult("job-1", allowlistedResult)
+	resp = getResult(t, r, "/api/v1/jobs/job-1/result?path=intermediate.weights", true)
+	assert.Equal(t, []any{0.1, 0.2}, resp["result"])
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+	resp = getResult(t, r, "/api/v1/jobs/job-1", true)
+	assert.JSONEq(t, allowlistedResult, resp["job"].(map[string]any)["result_summary"].(string))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestResultAllowlistByAlgoTask tests that the job looked up by algorithm
+// task ID embeds its result filtered like GetJob does
+func TestResultAllowlistByAlgoTask(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ResultPathAllowlist = map[string][]string{"KBM-WF01": {"summary.score"}}
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	for _, admin := range []bool{false, true} {
+		env.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+			WithArgs("algo-7").
+			WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+		env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS", Result: allowlistedResult})
+		resp := getResult(t, r, "/api/v1/jobs/by-algo-task/algo-7", admin)
+		summary := resp["job"].(map[string]any)["result_summary"].(string)
+		if admin {
+			assert.JSONEq(t, allowlistedResult, summary)
+		} else {
+			assert.JSONEq(t, `{"summary":{"score":0.9}}`, summary)
+		}
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// readTerminalResult subscribes to a finished job over /ws and returns the
+// result of its terminal frame
+func readTerminalResult(t *testing.T, addr, jobID string, header http.Header) string {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+jobID, header)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var frame struct {
+		Type   string          `json:"type"`
+		Result json.RawMessage `json:"result"`
+	}
+	require.NoError(t, conn.ReadJSON(&frame))
+	require.Equal(t, "terminal", frame.Type)
+	return string(frame.Result)
+}
+
+// TestResultAllowlistWebSocketTerminal tests that the terminal frame of a
+// finished job, sent by the /ws check or by the hub's replay, carries only
+// the allowlisted result paths unless the subscriber is an admin
+func TestResultAllowlistWebSocketTerminal(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ResultPathAllowlist = map[string][]string{"KBM-WF01": {"summary.score", "metrics.rmse"}}
+	env := newTestEnvWithConfig(t, cfg)
+	routerCfg := DefaultRouterConfig()
+	routerCfg.AdminAPIKey = "admin-secret"
+	addr := startTestServer(t, env, routerCfg, nil)
+	const filtered = `{"summary":{"score":0.9},"metrics":{"rmse":0.12}}`
+
+	env.expectGetJob(jobRow{JobID: doneJobID, Status: "SUCCESS", Progress: 100})
+	env.expectGetResult(doneJobID, allowlistedResult)
+	assert.JSONEq(t, filtered, readTerminalResult(t, addr, doneJobID, nil))
+
+	env.expectGetJob(jobRow{JobID: doneJobID, Status: "SUCCESS", Progress: 100})
+	env.expectGetResult(doneJobID, allowlistedResult)
+	admin := http.Header{middleware.AdminAPIKeyHeader: {"admin-secret"}}
+	assert.JSONEq(t, allowlistedResult, readTerminalResult(t, addr, doneJobID, admin))
+
+	// A job finishing just after the check is replayed by the hub, filtered
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "RUNNING", Progress: 90})
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "SUCCESS", Progress: 100})
+	env.expectGetResult(streamJobID, allowlistedResult)
+	assert.JSONEq(t, filtered, readTerminalResult(t, addr, streamJobID, nil))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
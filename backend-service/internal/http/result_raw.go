@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// GetJobRawResult godoc
+// @Summary      Get the raw job result
+// @Description  Returns a completed job's result exactly as stored, without parsing and re-serializing it,
+// @Description  so key order and number precision are preserved. Results are read from t_job_results, or
+// @Description  from t_algo_jobs.result_summary for jobs finished before results moved there. Non-admin
+// @Description  callers whose scheme has a result path allowlist get 403, as the raw result cannot be filtered.
+// @Tags         jobs
+// @Produce      json
+// @Param        id   path      string  true  "Job ID"
+// @Success      200  {object}  map[string]any
+// @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/{id}/result/raw [get]
+func (h *Handler) GetJobRawResult(c *gin.Context) {
+	jobID := c.Param("id")
+	job, err := h.store.GetJobTyped(c.Request.Context(), jobID)
+	if err != nil {
+		respondError(c, jobLookupError(jobID, err))
+		return
+	}
+	if job.Status != "SUCCESS" {
+		respondError(c, apperr.Conflict("Job not completed", "Job status is "+job.Status))
+		return
+	}
+	if h.resultAllowlist(c, job.SchemeCode) != nil {
+		respondError(c, apperr.Forbidden(
+			"Raw result not available",
+			"only allowlisted result paths are visible for this scheme; use /result instead",
+		))
+		return
+	}
+
+	resultJSON, err := h.store.GetResult(c.Request.Context(), jobID)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to load result", err.Error()))
+		return
+	}
+	if resultJSON == "" {
+		respondError(c, apperr.NotFound("Result not found", "job has no stored result"))
+		return
+	}
+	c.DataFromReader(http.StatusOK, int64(len(resultJSON)), "application/json", strings.NewReader(resultJSON), nil)
+}
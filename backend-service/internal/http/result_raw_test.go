@@ -0,0 +1,70 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/electric-power/backend-service/internal/middleware"
+)
+
+// rawResult would lose its key order, spacing and number precision in a
+// decode/encode round trip
+const rawResult = `{"z":1, "a":0.12345678901234567890,"big":12345678901234567890,"s":"<é>"}`
+
+// TestGetJobRawResult tests that the stored result is returned byte for byte
+func TestGetJobRawResult(t *testing.T) {
+	env := newTestEnv(t)
+	r := newAdminTestRouter(env)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS"})
+	env.expectGetResult("job-1", rawResult)
+	w := serve(r, newJSONRequest("GET", "/api/v1/jobs/job-1/result/raw", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, rawResult, w.Body.String())
+
+	// Results of older jobs are read from the job row
+	env.expectGetJob(jobRow{JobID: "job-2", Status: "SUCCESS"})
+	env.db.ExpectQuery(`SELECT result_json FROM t_job_results WHERE job_id = \?`).
+		WithArgs("job-2").WillReturnRows(sqlmock.NewRows([]string{"result_json"}))
+	env.db.ExpectQuery(`SELECT COALESCE\(result_summary, ''\) FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-2").WillReturnRows(sqlmock.NewRows([]string{"result_summary"}).AddRow(rawResult))
+	w = serve(r, newJSONRequest("GET", "/api/v1/jobs/job-2/result/raw", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, rawResult, w.Body.String())
+
+	env.expectGetJob(jobRow{JobID: "job-3", Status: "RUNNING"})
+	w = serve(r, newJSONRequest("GET", "/api/v1/jobs/job-3/result/raw", ""))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	env.expectGetJob(jobRow{JobID: "job-4", Status: "SUCCESS"})
+	env.expectGetResult("job-4", "")
+	w = serve(r, newJSONRequest("GET", "/api/v1/jobs/job-4/result/raw", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetJobRawResultAllowlist tests that only admins get the raw result of
+// a scheme with a result path allowlist
+func TestGetJobRawResultAllowlist(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ResultPathAllowlist = map[string][]string{"KBM-WF01": {"summary"}}
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS"})
+	w := serve(r, newJSONRequest("GET", "/api/v1/jobs/job-1/result/raw", ""))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	env.expectGetJob(jobRow{JobID: "job-1", Status: "SUCCESS"})
+	env.expectGetResult("job-1", rawResult)
+	req := newJSONRequest("GET", "/api/v1/jobs/job-1/result/raw", "")
+	req.Header.Set(middleware.AdminAPIKeyHeader, "admin-secret")
+	w = serve(r, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, rawResult, w.Body.String())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
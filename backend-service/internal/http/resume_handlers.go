@@ -0,0 +1,96 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// resumeFromParam is the param carrying the checkpoint a resumed job starts from
+const resumeFromParam = "resume_from"
+
+// ResumeJob godoc
+// @Summary      Resume a job from its checkpoint
+// @Description  Re-dispatches a FAILED or CANCELLED job as a new job whose params carry resume_from, the
+// @Description  last checkpoint the original run reported. Only schemes with supports_checkpoint can resume.
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Job ID"
+// @Success      200  {object}  map[string]any  "Returns the new job_id, resumed_from and resume_from"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
+// @Router       /api/v1/jobs/{id}/resume [post]
+func (h *Handler) ResumeJob(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx := c.Request.Context()
+
+	job, err := h.store.GetJobTyped(ctx, jobID)
+	if err != nil {
+		respondError(c, jobLookupError(jobID, err))
+		return
+	}
+	if job.Status != "FAILED" && job.Status != "CANCELLED" {
+		respondError(c, apperr.Validation("Cannot resume job", fmt.Sprintf("only FAILED or CANCELLED jobs can resume; job is %s", job.Status)))
+		return
+	}
+
+	schemes, err := h.visibleSchemes(ctx)
+	if err != nil {
+		respondError(c, apperr.Upstream("Failed to get schemes", err.Error()))
+		return
+	}
+	if !supportsCheckpoint(schemes, job.SchemeCode) {
+		respondError(c, apperr.Validation("Scheme not resumable", fmt.Sprintf("scheme %s does not support checkpoints", job.SchemeCode)))
+		return
+	}
+
+	checkpoint, err := h.store.GetCheckpointRef(ctx, jobID)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to load checkpoint", err.Error()))
+		return
+	}
+	if checkpoint == "" {
+		respondError(c, apperr.Validation("No checkpoint", "job has not reported a checkpoint"))
+		return
+	}
+
+	params := map[string]any{}
+	_ = json.Unmarshal([]byte(models.NormalizeParams(string(job.Params))), &params)
+	params[resumeFromParam] = checkpoint
+
+	newJobID, ok := h.dispatchJob(c, SubmitJobRequest{
+		Scheme:   job.SchemeCode,
+		DataID:   job.DataRef,
+		Params:   params,
+		UserID:   job.UserID,
+		Metadata: job.Metadata,
+	})
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, gin.H{
+		"job_id":       newJobID,
+		"status":       "PENDING",
+		"resumed_from": jobID,
+		"resume_from":  checkpoint,
+	})
+}
+
+// supportsCheckpoint reports whether the scheme with the given code can resume
+func supportsCheckpoint(schemes []models.Scheme, code string) bool {
+	for _, s := range schemes {
+		if s.Code == code {
+			return s.SupportsCheckpoint
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// expectCheckpoint registers the checkpoint lookup for a job
+func (e *testEnv) expectCheckpoint(jobID string, ref any) {
+	e.db.ExpectQuery(`SELECT checkpoint_ref FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"checkpoint_ref"}).AddRow(ref))
+}
+
+// TestResumeJobFromCheckpoint tests that a failed job of a resumable scheme is re-dispatched with resume_from
+func TestResumeJobFromCheckpoint(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedSchemes(t, models.Scheme{Code: "STM-WF01", SupportsCheckpoint: true})
+	submitted := make(chan *pb.TaskRequest, 1)
+	env.withAlgo(t, &fakeAlgo{submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+		submitted <- req
+		return &pb.TaskSubmissionResponse{Accepted: true}, nil
+	}})
+
+	env.expectGetJob(jobRow{JobID: "job-1", SchemeCode: "STM-WF01", UserID: "u1", Status: "FAILED", Params: `{"epochs":50}`})
+	env.expectCheckpoint("job-1", "s3://ckpt/job-1/epoch-30")
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs(sqlmock.AnyArg(), "STM-WF01", "u1", "data-1", `{"epochs":50,"resume_from":"s3://ckpt/job-1/epoch-30"}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/:id/resume", env.handler.ResumeJob)
+	w := env.do(r, "POST", "/api/v1/jobs/job-1/resume", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "job-1", resp["resumed_from"])
+	assert.Equal(t, "s3://ckpt/job-1/epoch-30", resp["resume_from"])
+	assert.NotEqual(t, "job-1", resp["job_id"])
+
+	req := <-submitted
+	assert.Equal(t, resp["job_id"], req.TaskId)
+	assert.JSONEq(t, `{"epochs":50,"resume_from":"s3://ckpt/job-1/epoch-30"}`, req.ParamsJson)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestResumeJobRejected tests non-resumable schemes, jobs without a checkpoint and jobs that did not fail
+func TestResumeJobRejected(t *testing.T) {
+	env := newTestEnv(t)
+	env.seedSchemes(t,
+		models.Scheme{Code: "KBM-WF01"},
+		models.Scheme{Code: "STM-WF01", SupportsCheckpoint: true},
+	)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/:id/resume", env.handler.ResumeJob)
+
+	env.expectGetJob(jobRow{JobID: "job-1", SchemeCode: "KBM-WF01", Status: "FAILED"})
+	w := env.do(r, "POST", "/api/v1/jobs/job-1/resume", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Scheme not resumable")
+
+	env.expectGetJob(jobRow{JobID: "job-2", SchemeCode: "STM-WF01", Status: "CANCELLED"})
+	env.expectCheckpoint("job-2", nil)
+	w = env.do(r, "POST", "/api/v1/jobs/job-2/resume", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "No checkpoint")
+
+	env.expectGetJob(jobRow{JobID: "job-3", SchemeCode: "STM-WF01", Status: "RUNNING"})
+	w = env.do(r, "POST", "/api/v1/jobs/job-3/resume", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Cannot resume job")
+
+	env.expectJobNotFound("job-4")
+	w = env.do(r, "POST", "/api/v1/jobs/job-4/resume", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -4,12 +4,16 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/electric-power/backend-service/internal/apperr"
 	"github.com/electric-power/backend-service/internal/middleware"
 	"github.com/electric-power/backend-service/internal/storage"
 	"github.com/electric-power/backend-service/internal/ws"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
@@ -20,6 +24,65 @@ type RouterConfig struct {
 	EnableSwagger bool
 	RateLimitRPS  int
 	RequestTimeout time.Duration
+
+	// RateLimitTiers caps requests per minute by tier name, and
+	// RateLimitClientTiers assigns authenticated users or IPs to tiers; other
+	// clients get RateLimitRPS
+	RateLimitTiers       map[string]int
+	RateLimitClientTiers map[string]string
+	// RateLimitKeyNS namespaces the rate limit counters in Redis (default
+	// middleware.RateLimitKeyNS)
+	RateLimitKeyNS string
+	// RateLimitRoutes additionally limits each client per /api/v1 route
+	// pattern (see middleware.RouteRateLimitConfig)
+	RateLimitRoutes map[string]middleware.RouteRateLimit
+
+	// MaxInFlightRequests sheds load with 503 once this many requests are
+	// being handled; health, metrics and admin endpoints are exempt (0 disables)
+	MaxInFlightRequests int
+
+	// WSHandshakeTimeout bounds how long a client may take to complete the
+	// WebSocket upgrade, from the first request byte to the 101 response
+	WSHandshakeTimeout time.Duration
+
+	// LogRequestBody logs (redacted, capped) bodies of failed job requests
+	LogRequestBody         bool
+	LogRequestBodyMaxBytes int
+
+	// ResponseEnvelope wraps responses in {data, error, meta} unless the
+	// client sends X-Response-Envelope: false
+	ResponseEnvelope bool
+
+	// AdminAPIKey guards admin-only endpoints such as /ws/system; empty
+	// disables them
+	AdminAPIKey string
+
+	// JWTSecret enables HS256 Bearer token auth on /api/v1, with cancel and
+	// stats restricted to the admin role; empty leaves the API open
+	JWTSecret string
+	// JWTIssuer, if set, is the only accepted token issuer
+	JWTIssuer string
+
+	// TLSCertFile and TLSKeyFile serve HTTPS directly; plaintext when either is empty
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// WSAllowedOrigins lists the browser origins allowed to open WebSockets
+	// ("*" allows any); empty allows only the server's own host
+	WSAllowedOrigins []string
+
+	// IdempotencyHeader carries the client's idempotency key on job
+	// submissions (default X-Request-ID)
+	IdempotencyHeader string
+	// IdempotencyTTL is how long a submission's response is replayed to
+	// duplicates (default middleware.IdempotencyTTL)
+	IdempotencyTTL time.Duration
+	// IdempotencyClaimTTL is how long a key is held by a submission still
+	// being processed (default middleware.IdempotencyClaimTTL)
+	IdempotencyClaimTTL time.Duration
+	// IdempotencyKeyNS namespaces idempotency keys in Redis (default
+	// middleware.IdempotencyKeyNS)
+	IdempotencyKeyNS string
 }
 
 // DefaultRouterConfig returns default router configuration
@@ -28,7 +91,30 @@ func DefaultRouterConfig() RouterConfig {
 		EnableSwagger:  true,
 		RateLimitRPS:   100,
 		RequestTimeout: 30 * time.Second,
+
+		WSHandshakeTimeout: 10 * time.Second,
+
+		IdempotencyHeader:   middleware.IdempotencyHeader,
+		IdempotencyTTL:      middleware.IdempotencyTTL,
+		IdempotencyClaimTTL: middleware.IdempotencyClaimTTL,
+		IdempotencyKeyNS:    middleware.IdempotencyKeyNS,
+		RateLimitKeyNS:      middleware.RateLimitKeyNS,
+	}
+}
+
+// NewServer wraps the router in an http.Server whose header read deadline
+// matches the WebSocket handshake timeout, so stalled upgrades are dropped
+// at the connection level before they ever reach a handler
+func NewServer(addr string, handler http.Handler, cfg RouterConfig) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.WSHandshakeTimeout,
 	}
+	if cfg.TLSEnabled() {
+		srv.TLSConfig = newTLSConfig()
+	}
+	return srv
 }
 
 // NewRouter creates a new Gin router with all routes configured
@@ -40,33 +126,75 @@ func NewRouter(handler *Handler, hub *ws.Hub) *gin.Engine {
 func NewRouterWithConfig(handler *Handler, hub *ws.Hub, cache *storage.RedisCache, logger *zap.Logger, cfg RouterConfig) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
+	r.HandleMethodNotAllowed = true
+
+	// Unmatched routes answer with the same JSON error shape as the handlers
+	r.NoRoute(func(c *gin.Context) {
+		respondError(c, apperr.NotFound("NOT_FOUND", "No route for "+c.Request.Method+" "+c.Request.URL.Path))
+	})
+	r.NoMethod(func(c *gin.Context) {
+		respondError(c, apperr.MethodNotAllowed("METHOD_NOT_ALLOWED", "Method "+c.Request.Method+" is not allowed for "+c.Request.URL.Path))
+	})
 
 	// Recovery middleware
 	r.Use(gin.Recovery())
 
 	// Custom middleware
-	r.Use(middleware.CORS())
+	r.Use(middleware.CORS(cfg.IdempotencyHeader))
 	r.Use(middleware.RequestID())
+	r.Use(responseEnvelope(cfg.ResponseEnvelope))
 
 	if logger != nil {
 		r.Use(middleware.StructuredLogger(logger))
 	} else {
-		r.Use(gin.Logger())
+		r.Use(middleware.PlainLogger())
 	}
 
-	// Rate limiting for all API routes
-	if cache != nil && cfg.RateLimitRPS > 0 {
-		r.Use(middleware.RateLimiter(cache, cfg.RateLimitRPS, time.Minute))
+	// Load shedding, keeping monitoring and admin endpoints reachable under overload
+	if cfg.MaxInFlightRequests > 0 {
+		r.Use(middleware.LoadShedder(cfg.MaxInFlightRequests, middleware.DefaultShedExemptPaths))
 	}
 
 	// Swagger documentation
 	if cfg.EnableSwagger {
 		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	} else {
+		r.GET("/swagger/*any", func(c *gin.Context) {
+			respondError(c, apperr.NotFound("SWAGGER_DISABLED", "API documentation is disabled on this server; set ENABLE_SWAGGER=true to enable it"))
+		})
 	}
 
 	// Health check endpoint (no auth required)
 	r.GET("/health", handler.HealthCheck)
 
+	// Prometheus metrics, backed by the same stats collector as /api/v1/system/stats
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		handler.StatsCollector(),
+		handler.jobs.ResultSizeCollector(),
+		handler.jobs.SLACollector(),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	r.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
+	// Body logging for failed job submissions, opt-in for debugging
+	var jobMiddleware []gin.HandlerFunc
+	if cfg.LogRequestBody && logger != nil {
+		jobMiddleware = append(jobMiddleware, middleware.BodyLogger(logger, middleware.BodyLogConfig{
+			MaxBytes: cfg.LogRequestBodyMaxBytes,
+		}))
+	}
+
+	// Job submissions are deduplicated on the client's idempotency key only
+	idempotent := middleware.IdempotencyWithConfig(cache, middleware.IdempotencyConfig{
+		Header:      cfg.IdempotencyHeader,
+		ResponseTTL: cfg.IdempotencyTTL,
+		ClaimTTL:    cfg.IdempotencyClaimTTL,
+		Logger:      logger,
+		KeyNS:       cfg.IdempotencyKeyNS,
+	})
+
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
@@ -75,6 +203,48 @@ func NewRouterWithConfig(handler *Handler, hub *ws.Hub, cache *storage.RedisCach
 			v1.Use(middleware.Timeout(cfg.RequestTimeout))
 		}
 
+		// Bearer token auth; admin routes also accept the admin API key instead
+		if cfg.JWTSecret != "" {
+			v1.Use(middleware.JWTAuth(cfg.JWTSecret,
+				middleware.WithIssuer(cfg.JWTIssuer),
+				middleware.WithLeeway(30*time.Second),
+				middleware.WithAnonymousPaths("/api/v1/admin/"),
+			))
+		}
+
+		// Rate limiting for all API routes, counted and tiered by the authenticated user
+		if cache != nil && (cfg.RateLimitRPS > 0 || len(cfg.RateLimitTiers) > 0) {
+			limits := middleware.TieredRateLimits(cfg.RateLimitRPS, cfg.RateLimitTiers, cfg.RateLimitClientTiers)
+			v1.Use(middleware.RateLimiterWithConfig(cache, middleware.RateLimitConfig{
+				Resolve: limits,
+				Window:  time.Minute,
+				Logger:  logger,
+				KeyNS:   cfg.RateLimitKeyNS,
+			}))
+		}
+
+		// Per-route limits count against the authenticated user
+		if cache != nil && len(cfg.RateLimitRoutes) > 0 {
+			v1.Use(middleware.RouteRateLimiter(cache, middleware.RouteRateLimitConfig{
+				Rules:  cfg.RateLimitRoutes,
+				Logger: logger,
+				KeyNS:  cfg.RateLimitKeyNS,
+			}))
+		}
+
+		// Routing submissions to an alternate algorithm service is for admins
+		v1.Use(middleware.AdminOnlyHeader(AlgoTargetHeader, cfg.AdminAPIKey))
+		// Handlers such as the job result hide data from non-admins
+		v1.Use(middleware.MarkAdmin(cfg.AdminAPIKey))
+
+		// adminOnly requires the admin role when token auth is enabled
+		adminOnly := func(h gin.HandlerFunc) []gin.HandlerFunc {
+			if cfg.JWTSecret == "" {
+				return []gin.HandlerFunc{h}
+			}
+			return []gin.HandlerFunc{middleware.RequireRole(middleware.RoleAdmin), h}
+		}
+
 		// Algorithm schemes
 		algorithms := v1.Group("/algorithms")
 		{
@@ -82,25 +252,60 @@ func NewRouterWithConfig(handler *Handler, hub *ws.Hub, cache *storage.RedisCach
 		}
 
 		// Job management
-		jobs := v1.Group("/jobs")
+		jobs := v1.Group("/jobs", jobMiddleware...)
 		{
 			// Idempotency for job creation
 			if cache != nil {
-				jobs.POST("", middleware.Idempotency(cache), handler.SubmitJob)
+				jobs.POST("", idempotent, handler.SubmitJob)
 			} else {
 				jobs.POST("", handler.SubmitJob)
 			}
+			jobs.POST("/batch", handler.SubmitBatchJobs)
+			jobs.POST("/inline", handler.SubmitInlineJob)
 			jobs.GET("", handler.ListJobs)
+			jobs.GET("/count", handler.CountJobs)
+			jobs.GET("/export", handler.ExportJobs)
+			jobs.GET("/sla-breaches", adminOnly(handler.GetSLABreaches)...)
+			jobs.GET("/by-algo-task/:algo_task_id", handler.GetJobByAlgoTask)
 			jobs.GET("/:id", handler.GetJob)
+			jobs.DELETE("/:id", adminOnly(handler.DeleteJob)...)
 			jobs.GET("/:id/result", handler.GetJobResult)
-			jobs.POST("/:id/cancel", handler.CancelJob)
+			jobs.GET("/:id/result/raw", handler.GetJobRawResult)
+			jobs.POST("/:id/cancel", adminOnly(handler.CancelJob)...)
+			jobs.POST("/:id/resume", handler.ResumeJob)
+			jobs.GET("/:id/notes", handler.ListJobNotes)
+			jobs.POST("/:id/notes", handler.AddJobNote)
+			jobs.GET("/:id/callbacks", handler.ListJobCallbacks)
+		}
+
+		// Job input data; data_refs are URLs, so the lookup takes the rest of the path
+		data := v1.Group("/data")
+		{
+			data.POST("", handler.UploadData)
+			data.GET("/*data_ref", handler.GetDataUpload)
+		}
+
+		// Multi-step pipelines
+		pipelines := v1.Group("/pipelines", jobMiddleware...)
+		{
+			pipelines.POST("", handler.SubmitPipeline)
+			pipelines.GET("/:id", handler.GetPipeline)
 		}
 
 		// System endpoints
 		system := v1.Group("/system")
 		{
 			system.GET("/health", handler.HealthCheck)
-			system.GET("/stats", handler.GetStats)
+			system.GET("/stats", adminOnly(handler.GetStats)...)
+			system.GET("/duration-histogram", adminOnly(handler.GetDurationHistogram)...)
+		}
+
+		// Admin endpoints, guarded by the admin API key or an admin token
+		admin := v1.Group("/admin", middleware.AdminAuth(cfg.AdminAPIKey))
+		{
+			admin.POST("/schemes/:code/cancel-all", handler.CancelSchemeJobs)
+			admin.GET("/zombies", handler.ListZombies)
+			admin.POST("/zombies/reap", handler.ReapZombies)
 		}
 
 		// ============================================================
@@ -110,85 +315,145 @@ func NewRouterWithConfig(handler *Handler, hub *ws.Hub, cache *storage.RedisCach
 		// ============================================================
 
 		// KBM (Knowledge Base Management) Module
-		kbm := v1.Group("/kbm")
+		kbm := v1.Group("/kbm", jobMiddleware...)
 		{
 			kbm.GET("/schemes", handler.GetSchemesForModule("KBM"))
 			kbm.GET("/workflows", handler.GetModuleWorkflows("KBM"))
 			kbm.GET("/jobs", handler.ListModuleJobs("KBM"))
 			kbm.GET("/jobs/:id", handler.GetJob)
 			kbm.GET("/jobs/:id/result", handler.GetJobResult)
-			kbm.POST("/jobs/:id/cancel", handler.CancelJob)
+			kbm.POST("/jobs/:id/cancel", adminOnly(handler.CancelJob)...)
 
 			// Dynamic workflow job submission: /api/v1/kbm/:workflow/jobs
 			// Supports any workflow discovered from algorithm-service (WF01, WF02, WF03, etc.)
 			if cache != nil {
-				kbm.POST("/:workflow/jobs", middleware.Idempotency(cache), handler.SubmitDynamicWorkflowJob("KBM"))
+				kbm.POST("/:workflow/jobs", idempotent, handler.SubmitDynamicWorkflowJob("KBM"))
 			} else {
 				kbm.POST("/:workflow/jobs", handler.SubmitDynamicWorkflowJob("KBM"))
 			}
 		}
 
 		// SCM (Safety Check Module) Module
-		scm := v1.Group("/scm")
+		scm := v1.Group("/scm", jobMiddleware...)
 		{
 			scm.GET("/schemes", handler.GetSchemesForModule("SCM"))
 			scm.GET("/workflows", handler.GetModuleWorkflows("SCM"))
 			scm.GET("/jobs", handler.ListModuleJobs("SCM"))
 			scm.GET("/jobs/:id", handler.GetJob)
 			scm.GET("/jobs/:id/result", handler.GetJobResult)
-			scm.POST("/jobs/:id/cancel", handler.CancelJob)
+			scm.POST("/jobs/:id/cancel", adminOnly(handler.CancelJob)...)
 
 			if cache != nil {
-				scm.POST("/:workflow/jobs", middleware.Idempotency(cache), handler.SubmitDynamicWorkflowJob("SCM"))
+				scm.POST("/:workflow/jobs", idempotent, handler.SubmitDynamicWorkflowJob("SCM"))
 			} else {
 				scm.POST("/:workflow/jobs", handler.SubmitDynamicWorkflowJob("SCM"))
 			}
 		}
 
 		// STM (Simulation Twin Module) Module
-		stm := v1.Group("/stm")
+		stm := v1.Group("/stm", jobMiddleware...)
 		{
 			stm.GET("/schemes", handler.GetSchemesForModule("STM"))
 			stm.GET("/workflows", handler.GetModuleWorkflows("STM"))
 			stm.GET("/jobs", handler.ListModuleJobs("STM"))
 			stm.GET("/jobs/:id", handler.GetJob)
 			stm.GET("/jobs/:id/result", handler.GetJobResult)
-			stm.POST("/jobs/:id/cancel", handler.CancelJob)
+			stm.POST("/jobs/:id/cancel", adminOnly(handler.CancelJob)...)
 
 			if cache != nil {
-				stm.POST("/:workflow/jobs", middleware.Idempotency(cache), handler.SubmitDynamicWorkflowJob("STM"))
+				stm.POST("/:workflow/jobs", idempotent, handler.SubmitDynamicWorkflowJob("STM"))
 			} else {
 				stm.POST("/:workflow/jobs", handler.SubmitDynamicWorkflowJob("STM"))
 			}
 		}
 	}
 
-	// WebSocket endpoint for real-time progress updates
-	r.GET("/ws", func(c *gin.Context) {
+	// upgradeWS completes the WebSocket handshake within cfg.WSHandshakeTimeout
+	upgradeWS := func(c *gin.Context) (*websocket.Conn, error) {
+		upgrader := websocket.Upgrader{
+			ReadBufferSize:   1024,
+			WriteBufferSize:  1024,
+			HandshakeTimeout: cfg.WSHandshakeTimeout,
+			CheckOrigin:      wsOriginChecker(cfg.WSAllowedOrigins),
+		}
+
+		// Bound reads on the connection until the upgrade completes; the hub
+		// sets its own deadlines once the client is registered
+		if cfg.WSHandshakeTimeout > 0 {
+			_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(cfg.WSHandshakeTimeout))
+		}
+		return upgrader.Upgrade(c.Writer, c.Request, nil)
+	}
+
+	// With token auth enabled, job streams need a token too; browsers pass it
+	// as ?token= since they cannot set headers on the upgrade request
+	var wsAuth []gin.HandlerFunc
+	if cfg.JWTSecret != "" {
+		wsAuth = append(wsAuth, middleware.JWTAuth(cfg.JWTSecret,
+			middleware.WithIssuer(cfg.JWTIssuer),
+			middleware.WithLeeway(30*time.Second),
+			middleware.WithQueryToken(WSTokenParam),
+		))
+	}
+	// Admins see a finished job's whole result in its terminal frame
+	wsAuth = append(wsAuth, middleware.MarkAdmin(cfg.AdminAPIKey))
+
+	// WebSocket endpoint for real-time progress updates. The pre-check below
+	// can race a job finishing, so the hub looks the job up again once the
+	// client is subscribed and sends the outcome if it has finished by then.
+	hub.SetReplaySource(handler.ReplayFrame)
+	r.GET("/ws", append(wsAuth, func(c *gin.Context) {
 		jobID := c.Query("job_id")
 		if jobID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "job_id query parameter is required"})
+			respondError(c, apperr.Validation("job_id query parameter is required", ""))
+			return
+		}
+		if jobID == ws.SystemTopic {
+			respondError(c, apperr.Validation("job_id "+jobID+" is reserved; use /ws/system", ""))
+			return
+		}
+		if !validateStreamParams(c, jobID) {
 			return
 		}
 
-		upgrader := websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin:     func(r *http.Request) bool { return true },
+		job, ok := handler.authorizeJobStream(c, jobID)
+		if !ok {
+			return
 		}
 
-		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		// A job that has already finished gets its outcome and a normal close
+		// instead of a subscription that would never see another update
+		final, finished := handler.terminalFrame(c.Request.Context(), job, middleware.IsAdmin(c))
+
+		conn, err := upgradeWS(c)
 		if err != nil {
 			return
 		}
+		if finished {
+			_ = ws.SendFinal(conn, final, "job already finished")
+			return
+		}
 
 		userID := c.Query("user_id")
-		hub.SubscribeWithUser(jobID, userID, conn)
+		if authUser := c.GetString(middleware.ContextUserID); authUser != "" {
+			userID = authUser
+		}
+		// A refused connection has already been sent a reconnect hint
+		_ = hub.SubscribeWithUser(jobID, userID, conn)
+	})...)
+
+	// Admin-only feed of system events (job created, job terminal, algo health)
+	r.GET("/ws/system", middleware.AdminAuth(cfg.AdminAPIKey), func(c *gin.Context) {
+		conn, err := upgradeWS(c)
+		if err != nil {
+			return
+		}
+		_ = hub.Subscribe(ws.SystemTopic, conn)
 	})
 
 	// WebSocket health endpoint
 	r.GET("/ws/ping", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok", "clients": hub.GetTotalClients()})
+		respond(c, http.StatusOK, gin.H{"status": "ok", "clients": hub.GetTotalClients()})
 	})
 
 	return r
@@ -0,0 +1,627 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swaggo/swag"
+	"go.uber.org/zap"
+
+	_ "github.com/electric-power/backend-service/docs"
+	"github.com/electric-power/backend-service/internal/lifecycle"
+	"github.com/electric-power/backend-service/internal/middleware"
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/ws"
+)
+
+// newTestRouter builds the production router around a test environment
+func newTestRouter(env *testEnv) http.Handler {
+	cfg := DefaultRouterConfig()
+	cfg.EnableSwagger = false
+	cfg.RateLimitRPS = 0
+	return NewRouterWithConfig(env.handler, env.hub, nil, zap.NewNop(), cfg)
+}
+
+// TestRouterUnknownPathReturnsJSON tests the JSON NoRoute handler
+func TestRouterUnknownPathReturnsJSON(t *testing.T) {
+	env := newTestEnv(t)
+	r := newTestRouter(env)
+
+	w := env.do(r, "GET", "/api/v1/does-not-exist", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "NOT_FOUND", resp.Error)
+	assert.Equal(t, "NOT_FOUND", resp.Code)
+	assert.Contains(t, resp.Message, "/api/v1/does-not-exist")
+}
+
+// TestRouterWrongMethodReturnsJSON tests the JSON NoMethod handler
+func TestRouterWrongMethodReturnsJSON(t *testing.T) {
+	env := newTestEnv(t)
+	r := newTestRouter(env)
+
+	w := env.do(r, "DELETE", "/api/v1/algorithms/schemes", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "METHOD_NOT_ALLOWED", resp.Error)
+	assert.Equal(t, "METHOD_NOT_ALLOWED", resp.Code)
+}
+
+// TestStatsAndMetricsShareOneQuery tests that JSON stats and Prometheus gauges agree
+func TestStatsAndMetricsShareOneQuery(t *testing.T) {
+	env := newTestEnv(t)
+	r := newTestRouter(env)
+
+	// Expect the aggregate queries exactly once; the second surface must hit the cache
+	env.db.ExpectQuery(`SELECT status, COUNT\(\*\) as count FROM t_algo_jobs WHERE deleted_at IS NULL GROUP BY status`).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow("SUCCESS", 7).AddRow("FAILED", 2))
+	env.db.ExpectQuery(`SELECT AVG`).
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(42.5))
+
+	w := env.do(r, "GET", "/api/v1/system/stats", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var stats struct {
+		StatusCounts map[string]int `json:"status_counts"`
+		AvgDuration  float64        `json:"avg_duration_seconds"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 7, stats.StatusCounts["SUCCESS"])
+	assert.Equal(t, 2, stats.StatusCounts["FAILED"])
+	assert.Equal(t, 42.5, stats.AvgDuration)
+
+	w = env.do(r, "GET", "/metrics", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `algo_jobs{status="SUCCESS"} 7`)
+	assert.Contains(t, body, `algo_jobs{status="FAILED"} 2`)
+	assert.Contains(t, body, `algo_job_avg_duration_seconds 42.5`)
+	assert.Contains(t, body, `algo_job_result_bytes_count 0`)
+
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// startTestServer serves the production router through NewServer on a loopback listener
+func startTestServer(t *testing.T, env *testEnv, cfg RouterConfig, connState func(net.Conn, http.ConnState)) string {
+	t.Helper()
+	cfg.EnableSwagger = false
+	cfg.RateLimitRPS = 0
+	srv := NewServer("", NewRouterWithConfig(env.handler, env.hub, nil, zap.NewNop(), cfg), cfg)
+	srv.ConnState = connState
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return lis.Addr().String()
+}
+
+// Job IDs streamed over /ws in tests; the endpoint only accepts UUIDs
+const (
+	streamJobID = "6f1c2a8e-4b7d-4e3a-9c51-0d2e8f7a1b34"
+	doneJobID   = "0b9e4d2c-7a61-4f18-8e3d-5c2a9b7f6e01"
+)
+
+// TestServerShutdownDrainsInFlightRequest tests that shutting the HTTP server
+// down refuses new connections but lets a request in progress complete before
+// the servers behind it are stopped
+func TestServerShutdownDrainsInFlightRequest(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(entered)
+		<-release
+		_, _ = w.Write([]byte("done"))
+	})
+	srv := NewServer("", mux, DefaultRouterConfig())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	addr := lis.Addr().String()
+
+	var order []string
+	shutdown := lifecycle.NewShutdownManager(nil)
+	shutdown.Register("grpc-result-server", lifecycle.PriorityServers, func(context.Context) error {
+		order = append(order, "grpc-result-server")
+		return nil
+	})
+	shutdown.Register("http-server", lifecycle.PriorityHTTP, func(ctx context.Context) error {
+		err := srv.Shutdown(ctx)
+		order = append(order, "http-server")
+		return err
+	})
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- shutdown.Shutdown(ctx) }()
+
+	// New connections are refused while the request is still running
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 2*time.Second, 10*time.Millisecond)
+	select {
+	case <-stopped:
+		t.Fatal("shutdown finished before the in-flight request")
+	default:
+	}
+
+	close(release)
+	res := <-responses
+	require.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	require.NoError(t, <-stopped)
+	assert.Equal(t, []string{"http-server", "grpc-result-server"}, order)
+}
+
+// TestWebSocketStalledHandshakeTimesOut tests that a half-sent upgrade request is dropped
+func TestWebSocketStalledHandshakeTimesOut(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.WSHandshakeTimeout = 100 * time.Millisecond
+
+	closed := make(chan struct{})
+	addr := startTestServer(t, env, cfg, func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			close(closed)
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Send the upgrade request line and some headers, then stall
+	_, err = conn.Write([]byte("GET /ws?job_id=" + streamJobID + " HTTP/1.1\r\nHost: " + addr + "\r\nUpgrade: websocket\r\n"))
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err, "server should close the connection, not leave it to the client deadline")
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not release the stalled connection")
+	}
+	assert.Equal(t, 0, env.hub.GetTotalClients())
+}
+
+// TestWebSocketHandshakeWithinTimeout tests that a prompt client still upgrades
+func TestWebSocketHandshakeWithinTimeout(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.WSHandshakeTimeout = 100 * time.Millisecond
+	addr := startTestServer(t, env, cfg, nil)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+streamJobID, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Outlive the handshake timeout; the hub's deadlines must have replaced it
+	time.Sleep(300 * time.Millisecond)
+	require.Eventually(t, func() bool { return env.hub.GetClientCount(streamJobID) == 1 }, time.Second, 10*time.Millisecond)
+	env.hub.Broadcast(streamJobID, []byte(`{"percentage":50}`))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"percentage":50}`, string(msg))
+}
+
+// TestWebSocketTerminalJobSendsResultAndCloses tests that subscribing to a
+// finished job delivers its outcome and closes instead of waiting forever
+func TestWebSocketTerminalJobSendsResultAndCloses(t *testing.T) {
+	env := newTestEnv(t)
+	addr := startTestServer(t, env, DefaultRouterConfig(), nil)
+
+	env.expectGetJob(jobRow{JobID: doneJobID, Status: "SUCCESS", Progress: 100})
+	env.expectGetResult(doneJobID, `{"score":0.97}`)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+doneJobID, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"terminal","job_id":"`+doneJobID+`","status":"SUCCESS","progress":100,"result":{"score":0.97}}`, string(msg))
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "expected a normal close, got %v", err)
+	assert.Equal(t, 0, env.hub.GetClientCount(doneJobID))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestWebSocketReplaysLastProgress tests that a client joining a running job
+// is sent its cached progress at once, then the live updates
+func TestWebSocketReplaysLastProgress(t *testing.T) {
+	env := newTestEnv(t)
+	addr := startTestServer(t, env, DefaultRouterConfig(), nil)
+
+	cached := models.ProgressMsg{TaskID: streamJobID, Percentage: 90, Message: "solving", Timestamp: 1700000000000}
+	require.NoError(t, env.cache.SetJSON(context.Background(), "job:progress:"+streamJobID, cached, time.Minute))
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "RUNNING", Progress: 90})
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "RUNNING", Progress: 90})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+streamJobID, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var replayed models.ProgressMsg
+	require.NoError(t, json.Unmarshal(msg, &replayed))
+	assert.Equal(t, cached, replayed)
+
+	env.hub.Broadcast(streamJobID, []byte(`{"percentage":95}`))
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"percentage":95}`, string(msg))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestWebSocketReplayFinishedJobCloses tests that a job finishing between the
+// /ws check and the subscription still gets its outcome and a normal close
+func TestWebSocketReplayFinishedJobCloses(t *testing.T) {
+	env := newTestEnv(t)
+	addr := startTestServer(t, env, DefaultRouterConfig(), nil)
+
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "RUNNING", Progress: 90})
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "FAILED", Progress: 90, ErrorLog: "solver diverged"})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+streamJobID, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"terminal","job_id":"`+streamJobID+`","status":"FAILED","progress":90,"error":"solver diverged"}`, string(msg))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "expected a normal close, got %v", err)
+	assert.Equal(t, 0, env.hub.GetClientCount(streamJobID))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestWebSocketRejectsInvalidJobID tests that a job_id that can't name a job
+// is refused before the upgrade, without a lookup or a subscription
+func TestWebSocketRejectsInvalidJobID(t *testing.T) {
+	env := newTestEnv(t)
+	addr := startTestServer(t, env, DefaultRouterConfig(), nil)
+
+	for name, query := range map[string]string{
+		"not a uuid":          "job_id=job-1",
+		"uuid without dashes": "job_id=6f1c2a8e4b7d4e3a9c510d2e8f7a1b34",
+		"too long":            "job_id=" + strings.Repeat("a", 10000),
+		"user too long":       "job_id=" + streamJobID + "&user_id=" + strings.Repeat("u", 200),
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, resp, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?"+query, nil)
+			if conn != nil {
+				conn.Close()
+			}
+			require.Error(t, err)
+			require.NotNil(t, resp)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+	assert.Equal(t, 0, env.hub.GetTotalClients())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestWebSocketCancelNotifiesAndCloses tests that cancelling a job tells its
+// subscribers, closes them, and that a reconnect gets the terminal status
+func TestWebSocketCancelNotifiesAndCloses(t *testing.T) {
+	env := newTestEnv(t)
+	addr := startTestServer(t, env, DefaultRouterConfig(), nil)
+
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "RUNNING", Progress: 30})
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+streamJobID, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return env.hub.GetClientCount(streamJobID) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'CANCELLED'`).
+		WithArgs("Cancelled by user", sqlmock.AnyArg(), sqlmock.AnyArg(), streamJobID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, env.handler.jobs.CancelJob(context.Background(), streamJobID, "Cancelled by user"))
+	require.NoError(t, env.handler.jobs.WaitHooks(context.Background()))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var cancelled models.WebSocketMessage
+	require.NoError(t, json.Unmarshal(msg, &cancelled))
+	assert.Equal(t, "cancelled", cancelled.Type)
+	assert.Equal(t, streamJobID, cancelled.TaskID)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, ws.CloseJobEnded), "expected a job-ended close, got %v", err)
+	assert.Equal(t, 0, env.hub.GetClientCount(streamJobID))
+
+	env.expectGetJob(jobRow{JobID: streamJobID, Status: "CANCELLED", Progress: 30, ErrorLog: "Cancelled by user"})
+	again, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+streamJobID, nil)
+	require.NoError(t, err)
+	defer again.Close()
+	require.NoError(t, again.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err = again.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"terminal","job_id":"`+streamJobID+`","status":"CANCELLED","progress":30,"error":"Cancelled by user"}`, string(msg))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestWebSocketAuthorizesJobOwner tests that with token auth enabled only
+// the job's owner or an admin can stream it, and browser origins are checked
+func TestWebSocketAuthorizesJobOwner(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.JWTSecret = "jwt-secret"
+	cfg.WSAllowedOrigins = []string{"https://ops.example.com"}
+	addr := startTestServer(t, env, cfg, nil)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	ownerToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "u1", ExpiresAt: exp})
+	require.NoError(t, err)
+	otherToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "u2", ExpiresAt: exp})
+	require.NoError(t, err)
+	adminToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "ops", Roles: []string{middleware.RoleAdmin}, ExpiresAt: exp})
+	require.NoError(t, err)
+
+	dial := func(query string, header http.Header) (*websocket.Conn, int) {
+		conn, resp, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+streamJobID+query, header)
+		if err != nil {
+			require.NotNil(t, resp, err)
+			return nil, resp.StatusCode
+		}
+		return conn, http.StatusSwitchingProtocols
+	}
+
+	_, code := dial("", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	_, code = dial("&token=garbage", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	env.expectGetJob(jobRow{JobID: streamJobID, UserID: "u1", Status: "RUNNING"})
+	_, code = dial("&token="+otherToken, nil)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// The owner may use the query parameter, an admin the header
+	env.expectGetJob(jobRow{JobID: streamJobID, UserID: "u1", Status: "RUNNING"})
+	conn, code := dial("&token="+ownerToken, http.Header{"Origin": {"https://ops.example.com"}})
+	require.Equal(t, http.StatusSwitchingProtocols, code)
+	defer conn.Close()
+
+	env.expectGetJob(jobRow{JobID: streamJobID, UserID: "u1", Status: "RUNNING"})
+	admin, code := dial("", http.Header{"Authorization": {"Bearer " + adminToken}})
+	require.Equal(t, http.StatusSwitchingProtocols, code)
+	defer admin.Close()
+	require.Eventually(t, func() bool { return env.hub.GetClientCount(streamJobID) == 2 }, time.Second, 10*time.Millisecond)
+
+	// Origins outside the allowlist are refused during the handshake
+	env.expectGetJob(jobRow{JobID: streamJobID, UserID: "u1", Status: "RUNNING"})
+	_, code = dial("&token="+ownerToken, http.Header{"Origin": {"https://evil.example.com"}})
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSystemFeedDeliversJobTerminal tests that an admin subscribed to /ws/system sees jobs finish
+func TestSystemFeedDeliversJobTerminal(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.AdminAPIKey = "admin-secret"
+	addr := startTestServer(t, env, cfg, nil)
+
+	header := http.Header{}
+	header.Set(middleware.AdminAPIKeyHeader, "admin-secret")
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws/system", header)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return env.hub.GetClientCount(ws.SystemTopic) == 1 }, time.Second, 10*time.Millisecond)
+
+	env.expectFinishJob("job-1", `{"score":1}`)
+	require.NoError(t, env.handler.jobs.FinishJob(context.Background(), "job-1", `{"score":1}`))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var event struct {
+		Type      string            `json:"type"`
+		Timestamp int64             `json:"timestamp"`
+		Data      map[string]string `json:"data"`
+	}
+	require.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, ws.EventJobTerminal, event.Type)
+	assert.NotZero(t, event.Timestamp)
+	assert.Equal(t, map[string]string{"job_id": "job-1", "status": "SUCCESS"}, event.Data)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSystemFeedRequiresAdminKey tests that /ws/system and the reserved topic are closed to other clients
+func TestSystemFeedRequiresAdminKey(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.AdminAPIKey = "admin-secret"
+	addr := startTestServer(t, env, cfg, nil)
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws/system", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, resp, err = websocket.DefaultDialer.Dial("ws://"+addr+"/ws/system?api_key=wrong", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, resp, err = websocket.DefaultDialer.Dial("ws://"+addr+"/ws?job_id="+ws.SystemTopic, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, env.hub.GetTotalClients())
+}
+
+// swaggerRouteRe matches swag @Router annotations, e.g. "// @Router /api/v1/jobs/{id} [get]"
+var swaggerRouteRe = regexp.MustCompile(`@Router\s+(\S+)\s+\[(\w+)\]`)
+
+// swaggerPathParamRe matches {param} segments in swagger paths
+var swaggerPathParamRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// routeKey normalizes a swagger path and method to gin's "METHOD /path/:param" form
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + swaggerPathParamRe.ReplaceAllString(path, ":$1")
+}
+
+// registeredRoutes returns the router's routes keyed by routeKey; catch-all
+// parameters, which swagger cannot express, are keyed as path parameters
+func registeredRoutes(t *testing.T) map[string]bool {
+	t.Helper()
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	r := NewRouterWithConfig(env.handler, env.hub, env.cache, zap.NewNop(), cfg)
+
+	routes := map[string]bool{}
+	for _, route := range r.Routes() {
+		routes[route.Method+" "+strings.Replace(route.Path, "/*", "/:", 1)] = true
+	}
+	return routes
+}
+
+// TestSwaggerAnnotationsMatchRoutes tests that every handler @Router annotation is a registered route
+func TestSwaggerAnnotationsMatchRoutes(t *testing.T) {
+	routes := registeredRoutes(t)
+
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	found := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+		for _, m := range swaggerRouteRe.FindAllStringSubmatch(string(src), -1) {
+			found++
+			key := routeKey(m[2], m[1])
+			assert.True(t, routes[key], "%s: @Router %s [%s] is not registered", file, m[1], m[2])
+		}
+	}
+	assert.NotZero(t, found)
+}
+
+// TestSwaggerDocPathsMatchRoutes tests that every path in the served swagger document is a registered route
+func TestSwaggerDocPathsMatchRoutes(t *testing.T) {
+	routes := registeredRoutes(t)
+
+	doc, err := swag.ReadDoc()
+	require.NoError(t, err)
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(doc), &spec))
+	require.NotEmpty(t, spec.Paths)
+
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			assert.True(t, routes[routeKey(method, path)], "swagger documents %s %s but it is not registered", method, path)
+		}
+	}
+}
+
+// TestSwaggerDisabledReturnsJSON tests the fallback when swagger is disabled
+func TestSwaggerDisabledReturnsJSON(t *testing.T) {
+	env := newTestEnv(t)
+	r := newTestRouter(env)
+
+	w := env.do(r, "GET", "/swagger/index.html", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "SWAGGER_DISABLED", resp.Error)
+}
+
+// TestJWTAuthRoutes tests token auth, token-derived user IDs and admin-only routes
+func TestJWTAuthRoutes(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{})
+	cfg := DefaultRouterConfig()
+	cfg.EnableSwagger = false
+	cfg.RateLimitRPS = 0
+	cfg.AdminAPIKey = "admin-secret"
+	cfg.JWTSecret = "jwt-secret"
+	r := NewRouterWithConfig(env.handler, env.hub, nil, zap.NewNop(), cfg)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	userToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "u1", Roles: []string{"operator"}, ExpiresAt: exp})
+	require.NoError(t, err)
+	adminToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "ops", Roles: []string{middleware.RoleAdmin}, ExpiresAt: exp})
+	require.NoError(t, err)
+	send := func(method, path, body, token string) int {
+		req := newJSONRequest(method, path, body)
+		req.Header.Set("X-User-ID", "spoofed")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return serve(r, req).Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/jobs/job-1", "", ""))
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/jobs/job-1", "", "bogus"))
+
+	// The token's user is used, ignoring X-User-ID; another user_id in the body is refused
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", "u1", "d1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Equal(t, http.StatusOK, send("POST", "/api/v1/jobs", `{"scheme":"KBM-WF01","data_id":"d1"}`, userToken))
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/v1/jobs", `{"scheme":"KBM-WF01","data_id":"d1","user_id":"u2"}`, userToken))
+
+	// Cancel, stats and SLA breaches need the admin role
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/v1/jobs/job-1/cancel", "", userToken))
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/v1/kbm/jobs/job-1/cancel", "", userToken))
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/v1/system/stats", "", userToken))
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/v1/jobs/sla-breaches?user_id=u2", "", userToken))
+
+	// Admin routes take either an admin token or the API key
+	assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/v1/admin/schemes/KBM-WF03/cancel-all", "", ""))
+	assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/v1/admin/schemes/KBM-WF03/cancel-all", "", userToken))
+	env.expectActiveJobs("KBM-WF03")
+	assert.Equal(t, http.StatusOK, send("POST", "/api/v1/admin/schemes/KBM-WF03/cancel-all", "", adminToken))
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
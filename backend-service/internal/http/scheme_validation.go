@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/electric-power/backend-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// Behaviors when a submission arrives while the scheme cache is cold
+const (
+	// ColdSchemeCacheStrict fetches the schemes from the algorithm service
+	// and validates against them, failing the submission if they can't be fetched
+	ColdSchemeCacheStrict = "strict"
+	// ColdSchemeCacheOptimistic skips validation and dispatches anyway,
+	// leaving the algorithm service to reject an unknown scheme
+	ColdSchemeCacheOptimistic = "optimistic"
+)
+
+var (
+	errUnknownScheme        = errors.New("unknown scheme")
+	errSchemesUnavailable   = errors.New("schemes are unavailable for validation")
+	errNoAlgoServiceSchemes = errors.New("no algorithm service configured")
+)
+
+// checkSchemeKnown validates a submitted scheme code against the cached
+// schemes. When the cache is cold, ColdSchemeCache decides whether to fetch
+// the schemes first or to let the submission through unvalidated.
+func (h *Handler) checkSchemeKnown(ctx context.Context, schemeCode string) error {
+	schemes, err := h.jobs.GetCachedSchemes(ctx)
+	if err != nil {
+		if h.cfg.ColdSchemeCache != ColdSchemeCacheStrict {
+			h.logger.Info("Scheme cache cold, dispatching without scheme validation",
+				zap.String("scheme", schemeCode), zap.Error(err))
+			return nil
+		}
+		h.logger.Info("Scheme cache cold, fetching schemes to validate submission",
+			zap.String("scheme", schemeCode), zap.Error(err))
+		if schemes, err = h.fetchSchemes(ctx); err != nil {
+			return fmt.Errorf("%w: %v", errSchemesUnavailable, err)
+		}
+	}
+	if !slices.ContainsFunc(schemes, func(s models.Scheme) bool { return s.Code == schemeCode }) {
+		return fmt.Errorf("%w: %s", errUnknownScheme, schemeCode)
+	}
+	return nil
+}
+
+// fetchSchemes loads the schemes from the algorithm service and caches them,
+// sharing the fetch with any refresh already in flight
+func (h *Handler) fetchSchemes(ctx context.Context) ([]models.Scheme, error) {
+	if h.algo == nil {
+		return nil, errNoAlgoServiceSchemes
+	}
+	return h.jobs.RefreshSchemes(ctx, h.algo.GetSchemes)
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// coldCacheEnv builds a handler with an empty scheme cache whose algorithm
+// service reports schemes through fetch and accepts every submission
+func coldCacheEnv(t *testing.T, mode string, fetch func(context.Context) (*pb.SchemeList, error)) (*testEnv, *observer.ObservedLogs) {
+	t.Helper()
+	cfg := DefaultHandlerConfig()
+	cfg.ColdSchemeCache = mode
+	env := newTestEnvWithConfig(t, cfg)
+	core, logs := observer.New(zap.InfoLevel)
+	env.handler.SetLogger(zap.New(core))
+	env.withAlgo(t, &fakeAlgo{
+		schemes: fetch,
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+	return env, logs
+}
+
+// TestColdSchemeCacheOptimisticSkipsValidation tests that a cold cache lets a
+// submission through without asking the algorithm service for schemes
+func TestColdSchemeCacheOptimisticSkipsValidation(t *testing.T) {
+	fetched := 0
+	env, logs := coldCacheEnv(t, ColdSchemeCacheOptimistic, func(context.Context) (*pb.SchemeList, error) {
+		fetched++
+		return nil, errors.New("algorithm service is slow")
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-NOPE","data_id":"d1"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Zero(t, fetched)
+	assert.Equal(t, 1, logs.FilterMessage("Scheme cache cold, dispatching without scheme validation").Len())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestColdSchemeCacheStrictFetchesSchemes tests that a cold cache is filled
+// from the algorithm service and submissions are validated against it
+func TestColdSchemeCacheStrictFetchesSchemes(t *testing.T) {
+	fetched := 0
+	env, logs := coldCacheEnv(t, ColdSchemeCacheStrict, func(context.Context) (*pb.SchemeList, error) {
+		fetched++
+		return &pb.SchemeList{Schemes: []*pb.SchemeList_Scheme{{Code: "KBM-WF01", Name: "Test"}}}, nil
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-NOPE","data_id":"d1"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Unknown scheme")
+	assert.Equal(t, 1, logs.FilterMessage("Scheme cache cold, fetching schemes to validate submission").Len())
+
+	// The fetched schemes were cached, so the next submission is validated without a fetch
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	w = env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, fetched)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestColdSchemeCacheStrictFailsWhenSchemesUnavailable tests that strict
+// validation refuses submissions it can't check
+func TestColdSchemeCacheStrictFailsWhenSchemesUnavailable(t *testing.T) {
+	env, _ := coldCacheEnv(t, ColdSchemeCacheStrict, func(context.Context) (*pb.SchemeList, error) {
+		return nil, errors.New("algorithm service is slow")
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1"}`))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Scheme validation unavailable")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSLABreachRows caps the flagged jobs returned by GetSLABreaches
+const maxSLABreachRows = 1000
+
+// GetSLABreaches godoc
+// @Summary      List jobs at risk of or past their SLA
+// @Description  Returns the PENDING and RUNNING jobs the scheduler has flagged against their sla_deadline, closest deadline first.
+// @Description  Flagged jobs keep running; flags are refreshed on the SLA check schedule.
+// @Description  Lists every user's jobs, so it requires the admin role when token auth is enabled.
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        state    query     string  false  "Only jobs in this SLA state (AT_RISK or BREACHED)"
+// @Param        user_id  query     string  false  "Filter by user ID"
+// @Success      200  {object}  map[string]any
+// @Security     BearerAuth
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/jobs/sla-breaches [get]
+func (h *Handler) GetSLABreaches(c *gin.Context) {
+	state := c.Query("state")
+	if state != "" && state != storage.SLAAtRisk && state != storage.SLABreached {
+		respondError(c, apperr.Validation("Invalid state", "state must be AT_RISK or BREACHED"))
+		return
+	}
+
+	jobs, err := h.store.ListSLAJobs(c.Request.Context(), c.Query("user_id"), state, maxSLABreachRows)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to list SLA breaches", err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, gin.H{
+		"jobs":      jobs,
+		"count":     len(jobs),
+		"truncated": len(jobs) == maxSLABreachRows,
+	})
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// TestSubmitJobStoresSLADeadline tests that an sla_deadline is stored with the job
+func TestSubmitJobStoresSLADeadline(t *testing.T) {
+	env := newTestEnv(t)
+	env.withAlgo(t, &fakeAlgo{
+		submit: func(_ context.Context, req *pb.TaskRequest) (*pb.TaskSubmissionResponse, error) {
+			return &pb.TaskSubmissionResponse{Accepted: true, TaskId: req.TaskId}, nil
+		},
+		watch: func(_ *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+			<-stream.Context().Done()
+			return nil
+		},
+	})
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	deadline := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	env.db.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET sla_deadline = \? WHERE job_id = \?`).
+		WithArgs(deadline, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"scheme":"KBM-WF01","data_id":"d1","sla_deadline":"` + deadline.Format(time.RFC3339) + `"}`
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestSubmitJobRejectsPastSLADeadline tests that a deadline already passed is refused
+func TestSubmitJobRejectsPastSLADeadline(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs", env.handler.SubmitJob)
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	w := env.do(r, "POST", "/api/v1/jobs", []byte(`{"scheme":"KBM-WF01","data_id":"d1","sla_deadline":"`+past+`"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid sla_deadline")
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestGetSLABreaches tests listing flagged jobs and the state filter
+func TestGetSLABreaches(t *testing.T) {
+	env := newTestEnv(t)
+	r := setupTestRouter()
+	r.GET("/api/v1/jobs/sla-breaches", env.handler.GetSLABreaches)
+
+	now := time.Now().UTC()
+	columns := []string{"job_id", "scheme_code", "user_id", "status", "progress", "created_at", "sla_deadline", "sla_state"}
+	env.db.ExpectQuery(`FROM t_algo_jobs WHERE status IN \('PENDING', 'RUNNING'\) AND sla_state IS NOT NULL ORDER BY sla_deadline ASC LIMIT \?`).
+		WithArgs(maxSLABreachRows).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("job-past", "KBM-WF01", "u1", "RUNNING", 90, now.Add(-time.Hour), now.Add(-time.Minute), "BREACHED").
+			AddRow("job-near", "KBM-WF01", "u2", "PENDING", 0, now.Add(-time.Hour), now.Add(5*time.Minute), "AT_RISK"))
+
+	w := env.do(r, "GET", "/api/v1/jobs/sla-breaches", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Jobs []struct {
+			JobID    string `json:"job_id"`
+			Status   string `json:"status"`
+			SLAState string `json:"sla_state"`
+		} `json:"jobs"`
+		Count     int  `json:"count"`
+		Truncated bool `json:"truncated"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 2, resp.Count)
+	assert.Equal(t, "job-past", resp.Jobs[0].JobID)
+	assert.Equal(t, "BREACHED", resp.Jobs[0].SLAState)
+	assert.Equal(t, "RUNNING", resp.Jobs[0].Status, "flagged jobs are not failed")
+	assert.Equal(t, "AT_RISK", resp.Jobs[1].SLAState)
+	assert.False(t, resp.Truncated)
+
+	w = env.do(r, "GET", "/api/v1/jobs/sla-breaches?state=LATE", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (cfg RouterConfig) TLSEnabled() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+// newTLSConfig restricts the server to TLS 1.2+ with forward-secret AEAD
+// cipher suites; TLS 1.3 suites are not configurable and always secure
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// Serve runs srv on lis, over TLS when cfg has a certificate and key
+func Serve(srv *http.Server, lis net.Listener, cfg RouterConfig) error {
+	if cfg.TLSEnabled() {
+		return srv.ServeTLS(lis, cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return srv.Serve(lis)
+}
+
+// ListenAndServe runs srv on its address, over TLS when cfg has a certificate and key
+func ListenAndServe(srv *http.Server, cfg RouterConfig) error {
+	if cfg.TLSEnabled() {
+		return srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+// NewRedirectServer answers plaintext requests on addr with a permanent
+// redirect to the same host and path over HTTPS. httpsPort is appended to the
+// host unless it is the default 443.
+func NewRedirectServer(addr, httpsPort string, cfg RouterConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		ReadHeaderTimeout: cfg.WSHandshakeTimeout,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if httpsPort != "" && httpsPort != "443" {
+				host = net.JoinHostPort(host, httpsPort)
+			}
+			target := "https://" + host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		}),
+	}
+}
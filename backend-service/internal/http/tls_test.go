@@ -0,0 +1,110 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeSelfSignedCert writes a 127.0.0.1 certificate and key to dir and
+// returns their paths along with the certificate for client trust
+func writeSelfSignedCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+// TestServerServesHTTPS tests a full HTTPS request against a self-signed certificate
+func TestServerServesHTTPS(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.EnableSwagger = false
+	cfg.RateLimitRPS = 0
+	var cert *x509.Certificate
+	cfg.TLSCertFile, cfg.TLSKeyFile, cert = writeSelfSignedCert(t, t.TempDir())
+	require.True(t, cfg.TLSEnabled())
+
+	srv := NewServer("", NewRouterWithConfig(env.handler, env.hub, nil, zap.NewNop(), cfg), cfg)
+	require.NotNil(t, srv.TLSConfig)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = Serve(srv, lis, cfg) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	resp, err := client.Get("https://" + lis.Addr().String() + "/ws/ping")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, resp.TLS)
+	assert.GreaterOrEqual(t, resp.TLS.Version, uint16(tls.VersionTLS12))
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "ok", body["status"])
+
+	// TLS 1.1 clients are refused
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}}}
+	_, err = old.Get("https://" + lis.Addr().String() + "/ws/ping")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "protocol version")
+}
+
+// TestRedirectServer tests that plaintext requests are redirected to HTTPS
+func TestRedirectServer(t *testing.T) {
+	tests := []struct {
+		port string
+		want string
+	}{
+		{"8443", "https://example.com:8443/api/v1/jobs?page=2"},
+		{"443", "https://example.com/api/v1/jobs?page=2"},
+	}
+	for _, tt := range tests {
+		srv := NewRedirectServer(":80", tt.port, DefaultRouterConfig())
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com:8080/api/v1/jobs?page=2", nil)
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		assert.Equal(t, tt.want, w.Header().Get("Location"))
+	}
+}
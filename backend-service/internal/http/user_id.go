@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"errors"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// User ID strategies applied to job submissions
+const (
+	// UserIDStrategyDefault uses user_id, then the caller identity, then DefaultUserID
+	UserIDStrategyDefault = "default"
+	// UserIDStrategyRequire uses user_id or the caller identity and rejects anonymous submissions
+	UserIDStrategyRequire = "require"
+	// UserIDStrategyAuth always uses the authenticated user; a different
+	// user_id is rejected and X-User-ID is ignored
+	UserIDStrategyAuth = "auth"
+)
+
+var (
+	errUserIDRequired  = errors.New("user_id is required")
+	errUnauthenticated = errors.New("submissions require an authenticated user")
+	errUserIDMismatch  = errors.New("user_id does not match the authenticated user")
+)
+
+// authenticatedUser returns the user set by auth middleware, "" when the
+// request was not authenticated
+func authenticatedUser(c *gin.Context) string {
+	return c.GetString(middleware.ContextUserID)
+}
+
+// callerIdentity returns the user set by auth middleware, otherwise the
+// X-User-ID header, or "" when the caller is anonymous. The header is chosen
+// by the client, so it is only fit where a self-declared user_id would be.
+func callerIdentity(c *gin.Context) string {
+	if userID := authenticatedUser(c); userID != "" {
+		return userID
+	}
+	return c.GetHeader("X-User-ID")
+}
+
+// submissionCaller returns the caller identity submissions are resolved
+// against: only the authenticated user under the auth strategy, the caller
+// identity otherwise
+func (h *Handler) submissionCaller(c *gin.Context) string {
+	if h.cfg.UserIDStrategy == UserIDStrategyAuth {
+		return authenticatedUser(c)
+	}
+	return callerIdentity(c)
+}
+
+// resolveUserID picks the user a submission is stored under according to the
+// configured strategy; callers authenticated by a token are always held to
+// their token's user, whatever the strategy
+func (h *Handler) resolveUserID(ctx context.Context, provided, caller string) (string, error) {
+	strategy := h.cfg.UserIDStrategy
+	if _, ok := middleware.ClaimsFromContext(ctx); ok {
+		strategy = UserIDStrategyAuth
+	}
+	switch strategy {
+	case UserIDStrategyAuth:
+		if caller == "" {
+			return "", errUnauthenticated
+		}
+		if provided != "" && provided != caller {
+			return "", errUserIDMismatch
+		}
+		return caller, nil
+	case UserIDStrategyRequire:
+		if provided != "" {
+			return provided, nil
+		}
+		if caller != "" {
+			return caller, nil
+		}
+		return "", errUserIDRequired
+	default:
+		if provided != "" {
+			return provided, nil
+		}
+		if caller != "" {
+			return caller, nil
+		}
+		return h.cfg.DefaultUserID, nil
+	}
+}
+
+// submissionUser resolves the user for a submission. On failure it writes
+// the error response and returns false.
+func (h *Handler) submissionUser(c *gin.Context, provided string) (string, bool) {
+	userID, err := h.resolveUserID(c.Request.Context(), provided, h.submissionCaller(c))
+	switch {
+	case err == nil:
+		return userID, true
+	case errors.Is(err, errUnauthenticated):
+		respondError(c, apperr.Unauthorized("Unauthorized", err.Error()))
+	case errors.Is(err, errUserIDMismatch):
+		respondError(c, apperr.Forbidden("Forbidden", err.Error()))
+	default:
+		respondError(c, apperr.Validation("Invalid request", err.Error()))
+	}
+	return "", false
+}
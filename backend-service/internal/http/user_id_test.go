@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/middleware"
+)
+
+// TestResolveUserID tests each strategy with and without a provided user_id and caller identity
+func TestResolveUserID(t *testing.T) {
+	tests := []struct {
+		strategy string
+		provided string
+		caller   string
+		want     string
+		wantErr  error
+	}{
+		{UserIDStrategyDefault, "u1", "", "u1", nil},
+		{UserIDStrategyDefault, "", "caller", "caller", nil},
+		{UserIDStrategyDefault, "", "", "anonymous", nil},
+		{UserIDStrategyRequire, "u1", "", "u1", nil},
+		{UserIDStrategyRequire, "", "caller", "caller", nil},
+		{UserIDStrategyRequire, "", "", "", errUserIDRequired},
+		{UserIDStrategyAuth, "", "caller", "caller", nil},
+		{UserIDStrategyAuth, "caller", "caller", "caller", nil},
+		{UserIDStrategyAuth, "u1", "caller", "", errUserIDMismatch},
+		{UserIDStrategyAuth, "u1", "", "", errUnauthenticated},
+		{UserIDStrategyAuth, "", "", "", errUnauthenticated},
+	}
+	for _, tt := range tests {
+		cfg := DefaultHandlerConfig()
+		cfg.UserIDStrategy = tt.strategy
+		h := &Handler{cfg: cfg}
+
+		got, err := h.resolveUserID(context.Background(), tt.provided, tt.caller)
+		assert.ErrorIs(t, err, tt.wantErr, "%s provided=%q caller=%q", tt.strategy, tt.provided, tt.caller)
+		assert.Equal(t, tt.want, got, "%s provided=%q caller=%q", tt.strategy, tt.provided, tt.caller)
+	}
+}
+
+// TestSubmitJobUserIDStrategies tests the status codes each strategy returns on the submit endpoint
+func TestSubmitJobUserIDStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		body     string
+		header   string
+		authUser string
+		wantCode int
+		wantUser string
+	}{
+		{UserIDStrategyRequire, `{"scheme":"KBM-WF01","data_id":"d1"}`, "", "", http.StatusBadRequest, ""},
+		{UserIDStrategyRequire, `{"scheme":"KBM-WF01","data_id":"d1","user_id":"u1"}`, "", "", http.StatusOK, "u1"},
+		{UserIDStrategyRequire, `{"scheme":"KBM-WF01","data_id":"d1"}`, "u2", "", http.StatusOK, "u2"},
+		{UserIDStrategyAuth, `{"scheme":"KBM-WF01","data_id":"d1","user_id":"u1"}`, "", "", http.StatusUnauthorized, ""},
+		{UserIDStrategyAuth, `{"scheme":"KBM-WF01","data_id":"d1","user_id":"u1"}`, "", "u2", http.StatusForbidden, ""},
+		{UserIDStrategyAuth, `{"scheme":"KBM-WF01","data_id":"d1"}`, "", "u2", http.StatusOK, "u2"},
+		// X-User-ID is chosen by the client, so it never authenticates
+		{UserIDStrategyAuth, `{"scheme":"KBM-WF01","data_id":"d1"}`, "u2", "", http.StatusUnauthorized, ""},
+		{UserIDStrategyDefault, `{"scheme":"KBM-WF01","data_id":"d1"}`, "", "", http.StatusOK, "anonymous"},
+	}
+	for _, tt := range tests {
+		cfg := DefaultHandlerConfig()
+		cfg.UserIDStrategy = tt.strategy
+		env := newTestEnvWithConfig(t, cfg)
+		env.withAlgo(t, &fakeAlgo{})
+		if tt.wantUser != "" {
+			env.db.ExpectExec(`INSERT INTO t_algo_jobs`).
+				WithArgs(sqlmock.AnyArg(), "KBM-WF01", tt.wantUser, "d1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+
+		r := setupTestRouter()
+		if tt.authUser != "" {
+			r.Use(func(c *gin.Context) { c.Set(middleware.ContextUserID, tt.authUser) })
+		}
+		r.POST("/api/v1/jobs", env.handler.SubmitJob)
+		req := newJSONRequest("POST", "/api/v1/jobs", tt.body)
+		if tt.header != "" {
+			req.Header.Set("X-User-ID", tt.header)
+		}
+		w := serve(r, req)
+
+		require.Equal(t, tt.wantCode, w.Code, "%s: %s", tt.strategy, w.Body.String())
+		assert.NoError(t, env.db.ExpectationsWereMet(), tt.strategy)
+	}
+}
+
+// TestSubmitBatchRejectsAnonymousItems tests that the require strategy rejects only the items without a user
+func TestSubmitBatchRejectsAnonymousItems(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.UserIDStrategy = UserIDStrategyRequire
+	env := newTestEnvWithConfig(t, cfg)
+	env.withAlgo(t, &fakeAlgo{})
+	env.db.ExpectBegin()
+	env.db.ExpectPrepare(`INSERT INTO t_algo_jobs`).ExpectExec().
+		WithArgs(sqlmock.AnyArg(), "KBM-WF01", "u1", "d1", sqlmock.AnyArg(), nil, nil, nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectCommit()
+
+	r := setupTestRouter()
+	r.POST("/api/v1/jobs/batch", env.handler.SubmitBatchJobs)
+	w := env.do(r, "POST", "/api/v1/jobs/batch",
+		[]byte(`{"jobs":[{"scheme":"KBM-WF01","data_id":"d1","user_id":"u1"},{"scheme":"KBM-WF01","data_id":"d2"}]}`))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"submitted":1`)
+	assert.Contains(t, w.Body.String(), errUserIDRequired.Error())
+	assert.Eventually(t, func() bool { return env.db.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// WatchHandoffKey is the Redis set of progress watches handed off by
+// instances shutting down; WatchHandoffChannel announces new entries
+const (
+	WatchHandoffKey     = "job:watch-handoff"
+	WatchHandoffChannel = "job:watch-handoff:notify"
+)
+
+// watchHandoff is a progress watch waiting for another instance to resume it
+type watchHandoff struct {
+	JobID      string `json:"job_id"`
+	AlgoTarget string `json:"algo_target,omitempty"`
+	AlgoTaskID string `json:"algo_task_id"`
+}
+
+// HandOffWatches stops this instance's progress watchers and hands them to
+// the surviving instances through Redis, returning how many it handed off.
+// Adoption is stopped first so the instance does not take its own watches back.
+func (h *Handler) HandOffWatches(ctx context.Context) (int, error) {
+	h.StopWatchAdoption()
+
+	h.watchMu.Lock()
+	watches := h.watchers
+	h.watchers = make(map[string]*progressWatch)
+	h.watchMu.Unlock()
+	if len(watches) == 0 {
+		return 0, nil
+	}
+
+	members := make([]string, 0, len(watches))
+	for jobID, w := range watches {
+		w.cancel()
+		b, err := json.Marshal(watchHandoff{JobID: jobID, AlgoTarget: w.algoTarget, AlgoTaskID: w.algoTaskID})
+		if err != nil {
+			return 0, err
+		}
+		members = append(members, string(b))
+	}
+	if err := h.cache.SAdd(ctx, WatchHandoffKey, h.cfg.WatchHandoffTTL, members...); err != nil {
+		return 0, err
+	}
+	// Instances that miss the announcement adopt the watches when they start
+	if err := h.cache.Publish(ctx, WatchHandoffChannel, len(members)); err != nil {
+		h.logger.Warn("Failed to announce handed-off progress watches", zap.Error(err))
+	}
+	return len(members), nil
+}
+
+// AdoptWatches resumes the progress watches handed off by other instances,
+// skipping jobs that finished in the meantime, and returns how many it
+// resumed. Each handed-off watch is adopted by one instance only.
+func (h *Handler) AdoptWatches(ctx context.Context) (int, error) {
+	adopted := 0
+	for {
+		member, err := h.cache.SPop(ctx, WatchHandoffKey)
+		if errors.Is(err, redis.Nil) {
+			return adopted, nil
+		}
+		if err != nil {
+			return adopted, err
+		}
+		var w watchHandoff
+		if err := json.Unmarshal([]byte(member), &w); err != nil || w.JobID == "" {
+			h.logger.Warn("Dropping malformed progress watch handoff", zap.String("handoff", member))
+			continue
+		}
+		if h.adoptWatch(ctx, w) {
+			adopted++
+		}
+	}
+}
+
+// adoptWatch starts the watcher of a handed-off watch unless its job ended
+func (h *Handler) adoptWatch(ctx context.Context, w watchHandoff) bool {
+	job, err := h.store.GetJobTyped(ctx, w.JobID)
+	if err != nil {
+		h.logger.Warn("Failed to load job of handed-off progress watch", zap.String("job_id", w.JobID), zap.Error(err))
+		return false
+	}
+	switch job.Status {
+	case "SUCCESS", "FAILED", "CANCELLED":
+		return false
+	}
+	algo, ok := h.algoFor(w.AlgoTarget)
+	if !ok || algo == nil {
+		h.logger.Warn("No algorithm client for handed-off progress watch",
+			zap.String("job_id", w.JobID), zap.String("algo_target", w.AlgoTarget))
+		return false
+	}
+	go h.watchProgress(h.startWatch(w.JobID, w.AlgoTarget, w.AlgoTaskID), algo, w.JobID, w.AlgoTaskID)
+	return true
+}
+
+// StartWatchAdoption adopts the watches already handed off, then those
+// announced on WatchHandoffChannel, until StopWatchAdoption is called
+func (h *Handler) StartWatchAdoption() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.watchMu.Lock()
+	if h.adoptStop != nil {
+		h.watchMu.Unlock()
+		cancel()
+		return
+	}
+	h.adoptStop = cancel
+	h.watchMu.Unlock()
+
+	// Subscribed before the first pass so no announcement falls in between
+	msgs, unsubscribe := h.cache.Subscribe(ctx, WatchHandoffChannel)
+	go func() {
+		defer unsubscribe()
+		h.adoptWatches(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-msgs:
+				if !ok {
+					return
+				}
+				h.adoptWatches(ctx)
+			}
+		}
+	}()
+}
+
+// StopWatchAdoption stops adopting handed-off watches; it is not restarted
+// by a later StartWatchAdoption
+func (h *Handler) StopWatchAdoption() {
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+	if h.adoptStop != nil {
+		h.adoptStop()
+	}
+	h.adoptStop = func() {}
+}
+
+func (h *Handler) adoptWatches(ctx context.Context) {
+	n, err := h.AdoptWatches(ctx)
+	if err != nil && ctx.Err() == nil {
+		h.logger.Warn("Failed to adopt handed-off progress watches", zap.Error(err))
+	}
+	if n > 0 {
+		h.logger.Info("Adopted handed-off progress watches", zap.Int("count", n))
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// watchRecorder is a fake progress stream that stays open until the watcher
+// goes away, recording which tasks are being watched
+type watchRecorder struct {
+	mu     sync.Mutex
+	active map[string]bool
+	opened chan string
+}
+
+func newWatchRecorder() *watchRecorder {
+	return &watchRecorder{active: make(map[string]bool), opened: make(chan string, 8)}
+}
+
+func (w *watchRecorder) watch(req *pb.TaskIdentity, stream pb.AlgoControlService_WatchTaskProgressServer) error {
+	w.mu.Lock()
+	w.active[req.GetTaskId()] = true
+	w.mu.Unlock()
+	w.opened <- req.GetTaskId()
+	<-stream.Context().Done()
+	w.mu.Lock()
+	delete(w.active, req.GetTaskId())
+	w.mu.Unlock()
+	return stream.Context().Err()
+}
+
+func (w *watchRecorder) watching() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var tasks []string
+	for task := range w.active {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+func (w *watchRecorder) waitOpened(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-w.opened:
+		case <-time.After(5 * time.Second):
+			t.Fatal("progress stream was not opened")
+		}
+	}
+}
+
+// TestWatchHandoffOnShutdown tests that an instance shutting down hands its
+// progress watches to a running instance, which resumes those of unfinished jobs
+func TestWatchHandoffOnShutdown(t *testing.T) {
+	leaving := newTestEnv(t)
+	leavingAlgo := newWatchRecorder()
+	leaving.withAlgo(t, &fakeAlgo{watch: leavingAlgo.watch})
+
+	surviving := newTestEnvOnRedis(t, DefaultHandlerConfig(), leaving.redis)
+	survivingAlgo := newWatchRecorder()
+	surviving.withAlgo(t, &fakeAlgo{watch: survivingAlgo.watch})
+	surviving.handler.StartWatchAdoption()
+	t.Cleanup(func() {
+		surviving.handler.StopWatchAdoption()
+		surviving.handler.stopWatch("job-1")
+	})
+
+	for jobID, taskID := range map[string]string{"job-1": "algo-1", "job-2": "algo-2"} {
+		go leaving.handler.watchProgress(leaving.handler.startWatch(jobID, "", taskID), leaving.handler.algo, jobID, taskID)
+	}
+	leavingAlgo.waitOpened(t, 2)
+
+	// job-2 finished while it was being handed off
+	surviving.db.MatchExpectationsInOrder(false)
+	surviving.expectGetJob(jobRow{JobID: "job-1", Status: "RUNNING"})
+	surviving.expectGetJob(jobRow{JobID: "job-2", Status: "SUCCESS"})
+
+	n, err := leaving.handler.HandOffWatches(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Empty(t, leaving.handler.watchers)
+	assert.Eventually(t, func() bool { return len(leavingAlgo.watching()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	survivingAlgo.waitOpened(t, 1)
+	assert.Equal(t, []string{"algo-1"}, survivingAlgo.watching())
+	assert.Eventually(t, func() bool { return surviving.db.ExpectationsWereMet() == nil }, 5*time.Second, 10*time.Millisecond)
+	surviving.handler.watchMu.Lock()
+	require.Contains(t, surviving.handler.watchers, "job-1")
+	assert.Equal(t, "algo-1", surviving.handler.watchers["job-1"].algoTaskID)
+	assert.NotContains(t, surviving.handler.watchers, "job-2")
+	surviving.handler.watchMu.Unlock()
+	assert.False(t, leaving.redis.Exists(WatchHandoffKey))
+
+	// Nothing is left for an instance starting later
+	n, err = leaving.handler.AdoptWatches(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+// TestWatchHandoffAdoptedAtStartup tests that watches handed off while no
+// instance was listening are adopted by the next one to start
+func TestWatchHandoffAdoptedAtStartup(t *testing.T) {
+	leaving := newTestEnv(t)
+	leavingAlgo := newWatchRecorder()
+	leaving.withAlgo(t, &fakeAlgo{watch: leavingAlgo.watch})
+	go leaving.handler.watchProgress(leaving.handler.startWatch("job-1", "", "algo-1"), leaving.handler.algo, "job-1", "algo-1")
+	leavingAlgo.waitOpened(t, 1)
+
+	n, err := leaving.handler.HandOffWatches(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, leaving.redis.Exists(WatchHandoffKey))
+	assert.Positive(t, leaving.redis.TTL(WatchHandoffKey))
+
+	starting := newTestEnvOnRedis(t, DefaultHandlerConfig(), leaving.redis)
+	startingAlgo := newWatchRecorder()
+	starting.withAlgo(t, &fakeAlgo{watch: startingAlgo.watch})
+	starting.expectGetJob(jobRow{JobID: "job-1", Status: "RUNNING"})
+	starting.handler.StartWatchAdoption()
+	t.Cleanup(func() {
+		starting.handler.StopWatchAdoption()
+		starting.handler.stopWatch("job-1")
+	})
+
+	startingAlgo.waitOpened(t, 1)
+	assert.Equal(t, []string{"algo-1"}, startingAlgo.watching())
+	assert.NoError(t, starting.db.ExpectationsWereMet())
+}
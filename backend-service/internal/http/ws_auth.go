@@ -0,0 +1,92 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/middleware"
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// WSTokenParam carries the Bearer token of WebSocket clients that cannot set
+// the Authorization header
+const WSTokenParam = "token"
+
+// wsOriginChecker returns the upgrader's CheckOrigin. Requests without an
+// Origin header come from non-browser clients and are accepted; browser
+// origins must be in allowed, where "*" accepts any. With no allowlist only
+// the server's own host is accepted.
+func wsOriginChecker(allowed []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if len(allowed) == 0 {
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		}
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Limits on the /ws query parameters
+const (
+	// maxStreamJobIDLen is the length of a canonical job ID (a UUID)
+	maxStreamJobIDLen  = 36
+	maxStreamUserIDLen = 128
+)
+
+// validateStreamParams rejects /ws query parameters that can't name a job or
+// user before the connection is upgraded, so no client subscribes to a topic
+// nothing will ever publish to. On failure it writes the error response and returns false.
+func validateStreamParams(c *gin.Context, jobID string) bool {
+	if len(jobID) > maxStreamJobIDLen {
+		respondError(c, apperr.Validation("Invalid job_id", "job_id must be at most 36 characters"))
+		return false
+	}
+	if _, err := uuid.Parse(jobID); err != nil || len(jobID) != maxStreamJobIDLen {
+		respondError(c, apperr.Validation("Invalid job_id", "job_id must be a UUID"))
+		return false
+	}
+	if userID := c.Query("user_id"); len(userID) > maxStreamUserIDLen {
+		respondError(c, apperr.Validation("Invalid user_id", "user_id must be at most 128 characters"))
+		return false
+	}
+	return true
+}
+
+// authorizeJobStream looks up the job a /ws client subscribes to. With token
+// auth enabled the job must exist and belong to the caller unless the caller
+// is an admin; without it a missing job is not an error. On failure it
+// writes the error response and returns false.
+func (h *Handler) authorizeJobStream(c *gin.Context, jobID string) (*models.Job, bool) {
+	job, err := h.store.GetJobTyped(c.Request.Context(), jobID)
+	claims, authenticated := middleware.ClaimsFromContext(c.Request.Context())
+	if !authenticated {
+		if err != nil {
+			return nil, true
+		}
+		return job, true
+	}
+
+	if err != nil {
+		respondError(c, jobLookupError(jobID, err))
+		return nil, false
+	}
+	if job.UserID != claims.User() && !claims.HasRole(middleware.RoleAdmin) {
+		respondError(c, apperr.Forbidden("Forbidden", "job "+jobID+" belongs to another user"))
+		return nil, false
+	}
+	return job, true
+}
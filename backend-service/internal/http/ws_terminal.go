@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// TerminalFrame is sent to a WebSocket client subscribing to a job that has
+// already finished, just before the connection is closed
+type TerminalFrame struct {
+	Type     string          `json:"type"`
+	JobID    string          `json:"job_id"`
+	Status   string          `json:"status"`
+	Progress int             `json:"progress"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// terminalFrame reports whether a job has already finished and, if so, the
+// frame carrying its outcome, cut down to the scheme's result allowlist
+// unless admin is set. A nil job (lookup failed) counts as not finished so
+// the client is subscribed as before.
+func (h *Handler) terminalFrame(ctx context.Context, job *models.Job, admin bool) ([]byte, bool) {
+	if job == nil {
+		return nil, false
+	}
+	jobID := job.JobID
+	switch job.Status {
+	case "SUCCESS", "FAILED", "CANCELLED":
+	default:
+		return nil, false
+	}
+
+	frame := TerminalFrame{Type: "terminal", JobID: jobID, Status: job.Status, Progress: job.Progress, Error: job.ErrorLog}
+	if job.Status == "SUCCESS" {
+		if result, err := h.store.GetResult(ctx, jobID); err == nil && json.Valid([]byte(result)) {
+			if allowed := h.schemeAllowlist(job.SchemeCode, admin); allowed != nil {
+				frame.Result = filterResultJSON(result, allowed)
+			} else {
+				frame.Result = json.RawMessage(result)
+			}
+		}
+	}
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// ReplayFrame is the hub's replay source: a job that has finished gets its
+// terminal frame, any other job its last cached progress. The job is looked
+// up again after the subscription is registered, which closes the window in
+// which a job finishing just after the /ws pre-check would leave its client
+// waiting for updates that never come. The hub does not know who the
+// subscriber is, so the result is always cut down as for a non-admin; an
+// admin can still fetch it whole from the result endpoint.
+func (h *Handler) ReplayFrame(ctx context.Context, jobID string) ([]byte, bool) {
+	if job, err := h.store.GetJobTyped(ctx, jobID); err == nil {
+		if final, finished := h.terminalFrame(ctx, job, false); finished {
+			return final, true
+		}
+	}
+	return h.jobs.LatestProgress(ctx, jobID), false
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// maxZombieList caps the zombies one listing returns
+const maxZombieList = 1000
+
+// ZombieTaskResponse is a job zombie detection would fail
+// @Description RUNNING job stuck past its timeout
+type ZombieTaskResponse struct {
+	storage.ZombieTask
+	// StuckSeconds is how long ago the job last updated (or progressed,
+	// under the progress strategy)
+	StuckSeconds int64 `json:"stuck_seconds" example:"2700"`
+}
+
+// zombieTimeouts returns the timeouts zombie detection uses for a request:
+// the configured ones, or timeout_min for every scheme when it is given
+func (h *Handler) zombieTimeouts(c *gin.Context) (time.Duration, map[string]time.Duration, bool) {
+	raw := c.Query("timeout_min")
+	if raw == "" {
+		return h.cfg.ZombieTimeout, h.cfg.ZombieTimeoutOverrides, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		respondError(c, apperr.Validation("Invalid timeout_min", "timeout_min must be a positive number of minutes"))
+		return 0, nil, false
+	}
+	return time.Duration(n) * time.Minute, nil, true
+}
+
+// ListZombies godoc
+// @Summary      List zombie jobs
+// @Description  Lists the RUNNING jobs the zombie cleanup would fail, longest stuck first, without modifying them.
+// @Description  timeout_min replaces the configured timeouts, including per-scheme overrides. Requires the admin API key.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        timeout_min  query     int  false  "Stuck for at least this many minutes (default: configured zombie timeouts)"
+// @Param        limit        query     int  false  "Maximum jobs returned (1-1000)"  default(100)
+// @Success      200  {object}  map[string]any  "Returns strategy, count and zombies ([]ZombieTaskResponse)"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/admin/zombies [get]
+func (h *Handler) ListZombies(c *gin.Context) {
+	timeout, overrides, ok := h.zombieTimeouts(c)
+	if !ok {
+		return
+	}
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxZombieList {
+			respondError(c, apperr.Validation("Invalid limit", "limit must be between 1 and "+strconv.Itoa(maxZombieList)))
+			return
+		}
+		limit = n
+	}
+
+	tasks, err := h.store.ListZombieTasks(c.Request.Context(), h.cfg.ZombieStrategy, timeout, overrides, limit)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to list zombie jobs", err.Error()))
+		return
+	}
+	now := time.Now()
+	zombies := make([]ZombieTaskResponse, len(tasks))
+	for i, task := range tasks {
+		zombies[i] = ZombieTaskResponse{ZombieTask: task, StuckSeconds: int64(now.Sub(task.LastUpdate).Seconds())}
+	}
+	respond(c, http.StatusOK, gin.H{
+		"strategy": h.cfg.ZombieStrategy,
+		"count":    len(zombies),
+		"zombies":  zombies,
+	})
+}
+
+// ReapZombies godoc
+// @Summary      Fail zombie jobs now
+// @Description  Marks every RUNNING job stuck past its timeout as failed immediately, as the scheduled zombie
+// @Description  cleanup would, without confirming with the algorithm service, then force-cancels the reaped tasks
+// @Description  there. Jobs that finish before they are marked keep their status. timeout_min replaces the
+// @Description  configured timeouts, including per-scheme overrides. Requires the admin API key.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        timeout_min  query     int  false  "Stuck for at least this many minutes (default: configured zombie timeouts)"
+// @Success      200  {object}  map[string]any  "Returns reaped (count) and job_ids"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /api/v1/admin/zombies/reap [post]
+func (h *Handler) ReapZombies(c *gin.Context) {
+	timeout, overrides, ok := h.zombieTimeouts(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	jobIDs, err := h.store.FindZombieTasks(ctx, h.cfg.ZombieStrategy, timeout, overrides)
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to find zombie jobs", err.Error()))
+		return
+	}
+	reaped, err := h.store.MarkZombieAsFailed(ctx, jobIDs)
+	if len(reaped) > 0 {
+		h.jobs.ZombiesFailed(reaped)
+		h.cancelReaped(ctx, reaped)
+		h.logger.Warn("Reaped zombie jobs on demand", zap.Int("count", len(reaped)), zap.Strings("job_ids", reaped))
+	}
+	if err != nil {
+		respondError(c, apperr.Internal("Failed to mark zombie jobs as failed", err.Error()))
+		return
+	}
+	if reaped == nil {
+		reaped = []string{}
+	}
+	respond(c, http.StatusOK, gin.H{"reaped": len(reaped), "job_ids": reaped})
+}
+
+// cancelReaped force-cancels reaped jobs on the algorithm service they were
+// routed to, so they stop running against a failed job. The jobs are failed
+// whatever the outcome.
+func (h *Handler) cancelReaped(ctx context.Context, jobIDs []string) {
+	for _, jobID := range jobIDs {
+		algo := h.algo
+		if len(h.cfg.AlgoTargets) > 0 {
+			algo, _ = h.algoFor(h.jobs.AlgoTarget(ctx, jobID))
+		}
+		if algo == nil {
+			continue
+		}
+		resp, err := algo.CancelTask(ctx, h.jobs.AlgoTaskID(ctx, jobID), true)
+		switch {
+		case err != nil:
+			h.logger.Warn("Failed to cancel reaped zombie job", zap.String("job_id", jobID), zap.Error(err))
+		case !resp.GetAccepted():
+			h.logger.Info("Algorithm service declined to cancel reaped zombie job",
+				zap.String("job_id", jobID), zap.String("status", resp.GetStatus()), zap.String("message", resp.GetMessage()))
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/electric-power/backend-service/internal/middleware"
+	"github.com/electric-power/backend-service/internal/storage"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// asAdmin sends a request with the admin API key of newAdminTestRouter
+func asAdmin(r http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := newJSONRequest(method, path, "")
+	req.Header.Set(middleware.AdminAPIKeyHeader, "admin-secret")
+	return serve(r, req)
+}
+
+// TestListZombies tests that stuck jobs are listed with their scheme and
+// stuck time, under the configured timeouts, and left untouched
+func TestListZombies(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ZombieTimeoutOverrides = map[string]time.Duration{"SCM-WF01": 4 * time.Hour}
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	w := env.do(r, "GET", "/api/v1/admin/zombies", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	now := time.Now()
+	env.db.ExpectQuery(`SELECT job_id, scheme_code, user_id, progress, updated_at AS last_update\s+FROM t_algo_jobs WHERE status = 'RUNNING' AND updated_at < CASE scheme_code WHEN \? THEN \? ELSE \? END AND scheme_code <> \? ORDER BY last_update ASC LIMIT \?`).
+		WithArgs("SCM-WF01", sqlmock.AnyArg(), sqlmock.AnyArg(), storage.PipelineSchemeCode, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "scheme_code", "user_id", "progress", "last_update"}).
+			AddRow("job-1", "KBM-WF01", "alice", 40, now.Add(-45*time.Minute)).
+			AddRow("job-2", "SCM-WF01", "bob", 10, now.Add(-5*time.Hour)))
+
+	res := asAdmin(r, "GET", "/api/v1/admin/zombies")
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	var resp struct {
+		Strategy string               `json:"strategy"`
+		Count    int                  `json:"count"`
+		Zombies  []ZombieTaskResponse `json:"zombies"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &resp))
+	assert.Equal(t, "updated_at", resp.Strategy)
+	require.Equal(t, 2, resp.Count)
+	assert.Equal(t, "job-1", resp.Zombies[0].JobID)
+	assert.Equal(t, "KBM-WF01", resp.Zombies[0].SchemeCode)
+	assert.Equal(t, 40, resp.Zombies[0].Progress)
+	assert.InDelta(t, 45*60, resp.Zombies[0].StuckSeconds, 5)
+	assert.Equal(t, "SCM-WF01", resp.Zombies[1].SchemeCode)
+	assert.InDelta(t, 5*3600, resp.Zombies[1].StuckSeconds, 5)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestListZombiesTimeoutParam tests that timeout_min replaces every
+// configured timeout and that bad parameters are rejected
+func TestListZombiesTimeoutParam(t *testing.T) {
+	cfg := DefaultHandlerConfig()
+	cfg.ZombieTimeoutOverrides = map[string]time.Duration{"SCM-WF01": 4 * time.Hour}
+	env := newTestEnvWithConfig(t, cfg)
+	r := newAdminTestRouter(env)
+
+	env.db.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \? ORDER BY last_update ASC LIMIT \?`).
+		WithArgs(sqlmock.AnyArg(), storage.PipelineSchemeCode, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "scheme_code", "user_id", "progress", "last_update"}))
+	res := asAdmin(r, "GET", "/api/v1/admin/zombies?timeout_min=5&limit=10")
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.JSONEq(t, `{"strategy":"updated_at","count":0,"zombies":[]}`, res.Body.String())
+
+	for _, query := range []string{"timeout_min=0", "timeout_min=abc", "limit=0", "limit=1001"} {
+		res := asAdmin(r, "GET", "/api/v1/admin/zombies?"+query)
+		assert.Equal(t, http.StatusBadRequest, res.Code, query)
+	}
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestZombieRoutesWithJWT tests that with token auth enabled the zombie
+// routes still take the admin API key alone, as well as an admin token
+func TestZombieRoutesWithJWT(t *testing.T) {
+	env := newTestEnv(t)
+	cfg := DefaultRouterConfig()
+	cfg.EnableSwagger = false
+	cfg.RateLimitRPS = 0
+	cfg.AdminAPIKey = "admin-secret"
+	cfg.JWTSecret = "jwt-secret"
+	r := NewRouterWithConfig(env.handler, env.hub, nil, zap.NewNop(), cfg)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	userToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "u1", ExpiresAt: exp})
+	require.NoError(t, err)
+	adminToken, err := middleware.SignToken(cfg.JWTSecret, middleware.Claims{UserID: "ops", Roles: []string{middleware.RoleAdmin}, ExpiresAt: exp})
+	require.NoError(t, err)
+	withToken := func(token string) int {
+		req := newJSONRequest("GET", "/api/v1/admin/zombies", "")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return serve(r, req).Code
+	}
+	expectList := func() {
+		env.db.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \? ORDER BY last_update ASC LIMIT \?`).
+			WillReturnRows(sqlmock.NewRows([]string{"job_id", "scheme_code", "user_id", "progress", "last_update"}))
+	}
+
+	expectList()
+	res := asAdmin(r, "GET", "/api/v1/admin/zombies")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	expectList()
+	assert.Equal(t, http.StatusOK, withToken(adminToken))
+	assert.Equal(t, http.StatusUnauthorized, withToken(userToken))
+	assert.Equal(t, http.StatusUnauthorized, env.do(r, "GET", "/api/v1/admin/zombies", nil).Code)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
+
+// TestReapZombies tests that reaping fails the zombies still RUNNING,
+// announces only those and force-cancels them on the algorithm service
+func TestReapZombies(t *testing.T) {
+	env := newTestEnv(t)
+	r := newAdminTestRouter(env)
+	cancelled := make(chan *pb.CancelRequest, 4)
+	env.withAlgo(t, &fakeAlgo{cancel: func(_ context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+		cancelled <- req
+		return &pb.CancelResponse{Accepted: true, Status: "CANCELLED"}, nil
+	}})
+
+	w := env.do(r, "POST", "/api/v1/admin/zombies/reap", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	env.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \?`).
+		WithArgs(sqlmock.AnyArg(), storage.PipelineSchemeCode).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1").AddRow("job-2"))
+	// job-2 reported its result after it was found
+	env.db.ExpectBegin()
+	env.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE job_id IN \(\?, \?\) AND status = 'RUNNING' FOR UPDATE`).
+		WithArgs("job-1", "job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+	env.db.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED', error_log = 'Task timeout - marked as zombie'`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	env.db.ExpectCommit()
+	env.db.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"algo_task_id"}).AddRow("algo-1"))
+
+	res := asAdmin(r, "POST", "/api/v1/admin/zombies/reap")
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.JSONEq(t, `{"reaped":1,"job_ids":["job-1"]}`, res.Body.String())
+	select {
+	case req := <-cancelled:
+		assert.Equal(t, "algo-1", req.TaskId)
+		assert.True(t, req.Force)
+	default:
+		t.Fatal("the reaped job was not cancelled on the algorithm service")
+	}
+
+	require.NoError(t, env.handler.jobs.WaitHooks(context.Background()))
+
+	// Nothing left to reap
+	env.db.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING'`).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	res = asAdmin(r, "POST", "/api/v1/admin/zombies/reap?timeout_min=60")
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.JSONEq(t, `{"reaped":0,"job_ids":[]}`, res.Body.String())
+	assert.Empty(t, cancelled)
+	assert.NoError(t, env.db.ExpectationsWereMet())
+}
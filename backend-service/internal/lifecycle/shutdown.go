@@ -0,0 +1,123 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Shutdown priorities for the built-in components. Hooks run in ascending
+// priority order, so servers stop accepting work before the things they
+// depend on are torn down. The HTTP API drains first, while the result server
+// is still accepting the callbacks its in-flight requests may be waiting on.
+const (
+	PriorityHTTP     = 5
+	PriorityServers  = 10
+	PriorityWorkers  = 20
+	PriorityHub      = 30
+	PriorityClients  = 40
+	PriorityStorages = 50
+)
+
+// hookGrace is how long each hook still runs once the shutdown deadline has
+// passed, so storages are closed even after a slow earlier hook
+const hookGrace = time.Second
+
+// ShutdownFunc releases a component's resources, honoring the context deadline
+type ShutdownFunc func(ctx context.Context) error
+
+type hook struct {
+	name     string
+	priority int
+	seq      int
+	fn       ShutdownFunc
+}
+
+// ShutdownManager runs registered shutdown hooks in priority order under a shared deadline
+type ShutdownManager struct {
+	mu     sync.Mutex
+	hooks  []hook
+	logger *zap.Logger
+	done   bool
+}
+
+// NewShutdownManager creates an empty shutdown manager
+func NewShutdownManager(logger *zap.Logger) *ShutdownManager {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &ShutdownManager{logger: logger}
+}
+
+// Register adds a hook. Hooks with equal priority run in registration order.
+func (m *ShutdownManager) Register(name string, priority int, fn ShutdownFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, priority: priority, seq: len(m.hooks), fn: fn})
+}
+
+// RegisterCloser adds a hook for components that only expose Close() error
+func (m *ShutdownManager) RegisterCloser(name string, priority int, closer func() error) {
+	m.Register(name, priority, func(context.Context) error { return closer() })
+}
+
+// Shutdown runs every hook once, in order. A hook that overruns the deadline is
+// abandoned so the remaining hooks still get a chance to run, each within a
+// short grace period of its own; the overrun and the errors of any failing
+// hooks are joined into the returned error.
+func (m *ShutdownManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.done {
+		m.mu.Unlock()
+		return nil
+	}
+	m.done = true
+	hooks := make([]hook, len(m.hooks))
+	copy(hooks, m.hooks)
+	m.mu.Unlock()
+
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].priority != hooks[j].priority {
+			return hooks[i].priority < hooks[j].priority
+		}
+		return hooks[i].seq < hooks[j].seq
+	})
+
+	var errs []error
+	for _, h := range hooks {
+		if err := m.runHook(ctx, h); err != nil {
+			m.logger.Warn("Shutdown hook failed", zap.String("hook", h.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		m.logger.Info("Shutdown hook completed", zap.String("hook", h.name))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *ShutdownManager) runHook(ctx context.Context, h hook) error {
+	if ctx.Err() != nil {
+		m.logger.Warn("Shutdown deadline passed, running hook within its grace period",
+			zap.String("hook", h.name), zap.Duration("grace", hookGrace))
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), hookGrace)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
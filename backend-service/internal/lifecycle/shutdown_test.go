@@ -0,0 +1,110 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownRunsHooksInPriorityOrder(t *testing.T) {
+	m := NewShutdownManager(nil)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) ShutdownFunc {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	m.Register("storage", PriorityStorages, record("storage"))
+	m.Register("hub", PriorityHub, record("hub"))
+	m.Register("grpc", PriorityServers, record("grpc"))
+	m.Register("scheduler", PriorityWorkers, record("scheduler"))
+	m.Register("http", PriorityHTTP, record("http"))
+
+	err := m.Shutdown(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http", "grpc", "scheduler", "hub", "storage"}, order)
+}
+
+func TestShutdownSlowHookDoesNotExceedDeadline(t *testing.T) {
+	m := NewShutdownManager(nil)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	m.Register("slow", PriorityServers, func(context.Context) error {
+		// Ignores ctx on purpose, like a component with a blocking Close
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := m.Shutdown(ctx)
+	elapsed := time.Since(start)
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, elapsed, 500*time.Millisecond)
+}
+
+func TestShutdownRunsLaterHooksAfterDeadline(t *testing.T) {
+	m := NewShutdownManager(nil)
+
+	release := make(chan struct{})
+	defer close(release)
+	m.Register("slow", PriorityServers, func(context.Context) error {
+		<-release
+		return nil
+	})
+	closed := false
+	m.Register("storage", PriorityStorages, func(ctx context.Context) error {
+		// Runs with a live context of its own after the shared deadline
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		closed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := m.Shutdown(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "slow:")
+	assert.NotContains(t, err.Error(), "storage:")
+	assert.True(t, closed, "storage hook was skipped after the deadline")
+}
+
+func TestShutdownCollectsErrorsAndRunsOnce(t *testing.T) {
+	m := NewShutdownManager(nil)
+
+	calls := 0
+	m.Register("failing", PriorityServers, func(context.Context) error {
+		calls++
+		return errors.New("boom")
+	})
+	m.RegisterCloser("closer", PriorityStorages, func() error {
+		calls++
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+	assert.ErrorContains(t, err, "failing: boom")
+	assert.Equal(t, 2, calls)
+
+	assert.NoError(t, m.Shutdown(context.Background()))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAdminRouter(apiKey string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", AdminAuth(apiKey), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+// TestAdminAuth tests header and query parameter keys
+func TestAdminAuth(t *testing.T) {
+	r := newAdminRouter("secret")
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{"missing key", "/admin", "", http.StatusUnauthorized},
+		{"wrong key", "/admin", "nope", http.StatusUnauthorized},
+		{"header key", "/admin", "secret", http.StatusOK},
+		{"query key", "/admin?api_key=secret", "", http.StatusOK},
+		{"wrong query key", "/admin?api_key=nope", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(AdminAPIKeyHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+// TestAdminAuthWithoutKeyRefusesAll tests that admin routes stay closed when no key is configured
+func TestAdminAuthWithoutKeyRefusesAll(t *testing.T) {
+	r := newAdminRouter("")
+	req := httptest.NewRequest(http.MethodGet, "/admin?api_key=", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// TestAdminOnlyHeader tests that only admins may set a restricted header
+func TestAdminOnlyHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/jobs", AdminOnlyHeader("X-Algo-Target", "secret"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	tests := []struct {
+		name   string
+		target string
+		key    string
+		want   int
+	}{
+		{"no header", "", "", http.StatusOK},
+		{"header without key", "canary", "", http.StatusForbidden},
+		{"header with wrong key", "canary", "nope", http.StatusForbidden},
+		{"header with admin key", "canary", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+			if tt.target != "" {
+				req.Header.Set("X-Algo-Target", tt.target)
+			}
+			if tt.key != "" {
+				req.Header.Set(AdminAPIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DefaultRedactedKeys lists JSON keys whose values never reach the logs
+var DefaultRedactedKeys = []string{"password", "token", "secret", "authorization", "api_key", "apikey", "access_key", "credential"}
+
+const redactedValue = "[REDACTED]"
+
+// BodyLogConfig controls request body logging
+type BodyLogConfig struct {
+	MaxBytes   int      // Bytes of body captured at most
+	RedactKeys []string // Case-insensitive JSON keys to redact
+}
+
+// BodyLogger logs the request body for responses with status >= 400 to help
+// reproduce failed submissions. The body is captured up front (up to MaxBytes)
+// and then stitched back in front of the remaining stream, so handlers still
+// read the complete body.
+func BodyLogger(logger *zap.Logger, cfg BodyLogConfig) gin.HandlerFunc {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 4096
+	}
+	if len(cfg.RedactKeys) == 0 {
+		cfg.RedactKeys = DefaultRedactedKeys
+	}
+	redact := make(map[string]struct{}, len(cfg.RedactKeys))
+	for _, k := range cfg.RedactKeys {
+		redact[strings.ToLower(k)] = struct{}{}
+	}
+	pattern := buildRedactPattern(cfg.RedactKeys)
+
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		captured, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(cfg.MaxBytes)+1))
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = &replayBody{
+			Reader: io.MultiReader(bytes.NewReader(captured), c.Request.Body),
+			closer: c.Request.Body,
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 400 {
+			return
+		}
+
+		truncated := len(captured) > cfg.MaxBytes
+		if truncated {
+			captured = captured[:cfg.MaxBytes]
+		}
+
+		logger.Warn("Failed request body",
+			zap.Int("status", status),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("request_id", c.GetString(RequestIDKey)),
+			zap.Bool("truncated", truncated),
+			zap.String("body", redactBody(captured, truncated, redact, pattern)),
+		)
+	}
+}
+
+type replayBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *replayBody) Close() error {
+	return b.closer.Close()
+}
+
+// redactBody masks sensitive values. Complete JSON documents are rewritten
+// structurally; truncated or non-JSON bodies fall back to finding "key":
+// pairs with a regex and masking the value after each, whether a string,
+// number, literal or nested object or array. A value cut off by truncation
+// is masked to the end of the body.
+func redactBody(body []byte, truncated bool, keys map[string]struct{}, pattern *regexp.Regexp) string {
+	if !truncated {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err == nil {
+			out, err := json.Marshal(redactValue(doc, keys))
+			if err == nil {
+				return string(out)
+			}
+		}
+	}
+	s := string(body)
+	var out strings.Builder
+	last := 0
+	for _, m := range pattern.FindAllStringIndex(s, -1) {
+		if m[0] < last {
+			continue // the key is inside a value already masked
+		}
+		end := jsonValueEnd(s, m[1])
+		out.WriteString(s[last:m[1]])
+		out.WriteString(`"` + redactedValue + `"`)
+		last = end
+	}
+	out.WriteString(s[last:])
+	return out.String()
+}
+
+// jsonValueEnd returns the end of the JSON value starting at s[start], or
+// len(s) when the value is cut off
+func jsonValueEnd(s string, start int) int {
+	depth, inString := 0, false
+	for i := start; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case inString:
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inString = false
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		case ch == '"':
+			inString = true
+		case ch == '{' || ch == '[':
+			depth++
+		case ch == '}' || ch == ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case depth == 0 && (ch == ',' || ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'):
+			return i
+		}
+	}
+	return len(s)
+}
+
+func redactValue(v any, keys map[string]struct{}) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if _, ok := keys[strings.ToLower(k)]; ok {
+				t[k] = redactedValue
+				continue
+			}
+			t[k] = redactValue(child, keys)
+		}
+		return t
+	case []any:
+		for i, child := range t {
+			t[i] = redactValue(child, keys)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func buildRedactPattern(keys []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(k))
+	}
+	return regexp.MustCompile(`(?i)"(?:` + strings.Join(quoted, "|") + `)"\s*:\s*`)
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newBodyLoggerRouter(cfg BodyLogConfig) (*gin.Engine, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	r := gin.New()
+	r.Use(BodyLogger(zap.New(core), cfg))
+	r.POST("/jobs", func(c *gin.Context) {
+		var req struct {
+			Scheme string `json:"scheme" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"scheme": req.Scheme})
+	})
+	return r, logs
+}
+
+func TestBodyLoggerLogsFailedRequest(t *testing.T) {
+	r, logs := newBodyLoggerRouter(BodyLogConfig{})
+
+	body := `{"data_id":"d1","params":{"password":"hunter2","threshold":0.9}}`
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, 1, logs.Len())
+
+	logged := logs.All()[0].ContextMap()["body"].(string)
+	assert.Contains(t, logged, `"data_id":"d1"`)
+	assert.Contains(t, logged, `"password":"[REDACTED]"`)
+	assert.NotContains(t, logged, "hunter2")
+}
+
+func TestBodyLoggerSkipsSuccessAndPreservesBody(t *testing.T) {
+	r, logs := newBodyLoggerRouter(BodyLogConfig{})
+
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"scheme":"KBM-WF01"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "KBM-WF01", "handler must still read the full body")
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestBodyLoggerCapsAndRedactsTruncatedBody(t *testing.T) {
+	r, logs := newBodyLoggerRouter(BodyLogConfig{MaxBytes: 40})
+
+	body := `{"token":"abcdefgh","data_id":"` + strings.Repeat("x", 100) + `"}`
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, 1, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, true, fields["truncated"])
+	assert.NotContains(t, fields["body"], "abcdefgh")
+	assert.Contains(t, fields["body"], `"token":"[REDACTED]"`)
+}
+
+func TestRedactTruncatedBodyMasksEveryValueType(t *testing.T) {
+	keys := map[string]struct{}{"pin": {}, "secret": {}, "token": {}, "credential": {}}
+	pattern := buildRedactPattern([]string{"pin", "secret", "token", "credential"})
+
+	body := `{"pin": 1234, "secret": {"user": "a", "pass": "b}"}, "token": ["x", "y"], "data_id": "d1", "credential": {"key": "cut`
+	assert.Equal(t,
+		`{"pin": "[REDACTED]", "secret": "[REDACTED]", "token": "[REDACTED]", "data_id": "d1", "credential": "[REDACTED]"`,
+		redactBody([]byte(body), true, keys, pattern))
+	assert.Equal(t, `not json; "secret":"[REDACTED]", "pin":"[REDACTED]"`,
+		redactBody([]byte(`not json; "secret":null, "pin":true`), false, keys, pattern))
+}
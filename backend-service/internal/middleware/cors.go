@@ -2,12 +2,20 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CORS middleware enables Cross-Origin Resource Sharing
-func CORS() gin.HandlerFunc {
+// CORS middleware enables Cross-Origin Resource Sharing. extraHeaders are
+// allowed in addition to the standard set, e.g. a custom idempotency header.
+func CORS(extraHeaders ...string) gin.HandlerFunc {
+	allowHeaders := "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, X-Request-ID, X-User-ID, X-Response-Envelope, X-API-Key"
+	for _, h := range extraHeaders {
+		if h != "" && !strings.Contains(allowHeaders, h) {
+			allowHeaders += ", " + h
+		}
+	}
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
 		if origin == "" {
@@ -16,9 +24,9 @@ func CORS() gin.HandlerFunc {
 
 		c.Header("Access-Control-Allow-Origin", origin)
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, X-Request-ID, X-User-ID")
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
 		c.Header("Access-Control-Allow-Methods", "POST, HEAD, PATCH, OPTIONS, GET, PUT, DELETE")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, "+IdempotentReplayHeader)
 
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// abortWithError aborts the request with the body handlers write for err:
+// its title, message and application code, plus any extra fields
+func abortWithError(c *gin.Context, err *apperr.Error, extra gin.H) {
+	body := gin.H{"error": err.Title, "message": err.Message, "code": err.Code()}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.AbortWithStatusJSON(err.Status(), body)
+}
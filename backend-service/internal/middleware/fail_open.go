@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FailOpenLogInterval is the least time between two warnings of a middleware
+// letting requests through while Redis is down
+const FailOpenLogInterval = time.Minute
+
+// errRedisUnavailable is logged when requests pass because the cache's
+// health monitor reports Redis down
+var errRedisUnavailable = errors.New("redis unavailable")
+
+// failOpenLog warns that a middleware is letting requests through without
+// Redis, at most once per FailOpenLogInterval
+type failOpenLog struct {
+	logger     *zap.Logger
+	middleware string
+	last       atomic.Int64
+}
+
+func newFailOpenLog(logger *zap.Logger, middleware string) *failOpenLog {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &failOpenLog{logger: logger, middleware: middleware}
+}
+
+func (l *failOpenLog) warn(err error) {
+	now := time.Now().UnixNano()
+	last := l.last.Load()
+	if last != 0 && now-last < int64(FailOpenLogInterval) {
+		return
+	}
+	if !l.last.CompareAndSwap(last, now) {
+		return
+	}
+	l.logger.Warn("Redis unavailable, requests pass without "+l.middleware, zap.Error(err))
+}
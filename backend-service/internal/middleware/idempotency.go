@@ -1,79 +1,321 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
-	"net/http"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/electric-power/backend-service/internal/apperr"
 	"github.com/electric-power/backend-service/internal/storage"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 const (
-	IdempotencyHeader = "X-Request-ID"
-	IdempotencyTTL    = 10 * time.Minute
+	// IdempotencyHeader is the default header carrying a client's idempotency key
+	IdempotencyHeader = RequestIDHeader
+	// IdempotencyTTL is the default time a response is replayed for
+	IdempotencyTTL = 10 * time.Minute
+	// IdempotencyClaimTTL is the default time a key is held by a request
+	// still being processed
+	IdempotencyClaimTTL = time.Minute
+	// IdempotentReplayHeader marks a response replayed from an earlier request
+	IdempotentReplayHeader = "X-Idempotent-Replay"
+	// IdempotencyKeyNS is the default namespace of idempotency keys in Redis
+	IdempotencyKeyNS = "idempotency:"
+	// RateLimitKeyNS is the default namespace of rate limit counters in Redis
+	RateLimitKeyNS = "ratelimit:"
 )
 
+// IdempotencyConfig holds idempotency middleware configuration
+type IdempotencyConfig struct {
+	// Header carries the client-supplied idempotency key
+	Header string
+	// ResponseTTL is how long a successful response is replayed; older
+	// responses are never replayed and the request is processed afresh
+	ResponseTTL time.Duration
+	// ClaimTTL is how long a key is held while its first request is
+	// processed, so a request that never finishes does not block the key
+	ClaimTTL time.Duration
+	// RetryAfter is suggested to duplicates arriving while the first request
+	// is still being processed
+	RetryAfter time.Duration
+	// Logger, if set, is warned when requests pass undeduplicated because
+	// Redis is down
+	Logger *zap.Logger
+	// KeyNS prefixes the Redis key of each idempotency key
+	KeyNS string
+}
+
+// DefaultIdempotencyConfig returns the default idempotency configuration
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		Header:      IdempotencyHeader,
+		ResponseTTL: IdempotencyTTL,
+		ClaimTTL:    IdempotencyClaimTTL,
+		RetryAfter:  time.Second,
+		KeyNS:       IdempotencyKeyNS,
+	}
+}
+
+// Idempotency states stored under a key
+const (
+	idempotencyProcessing = "processing"
+	idempotencyCompleted  = "completed"
+)
+
+// idempotencyRecord is what is stored under an idempotency key: a claim while
+// the first request is processed, then its response
+type idempotencyRecord struct {
+	State       string `json:"state"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	// CompletedAt is when the response was stored, in Unix milliseconds
+	CompletedAt int64 `json:"completed_at,omitempty"`
+}
+
+// stale reports whether a completed record is older than ttl. Records
+// written before CompletedAt existed rely on the Redis expiry alone.
+func (r idempotencyRecord) stale(ttl time.Duration, now time.Time) bool {
+	return r.CompletedAt > 0 && now.Sub(time.UnixMilli(r.CompletedAt)) >= ttl
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
 // Idempotency middleware ensures that duplicate requests with the same X-Request-ID
 // are not processed multiple times. This is critical for safety-critical operations
 // like applying decision plans (per design doc section 4.1).
 func Idempotency(cache *storage.RedisCache) gin.HandlerFunc {
+	return IdempotencyWithConfig(cache, DefaultIdempotencyConfig())
+}
+
+// IdempotencyWithConfig is Idempotency keyed by cfg.Header. Only a key the
+// client sent counts; requests without one are never deduplicated. Keys are
+// per client and route, so the middleware must run after JWTAuth.
+//
+// The first request with a key is processed and, if it succeeds, its response
+// is stored for cfg.ResponseTTL; duplicates within that time get it replayed
+// with X-Idempotent-Replay, later ones are processed afresh. A duplicate of a
+// request still being processed gets 409 with Retry-After, and a failed
+// request releases its key so the client can retry. If Redis is unavailable
+// requests are processed without deduplication; while the cache's health
+// monitor reports it down, Redis is not tried at all.
+func IdempotencyWithConfig(cache *storage.RedisCache, cfg IdempotencyConfig) gin.HandlerFunc {
+	defaults := DefaultIdempotencyConfig()
+	if cfg.Header == "" {
+		cfg.Header = defaults.Header
+	}
+	if cfg.ResponseTTL <= 0 {
+		cfg.ResponseTTL = defaults.ResponseTTL
+	}
+	if cfg.ClaimTTL <= 0 {
+		cfg.ClaimTTL = defaults.ClaimTTL
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = defaults.RetryAfter
+	}
+	if cfg.KeyNS == "" {
+		cfg.KeyNS = defaults.KeyNS
+	}
+	retryAfter := strconv.Itoa(int((cfg.RetryAfter + time.Second - 1) / time.Second))
+	failOpen := newFailOpenLog(cfg.Logger, "idempotency")
+
 	return func(c *gin.Context) {
-		requestID := c.GetHeader(IdempotencyHeader)
+		requestID := IdempotencyKey(c, cfg.Header)
 		if requestID == "" {
 			c.Next()
 			return
 		}
+		if !cache.IsAvailable() {
+			failOpen.warn(errRedisUnavailable)
+			c.Next()
+			return
+		}
 
 		ctx := c.Request.Context()
-		key := "idempotency:" + requestID
-
-		// Check if request was already processed
-		var existing string
-		err := cache.GetJSON(ctx, key, &existing)
-		if err == nil && existing != "" {
-			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
-				"error":      "Duplicate request",
-				"request_id": requestID,
-				"message":    "This request has already been processed",
-			})
+		key := idempotencyStoreKey(c, cfg.KeyNS, requestID)
+
+		claimed, err := cache.SetNX(ctx, key, idempotencyRecord{State: idempotencyProcessing}, cfg.ClaimTTL)
+		if err != nil {
+			failOpen.warn(err)
+			c.Next()
+			return
+		}
+		if !claimed {
+			var existing idempotencyRecord
+			raw, err := cache.GetBytes(ctx, key)
+			if err == nil {
+				err = json.Unmarshal(raw, &existing)
+			}
+			if err != nil {
+				// The key expired in between or Redis failed; process the request
+				c.Next()
+				return
+			}
+			if existing.State == idempotencyCompleted && !existing.stale(cfg.ResponseTTL, time.Now()) {
+				c.Header(IdempotentReplayHeader, "true")
+				c.Data(existing.Status, existing.ContentType, existing.Body)
+				c.Abort()
+				return
+			}
+			if existing.State == idempotencyCompleted {
+				// Too old to replay: process it afresh, unless a concurrent
+				// duplicate has already reclaimed the key
+				claimed, _ = cache.CompareAndSwapJSON(ctx, key, raw, idempotencyRecord{State: idempotencyProcessing}, cfg.ClaimTTL)
+			}
+		}
+		if !claimed {
+			c.Header("Retry-After", retryAfter)
+			abortWithError(c, apperr.Conflict("Duplicate request", "This request is still being processed"),
+				gin.H{"request_id": requestID})
 			return
 		}
 
-		// Mark request as processing
-		_ = cache.SetJSON(ctx, key, "processing", IdempotencyTTL)
-
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
 		c.Next()
 
-		// Mark request as completed
-		_ = cache.SetJSON(ctx, key, "completed", IdempotencyTTL)
+		// The outcome is recorded even if the client has gone away
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status < 200 || status >= 300 {
+			_ = cache.Delete(ctx, key)
+			return
+		}
+		_ = cache.SetJSON(ctx, key, idempotencyRecord{
+			State:       idempotencyCompleted,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			CompletedAt: time.Now().UnixMilli(),
+		}, cfg.ResponseTTL)
 	}
 }
 
-// RateLimiter implements a simple sliding window rate limiter using Redis.
-// Limits requests per IP/user to prevent abuse.
+// idempotencyStoreKey is the Redis key of requestID, scoped to the client
+// (the authenticated user, or the client IP) and the route so that the same
+// key sent by another client or to another endpoint is a different request
+func idempotencyStoreKey(c *gin.Context, ns, requestID string) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return ns + rateLimitClient(c) + ":" + c.Request.Method + ":" + route + ":" + requestID
+}
+
+// IdempotencyKey returns the idempotency key the client sent in header, or ""
+// when there is none. A correlation ID generated by RequestID is never a key.
+func IdempotencyKey(c *gin.Context, header string) string {
+	key := c.GetHeader(header)
+	if key == "" || (c.GetBool(RequestIDGeneratedKey) && key == c.GetString(RequestIDKey)) {
+		return ""
+	}
+	return key
+}
+
+// RateLimitResolver returns the requests a client may make per window; zero
+// or less exempts the client
+type RateLimitResolver func(clientID string) int
+
+// TieredRateLimits resolves a client's cap from the tier it is assigned to,
+// falling back to defaultLimit for clients without a known tier
+func TieredRateLimits(defaultLimit int, tiers map[string]int, clientTiers map[string]string) RateLimitResolver {
+	return func(clientID string) int {
+		if limit, ok := tiers[clientTiers[clientID]]; ok {
+			return limit
+		}
+		return defaultLimit
+	}
+}
+
+// RateLimiter implements a fixed window rate limiter using Redis.
+// Limits requests per authenticated user or IP to prevent abuse.
 func RateLimiter(cache *storage.RedisCache, maxRequests int, window time.Duration) gin.HandlerFunc {
+	return RateLimiterWithResolver(cache, func(string) int { return maxRequests }, window)
+}
+
+// RateLimitConfig holds rate limiter configuration
+type RateLimitConfig struct {
+	// Resolve returns the requests a client may make per Window
+	Resolve RateLimitResolver
+	Window  time.Duration
+	// Logger, if set, is warned when requests pass unlimited because Redis is down
+	Logger *zap.Logger
+	// KeyNS prefixes the Redis counter of each client (default RateLimitKeyNS)
+	KeyNS string
+}
+
+// RateLimiterWithResolver is RateLimiter with a per-client cap
+func RateLimiterWithResolver(cache *storage.RedisCache, resolve RateLimitResolver, window time.Duration) gin.HandlerFunc {
+	return RateLimiterWithConfig(cache, RateLimitConfig{Resolve: resolve, Window: window})
+}
+
+// RateLimiterWithConfig is RateLimiter with a per-client cap. Clients are
+// the user JWTAuth authenticated, so it must run after JWTAuth, or the client
+// IP; X-User-ID neither picks the bucket nor the tier. Every response
+// carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// (seconds until the window resets). If Redis is unavailable requests are let
+// through; while the cache's health monitor reports it down, Redis is not
+// tried at all.
+func RateLimiterWithConfig(cache *storage.RedisCache, cfg RateLimitConfig) gin.HandlerFunc {
+	resolve, window := cfg.Resolve, cfg.Window
+	if cfg.KeyNS == "" {
+		cfg.KeyNS = RateLimitKeyNS
+	}
+	failOpen := newFailOpenLog(cfg.Logger, "rate limiting")
 	return func(c *gin.Context) {
-		clientID := c.ClientIP()
-		if userID := c.GetHeader("X-User-ID"); userID != "" {
-			clientID = userID
+		clientID := rateLimitClient(c)
+		limit := resolve(clientID)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		if !cache.IsAvailable() {
+			failOpen.warn(errRedisUnavailable)
+			c.Next()
+			return
 		}
 
-		key := "ratelimit:" + clientID
-		ctx := c.Request.Context()
-
-		var count int
-		_ = cache.GetJSON(ctx, key, &count)
-		if count >= maxRequests {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": int(window.Seconds()),
-			})
+		// Counting and reading in one step keeps concurrent requests from overshooting
+		count, ttl, err := cache.IncrWindow(c.Request.Context(), cfg.KeyNS+clientID, window)
+		if err != nil {
+			failOpen.warn(err)
+			c.Next()
 			return
 		}
+		if ttl <= 0 {
+			ttl = window
+		}
+		resetSec := int((ttl + time.Second - 1) / time.Second)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(max(limit-int(count), 0)))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(resetSec))
 
-		_ = cache.Incr(ctx, key, window)
+		if count > int64(limit) {
+			c.Header("Retry-After", strconv.Itoa(resetSec))
+			abortWithError(c, apperr.RateLimited("Rate limit exceeded", fmt.Sprintf("retry in %d seconds", resetSec)),
+				gin.H{"retry_after": resetSec})
+			return
+		}
 		c.Next()
 	}
 }
@@ -96,9 +338,7 @@ func Timeout(timeout time.Duration) gin.HandlerFunc {
 		case <-done:
 			return
 		case <-ctx.Done():
-			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
-				"error": "Request timeout",
-			})
+			abortWithError(c, apperr.Timeout("Request timeout", "The request did not complete within "+timeout.String()), nil)
 		}
 	}
 }
@@ -0,0 +1,450 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+func newIdempotencyRouter(t *testing.T, cfg IdempotencyConfig) (*gin.Engine, *int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	handled := 0
+	r := gin.New()
+	r.Use(RequestID())
+	r.POST("/jobs", IdempotencyWithConfig(cache, cfg), func(c *gin.Context) {
+		handled++
+		c.String(http.StatusOK, c.GetString(RequestIDKey))
+	})
+	return r, &handled
+}
+
+func postJob(r http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestIdempotencyIgnoresGeneratedRequestIDs tests that only client-supplied
+// keys deduplicate, while every request still gets a correlation ID
+func TestIdempotencyIgnoresGeneratedRequestIDs(t *testing.T) {
+	r, handled := newIdempotencyRouter(t, DefaultIdempotencyConfig())
+
+	first := postJob(r, nil)
+	second := postJob(r, nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, 2, *handled)
+	assert.NotEmpty(t, first.Header().Get(RequestIDHeader))
+	assert.NotEqual(t, first.Header().Get(RequestIDHeader), second.Header().Get(RequestIDHeader))
+	assert.Equal(t, first.Header().Get(RequestIDHeader), first.Body.String(), "generated ID is the correlation ID")
+
+	// A client-supplied key is both the correlation ID and the idempotency key
+	key := map[string]string{RequestIDHeader: "client-key-1"}
+	assert.Equal(t, http.StatusOK, postJob(r, key).Code)
+	replay := postJob(r, key)
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, 3, *handled)
+}
+
+// TestIdempotencyCustomHeader tests that a configured header replaces
+// X-Request-ID as the idempotency key
+func TestIdempotencyCustomHeader(t *testing.T) {
+	r, handled := newIdempotencyRouter(t, IdempotencyConfig{Header: "Idempotency-Key"})
+
+	// X-Request-ID is only a correlation ID now
+	correlated := map[string]string{RequestIDHeader: "trace-1"}
+	assert.Equal(t, http.StatusOK, postJob(r, correlated).Code)
+	assert.Equal(t, http.StatusOK, postJob(r, correlated).Code)
+
+	keyed := map[string]string{"Idempotency-Key": "order-7", RequestIDHeader: "trace-2"}
+	assert.Equal(t, http.StatusOK, postJob(r, keyed).Code)
+	keyed[RequestIDHeader] = "trace-3"
+	replay := postJob(r, keyed)
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, 3, *handled)
+}
+
+// TestIdempotencyReplaysOriginalResponse tests that a duplicate gets the
+// exact response of the first request instead of being processed again
+func TestIdempotencyReplaysOriginalResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	handled := 0
+	r := gin.New()
+	r.POST("/jobs", IdempotencyWithConfig(cache, DefaultIdempotencyConfig()), func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusCreated, gin.H{"job_id": fmt.Sprintf("job-%d", handled)})
+	})
+
+	key := map[string]string{RequestIDHeader: "submit-1"}
+	first := postJob(r, key)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayHeader))
+
+	replay := postJob(r, key)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), replay.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"job_id":"job-1"}`, replay.Body.String())
+	assert.Equal(t, 1, handled)
+
+	// The response is forgotten with the key
+	mr.FastForward(IdempotencyTTL + time.Second)
+	fresh := postJob(r, key)
+	assert.JSONEq(t, `{"job_id":"job-2"}`, fresh.Body.String())
+	assert.Empty(t, fresh.Header().Get(IdempotentReplayHeader))
+}
+
+// TestIdempotencyKeysArePerClientAndRoute tests that the same key sent by
+// another user or to another route is processed as a separate request
+func TestIdempotencyKeysArePerClientAndRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	handled := 0
+	handler := func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusCreated, gin.H{"user": c.GetString(ContextUserID), "n": handled})
+	}
+	r := gin.New()
+	r.Use(authenticateTestUser)
+	r.POST("/jobs", IdempotencyWithConfig(cache, DefaultIdempotencyConfig()), handler)
+	r.POST("/pipelines", IdempotencyWithConfig(cache, DefaultIdempotencyConfig()), handler)
+
+	first := postJob(r, map[string]string{RequestIDHeader: "shared", testUserHeader: "alice"})
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.JSONEq(t, `{"user":"alice","n":1}`, first.Body.String())
+
+	// Another user's request is not answered with alice's response
+	other := postJob(r, map[string]string{RequestIDHeader: "shared", testUserHeader: "bob"})
+	assert.Empty(t, other.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"user":"bob","n":2}`, other.Body.String())
+
+	req := httptest.NewRequest(http.MethodPost, "/pipelines", nil)
+	req.Header.Set(RequestIDHeader, "shared")
+	req.Header.Set(testUserHeader, "alice")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"user":"alice","n":3}`, w.Body.String())
+
+	replay := postJob(r, map[string]string{RequestIDHeader: "shared", testUserHeader: "alice"})
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"user":"alice","n":1}`, replay.Body.String())
+	assert.Equal(t, 3, handled)
+}
+
+// TestIdempotencyInFlightDuplicate tests that a duplicate of a request still
+// being processed is refused with Retry-After, and a failure frees the key
+func TestIdempotencyInFlightDuplicate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	r := gin.New()
+	r.POST("/jobs", IdempotencyWithConfig(cache, IdempotencyConfig{RetryAfter: 2 * time.Second}), func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "algorithm service unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"job_id": "job-2"})
+	})
+
+	key := map[string]string{RequestIDHeader: "submit-2"}
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postJob(r, key) }()
+	<-entered
+
+	w := postJob(r, key)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"CONFLICT"`)
+
+	close(release)
+	assert.Equal(t, http.StatusServiceUnavailable, (<-done).Code)
+
+	// The failed request is not replayed; the retry is processed
+	w = postJob(r, key)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// TestIdempotencyResponseAndClaimTTLs tests that responses are replayed for
+// ResponseTTL only, while a claim held by a stuck request expires after ClaimTTL
+func TestIdempotencyResponseAndClaimTTLs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	cfg := IdempotencyConfig{ResponseTTL: time.Hour, ClaimTTL: 5 * time.Second}
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	r := gin.New()
+	r.POST("/jobs", IdempotencyWithConfig(cache, cfg), func(c *gin.Context) {
+		n := calls.Add(1)
+		if c.GetHeader(RequestIDHeader) == "stuck" && n == 1 {
+			close(entered)
+			<-release
+		}
+		c.JSON(http.StatusOK, gin.H{"job_id": fmt.Sprintf("job-%d", n)})
+	})
+
+	// Within ResponseTTL the original response is replayed
+	key := map[string]string{RequestIDHeader: "submit-ttl"}
+	assert.JSONEq(t, `{"job_id":"job-1"}`, postJob(r, key).Body.String())
+	mr.FastForward(59 * time.Minute)
+	replay := postJob(r, key)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"job_id":"job-1"}`, replay.Body.String())
+
+	// After it the request executes anew
+	mr.FastForward(2 * time.Minute)
+	fresh := postJob(r, key)
+	assert.Empty(t, fresh.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"job_id":"job-2"}`, fresh.Body.String())
+
+	// A request that never finishes holds its key for ClaimTTL, not ResponseTTL
+	calls.Store(0)
+	stuck := map[string]string{RequestIDHeader: "stuck"}
+	done := make(chan struct{})
+	go func() {
+		postJob(r, stuck)
+		close(done)
+	}()
+	<-entered
+	assert.Equal(t, http.StatusConflict, postJob(r, stuck).Code)
+	mr.FastForward(6 * time.Second)
+	assert.JSONEq(t, `{"job_id":"job-2"}`, postJob(r, stuck).Body.String())
+	close(release)
+	<-done
+}
+
+// TestIdempotencyStaleResponseNotReplayed tests that a response older than
+// ResponseTTL is not replayed even while Redis still holds it, e.g. after the
+// TTL was lowered
+func TestIdempotencyStaleResponseNotReplayed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	handled := 0
+	r := gin.New()
+	r.POST("/jobs", IdempotencyWithConfig(cache, IdempotencyConfig{ResponseTTL: time.Minute}), func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusOK, gin.H{"job_id": "fresh"})
+	})
+
+	old := idempotencyRecord{
+		State:       idempotencyCompleted,
+		Status:      http.StatusOK,
+		ContentType: "application/json",
+		Body:        []byte(`{"job_id":"stale"}`),
+		CompletedAt: time.Now().Add(-2 * time.Hour).UnixMilli(),
+	}
+	// Keys are scoped to the client, here httptest's remote address, and route
+	const stored = "idempotency:192.0.2.1:POST:/jobs:submit-old"
+	require.NoError(t, cache.SetJSON(context.Background(), stored, old, 24*time.Hour))
+
+	key := map[string]string{RequestIDHeader: "submit-old"}
+	w := postJob(r, key)
+	assert.Empty(t, w.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"job_id":"fresh"}`, w.Body.String())
+
+	// The fresh response is what is replayed from now on
+	replay := postJob(r, key)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.JSONEq(t, `{"job_id":"fresh"}`, replay.Body.String())
+	assert.Equal(t, 1, handled)
+	assert.InDelta(t, time.Minute.Seconds(), mr.TTL(stored).Seconds(), 1)
+}
+
+// TestIdempotencyWithoutRedis tests that requests are still processed when
+// Redis is unavailable
+func TestIdempotencyWithoutRedis(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+	mr.Close()
+
+	handled := 0
+	r := gin.New()
+	r.POST("/jobs", IdempotencyWithConfig(cache, DefaultIdempotencyConfig()), func(c *gin.Context) {
+		handled++
+		c.Status(http.StatusOK)
+	})
+
+	key := map[string]string{RequestIDHeader: "submit-3"}
+	assert.Equal(t, http.StatusOK, postJob(r, key).Code)
+	assert.Equal(t, http.StatusOK, postJob(r, key).Code)
+	assert.Equal(t, 2, handled)
+}
+
+func newRateLimitRouter(t *testing.T, resolve RateLimitResolver) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	r := gin.New()
+	r.Use(authenticateTestUser, RateLimiterWithResolver(cache, resolve, time.Minute))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func getAs(r http.Handler, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(testUserHeader, userID)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestRateLimiterTiers tests per-tier caps and the quota headers
+func TestRateLimiterTiers(t *testing.T) {
+	limits := TieredRateLimits(2, map[string]int{"batch": 4, "unlimited": 0}, map[string]string{"svc-etl": "batch", "svc-ops": "unlimited"})
+	r := newRateLimitRouter(t, limits)
+
+	w := getAs(r, "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, http.StatusOK, getAs(r, "alice").Code)
+	w = getAs(r, "alice")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, getAs(r, "svc-etl").Code, "request %d", i)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, getAs(r, "svc-etl").Code)
+
+	// Claiming a tiered user in X-User-ID gets an unauthenticated client neither their tier nor their bucket
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-ID", "svc-ops")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+
+	// A zero cap exempts the tier
+	for i := 0; i < 10; i++ {
+		w = getAs(r, "svc-ops")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
+
+// TestRateLimiterConcurrentRequestsDoNotOvershoot tests that the cap holds
+// exactly under concurrent requests from one client
+func TestRateLimiterConcurrentRequestsDoNotOvershoot(t *testing.T) {
+	r := newRateLimitRouter(t, func(string) int { return 10 })
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if getAs(r, "burst").Code == http.StatusOK {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(10), allowed.Load())
+}
+
+// TestMiddlewaresFailOpenWhileRedisDown tests that rate limiting and
+// idempotency let requests through once the cache is known to be down,
+// warning once rather than per request
+func TestMiddlewaresFailOpenWhileRedisDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+	mr.Close()
+	require.Error(t, cache.CheckHealth(context.Background()))
+
+	core, logs := observer.New(zap.WarnLevel)
+	logger := zap.New(core)
+	idempotency := DefaultIdempotencyConfig()
+	idempotency.Logger = logger
+	handled := 0
+	r := gin.New()
+	r.Use(RateLimiterWithConfig(cache, RateLimitConfig{Resolve: func(string) int { return 1 }, Window: time.Minute, Logger: logger}))
+	r.POST("/jobs", IdempotencyWithConfig(cache, idempotency), func(c *gin.Context) {
+		handled++
+		c.Status(http.StatusOK)
+	})
+
+	key := map[string]string{RequestIDHeader: "submit-4"}
+	for i := 0; i < 3; i++ {
+		w := postJob(r, key)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+	assert.Equal(t, 3, handled)
+	assert.Equal(t, 1, logs.FilterMessage("Redis unavailable, requests pass without rate limiting").Len())
+	assert.Equal(t, 1, logs.FilterMessage("Redis unavailable, requests pass without idempotency").Len())
+}
+
+// TestMiddlewareKeysAreNamespaced tests that idempotency keys and rate limit
+// counters live under their namespaces behind the cache's prefix
+func TestMiddlewareKeysAreNamespaced(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCacheWithConfig(storage.RedisCacheConfig{Addr: mr.Addr(), KeyPrefix: "staging:"})
+	t.Cleanup(func() { _ = cache.Close() })
+
+	idempotency := DefaultIdempotencyConfig()
+	idempotency.KeyNS = "submit:"
+	r := gin.New()
+	r.Use(authenticateTestUser, RateLimiterWithConfig(cache, RateLimitConfig{Resolve: func(string) int { return 10 }, Window: time.Minute}))
+	r.POST("/jobs", IdempotencyWithConfig(cache, idempotency), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := postJob(r, map[string]string{RequestIDHeader: "submit-5", testUserHeader: "user-1"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, []string{"staging:submit:user-1:POST:/jobs:submit-5", "staging:ratelimit:user-1"}, mr.Keys())
+}
@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// Gin context keys set by JWTAuth
+const (
+	ContextUserID = "user_id"
+	ContextRoles  = "roles"
+	ContextClaims = "claims"
+)
+
+// RoleAdmin is the role allowed through admin-only routes
+const RoleAdmin = "admin"
+
+var (
+	errTokenMissing   = errors.New("a Bearer token is required")
+	errTokenMalformed = errors.New("token is malformed")
+	errTokenAlgorithm = errors.New("token must be signed with HS256")
+	errTokenSignature = errors.New("token signature is invalid")
+	errTokenExpired   = errors.New("token has expired")
+	errTokenNotYet    = errors.New("token is not valid yet")
+	errTokenNoExpiry  = errors.New("token has no expiry")
+	errTokenIssuer    = errors.New("token issuer is not accepted")
+	errTokenNoUser    = errors.New("token has no user")
+)
+
+// Claims are the JWT claims the service understands
+type Claims struct {
+	Subject   string   `json:"sub,omitempty"`
+	UserID    string   `json:"user_id,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// User returns user_id, falling back to the subject
+func (c *Claims) User() string {
+	if c.UserID != "" {
+		return c.UserID
+	}
+	return c.Subject
+}
+
+// HasRole reports whether the claims carry the role
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type claimsCtxKey struct{}
+
+// ClaimsFromContext returns the claims of a request authenticated by JWTAuth
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsCtxKey{}).(*Claims)
+	return claims, ok
+}
+
+// AuthOption customizes JWTAuth
+type AuthOption func(*authOptions)
+
+type authOptions struct {
+	issuer         string
+	leeway         time.Duration
+	anonymousPaths []string
+	queryParam     string
+}
+
+// WithIssuer only accepts tokens issued by iss
+func WithIssuer(iss string) AuthOption {
+	return func(o *authOptions) { o.issuer = iss }
+}
+
+// WithLeeway tolerates clock skew when checking exp and nbf
+func WithLeeway(d time.Duration) AuthOption {
+	return func(o *authOptions) { o.leeway = d }
+}
+
+// WithAnonymousPaths lets requests without a token through on paths with
+// these prefixes; a token that is present must still be valid
+func WithAnonymousPaths(prefixes ...string) AuthOption {
+	return func(o *authOptions) { o.anonymousPaths = append(o.anonymousPaths, prefixes...) }
+}
+
+// WithQueryToken also accepts the token from the query parameter param when
+// there is no Authorization header, for browser WebSocket clients that cannot
+// set headers
+func WithQueryToken(param string) AuthOption {
+	return func(o *authOptions) { o.queryParam = param }
+}
+
+// JWTAuth validates an HS256 Bearer token and stores its claims, user ID and
+// roles in the gin context (and the claims in the request context).
+// Missing or invalid tokens are rejected with 401.
+func JWTAuth(secret string, opts ...AuthOption) gin.HandlerFunc {
+	var o authOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok && c.GetHeader("Authorization") == "" && o.queryParam != "" {
+			token = c.Query(o.queryParam)
+			ok = token != ""
+		}
+		if !ok {
+			if c.GetHeader("Authorization") == "" && pathHasPrefix(c.Request.URL.Path, o.anonymousPaths) {
+				c.Next()
+				return
+			}
+			abortUnauthorized(c, errTokenMissing)
+			return
+		}
+
+		claims, err := parseToken(token, []byte(secret), &o, time.Now())
+		if err != nil {
+			abortUnauthorized(c, err)
+			return
+		}
+
+		c.Set(ContextClaims, claims)
+		c.Set(ContextUserID, claims.User())
+		c.Set(ContextRoles, claims.Roles)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), claimsCtxKey{}, claims))
+		c.Next()
+	}
+}
+
+// RequireRole lets through requests whose token carries role; it must run
+// after JWTAuth. Unauthenticated requests get 401, others lacking the role 403.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c.Request.Context())
+		if !ok {
+			abortUnauthorized(c, errTokenMissing)
+			return
+		}
+		if !claims.HasRole(role) {
+			abortWithError(c, apperr.Forbidden("Forbidden", "role "+role+" is required"), nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// SignToken issues an HS256 token for claims
+func SignToken(secret string, claims Claims) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sign(signingInput, []byte(secret))), nil
+}
+
+// parseToken verifies the signature and time claims of a compact JWT
+func parseToken(token string, secret []byte, o *authOptions, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errTokenMalformed
+	}
+	if header.Alg != "HS256" {
+		return nil, errTokenAlgorithm
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+	if !hmac.Equal(sig, sign(parts[0]+"."+parts[1], secret)) {
+		return nil, errTokenSignature
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errTokenMalformed
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, errTokenNoExpiry
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(o.leeway)) {
+		return nil, errTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-o.leeway)) {
+		return nil, errTokenNotYet
+	}
+	if o.issuer != "" && claims.Issuer != o.issuer {
+		return nil, errTokenIssuer
+	}
+	if claims.User() == "" {
+		return nil, errTokenNoUser
+	}
+	return &claims, nil
+}
+
+func sign(signingInput string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(seg string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+func pathHasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func abortUnauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	abortWithError(c, apperr.Unauthorized("Unauthorized", err.Error()), nil)
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "jwt-secret"
+
+func newJWTRouter(opts ...AuthOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(JWTAuth(testSecret, opts...))
+	r.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString(ContextUserID), "roles": c.GetStringSlice(ContextRoles)})
+	})
+	r.GET("/admin/stats", RequireRole(RoleAdmin), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func mustSign(t *testing.T, secret string, claims Claims) string {
+	t.Helper()
+	token, err := SignToken(secret, claims)
+	require.NoError(t, err)
+	return token
+}
+
+func getWithToken(r http.Handler, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestJWTAuthAcceptsValidToken tests that claims are stored in the gin context
+func TestJWTAuthAcceptsValidToken(t *testing.T) {
+	r := newJWTRouter()
+	token := mustSign(t, testSecret, Claims{UserID: "u1", Roles: []string{"operator"}, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	w := getWithToken(r, "/me", token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"user_id":"u1","roles":["operator"]}`, w.Body.String())
+
+	// sub is used when user_id is absent
+	token = mustSign(t, testSecret, Claims{Subject: "u2", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	w = getWithToken(r, "/me", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_id":"u2"`)
+}
+
+// TestJWTAuthRejectsInvalidTokens tests the 401 cases
+func TestJWTAuthRejectsInvalidTokens(t *testing.T) {
+	r := newJWTRouter(WithIssuer("portal"))
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := mustSign(t, testSecret, Claims{UserID: "u1", Issuer: "portal", ExpiresAt: exp})
+	parts := strings.Split(valid, ".")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"missing", ""},
+		{"garbage", "not-a-jwt"},
+		{"wrong secret", mustSign(t, "other", Claims{UserID: "u1", Issuer: "portal", ExpiresAt: exp})},
+		{"tampered payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"user_id":"root","iss":"portal","exp":9999999999}`)) + "." + parts[2]},
+		{"alg none", unsigned},
+		{"expired", mustSign(t, testSecret, Claims{UserID: "u1", Issuer: "portal", ExpiresAt: time.Now().Add(-time.Minute).Unix()})},
+		{"no expiry", mustSign(t, testSecret, Claims{UserID: "u1", Issuer: "portal"})},
+		{"not yet valid", mustSign(t, testSecret, Claims{UserID: "u1", Issuer: "portal", ExpiresAt: exp, NotBefore: time.Now().Add(time.Minute).Unix()})},
+		{"wrong issuer", mustSign(t, testSecret, Claims{UserID: "u1", Issuer: "elsewhere", ExpiresAt: exp})},
+		{"no user", mustSign(t, testSecret, Claims{Issuer: "portal", ExpiresAt: exp})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getWithToken(r, "/me", tt.token)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), `"error":"Unauthorized"`)
+			assert.Contains(t, w.Body.String(), `"code":"UNAUTHORIZED"`)
+			assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+
+	assert.Equal(t, http.StatusOK, getWithToken(r, "/me", valid).Code)
+}
+
+// TestJWTAuthLeeway tests that a just-expired token is accepted within the leeway
+func TestJWTAuthLeeway(t *testing.T) {
+	r := newJWTRouter(WithLeeway(time.Minute))
+	token := mustSign(t, testSecret, Claims{UserID: "u1", ExpiresAt: time.Now().Add(-10 * time.Second).Unix()})
+	assert.Equal(t, http.StatusOK, getWithToken(r, "/me", token).Code)
+}
+
+// TestRequireRole tests 403 for tokens without the role
+func TestRequireRole(t *testing.T) {
+	r := newJWTRouter()
+	exp := time.Now().Add(time.Hour).Unix()
+
+	w := getWithToken(r, "/admin/stats", mustSign(t, testSecret, Claims{UserID: "u1", Roles: []string{"operator"}, ExpiresAt: exp}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"FORBIDDEN"`)
+
+	w = getWithToken(r, "/admin/stats", mustSign(t, testSecret, Claims{UserID: "u1", Roles: []string{RoleAdmin}, ExpiresAt: exp}))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Without JWTAuth in front there are no claims at all
+	gin.SetMode(gin.TestMode)
+	bare := gin.New()
+	bare.GET("/admin", RequireRole(RoleAdmin), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	assert.Equal(t, http.StatusUnauthorized, getWithToken(bare, "/admin", "").Code)
+}
+
+// TestJWTAuthAnonymousPaths tests that anonymous paths allow missing but not invalid tokens
+func TestJWTAuthAnonymousPaths(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(JWTAuth(testSecret, WithAnonymousPaths("/admin/")))
+	r.GET("/admin/x", AdminAuth("key"), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	exp := time.Now().Add(time.Hour).Unix()
+
+	// No token: falls through to the API key check
+	assert.Equal(t, http.StatusUnauthorized, getWithToken(r, "/admin/x", "").Code)
+	req := httptest.NewRequest(http.MethodGet, "/admin/x", nil)
+	req.Header.Set(AdminAPIKeyHeader, "key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// An admin token stands in for the API key
+	assert.Equal(t, http.StatusOK, getWithToken(r, "/admin/x", mustSign(t, testSecret, Claims{UserID: "ops", Roles: []string{RoleAdmin}, ExpiresAt: exp})).Code)
+	assert.Equal(t, http.StatusUnauthorized, getWithToken(r, "/admin/x", mustSign(t, testSecret, Claims{UserID: "u1", ExpiresAt: exp})).Code)
+	assert.Equal(t, http.StatusUnauthorized, getWithToken(r, "/admin/x", "bogus.token.value").Code)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/electric-power/backend-service/internal/apperr"
+)
+
+// DefaultShedExemptPaths are served even when the server is shedding load so
+// operators keep visibility and can act; entries ending in "/" match prefixes
+var DefaultShedExemptPaths = []string{
+	"/health",
+	"/ready",
+	"/metrics",
+	"/api/v1/system/health",
+	"/api/v1/admin/",
+}
+
+// LoadShedder caps the number of requests handled at once, answering 503
+// when all maxInFlight slots are busy. Requests whose path matches exempt
+// bypass the cap entirely. maxInFlight <= 0 disables shedding.
+func LoadShedder(maxInFlight int, exempt []string) gin.HandlerFunc {
+	if maxInFlight <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	slots := make(chan struct{}, maxInFlight)
+
+	return func(c *gin.Context) {
+		if shedExempt(c.Request.URL.Path, exempt) {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			c.Next()
+		default:
+			c.Header("Retry-After", "1")
+			abortWithError(c, apperr.Unavailable("Server overloaded", "too many requests in flight, retry shortly"), nil)
+		}
+	}
+}
+
+// shedExempt reports whether path is an exact or prefix match in exempt
+func shedExempt(path string, exempt []string) bool {
+	for _, p := range exempt {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
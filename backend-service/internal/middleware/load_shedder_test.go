@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadShedderExemptsHealthAndAdmin tests that a saturated limiter sheds job
+// requests while health, metrics and admin endpoints keep responding
+func TestLoadShedderExemptsHealthAndAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const slots = 2
+	release := make(chan struct{})
+	entered := make(chan struct{}, slots)
+
+	r := gin.New()
+	r.Use(LoadShedder(slots, DefaultShedExemptPaths))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	r.GET("/health", ok)
+	r.GET("/metrics", ok)
+	r.GET("/api/v1/admin/schemes", ok)
+	r.GET("/api/v1/jobs", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.String(http.StatusOK, "ok")
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Saturate the limiter with slow job requests
+	var wg sync.WaitGroup
+	for i := 0; i < slots; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, get("/api/v1/jobs").Code)
+		}()
+	}
+	for i := 0; i < slots; i++ {
+		select {
+		case <-entered:
+		case <-time.After(2 * time.Second):
+			t.Fatal("job requests did not start")
+		}
+	}
+
+	w := get("/api/v1/jobs")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"UNAVAILABLE"`)
+
+	for _, path := range []string{"/health", "/metrics", "/api/v1/admin/schemes"} {
+		assert.Equal(t, http.StatusOK, get(path).Code, path)
+	}
+
+	// Slots free up once the slow requests finish
+	close(release)
+	wg.Wait()
+	require.Eventually(t, func() bool { return get("/api/v1/jobs").Code == http.StatusOK }, time.Second, 10*time.Millisecond)
+}
+
+// TestShedExempt tests exact and prefix matching
+func TestShedExempt(t *testing.T) {
+	assert.True(t, shedExempt("/health", DefaultShedExemptPaths))
+	assert.True(t, shedExempt("/api/v1/admin/schemes/KBM-WF01/cancel-all", DefaultShedExemptPaths))
+	assert.False(t, shedExempt("/healthz", DefaultShedExemptPaths))
+	assert.False(t, shedExempt("/api/v1/administrator", DefaultShedExemptPaths))
+	assert.False(t, shedExempt("/api/v1/jobs", DefaultShedExemptPaths))
+}
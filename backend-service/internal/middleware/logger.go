@@ -1,18 +1,32 @@
 package middleware
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// RequestIDHeader carries a request's correlation ID
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey holds the correlation ID in the gin context; RequestIDGeneratedKey
+// is true when the client sent none and RequestID generated it
+const (
+	RequestIDKey          = "request_id"
+	RequestIDGeneratedKey = "request_id_generated"
+)
+
 // StructuredLogger returns a middleware that logs HTTP requests using zap
 func StructuredLogger(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactQuery(c.Request.URL.RawQuery)
 
 		c.Next()
 
@@ -27,7 +41,7 @@ func StructuredLogger(logger *zap.Logger) gin.HandlerFunc {
 			zap.String("ip", c.ClientIP()),
 			zap.Duration("latency", latency),
 			zap.String("user-agent", c.Request.UserAgent()),
-			zap.String("request_id", c.GetHeader(IdempotencyHeader)),
+			zap.String("request_id", c.GetString(RequestIDKey)),
 		}
 
 		if len(c.Errors) > 0 {
@@ -44,20 +58,52 @@ func StructuredLogger(logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
-// RequestID adds a unique request ID to each request if not provided
+// PlainLogger is gin's default request log with credentials in the query
+// string redacted, for when no zap logger is configured
+func PlainLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		path := p.Path
+		if base, query, ok := strings.Cut(path, "?"); ok {
+			path = base + "?" + redactQuery(query)
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"), p.StatusCode, p.Latency, p.ClientIP, p.Method, path, p.ErrorMessage)
+	})
+}
+
+// secretQueryParams carry credentials and are never logged
+var secretQueryParams = map[string]bool{"token": true, "api_key": true}
+
+// redactQuery masks credential values in a raw query string. Pairs are
+// matched one by one, so a malformed pair elsewhere does not leave a
+// credential in the clear.
+func redactQuery(raw string) string {
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		if secretQueryParams[key] {
+			pairs[i] = key + "=REDACTED"
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// RequestID gives each request a correlation ID for logs and responses: the
+// client's X-Request-ID, or a generated one echoed in the response. Generated
+// IDs are never written to the request headers, so they cannot be mistaken
+// for an idempotency key.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestID := c.GetHeader(IdempotencyHeader)
+		requestID := c.GetHeader(RequestIDHeader)
 		if requestID == "" {
-			requestID = generateRequestID()
-			c.Header(IdempotencyHeader, requestID)
+			requestID = uuid.NewString()
+			c.Header(RequestIDHeader, requestID)
+			c.Set(RequestIDGeneratedKey, true)
 		}
-		c.Set("request_id", requestID)
+		c.Set(RequestIDKey, requestID)
 		c.Next()
 	}
 }
-
-func generateRequestID() string {
-	// Simple timestamp-based ID, could use UUID in production
-	return time.Now().Format("20060102150405.000000")
-}
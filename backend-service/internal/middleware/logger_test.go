@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                            "",
+		"page=2&size=10":              "page=2&size=10",
+		"api_key=secret&page=2":       "api_key=REDACTED&page=2",
+		"token=abc&api%5Fkey=secret":  "token=REDACTED&api_key=REDACTED",
+		"bad=%zz&api_key=secret":      "bad=%zz&api_key=REDACTED",
+		"api_key=one&api_key=two&x=1": "api_key=REDACTED&api_key=REDACTED&x=1",
+	}
+	for raw, want := range tests {
+		assert.Equal(t, want, redactQuery(raw), raw)
+	}
+}
+
+// TestLoggersRedactAPIKey tests that neither request logger writes the admin API key
+func TestLoggersRedactAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	var plain bytes.Buffer
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &plain
+	t.Cleanup(func() { gin.DefaultWriter = defaultWriter })
+
+	for _, logger := range []gin.HandlerFunc{StructuredLogger(zap.New(core)), PlainLogger()} {
+		r := gin.New()
+		r.Use(logger)
+		r.GET("/ws/system", func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws/system?api_key=admin-secret&bad=%zz", nil))
+	}
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "api_key=REDACTED&bad=%zz", logs.All()[0].ContextMap()["query"])
+	assert.Contains(t, plain.String(), "/ws/system?api_key=REDACTED")
+	assert.NotContains(t, plain.String(), "admin-secret")
+}
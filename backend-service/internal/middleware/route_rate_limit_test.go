@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// testUserHeader names the user the test routers authenticate a request as
+const testUserHeader = "X-Test-User"
+
+// authenticateTestUser stands in for JWTAuth, authenticating the user named
+// in testUserHeader
+func authenticateTestUser(c *gin.Context) {
+	if userID := c.GetHeader(testUserHeader); userID != "" {
+		c.Set(ContextUserID, userID)
+	}
+}
+
+// newRouteRateLimitRouter serves the job routes behind a route rate limiter
+// with rules
+func newRouteRateLimitRouter(t *testing.T, rules map[string]RouteRateLimit) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	r := gin.New()
+	r.Use(authenticateTestUser)
+	r.Use(RouteRateLimiter(cache, RouteRateLimitConfig{Rules: rules}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/v1/jobs", ok)
+	r.POST("/api/v1/jobs", ok)
+	r.GET("/api/v1/jobs/:id", ok)
+	r.POST("/api/v1/jobs/:id/cancel", ok)
+	r.GET("/health", ok)
+	return r
+}
+
+func requestAs(r http.Handler, method, path, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(testUserHeader, userID)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// allowed counts how many of n requests pass
+func allowed(r http.Handler, n int, method, path, userID string) int {
+	passed := 0
+	for i := 0; i < n; i++ {
+		if requestAs(r, method, path, userID).Code == http.StatusOK {
+			passed++
+		}
+	}
+	return passed
+}
+
+// TestRouteRateLimiterRulesAreIndependent tests that each route pattern
+// enforces its own limit, per client, with the default rule covering the rest
+func TestRouteRateLimiterRulesAreIndependent(t *testing.T) {
+	// Refill is slow enough that only the burst counts within the test
+	r := newRouteRateLimitRouter(t, map[string]RouteRateLimit{
+		"POST /api/v1/jobs":          {RPS: 0.001, Burst: 2},
+		"/api/v1/jobs/:id":           {RPS: 0.001, Burst: 5},
+		"/api/v1/jobs/:id/cancel":    {RPS: 0.001, Burst: 3},
+		DefaultRouteRule:             {RPS: 0.001, Burst: 4},
+		"GET /api/v1/does-not-exist": {RPS: 0.001, Burst: 1},
+	})
+
+	assert.Equal(t, 2, allowed(r, 4, "POST", "/api/v1/jobs", "alice"))
+	// Submissions being exhausted leaves reads and cancels alone
+	w := requestAs(r, "GET", "/api/v1/jobs/job-1", "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
+	// Every job ID shares the pattern's bucket
+	assert.Equal(t, 4, allowed(r, 6, "GET", "/api/v1/jobs/job-2", "alice"))
+	assert.Equal(t, 3, allowed(r, 5, "POST", "/api/v1/jobs/job-1/cancel", "alice"))
+
+	// GET /api/v1/jobs has no rule of its own and falls back to the default
+	assert.Equal(t, 4, allowed(r, 6, "GET", "/api/v1/jobs", "alice"))
+	w = requestAs(r, "GET", "/health", "alice")
+	assert.Equal(t, http.StatusOK, w.Code, "the default rule keeps a bucket per route")
+
+	w = requestAs(r, "POST", "/api/v1/jobs", "alice")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"route":"POST /api/v1/jobs"`)
+	assert.Contains(t, w.Body.String(), `"code":"RATE_LIMITED"`)
+
+	// Other clients have their own buckets
+	assert.Equal(t, 2, allowed(r, 3, "POST", "/api/v1/jobs", "bob"))
+}
+
+// TestRouteRateLimiterWithoutDefault tests that routes without a rule are
+// unlimited when there is no default rule, and that buckets refill
+func TestRouteRateLimiterWithoutDefault(t *testing.T) {
+	r := newRouteRateLimitRouter(t, map[string]RouteRateLimit{
+		"POST /api/v1/jobs": {RPS: 1000, Burst: 1},
+	})
+	assert.Equal(t, 20, allowed(r, 20, "GET", "/api/v1/jobs", "alice"))
+	assert.Equal(t, http.StatusNotFound, requestAs(r, "GET", "/missing", "alice").Code)
+
+	assert.Equal(t, http.StatusOK, requestAs(r, "POST", "/api/v1/jobs", "alice").Code)
+	assert.Eventually(t, func() bool {
+		return requestAs(r, "POST", "/api/v1/jobs", "alice").Code == http.StatusOK
+	}, time.Second, 5*time.Millisecond)
+}
+
+// TestRouteRateLimiterIgnoresUserIDHeader tests that anonymous clients are
+// counted by IP, so a new X-User-ID per request does not reset the limit
+func TestRouteRateLimiterIgnoresUserIDHeader(t *testing.T) {
+	r := newRouteRateLimitRouter(t, map[string]RouteRateLimit{"POST /api/v1/jobs": {RPS: 0.001, Burst: 2}})
+
+	passed := 0
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", nil)
+		req.Header.Set("X-User-ID", fmt.Sprintf("user-%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			passed++
+		}
+	}
+	assert.Equal(t, 2, passed)
+}
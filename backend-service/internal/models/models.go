@@ -2,44 +2,135 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
 // Scheme represents an algorithm scheme definition
 type Scheme struct {
-	Model          string   `json:"model" db:"model"`
-	Code           string   `json:"code" db:"code"`
-	Name           string   `json:"name" db:"name"`
-	ClassName      string   `json:"class_name" db:"class_name"`
-	ResourceType   string   `json:"resource_type" db:"resource_type"`
-	Description    string   `json:"description,omitempty" db:"description"`
-	RequiredParams []string `json:"required_params,omitempty" db:"-"`
+	Model              string   `json:"model" db:"model"`
+	Code               string   `json:"code" db:"code"`
+	Name               string   `json:"name" db:"name"`
+	ClassName          string   `json:"class_name" db:"class_name"`
+	ResourceType       string   `json:"resource_type" db:"resource_type"`
+	Description        string   `json:"description,omitempty" db:"description"`
+	RequiredParams     []string `json:"required_params,omitempty" db:"-"`
+	SupportsCheckpoint bool     `json:"supports_checkpoint,omitempty" db:"-"`
 }
 
 // Job represents an algorithm job record
 type Job struct {
-	JobID      string       `db:"job_id" json:"job_id"`
-	SchemeCode string       `db:"scheme_code" json:"scheme_code"`
-	UserID     string       `db:"user_id" json:"user_id"`
-	Status     string       `db:"status" json:"status"`
-	Progress   int          `db:"progress" json:"progress"`
-	DataRef    string       `db:"data_ref" json:"data_ref"`
-	Params     string       `db:"params" json:"params"`
-	ResultJSON string       `db:"result_summary" json:"result_summary"`
+	JobID      string      `db:"job_id" json:"job_id"`
+	SchemeCode string      `db:"scheme_code" json:"scheme_code"`
+	UserID     string      `db:"user_id" json:"user_id"`
+	Status     string      `db:"status" json:"status"`
+	Progress   int         `db:"progress" json:"progress"`
+	DataRef    string      `db:"data_ref" json:"data_ref"`
+	Params     JobParams   `db:"params" json:"params"`
+	Metadata   JobMetadata `db:"metadata" json:"metadata,omitempty"`
+	// ResultJSON is left empty by job queries; results live in t_job_results
+	// and are read with MySQLStore.GetResult
+	ResultJSON string       `db:"result_summary" json:"result_summary,omitempty"`
 	ErrorLog   string       `db:"error_log" json:"error_log,omitempty"`
 	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
 	UpdatedAt  sql.NullTime `db:"updated_at" json:"updated_at,omitempty"`
 	FinishedAt sql.NullTime `db:"finished_at" json:"finished_at,omitempty"`
 }
 
+// JobParams is the raw params column of a job. It marshals as the JSON
+// value itself rather than as a quoted string.
+type JobParams string
+
+// MarshalJSON implements json.Marshaler
+func (p JobParams) MarshalJSON() ([]byte, error) {
+	return []byte(NormalizeParams(string(p))), nil
+}
+
+// NormalizeParams returns params as a JSON value: empty and null become {},
+// and values double-encoded as a JSON string are unwrapped
+func NormalizeParams(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return "{}"
+	}
+	if !json.Valid([]byte(raw)) {
+		quoted, _ := json.Marshal(raw)
+		return string(quoted)
+	}
+	var inner string
+	if err := json.Unmarshal([]byte(raw), &inner); err == nil && isJSONContainer(inner) {
+		return NormalizeParams(inner)
+	}
+	return raw
+}
+
+func isJSONContainer(s string) bool {
+	s = strings.TrimSpace(s)
+	return (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) && json.Valid([]byte(s))
+}
+
+// JobMetadata is opaque caller context (trace IDs, tenant IDs) attached to a
+// job at submission and forwarded to the algorithm service
+type JobMetadata map[string]string
+
+// Scan implements sql.Scanner for the JSON metadata column
+func (m *JobMetadata) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", src)
+	}
+	if len(raw) == 0 {
+		*m = nil
+		return nil
+	}
+	return json.Unmarshal(raw, (*map[string]string)(m))
+}
+
+// JobNote is an operator note attached to a job
+type JobNote struct {
+	ID        int64     `db:"id" json:"id"`
+	JobID     string    `db:"job_id" json:"job_id"`
+	Author    string    `db:"author" json:"author"`
+	Note      string    `db:"note" json:"note"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// JobCallback is one webhook delivery of a job's completion, kept in an
+// outbox until it is delivered or runs out of attempts
+type JobCallback struct {
+	ID            int64      `db:"id" json:"-"`
+	DeliveryID    string     `db:"delivery_id" json:"delivery_id"`
+	JobID         string     `db:"job_id" json:"job_id"`
+	Event         string     `db:"event" json:"event"`
+	URL           string     `db:"url" json:"url"`
+	Payload       string     `db:"payload" json:"-"`
+	Status        string     `db:"status" json:"status"` // PENDING, DELIVERED, FAILED
+	Attempts      int        `db:"attempts" json:"attempts"`
+	LastError     string     `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
 // ProgressMsg represents a progress update message
 type ProgressMsg struct {
-	TaskID     string            `json:"task_id"`
-	Percentage int32             `json:"percentage"`
-	Message    string            `json:"message"`
-	Timestamp  int64             `json:"timestamp"`
-	Stage      string            `json:"stage,omitempty"`
-	Metrics    map[string]string `json:"metrics,omitempty"`
+	TaskID        string            `json:"task_id"`
+	Percentage    int32             `json:"percentage"`
+	Message       string            `json:"message"`
+	Timestamp     int64             `json:"timestamp"`
+	Stage         string            `json:"stage,omitempty"`
+	Metrics       map[string]string `json:"metrics,omitempty"`
+	CheckpointRef string            `json:"checkpoint_ref,omitempty"`
 }
 
 // JobSubmitRequest represents a job submission request
@@ -78,16 +169,63 @@ type PaginatedResult[T any] struct {
 	Pages    int `json:"pages"`
 }
 
+// Health statuses of a HealthCheck and of a HealthReport
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
 // HealthCheck represents the health status of a service component
 type HealthCheck struct {
-	Status  string            `json:"status"` // healthy, degraded, unhealthy
-	Checks  map[string]string `json:"checks,omitempty"`
-	Message string            `json:"message,omitempty"`
+	Status    string `json:"status"` // healthy, degraded, unhealthy
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	// Critical components make the whole service unhealthy when they are
+	Critical bool `json:"-"`
+	// Details are component-specific fields reported alongside the status
+	Details map[string]any `json:"-"`
+}
+
+// MarshalJSON flattens Details into the check's object
+func (c HealthCheck) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(c.Details)+3)
+	for k, v := range c.Details {
+		out[k] = v
+	}
+	out["status"] = c.Status
+	out["latency_ms"] = c.LatencyMs
+	if c.Error != "" {
+		out["error"] = c.Error
+	}
+	return json.Marshal(out)
+}
+
+// HealthReport is the health of the service and each of its components
+type HealthReport struct {
+	Status string                 `json:"status"` // healthy, degraded, unhealthy
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+// NewHealthReport computes the overall status from the checks: unhealthy
+// when a critical component is unhealthy, degraded when any component is
+// not healthy, healthy otherwise
+func NewHealthReport(checks map[string]HealthCheck) HealthReport {
+	status := HealthHealthy
+	for _, c := range checks {
+		switch {
+		case c.Critical && c.Status == HealthUnhealthy:
+			return HealthReport{Status: HealthUnhealthy, Checks: checks}
+		case c.Status != HealthHealthy:
+			status = HealthDegraded
+		}
+	}
+	return HealthReport{Status: status, Checks: checks}
 }
 
 // WebSocketMessage represents a message sent over WebSocket
 type WebSocketMessage struct {
-	Type      string `json:"type"` // progress, result, error, ping, pong
+	Type      string `json:"type"` // progress, result, error, cancelled, ping, pong
 	TaskID    string `json:"task_id,omitempty"`
 	Payload   any    `json:"payload,omitempty"`
 	Timestamp int64  `json:"timestamp"`
@@ -95,11 +233,12 @@ type WebSocketMessage struct {
 
 // DataUploadMeta contains metadata for uploaded data files
 type DataUploadMeta struct {
-	DataRef     string    `json:"data_ref"`
-	Filename    string    `json:"filename"`
-	ContentType string    `json:"content_type"`
-	Size        int64     `json:"size"`
-	Checksum    string    `json:"checksum"`
-	UploadedAt  time.Time `json:"uploaded_at"`
-	UploadedBy  string    `json:"uploaded_by,omitempty"`
+	DataRef     string `json:"data_ref" db:"data_ref"`
+	Filename    string `json:"filename" db:"filename"`
+	ContentType string `json:"content_type" db:"content_type"`
+	Size        int64  `json:"size" db:"size"`
+	// Checksum is the hex SHA-256 of the file
+	Checksum   string    `json:"checksum" db:"checksum"`
+	UploadedAt time.Time `json:"uploaded_at" db:"uploaded_at"`
+	UploadedBy string    `json:"uploaded_by,omitempty" db:"uploaded_by"`
 }
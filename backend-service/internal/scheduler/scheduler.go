@@ -2,13 +2,19 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/electric-power/backend-service/internal/grpcclient"
+	"github.com/electric-power/backend-service/internal/models"
 	"github.com/electric-power/backend-service/internal/storage"
 
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Scheduler manages background jobs for the backend service
@@ -18,70 +24,461 @@ type Scheduler struct {
 	cache  *storage.RedisCache
 	algo   *grpcclient.AlgoClient
 	logger *zap.Logger
+	cfg    SchedulerConfig
+	tasks  []task
+
+	healthMu   sync.Mutex
+	lastHealth string
+
+	statusMu  sync.Mutex
+	startedAt time.Time
+	runs      map[string]*TaskStatus
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// SchedulerConfig tunes the background tasks
+type SchedulerConfig struct {
+	// ZombieTimeout is how long a RUNNING job may go without updates
+	ZombieTimeout time.Duration
+	// ZombieTimeoutOverrides replaces ZombieTimeout for specific scheme codes
+	ZombieTimeoutOverrides map[string]time.Duration
+	// ZombieStrategy chooses between no updates at all and no progress
+	ZombieStrategy storage.ZombieStrategy
+	// ZombieBatchSize is how many zombies one cleanup step finds and marks
+	// as failed; a large backlog is worked through in steps of this size
+	ZombieBatchSize int
+	// ConfirmZombies asks the algorithm service about each zombie candidate
+	// first, sparing jobs it still reports as active
+	ConfirmZombies bool
+	// OnZombiesFailed, if set, is called with the jobs marked as zombies
+	OnZombiesFailed func(jobIDs []string)
+	// OnJobsTimedOut, if set, is called with the jobs failed for running
+	// past their own timeout_seconds
+	OnJobsTimedOut func(jobIDs []string)
+	// AlgoTargets are the clients of the named alternate algorithm
+	// services, for cancelling timed-out jobs routed to them
+	AlgoTargets map[string]*grpcclient.AlgoClient
+	// OnAlgoHealthChange, if set, is called when the algorithm service's
+	// health status differs from the previous check
+	OnAlgoHealthChange func(status, previous string)
+	// SLAWarnWindow is how long before its SLA deadline an active job is
+	// flagged as at risk
+	SLAWarnWindow time.Duration
+	// OnSLAFlagged, if set, is called with the jobs whose SLA state changed
+	OnSLAFlagged func(jobs []storage.SLAJob)
+	// RefreshSchemes, if set, fetches and caches the schemes for the scheme
+	// cache refresh, so the refresh shares fetches with other callers such
+	// as JobService.RefreshSchemes
+	RefreshSchemes func(ctx context.Context, fetch func(context.Context) ([]models.Scheme, error)) ([]models.Scheme, error)
+	// DeletedJobRetention is how long soft-deleted jobs are kept before
+	// they are purged along with their results
+	DeletedJobRetention time.Duration
+	// SchemeCacheKey is where the scheme cache refresh stores the schemes
+	// when RefreshSchemes is not set
+	SchemeCacheKey string
+	// MaxJitter delays each scheduled run by a random 0..MaxJitter so that
+	// replicas do not all fire on the same second; 0 disables jitter
+	MaxJitter time.Duration
+
+	// ZombieCleanupSpec, AlgoHealthSpec, SchemeRefreshSpec, SLACheckSpec,
+	// DeletedJobPurgeSpec and JobTimeoutSpec are the cron specs (with a
+	// seconds field) of each task; empty uses the default. JobTimeoutSpec
+	// bounds how late a job's timeout_seconds is enforced.
+	ZombieCleanupSpec   string
+	AlgoHealthSpec      string
+	SchemeRefreshSpec   string
+	SLACheckSpec        string
+	DeletedJobPurgeSpec string
+	JobTimeoutSpec      string
+	// DisableZombieCleanup, DisableAlgoHealth, DisableSchemeRefresh,
+	// DisableSLACheck, DisableDeletedJobPurge and DisableJobTimeout keep the
+	// corresponding task from being scheduled
+	DisableZombieCleanup   bool
+	DisableAlgoHealth      bool
+	DisableSchemeRefresh   bool
+	DisableSLACheck        bool
+	DisableDeletedJobPurge bool
+	DisableJobTimeout      bool
+}
+
+// DefaultSchedulerConfig returns the default scheduler configuration
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		ZombieTimeout:       30 * time.Minute,
+		ZombieStrategy:      storage.ZombieByUpdatedAt,
+		ZombieBatchSize:     500,
+		SLAWarnWindow:       15 * time.Minute,
+		DeletedJobRetention: 30 * 24 * time.Hour,
+		SchemeCacheKey:      "sys:algo:schemes",
+		ZombieCleanupSpec:   "0 */5 * * * *",
+		AlgoHealthSpec:      "*/30 * * * * *",
+		SchemeRefreshSpec:   "0 * * * * *",
+		SLACheckSpec:        "30 * * * * *",
+		DeletedJobPurgeSpec: "0 15 * * * *",
+		JobTimeoutSpec:      "*/10 * * * * *",
+	}
+}
+
+// task is a background task and the schedule it runs on
+type task struct {
+	name      string
+	schedule  cron.Schedule
+	run       func() error
+	needsAlgo bool
 }
 
+// specParser parses the six-field cron specs of SchedulerConfig
+var specParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 // NewScheduler creates a new scheduler instance
 func NewScheduler(store *storage.MySQLStore, cache *storage.RedisCache, algo *grpcclient.AlgoClient, logger *zap.Logger) *Scheduler {
+	// The default specs always parse
+	s, _ := NewSchedulerWithConfig(store, cache, algo, logger, DefaultSchedulerConfig())
+	return s
+}
+
+// NewSchedulerWithConfig creates a scheduler with custom configuration,
+// returning an error if the spec of an enabled task does not parse.
+// algo may be nil on deployments without an algorithm service; the
+// scheduler then only runs the tasks that need just the database.
+func NewSchedulerWithConfig(store *storage.MySQLStore, cache *storage.RedisCache, algo *grpcclient.AlgoClient, logger *zap.Logger, cfg SchedulerConfig) (*Scheduler, error) {
 	if logger == nil {
 		logger, _ = zap.NewProduction()
 	}
-	return &Scheduler{
+	if cfg.ZombieTimeout <= 0 {
+		cfg.ZombieTimeout = DefaultSchedulerConfig().ZombieTimeout
+	}
+	if cfg.ZombieStrategy != storage.ZombieByProgress {
+		cfg.ZombieStrategy = storage.ZombieByUpdatedAt
+	}
+	if cfg.ZombieBatchSize <= 0 {
+		cfg.ZombieBatchSize = DefaultSchedulerConfig().ZombieBatchSize
+	}
+	if cfg.MaxJitter < 0 {
+		cfg.MaxJitter = 0
+	}
+	if cfg.SLAWarnWindow < 0 {
+		cfg.SLAWarnWindow = 0
+	}
+	defaults := DefaultSchedulerConfig()
+	if cfg.DeletedJobRetention <= 0 {
+		cfg.DeletedJobRetention = defaults.DeletedJobRetention
+	}
+	if cfg.SchemeCacheKey == "" {
+		cfg.SchemeCacheKey = defaults.SchemeCacheKey
+	}
+	if cfg.ZombieCleanupSpec == "" {
+		cfg.ZombieCleanupSpec = defaults.ZombieCleanupSpec
+	}
+	if cfg.AlgoHealthSpec == "" {
+		cfg.AlgoHealthSpec = defaults.AlgoHealthSpec
+	}
+	if cfg.SchemeRefreshSpec == "" {
+		cfg.SchemeRefreshSpec = defaults.SchemeRefreshSpec
+	}
+	if cfg.SLACheckSpec == "" {
+		cfg.SLACheckSpec = defaults.SLACheckSpec
+	}
+	if cfg.DeletedJobPurgeSpec == "" {
+		cfg.DeletedJobPurgeSpec = defaults.DeletedJobPurgeSpec
+	}
+	if cfg.JobTimeoutSpec == "" {
+		cfg.JobTimeoutSpec = defaults.JobTimeoutSpec
+	}
+
+	s := &Scheduler{
 		cron:   cron.New(cron.WithSeconds()),
 		store:  store,
 		cache:  cache,
 		algo:   algo,
 		logger: logger,
+		cfg:    cfg,
+		runs:   make(map[string]*TaskStatus),
+		stopCh: make(chan struct{}),
 	}
+	for _, t := range []struct {
+		task
+		spec     string
+		disabled bool
+	}{
+		{task{name: "zombie cleanup", run: s.cleanupZombieTasks}, cfg.ZombieCleanupSpec, cfg.DisableZombieCleanup},
+		{task{name: "algorithm health check", run: s.checkAlgoHealth, needsAlgo: true}, cfg.AlgoHealthSpec, cfg.DisableAlgoHealth},
+		{task{name: "scheme cache refresh", run: s.refreshSchemeCache, needsAlgo: true}, cfg.SchemeRefreshSpec, cfg.DisableSchemeRefresh},
+		{task{name: "SLA check", run: s.checkSLAs}, cfg.SLACheckSpec, cfg.DisableSLACheck},
+		{task{name: "deleted job purge", run: s.purgeDeletedJobs}, cfg.DeletedJobPurgeSpec, cfg.DisableDeletedJobPurge},
+		{task{name: "job timeout check", run: s.enforceJobTimeouts}, cfg.JobTimeoutSpec, cfg.DisableJobTimeout},
+	} {
+		if t.disabled {
+			logger.Info("Scheduled task disabled", zap.String("task", t.name))
+			continue
+		}
+		schedule, err := specParser.Parse(t.spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron spec %q for %s: %w", t.spec, t.name, err)
+		}
+		t.schedule = schedule
+		s.tasks = append(s.tasks, t.task)
+	}
+	return s, nil
 }
 
 // Start begins the scheduled jobs
 func (s *Scheduler) Start() {
-	// Zombie task cleanup every 5 minutes
-	_, _ = s.cron.AddFunc("0 */5 * * * *", s.cleanupZombieTasks)
-
-	// Algorithm service health check every 30 seconds
-	_, _ = s.cron.AddFunc("*/30 * * * * *", s.checkAlgoHealth)
-
-	// Cache refresh every minute
-	_, _ = s.cron.AddFunc("0 * * * * *", s.refreshSchemeCache)
-
+	if s.algo == nil {
+		s.logger.Info("No algorithm client configured, algorithm tasks are not scheduled")
+	}
+	for _, t := range s.tasks {
+		if t.needsAlgo && s.algo == nil {
+			continue
+		}
+		s.cron.Schedule(t.schedule, cron.FuncJob(s.withJitter(s.tracked(t))))
+	}
+	s.markStarted(time.Now())
 	s.cron.Start()
 	s.logger.Info("Scheduler started")
 }
 
-// Stop gracefully stops the scheduler
+// Stop gracefully stops the scheduler; runs still waiting out their jitter are skipped
 func (s *Scheduler) Stop() context.Context {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	s.markStarted(time.Time{})
 	return s.cron.Stop()
 }
 
-// cleanupZombieTasks marks stuck tasks as failed
-func (s *Scheduler) cleanupZombieTasks() {
+// jitterDelay picks a random delay in [0, MaxJitter]
+func (s *Scheduler) jitterDelay() time.Duration {
+	if s.cfg.MaxJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.cfg.MaxJitter) + 1))
+}
+
+// withJitter wraps a task so each run starts after a random delay
+func (s *Scheduler) withJitter(task func()) func() {
+	return func() {
+		if delay := s.jitterDelay(); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-s.stopCh:
+				timer.Stop()
+				return
+			}
+		}
+		task()
+	}
+}
+
+// cleanupZombieTasks marks stuck tasks as failed, ZombieBatchSize at a time
+// so a large backlog never has to fit in memory or in one statement
+func (s *Scheduler) cleanupZombieTasks() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	total := 0
+	after := ""
+	for {
+		// Tasks without updates (or progress) for longer than their scheme's timeout are considered zombies.
+		// Paging on job_id moves past candidates spared by confirmation.
+		candidates, err := s.store.FindZombieTasksAfter(ctx, s.cfg.ZombieStrategy, s.cfg.ZombieTimeout, s.cfg.ZombieTimeoutOverrides, after, s.cfg.ZombieBatchSize)
+		if err != nil {
+			s.logger.Error("Failed to find zombie tasks", zap.Int("failed", total), zap.Error(err))
+			return err
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		after = candidates[len(candidates)-1]
+		more := len(candidates) == s.cfg.ZombieBatchSize
+
+		zombies := candidates
+		if s.cfg.ConfirmZombies && (s.algo != nil || len(s.cfg.AlgoTargets) > 0) {
+			zombies = s.confirmZombies(ctx, candidates)
+		}
+		if len(zombies) > 0 {
+			s.logger.Warn("Found zombie tasks", zap.Int("count", len(zombies)), zap.Strings("job_ids", zombies))
+			failed, err := s.store.MarkZombieAsFailed(ctx, zombies)
+			if len(failed) > 0 && s.cfg.OnZombiesFailed != nil {
+				s.cfg.OnZombiesFailed(failed)
+			}
+			total += len(failed)
+			if err != nil {
+				s.logger.Error("Failed to mark zombies as failed", zap.Int("failed", total), zap.Error(err))
+				return err
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	if total > 0 {
+		s.logger.Info("Cleaned up zombie tasks", zap.Int("count", total))
+	}
+	return nil
+}
+
+// checkSLAs flags active jobs approaching or past their SLA deadline. The
+// jobs are only flagged; they keep running.
+func (s *Scheduler) checkSLAs() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Tasks running for more than 30 minutes are considered zombies
-	zombies, err := s.store.FindZombieTasks(ctx, 30*time.Minute)
+	flagged, err := s.store.FlagSLAJobs(ctx, time.Now(), s.cfg.SLAWarnWindow)
 	if err != nil {
-		s.logger.Error("Failed to find zombie tasks", zap.Error(err))
-		return
+		s.logger.Error("Failed to check job SLAs", zap.Error(err))
+		return err
+	}
+	if len(flagged) == 0 {
+		return nil
+	}
+	s.logger.Warn("Jobs flagged against their SLA", zap.Int("count", len(flagged)))
+	if s.cfg.OnSLAFlagged != nil {
+		s.cfg.OnSLAFlagged(flagged)
+	}
+	return nil
+}
+
+// jobTimeoutBatchSize is how many timed-out jobs one check fails; the rest
+// are picked up by the next run
+const jobTimeoutBatchSize = 500
+
+// enforceJobTimeouts fails the active jobs past their own timeout_seconds
+// and force-cancels them on the algorithm service. Each job is failed first,
+// so one that finished in the meantime is neither failed nor cancelled. Jobs
+// without a timeout are left to the zombie cleanup.
+func (s *Scheduler) enforceJobTimeouts() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	candidates, err := s.store.FindTimedOutJobs(ctx, time.Now(), jobTimeoutBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to find timed-out jobs", zap.Error(err))
+		return err
+	}
+	var timedOut []string
+	for _, job := range candidates {
+		failed, err := s.store.MarkTimedOutAsFailed(ctx, job.JobID)
+		if err != nil {
+			s.logger.Error("Failed to mark timed-out job as failed", zap.String("job_id", job.JobID), zap.Error(err))
+			continue
+		}
+		if !failed {
+			continue
+		}
+		timedOut = append(timedOut, job.JobID)
+		s.cancelTimedOut(ctx, job)
+	}
+	if len(timedOut) == 0 {
+		return nil
+	}
+	s.logger.Warn("Failed jobs past their timeout", zap.Int("count", len(timedOut)), zap.Strings("job_ids", timedOut))
+	if s.cfg.OnJobsTimedOut != nil {
+		s.cfg.OnJobsTimedOut(timedOut)
 	}
+	return nil
+}
 
-	if len(zombies) == 0 {
+// cancelTimedOut force-cancels a timed-out job on the algorithm service it
+// was routed to; the job is already failed whatever the outcome
+func (s *Scheduler) cancelTimedOut(ctx context.Context, job storage.TimedOutJob) {
+	algo := s.algo
+	if job.AlgoTarget != "" {
+		algo = s.cfg.AlgoTargets[job.AlgoTarget]
+	}
+	if algo == nil {
 		return
 	}
+	resp, err := algo.CancelTask(ctx, job.AlgoTaskID, true)
+	switch {
+	case err != nil:
+		s.logger.Warn("Failed to cancel timed-out job", zap.String("job_id", job.JobID), zap.Error(err))
+	case !resp.GetAccepted():
+		s.logger.Info("Algorithm service declined to cancel timed-out job",
+			zap.String("job_id", job.JobID), zap.String("status", resp.GetStatus()), zap.String("message", resp.GetMessage()))
+	}
+}
 
-	s.logger.Warn("Found zombie tasks", zap.Int("count", len(zombies)), zap.Strings("job_ids", zombies))
+// purgeBatchSize is how many deleted jobs one purge transaction removes
+const purgeBatchSize = 500
 
-	if err := s.store.MarkZombieAsFailed(ctx, zombies); err != nil {
-		s.logger.Error("Failed to mark zombies as failed", zap.Error(err))
-		return
+// purgeDeletedJobs hard-deletes the jobs soft-deleted longer than the
+// retention ago, in batches so no transaction grows unbounded
+func (s *Scheduler) purgeDeletedJobs() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cutoff := time.Now().Add(-s.cfg.DeletedJobRetention)
+	total := 0
+	for {
+		n, err := s.store.PurgeDeletedJobs(ctx, cutoff, purgeBatchSize)
+		total += n
+		if err != nil {
+			s.logger.Error("Failed to purge deleted jobs", zap.Int("purged", total), zap.Error(err))
+			return err
+		}
+		if n < purgeBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		s.logger.Info("Purged deleted jobs", zap.Int("count", total))
 	}
+	return nil
+}
+
+// activeAlgoStatuses are the algorithm service statuses of a task still in progress
+var activeAlgoStatuses = map[string]bool{"PENDING": true, "QUEUED": true, "RUNNING": true, "TERMINATING": true}
 
-	s.logger.Info("Cleaned up zombie tasks", zap.Int("count", len(zombies)))
+// confirmZombies keeps the candidates the algorithm service they were
+// routed to no longer knows as active. A candidate whose status can't be
+// fetched is spared until the next run rather than failed on a guess. One
+// whose service is not configured cannot be asked and is failed, as without
+// confirmation.
+func (s *Scheduler) confirmZombies(ctx context.Context, candidates []string) []string {
+	confirmed := candidates[:0]
+	for _, jobID := range candidates {
+		target, err := s.store.GetAlgoTarget(ctx, jobID)
+		if err != nil {
+			s.logger.Warn("Could not load zombie task's algorithm target, keeping it", zap.String("job_id", jobID), zap.Error(err))
+			continue
+		}
+		algo := s.algo
+		if target != "" {
+			algo = s.cfg.AlgoTargets[target]
+		}
+		if algo == nil {
+			confirmed = append(confirmed, jobID)
+			continue
+		}
+		taskID, err := s.store.GetAlgoTaskID(ctx, jobID)
+		if err != nil || taskID == "" {
+			taskID = jobID
+		}
+		task, err := algo.GetTaskStatus(ctx, taskID)
+		switch {
+		case status.Code(err) == codes.NotFound:
+		case err != nil:
+			s.logger.Warn("Could not confirm zombie task, keeping it", zap.String("job_id", jobID), zap.Error(err))
+			continue
+		case activeAlgoStatuses[task.Status]:
+			s.logger.Info("Zombie candidate still active on the algorithm service",
+				zap.String("job_id", jobID), zap.String("status", task.Status))
+			continue
+		}
+		confirmed = append(confirmed, jobID)
+	}
+	return confirmed
 }
 
-// checkAlgoHealth verifies the algorithm service is responsive
-func (s *Scheduler) checkAlgoHealth() {
+// checkAlgoHealth verifies the algorithm service is responsive. An
+// unresponsive service is a result of the check, not a failed run.
+func (s *Scheduler) checkAlgoHealth() error {
+	if s.algo == nil {
+		s.logger.Debug("Skipping algorithm health check: no algorithm client")
+		return nil
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -93,7 +490,8 @@ func (s *Scheduler) checkAlgoHealth() {
 			"checked": time.Now().Unix(),
 			"error":   err.Error(),
 		}, 1*time.Minute)
-		return
+		s.recordAlgoHealth("DOWN")
+		return nil
 	}
 
 	_ = s.cache.SetJSON(ctx, "sys:algo:health", map[string]any{
@@ -101,20 +499,47 @@ func (s *Scheduler) checkAlgoHealth() {
 		"checked": time.Now().Unix(),
 		"metrics": status.Metrics,
 	}, 1*time.Minute)
+	s.recordAlgoHealth(status.Status.String())
+	return nil
+}
+
+// recordAlgoHealth remembers the latest health status and reports changes
+func (s *Scheduler) recordAlgoHealth(status string) {
+	s.healthMu.Lock()
+	previous := s.lastHealth
+	s.lastHealth = status
+	s.healthMu.Unlock()
+
+	if status != previous && s.cfg.OnAlgoHealthChange != nil {
+		s.cfg.OnAlgoHealthChange(status, previous)
+	}
 }
 
 // refreshSchemeCache refreshes the algorithm scheme cache
-func (s *Scheduler) refreshSchemeCache() {
+func (s *Scheduler) refreshSchemeCache() error {
+	if s.algo == nil {
+		s.logger.Debug("Skipping scheme cache refresh: no algorithm client")
+		return nil
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if s.cfg.RefreshSchemes != nil {
+		if _, err := s.cfg.RefreshSchemes(ctx, s.algo.GetSchemes); err != nil {
+			s.logger.Warn("Failed to refresh scheme cache", zap.Error(err))
+			return err
+		}
+		return nil
+	}
 	schemes, err := s.algo.GetSchemes(ctx)
 	if err != nil {
 		s.logger.Warn("Failed to refresh scheme cache", zap.Error(err))
-		return
+		return err
 	}
 
-	if err := s.cache.SetJSON(ctx, "sys:algo:schemes", schemes, 10*time.Minute); err != nil {
+	if err := s.cache.SetJSON(ctx, s.cfg.SchemeCacheKey, schemes, 10*time.Minute); err != nil {
 		s.logger.Warn("Failed to cache schemes", zap.Error(err))
+		return err
 	}
+	return nil
 }
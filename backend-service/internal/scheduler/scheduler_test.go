@@ -0,0 +1,591 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/grpcclient"
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/services"
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+	pb "github.com/electric-power/backend-service/proto"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func mustScheduler(t *testing.T, store *storage.MySQLStore, cfg SchedulerConfig) *Scheduler {
+	t.Helper()
+	s, err := NewSchedulerWithConfig(store, nil, nil, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJitterWithinBound(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.MaxJitter = 40 * time.Millisecond
+	s := mustScheduler(t, nil, cfg)
+
+	var spread bool
+	for i := 0; i < 1000; i++ {
+		d := s.jitterDelay()
+		if d < 0 || d > cfg.MaxJitter {
+			t.Fatalf("jitter %v outside [0, %v]", d, cfg.MaxJitter)
+		}
+		if d > 0 {
+			spread = true
+		}
+	}
+	if !spread {
+		t.Fatal("expected non-zero jitter")
+	}
+
+	for i := 0; i < 5; i++ {
+		ran := make(chan time.Duration, 1)
+		start := time.Now()
+		s.withJitter(func() { ran <- time.Since(start) })()
+		if elapsed := <-ran; elapsed > cfg.MaxJitter+20*time.Millisecond {
+			t.Fatalf("task started after %v, want at most %v", elapsed, cfg.MaxJitter)
+		}
+	}
+}
+
+func TestJitterDisabled(t *testing.T) {
+	s := mustScheduler(t, nil, DefaultSchedulerConfig())
+	if d := s.jitterDelay(); d != 0 {
+		t.Fatalf("expected no jitter by default, got %v", d)
+	}
+	ran := false
+	s.withJitter(func() { ran = true })()
+	if !ran {
+		t.Fatal("task did not run")
+	}
+}
+
+func TestStopSkipsPendingJitter(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.MaxJitter = time.Hour
+	s := mustScheduler(t, nil, cfg)
+
+	done := make(chan bool, 1)
+	go func() {
+		ran := false
+		s.withJitter(func() { ran = true })()
+		done <- ran
+	}()
+	s.Stop()
+
+	select {
+	case ran := <-done:
+		if ran {
+			t.Fatal("task ran after stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("jittered task did not return after stop")
+	}
+}
+
+func TestSchedulerWithoutAlgoClient(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+
+	var failed []string
+	cfg := DefaultSchedulerConfig()
+	cfg.OnZombiesFailed = func(jobIDs []string) { failed = jobIDs }
+	cfg.OnAlgoHealthChange = func(status, previous string) {
+		t.Fatalf("unexpected health change %q -> %q", previous, status)
+	}
+	s := mustScheduler(t, store, cfg)
+
+	s.Start()
+	entries := len(s.cron.Entries())
+	s.Stop()
+	if entries != 4 {
+		t.Fatalf("expected only the zombie cleanup, SLA check, deleted job purge and job timeout check to be scheduled, got %d entries", entries)
+	}
+
+	// Algo tasks are skipped instead of panicking
+	s.checkAlgoHealth()
+	s.refreshSchemeCache()
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING'`).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+	expectMarkZombies(mock, "job-1")
+	s.cleanupZombieTasks()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != "job-1" {
+		t.Fatalf("expected job-1 to be marked as a zombie, got %v", failed)
+	}
+}
+
+// expectMarkZombies registers the locking read and UPDATE that fail zombies
+// still RUNNING
+func expectMarkZombies(mock sqlmock.Sqlmock, jobIDs ...string) {
+	ids := make([]driver.Value, len(jobIDs))
+	rows := sqlmock.NewRows([]string{"job_id"})
+	for i, id := range jobIDs {
+		ids[i] = id
+		rows.AddRow(id)
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE job_id IN \(.+\) AND status = 'RUNNING' FOR UPDATE`).
+		WithArgs(ids...).
+		WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs(append([]driver.Value{sqlmock.AnyArg(), sqlmock.AnyArg()}, ids...)...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(jobIDs))))
+	mock.ExpectCommit()
+}
+
+func TestDisabledTaskNeverRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+
+	cfg := DefaultSchedulerConfig()
+	cfg.ZombieCleanupSpec = "* * * * * *"
+	cfg.DisableZombieCleanup = true
+	cfg.DisableSLACheck = true
+	cfg.DisableDeletedJobPurge = true
+	cfg.DisableJobTimeout = true
+	s := mustScheduler(t, store, cfg)
+
+	// The cleanup would query for zombies within a second if it were scheduled
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING'`).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	s.Start()
+	entries := len(s.cron.Entries())
+	time.Sleep(1500 * time.Millisecond)
+	<-s.Stop().Done()
+
+	if entries != 0 {
+		t.Fatalf("expected no tasks to be scheduled, got %d entries", entries)
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Fatal("disabled zombie cleanup ran")
+	}
+}
+
+func TestZombieCleanupInBatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+
+	var failed [][]string
+	cfg := DefaultSchedulerConfig()
+	cfg.ZombieBatchSize = 2
+	cfg.OnZombiesFailed = func(jobIDs []string) { failed = append(failed, append([]string(nil), jobIDs...)) }
+	s := mustScheduler(t, store, cfg)
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING' .* ORDER BY job_id LIMIT \?`).
+		WithArgs(sqlmock.AnyArg(), storage.PipelineSchemeCode, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1").AddRow("job-2"))
+	expectMarkZombies(mock, "job-1", "job-2")
+	mock.ExpectQuery(`AND job_id > \? ORDER BY job_id LIMIT \?`).
+		WithArgs(sqlmock.AnyArg(), storage.PipelineSchemeCode, "job-2", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-3"))
+	expectMarkZombies(mock, "job-3")
+
+	// A short page ends the run without another query
+	if err := s.cleanupZombieTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(failed) != "[[job-1 job-2] [job-3]]" {
+		t.Fatalf("expected two batches of zombies, got %v", failed)
+	}
+}
+
+func TestStatusReportsOverdueTasks(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.DisableZombieCleanup = true
+	cfg.DisableDeletedJobPurge = true
+	cfg.DisableJobTimeout = true
+	s := mustScheduler(t, nil, cfg)
+
+	// Before Start nothing is overdue and algorithm tasks are not listed
+	t0 := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	st := s.statusAt(t0)
+	if st.Running || len(st.Tasks) != 1 || st.Tasks[0].Overdue {
+		t.Fatalf("unexpected status before start: %+v", st)
+	}
+
+	s.markStarted(t0)
+	s.recordRun("SLA check", t0.Add(time.Minute), nil)
+	s.recordRun("SLA check", t0.Add(2*time.Minute), fmt.Errorf("db down"))
+	st = s.statusAt(t0.Add(3 * time.Minute))
+	task := st.Tasks[0]
+	if !st.Running || task.Interval != time.Minute || task.LastError != "db down" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	if !task.LastSuccess.Equal(t0.Add(time.Minute)) || !task.LastRun.Equal(t0.Add(2*time.Minute)) || task.Overdue {
+		t.Fatalf("unexpected task status: %+v", task)
+	}
+
+	// Three intervals without a success make the task overdue
+	if st = s.statusAt(t0.Add(4*time.Minute + time.Second)); !st.Tasks[0].Overdue {
+		t.Fatalf("expected the SLA check to be overdue: %+v", st.Tasks[0])
+	}
+}
+
+func TestInvalidCronSpecIsRejected(t *testing.T) {
+	cfg := DefaultSchedulerConfig()
+	cfg.AlgoHealthSpec = "every thirty seconds"
+	if _, err := NewSchedulerWithConfig(nil, nil, nil, zap.NewNop(), cfg); err == nil {
+		t.Fatal("expected an invalid spec to be rejected")
+	}
+
+	// A disabled task's spec is not validated
+	cfg.DisableAlgoHealth = true
+	mustScheduler(t, nil, cfg)
+}
+
+// taskStatusAlgo is an algorithm service answering GetTaskStatus from a map;
+// unknown tasks are NotFound
+type taskStatusAlgo struct {
+	pb.UnimplementedAlgoControlServiceServer
+	statuses map[string]string
+}
+
+func (a *taskStatusAlgo) GetTaskStatus(_ context.Context, req *pb.TaskIdentity) (*pb.TaskStatus, error) {
+	st, ok := a.statuses[req.TaskId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown task")
+	}
+	return &pb.TaskStatus{TaskId: req.TaskId, Status: st}, nil
+}
+
+func startAlgo(t *testing.T, srv pb.AlgoControlServiceServer) *grpcclient.AlgoClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterAlgoControlServiceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	cfg := grpcclient.DefaultAlgoClientConfig(lis.Addr().String())
+	cfg.MaxRetries = 0
+	client, err := grpcclient.NewAlgoClientWithConfig(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestConfirmZombiesSparesActiveTasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+	algo := startAlgo(t, &taskStatusAlgo{statuses: map[string]string{
+		"algo-slow": "RUNNING",
+		"job-done":  "FAILED",
+	}})
+	// The GPU target knows job-gpu; the default service does not
+	gpu := startAlgo(t, &taskStatusAlgo{statuses: map[string]string{"job-gpu": "RUNNING"}})
+
+	var failed []string
+	cfg := DefaultSchedulerConfig()
+	cfg.ConfirmZombies = true
+	cfg.AlgoTargets = map[string]*grpcclient.AlgoClient{"gpu": gpu}
+	cfg.OnZombiesFailed = func(jobIDs []string) { failed = jobIDs }
+	s, err := NewSchedulerWithConfig(store, nil, algo, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING'`).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).
+			AddRow("job-slow").AddRow("job-done").AddRow("job-gone").AddRow("job-gpu").AddRow("job-lost"))
+	expectRoute := func(jobID string, target, taskID any) {
+		mock.ExpectQuery(`SELECT algo_target FROM t_algo_jobs WHERE job_id = \?`).WithArgs(jobID).
+			WillReturnRows(sqlmock.NewRows([]string{"algo_target"}).AddRow(target))
+		if target == "lost" {
+			return
+		}
+		mock.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs WHERE job_id = \?`).WithArgs(jobID).
+			WillReturnRows(sqlmock.NewRows([]string{"algo_task_id"}).AddRow(taskID))
+	}
+	// job-slow is known to the algorithm service under its own task ID
+	expectRoute("job-slow", nil, "algo-slow")
+	expectRoute("job-done", nil, nil)
+	expectRoute("job-gone", nil, nil)
+	expectRoute("job-gpu", "gpu", nil)
+	// job-lost was routed to a target no longer configured
+	expectRoute("job-lost", "lost", nil)
+	expectMarkZombies(mock, "job-done", "job-gone", "job-lost")
+	s.cleanupZombieTasks()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(failed) != "[job-done job-gone job-lost]" {
+		t.Fatalf("expected job-done, job-gone and job-lost to be marked as zombies, got %v", failed)
+	}
+}
+
+func TestCheckSLAsFlagsWithoutFailing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+
+	var flagged []storage.SLAJob
+	cfg := DefaultSchedulerConfig()
+	cfg.OnSLAFlagged = func(jobs []storage.SLAJob) { flagged = jobs }
+	s := mustScheduler(t, store, cfg)
+
+	now := time.Now()
+	columns := []string{"job_id", "scheme_code", "user_id", "status", "progress", "created_at", "sla_deadline", "sla_state"}
+	mock.ExpectQuery(`SELECT job_id, scheme_code, .* sla_deadline IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("job-past", "KBM-WF01", "u1", "RUNNING", 90, now.Add(-time.Hour), now.Add(-time.Minute), "").
+			AddRow("job-near", "KBM-WF01", "u1", "RUNNING", 10, now.Add(-time.Hour), now.Add(5*time.Minute), ""))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET sla_state = \?`).WithArgs(storage.SLAAtRisk, "job-near").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET sla_state = \?`).WithArgs(storage.SLABreached, "job-past").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.checkSLAs()
+
+	// Only sla_state is written; the jobs' status is untouched
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if len(flagged) != 2 || flagged[0].SLAState != storage.SLABreached || flagged[1].SLAState != storage.SLAAtRisk {
+		t.Fatalf("expected job-past breached and job-near at risk, got %+v", flagged)
+	}
+
+	// A run with nothing newly flagged does not call back
+	flagged = nil
+	mock.ExpectQuery(`SELECT job_id, scheme_code`).WillReturnRows(sqlmock.NewRows(columns))
+	s.checkSLAs()
+	if flagged != nil {
+		t.Fatalf("unexpected callback with %+v", flagged)
+	}
+}
+
+// cancelAlgo is an algorithm service recording the cancellations it receives
+type cancelAlgo struct {
+	pb.UnimplementedAlgoControlServiceServer
+	mu       sync.Mutex
+	requests []string
+}
+
+func (a *cancelAlgo) CancelTask(_ context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, fmt.Sprintf("%s force=%t", req.TaskId, req.Force))
+	return &pb.CancelResponse{Accepted: true, Status: "CANCELLED"}, nil
+}
+
+func (a *cancelAlgo) cancelled() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.requests
+}
+
+func TestEnforceJobTimeouts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+	defaultAlgo, gpuAlgo := &cancelAlgo{}, &cancelAlgo{}
+
+	var timedOut []string
+	cfg := DefaultSchedulerConfig()
+	cfg.AlgoTargets = map[string]*grpcclient.AlgoClient{"gpu": startAlgo(t, gpuAlgo)}
+	cfg.OnJobsTimedOut = func(jobIDs []string) { timedOut = jobIDs }
+	s, err := NewSchedulerWithConfig(store, nil, startAlgo(t, defaultAlgo), zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE status IN \('PENDING', 'RUNNING'\) AND timeout_at IS NOT NULL AND timeout_at <= \?`).
+		WithArgs(sqlmock.AnyArg(), storage.PipelineSchemeCode, jobTimeoutBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "algo_task_id", "algo_target"}).
+			AddRow("job-1", "algo-1", "").
+			AddRow("job-done", "algo-done", "").
+			AddRow("job-gpu", "algo-gpu", "gpu"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'.*WHERE job_id = \? AND status IN \('PENDING', 'RUNNING'\)`).
+		WithArgs(storage.TimeoutErrorLog, sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// job-done finished after it was found, so it is neither failed nor cancelled
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs(storage.TimeoutErrorLog, sqlmock.AnyArg(), sqlmock.AnyArg(), "job-done").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs(storage.TimeoutErrorLog, sqlmock.AnyArg(), sqlmock.AnyArg(), "job-gpu").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.enforceJobTimeouts(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(timedOut) != "[job-1 job-gpu]" {
+		t.Fatalf("expected job-1 and job-gpu to time out, got %v", timedOut)
+	}
+	if got := fmt.Sprint(defaultAlgo.cancelled()); got != "[algo-1 force=true]" {
+		t.Fatalf("unexpected cancellations on the default service: %s", got)
+	}
+	if got := fmt.Sprint(gpuAlgo.cancelled()); got != "[algo-gpu force=true]" {
+		t.Fatalf("unexpected cancellations on the gpu target: %s", got)
+	}
+}
+
+func TestPurgeDeletedJobsInBatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+
+	cfg := DefaultSchedulerConfig()
+	cfg.DeletedJobRetention = 7 * 24 * time.Hour
+	s := mustScheduler(t, store, cfg)
+
+	// A full batch is followed by another until one comes back short
+	full := sqlmock.NewRows([]string{"job_id"})
+	for i := 0; i < purgeBatchSize; i++ {
+		full.AddRow(fmt.Sprintf("job-%d", i))
+	}
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE deleted_at IS NOT NULL AND deleted_at < \?`).
+		WithArgs(approxCutoff{time.Now().Add(-cfg.DeletedJobRetention)}, purgeBatchSize).
+		WillReturnRows(full)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM t_job_results WHERE job_id IN`).WillReturnResult(sqlmock.NewResult(0, purgeBatchSize))
+	mock.ExpectExec(`DELETE FROM t_job_notes WHERE job_id IN`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM t_job_callbacks WHERE job_id IN`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM t_algo_jobs WHERE job_id IN`).WillReturnResult(sqlmock.NewResult(0, purgeBatchSize))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-last"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM t_job_results WHERE job_id IN \(\?\)`).WithArgs("job-last").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM t_job_notes WHERE job_id IN \(\?\)`).WithArgs("job-last").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM t_job_callbacks WHERE job_id IN \(\?\)`).WithArgs("job-last").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM t_algo_jobs WHERE job_id IN \(\?\)`).WithArgs("job-last").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	s.purgeDeletedJobs()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// approxCutoff matches a time argument within a few seconds of want
+type approxCutoff struct{ want time.Time }
+
+func (a approxCutoff) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	d := got.Sub(a.want)
+	return d > -5*time.Second && d < 5*time.Second
+}
+
+// schemesAlgo is an algorithm service whose GetAvailableSchemes blocks until
+// release is closed, counting the calls
+type schemesAlgo struct {
+	pb.UnimplementedAlgoControlServiceServer
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a *schemesAlgo) GetAvailableSchemes(context.Context, *pb.Empty) (*pb.SchemeList, error) {
+	if a.calls.Add(1) == 1 {
+		close(a.started)
+	}
+	<-a.release
+	return &pb.SchemeList{Schemes: []*pb.SchemeList_Scheme{{Code: "KBM-WF01"}}}, nil
+}
+
+func TestSchemeRefreshSharesFetchWithHandlers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+	jobs := services.NewJobService(nil, cache, ws.NewHub(), "sys:algo:schemes", "job:progress:")
+
+	srv := &schemesAlgo{started: make(chan struct{}), release: make(chan struct{})}
+	algo := startAlgo(t, srv)
+	cfg := DefaultSchedulerConfig()
+	cfg.RefreshSchemes = jobs.RefreshSchemes
+	s, err := NewSchedulerWithConfig(nil, cache, algo, zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The scheduled refresh is in flight when a handler's cache miss fetches too
+	refreshed := make(chan struct{})
+	go func() {
+		s.refreshSchemeCache()
+		close(refreshed)
+	}()
+	<-srv.started
+	fetched := make(chan []models.Scheme)
+	go func() {
+		schemes, err := jobs.RefreshSchemes(context.Background(), algo.GetSchemes)
+		if err != nil {
+			t.Error(err)
+		}
+		fetched <- schemes
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(srv.release)
+
+	schemes := <-fetched
+	<-refreshed
+	if n := srv.calls.Load(); n != 1 {
+		t.Fatalf("algorithm service fetched schemes %d times, want 1", n)
+	}
+	if len(schemes) != 1 || schemes[0].Code != "KBM-WF01" {
+		t.Fatalf("handler got %v", schemes)
+	}
+	cached, err := jobs.GetCachedSchemes(context.Background())
+	if err != nil || len(cached) != 1 {
+		t.Fatalf("cached schemes %v, err %v", cached, err)
+	}
+}
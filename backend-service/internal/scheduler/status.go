@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// overdueRuns is how many scheduled runs a task may miss, or fail, before it
+// is reported as overdue
+const overdueRuns = 3
+
+// TaskStatus is the run history of one scheduled task
+type TaskStatus struct {
+	Name string
+	// Interval is the time between two scheduled runs
+	Interval    time.Duration
+	LastRun     time.Time
+	LastSuccess time.Time
+	// LastError is the error of the last run, empty if it succeeded
+	LastError string
+	// Overdue is set when the task has not succeeded for overdueRuns
+	// intervals (plus the jitter) since its last success or the start
+	Overdue bool
+}
+
+// Status is a snapshot of the scheduler's liveness
+type Status struct {
+	Running   bool
+	StartedAt time.Time
+	Tasks     []TaskStatus
+}
+
+// Status returns the liveness of the scheduler and of each scheduled task
+func (s *Scheduler) Status() Status {
+	return s.statusAt(time.Now())
+}
+
+func (s *Scheduler) statusAt(now time.Time) Status {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	st := Status{Running: !s.startedAt.IsZero(), StartedAt: s.startedAt}
+	for _, t := range s.tasks {
+		if t.needsAlgo && s.algo == nil {
+			continue
+		}
+		ts := TaskStatus{Name: t.name}
+		if run := s.runs[t.name]; run != nil {
+			ts = *run
+		}
+		next := t.schedule.Next(now)
+		ts.Interval = t.schedule.Next(next).Sub(next)
+
+		since := ts.LastSuccess
+		if since.Before(s.startedAt) {
+			since = s.startedAt
+		}
+		ts.Overdue = st.Running && now.Sub(since) > overdueRuns*ts.Interval+s.cfg.MaxJitter
+		st.Tasks = append(st.Tasks, ts)
+	}
+	return st
+}
+
+// markStarted records when the scheduler started, or that it stopped when
+// at is zero
+func (s *Scheduler) markStarted(at time.Time) {
+	s.statusMu.Lock()
+	s.startedAt = at
+	s.statusMu.Unlock()
+}
+
+// tracked wraps a task so the outcome of each run is recorded
+func (s *Scheduler) tracked(t task) func() {
+	return func() {
+		s.recordRun(t.name, time.Now(), t.run())
+	}
+}
+
+// recordRun records the outcome of a task run started at
+func (s *Scheduler) recordRun(name string, at time.Time, err error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	run := s.runs[name]
+	if run == nil {
+		run = &TaskStatus{Name: name}
+		s.runs[name] = run
+	}
+	run.LastRun = at
+	run.LastError = ""
+	if err != nil {
+		run.LastError = err.Error()
+		return
+	}
+	run.LastSuccess = at
+}
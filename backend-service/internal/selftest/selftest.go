@@ -0,0 +1,143 @@
+// Package selftest checks at startup that every dependency is reachable and
+// usable, so a bad DSN or Redis DB fails fast instead of on the first request.
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/models"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+// DefaultTimeout bounds each check
+const DefaultTimeout = 5 * time.Second
+
+// Check probes one dependency
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Report holds the results of all checks in the order they ran
+type Report struct {
+	Results []Result
+}
+
+// Passed reports whether every check succeeded
+func (r Report) Passed() bool {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ExitCode is 0 when every check passed and 1 otherwise
+func (r Report) ExitCode() int {
+	if r.Passed() {
+		return 0
+	}
+	return 1
+}
+
+// Write prints one line per check followed by the overall verdict
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, res := range r.Results {
+		status, detail := "OK", ""
+		if res.Err != nil {
+			status, detail = "FAIL", res.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Name, status, res.Duration.Round(time.Millisecond), detail)
+	}
+	verdict := "self-test passed"
+	if !r.Passed() {
+		verdict = "self-test FAILED"
+	}
+	fmt.Fprintln(tw, verdict)
+	return tw.Flush()
+}
+
+// Run runs the checks one after another, each bounded by timeout
+func Run(ctx context.Context, timeout time.Duration, checks ...Check) Report {
+	var report Report
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := runCheck(checkCtx, check)
+		cancel()
+		report.Results = append(report.Results, Result{Name: check.Name, Err: err, Duration: time.Since(start)})
+	}
+	return report
+}
+
+// runCheck returns once the check finishes or ctx expires, whichever is first
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
+
+// Failed is a check that reports err, for dependencies that could not even
+// be set up
+func Failed(name string, err error) Check {
+	return Check{Name: name, Run: func(context.Context) error { return err }}
+}
+
+// Store is the part of a database or cache the storage checks exercise
+type Store interface {
+	Ping(ctx context.Context) error
+	RoundTrip(ctx context.Context) error
+}
+
+// StoreCheck pings s, then writes and reads back a value
+func StoreCheck(name string, s Store) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		if err := s.Ping(ctx); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+		return s.RoundTrip(ctx)
+	}}
+}
+
+// Algo is the part of the algorithm client the check exercises
+type Algo interface {
+	Health(ctx context.Context) (*pb.HealthStatus, error)
+	GetSchemes(ctx context.Context) ([]models.Scheme, error)
+}
+
+// AlgoCheck requires the algorithm service to report SERVING and answer a
+// scheme listing
+func AlgoCheck(name string, algo Algo) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		status, err := algo.Health(ctx)
+		if err != nil {
+			return fmt.Errorf("health: %w", err)
+		}
+		if status.GetStatus() != pb.HealthStatus_SERVING {
+			return errors.New("health: service reports " + status.GetStatus().String())
+		}
+		if _, err := algo.GetSchemes(ctx); err != nil {
+			return fmt.Errorf("list schemes: %w", err)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,103 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+	pb "github.com/electric-power/backend-service/proto"
+)
+
+type fakeAlgo struct {
+	status *pb.HealthStatus
+	err    error
+}
+
+func (f fakeAlgo) Health(context.Context) (*pb.HealthStatus, error) { return f.status, f.err }
+
+func (f fakeAlgo) GetSchemes(context.Context) ([]models.Scheme, error) { return nil, nil }
+
+func newMockStore(t *testing.T) (*storage.MySQLStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql")), mock
+}
+
+func TestSelfTestReportsFailingDependencies(t *testing.T) {
+	store, mock := newMockStore(t)
+	mock.ExpectPing().WillReturnError(errors.New("access denied for user 'root'"))
+
+	// A Redis that has gone away
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	defer cache.Close()
+	mr.Close()
+
+	report := Run(context.Background(), time.Second,
+		StoreCheck("mysql", store),
+		StoreCheck("redis", cache),
+		AlgoCheck("algo", fakeAlgo{status: &pb.HealthStatus{Status: pb.HealthStatus_NOT_SERVING}}),
+		Failed("config", errors.New("bad value")),
+	)
+
+	assert.False(t, report.Passed())
+	assert.Equal(t, 1, report.ExitCode())
+	require.Len(t, report.Results, 4)
+	for _, res := range report.Results {
+		assert.Error(t, res.Err, res.Name)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "access denied")
+	assert.Contains(t, out.String(), "NOT_SERVING")
+	assert.Regexp(t, `(?m)^redis\s+FAIL\s+`, out.String())
+	assert.Contains(t, out.String(), "self-test FAILED")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelfTestPassesHealthyDependencies(t *testing.T) {
+	store, mock := newMockStore(t)
+	mock.ExpectPing()
+	mock.ExpectExec(`CREATE TEMPORARY TABLE t_selftest`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO t_selftest`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT v FROM t_selftest`).WillReturnRows(sqlmock.NewRows([]string{"v"}).AddRow("stale"))
+	mock.ExpectExec(`DROP TEMPORARY TABLE IF EXISTS t_selftest`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	defer cache.Close()
+
+	report := Run(context.Background(), time.Second,
+		StoreCheck("redis", cache),
+		AlgoCheck("algo", fakeAlgo{status: &pb.HealthStatus{Status: pb.HealthStatus_SERVING}}),
+	)
+	assert.True(t, report.Passed())
+	assert.Equal(t, 0, report.ExitCode())
+	assert.Empty(t, mr.Keys(), "round-trip key left behind")
+
+	// A value that does not read back as written fails the check
+	report = Run(context.Background(), time.Second, StoreCheck("mysql", store))
+	require.Error(t, report.Results[0].Err)
+	assert.Contains(t, report.Results[0].Err.Error(), "read back")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelfTestTimesOutHungCheck(t *testing.T) {
+	hung := Check{Name: "hung", Run: func(context.Context) error { select {} }}
+	report := Run(context.Background(), 20*time.Millisecond, hung)
+	require.Error(t, report.Results[0].Err)
+	assert.Contains(t, report.Results[0].Err.Error(), "timed out")
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// EventJobCompleted is the webhook event sent when a job reaches SUCCESS,
+// FAILED or CANCELLED
+const EventJobCompleted = "job.completed"
+
+// CompletionPayload is the body of a job completion webhook
+type CompletionPayload struct {
+	Event  string `json:"event"`
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+	// Summary is the "summary" field of a successful job's result, if any
+	Summary json.RawMessage `json:"summary,omitempty"`
+	// Error is the error log of a failed job or the reason a job was cancelled
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// enqueueCompletion adds the completion webhook of a job to the callback
+// outbox; jobs without a callback URL are skipped by the store. The outbox
+// is only written while webhooks are enabled.
+func (s *JobService) enqueueCompletion(ctx context.Context, jobID, status string, summary json.RawMessage, errMsg string) {
+	if s.webhooks == nil {
+		return
+	}
+	body, err := json.Marshal(CompletionPayload{
+		Event:     EventJobCompleted,
+		JobID:     jobID,
+		Status:    status,
+		Summary:   summary,
+		Error:     errMsg,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		s.logger.Error("Completion webhook payload does not encode", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	// The job is already finished; a cancelled request must not lose its callback
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if _, err := s.store.EnqueueJobCallback(ctx, jobID, EventJobCompleted, uuid.NewString(), body); err != nil {
+		s.logger.Error("Failed to enqueue completion webhook", zap.String("job_id", jobID), zap.Error(err))
+	}
+}
+
+// resultSummary returns the "summary" field of a result JSON object, nil
+// when the result has none
+func resultSummary(resultJSON string) json.RawMessage {
+	var result struct {
+		Summary json.RawMessage `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil || string(result.Summary) == "null" {
+		return nil
+	}
+	return result.Summary
+}
+
+// CallbackWorkerConfig tunes the callback outbox worker
+type CallbackWorkerConfig struct {
+	// PollInterval is how often the outbox is checked for due deliveries
+	PollInterval time.Duration
+	// BatchSize caps the deliveries attempted per poll
+	BatchSize int
+	// Concurrency caps the deliveries attempted at once
+	Concurrency int
+}
+
+// DefaultCallbackWorkerConfig returns the default worker settings
+func DefaultCallbackWorkerConfig() CallbackWorkerConfig {
+	return CallbackWorkerConfig{
+		PollInterval: 2 * time.Second,
+		BatchSize:    50,
+		Concurrency:  4,
+	}
+}
+
+// CallbackWorker delivers the job callbacks queued in the t_job_callbacks
+// outbox, so deliveries survive restarts. Failed attempts are retried with
+// the sender's exponential backoff until MaxAttempts is reached. Replicas
+// may run workers side by side: each delivery is claimed before an attempt.
+type CallbackWorker struct {
+	store  *storage.MySQLStore
+	sender *WebhookSender
+	cfg    CallbackWorkerConfig
+	logger *zap.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewCallbackWorker creates a worker delivering through sender
+func NewCallbackWorker(store *storage.MySQLStore, sender *WebhookSender, cfg CallbackWorkerConfig, logger *zap.Logger) *CallbackWorker {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	defaults := DefaultCallbackWorkerConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaults.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaults.BatchSize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaults.Concurrency
+	}
+	return &CallbackWorker{
+		store:  store,
+		sender: sender,
+		cfg:    cfg,
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start polls the outbox in the background until Close
+func (w *CallbackWorker) Start() {
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				// Keep going while full batches come back
+				for w.RunOnce(context.Background()) == w.cfg.BatchSize {
+					select {
+					case <-w.stop:
+						return
+					default:
+					}
+				}
+			}
+		}
+	}()
+}
+
+// RunOnce attempts the deliveries currently due and returns how many were due
+func (w *CallbackWorker) RunOnce(ctx context.Context) int {
+	due, err := w.store.DueJobCallbacks(ctx, time.Now(), w.cfg.BatchSize)
+	if err != nil {
+		w.logger.Warn("Failed to load due job callbacks", zap.Error(err))
+		return 0
+	}
+	var g errgroup.Group
+	g.SetLimit(w.cfg.Concurrency)
+	for _, cb := range due {
+		cb := cb
+		g.Go(func() error {
+			w.attempt(ctx, cb)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return len(due)
+}
+
+// attempt claims one delivery, POSTs it and records the outcome
+func (w *CallbackWorker) attempt(ctx context.Context, cb models.JobCallback) {
+	now := time.Now()
+	claimed, err := w.store.ClaimJobCallback(ctx, cb, now, now.Add(w.sender.cfg.Timeout+time.Minute))
+	if err != nil || !claimed {
+		return
+	}
+
+	attempts := cb.Attempts + 1
+	err = w.sender.Deliver(ctx, cb.URL, cb.Event, cb.DeliveryID, []byte(cb.Payload))
+	status, lastError, next := storage.CallbackDelivered, "", time.Now()
+	switch {
+	case err == nil:
+	case attempts >= w.sender.cfg.MaxAttempts:
+		status, lastError = storage.CallbackFailed, err.Error()
+		w.logger.Error("Job callback delivery gave up", zap.String("job_id", cb.JobID),
+			zap.String("delivery_id", cb.DeliveryID), zap.Int("attempts", attempts), zap.Error(err))
+	default:
+		status, lastError = storage.CallbackPending, err.Error()
+		next = next.Add(w.sender.retryDelay(attempts))
+		w.logger.Warn("Job callback delivery failed", zap.String("job_id", cb.JobID),
+			zap.String("delivery_id", cb.DeliveryID), zap.Int("attempt", attempts), zap.Error(err))
+	}
+	if err := w.store.RecordJobCallbackAttempt(ctx, cb.ID, status, attempts, lastError, next); err != nil {
+		w.logger.Error("Failed to record job callback attempt", zap.String("delivery_id", cb.DeliveryID), zap.Error(err))
+	}
+}
+
+// Close stops polling and waits for the current poll until ctx is done
+func (w *CallbackWorker) Close(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
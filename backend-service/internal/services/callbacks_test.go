@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// completionBody matches a completion payload argument against want,
+// ignoring its timestamp
+type completionBody struct{ want CompletionPayload }
+
+func (c completionBody) Match(v driver.Value) bool {
+	raw, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var got CompletionPayload
+	if json.Unmarshal([]byte(raw), &got) != nil {
+		return false
+	}
+	got.Timestamp = 0
+	return string(got.Summary) == string(c.want.Summary) &&
+		got.Event == c.want.Event && got.JobID == c.want.JobID && got.Status == c.want.Status && got.Error == c.want.Error
+}
+
+func TestTerminalJobsEnqueueCompletionCallback(t *testing.T) {
+	s, mock := newMilestoneService(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT IGNORE INTO t_job_callbacks .* FROM t_algo_jobs WHERE job_id = \? AND callback_url IS NOT NULL`).
+		WithArgs(sqlmock.AnyArg(), EventJobCompleted,
+			completionBody{CompletionPayload{Event: EventJobCompleted, JobID: "job-1", Status: "FAILED", Error: "solver diverged"}},
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, s.FailJob(ctx, "job-1", "solver diverged"))
+
+	mock.ExpectExec(`INSERT INTO t_job_results`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'SUCCESS'`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT IGNORE INTO t_job_callbacks`).
+		WithArgs(sqlmock.AnyArg(), EventJobCompleted,
+			completionBody{CompletionPayload{Event: EventJobCompleted, JobID: "job-2", Status: "SUCCESS", Summary: json.RawMessage(`{"score":0.9}`)}},
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "job-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, s.FinishJob(ctx, "job-2", `{"summary":{"score":0.9},"metrics":{"rmse":0.1}}`))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCancelAfterFinishHasNoSideEffects tests that cancelling a job that
+// already finished neither announces it nor enqueues a CANCELLED callback,
+// which would take the place of the real completion callback
+func TestCancelAfterFinishHasNoSideEffects(t *testing.T) {
+	s, mock := newMilestoneService(t)
+	events := &gatedPublisher{gate: make(chan struct{})}
+	close(events.gate)
+	s.SetEventPublisher(events, nil)
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'CANCELLED'`).
+		WithArgs("too late", sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, s.CancelJob(context.Background(), "job-1", "too late"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, events.got)
+}
+
+func TestResultSummary(t *testing.T) {
+	assert.JSONEq(t, `{"score":0.9}`, string(resultSummary(`{"summary":{"score":0.9},"raw":[1]}`)))
+	assert.Nil(t, resultSummary(`{"metrics":{}}`))
+	assert.Nil(t, resultSummary(`{"summary":null}`))
+	assert.Nil(t, resultSummary(`[1,2]`))
+}
+
+var callbackColumns = []string{"id", "delivery_id", "job_id", "event", "url", "payload", "status", "attempts",
+	"last_error", "next_attempt_at", "delivered_at", "created_at"}
+
+// newCallbackWorker returns a worker on a mocked MySQL, giving up after maxAttempts
+func newCallbackWorker(t *testing.T, maxAttempts int) (*CallbackWorker, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	cfg := DefaultWebhookConfig()
+	cfg.Secret = "s3cret"
+	cfg.MaxAttempts = maxAttempts
+	cfg.InitialBackoff = time.Second
+	cfg.MaxBackoff = time.Minute
+	cfg.AllowPrivateTargets = true
+	store := storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql"))
+	return NewCallbackWorker(store, NewWebhookSender(cfg, nil), DefaultCallbackWorkerConfig(), nil), mock
+}
+
+// expectDue returns one due delivery to url after attempts attempts, and its claim
+func expectDue(mock sqlmock.Sqlmock, url string, attempts int) {
+	now := time.Now()
+	mock.ExpectQuery(`FROM t_job_callbacks\s+WHERE status = 'PENDING' AND next_attempt_at <= \? ORDER BY next_attempt_at LIMIT \?`).
+		WillReturnRows(sqlmock.NewRows(callbackColumns).
+			AddRow(7, "delivery-1", "job-1", EventJobCompleted, url, `{"job_id":"job-1","status":"SUCCESS"}`, "PENDING", attempts, "", now, nil, now))
+	mock.ExpectExec(`UPDATE t_job_callbacks SET next_attempt_at = \?, updated_at = \?\s+WHERE id = \? AND status = 'PENDING' AND attempts = \?`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 7, attempts, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// retryAfter matches a next attempt time delay from now
+type retryAfter struct{ delay time.Duration }
+
+func (r retryAfter) Match(v driver.Value) bool {
+	at, ok := v.(time.Time)
+	return ok && at.Sub(time.Now().Add(r.delay)).Abs() < time.Second
+}
+
+func TestCallbackWorkerRetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, SignWebhook("s3cret", body), r.Header.Get(WebhookSignatureHeader))
+		assert.Equal(t, "delivery-1", r.Header.Get(WebhookDeliveryHeader))
+		assert.Equal(t, EventJobCompleted, r.Header.Get(WebhookEventHeader))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	w, mock := newCallbackWorker(t, 5)
+	ctx := context.Background()
+
+	// The second failure waits twice as long as the first
+	expectDue(mock, srv.URL, 0)
+	mock.ExpectExec(`UPDATE t_job_callbacks SET status = \?, attempts = \?, last_error = \?`).
+		WithArgs(storage.CallbackPending, 1, "webhook returned 502 Bad Gateway", retryAfter{time.Second}, nil, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Equal(t, 1, w.RunOnce(ctx))
+	expectDue(mock, srv.URL, 1)
+	mock.ExpectExec(`UPDATE t_job_callbacks SET status = \?`).
+		WithArgs(storage.CallbackPending, 2, sqlmock.AnyArg(), retryAfter{2 * time.Second}, nil, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Equal(t, 1, w.RunOnce(ctx))
+
+	expectDue(mock, srv.URL, 2)
+	mock.ExpectExec(`UPDATE t_job_callbacks SET status = \?`).
+		WithArgs(storage.CallbackDelivered, 3, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Equal(t, 1, w.RunOnce(ctx))
+	assert.EqualValues(t, 3, calls.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCallbackWorkerGivesUpAfterMaxAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	w, mock := newCallbackWorker(t, 2)
+
+	expectDue(mock, srv.URL, 1)
+	mock.ExpectExec(`UPDATE t_job_callbacks SET status = \?`).
+		WithArgs(storage.CallbackFailed, 2, "webhook returned 500 Internal Server Error", sqlmock.AnyArg(), nil, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Equal(t, 1, w.RunOnce(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCallbackWorkerSkipsDeliveriesClaimedElsewhere(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { calls.Add(1) }))
+	defer srv.Close()
+	w, mock := newCallbackWorker(t, 5)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM t_job_callbacks`).
+		WillReturnRows(sqlmock.NewRows(callbackColumns).
+			AddRow(7, "delivery-1", "job-1", EventJobCompleted, srv.URL, `{}`, "PENDING", 0, "", now, nil, now))
+	mock.ExpectExec(`UPDATE t_job_callbacks SET next_attempt_at = \?`).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Equal(t, 1, w.RunOnce(context.Background()))
+	assert.Zero(t, calls.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookSenderRefusesPrivateTargets(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { calls.Add(1) }))
+	defer srv.Close()
+
+	sender := NewWebhookSender(DefaultWebhookConfig(), nil)
+	for _, url := range []string{srv.URL, "http://169.254.169.254/latest/meta-data", "http://10.0.0.8:8080/hook"} {
+		err := sender.Deliver(context.Background(), url, EventJobCompleted, "delivery-1", []byte(`{}`))
+		assert.ErrorIs(t, err, ErrPrivateWebhookTarget, url)
+	}
+	assert.Zero(t, calls.Load())
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDispatchQueueFull is returned by Enqueue when the queue is at capacity
+	ErrDispatchQueueFull = errors.New("dispatch queue is full")
+	// ErrDispatchQueueClosed is returned by Enqueue after Close
+	ErrDispatchQueueClosed = errors.New("dispatch queue is closed")
+)
+
+// QueuedJob is a created job waiting to be sent to the algorithm service
+type QueuedJob struct {
+	JobID      string
+	SchemeCode string
+	DataRef    string
+	Params     map[string]any
+	Metadata   map[string]string
+	// AlgoTarget names the algorithm service target ("" for the default)
+	AlgoTarget string
+	// ResourceType is the scheme's resource type (e.g. "GPU"), if known
+	ResourceType string
+	// Priority orders dispatch: higher values go first
+	Priority   int
+	EnqueuedAt time.Time
+
+	seq   uint64
+	index int
+}
+
+// DispatchFunc sends one job to the algorithm service
+type DispatchFunc func(ctx context.Context, job QueuedJob)
+
+// DispatchQueueConfig tunes a DispatchQueue
+type DispatchQueueConfig struct {
+	// Capacity caps the jobs waiting in the queue (0 means no cap)
+	Capacity int
+	// MaxInFlight is the number of dispatches running at once
+	MaxInFlight int
+	// SchemeLimits caps the dispatched jobs running at once per scheme code
+	// and ResourceLimits per resource type; a job holds its slots from
+	// dispatch until Release. Schemes and types without a positive limit are
+	// not capped.
+	SchemeLimits   map[string]int
+	ResourceLimits map[string]int
+	// Finished, if set, returns those of jobIDs that have reached a terminal
+	// status. A job that finishes on another replica never calls Release
+	// here, so while queued jobs are held back by their limits, the jobs
+	// holding slots are checked every RecheckInterval (default 10s) and the
+	// finished ones released.
+	Finished        func(ctx context.Context, jobIDs []string) []string
+	RecheckInterval time.Duration
+}
+
+// DispatchQueue holds created jobs in memory and dispatches them by priority,
+// then by age, with at most MaxInFlight dispatches running at once. A job
+// whose scheme or resource type already has its limit of jobs running waits
+// while the jobs behind it go ahead, so a flood of one scheme cannot take the
+// whole algorithm service. Queued jobs and running counts are not persisted;
+// queued jobs stay PENDING if the process stops first. Slots are counted per
+// replica, so each replica enforces the limits on its own dispatches.
+type DispatchQueue struct {
+	cfg      DispatchQueueConfig
+	dispatch DispatchFunc
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	heap     jobHeap
+	byID     map[string]*QueuedJob
+	seq      uint64
+	inFlight int
+	closed   bool
+	// running holds the dispatched jobs not yet released; schemeInFlight
+	// and resourceInFlight count them by scheme code and resource type
+	running          map[string]*QueuedJob
+	schemeInFlight   map[string]int
+	resourceInFlight map[string]int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// stop ends the recheck loop on Close
+	stop chan struct{}
+}
+
+// NewDispatchQueue creates a queue and starts its workers
+func NewDispatchQueue(cfg DispatchQueueConfig, dispatch DispatchFunc) *DispatchQueue {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1
+	}
+	q := &DispatchQueue{
+		cfg:      cfg,
+		dispatch: dispatch,
+		byID:     make(map[string]*QueuedJob),
+
+		running:          make(map[string]*QueuedJob),
+		schemeInFlight:   make(map[string]int),
+		resourceInFlight: make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	q.stop = make(chan struct{})
+	for i := 0; i < cfg.MaxInFlight; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	if cfg.Finished != nil {
+		if q.cfg.RecheckInterval <= 0 {
+			q.cfg.RecheckInterval = 10 * time.Second
+		}
+		go q.recheck()
+	}
+	return q
+}
+
+// Enqueue adds a job to the queue
+func (q *DispatchQueue) Enqueue(job QueuedJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrDispatchQueueClosed
+	}
+	if q.cfg.Capacity > 0 && len(q.heap) >= q.cfg.Capacity {
+		return ErrDispatchQueueFull
+	}
+	if _, ok := q.byID[job.JobID]; ok {
+		return nil
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+	q.seq++
+	job.seq = q.seq
+	heap.Push(&q.heap, &job)
+	q.byID[job.JobID] = &job
+	q.cond.Signal()
+	return nil
+}
+
+// Remove drops a job that has not been dispatched yet, reporting whether it
+// was still queued
+func (q *DispatchQueue) Remove(jobID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.byID[jobID]
+	if !ok {
+		return false
+	}
+	heap.Remove(&q.heap, job.index)
+	delete(q.byID, jobID)
+	return true
+}
+
+// Depth returns the number of jobs waiting to be dispatched
+func (q *DispatchQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.heap)
+}
+
+// InFlight returns the number of dispatches currently running
+func (q *DispatchQueue) InFlight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.inFlight
+}
+
+// MaxInFlight returns the configured dispatch concurrency
+func (q *DispatchQueue) MaxInFlight() int {
+	return q.cfg.MaxInFlight
+}
+
+// InFlightBySchemeAndResource returns the dispatched jobs still running per
+// scheme code and per resource type; jobs of unknown resource type are not
+// counted in the latter
+func (q *DispatchQueue) InFlightBySchemeAndResource() (map[string]int, map[string]int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return maps.Clone(q.schemeInFlight), maps.Clone(q.resourceInFlight)
+}
+
+// Close stops accepting jobs and waits for running dispatches, cancelling
+// them when ctx expires. Jobs still queued are returned in dispatch order.
+func (q *DispatchQueue) Close(ctx context.Context) ([]QueuedJob, error) {
+	q.mu.Lock()
+	if !q.closed {
+		close(q.stop)
+	}
+	q.closed = true
+	var left []QueuedJob
+	for len(q.heap) > 0 {
+		job := heap.Pop(&q.heap).(*QueuedJob)
+		delete(q.byID, job.JobID)
+		left = append(left, *job)
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.cancel()
+		return left, nil
+	case <-ctx.Done():
+		q.cancel()
+		return left, ctx.Err()
+	}
+}
+
+func (q *DispatchQueue) work() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		var job *QueuedJob
+		for !q.closed {
+			if job = q.nextLocked(); job != nil {
+				break
+			}
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		heap.Remove(&q.heap, job.index)
+		delete(q.byID, job.JobID)
+		q.inFlight++
+		// Taken before dispatching, so a job failed during its own
+		// submission releases a slot it already holds
+		q.running[job.JobID] = job
+		q.schemeInFlight[job.SchemeCode]++
+		if job.ResourceType != "" {
+			q.resourceInFlight[job.ResourceType]++
+		}
+		q.mu.Unlock()
+
+		q.dispatch(q.ctx, *job)
+
+		q.mu.Lock()
+		q.inFlight--
+		q.mu.Unlock()
+	}
+}
+
+// Release frees the scheme and resource slots of jobs that reached a
+// terminal status. Jobs not dispatched by this queue, or already released,
+// are ignored.
+func (q *DispatchQueue) Release(jobIDs ...string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, jobID := range jobIDs {
+		job, ok := q.running[jobID]
+		if !ok {
+			continue
+		}
+		delete(q.running, jobID)
+		decrement(q.schemeInFlight, job.SchemeCode)
+		if job.ResourceType != "" {
+			decrement(q.resourceInFlight, job.ResourceType)
+		}
+	}
+	// The freed slots may unblock jobs idle workers passed over
+	q.cond.Broadcast()
+}
+
+// recheck releases the slots of finished jobs while queued jobs are held
+// back by their limits
+func (q *DispatchQueue) recheck() {
+	ticker := time.NewTicker(q.cfg.RecheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+		holding := q.blockedHolders()
+		if len(holding) == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(q.ctx, q.cfg.RecheckInterval)
+		finished := q.cfg.Finished(ctx, holding)
+		cancel()
+		q.Release(finished...)
+	}
+}
+
+// blockedHolders returns the dispatched jobs still holding slots when jobs
+// are queued but none of them is under its limits, nil otherwise
+func (q *DispatchQueue) blockedHolders() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.heap) == 0 || q.nextLocked() != nil {
+		return nil
+	}
+	holding := make([]string, 0, len(q.running))
+	for jobID := range q.running {
+		holding = append(holding, jobID)
+	}
+	return holding
+}
+
+// nextLocked returns the first job in dispatch order whose scheme and
+// resource type are under their limits, nil if there is none; q.mu must be
+// held. The heap's top is checked first, so only a blocked top costs a scan.
+func (q *DispatchQueue) nextLocked() *QueuedJob {
+	if len(q.heap) == 0 {
+		return nil
+	}
+	if q.admits(q.heap[0]) {
+		return q.heap[0]
+	}
+	var best *QueuedJob
+	for _, job := range q.heap[1:] {
+		if q.admits(job) && (best == nil || q.heap.less(job, best)) {
+			best = job
+		}
+	}
+	return best
+}
+
+// admits reports whether a job's scheme and resource type have a free slot
+func (q *DispatchQueue) admits(job *QueuedJob) bool {
+	if limit := q.cfg.SchemeLimits[job.SchemeCode]; limit > 0 && q.schemeInFlight[job.SchemeCode] >= limit {
+		return false
+	}
+	if limit := q.cfg.ResourceLimits[job.ResourceType]; job.ResourceType != "" && limit > 0 && q.resourceInFlight[job.ResourceType] >= limit {
+		return false
+	}
+	return true
+}
+
+// decrement lowers a count, dropping it at zero
+func decrement(counts map[string]int, key string) {
+	if counts[key]--; counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// jobHeap orders jobs by priority (highest first), then enqueue time, then
+// arrival order
+type jobHeap []*QueuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+
+func (jobHeap) less(a, b *QueuedJob) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.EnqueuedAt.Equal(b.EnqueuedAt) {
+		return a.EnqueuedAt.Before(b.EnqueuedAt)
+	}
+	return a.seq < b.seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x any) {
+	job := x.(*QueuedJob)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return job
+}
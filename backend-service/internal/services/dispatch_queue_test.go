@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingDispatcher records dispatch order and holds each dispatch until released
+type blockingDispatcher struct {
+	mu      sync.Mutex
+	order   []string
+	started chan string
+	release chan struct{}
+}
+
+func newBlockingDispatcher() *blockingDispatcher {
+	return &blockingDispatcher{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+func (d *blockingDispatcher) dispatch(ctx context.Context, job QueuedJob) {
+	d.mu.Lock()
+	d.order = append(d.order, job.JobID)
+	d.mu.Unlock()
+	d.started <- job.JobID
+	select {
+	case <-d.release:
+	case <-ctx.Done():
+	}
+}
+
+func (d *blockingDispatcher) waitStarted(t *testing.T) string {
+	t.Helper()
+	select {
+	case id := <-d.started:
+		return id
+	case <-time.After(2 * time.Second):
+		t.Fatal("no dispatch started")
+		return ""
+	}
+}
+
+func TestDispatchQueueOrdersByPriorityThenAge(t *testing.T) {
+	d := newBlockingDispatcher()
+	q := NewDispatchQueue(DispatchQueueConfig{MaxInFlight: 1}, d.dispatch)
+	defer q.Close(context.Background())
+
+	// The first job occupies the only worker while the rest queue up
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "first"}))
+	assert.Equal(t, "first", d.waitStarted(t))
+
+	base := time.Now()
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "low-old", EnqueuedAt: base}))
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "high", Priority: 5, EnqueuedAt: base.Add(2 * time.Second)}))
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "low-new", EnqueuedAt: base.Add(time.Second)}))
+	assert.Equal(t, 3, q.Depth())
+	assert.Equal(t, 1, q.InFlight())
+
+	for _, want := range []string{"high", "low-old", "low-new"} {
+		d.release <- struct{}{}
+		assert.Equal(t, want, d.waitStarted(t))
+	}
+	d.release <- struct{}{}
+}
+
+func TestDispatchQueueRemoveAndCapacity(t *testing.T) {
+	d := newBlockingDispatcher()
+	q := NewDispatchQueue(DispatchQueueConfig{MaxInFlight: 1, Capacity: 2}, d.dispatch)
+
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "running"}))
+	d.waitStarted(t)
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "a"}))
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "b"}))
+	assert.ErrorIs(t, q.Enqueue(QueuedJob{JobID: "c"}), ErrDispatchQueueFull)
+
+	assert.True(t, q.Remove("a"))
+	assert.False(t, q.Remove("a"))
+	assert.False(t, q.Remove("running"), "a dispatched job is no longer queued")
+	assert.Equal(t, 1, q.Depth())
+
+	// Jobs still queued at shutdown are handed back instead of dispatched
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go func() { d.release <- struct{}{} }()
+	left, err := q.Close(ctx)
+	require.NoError(t, err)
+	require.Len(t, left, 1)
+	assert.Equal(t, "b", left[0].JobID)
+	assert.Equal(t, []string{"running"}, d.order)
+	assert.ErrorIs(t, q.Enqueue(QueuedJob{JobID: "late"}), ErrDispatchQueueClosed)
+}
+
+func TestDispatchQueueBoundsInFlight(t *testing.T) {
+	d := newBlockingDispatcher()
+	q := NewDispatchQueue(DispatchQueueConfig{MaxInFlight: 2}, d.dispatch)
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, q.Enqueue(QueuedJob{JobID: id}))
+	}
+	d.waitStarted(t)
+	d.waitStarted(t)
+	select {
+	case id := <-d.started:
+		t.Fatalf("job %s dispatched beyond the in-flight cap", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 2, q.InFlight())
+	assert.Equal(t, 2, q.Depth())
+
+	// Closing with an expired context cancels the running dispatches
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	left, err := q.Close(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, left, 2)
+}
+
+// jobDispatcher holds each dispatch until that job is released
+type jobDispatcher struct {
+	started chan string
+	mu      sync.Mutex
+	release map[string]chan struct{}
+}
+
+func newJobDispatcher() *jobDispatcher {
+	return &jobDispatcher{started: make(chan string, 16), release: make(map[string]chan struct{})}
+}
+
+func (d *jobDispatcher) gate(jobID string) chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.release[jobID] == nil {
+		d.release[jobID] = make(chan struct{})
+	}
+	return d.release[jobID]
+}
+
+func (d *jobDispatcher) dispatch(ctx context.Context, job QueuedJob) {
+	d.started <- job.JobID
+	select {
+	case <-d.gate(job.JobID):
+	case <-ctx.Done():
+	}
+}
+
+func (d *jobDispatcher) waitStarted(t *testing.T) string {
+	t.Helper()
+	select {
+	case id := <-d.started:
+		return id
+	case <-time.After(2 * time.Second):
+		t.Fatal("no dispatch started")
+		return ""
+	}
+}
+
+func (d *jobDispatcher) assertIdle(t *testing.T) {
+	t.Helper()
+	select {
+	case id := <-d.started:
+		t.Fatalf("job %s dispatched beyond its limit", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatchQueueSchemeLimitLetsOtherSchemesAhead(t *testing.T) {
+	d := newJobDispatcher()
+	q := NewDispatchQueue(DispatchQueueConfig{MaxInFlight: 3, SchemeLimits: map[string]int{"SCM-WF01": 1}}, d.dispatch)
+	defer q.Close(context.Background())
+
+	base := time.Now()
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "gpu-1", SchemeCode: "SCM-WF01", EnqueuedAt: base}))
+	assert.Equal(t, "gpu-1", d.waitStarted(t))
+	// gpu-2 is older and outranks cpu-1, but its scheme is at its limit
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "gpu-2", SchemeCode: "SCM-WF01", Priority: 5, EnqueuedAt: base.Add(time.Second)}))
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "cpu-1", SchemeCode: "KBM-WF01", EnqueuedAt: base.Add(2 * time.Second)}))
+	assert.Equal(t, "cpu-1", d.waitStarted(t))
+	d.assertIdle(t)
+	assert.Equal(t, 1, q.Depth())
+	schemes, resources := q.InFlightBySchemeAndResource()
+	assert.Equal(t, map[string]int{"SCM-WF01": 1, "KBM-WF01": 1}, schemes)
+	assert.Empty(t, resources)
+
+	// Finishing a job of another scheme frees no slot for gpu-2
+	close(d.gate("cpu-1"))
+	q.Release("cpu-1")
+	d.assertIdle(t)
+	// gpu-1 keeps its slot while it runs on the algorithm service
+	close(d.gate("gpu-1"))
+	d.assertIdle(t)
+	schemes, _ = q.InFlightBySchemeAndResource()
+	assert.Equal(t, map[string]int{"SCM-WF01": 1}, schemes)
+	q.Release("gpu-1")
+	assert.Equal(t, "gpu-2", d.waitStarted(t))
+	close(d.gate("gpu-2"))
+}
+
+func TestDispatchQueueResourceLimit(t *testing.T) {
+	d := newJobDispatcher()
+	q := NewDispatchQueue(DispatchQueueConfig{MaxInFlight: 3, ResourceLimits: map[string]int{"GPU": 1}}, d.dispatch)
+	defer q.Close(context.Background())
+
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "gpu-a", SchemeCode: "SCM-WF01", ResourceType: "GPU"}))
+	assert.Equal(t, "gpu-a", d.waitStarted(t))
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "gpu-b", SchemeCode: "STM-WF02", ResourceType: "GPU"}))
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "unknown", SchemeCode: "KBM-WF09"}))
+	assert.Equal(t, "unknown", d.waitStarted(t))
+	d.assertIdle(t)
+	schemes, resources := q.InFlightBySchemeAndResource()
+	assert.Equal(t, map[string]int{"SCM-WF01": 1, "KBM-WF09": 1}, schemes)
+	assert.Equal(t, map[string]int{"GPU": 1}, resources)
+
+	close(d.gate("gpu-a"))
+	d.assertIdle(t)
+	q.Release("gpu-a", "gpu-a")
+	assert.Equal(t, "gpu-b", d.waitStarted(t))
+	close(d.gate("gpu-b"))
+	close(d.gate("unknown"))
+}
+
+// TestDispatchQueueRechecksBlockedSlots tests that a job finished where this
+// queue never hears of it, e.g. on another replica, stops holding its slot
+// once the blocked queue checks the holders
+func TestDispatchQueueRechecksBlockedSlots(t *testing.T) {
+	d := newJobDispatcher()
+	var mu sync.Mutex
+	var checked [][]string
+	done := map[string]bool{}
+	q := NewDispatchQueue(DispatchQueueConfig{
+		MaxInFlight:  2,
+		SchemeLimits: map[string]int{"SCM-WF01": 1},
+		Finished: func(_ context.Context, jobIDs []string) []string {
+			mu.Lock()
+			defer mu.Unlock()
+			checked = append(checked, jobIDs)
+			var finished []string
+			for _, jobID := range jobIDs {
+				if done[jobID] {
+					finished = append(finished, jobID)
+				}
+			}
+			return finished
+		},
+		RecheckInterval: 20 * time.Millisecond,
+	}, d.dispatch)
+	defer q.Close(context.Background())
+
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "gpu-1", SchemeCode: "SCM-WF01"}))
+	assert.Equal(t, "gpu-1", d.waitStarted(t))
+	close(d.gate("gpu-1"))
+	// Nothing is blocked, so nothing is checked
+	time.Sleep(60 * time.Millisecond)
+	mu.Lock()
+	assert.Empty(t, checked)
+	mu.Unlock()
+
+	require.NoError(t, q.Enqueue(QueuedJob{JobID: "gpu-2", SchemeCode: "SCM-WF01"}))
+	d.assertIdle(t)
+	mu.Lock()
+	assert.NotEmpty(t, checked)
+	assert.Equal(t, []string{"gpu-1"}, checked[0])
+	done["gpu-1"] = true
+	mu.Unlock()
+	assert.Equal(t, "gpu-2", d.waitStarted(t))
+	close(d.gate("gpu-2"))
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// Job lifecycle event types
+const (
+	JobEventCreated   = "job.created"
+	JobEventStarted   = "job.started"
+	JobEventMilestone = EventProgressMilestone
+	JobEventTerminal  = "job.terminal"
+)
+
+// JobEvent is a job lifecycle transition published for downstream consumers.
+// Fields that do not apply to the event type are left empty.
+type JobEvent struct {
+	Type       string `json:"type"`
+	JobID      string `json:"job_id"`
+	SchemeCode string `json:"scheme_code,omitempty"`
+	UserID     string `json:"user_id,omitempty"`
+	// Status is the job status the event leaves it in
+	Status     string `json:"status"`
+	Milestone  int    `json:"milestone,omitempty"`
+	Percentage int32  `json:"percentage,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// EventPublisher publishes job lifecycle events to a message broker
+type EventPublisher interface {
+	Publish(ctx context.Context, event JobEvent) error
+}
+
+// NopEventPublisher discards events; it is the JobService default
+type NopEventPublisher struct{}
+
+func (NopEventPublisher) Publish(context.Context, JobEvent) error { return nil }
+
+// RedisStreamPublisher appends events to a Redis stream. Each entry has the
+// event type and job ID as fields of their own, for consumers filtering
+// without decoding, and the whole event as JSON in "event".
+type RedisStreamPublisher struct {
+	cache  *storage.RedisCache
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamPublisher publishes to stream, trimmed to about maxLen
+// entries (0 keeps every entry)
+func NewRedisStreamPublisher(cache *storage.RedisCache, stream string, maxLen int64) *RedisStreamPublisher {
+	return &RedisStreamPublisher{cache: cache, stream: stream, maxLen: maxLen}
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, event JobEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = p.cache.XAdd(ctx, p.stream, p.maxLen, map[string]any{
+		"type":   event.Type,
+		"job_id": event.JobID,
+		"event":  payload,
+	})
+	return err
+}
+
+// Overflow policies of an AsyncEventPublisher
+const (
+	EventOverflowDrop  = "drop"
+	EventOverflowBlock = "block"
+)
+
+// ErrEventQueueClosed is returned when publishing to a closed AsyncEventPublisher
+var ErrEventQueueClosed = errors.New("event queue closed")
+
+// AsyncEventConfig configures an AsyncEventPublisher
+type AsyncEventConfig struct {
+	// BufferSize bounds the events waiting to be published
+	BufferSize int
+	// Overflow is what Publish does when the buffer is full: EventOverflowDrop
+	// discards the event, EventOverflowBlock waits for room up to Timeout
+	Overflow string
+	// Timeout bounds each publish to the underlying publisher, and the wait
+	// for room under EventOverflowBlock
+	Timeout time.Duration
+}
+
+// DefaultAsyncEventConfig returns the default buffering settings
+func DefaultAsyncEventConfig() AsyncEventConfig {
+	return AsyncEventConfig{
+		BufferSize: 1024,
+		Overflow:   EventOverflowDrop,
+		Timeout:    5 * time.Second,
+	}
+}
+
+// AsyncEventPublisher buffers events and publishes them in order from a
+// background goroutine, so a slow or unreachable broker does not hold up
+// job transitions. Events failing to publish are logged and dropped.
+type AsyncEventPublisher struct {
+	next   EventPublisher
+	cfg    AsyncEventConfig
+	logger *zap.Logger
+
+	queue  chan JobEvent
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewAsyncEventPublisher starts publishing buffered events to next
+func NewAsyncEventPublisher(next EventPublisher, cfg AsyncEventConfig, logger *zap.Logger) *AsyncEventPublisher {
+	defaults := DefaultAsyncEventConfig()
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaults.BufferSize
+	}
+	if cfg.Overflow == "" {
+		cfg.Overflow = defaults.Overflow
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	p := &AsyncEventPublisher{
+		next:   next,
+		cfg:    cfg,
+		logger: logger,
+		queue:  make(chan JobEvent, cfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues event. With the drop policy a full buffer discards it;
+// with the block policy Publish waits for room until ctx is done or the
+// timeout elapses, so a broker outage slows transitions but never halts them.
+func (p *AsyncEventPublisher) Publish(ctx context.Context, event JobEvent) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrEventQueueClosed
+	}
+	if p.cfg.Overflow == EventOverflowBlock {
+		timer := time.NewTimer(p.cfg.Timeout)
+		defer timer.Stop()
+		select {
+		case p.queue <- event:
+			return nil
+		case <-timer.C:
+			return context.DeadlineExceeded
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case p.queue <- event:
+	default:
+		p.logger.Warn("Event queue full, dropping job event",
+			zap.String("type", event.Type), zap.String("job_id", event.JobID))
+	}
+	return nil
+}
+
+func (p *AsyncEventPublisher) run() {
+	defer close(p.done)
+	for event := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+		err := p.next.Publish(ctx, event)
+		cancel()
+		if err != nil {
+			p.logger.Warn("Failed to publish job event", zap.String("type", event.Type),
+				zap.String("job_id", event.JobID), zap.Error(err))
+		}
+	}
+}
+
+// Close stops accepting events and publishes the buffered ones until ctx is
+// done
+func (p *AsyncEventPublisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetEventPublisher publishes job lifecycle events through p, including a
+// milestone event when a job's progress first reaches each of milestones
+func (s *JobService) SetEventPublisher(p EventPublisher, milestones []int) {
+	s.events = p
+	s.eventMilestones = sortedMilestones(milestones)
+}
+
+// emit publishes a lifecycle event; failures are logged, never returned,
+// so events cannot fail a transition
+func (s *JobService) emit(event JobEvent) {
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().UnixMilli()
+	}
+	if err := s.events.Publish(context.Background(), event); err != nil {
+		s.logger.Warn("Failed to publish job event", zap.String("type", event.Type),
+			zap.String("job_id", event.JobID), zap.Error(err))
+	}
+}
+
+// defaultEventIdle is how long a job may go without a progress report before
+// its milestone tracking is dropped
+const defaultEventIdle = time.Hour
+
+// eventMark is the highest milestone event sent for a job and when its
+// progress was last seen
+type eventMark struct {
+	milestone int
+	seen      time.Time
+}
+
+// emitProgress publishes the started event of a job on its first progress
+// report and a milestone event for each milestone it newly reached. Jobs
+// are tracked per instance, so a job whose progress moves to another
+// instance, or resumes after being silent for eventIdle, may repeat events.
+func (s *JobService) emitProgress(msg models.ProgressMsg) {
+	now := time.Now()
+	s.eventMu.Lock()
+	if now.Sub(s.eventSwept) >= s.eventIdle {
+		for jobID, mark := range s.eventProgress {
+			if now.Sub(mark.seen) >= s.eventIdle {
+				delete(s.eventProgress, jobID)
+			}
+		}
+		s.eventSwept = now
+	}
+	mark, started := s.eventProgress[msg.TaskID]
+	var crossed []int
+	for _, m := range s.eventMilestones {
+		if m > mark.milestone && m <= int(msg.Percentage) {
+			crossed = append(crossed, m)
+		}
+	}
+	if len(crossed) > 0 {
+		mark.milestone = crossed[len(crossed)-1]
+	}
+	mark.seen = now
+	s.eventProgress[msg.TaskID] = mark
+	s.eventMu.Unlock()
+
+	if !started {
+		s.emit(JobEvent{Type: JobEventStarted, JobID: msg.TaskID, Status: "RUNNING", Percentage: msg.Percentage, Message: msg.Message})
+	}
+	for _, m := range crossed {
+		s.emit(JobEvent{Type: JobEventMilestone, JobID: msg.TaskID, Status: "RUNNING", Milestone: m, Percentage: msg.Percentage, Message: msg.Message})
+	}
+}
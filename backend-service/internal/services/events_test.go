@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+)
+
+// streamEvents reads back the events in a Redis stream
+func streamEvents(t *testing.T, mr *miniredis.Miniredis, stream string) []JobEvent {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	entries, err := client.XRange(context.Background(), stream, "-", "+").Result()
+	require.NoError(t, err)
+	events := make([]JobEvent, 0, len(entries))
+	for _, entry := range entries {
+		var event JobEvent
+		require.NoError(t, json.Unmarshal([]byte(entry.Values["event"].(string)), &event))
+		assert.Equal(t, event.Type, entry.Values["type"])
+		assert.Equal(t, event.JobID, entry.Values["job_id"])
+		assert.NotZero(t, event.Timestamp)
+		event.Timestamp = 0
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestJobEventsPublishedToRedisStream(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCacheWithConfig(storage.RedisCacheConfig{Addr: mr.Addr(), KeyPrefix: "env:"})
+	t.Cleanup(func() { _ = cache.Close() })
+	hub := ws.NewHubWithConfig(ws.HubConfig{}, nil)
+	t.Cleanup(hub.Close)
+
+	s := NewJobService(storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql")), cache, hub, "schemes", "progress:")
+	events := NewAsyncEventPublisher(NewRedisStreamPublisher(cache, "job:events", 1000), DefaultAsyncEventConfig(), nil)
+	s.SetEventPublisher(events, []int{50, 25, 75})
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, s.CreateJob(ctx, "job-1", "KBM-WF01", "alice", "ref", "{}"))
+	for _, pct := range []int32{10, 60, 70, 80} {
+		expectProgressUpdate(mock)
+		require.NoError(t, s.UpdateProgress(ctx, models.ProgressMsg{TaskID: "job-1", Percentage: pct, Message: "solving"}))
+	}
+	mock.ExpectExec(`INSERT INTO t_job_results`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'SUCCESS'`).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.FinishJob(ctx, "job-1", `{"summary":{}}`))
+
+	mock.ExpectExec(`INSERT INTO t_algo_jobs`).WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, s.CreateJob(ctx, "job-2", "SCM-01", "bob", "ref", "{}"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'CANCELLED'`).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.CancelJob(ctx, "job-2", "no longer needed"))
+
+	require.NoError(t, events.Close(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []JobEvent{
+		{Type: JobEventCreated, JobID: "job-1", SchemeCode: "KBM-WF01", UserID: "alice", Status: "PENDING"},
+		{Type: JobEventStarted, JobID: "job-1", Status: "RUNNING", Percentage: 10, Message: "solving"},
+		{Type: JobEventMilestone, JobID: "job-1", Status: "RUNNING", Milestone: 25, Percentage: 60, Message: "solving"},
+		{Type: JobEventMilestone, JobID: "job-1", Status: "RUNNING", Milestone: 50, Percentage: 60, Message: "solving"},
+		{Type: JobEventMilestone, JobID: "job-1", Status: "RUNNING", Milestone: 75, Percentage: 80, Message: "solving"},
+		{Type: JobEventTerminal, JobID: "job-1", Status: "SUCCESS"},
+		{Type: JobEventCreated, JobID: "job-2", SchemeCode: "SCM-01", UserID: "bob", Status: "PENDING"},
+		{Type: JobEventTerminal, JobID: "job-2", Status: "CANCELLED"},
+	}, streamEvents(t, mr, "env:job:events"))
+}
+
+// gatedPublisher records events, each publish waiting for the gate to open
+// whatever its context says, like a broker ignoring deadlines
+type gatedPublisher struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	got  []string
+}
+
+func (p *gatedPublisher) Publish(_ context.Context, event JobEvent) error {
+	<-p.gate
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.got = append(p.got, event.JobID)
+	return nil
+}
+
+func TestAsyncEventPublisherOverflow(t *testing.T) {
+	t.Run("Drop", func(t *testing.T) {
+		next := &gatedPublisher{gate: make(chan struct{})}
+		p := NewAsyncEventPublisher(next, AsyncEventConfig{BufferSize: 2, Overflow: EventOverflowDrop}, nil)
+		ctx := context.Background()
+		// The first event is taken by the worker, two wait, the rest are dropped
+		require.NoError(t, p.Publish(ctx, JobEvent{JobID: "a"}))
+		assert.Eventually(t, func() bool { return len(p.queue) == 0 }, time.Second, time.Millisecond)
+		for _, id := range []string{"b", "c", "d", "e"} {
+			start := time.Now()
+			require.NoError(t, p.Publish(ctx, JobEvent{JobID: id}))
+			assert.Less(t, time.Since(start), 100*time.Millisecond, "publishing never blocks")
+		}
+		close(next.gate)
+		require.NoError(t, p.Close(ctx))
+		assert.Equal(t, []string{"a", "b", "c"}, next.got)
+		assert.ErrorIs(t, p.Publish(ctx, JobEvent{JobID: "f"}), ErrEventQueueClosed)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		next := &gatedPublisher{gate: make(chan struct{})}
+		p := NewAsyncEventPublisher(next, AsyncEventConfig{BufferSize: 1, Overflow: EventOverflowBlock, Timeout: 50 * time.Millisecond}, nil)
+		ctx := context.Background()
+		require.NoError(t, p.Publish(ctx, JobEvent{JobID: "a"}))
+		assert.Eventually(t, func() bool { return len(p.queue) == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, p.Publish(ctx, JobEvent{JobID: "b"}))
+		// The buffer is full until the broker takes an event
+		assert.True(t, errors.Is(p.Publish(ctx, JobEvent{JobID: "c"}), context.DeadlineExceeded))
+
+		blocked := make(chan error, 1)
+		go func() { blocked <- p.Publish(ctx, JobEvent{JobID: "d"}) }()
+		close(next.gate)
+		require.NoError(t, <-blocked)
+		require.NoError(t, p.Close(ctx))
+		assert.Equal(t, []string{"a", "b", "d"}, next.got)
+	})
+}
+
+// recordingPublisher records the type and job of each event
+type recordingPublisher struct {
+	mu  sync.Mutex
+	got []string
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event JobEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.got = append(p.got, event.Type+" "+event.JobID)
+	return nil
+}
+
+// TestEventProgressForgetsSilentJobs tests that jobs which stop reporting,
+// such as ones finishing on another instance, are not tracked forever
+func TestEventProgressForgetsSilentJobs(t *testing.T) {
+	s := NewJobService(nil, nil, nil, "schemes", "progress:")
+	events := &recordingPublisher{}
+	s.SetEventPublisher(events, []int{50})
+	s.eventIdle = 200 * time.Millisecond
+
+	s.emitProgress(models.ProgressMsg{TaskID: "elsewhere", Percentage: 60})
+	s.emitProgress(models.ProgressMsg{TaskID: "here", Percentage: 10})
+	time.Sleep(120 * time.Millisecond)
+	s.emitProgress(models.ProgressMsg{TaskID: "here", Percentage: 20})
+	time.Sleep(120 * time.Millisecond)
+	s.emitProgress(models.ProgressMsg{TaskID: "here", Percentage: 30})
+
+	s.eventMu.Lock()
+	tracked := make([]string, 0, len(s.eventProgress))
+	for jobID := range s.eventProgress {
+		tracked = append(tracked, jobID)
+	}
+	s.eventMu.Unlock()
+	assert.Equal(t, []string{"here"}, tracked)
+	assert.Equal(t, []string{JobEventStarted + " elsewhere", JobEventMilestone + " elsewhere", JobEventStarted + " here"}, events.got)
+}
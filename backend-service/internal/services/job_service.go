@@ -3,58 +3,564 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
 	"github.com/electric-power/backend-service/internal/models"
 	"github.com/electric-power/backend-service/internal/storage"
 	"github.com/electric-power/backend-service/internal/ws"
 )
 
 type JobService struct {
-	store       *storage.MySQLStore
-	cache       *storage.RedisCache
-	hub         *ws.Hub
-	schemeKey   string
-	progressNS  string
+	store      *storage.MySQLStore
+	cache      *storage.RedisCache
+	hub        *ws.Hub
+	terminals  *ws.Throttle
+	fanout     *ProgressFanout
+	schemeKey  string
+	progressNS string
+	// schemeFetch dedupes concurrent RefreshSchemes calls
+	schemeFetch singleflight.Group
+
+	hooksMu sync.RWMutex
+	hooks   []JobHook
+	// hooksRunning tracks hooks still running after their transition returned
+	hooksRunning sync.WaitGroup
+
+	limiter  *SchemeLimiter
+	dispatch *DispatchQueue
+	logger   *zap.Logger
+
+	webhooks      *WebhookSender
+	milestoneMu   sync.Mutex
+	milestoneSubs map[string]*storage.MilestoneSubscription
+
+	startMu      sync.Mutex
+	startWaiters map[string][]chan string
+
+	events          EventPublisher
+	eventMilestones []int
+	// eventProgress holds the highest milestone event sent for each job
+	// seen running; a job missing from it has had no started event yet.
+	// Jobs silent for eventIdle are dropped, as one finishing on another
+	// instance is never removed by publishTerminal here.
+	eventMu       sync.Mutex
+	eventProgress map[string]eventMark
+	eventIdle     time.Duration
+	eventSwept    time.Time
+
+	maxResultBytes int
+	resultSize     prometheus.Histogram
+	slaFlagged     *prometheus.CounterVec
 }
 
+// JobHook is notified when a job reaches a terminal status, whether through a
+// result callback, the scheduler or a cancellation. detail is the result JSON
+// on success or the error message otherwise.
+type JobHook func(ctx context.Context, jobID string, success bool, detail string)
+
 func NewJobService(store *storage.MySQLStore, cache *storage.RedisCache, hub *ws.Hub, schemeKey, progressNS string) *JobService {
-	return &JobService{store: store, cache: cache, hub: hub, schemeKey: schemeKey, progressNS: progressNS}
+	return &JobService{
+		store:         store,
+		cache:         cache,
+		hub:           hub,
+		schemeKey:     schemeKey,
+		progressNS:    progressNS,
+		logger:        zap.NewNop(),
+		startWaiters:  make(map[string][]chan string),
+		milestoneSubs: make(map[string]*storage.MilestoneSubscription),
+		events:        NopEventPublisher{},
+		eventProgress: make(map[string]eventMark),
+		eventIdle:     defaultEventIdle,
+		resultSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "algo_job_result_bytes",
+			Help:    "Size of results reported for successful jobs",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 11), // 1KB to 1GB
+		}),
+		slaFlagged: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "algo_job_sla_flagged_total",
+			Help: "Jobs flagged as at risk of or in breach of their SLA deadline",
+		}, []string{"state"}),
+	}
+}
+
+// ErrResultTooLarge is returned by FinishJob when a result exceeds the
+// configured maximum; the job has been failed instead
+var ErrResultTooLarge = errors.New("result too large")
+
+// SetMaxResultBytes caps the size of stored results; 0 disables the cap
+func (s *JobService) SetMaxResultBytes(n int) {
+	s.maxResultBytes = n
+}
+
+// ResultSizeCollector exposes the result size histogram for Prometheus
+func (s *JobService) ResultSizeCollector() prometheus.Collector {
+	return s.resultSize
+}
+
+// SLACollector exposes the SLA flag counter for Prometheus
+func (s *JobService) SLACollector() prometheus.Collector {
+	return s.slaFlagged
+}
+
+// SetLogger sets the logger used for cache maintenance warnings
+func (s *JobService) SetLogger(logger *zap.Logger) {
+	if logger != nil {
+		s.logger = logger
+	}
 }
 
+// ErrSchemeCacheCorrupt is returned when the cached scheme list cannot be
+// decoded; the entry has been deleted so the next refresh replaces it
+var ErrSchemeCacheCorrupt = errors.New("scheme cache entry is corrupt")
+
 func (s *JobService) CacheSchemes(ctx context.Context, schemes []models.Scheme) error {
 	return s.cache.SetJSON(ctx, s.schemeKey, schemes, 5*time.Minute)
 }
 
+// RefreshSchemes loads the schemes with fetch and caches them. Concurrent
+// refreshes, from the scheduler or a handler's cache miss alike, share one
+// fetch and its result.
+func (s *JobService) RefreshSchemes(ctx context.Context, fetch func(context.Context) ([]models.Scheme, error)) ([]models.Scheme, error) {
+	v, err, _ := s.schemeFetch.Do(s.schemeKey, func() (any, error) {
+		schemes, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.CacheSchemes(ctx, schemes); err != nil {
+			s.logger.Warn("Failed to cache schemes", zap.Error(err))
+		}
+		return schemes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]models.Scheme), nil
+}
+
 func (s *JobService) GetCachedSchemes(ctx context.Context) ([]models.Scheme, error) {
 	var schemes []models.Scheme
 	err := s.cache.GetJSON(ctx, s.schemeKey, &schemes)
+	if isCorruptCacheError(err) {
+		delErr := s.cache.Delete(ctx, s.schemeKey)
+		s.logger.Warn("Deleted corrupt scheme cache entry",
+			zap.String("key", s.schemeKey), zap.Error(err), zap.NamedError("delete_error", delErr))
+		return nil, fmt.Errorf("%w: %v", ErrSchemeCacheCorrupt, err)
+	}
 	return schemes, err
 }
 
+// isCorruptCacheError reports whether a cache read failed because the stored
+// value is undecodable or of the wrong Redis type, as opposed to a miss or an
+// unreachable Redis
+func isCorruptCacheError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
+
+// SetSchemeLimiter enables per-scheme concurrency limits for job creation
+func (s *JobService) SetSchemeLimiter(l *SchemeLimiter) {
+	s.limiter = l
+}
+
+// SetTerminalThrottle smooths job terminal events through t instead of
+// broadcasting each one as its result arrives
+func (s *JobService) SetTerminalThrottle(t *ws.Throttle) {
+	s.terminals = t
+}
+
+// SetProgressFanout also publishes progress to other instances through f
+func (s *JobService) SetProgressFanout(f *ProgressFanout) {
+	s.fanout = f
+}
+
+// SetDispatchQueue makes terminal jobs release the per-scheme and
+// per-resource slots they hold in the dispatch queue
+func (s *JobService) SetDispatchQueue(q *DispatchQueue) {
+	s.dispatch = q
+}
+
+// SchemeLimiter returns the configured limiter, nil when limits are disabled
+func (s *JobService) SchemeLimiter() *SchemeLimiter {
+	return s.limiter
+}
+
+// CreateJob inserts a job, returning ErrSchemeAtCapacity when its scheme
+// already has the maximum number of jobs in flight
 func (s *JobService) CreateJob(ctx context.Context, jobID, schemeCode, userID, dataRef, params string) error {
-	return s.store.InsertJob(ctx, jobID, schemeCode, userID, dataRef, params)
+	if !s.limiter.Acquire(schemeCode, jobID) {
+		return ErrSchemeAtCapacity
+	}
+	if err := s.store.InsertJob(ctx, jobID, schemeCode, userID, dataRef, params); err != nil {
+		s.limiter.Release(jobID)
+		return err
+	}
+	s.publishCreated(jobID, schemeCode, userID)
+	return nil
+}
+
+// CreateJobsBatch inserts jobs in a single transaction. Jobs whose scheme is
+// at capacity are left out and get ErrSchemeAtCapacity in their slot of
+// rejected; the others are created together, or none is and err is returned.
+func (s *JobService) CreateJobsBatch(ctx context.Context, jobs []storage.NewJob) (rejected []error, err error) {
+	rejected = make([]error, len(jobs))
+	accepted := make([]storage.NewJob, 0, len(jobs))
+	for i, job := range jobs {
+		if !s.limiter.Acquire(job.SchemeCode, job.JobID) {
+			rejected[i] = ErrSchemeAtCapacity
+			continue
+		}
+		job.ProgressMilestones = sortedMilestones(job.ProgressMilestones)
+		accepted = append(accepted, job)
+	}
+	if err := s.store.InsertJobsBatch(ctx, accepted); err != nil {
+		for _, job := range accepted {
+			s.limiter.Release(job.JobID)
+		}
+		return rejected, err
+	}
+	for _, job := range accepted {
+		s.publishCreated(job.JobID, job.SchemeCode, job.UserID)
+	}
+	return rejected, nil
+}
+
+// CreateChildJob inserts step stepIndex of a pipeline. Only the first step is
+// subject to scheme limits; later steps are counted but never rejected, so a
+// running pipeline is not failed midway.
+func (s *JobService) CreateChildJob(ctx context.Context, jobID, parentJobID string, stepIndex int, schemeCode, userID, dataRef, params string) error {
+	if stepIndex == 0 {
+		if !s.limiter.Acquire(schemeCode, jobID) {
+			return ErrSchemeAtCapacity
+		}
+	} else {
+		s.limiter.Track(schemeCode, jobID)
+	}
+	if err := s.store.InsertChildJob(ctx, jobID, parentJobID, stepIndex, schemeCode, userID, dataRef, params); err != nil {
+		s.limiter.Release(jobID)
+		return err
+	}
+	s.publishCreated(jobID, schemeCode, userID)
+	return nil
+}
+
+// ReleaseJobs frees the scheme and dispatch slots of jobs finished outside
+// this service, e.g. zombies failed by the scheduler
+func (s *JobService) ReleaseJobs(jobIDs []string) {
+	s.limiter.Release(jobIDs...)
+	s.dispatch.Release(jobIDs...)
+}
+
+// ZombiesFailed releases the slots of jobs failed by the scheduler and
+// announces them on the system topic
+func (s *JobService) ZombiesFailed(jobIDs []string) {
+	s.failedByScheduler(jobIDs, storage.ZombieErrorLog)
+}
+
+// JobsTimedOut is ZombiesFailed for jobs failed for running past their own timeout
+func (s *JobService) JobsTimedOut(jobIDs []string) {
+	s.failedByScheduler(jobIDs, storage.TimeoutErrorLog)
+}
+
+func (s *JobService) failedByScheduler(jobIDs []string, errorLog string) {
+	s.ReleaseJobs(jobIDs)
+	for _, jobID := range jobIDs {
+		s.publishTerminal(jobID, "FAILED")
+		s.enqueueCompletion(context.Background(), jobID, "FAILED", nil, errorLog)
+		s.startHooks(jobID, false, errorLog)
+	}
+}
+
+// SLAFlagged counts and announces jobs the scheduler flagged against their
+// SLA deadline; the jobs keep running
+func (s *JobService) SLAFlagged(jobs []storage.SLAJob) {
+	for _, job := range jobs {
+		s.slaFlagged.WithLabelValues(job.SLAState).Inc()
+		s.logger.Warn("Job flagged against its SLA", zap.String("job_id", job.JobID),
+			zap.String("sla_state", job.SLAState), zap.Time("sla_deadline", job.SLADeadline))
+		s.hub.PublishSystem(ws.EventJobSLA, map[string]string{
+			"job_id":       job.JobID,
+			"scheme_code":  job.SchemeCode,
+			"user_id":      job.UserID,
+			"sla_state":    job.SLAState,
+			"sla_deadline": job.SLADeadline.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// publishCreated announces a new job on the system topic
+func (s *JobService) publishCreated(jobID, schemeCode, userID string) {
+	s.hub.PublishSystem(ws.EventJobCreated, map[string]string{
+		"job_id":      jobID,
+		"scheme_code": schemeCode,
+		"user_id":     userID,
+	})
+	s.emit(JobEvent{Type: JobEventCreated, JobID: jobID, SchemeCode: schemeCode, UserID: userID, Status: "PENDING"})
+}
+
+// publishTerminal announces a job reaching a terminal status on the system
+// topic and to AwaitStart callers
+func (s *JobService) publishTerminal(jobID, status string) {
+	event := map[string]string{"job_id": jobID, "status": status}
+	if s.terminals != nil {
+		s.terminals.PublishSystem(ws.EventJobTerminal, event)
+	} else {
+		s.hub.PublishSystem(ws.EventJobTerminal, event)
+	}
+	s.notifyStarted(jobID, status)
+	s.forgetMilestones(jobID)
+	s.eventMu.Lock()
+	delete(s.eventProgress, jobID)
+	s.eventMu.Unlock()
+	s.emit(JobEvent{Type: JobEventTerminal, JobID: jobID, Status: status})
+}
+
+// algoTaskKeyNS caches algo task ID -> job ID mappings for result callbacks
+const algoTaskKeyNS = "job:algo-task:"
+
+// RecordAlgoTaskID remembers the algorithm service's ID for a job when it
+// differs from the job ID we submitted
+func (s *JobService) RecordAlgoTaskID(ctx context.Context, jobID, algoTaskID string) error {
+	if algoTaskID == "" || algoTaskID == jobID {
+		return nil
+	}
+	if err := s.store.SetAlgoTaskID(ctx, jobID, algoTaskID); err != nil {
+		return err
+	}
+	_ = s.cache.SetJSON(ctx, algoTaskKeyNS+algoTaskID, jobID, 24*time.Hour)
+	return nil
+}
+
+// resultSeenKeyNS marks result callbacks processed recently, so exact
+// duplicates are acknowledged without touching MySQL
+const (
+	resultSeenKeyNS = "job:result-seen:"
+	resultSeenTTL   = 10 * time.Minute
+)
+
+// ClaimResult marks the result callback identified by taskID and digest as
+// processed, returning false if it already was. When Redis is unavailable
+// the callback is let through and the status guard in MySQL applies.
+func (s *JobService) ClaimResult(ctx context.Context, taskID, digest string) bool {
+	claimed, err := s.cache.SetNX(ctx, resultSeenKeyNS+taskID+":"+digest, time.Now().UnixMilli(), resultSeenTTL)
+	return err != nil || claimed
+}
+
+// ReleaseResult forgets a claimed result callback so a redelivery is processed
+func (s *JobService) ReleaseResult(ctx context.Context, taskID, digest string) {
+	_ = s.cache.Delete(ctx, resultSeenKeyNS+taskID+":"+digest)
+}
+
+// RecordCheckpoint stores the checkpoint a job reported; empty refs are ignored
+func (s *JobService) RecordCheckpoint(ctx context.Context, jobID, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	return s.store.SetCheckpointRef(ctx, jobID, ref)
+}
+
+// RecordMetadata stores the caller metadata submitted with a job; empty
+// metadata is ignored
+func (s *JobService) RecordMetadata(ctx context.Context, jobID string, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return s.store.SetJobMetadata(ctx, jobID, metadata)
+}
+
+// RecordSLADeadline stores the SLA deadline submitted with a job; a nil
+// deadline is ignored
+func (s *JobService) RecordSLADeadline(ctx context.Context, jobID string, deadline *time.Time) error {
+	if deadline == nil {
+		return nil
+	}
+	return s.store.SetSLADeadline(ctx, jobID, *deadline)
+}
+
+// RecordTimeout stores when a job is failed for running past its own
+// timeout; a nil time is ignored
+func (s *JobService) RecordTimeout(ctx context.Context, jobID string, timeoutAt *time.Time) error {
+	if timeoutAt == nil {
+		return nil
+	}
+	return s.store.SetJobTimeout(ctx, jobID, *timeoutAt)
+}
+
+// ResolveJobID maps a task ID reported by the algorithm service to our job
+// ID, falling back to the task ID itself when no mapping exists
+func (s *JobService) ResolveJobID(ctx context.Context, taskID string) string {
+	var jobID string
+	if err := s.cache.GetJSON(ctx, algoTaskKeyNS+taskID, &jobID); err == nil && jobID != "" {
+		return jobID
+	}
+	if jobID, err := s.store.GetJobIDByAlgoTaskID(ctx, taskID); err == nil && jobID != "" {
+		_ = s.cache.SetJSON(ctx, algoTaskKeyNS+taskID, jobID, 24*time.Hour)
+		return jobID
+	}
+	return taskID
+}
+
+// RecordAlgoTarget remembers which algorithm service target a job was routed
+// to; the default target is not recorded
+func (s *JobService) RecordAlgoTarget(ctx context.Context, jobID, target string) error {
+	if target == "" {
+		return nil
+	}
+	return s.store.SetAlgoTarget(ctx, jobID, target)
+}
+
+// AlgoTarget returns the algorithm service target handling a job, "" for the
+// default service
+func (s *JobService) AlgoTarget(ctx context.Context, jobID string) string {
+	target, _ := s.store.GetAlgoTarget(ctx, jobID)
+	return target
+}
+
+// AlgoTaskID returns the ID to use when addressing a job on the algorithm service
+func (s *JobService) AlgoTaskID(ctx context.Context, jobID string) string {
+	if algoTaskID, err := s.store.GetAlgoTaskID(ctx, jobID); err == nil && algoTaskID != "" {
+		return algoTaskID
+	}
+	return jobID
 }
 
 func (s *JobService) UpdateProgress(ctx context.Context, msg models.ProgressMsg) error {
 	_ = s.store.UpdateProgress(ctx, msg.TaskID, int(msg.Percentage), msg.Message)
+	_ = s.RecordCheckpoint(ctx, msg.TaskID, msg.CheckpointRef)
 	key := s.progressNS + msg.TaskID
 	_ = s.cache.SetJSON(ctx, key, msg, 10*time.Minute)
 	payload, _ := json.Marshal(msg)
 	s.hub.Broadcast(msg.TaskID, payload)
+	if s.fanout != nil {
+		s.fanout.Publish(ctx, msg.TaskID, payload)
+	}
+	s.notifyStarted(msg.TaskID, "RUNNING")
+	s.notifyMilestones(ctx, msg)
+	s.emitProgress(msg)
 	return nil
 }
 
+// LatestProgress returns the last progress message cached for a job, as it
+// was broadcast, or nil if none is cached
+func (s *JobService) LatestProgress(ctx context.Context, jobID string) []byte {
+	payload, err := s.cache.GetBytes(ctx, s.progressNS+jobID)
+	if err != nil || len(payload) == 0 {
+		return nil
+	}
+	return payload
+}
+
+// AwaitStart returns a channel receiving the job's status once it reports
+// progress or reaches a terminal status, and a func to stop waiting. Call it
+// before dispatching the job so no update is missed.
+func (s *JobService) AwaitStart(jobID string) (<-chan string, func()) {
+	ch := make(chan string, 1)
+	s.startMu.Lock()
+	s.startWaiters[jobID] = append(s.startWaiters[jobID], ch)
+	s.startMu.Unlock()
+
+	return ch, func() {
+		s.startMu.Lock()
+		defer s.startMu.Unlock()
+		waiters := s.startWaiters[jobID]
+		for i, w := range waiters {
+			if w == ch {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(s.startWaiters, jobID)
+		} else {
+			s.startWaiters[jobID] = waiters
+		}
+	}
+}
+
+// notifyStarted wakes the AwaitStart callers of a job
+func (s *JobService) notifyStarted(jobID, status string) {
+	s.startMu.Lock()
+	waiters := s.startWaiters[jobID]
+	delete(s.startWaiters, jobID)
+	s.startMu.Unlock()
+	for _, ch := range waiters {
+		ch <- status
+	}
+}
+
+// FinishJob stores a successful result. Results over the size cap are not
+// stored; the job is failed and ErrResultTooLarge returned.
 func (s *JobService) FinishJob(ctx context.Context, jobID, resultJSON string) error {
-	return s.store.FinishJob(ctx, jobID, resultJSON)
+	return s.FinishJobWithLimit(ctx, jobID, resultJSON, s.maxResultBytes)
+}
+
+// FinishJobWithLimit is FinishJob with its own size cap (0 means no cap), for
+// results delivered over a channel with a limit of its own
+func (s *JobService) FinishJobWithLimit(ctx context.Context, jobID, resultJSON string, maxBytes int) error {
+	s.ReleaseJobs([]string{jobID})
+	size := len(resultJSON)
+	s.resultSize.Observe(float64(size))
+	if maxBytes > 0 && size > maxBytes {
+		tooLarge := fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrResultTooLarge, size, maxBytes)
+		if err := s.store.FailOversizedResult(ctx, jobID, size, tooLarge.Error()); err != nil {
+			return err
+		}
+		s.publishTerminal(jobID, "FAILED")
+		s.enqueueCompletion(ctx, jobID, "FAILED", nil, tooLarge.Error())
+		s.startHooks(jobID, false, tooLarge.Error())
+		return tooLarge
+	}
+	if err := s.store.FinishJob(ctx, jobID, resultJSON); err != nil {
+		return err
+	}
+	s.publishTerminal(jobID, "SUCCESS")
+	s.enqueueCompletion(ctx, jobID, "SUCCESS", resultSummary(resultJSON), "")
+	s.startHooks(jobID, true, resultJSON)
+	return nil
 }
 
 func (s *JobService) FailJob(ctx context.Context, jobID, errorLog string) error {
-	return s.store.FailJob(ctx, jobID, errorLog)
+	s.ReleaseJobs([]string{jobID})
+	if err := s.store.FailJob(ctx, jobID, errorLog); err != nil {
+		return err
+	}
+	s.publishTerminal(jobID, "FAILED")
+	s.enqueueCompletion(ctx, jobID, "FAILED", nil, errorLog)
+	s.startHooks(jobID, false, errorLog)
+	return nil
 }
 
+// CancelJob marks a job cancelled, tells its WebSocket subscribers and closes
+// their connections. A job that finished first keeps its status and nothing
+// is announced for it.
 func (s *JobService) CancelJob(ctx context.Context, jobID, message string) error {
-	return s.store.CancelJob(ctx, jobID, message)
+	cancelled, err := s.store.CancelJob(ctx, jobID, message)
+	if err != nil || !cancelled {
+		return err
+	}
+	s.ReleaseJobs([]string{jobID})
+	s.publishTerminal(jobID, "CANCELLED")
+	s.enqueueCompletion(ctx, jobID, "CANCELLED", nil, message)
+	_ = s.hub.BroadcastJSON(jobID, models.WebSocketMessage{
+		Type:      "cancelled",
+		TaskID:    jobID,
+		Payload:   map[string]string{"message": message},
+		Timestamp: time.Now().UnixMilli(),
+	})
+	s.hub.CloseJob(jobID, "job cancelled")
+	s.startHooks(jobID, false, message)
+	return nil
 }
 
 func (s *JobService) GetJob(ctx context.Context, jobID string) (map[string]any, error) {
@@ -70,6 +576,43 @@ func (s *JobService) IsFinished(ctx context.Context, jobID string) bool {
 	return status == "SUCCESS" || status == "FAILED"
 }
 
-func (s *JobService) OnJobSuccess(jobID string) {
-	// Hook for additional post-processing
+// AddHook registers a hook run whenever a job reaches a terminal status
+func (s *JobService) AddHook(hook JobHook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// startHooks runs the hooks for a job in the background, tracked so
+// WaitHooks can wait for them
+func (s *JobService) startHooks(jobID string, success bool, detail string) {
+	s.hooksRunning.Add(1)
+	go func() {
+		defer s.hooksRunning.Done()
+		s.runHooks(jobID, success, detail)
+	}()
+}
+
+// WaitHooks waits until the hooks started so far have returned, or ctx is done
+func (s *JobService) WaitHooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.hooksRunning.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *JobService) runHooks(jobID string, success bool, detail string) {
+	s.hooksMu.RLock()
+	hooks := append([]JobHook(nil), s.hooks...)
+	s.hooksMu.RUnlock()
+	for _, hook := range hooks {
+		hook(context.Background(), jobID, success, detail)
+	}
 }
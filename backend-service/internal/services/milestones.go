@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+)
+
+// EventProgressMilestone is the webhook event sent when a job's progress
+// crosses one of its milestones
+const EventProgressMilestone = "job.progress_milestone"
+
+// MilestonePayload is the body of a progress milestone webhook
+type MilestonePayload struct {
+	Event      string `json:"event"`
+	JobID      string `json:"job_id"`
+	Milestone  int    `json:"milestone"`
+	Percentage int32  `json:"percentage"`
+	Message    string `json:"message,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// SetWebhookSender enables webhook callbacks through w
+func (s *JobService) SetWebhookSender(w *WebhookSender) {
+	s.webhooks = w
+}
+
+// RecordCallback stores a job's callback URL, notified when the job finishes,
+// and the progress percentages at which it is also notified. No URL is
+// ignored; duplicate milestones are dropped.
+func (s *JobService) RecordCallback(ctx context.Context, jobID, callbackURL string, milestones []int) error {
+	if callbackURL == "" {
+		return nil
+	}
+	return s.store.SetJobCallback(ctx, jobID, callbackURL, sortedMilestones(milestones))
+}
+
+// sortedMilestones returns milestones ascending without duplicates
+func sortedMilestones(milestones []int) []int {
+	if len(milestones) == 0 {
+		return nil
+	}
+	sorted := append([]int(nil), milestones...)
+	sort.Ints(sorted)
+	unique := sorted[:1]
+	for _, m := range sorted[1:] {
+		if m != unique[len(unique)-1] {
+			unique = append(unique, m)
+		}
+	}
+	return unique
+}
+
+// notifyMilestones enqueues a callback for each milestone the job's
+// progress has newly crossed. Progress moving back and forth over a
+// milestone does not repeat it: the highest notified milestone is claimed in
+// MySQL in the transaction writing the callbacks to the outbox, so each is
+// enqueued once across instances and the callback worker retries it until
+// delivered.
+func (s *JobService) notifyMilestones(ctx context.Context, msg models.ProgressMsg) {
+	if s.webhooks == nil {
+		return
+	}
+	sub := s.milestoneSubscription(ctx, msg.TaskID)
+	if sub == nil {
+		return
+	}
+
+	s.milestoneMu.Lock()
+	var crossed []int
+	for _, m := range sub.Milestones {
+		if m > sub.Notified && m <= int(msg.Percentage) {
+			crossed = append(crossed, m)
+		}
+	}
+	if len(crossed) > 0 {
+		sub.Notified = crossed[len(crossed)-1]
+	}
+	s.milestoneMu.Unlock()
+	if len(crossed) == 0 {
+		return
+	}
+
+	callbacks := make([]storage.MilestoneCallback, 0, len(crossed))
+	for _, m := range crossed {
+		body, err := json.Marshal(MilestonePayload{
+			Event:      EventProgressMilestone,
+			JobID:      msg.TaskID,
+			Milestone:  m,
+			Percentage: msg.Percentage,
+			Message:    msg.Message,
+			Timestamp:  time.Now().UnixMilli(),
+		})
+		if err != nil {
+			s.logger.Error("Milestone webhook payload does not encode", zap.String("job_id", msg.TaskID), zap.Error(err))
+			return
+		}
+		callbacks = append(callbacks, storage.MilestoneCallback{Milestone: m, DeliveryID: uuid.NewString(), Payload: body})
+	}
+	if _, err := s.store.ClaimMilestone(ctx, msg.TaskID, crossed[len(crossed)-1], EventProgressMilestone, callbacks); err != nil {
+		// Nothing was claimed; reload the subscription so the next update retries
+		s.logger.Warn("Failed to enqueue progress milestones", zap.String("job_id", msg.TaskID), zap.Error(err))
+		s.forgetMilestones(msg.TaskID)
+	}
+}
+
+// milestoneSubscription returns the job's subscription, loading it once per
+// job; nil when it has none
+func (s *JobService) milestoneSubscription(ctx context.Context, jobID string) *storage.MilestoneSubscription {
+	s.milestoneMu.Lock()
+	sub, ok := s.milestoneSubs[jobID]
+	s.milestoneMu.Unlock()
+	if ok {
+		return sub
+	}
+
+	sub, err := s.store.GetMilestoneSubscription(ctx, jobID)
+	if err != nil {
+		s.logger.Warn("Failed to load progress milestones", zap.String("job_id", jobID), zap.Error(err))
+		return nil
+	}
+	s.milestoneMu.Lock()
+	defer s.milestoneMu.Unlock()
+	if cached, ok := s.milestoneSubs[jobID]; ok {
+		return cached
+	}
+	s.milestoneSubs[jobID] = sub
+	return sub
+}
+
+// forgetMilestones drops a finished job's cached subscription
+func (s *JobService) forgetMilestones(jobID string) {
+	s.milestoneMu.Lock()
+	delete(s.milestoneSubs, jobID)
+	s.milestoneMu.Unlock()
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+)
+
+// milestoneBody matches an outbox payload holding milestone m
+type milestoneBody struct {
+	jobID     string
+	milestone int
+}
+
+func (b milestoneBody) Match(v driver.Value) bool {
+	raw, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var got MilestonePayload
+	if json.Unmarshal([]byte(raw), &got) != nil {
+		return false
+	}
+	return got.Event == EventProgressMilestone && got.JobID == b.jobID && got.Milestone == b.milestone
+}
+
+// newMilestoneService returns a job service on a mocked MySQL with webhooks
+// enabled
+func newMilestoneService(t *testing.T) (*JobService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	mr := miniredis.RunT(t)
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+	hub := ws.NewHubWithConfig(ws.HubConfig{}, nil)
+	t.Cleanup(hub.Close)
+
+	sender := NewWebhookSender(DefaultWebhookConfig(), nil)
+	t.Cleanup(func() { _ = sender.Close(context.Background()) })
+
+	s := NewJobService(storage.NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql")), cache, hub, "schemes", "progress:")
+	s.SetWebhookSender(sender)
+	return s, mock
+}
+
+func expectProgressUpdate(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`UPDATE t_algo_jobs\s+SET progress_updated_at`).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectSubscription(mock sqlmock.Sqlmock, url string, notified int) {
+	mock.ExpectQuery(`SELECT COALESCE\(callback_url, ''\) AS callback_url`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"callback_url", "progress_milestones", "milestone_notified"}).
+			AddRow(url, "[25,50,75]", notified))
+}
+
+// expectClaim expects milestone to be claimed and, when claimed, a callback
+// enqueued for each of enqueued in the same transaction
+func expectClaim(mock sqlmock.Sqlmock, milestone int, claimed bool, enqueued ...int) {
+	mock.ExpectBegin()
+	var affected int64
+	if claimed {
+		affected = 1
+	}
+	mock.ExpectExec(`UPDATE t_algo_jobs SET milestone_notified = \? WHERE job_id = \? AND milestone_notified < \?`).
+		WithArgs(milestone, "job-1", milestone).
+		WillReturnResult(sqlmock.NewResult(0, affected))
+	if !claimed {
+		mock.ExpectRollback()
+		return
+	}
+	for _, m := range enqueued {
+		mock.ExpectExec(`INSERT IGNORE INTO t_job_callbacks .* FROM t_algo_jobs WHERE job_id = \? AND callback_url IS NOT NULL`).
+			WithArgs(sqlmock.AnyArg(), EventProgressMilestone, m, milestoneBody{"job-1", m},
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestMilestonesEnqueuedOncePerCrossing(t *testing.T) {
+	s, mock := newMilestoneService(t)
+
+	// Progress jitters around each milestone; each is claimed and enqueued once
+	claims := map[int32]int{26: 25, 51: 50, 90: 75}
+	for i, pct := range []int32{10, 24, 26, 25, 24, 27, 49, 51, 50, 49, 90, 80, 100} {
+		expectProgressUpdate(mock)
+		if i == 0 {
+			expectSubscription(mock, "https://example.com/hook", 0)
+		}
+		if m, ok := claims[pct]; ok {
+			expectClaim(mock, m, true, m)
+		}
+		require.NoError(t, s.UpdateProgress(context.Background(), models.ProgressMsg{TaskID: "job-1", Percentage: pct}))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMilestonesJumpAndClaimedElsewhere(t *testing.T) {
+	s, mock := newMilestoneService(t)
+	url := "https://example.com/hook"
+
+	// 25 was notified before; a jump to 80 enqueues 50 and 75 with one claim
+	expectProgressUpdate(mock)
+	expectSubscription(mock, url, 25)
+	expectClaim(mock, 75, true, 50, 75)
+	require.NoError(t, s.UpdateProgress(context.Background(), models.ProgressMsg{TaskID: "job-1", Percentage: 80}))
+
+	// When another instance has already claimed a milestone, nothing is enqueued here
+	s.forgetMilestones("job-1")
+	expectProgressUpdate(mock)
+	mock.ExpectQuery(`SELECT COALESCE\(callback_url, ''\) AS callback_url`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"callback_url", "progress_milestones", "milestone_notified"}).
+			AddRow(url, "[25,50,75,100]", 75))
+	expectClaim(mock, 100, false)
+	require.NoError(t, s.UpdateProgress(context.Background(), models.ProgressMsg{TaskID: "job-1", Percentage: 100}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestMilestoneRetriedAfterFailedClaim tests that a claim failing in MySQL
+// loses no milestone: the subscription is reloaded and the next update
+// claims it again
+func TestMilestoneRetriedAfterFailedClaim(t *testing.T) {
+	s, mock := newMilestoneService(t)
+	url := "https://example.com/hook"
+
+	expectProgressUpdate(mock)
+	expectSubscription(mock, url, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE t_algo_jobs SET milestone_notified`).WillReturnError(errors.New("deadlock"))
+	mock.ExpectRollback()
+	require.NoError(t, s.UpdateProgress(context.Background(), models.ProgressMsg{TaskID: "job-1", Percentage: 30}))
+
+	expectProgressUpdate(mock)
+	expectSubscription(mock, url, 0)
+	expectClaim(mock, 25, true, 25)
+	require.NoError(t, s.UpdateProgress(context.Background(), models.ProgressMsg{TaskID: "job-1", Percentage: 31}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
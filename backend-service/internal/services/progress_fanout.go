@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+)
+
+// progressChannelPrefix prefixes the Redis pub/sub channel of each job
+const progressChannelPrefix = "job:progress:"
+
+// progressEnvelope is a progress message on a job's channel; origin is the
+// instance that published it
+type progressEnvelope struct {
+	Origin  string          `json:"origin"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ProgressFanout relays job progress between backend replicas over Redis
+// pub/sub, so WebSocket clients connected to one instance receive progress
+// for jobs watched by another. Each instance only subscribes to the channels
+// of jobs it has local clients for, and drops the messages it published itself.
+type ProgressFanout struct {
+	cache      *storage.RedisCache
+	hub        *ws.Hub
+	instanceID string
+	logger     *zap.Logger
+
+	mu     sync.Mutex
+	subs   map[string]context.CancelFunc
+	closed bool
+}
+
+// NewProgressFanout creates a fanout for hub and registers it as the hub's
+// topic observer
+func NewProgressFanout(cache *storage.RedisCache, hub *ws.Hub, logger *zap.Logger) *ProgressFanout {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	f := &ProgressFanout{
+		cache:      cache,
+		hub:        hub,
+		instanceID: uuid.NewString(),
+		logger:     logger,
+		subs:       make(map[string]context.CancelFunc),
+	}
+	hub.SetTopicObserver(f.TopicChanged)
+	return f
+}
+
+// Publish sends a job's progress payload to the other instances
+func (f *ProgressFanout) Publish(ctx context.Context, jobID string, payload []byte) {
+	err := f.cache.Publish(ctx, progressChannelPrefix+jobID, progressEnvelope{Origin: f.instanceID, Payload: payload})
+	if err != nil {
+		f.logger.Debug("Progress fan-out publish failed", zap.String("job_id", jobID), zap.Error(err))
+	}
+}
+
+// TopicChanged subscribes to or unsubscribes from a job's channel to match
+// whether the hub still has clients for it. The hub's current state is
+// consulted rather than active, so notifications arriving out of order
+// still settle on the right subscription.
+func (f *ProgressFanout) TopicChanged(topic string, _ bool) {
+	if topic == ws.SystemTopic {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+
+	cancel, subscribed := f.subs[topic]
+	wanted := f.hub.GetClientCount(topic) > 0
+	switch {
+	case wanted && !subscribed:
+		ctx, cancel := context.WithCancel(context.Background())
+		f.subs[topic] = cancel
+		go f.relay(ctx, topic)
+	case !wanted && subscribed:
+		cancel()
+		delete(f.subs, topic)
+	}
+}
+
+// relay broadcasts the messages other instances publish for jobID to the
+// local clients until ctx is cancelled
+func (f *ProgressFanout) relay(ctx context.Context, jobID string) {
+	msgs, unsubscribe := f.cache.Subscribe(ctx, progressChannelPrefix+jobID)
+	defer unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var env progressEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				f.logger.Warn("Dropping malformed progress fan-out message", zap.String("job_id", jobID), zap.Error(err))
+				continue
+			}
+			if env.Origin == f.instanceID {
+				continue
+			}
+			f.hub.Broadcast(jobID, env.Payload)
+		}
+	}
+}
+
+// Subscriptions returns the number of job channels currently subscribed to
+func (f *ProgressFanout) Subscriptions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+// Close drops every subscription
+func (f *ProgressFanout) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for topic, cancel := range f.subs {
+		cancel()
+		delete(f.subs, topic)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/storage"
+	"github.com/electric-power/backend-service/internal/ws"
+)
+
+// fanoutInstance is one replica: a hub and its fanout sharing Redis with the others
+type fanoutInstance struct {
+	hub    *ws.Hub
+	fanout *ProgressFanout
+}
+
+func newFanoutInstance(t *testing.T, mr *miniredis.Miniredis) *fanoutInstance {
+	t.Helper()
+	cache := storage.NewRedisCache(mr.Addr(), "", 0)
+	hub := ws.NewHubWithConfig(ws.HubConfig{}, nil)
+	fanout := NewProgressFanout(cache, hub, nil)
+	t.Cleanup(func() {
+		fanout.Close()
+		hub.Close()
+		_ = cache.Close()
+	})
+	return &fanoutInstance{hub: hub, fanout: fanout}
+}
+
+// connect subscribes a WebSocket client to jobID on the instance's hub
+func (i *fanoutInstance) connect(t *testing.T, jobID string) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = i.hub.Subscribe(jobID, conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.Eventually(t, func() bool { return i.hub.GetClientCount(jobID) == 1 }, time.Second, 5*time.Millisecond)
+	return conn
+}
+
+// awaitSubscribed waits until the instance is subscribed to n job channels in Redis
+func awaitSubscribed(t *testing.T, mr *miniredis.Miniredis, channel string, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return mr.PubSubNumSub(channel)[channel] == n }, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestProgressFanoutRelaysBetweenInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a, b := newFanoutInstance(t, mr), newFanoutInstance(t, mr)
+
+	// Only the instance with a client subscribes to the job's channel
+	conn := b.connect(t, "job-1")
+	awaitSubscribed(t, mr, "job:progress:job-1", 1)
+	assert.Equal(t, 0, a.fanout.Subscriptions())
+	assert.Equal(t, 1, b.fanout.Subscriptions())
+
+	// Progress published on A reaches the client on B, exactly once
+	a.fanout.Publish(context.Background(), "job-1", []byte(`{"task_id":"job-1","percentage":40}`))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"task_id":"job-1","percentage":40}`, string(data))
+
+	// B's own publication is not rebroadcast on top of its local broadcast
+	b.fanout.Publish(context.Background(), "job-1", []byte(`{"task_id":"job-1","percentage":50}`))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err, "an instance must drop the messages it published")
+}
+
+func TestProgressFanoutUnsubscribesWhenClientsLeave(t *testing.T) {
+	mr := miniredis.RunT(t)
+	inst := newFanoutInstance(t, mr)
+
+	inst.connect(t, "job-1")
+	awaitSubscribed(t, mr, "job:progress:job-1", 1)
+
+	inst.hub.CloseJob("job-1", "job finished")
+	awaitSubscribed(t, mr, "job:progress:job-1", 0)
+	assert.Equal(t, 0, inst.fanout.Subscriptions())
+
+	// System dashboards never cause a subscription
+	inst.connect(t, ws.SystemTopic)
+	assert.Equal(t, 0, inst.fanout.Subscriptions())
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// SchemeFilter hides and rejects scheme codes based on glob allow/deny lists.
+// An empty allowlist allows everything; the denylist always wins.
+// A nil *SchemeFilter allows every scheme.
+type SchemeFilter struct {
+	allow []string
+	deny  []string
+}
+
+// NewSchemeFilter validates the glob patterns (path.Match syntax, case-insensitive)
+func NewSchemeFilter(allow, deny []string) (*SchemeFilter, error) {
+	f := &SchemeFilter{}
+	for _, p := range allow {
+		if err := addPattern(&f.allow, p); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range deny {
+		if err := addPattern(&f.deny, p); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func addPattern(dst *[]string, pattern string) error {
+	pattern = strings.ToUpper(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid scheme pattern %q: %w", pattern, err)
+	}
+	*dst = append(*dst, pattern)
+	return nil
+}
+
+// Allowed reports whether a scheme code may be listed and submitted
+func (f *SchemeFilter) Allowed(code string) bool {
+	if f == nil {
+		return true
+	}
+	code = strings.ToUpper(code)
+	if matchAny(f.deny, code) {
+		return false
+	}
+	return len(f.allow) == 0 || matchAny(f.allow, code)
+}
+
+// Filter returns only the allowed schemes
+func (f *SchemeFilter) Filter(schemes []models.Scheme) []models.Scheme {
+	if f == nil {
+		return schemes
+	}
+	out := make([]models.Scheme, 0, len(schemes))
+	for _, s := range schemes {
+		if f.Allowed(s.Code) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func matchAny(patterns []string, code string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, code); ok {
+			return true
+		}
+	}
+	return false
+}
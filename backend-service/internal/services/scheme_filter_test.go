@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+func TestSchemeFilterAllowed(t *testing.T) {
+	f, err := NewSchemeFilter([]string{"kbm-*", "SCM-WF0?"}, []string{"KBM-WF03"})
+	assert.NoError(t, err)
+
+	assert.True(t, f.Allowed("KBM-WF01"))
+	assert.True(t, f.Allowed("scm-wf02"))
+	assert.False(t, f.Allowed("KBM-WF03"), "denylist wins over allowlist")
+	assert.False(t, f.Allowed("STM-WF01"), "not in allowlist")
+	assert.False(t, f.Allowed("SCM-WF10"))
+}
+
+func TestSchemeFilterDenyOnlyAndNil(t *testing.T) {
+	f, err := NewSchemeFilter(nil, []string{"*-EXP*"})
+	assert.NoError(t, err)
+
+	out := f.Filter([]models.Scheme{{Code: "KBM-WF01"}, {Code: "KBM-EXP01"}})
+	assert.Len(t, out, 1)
+	assert.Equal(t, "KBM-WF01", out[0].Code)
+
+	var none *SchemeFilter
+	assert.True(t, none.Allowed("ANYTHING"))
+}
+
+func TestSchemeFilterInvalidPattern(t *testing.T) {
+	_, err := NewSchemeFilter([]string{"KBM-[WF"}, nil)
+	assert.Error(t, err)
+}
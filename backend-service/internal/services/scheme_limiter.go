@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSchemeAtCapacity is returned when a scheme already has its maximum
+// number of jobs in flight
+var ErrSchemeAtCapacity = errors.New("scheme concurrency limit reached")
+
+// SchemeLimiter caps the number of in-flight jobs per scheme code. Slots are
+// keyed by job ID so releasing a job more than once is harmless. Counts are
+// kept in memory and start from zero on restart.
+type SchemeLimiter struct {
+	mu       sync.Mutex
+	limits   map[string]int
+	inflight map[string]string // job ID -> scheme code
+	counts   map[string]int
+}
+
+// NewSchemeLimiter creates a limiter; schemes without a positive limit are unlimited
+func NewSchemeLimiter(limits map[string]int) *SchemeLimiter {
+	return &SchemeLimiter{
+		limits:   limits,
+		inflight: make(map[string]string),
+		counts:   make(map[string]int),
+	}
+}
+
+// Acquire takes a slot for jobID, returning false when the scheme is at its limit
+func (l *SchemeLimiter) Acquire(schemeCode, jobID string) bool {
+	return l.take(schemeCode, jobID, true)
+}
+
+// Track takes a slot for jobID even if the scheme is at its limit, so the
+// job still counts against later Acquire calls
+func (l *SchemeLimiter) Track(schemeCode, jobID string) {
+	l.take(schemeCode, jobID, false)
+}
+
+func (l *SchemeLimiter) take(schemeCode, jobID string, enforce bool) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.inflight[jobID]; ok {
+		return true
+	}
+	if limit := l.limits[schemeCode]; enforce && limit > 0 && l.counts[schemeCode] >= limit {
+		return false
+	}
+	l.inflight[jobID] = schemeCode
+	l.counts[schemeCode]++
+	return true
+}
+
+// Release frees the slots held by the given jobs
+func (l *SchemeLimiter) Release(jobIDs ...string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, jobID := range jobIDs {
+		schemeCode, ok := l.inflight[jobID]
+		if !ok {
+			continue
+		}
+		delete(l.inflight, jobID)
+		if l.counts[schemeCode]--; l.counts[schemeCode] <= 0 {
+			delete(l.counts, schemeCode)
+		}
+	}
+}
+
+// InFlight returns the number of in-flight jobs for a scheme
+func (l *SchemeLimiter) InFlight(schemeCode string) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[schemeCode]
+}
+
+// Limit returns the configured limit for a scheme, 0 when unlimited
+func (l *SchemeLimiter) Limit(schemeCode string) int {
+	if l == nil {
+		return 0
+	}
+	return l.limits[schemeCode]
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemeLimiterCapsOnlyConfiguredSchemes(t *testing.T) {
+	l := NewSchemeLimiter(map[string]int{"KBM-WF03": 2})
+
+	assert.True(t, l.Acquire("KBM-WF03", "a"))
+	assert.True(t, l.Acquire("KBM-WF03", "b"))
+	assert.False(t, l.Acquire("KBM-WF03", "c"))
+	assert.Equal(t, 2, l.InFlight("KBM-WF03"))
+
+	// Other schemes are unaffected
+	for _, id := range []string{"x", "y", "z"} {
+		assert.True(t, l.Acquire("KBM-WF01", id))
+	}
+
+	// Releasing twice frees only one slot
+	l.Release("a")
+	l.Release("a")
+	assert.Equal(t, 1, l.InFlight("KBM-WF03"))
+	assert.True(t, l.Acquire("KBM-WF03", "c"))
+	assert.False(t, l.Acquire("KBM-WF03", "d"))
+}
+
+func TestSchemeLimiterTrackCountsWithoutRejecting(t *testing.T) {
+	l := NewSchemeLimiter(map[string]int{"KBM-WF03": 1})
+
+	assert.True(t, l.Acquire("KBM-WF03", "a"))
+	l.Track("KBM-WF03", "b")
+	assert.Equal(t, 2, l.InFlight("KBM-WF03"))
+
+	l.Release("a")
+	assert.False(t, l.Acquire("KBM-WF03", "c"), "tracked job still holds a slot")
+	l.Release("b")
+	assert.True(t, l.Acquire("KBM-WF03", "c"))
+}
+
+func TestNilSchemeLimiterIsUnlimited(t *testing.T) {
+	var l *SchemeLimiter
+	assert.True(t, l.Acquire("KBM-WF03", "a"))
+	l.Release("a")
+	assert.Equal(t, 0, l.InFlight("KBM-WF03"))
+}
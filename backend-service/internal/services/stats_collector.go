@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsCollector computes the job aggregates shared by the JSON stats endpoint
+// and the Prometheus /metrics endpoint. Results are cached for a short TTL so a
+// dashboard poll and a Prometheus scrape landing together cost one query.
+type StatsCollector struct {
+	store *storage.MySQLStore
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	cached    map[string]any
+	fetchedAt time.Time
+
+	statusDesc   *prometheus.Desc
+	durationDesc *prometheus.Desc
+}
+
+// NewStatsCollector creates a collector caching results for ttl
+func NewStatsCollector(store *storage.MySQLStore, ttl time.Duration) *StatsCollector {
+	return &StatsCollector{
+		store: store,
+		ttl:   ttl,
+		statusDesc: prometheus.NewDesc(
+			"algo_jobs",
+			"Number of jobs by status",
+			[]string{"status"}, nil,
+		),
+		durationDesc: prometheus.NewDesc(
+			"algo_job_avg_duration_seconds",
+			"Average duration of successfully completed jobs",
+			nil, nil,
+		),
+	}
+}
+
+// Stats returns the unfiltered job aggregates, served from cache when fresh
+func (s *StatsCollector) Stats(ctx context.Context) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.fetchedAt) < s.ttl {
+		return s.cached, nil
+	}
+
+	stats, err := s.store.GetStats(ctx, storage.JobFilter{})
+	if err != nil {
+		return nil, err
+	}
+	s.cached = stats
+	s.fetchedAt = time.Now()
+	return stats, nil
+}
+
+// Describe implements prometheus.Collector
+func (s *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.statusDesc
+	ch <- s.durationDesc
+}
+
+// Collect implements prometheus.Collector
+func (s *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stats, err := s.Stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(s.statusDesc, err)
+		return
+	}
+
+	if counts, ok := stats["status_counts"].(map[string]int); ok {
+		for status, count := range counts {
+			ch <- prometheus.MustNewConstMetric(s.statusDesc, prometheus.GaugeValue, float64(count), status)
+		}
+	}
+	if avg, ok := stats["avg_duration_seconds"].(float64); ok {
+		ch <- prometheus.MustNewConstMetric(s.durationDesc, prometheus.GaugeValue, avg)
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Webhook request headers
+const (
+	WebhookSignatureHeader = "X-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	// WebhookDeliveryHeader is the same on every attempt of a delivery, so
+	// receivers can drop retries they already processed
+	WebhookDeliveryHeader = "X-Webhook-Delivery"
+)
+
+// WebhookConfig configures webhook delivery
+type WebhookConfig struct {
+	// Secret signs bodies with HMAC-SHA256 in the X-Signature header; empty
+	// sends them unsigned
+	Secret  string
+	Timeout time.Duration
+	// MaxAttempts bounds the attempts per delivery, retried with exponential
+	// backoff between InitialBackoff and MaxBackoff
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// AllowPrivateTargets lets callbacks reach loopback, private and
+	// link-local addresses, which are otherwise refused so a caller-supplied
+	// URL cannot probe the internal network
+	AllowPrivateTargets bool
+}
+
+// ErrPrivateWebhookTarget is returned by Deliver when the callback URL
+// resolves to an address that is not publicly routable
+var ErrPrivateWebhookTarget = errors.New("webhook target is not a public address")
+
+// DefaultWebhookConfig returns the default delivery settings
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Timeout:        10 * time.Second,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+}
+
+// WebhookSender POSTs signed JSON events to caller-supplied URLs
+type WebhookSender struct {
+	cfg    WebhookConfig
+	client *http.Client
+	logger *zap.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWebhookSender creates a sender with cfg
+func NewWebhookSender(cfg WebhookConfig, logger *zap.Logger) *WebhookSender {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateTargets {
+		// The check runs on the resolved address, so DNS cannot point a
+		// public-looking host at an internal one
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refusePrivateTargets}
+		transport.DialContext = dialer.DialContext
+		client.Transport = transport
+	}
+	return &WebhookSender{
+		cfg:    cfg,
+		client: client,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// refusePrivateTargets is a dialer control refusing connections to
+// addresses that are not publicly routable
+func refusePrivateTargets(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrPrivateWebhookTarget, host)
+	}
+	return nil
+}
+
+// SignWebhook returns the X-Signature value of body: "sha256=" followed by
+// the hex HMAC-SHA256 of body under secret
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver makes one attempt to POST body to url; a non-2xx response is an error
+func (w *WebhookSender) Deliver(ctx context.Context, url, event, deliveryID string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	if w.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.cfg.Secret, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Send delivers payload as JSON in the background, retrying failed attempts
+// with exponential backoff up to MaxAttempts
+func (w *WebhookSender) Send(url, event string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		w.logger.Error("Webhook payload does not encode", zap.String("event", event), zap.Error(err))
+		return
+	}
+	deliveryID := uuid.NewString()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = w.cfg.InitialBackoff
+		b.MaxInterval = w.cfg.MaxBackoff
+		b.MaxElapsedTime = 0
+		attempt := 0
+		err := backoff.Retry(func() error {
+			attempt++
+			err := w.Deliver(w.ctx, url, event, deliveryID, body)
+			if err != nil {
+				w.logger.Warn("Webhook delivery failed", zap.String("event", event),
+					zap.String("delivery_id", deliveryID), zap.Int("attempt", attempt), zap.Error(err))
+			}
+			return err
+		}, backoff.WithContext(backoff.WithMaxRetries(b, uint64(w.cfg.MaxAttempts-1)), w.ctx))
+		if err != nil {
+			w.logger.Error("Webhook delivery gave up", zap.String("event", event),
+				zap.String("delivery_id", deliveryID), zap.Int("attempts", attempt), zap.Error(err))
+		}
+	}()
+}
+
+// retryDelay is the backoff before the attempt following attempt (1-based):
+// InitialBackoff doubled per attempt, capped at MaxBackoff
+func (w *WebhookSender) retryDelay(attempt int) time.Duration {
+	delay := w.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, w.cfg.MaxBackoff)
+}
+
+// Close waits for deliveries in progress until ctx is done, then abandons
+// the remaining retries
+func (w *WebhookSender) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
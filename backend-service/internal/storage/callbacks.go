@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// Job callback delivery statuses
+const (
+	CallbackPending   = "PENDING"
+	CallbackDelivered = "DELIVERED"
+	CallbackFailed    = "FAILED"
+)
+
+const jobCallbackColumns = `id, delivery_id, job_id, event, url, payload, status, attempts,
+       COALESCE(last_error, '') AS last_error, next_attempt_at, delivered_at, created_at`
+
+// EnqueueJobCallback adds a delivery of event to the outbox, addressed to
+// the job's callback URL. It does nothing, returning false, when the job has
+// no callback URL or the event was already enqueued for the job.
+func (s *MySQLStore) EnqueueJobCallback(ctx context.Context, jobID, event, deliveryID string, payload []byte) (bool, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, `
+INSERT IGNORE INTO t_job_callbacks (delivery_id, job_id, event, url, payload, status, attempts, next_attempt_at, created_at, updated_at)
+SELECT ?, job_id, ?, callback_url, ?, 'PENDING', 0, ?, ?, ?
+FROM t_algo_jobs WHERE job_id = ? AND callback_url IS NOT NULL AND callback_url <> ''`,
+		deliveryID, event, string(payload), now, now, now, jobID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DueJobCallbacks returns up to limit pending deliveries whose next attempt
+// is due at now, oldest first
+func (s *MySQLStore) DueJobCallbacks(ctx context.Context, now time.Time, limit int) ([]models.JobCallback, error) {
+	var callbacks []models.JobCallback
+	err := s.db.SelectContext(ctx, &callbacks, `
+SELECT `+jobCallbackColumns+` FROM t_job_callbacks
+WHERE status = 'PENDING' AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`, now, limit)
+	return callbacks, err
+}
+
+// ClaimJobCallback takes a due delivery for one attempt by pushing its next
+// attempt to leaseUntil, returning false when another instance claimed it
+// first. Should the attempt never be recorded, the delivery is due again
+// once the lease expires.
+func (s *MySQLStore) ClaimJobCallback(ctx context.Context, cb models.JobCallback, now, leaseUntil time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+UPDATE t_job_callbacks SET next_attempt_at = ?, updated_at = ?
+WHERE id = ? AND status = 'PENDING' AND attempts = ? AND next_attempt_at <= ?`,
+		leaseUntil, now, cb.ID, cb.Attempts, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RecordJobCallbackAttempt stores the outcome of an attempt: the delivery's
+// new status, attempt count, error (empty on success) and, while it is still
+// pending, when to try again
+func (s *MySQLStore) RecordJobCallbackAttempt(ctx context.Context, id int64, status string, attempts int, lastError string, nextAttemptAt time.Time) error {
+	now := time.Now()
+	var deliveredAt, errArg any
+	if status == CallbackDelivered {
+		deliveredAt = now
+	}
+	if lastError != "" {
+		errArg = lastError
+	}
+	_, err := s.db.ExecContext(ctx, `
+UPDATE t_job_callbacks SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?, updated_at = ?
+WHERE id = ?`, status, attempts, errArg, nextAttemptAt, deliveredAt, now, id)
+	return err
+}
+
+// ListJobCallbacks returns the deliveries of a job's callbacks, oldest first
+func (s *MySQLStore) ListJobCallbacks(ctx context.Context, jobID string) ([]models.JobCallback, error) {
+	callbacks := []models.JobCallback{}
+	err := s.selectRead(ctx, &callbacks, `
+SELECT `+jobCallbackColumns+` FROM t_job_callbacks WHERE job_id = ? ORDER BY id`, jobID)
+	return callbacks, err
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// DataStore persists job input data and returns a data_ref the algorithm
+// service can resolve. Put reads size bytes of data from r.
+type DataStore interface {
+	Put(ctx context.Context, name string, r io.Reader, size int64) (string, error)
+}
+
+// LocalDataStore writes data files under a directory shared with the
+// algorithm service; data_refs are file:// URLs
+type LocalDataStore struct {
+	dir string
+}
+
+// NewLocalDataStore creates the directory if needed
+func NewLocalDataStore(dir string) (*LocalDataStore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+	return &LocalDataStore{dir: abs}, nil
+}
+
+// Put writes data to name inside the store directory. The name's extension
+// tells the algorithm service how to parse the file.
+func (s *LocalDataStore) Put(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid data file name %q", name)
+	}
+
+	path := filepath.Join(s.dir, name)
+	// Write to a temp file first so a reader never sees a partial file
+	tmp, err := os.CreateTemp(s.dir, "."+name+".*")
+	if err != nil {
+		return "", err
+	}
+	if n, err := io.Copy(tmp, r); err != nil || n != size {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		if err == nil {
+			err = fmt.Errorf("data file %q: read %d of %d bytes", name, n, size)
+		}
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(), nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalDataStorePut(t *testing.T) {
+	store, err := NewLocalDataStore(t.TempDir())
+	require.NoError(t, err)
+
+	ref, err := store.Put(context.Background(), "sample.json", strings.NewReader(`[{"a":1}]`), 9)
+	require.NoError(t, err)
+
+	u, err := url.Parse(ref)
+	require.NoError(t, err)
+	assert.Equal(t, "file", u.Scheme)
+	data, err := os.ReadFile(u.Path)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"a":1}]`, string(data))
+
+	_, err = store.Put(context.Background(), "../escape.csv", strings.NewReader("x"), 1)
+	assert.Error(t, err)
+}
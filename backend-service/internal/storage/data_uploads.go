@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+const dataUploadColumns = `data_ref, filename, content_type, size, checksum, COALESCE(uploaded_by, '') AS uploaded_by, uploaded_at`
+
+// InsertDataUpload records an uploaded data file. When the same uploader
+// recorded a file with the same checksum first, nothing is written and the
+// existing upload is returned instead, so an uploader's identical files
+// share one data_ref.
+func (s *MySQLStore) InsertDataUpload(ctx context.Context, meta models.DataUploadMeta) (models.DataUploadMeta, bool, error) {
+	var uploadedBy any
+	if meta.UploadedBy != "" {
+		uploadedBy = meta.UploadedBy
+	}
+	res, err := s.db.ExecContext(ctx, `
+INSERT IGNORE INTO t_data_uploads (data_ref, filename, content_type, size, checksum, uploaded_by, uploaded_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		meta.DataRef, meta.Filename, meta.ContentType, meta.Size, meta.Checksum, uploadedBy, meta.UploadedAt)
+	if err != nil {
+		return models.DataUploadMeta{}, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return meta, n > 0, err
+	}
+	existing, err := s.GetDataUploadByChecksum(ctx, meta.Checksum, meta.UploadedBy)
+	if err != nil {
+		return models.DataUploadMeta{}, false, err
+	}
+	if existing == nil {
+		return models.DataUploadMeta{}, false, errors.New("data upload conflicts with an existing data_ref")
+	}
+	return *existing, false, nil
+}
+
+// GetDataUploadByChecksum returns uploadedBy's upload of a file ("" for
+// anonymous uploads), nil when they recorded none
+func (s *MySQLStore) GetDataUploadByChecksum(ctx context.Context, checksum, uploadedBy string) (*models.DataUploadMeta, error) {
+	return s.getDataUpload(ctx, `SELECT `+dataUploadColumns+` FROM t_data_uploads WHERE checksum = ? AND COALESCE(uploaded_by, '') = ?`,
+		checksum, uploadedBy)
+}
+
+// GetDataUpload returns the upload behind a data_ref, nil when there is none
+func (s *MySQLStore) GetDataUpload(ctx context.Context, dataRef string) (*models.DataUploadMeta, error) {
+	return s.getDataUpload(ctx, `SELECT `+dataUploadColumns+` FROM t_data_uploads WHERE data_ref = ?`, dataRef)
+}
+
+func (s *MySQLStore) getDataUpload(ctx context.Context, query string, args ...any) (*models.DataUploadMeta, error) {
+	var meta models.DataUploadMeta
+	if err := s.getRead(ctx, &meta, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &meta, nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// DurationBucketBounds are the upper bounds, in seconds, of the duration
+// histogram buckets; a final bucket catches everything longer
+var DurationBucketBounds = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600, 7200, 14400}
+
+// DurationBucket counts the jobs that took at most LeSeconds and longer than
+// the previous bucket's bound; LeSeconds is nil for the overflow bucket
+type DurationBucket struct {
+	LeSeconds *float64 `json:"le_seconds"`
+	Count     int      `json:"count"`
+}
+
+// DurationHistogram is the distribution of how long successful jobs took
+type DurationHistogram struct {
+	Count   int              `json:"count"`
+	Buckets []DurationBucket `json:"buckets"`
+	P50     float64          `json:"p50_seconds"`
+	P95     float64          `json:"p95_seconds"`
+	P99     float64          `json:"p99_seconds"`
+	// Truncated is set when only the most recent jobs were included
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// NewDurationHistogram buckets durations (in seconds) by DurationBucketBounds
+// and computes nearest-rank percentiles
+func NewDurationHistogram(durations []float64) *DurationHistogram {
+	sorted := append([]float64(nil), durations...)
+	sort.Float64s(sorted)
+
+	hist := &DurationHistogram{Count: len(sorted), Buckets: make([]DurationBucket, len(DurationBucketBounds)+1)}
+	for i := range DurationBucketBounds {
+		hist.Buckets[i].LeSeconds = &DurationBucketBounds[i]
+	}
+	for _, d := range sorted {
+		hist.Buckets[sort.SearchFloat64s(DurationBucketBounds, d)].Count++
+	}
+	hist.P50 = percentile(sorted, 50)
+	hist.P95 = percentile(sorted, 95)
+	hist.P99 = percentile(sorted, 99)
+	return hist
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// GetDurationHistogram computes the duration distribution of successful jobs
+// matching the filter, optionally of one scheme. At most maxRows of the most
+// recently finished jobs are read; 0 reads them all.
+func (s *MySQLStore) GetDurationHistogram(ctx context.Context, filter JobFilter, schemeCode string, maxRows int) (*DurationHistogram, error) {
+	where, args := filter.whereClause()
+	if schemeCode != "" {
+		where += " AND scheme_code = ?"
+		args = append(args, schemeCode)
+	}
+	query := `
+SELECT TIMESTAMPDIFF(SECOND, created_at, finished_at) FROM t_algo_jobs ` + where + ` AND status = 'SUCCESS' AND finished_at IS NOT NULL
+ORDER BY finished_at DESC`
+	if maxRows > 0 {
+		query += " LIMIT ?"
+		args = append(args, maxRows)
+	}
+
+	var durations []float64
+	if err := s.selectRead(ctx, &durations, query, args...); err != nil {
+		return nil, err
+	}
+	hist := NewDurationHistogram(durations)
+	hist.Truncated = maxRows > 0 && len(durations) == maxRows
+	return hist, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// jobCache keeps recently read jobs in memory so bursts of reads for the same
+// job (WebSocket clients, pollers) cost one query. Every write to a job drops
+// its entry; entries of other replicas' writes expire after the TTL.
+type jobCache struct {
+	activeTTL   time.Duration
+	terminalTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]jobCacheEntry
+	// results holds result payloads; a job only has one once it finished,
+	// so they are kept for terminalTTL
+	results map[string]resultCacheEntry
+	// gen is bumped on every invalidation so that a read that raced with a
+	// write does not put the stale row back
+	gen       uint64
+	lastSweep time.Time
+}
+
+type jobCacheEntry struct {
+	job     models.Job
+	expires time.Time
+}
+
+type resultCacheEntry struct {
+	result  string
+	expires time.Time
+}
+
+func newJobCache(activeTTL, terminalTTL time.Duration) *jobCache {
+	return &jobCache{
+		activeTTL:   activeTTL,
+		terminalTTL: terminalTTL,
+		entries:     make(map[string]jobCacheEntry),
+		results:     make(map[string]resultCacheEntry),
+	}
+}
+
+// EnableJobCache caches GetJobTyped results for activeTTL, or terminalTTL
+// once the job has finished, and GetResult payloads for terminalTTL.
+// A zero activeTTL disables the cache.
+func (s *MySQLStore) EnableJobCache(activeTTL, terminalTTL time.Duration) {
+	if activeTTL <= 0 {
+		s.jobs = nil
+		return
+	}
+	if terminalTTL < activeTTL {
+		terminalTTL = activeTTL
+	}
+	s.jobs = newJobCache(activeTTL, terminalTTL)
+}
+
+// get returns a copy of the cached job and the generation to pass to put on a miss
+func (c *jobCache) get(jobID string) (*models.Job, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[jobID]
+	if !ok {
+		return nil, c.gen
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, jobID)
+		return nil, c.gen
+	}
+	return copyJob(&entry.job), c.gen
+}
+
+// put caches job unless a write happened since gen was read
+func (c *jobCache) put(job *models.Job, gen uint64) {
+	ttl := c.activeTTL
+	if isTerminalStatus(job.Status) {
+		ttl = c.terminalTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	now := time.Now()
+	c.sweep(now)
+	c.entries[job.JobID] = jobCacheEntry{job: *copyJob(job), expires: now.Add(ttl)}
+}
+
+// getResult returns the cached result of jobID and the generation to pass to
+// putResult on a miss
+func (c *jobCache) getResult(jobID string) (string, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.results[jobID]
+	if !ok {
+		return "", false, c.gen
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.results, jobID)
+		return "", false, c.gen
+	}
+	return entry.result, true, c.gen
+}
+
+// putResult caches the result of jobID unless a write happened since gen was read
+func (c *jobCache) putResult(jobID, result string, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	now := time.Now()
+	c.sweep(now)
+	c.results[jobID] = resultCacheEntry{result: result, expires: now.Add(c.terminalTTL)}
+}
+
+// sweep drops expired entries at most once per activeTTL
+func (c *jobCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.activeTTL {
+		return
+	}
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+	for id, entry := range c.results {
+		if now.After(entry.expires) {
+			delete(c.results, id)
+		}
+	}
+	c.lastSweep = now
+}
+
+// invalidate drops the cached entries of jobIDs
+func (c *jobCache) invalidate(jobIDs ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, id := range jobIDs {
+		delete(c.entries, id)
+		delete(c.results, id)
+	}
+}
+
+func copyJob(job *models.Job) *models.Job {
+	cp := *job
+	cp.Metadata = maps.Clone(job.Metadata)
+	return &cp
+}
+
+func isTerminalStatus(status string) bool {
+	switch status {
+	case "SUCCESS", "FAILED", "CANCELLED":
+		return true
+	}
+	return false
+}
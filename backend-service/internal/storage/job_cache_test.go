@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+func expectJobTyped(mock sqlmock.Sqlmock, jobID, status string, progress int) {
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows(jobColumns()).
+			AddRow(jobID, "KBM-WF01", "user-1", status, progress, "d", "{}", "", "", time.Now(), time.Now()))
+}
+
+func TestJobCacheCoalescesReads(t *testing.T) {
+	store, mock := newMockStore(t)
+	store.EnableJobCache(time.Minute, time.Minute)
+	expectJobTyped(mock, "job-1", "RUNNING", 40)
+
+	for i := 0; i < 5; i++ {
+		job, err := store.GetJobTyped(context.Background(), "job-1")
+		require.NoError(t, err)
+		assert.Equal(t, 40, job.Progress)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Callers get copies, so mutating one does not leak into the cache
+	job, err := store.GetJobTyped(context.Background(), "job-1")
+	require.NoError(t, err)
+	job.Status = "MUTATED"
+	job, err = store.GetJobTyped(context.Background(), "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "RUNNING", job.Status)
+}
+
+func TestJobCacheInvalidatedByWrites(t *testing.T) {
+	store, mock := newMockStore(t)
+	store.EnableJobCache(time.Minute, time.Minute)
+	ctx := context.Background()
+
+	expectJobTyped(mock, "job-1", "RUNNING", 40)
+	_, err := store.GetJobTyped(ctx, "job-1")
+	require.NoError(t, err)
+
+	mock.ExpectExec(`UPDATE t_algo_jobs`).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.UpdateProgress(ctx, "job-1", 60, ""))
+	expectJobTyped(mock, "job-1", "RUNNING", 60)
+	job, err := store.GetJobTyped(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, 60, job.Progress)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	_, err = store.MarkZombieAsFailed(ctx, []string{"job-1"})
+	require.NoError(t, err)
+	expectJobTyped(mock, "job-1", "FAILED", 60)
+	job, err = store.GetJobTyped(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "FAILED", job.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobCacheKeepsTerminalJobsLonger(t *testing.T) {
+	store, mock := newMockStore(t)
+	store.EnableJobCache(20*time.Millisecond, time.Minute)
+	ctx := context.Background()
+
+	expectJobTyped(mock, "running", "RUNNING", 40)
+	expectJobTyped(mock, "done", "SUCCESS", 100)
+	_, err := store.GetJobTyped(ctx, "running")
+	require.NoError(t, err)
+	_, err = store.GetJobTyped(ctx, "done")
+	require.NoError(t, err)
+
+	time.Sleep(40 * time.Millisecond)
+
+	// The running job has expired and is read again; the finished one is still cached
+	expectJobTyped(mock, "running", "RUNNING", 50)
+	job, err := store.GetJobTyped(ctx, "running")
+	require.NoError(t, err)
+	assert.Equal(t, 50, job.Progress)
+	_, err = store.GetJobTyped(ctx, "done")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobCacheSkipsFillRacingAWrite(t *testing.T) {
+	c := newJobCache(time.Minute, time.Minute)
+	cached, gen := c.get("job-1")
+	require.Nil(t, cached)
+
+	// A write lands between the miss and the fill
+	c.invalidate("job-1")
+	c.put(&models.Job{JobID: "job-1", Status: "RUNNING"}, gen)
+
+	cached, _ = c.get("job-1")
+	assert.Nil(t, cached)
+}
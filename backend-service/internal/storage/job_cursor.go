@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// ErrInvalidCursor is returned when a job list cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// JobCursor marks a position in the job list, which is ordered by
+// created_at and then job_id, both descending
+type JobCursor struct {
+	CreatedAt time.Time
+	JobID     string
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c JobCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.Format(time.RFC3339Nano) + "|" + c.JobID))
+}
+
+// DecodeJobCursor parses a cursor produced by JobCursor.Encode
+func DecodeJobCursor(s string) (JobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return JobCursor{}, ErrInvalidCursor
+	}
+	ts, jobID, ok := strings.Cut(string(raw), "|")
+	if !ok || jobID == "" {
+		return JobCursor{}, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return JobCursor{}, ErrInvalidCursor
+	}
+	return JobCursor{CreatedAt: createdAt, JobID: jobID}, nil
+}
+
+// ListJobsAfterCursor returns up to limit jobs matching the filter that come
+// after cursor (from the newest when cursor is nil), seeking on
+// (created_at, job_id) instead of skipping rows with OFFSET. next is the
+// cursor of the following page, or nil when there are no more jobs.
+func (s *MySQLStore) ListJobsAfterCursor(ctx context.Context, filter JobFilter, cursor *JobCursor, limit int) (jobs []models.Job, next *JobCursor, err error) {
+	if limit < 1 {
+		return nil, nil, fmt.Errorf("%w: limit must be positive", ErrPageOutOfRange)
+	}
+	where, args := filter.whereClause()
+	if cursor != nil {
+		where += " AND (created_at, job_id) < (?, ?)"
+		args = append(args, cursor.CreatedAt, cursor.JobID)
+	}
+	querySQL := `
+SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, metadata,
+       '' as result_summary,
+       COALESCE(error_log, '') as error_log,
+       created_at,
+       COALESCE(finished_at, created_at) as finished_at
+FROM t_algo_jobs ` + where + ` ORDER BY created_at DESC, job_id DESC LIMIT ?`
+
+	// One extra row tells whether another page follows
+	if err := s.selectRead(ctx, &jobs, querySQL, append(args, limit+1)...); err != nil {
+		return nil, nil, err
+	}
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+		last := jobs[limit-1]
+		next = &JobCursor{CreatedAt: last.CreatedAt, JobID: last.JobID}
+	}
+	return jobs, next, nil
+}
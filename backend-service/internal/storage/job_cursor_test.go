@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobCursorRoundTrip(t *testing.T) {
+	cursor := JobCursor{CreatedAt: time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), JobID: "job-1"}
+	decoded, err := DecodeJobCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, "job-1", decoded.JobID)
+
+	notTime := base64.RawURLEncoding.EncodeToString([]byte("yesterday|job-1"))
+	noID := base64.RawURLEncoding.EncodeToString([]byte("2026-03-01T08:30:00Z|"))
+	for _, bad := range []string{"not base64!", "bm8tc2VwYXJhdG9y", notTime, noID} {
+		_, err := DecodeJobCursor(bad)
+		assert.ErrorIs(t, err, ErrInvalidCursor, bad)
+	}
+}
+
+func TestListJobsAfterCursor(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+	t0 := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	columns := []string{"job_id", "status", "created_at"}
+
+	// The first page fetches one row more than the limit to detect a next page
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND status = \? ORDER BY created_at DESC, job_id DESC LIMIT \?`).
+		WithArgs("SUCCESS", 3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("job-c", "SUCCESS", t0.Add(2*time.Minute)).
+			AddRow("job-b", "SUCCESS", t0.Add(time.Minute)).
+			AddRow("job-a", "SUCCESS", t0.Add(time.Minute)))
+	jobs, next, err := store.ListJobsAfterCursor(ctx, JobFilter{Status: "SUCCESS"}, nil, 2)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	require.NotNil(t, next)
+	assert.Equal(t, "job-b", next.JobID)
+	assert.True(t, next.CreatedAt.Equal(t0.Add(time.Minute)))
+
+	// The next page seeks past the cursor, ties on created_at broken by job_id
+	mock.ExpectQuery(`WHERE deleted_at IS NULL AND status = \? AND \(created_at, job_id\) < \(\?, \?\) ORDER BY created_at DESC, job_id DESC LIMIT \?`).
+		WithArgs("SUCCESS", next.CreatedAt, "job-b", 3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("job-a", "SUCCESS", t0.Add(time.Minute)))
+	jobs, next, err = store.ListJobsAfterCursor(ctx, JobFilter{Status: "SUCCESS"}, next, 2)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "job-a", jobs[0].JobID)
+	assert.Nil(t, next)
+
+	_, _, err = store.ListJobsAfterCursor(ctx, JobFilter{}, nil, 0)
+	assert.ErrorIs(t, err, ErrPageOutOfRange)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SoftDeleteJob marks a terminal job as deleted, hiding it from job queries
+// until PurgeDeletedJobs removes it. It returns sql.ErrNoRows when the job
+// does not exist, is already deleted or is still PENDING or RUNNING.
+func (s *MySQLStore) SoftDeleteJob(ctx context.Context, jobID string) (time.Time, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, `
+UPDATE t_algo_jobs SET deleted_at = ?, updated_at = ?
+WHERE job_id = ? AND deleted_at IS NULL AND status IN ('SUCCESS', 'FAILED', 'CANCELLED')`, now, now, jobID)
+	s.jobs.invalidate(jobID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return now, nil
+}
+
+// PurgeDeletedJobs hard-deletes up to limit jobs soft-deleted before cutoff,
+// together with their results, notes and callbacks, in one transaction. It returns the
+// number of jobs removed.
+func (s *MySQLStore) PurgeDeletedJobs(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	var jobIDs []string
+	err := s.selectRead(ctx, &jobIDs, `
+SELECT job_id FROM t_algo_jobs WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY deleted_at ASC LIMIT ?`, cutoff, limit)
+	if err != nil || len(jobIDs) == 0 {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`DELETE FROM t_job_results WHERE job_id IN (?)`,
+		`DELETE FROM t_job_notes WHERE job_id IN (?)`,
+		`DELETE FROM t_job_callbacks WHERE job_id IN (?)`,
+		`DELETE FROM t_algo_jobs WHERE job_id IN (?)`,
+	} {
+		query, args, err := sqlx.In(stmt, jobIDs)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.jobs.invalidate(jobIDs...)
+	return len(jobIDs), nil
+}
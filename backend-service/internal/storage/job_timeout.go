@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// TimeoutErrorLog is the error log of jobs failed for running past their
+// own timeout_seconds
+const TimeoutErrorLog = "timeout exceeded"
+
+// TimedOutJob is an active job past its timeout and where to cancel it
+type TimedOutJob struct {
+	JobID      string `db:"job_id"`
+	AlgoTaskID string `db:"algo_task_id"`
+	AlgoTarget string `db:"algo_target"`
+}
+
+// SetJobTimeout records the time after which a job is failed for running too long
+func (s *MySQLStore) SetJobTimeout(ctx context.Context, jobID string, timeoutAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET timeout_at = ? WHERE job_id = ?`, timeoutAt, jobID)
+	return err
+}
+
+// FindTimedOutJobs returns up to limit PENDING and RUNNING jobs whose own
+// timeout has passed at now, earliest first. Jobs without a timeout are
+// left to the zombie cleanup.
+func (s *MySQLStore) FindTimedOutJobs(ctx context.Context, now time.Time, limit int) ([]TimedOutJob, error) {
+	var jobs []TimedOutJob
+	err := s.selectRead(ctx, &jobs, `
+SELECT job_id, COALESCE(algo_task_id, job_id) AS algo_task_id, COALESCE(algo_target, '') AS algo_target
+FROM t_algo_jobs WHERE status IN ('PENDING', 'RUNNING') AND timeout_at IS NOT NULL AND timeout_at <= ? AND scheme_code <> ?
+ORDER BY timeout_at ASC LIMIT ?`, now, PipelineSchemeCode, limit)
+	return jobs, err
+}
+
+// MarkTimedOutAsFailed fails a PENDING or RUNNING job with TimeoutErrorLog,
+// reporting whether it did; a job that finished meanwhile is left as is
+func (s *MySQLStore) MarkTimedOutAsFailed(ctx context.Context, jobID string) (bool, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, `
+UPDATE t_algo_jobs SET status = 'FAILED', error_log = ?, finished_at = ?, updated_at = ?
+WHERE job_id = ? AND status IN ('PENDING', 'RUNNING')
+`, TimeoutErrorLog, now, now, jobID)
+	s.jobs.invalidate(jobID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
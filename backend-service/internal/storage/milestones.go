@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// MilestoneSubscription is a job's request for progress milestone callbacks
+type MilestoneSubscription struct {
+	CallbackURL string
+	// Milestones are the percentages to notify at, ascending
+	Milestones []int
+	// Notified is the highest milestone already claimed for delivery
+	Notified int
+}
+
+// SetJobCallback records the callback URL submitted with a job and the
+// progress milestones, if any, notified at it
+func (s *MySQLStore) SetJobCallback(ctx context.Context, jobID, callbackURL string, milestones []int) error {
+	var rawMilestones any
+	if len(milestones) > 0 {
+		raw, err := json.Marshal(milestones)
+		if err != nil {
+			return err
+		}
+		rawMilestones = string(raw)
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET callback_url = ?, progress_milestones = ? WHERE job_id = ?`,
+		callbackURL, rawMilestones, jobID)
+	s.jobs.invalidate(jobID)
+	return err
+}
+
+// GetMilestoneSubscription returns a job's milestone subscription, nil when
+// the job has none
+func (s *MySQLStore) GetMilestoneSubscription(ctx context.Context, jobID string) (*MilestoneSubscription, error) {
+	var row struct {
+		CallbackURL string `db:"callback_url"`
+		Milestones  string `db:"progress_milestones"`
+		Notified    int    `db:"milestone_notified"`
+	}
+	err := s.db.GetContext(ctx, &row, `
+SELECT COALESCE(callback_url, '') AS callback_url, COALESCE(progress_milestones, '') AS progress_milestones, milestone_notified
+FROM t_algo_jobs WHERE job_id = ?`, jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row.CallbackURL == "" || row.Milestones == "" {
+		return nil, nil
+	}
+	sub := &MilestoneSubscription{CallbackURL: row.CallbackURL, Notified: row.Notified}
+	if err := json.Unmarshal([]byte(row.Milestones), &sub.Milestones); err != nil {
+		return nil, err
+	}
+	if len(sub.Milestones) == 0 {
+		return nil, nil
+	}
+	return sub, nil
+}
+
+// MilestoneCallback is a progress milestone delivery for the callback outbox
+type MilestoneCallback struct {
+	Milestone  int
+	DeliveryID string
+	Payload    []byte
+}
+
+// ClaimMilestone advances a job's notified milestone to milestone and, in
+// the same transaction, adds callbacks of event to the outbox, addressed to
+// the job's callback URL. It returns false, enqueueing nothing, when the job already
+// reached the milestone, so only one caller on any instance claims it.
+func (s *MySQLStore) ClaimMilestone(ctx context.Context, jobID string, milestone int, event string, callbacks []MilestoneCallback) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE t_algo_jobs SET milestone_notified = ? WHERE job_id = ? AND milestone_notified < ?`,
+		milestone, jobID, milestone)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	now := time.Now()
+	for _, cb := range callbacks {
+		if _, err := tx.ExecContext(ctx, `
+INSERT IGNORE INTO t_job_callbacks (delivery_id, job_id, event, milestone, url, payload, status, attempts, next_attempt_at, created_at, updated_at)
+SELECT ?, job_id, ?, ?, callback_url, ?, 'PENDING', 0, ?, ?, ?
+FROM t_algo_jobs WHERE job_id = ? AND callback_url IS NOT NULL AND callback_url <> ''`,
+			cb.DeliveryID, event, cb.Milestone, string(cb.Payload), now, now, now, jobID); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
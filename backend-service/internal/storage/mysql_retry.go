@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// isStaleConnError reports whether err means the pooled connection was
+// already dead, as happens to idle connections after a MySQL restart
+func isStaleConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "bad connection") || strings.Contains(msg, "invalid connection")
+}
+
+// retryRead runs a read-only query, running it once more when it fails on a
+// stale connection. Writes must not use it: a retried write could apply twice.
+func (s *MySQLStore) retryRead(ctx context.Context, query func() error) error {
+	err := query()
+	if isStaleConnError(err) && ctx.Err() == nil {
+		err = query()
+	}
+	return err
+}
+
+// getRead is GetContext with retryRead
+func (s *MySQLStore) getRead(ctx context.Context, dest any, query string, args ...any) error {
+	return s.retryRead(ctx, func() error {
+		return s.db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+// selectRead is SelectContext with retryRead. dest is truncated before the
+// retry so rows scanned by the failed attempt are not kept.
+func (s *MySQLStore) selectRead(ctx context.Context, dest any, query string, args ...any) error {
+	slice := reflect.ValueOf(dest).Elem()
+	return s.retryRead(ctx, func() error {
+		if slice.Len() > 0 {
+			slice.Set(slice.Slice(0, 0))
+		}
+		return s.db.SelectContext(ctx, dest, query, args...)
+	})
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadRetriesOnceAfterStaleConnection tests that a read failing on a
+// dropped connection is retried transparently
+func TestReadRetriesOnceAfterStaleConnection(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("algo-7").
+		WillReturnError(mysql.ErrInvalidConn)
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("algo-7").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+
+	jobID, err := store.GetJobIDByAlgoTaskID(ctx, "algo-7")
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", jobID)
+
+	// Rows scanned before the connection dropped are not kept by the retry
+	t0 := time.Now()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_job_notes WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`ORDER BY created_at ASC, id ASC LIMIT \? OFFSET \?`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "author", "note", "created_at"}).
+			AddRow(5, "job-1", "alice", "first", t0).
+			AddRow(6, "job-1", "bob", "second", t0).
+			RowError(1, mysql.ErrInvalidConn))
+	mock.ExpectQuery(`ORDER BY created_at ASC, id ASC LIMIT \? OFFSET \?`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "author", "note", "created_at"}).
+			AddRow(5, "job-1", "alice", "first", t0).
+			AddRow(6, "job-1", "bob", "second", t0))
+	notes, total, err := store.ListJobNotes(ctx, "job-1", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, notes, 2)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestReadRetryLimits tests that only one retry is made, only for connection
+// errors, and never for writes
+func TestReadRetryLimits(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs`).WillReturnError(mysql.ErrInvalidConn)
+	mock.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs`).WillReturnError(mysql.ErrInvalidConn)
+	_, err := store.GetAlgoTaskID(ctx, "job-1")
+	assert.ErrorIs(t, err, mysql.ErrInvalidConn)
+
+	mock.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs`).WillReturnError(errors.New("syntax error"))
+	_, err = store.GetAlgoTaskID(ctx, "job-1")
+	assert.EqualError(t, err, "syntax error")
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET progress_updated_at`).WillReturnError(mysql.ErrInvalidConn)
+	err = store.UpdateProgress(ctx, "job-1", 50, "")
+	assert.ErrorIs(t, err, mysql.ErrInvalidConn)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsStaleConnError(t *testing.T) {
+	assert.True(t, isStaleConnError(mysql.ErrInvalidConn))
+	assert.True(t, isStaleConnError(errors.New("driver: bad connection")))
+	assert.False(t, isStaleConnError(nil))
+	assert.False(t, isStaleConnError(context.Canceled))
+}
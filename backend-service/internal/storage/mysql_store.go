@@ -3,6 +3,11 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/electric-power/backend-service/internal/models"
@@ -11,8 +16,16 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// maxListOffset caps OFFSET for page-based listing
+const maxListOffset = 10_000_000
+
+// ErrPageOutOfRange is returned when a requested page cannot be served safely
+var ErrPageOutOfRange = errors.New("page out of range")
+
 type MySQLStore struct {
 	db *sqlx.DB
+	// jobs caches GetJobTyped; nil unless EnableJobCache was called
+	jobs *jobCache
 }
 
 func NewMySQLStore(dsn string) (*MySQLStore, error) {
@@ -29,6 +42,70 @@ func NewMySQLStore(dsn string) (*MySQLStore, error) {
 	return &MySQLStore{db: db}, nil
 }
 
+// NewMySQLStoreWithDB wraps an existing connection pool, e.g. one opened by tests
+func NewMySQLStoreWithDB(db *sqlx.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+// JobFilter narrows job queries; zero-valued fields are ignored
+type JobFilter struct {
+	UserID string
+	Status string
+	// SchemeCode matches the scheme code exactly, SchemePrefix by prefix
+	SchemeCode   string
+	SchemePrefix string
+	// CreatedFrom and CreatedTo bound created_at; CreatedTo is exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// HasError keeps only jobs with a non-empty error_log, whatever their status
+	HasError bool
+}
+
+// whereClause builds the SQL WHERE clause and its arguments for the
+// filter; soft-deleted jobs never match
+func (f JobFilter) whereClause() (string, []any) {
+	args := []any{}
+	where := "WHERE deleted_at IS NULL"
+
+	if f.UserID != "" {
+		where += " AND user_id = ?"
+		args = append(args, f.UserID)
+	}
+	if f.Status != "" {
+		where += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	if f.SchemeCode != "" {
+		where += " AND scheme_code = ?"
+		args = append(args, f.SchemeCode)
+	}
+	if f.SchemePrefix != "" {
+		where += " AND scheme_code LIKE ? ESCAPE '!'"
+		args = append(args, escapeLike(f.SchemePrefix)+"%")
+	}
+	if !f.CreatedFrom.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, f.CreatedTo)
+	}
+	if f.HasError {
+		where += " AND error_log IS NOT NULL AND error_log != ''"
+	}
+	return where, args
+}
+
+// likeEscaper escapes the LIKE wildcards with '!', which unlike a backslash
+// means the same whatever the SQL mode
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// escapeLike makes s match itself literally in a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
 func (s *MySQLStore) Close() error {
 	return s.db.Close()
 }
@@ -37,8 +114,37 @@ func (s *MySQLStore) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
 }
 
-func (s *MySQLStore) InitSchema(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, `
+// RoundTrip writes a value to a temporary table and reads it back, proving
+// the connection can execute statements without touching real data
+func (s *MySQLStore) RoundTrip(ctx context.Context) error {
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	want := fmt.Sprintf("selftest-%d", time.Now().UnixNano())
+	if _, err := conn.ExecContext(ctx, `CREATE TEMPORARY TABLE t_selftest (v VARCHAR(64) NOT NULL)`); err != nil {
+		return fmt.Errorf("create temporary table: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `DROP TEMPORARY TABLE IF EXISTS t_selftest`)
+
+	if _, err := conn.ExecContext(ctx, `INSERT INTO t_selftest (v) VALUES (?)`, want); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	var got string
+	if err := conn.GetContext(ctx, &got, `SELECT v FROM t_selftest`); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("read back %q, wrote %q", got, want)
+	}
+	return nil
+}
+
+// schemaStatements are applied in order by InitSchema; each must be idempotent
+var schemaStatements = []string{
+	`
 CREATE TABLE IF NOT EXISTS t_algo_jobs (
   job_id CHAR(36) PRIMARY KEY,
   scheme_code VARCHAR(50) NOT NULL,
@@ -52,12 +158,171 @@ CREATE TABLE IF NOT EXISTS t_algo_jobs (
   created_at DATETIME NOT NULL,
   updated_at DATETIME,
   finished_at DATETIME,
+  algo_task_id VARCHAR(64) NULL,
+  parent_job_id CHAR(36) NULL,
+  step_index INT NULL,
+  checkpoint_ref VARCHAR(512) NULL,
+  metadata JSON NULL,
+  progress_updated_at DATETIME NULL,
+  result_bytes BIGINT NULL,
+  algo_target VARCHAR(64) NULL,
+  sla_deadline DATETIME NULL,
+  sla_state VARCHAR(16) NULL,
+  deleted_at DATETIME NULL,
+  callback_url VARCHAR(1024) NULL,
+  progress_milestones JSON NULL,
+  milestone_notified INT NOT NULL DEFAULT 0,
+  timeout_at DATETIME NULL,
   INDEX idx_user_status (user_id, status),
   INDEX idx_status_created (status, created_at),
-  INDEX idx_scheme (scheme_code)
+  INDEX idx_scheme (scheme_code),
+  INDEX idx_algo_task (algo_task_id),
+  INDEX idx_parent_step (parent_job_id, step_index),
+  INDEX idx_sla_deadline (sla_deadline),
+  INDEX idx_deleted_at (deleted_at),
+  INDEX idx_timeout_at (timeout_at)
 );
-`)
-	return err
+`,
+	`
+CREATE TABLE IF NOT EXISTS t_job_notes (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  job_id CHAR(36) NOT NULL,
+  author VARCHAR(50) NOT NULL,
+  note TEXT NOT NULL,
+  created_at DATETIME(3) NOT NULL,
+  INDEX idx_job_created (job_id, created_at)
+);
+`,
+	`
+CREATE TABLE IF NOT EXISTS t_job_results (
+  job_id CHAR(36) PRIMARY KEY,
+  result_json LONGTEXT NOT NULL,
+  created_at DATETIME NOT NULL
+);
+`,
+	`
+CREATE TABLE IF NOT EXISTS t_job_callbacks (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  delivery_id CHAR(36) NOT NULL,
+  job_id CHAR(36) NOT NULL,
+  event VARCHAR(64) NOT NULL,
+  milestone INT NOT NULL DEFAULT 0,
+  url VARCHAR(1024) NOT NULL,
+  payload JSON NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NULL,
+  next_attempt_at DATETIME(3) NOT NULL,
+  delivered_at DATETIME(3) NULL,
+  created_at DATETIME(3) NOT NULL,
+  updated_at DATETIME(3) NOT NULL,
+  UNIQUE KEY uk_job_event_milestone (job_id, event, milestone),
+  INDEX idx_status_next (status, next_attempt_at)
+);
+`,
+	`
+CREATE TABLE IF NOT EXISTS t_data_uploads (
+  data_ref VARCHAR(255) PRIMARY KEY,
+  filename VARCHAR(255) NOT NULL,
+  content_type VARCHAR(128) NOT NULL,
+  size BIGINT NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  uploaded_by VARCHAR(64) NULL,
+  uploaded_at DATETIME(3) NOT NULL,
+  UNIQUE KEY uk_checksum (checksum)
+);
+`,
+	`
+CREATE TABLE IF NOT EXISTS t_schema_migrations (
+  name VARCHAR(100) PRIMARY KEY,
+  applied_at DATETIME NOT NULL
+);
+`,
+}
+
+// columnMigrations add columns to tables created before the column existed.
+// CREATE TABLE above already includes them, so fresh installs skip these.
+var columnMigrations = []struct {
+	table, column, ddl string
+}{
+	{"t_algo_jobs", "algo_task_id", "ALTER TABLE t_algo_jobs ADD COLUMN algo_task_id VARCHAR(64) NULL, ADD INDEX idx_algo_task (algo_task_id)"},
+	{"t_algo_jobs", "parent_job_id", "ALTER TABLE t_algo_jobs ADD COLUMN parent_job_id CHAR(36) NULL, ADD COLUMN step_index INT NULL, ADD INDEX idx_parent_step (parent_job_id, step_index)"},
+	{"t_algo_jobs", "checkpoint_ref", "ALTER TABLE t_algo_jobs ADD COLUMN checkpoint_ref VARCHAR(512) NULL"},
+	{"t_algo_jobs", "metadata", "ALTER TABLE t_algo_jobs ADD COLUMN metadata JSON NULL"},
+	{"t_algo_jobs", "progress_updated_at", "ALTER TABLE t_algo_jobs ADD COLUMN progress_updated_at DATETIME NULL"},
+	{"t_algo_jobs", "result_bytes", "ALTER TABLE t_algo_jobs ADD COLUMN result_bytes BIGINT NULL"},
+	{"t_algo_jobs", "algo_target", "ALTER TABLE t_algo_jobs ADD COLUMN algo_target VARCHAR(64) NULL"},
+	{"t_algo_jobs", "sla_deadline", "ALTER TABLE t_algo_jobs ADD COLUMN sla_deadline DATETIME NULL, ADD COLUMN sla_state VARCHAR(16) NULL, ADD INDEX idx_sla_deadline (sla_deadline)"},
+	{"t_algo_jobs", "deleted_at", "ALTER TABLE t_algo_jobs ADD COLUMN deleted_at DATETIME NULL, ADD INDEX idx_deleted_at (deleted_at)"},
+	{"t_algo_jobs", "progress_milestones", "ALTER TABLE t_algo_jobs ADD COLUMN callback_url VARCHAR(1024) NULL, ADD COLUMN progress_milestones JSON NULL, ADD COLUMN milestone_notified INT NOT NULL DEFAULT 0"},
+	{"t_algo_jobs", "timeout_at", "ALTER TABLE t_algo_jobs ADD COLUMN timeout_at DATETIME NULL, ADD INDEX idx_timeout_at (timeout_at)"},
+	{"t_job_callbacks", "milestone", "ALTER TABLE t_job_callbacks ADD COLUMN milestone INT NOT NULL DEFAULT 0, DROP INDEX uk_job_event, ADD UNIQUE KEY uk_job_event_milestone (job_id, event, milestone)"},
+}
+
+// dataMigrations rewrite rows written by older versions. Each runs once and
+// is recorded in t_schema_migrations.
+var dataMigrations = []struct {
+	name, stmt string
+}{
+	// params used to be stored double-encoded, as a JSON string holding the object
+	{"unwrap_string_params", `
+UPDATE t_algo_jobs SET params = CAST(JSON_UNQUOTE(params) AS JSON)
+WHERE JSON_TYPE(params) = 'STRING' AND JSON_VALID(JSON_UNQUOTE(params))`},
+	// results used to live in t_algo_jobs.result_summary; copy them over,
+	// then clear only the rows that made it so nothing is lost
+	{"copy_results_to_t_job_results", `
+INSERT INTO t_job_results (job_id, result_json, created_at)
+SELECT job_id, result_summary, COALESCE(finished_at, updated_at, created_at) FROM t_algo_jobs
+WHERE result_summary IS NOT NULL AND result_summary <> ''
+ON DUPLICATE KEY UPDATE result_json = t_job_results.result_json`},
+	{"clear_copied_result_summary", `
+UPDATE t_algo_jobs j JOIN t_job_results r ON r.job_id = j.job_id
+SET j.result_summary = NULL WHERE j.result_summary IS NOT NULL`},
+	// keyset pagination seeks on (created_at, job_id); the index is added
+	// here rather than in CREATE TABLE so every install gets it exactly once
+	{"add_idx_created_job", `ALTER TABLE t_algo_jobs ADD INDEX idx_created_job (created_at, job_id)`},
+	// uploads are deduplicated per uploader, so each one's file has its own row
+	{"scope_upload_checksum_to_uploader", `
+ALTER TABLE t_data_uploads DROP INDEX uk_checksum, ADD UNIQUE KEY uk_checksum_uploader (checksum, uploaded_by)`},
+}
+
+func (s *MySQLStore) InitSchema(ctx context.Context) error {
+	for _, stmt := range schemaStatements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	for _, m := range columnMigrations {
+		var n int
+		err := s.db.GetContext(ctx, &n, `
+SELECT COUNT(*) FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, m.ddl); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	for _, m := range dataMigrations {
+		var n int
+		if err := s.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM t_schema_migrations WHERE name = ?`, m.name); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, m.stmt); err != nil {
+			return fmt.Errorf("data migration %s: %w", m.name, err)
+		}
+		if _, err := s.db.ExecContext(ctx, `INSERT INTO t_schema_migrations (name, applied_at) VALUES (?, ?)`, m.name, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *MySQLStore) InsertJob(ctx context.Context, jobID, schemeCode, userID, dataRef, params string) error {
@@ -65,22 +330,213 @@ func (s *MySQLStore) InsertJob(ctx context.Context, jobID, schemeCode, userID, d
 	_, err := s.db.ExecContext(ctx, `
 INSERT INTO t_algo_jobs (job_id, scheme_code, user_id, status, progress, data_ref, params, created_at, updated_at)
 VALUES (?, ?, ?, 'PENDING', 0, ?, ?, ?, ?)
-`, jobID, schemeCode, userID, dataRef, params, now, now)
+`, jobID, schemeCode, userID, dataRef, models.NormalizeParams(params), now, now)
+	return err
+}
+
+// NewJob is one row written by InsertJobsBatch
+type NewJob struct {
+	JobID      string
+	SchemeCode string
+	UserID     string
+	DataRef    string
+	Params     string
+	Metadata   map[string]string
+	// SLADeadline is stored when set
+	SLADeadline *time.Time
+	// TimeoutAt, when set, is when the job is failed for running too long
+	TimeoutAt *time.Time
+	// CallbackURL is notified when the job finishes and, when set, as its
+	// progress crosses ProgressMilestones
+	CallbackURL        string
+	ProgressMilestones []int
+}
+
+// InsertJobsBatch creates jobs in a single transaction: either every row is
+// written or none is
+func (s *MySQLStore) InsertJobsBatch(ctx context.Context, jobs []NewJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(ctx, `
+INSERT INTO t_algo_jobs (job_id, scheme_code, user_id, status, progress, data_ref, params, metadata, sla_deadline, timeout_at, callback_url, progress_milestones, created_at, updated_at)
+VALUES (?, ?, ?, 'PENDING', 0, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, job := range jobs {
+		var metadata any
+		if len(job.Metadata) > 0 {
+			raw, err := json.Marshal(job.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = string(raw)
+		}
+		var deadline, timeoutAt any
+		if job.SLADeadline != nil {
+			deadline = *job.SLADeadline
+		}
+		if job.TimeoutAt != nil {
+			timeoutAt = *job.TimeoutAt
+		}
+		var callbackURL, milestones any
+		if job.CallbackURL != "" {
+			callbackURL = job.CallbackURL
+		}
+		if len(job.ProgressMilestones) > 0 {
+			raw, err := json.Marshal(job.ProgressMilestones)
+			if err != nil {
+				return err
+			}
+			milestones = string(raw)
+		}
+		if _, err := stmt.ExecContext(ctx, job.JobID, job.SchemeCode, job.UserID, job.DataRef, models.NormalizeParams(job.Params), metadata, deadline, timeoutAt, callbackURL, milestones, now, now); err != nil {
+			return fmt.Errorf("insert job %s: %w", job.JobID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// PipelineSchemeCode marks parent jobs that orchestrate a multi-step pipeline
+const PipelineSchemeCode = "PIPELINE"
+
+// InsertChildJob creates a job that runs as step stepIndex of a parent pipeline job
+func (s *MySQLStore) InsertChildJob(ctx context.Context, jobID, parentJobID string, stepIndex int, schemeCode, userID, dataRef, params string) error {
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO t_algo_jobs (job_id, scheme_code, user_id, status, progress, data_ref, params, parent_job_id, step_index, created_at, updated_at)
+VALUES (?, ?, ?, 'PENDING', 0, ?, ?, ?, ?, ?, ?)
+`, jobID, schemeCode, userID, dataRef, models.NormalizeParams(params), parentJobID, stepIndex, now, now)
 	return err
 }
 
+// GetParentJob returns the parent job ID and step index of a child job.
+// parentJobID is "" when the job is not part of a pipeline.
+func (s *MySQLStore) GetParentJob(ctx context.Context, jobID string) (parentJobID string, stepIndex int, err error) {
+	var row struct {
+		ParentJobID sql.NullString `db:"parent_job_id"`
+		StepIndex   sql.NullInt64  `db:"step_index"`
+	}
+	err = s.getRead(ctx, &row, `SELECT parent_job_id, step_index FROM t_algo_jobs WHERE job_id = ?`, jobID)
+	if err != nil {
+		return "", 0, err
+	}
+	return row.ParentJobID.String, int(row.StepIndex.Int64), nil
+}
+
+// JobRef identifies a job and the task ID the algorithm service knows it by
+type JobRef struct {
+	JobID      string `db:"job_id"`
+	AlgoTaskID string `db:"algo_task_id"`
+}
+
+// ListActiveJobsByScheme returns the PENDING and RUNNING jobs of a scheme, oldest first
+func (s *MySQLStore) ListActiveJobsByScheme(ctx context.Context, schemeCode string) ([]JobRef, error) {
+	var refs []JobRef
+	err := s.selectRead(ctx, &refs, `
+SELECT job_id, COALESCE(algo_task_id, job_id) AS algo_task_id
+FROM t_algo_jobs WHERE scheme_code = ? AND status IN ('PENDING', 'RUNNING') ORDER BY created_at ASC`, schemeCode)
+	return refs, err
+}
+
+// ListChildJobs returns the child jobs of a parent in step order
+func (s *MySQLStore) ListChildJobs(ctx context.Context, parentJobID string) ([]models.Job, error) {
+	var jobs []models.Job
+	err := s.selectRead(ctx, &jobs, `
+SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, metadata, 
+       '' as result_summary, 
+       COALESCE(error_log, '') as error_log, 
+       created_at, 
+       COALESCE(finished_at, created_at) as finished_at
+FROM t_algo_jobs WHERE parent_job_id = ? ORDER BY step_index ASC`, parentJobID)
+	return jobs, err
+}
+
+// UpdateProgress records a progress report. progress_updated_at only moves
+// when the percentage changes, so repeated reports at the same value count
+// as heartbeats rather than progress.
 func (s *MySQLStore) UpdateProgress(ctx context.Context, jobID string, progress int, message string) error {
+	now := time.Now()
 	_, err := s.db.ExecContext(ctx, `
-UPDATE t_algo_jobs SET progress = ?, status = 'RUNNING', updated_at = ? WHERE job_id = ?
-`, progress, time.Now(), jobID)
+UPDATE t_algo_jobs
+SET progress_updated_at = IF(progress = ? AND progress_updated_at IS NOT NULL, progress_updated_at, ?),
+    progress = ?, status = 'RUNNING', updated_at = ?
+WHERE job_id = ?
+`, progress, now, progress, now, jobID)
+	s.jobs.invalidate(jobID)
 	return err
 }
 
+// FinishJob stores a successful result. The result is saved before the job
+// is marked SUCCESS, so a finished job always has its result readable.
 func (s *MySQLStore) FinishJob(ctx context.Context, jobID, resultJSON string) error {
+	if err := s.SaveResult(ctx, jobID, resultJSON); err != nil {
+		return err
+	}
 	now := time.Now()
 	_, err := s.db.ExecContext(ctx, `
-UPDATE t_algo_jobs SET status = 'SUCCESS', result_summary = ?, finished_at = ?, updated_at = ? WHERE job_id = ?
-`, resultJSON, now, now, jobID)
+UPDATE t_algo_jobs SET status = 'SUCCESS', result_bytes = ?, finished_at = ?, updated_at = ? WHERE job_id = ?
+`, len(resultJSON), now, now, jobID)
+	s.jobs.invalidate(jobID)
+	return err
+}
+
+// SaveResult stores the result payload of a job, replacing any earlier one
+func (s *MySQLStore) SaveResult(ctx context.Context, jobID, resultJSON string) error {
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO t_job_results (job_id, result_json, created_at) VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE result_json = VALUES(result_json), created_at = VALUES(created_at)
+`, jobID, resultJSON, time.Now())
+	s.jobs.invalidate(jobID)
+	return err
+}
+
+// GetResult returns the result payload of a job, or "" if it has none. Jobs
+// finished before results moved to t_job_results are read from
+// t_algo_jobs.result_summary. sql.ErrNoRows means the job does not exist.
+func (s *MySQLStore) GetResult(ctx context.Context, jobID string) (string, error) {
+	var gen uint64
+	if s.jobs != nil {
+		var cached string
+		var ok bool
+		if cached, ok, gen = s.jobs.getResult(jobID); ok {
+			return cached, nil
+		}
+	}
+
+	var result string
+	err := s.getRead(ctx, &result, `SELECT result_json FROM t_job_results WHERE job_id = ?`, jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = s.getRead(ctx, &result, `SELECT COALESCE(result_summary, '') FROM t_algo_jobs WHERE job_id = ?`, jobID)
+	}
+	if err != nil {
+		return "", err
+	}
+	if s.jobs != nil {
+		s.jobs.putResult(jobID, result, gen)
+	}
+	return result, nil
+}
+
+// FailOversizedResult fails a job whose result was rejected for its size,
+// recording the size without storing the result
+func (s *MySQLStore) FailOversizedResult(ctx context.Context, jobID string, size int, reason string) error {
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `
+UPDATE t_algo_jobs SET status = 'FAILED', error_log = ?, result_bytes = ?, finished_at = ?, updated_at = ? WHERE job_id = ?
+`, reason, size, now, now, jobID)
+	s.jobs.invalidate(jobID)
 	return err
 }
 
@@ -89,70 +545,201 @@ func (s *MySQLStore) FailJob(ctx context.Context, jobID, errorLog string) error
 	_, err := s.db.ExecContext(ctx, `
 UPDATE t_algo_jobs SET status = 'FAILED', error_log = ?, finished_at = ?, updated_at = ? WHERE job_id = ?
 `, errorLog, now, now, jobID)
+	s.jobs.invalidate(jobID)
 	return err
 }
 
-func (s *MySQLStore) CancelJob(ctx context.Context, jobID, message string) error {
+// CancelJob marks a PENDING or RUNNING job cancelled, reporting whether it
+// did; a job that has already finished keeps its terminal status
+func (s *MySQLStore) CancelJob(ctx context.Context, jobID, message string) (bool, error) {
 	now := time.Now()
-	_, err := s.db.ExecContext(ctx, `
-UPDATE t_algo_jobs SET status = 'CANCELLED', error_log = ?, finished_at = ?, updated_at = ? WHERE job_id = ?
+	res, err := s.db.ExecContext(ctx, `
+UPDATE t_algo_jobs SET status = 'CANCELLED', error_log = ?, finished_at = ?, updated_at = ?
+WHERE job_id = ? AND status IN ('PENDING', 'RUNNING')
 `, message, now, now, jobID)
-	return err
+	s.jobs.invalidate(jobID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
 }
 
 func (s *MySQLStore) GetJob(ctx context.Context, jobID string) (map[string]any, error) {
-	row := s.db.QueryRowxContext(ctx, `
-SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, result_summary, error_log, created_at, updated_at, finished_at 
-FROM t_algo_jobs WHERE job_id = ?`, jobID)
-	result := map[string]any{}
-	if err := row.MapScan(result); err != nil {
+	var result map[string]any
+	err := s.retryRead(ctx, func() error {
+		result = map[string]any{}
+		return s.db.QueryRowxContext(ctx, `
+SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, metadata,
+       COALESCE((SELECT result_json FROM t_job_results r WHERE r.job_id = t_algo_jobs.job_id), result_summary) as result_summary,
+       result_bytes, algo_target, error_log, created_at, updated_at, finished_at 
+FROM t_algo_jobs WHERE job_id = ? AND deleted_at IS NULL`, jobID).MapScan(result)
+	})
+	if err != nil {
 		return nil, err
 	}
+	// The driver returns text columns as []byte
+	for k, v := range result {
+		if b, ok := v.([]byte); ok {
+			result[k] = string(b)
+		}
+	}
+	if params, ok := result["params"].(string); ok {
+		result["params"] = json.RawMessage(models.NormalizeParams(params))
+	}
+	if metadata, ok := result["metadata"].(string); ok && metadata != "" {
+		result["metadata"] = json.RawMessage(metadata)
+	} else {
+		delete(result, "metadata")
+	}
+	if result["result_bytes"] == nil {
+		delete(result, "result_bytes")
+	}
+	if result["algo_target"] == nil {
+		delete(result, "algo_target")
+	}
 	return result, nil
 }
 
+// SetAlgoTaskID records the task ID the algorithm service assigned to a job
+func (s *MySQLStore) SetAlgoTaskID(ctx context.Context, jobID, algoTaskID string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET algo_task_id = ? WHERE job_id = ?`, algoTaskID, jobID)
+	return err
+}
+
+// GetAlgoTaskID returns the algorithm-side task ID for a job, or "" if the
+// algorithm service uses the job ID itself
+func (s *MySQLStore) GetAlgoTaskID(ctx context.Context, jobID string) (string, error) {
+	var algoTaskID sql.NullString
+	err := s.getRead(ctx, &algoTaskID, `SELECT algo_task_id FROM t_algo_jobs WHERE job_id = ?`, jobID)
+	if err != nil {
+		return "", err
+	}
+	return algoTaskID.String, nil
+}
+
+// SetAlgoTarget records the algorithm service target a job was routed to
+func (s *MySQLStore) SetAlgoTarget(ctx context.Context, jobID, target string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET algo_target = ? WHERE job_id = ?`, target, jobID)
+	return err
+}
+
+// GetAlgoTarget returns the algorithm service target of a job, or "" for the
+// default service
+func (s *MySQLStore) GetAlgoTarget(ctx context.Context, jobID string) (string, error) {
+	var target sql.NullString
+	err := s.getRead(ctx, &target, `SELECT algo_target FROM t_algo_jobs WHERE job_id = ?`, jobID)
+	if err != nil {
+		return "", err
+	}
+	return target.String, nil
+}
+
+// SetCheckpointRef records the latest checkpoint a job can resume from
+func (s *MySQLStore) SetCheckpointRef(ctx context.Context, jobID, ref string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET checkpoint_ref = ? WHERE job_id = ?`, ref, jobID)
+	return err
+}
+
+// GetCheckpointRef returns the latest checkpoint recorded for a job, or "" if none
+func (s *MySQLStore) GetCheckpointRef(ctx context.Context, jobID string) (string, error) {
+	var ref sql.NullString
+	if err := s.getRead(ctx, &ref, `SELECT checkpoint_ref FROM t_algo_jobs WHERE job_id = ?`, jobID); err != nil {
+		return "", err
+	}
+	return ref.String, nil
+}
+
+// SetJobMetadata stores the caller metadata of a job as a JSON object
+func (s *MySQLStore) SetJobMetadata(ctx context.Context, jobID string, metadata map[string]string) error {
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET metadata = ? WHERE job_id = ?`, string(raw), jobID)
+	s.jobs.invalidate(jobID)
+	return err
+}
+
+// GetJobIDByAlgoTaskID maps an algorithm-side task ID back to our job ID.
+// Returns sql.ErrNoRows when no job carries that algo task ID.
+func (s *MySQLStore) GetJobIDByAlgoTaskID(ctx context.Context, algoTaskID string) (string, error) {
+	var jobID string
+	err := s.getRead(ctx, &jobID, `SELECT job_id FROM t_algo_jobs WHERE algo_task_id = ? LIMIT 1`, algoTaskID)
+	return jobID, err
+}
+
+// GetJobByAlgoTaskID returns the job the algorithm service knows as
+// algoTaskID. Returns sql.ErrNoRows when no job carries that algo task ID.
+func (s *MySQLStore) GetJobByAlgoTaskID(ctx context.Context, algoTaskID string) (map[string]any, error) {
+	jobID, err := s.GetJobIDByAlgoTaskID(ctx, algoTaskID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetJob(ctx, jobID)
+}
+
 // GetJobTyped returns a strongly typed Job struct
 func (s *MySQLStore) GetJobTyped(ctx context.Context, jobID string) (*models.Job, error) {
+	var gen uint64
+	if s.jobs != nil {
+		var cached *models.Job
+		if cached, gen = s.jobs.get(jobID); cached != nil {
+			return cached, nil
+		}
+	}
+
 	var job models.Job
-	err := s.db.GetContext(ctx, &job, `
-SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, 
-       COALESCE(result_summary, '') as result_summary, 
+	err := s.getRead(ctx, &job, `
+SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, metadata, 
+       '' as result_summary, 
        COALESCE(error_log, '') as error_log, 
        created_at, 
        COALESCE(finished_at, created_at) as finished_at
-FROM t_algo_jobs WHERE job_id = ?`, jobID)
+FROM t_algo_jobs WHERE job_id = ? AND deleted_at IS NULL`, jobID)
 	if err != nil {
 		return nil, err
 	}
+	if s.jobs != nil {
+		s.jobs.put(&job, gen)
+	}
 	return &job, nil
 }
 
 // ListJobsWithPagination returns paginated jobs with filters
-func (s *MySQLStore) ListJobsWithPagination(ctx context.Context, userID, status string, page, pageSize int) ([]models.Job, int, error) {
-	offset := (page - 1) * pageSize
-	args := []any{}
-	where := "WHERE 1=1"
-
-	if userID != "" {
-		where += " AND user_id = ?"
-		args = append(args, userID)
+func (s *MySQLStore) ListJobsWithPagination(ctx context.Context, filter JobFilter, page, pageSize int) ([]models.Job, int, error) {
+	if _, err := pageOffset(page, pageSize); err != nil {
+		return nil, 0, err
 	}
-	if status != "" {
-		where += " AND status = ?"
-		args = append(args, status)
+	total, err := s.CountJobs(ctx, filter)
+	if err != nil {
+		return nil, 0, err
 	}
-
-	// Count total
-	var total int
-	countSQL := "SELECT COUNT(*) FROM t_algo_jobs " + where
-	if err := s.db.GetContext(ctx, &total, countSQL, args...); err != nil {
+	jobs, err := s.ListJobsPage(ctx, filter, page, pageSize)
+	if err != nil {
 		return nil, 0, err
 	}
+	return jobs, total, nil
+}
 
-	// Fetch page
+// ListModuleJobs is ListJobsWithPagination restricted to the jobs whose
+// scheme code starts with schemePrefix, e.g. "KBM-" for a module
+func (s *MySQLStore) ListModuleJobs(ctx context.Context, schemePrefix string, filter JobFilter, page, pageSize int) ([]models.Job, int, error) {
+	filter.SchemePrefix = schemePrefix
+	return s.ListJobsWithPagination(ctx, filter, page, pageSize)
+}
+
+// ListJobsPage returns one page of jobs matching the filter, newest first,
+// without counting the total
+func (s *MySQLStore) ListJobsPage(ctx context.Context, filter JobFilter, page, pageSize int) ([]models.Job, error) {
+	offset, err := pageOffset(page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	where, args := filter.whereClause()
 	querySQL := `
-SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, 
-       COALESCE(result_summary, '') as result_summary, 
+SELECT job_id, scheme_code, user_id, status, progress, data_ref, params, metadata, 
+       '' as result_summary, 
        COALESCE(error_log, '') as error_log, 
        created_at, 
        COALESCE(finished_at, created_at) as finished_at
@@ -160,45 +747,274 @@ FROM t_algo_jobs ` + where + ` ORDER BY created_at DESC LIMIT ? OFFSET ?`
 
 	queryArgs := append(args, pageSize, offset)
 	var jobs []models.Job
-	if err := s.db.SelectContext(ctx, &jobs, querySQL, queryArgs...); err != nil {
+	if err := s.selectRead(ctx, &jobs, querySQL, queryArgs...); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CountJobs returns the number of jobs matching the filter
+func (s *MySQLStore) CountJobs(ctx context.Context, filter JobFilter) (int, error) {
+	where, args := filter.whereClause()
+	var total int
+	err := s.getRead(ctx, &total, "SELECT COUNT(*) FROM t_algo_jobs "+where, args...)
+	return total, err
+}
+
+// ExportJobs streams up to limit matching jobs, newest first, to fn without
+// loading them all into memory. Result payloads are not included.
+func (s *MySQLStore) ExportJobs(ctx context.Context, filter JobFilter, limit int, fn func(models.Job) error) error {
+	where, args := filter.whereClause()
+	querySQL := `
+SELECT job_id, scheme_code, user_id, status, progress, data_ref, params,
+       '' as result_summary,
+       COALESCE(error_log, '') as error_log,
+       created_at,
+       COALESCE(finished_at, created_at) as finished_at
+FROM t_algo_jobs ` + where + ` ORDER BY created_at DESC LIMIT ?`
+
+	// Only opening the cursor is retried; rows already handed to fn are not replayed
+	var rows *sqlx.Rows
+	err := s.retryRead(ctx, func() (err error) {
+		rows, err = s.db.QueryxContext(ctx, querySQL, append(args, limit)...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var job models.Job
+		if err := rows.StructScan(&job); err != nil {
+			return err
+		}
+		if err := fn(job); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// AddJobNote appends an operator note to a job
+func (s *MySQLStore) AddJobNote(ctx context.Context, jobID, author, note string) (*models.JobNote, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO t_job_notes (job_id, author, note, created_at) VALUES (?, ?, ?, ?)
+`, jobID, author, note, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &models.JobNote{ID: id, JobID: jobID, Author: author, Note: note, CreatedAt: now}, nil
+}
+
+// ListJobNotes returns a job's notes in chronological order with the total count
+func (s *MySQLStore) ListJobNotes(ctx context.Context, jobID string, page, pageSize int) ([]models.JobNote, int, error) {
+	offset, err := pageOffset(page, pageSize)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	return jobs, total, nil
+	var total int
+	if err := s.getRead(ctx, &total, `SELECT COUNT(*) FROM t_job_notes WHERE job_id = ?`, jobID); err != nil {
+		return nil, 0, err
+	}
+
+	notes := []models.JobNote{}
+	if err := s.selectRead(ctx, &notes, `
+SELECT id, job_id, author, note, created_at FROM t_job_notes
+WHERE job_id = ? ORDER BY created_at ASC, id ASC LIMIT ? OFFSET ?`, jobID, pageSize, offset); err != nil {
+		return nil, 0, err
+	}
+	return notes, total, nil
+}
+
+// pageOffset converts a 1-based page into a row offset, refusing values whose
+// offset would overflow or exceed what MySQL can evaluate sensibly
+func pageOffset(page, pageSize int) (int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		return 0, fmt.Errorf("%w: page_size must be positive", ErrPageOutOfRange)
+	}
+	if page-1 > maxListOffset/pageSize {
+		return 0, fmt.Errorf("%w: page %d with page_size %d", ErrPageOutOfRange, page, pageSize)
+	}
+	return (page - 1) * pageSize, nil
+}
+
+// ZombieStrategy selects which timestamp zombie detection compares against
+type ZombieStrategy string
+
+const (
+	// ZombieByUpdatedAt flags jobs without any update, heartbeats included
+	ZombieByUpdatedAt ZombieStrategy = "updated_at"
+	// ZombieByProgress flags jobs whose progress percentage has not advanced,
+	// even if they keep reporting
+	ZombieByProgress ZombieStrategy = "progress"
+)
+
+// column returns the timestamp column the strategy checks
+func (z ZombieStrategy) column() string {
+	if z == ZombieByProgress {
+		// Jobs that never reported progress fall back to their last update
+		return "COALESCE(progress_updated_at, updated_at)"
+	}
+	return "updated_at"
 }
 
-// FindZombieTasks finds tasks stuck in RUNNING state for longer than timeout
-func (s *MySQLStore) FindZombieTasks(ctx context.Context, timeout time.Duration) ([]string, error) {
-	cutoff := time.Now().Add(-timeout)
+// FindZombieTasks finds RUNNING tasks whose strategy timestamp is older than
+// their scheme's timeout. Schemes without an override use defaultTimeout.
+func (s *MySQLStore) FindZombieTasks(ctx context.Context, strategy ZombieStrategy, defaultTimeout time.Duration, overrides map[string]time.Duration) ([]string, error) {
+	return s.FindZombieTasksAfter(ctx, strategy, defaultTimeout, overrides, "", 0)
+}
+
+// FindZombieTasksAfter is FindZombieTasks one chunk at a time: it returns up
+// to limit zombies ordered by job_id, starting after afterID, so a large set
+// can be walked without loading it whole. limit <= 0 returns every zombie.
+func (s *MySQLStore) FindZombieTasksAfter(ctx context.Context, strategy ZombieStrategy, defaultTimeout time.Duration, overrides map[string]time.Duration, afterID string, limit int) ([]string, error) {
+	cond, args := zombieCondition(strategy, defaultTimeout, overrides)
+	query := `
+SELECT job_id FROM t_algo_jobs WHERE ` + cond
+	if afterID != "" {
+		query += ` AND job_id > ?`
+		args = append(args, afterID)
+	}
+	if limit > 0 {
+		query += ` ORDER BY job_id LIMIT ?`
+		args = append(args, limit)
+	}
 	var jobIDs []string
-	err := s.db.SelectContext(ctx, &jobIDs, `
-SELECT job_id FROM t_algo_jobs WHERE status = 'RUNNING' AND updated_at < ?`, cutoff)
+	err := s.selectRead(ctx, &jobIDs, query, args...)
 	return jobIDs, err
 }
 
-// MarkZombieAsFailed marks zombie tasks as failed
-func (s *MySQLStore) MarkZombieAsFailed(ctx context.Context, jobIDs []string) error {
-	if len(jobIDs) == 0 {
-		return nil
+// ZombieTask is a RUNNING job zombie detection would fail, with the
+// timestamp its strategy compares against
+type ZombieTask struct {
+	JobID      string    `db:"job_id" json:"job_id"`
+	SchemeCode string    `db:"scheme_code" json:"scheme_code"`
+	UserID     string    `db:"user_id" json:"user_id"`
+	Progress   int       `db:"progress" json:"progress"`
+	LastUpdate time.Time `db:"last_update" json:"last_update"`
+}
+
+// ListZombieTasks returns up to limit of the jobs FindZombieTasks finds,
+// longest stuck first, without modifying them
+func (s *MySQLStore) ListZombieTasks(ctx context.Context, strategy ZombieStrategy, defaultTimeout time.Duration, overrides map[string]time.Duration, limit int) ([]ZombieTask, error) {
+	cond, args := zombieCondition(strategy, defaultTimeout, overrides)
+	args = append(args, limit)
+	var tasks []ZombieTask
+	err := s.selectRead(ctx, &tasks, `
+SELECT job_id, scheme_code, user_id, progress, `+strategy.column()+` AS last_update
+FROM t_algo_jobs WHERE `+cond+` ORDER BY last_update ASC LIMIT ?`, args...)
+	return tasks, err
+}
+
+// zombieCondition returns the WHERE condition selecting RUNNING jobs whose
+// strategy timestamp is older than their scheme's timeout, and its args
+func zombieCondition(strategy ZombieStrategy, defaultTimeout time.Duration, overrides map[string]time.Duration) (string, []any) {
+	now := time.Now()
+	cutoff := "?"
+	var args []any
+	if len(overrides) > 0 {
+		codes := make([]string, 0, len(overrides))
+		for code := range overrides {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		cutoff = "CASE scheme_code"
+		for _, code := range codes {
+			cutoff += " WHEN ? THEN ?"
+			args = append(args, code, now.Add(-overrides[code]))
+		}
+		cutoff += " ELSE ? END"
+	}
+	args = append(args, now.Add(-defaultTimeout), PipelineSchemeCode)
+
+	// Pipeline parents stay RUNNING across steps; their children are checked instead
+	return `status = 'RUNNING' AND ` + strategy.column() + ` < ` + cutoff + ` AND scheme_code <> ?`, args
+}
+
+// maxZombieMarkBatch caps the job IDs of one zombie UPDATE so its IN clause
+// stays well within MySQL's placeholder and packet limits
+const maxZombieMarkBatch = 1000
+
+// ZombieErrorLog is the error log of jobs failed as zombies
+const ZombieErrorLog = "Task timeout - marked as zombie"
+
+// MarkZombieAsFailed marks zombie tasks as failed, in chunks of at most
+// maxZombieMarkBatch jobs, and returns the jobs it failed. Jobs no longer
+// RUNNING, e.g. ones whose result arrived since they were found, keep their
+// status.
+func (s *MySQLStore) MarkZombieAsFailed(ctx context.Context, jobIDs []string) ([]string, error) {
+	var failed []string
+	for len(jobIDs) > 0 {
+		chunk := jobIDs[:min(len(jobIDs), maxZombieMarkBatch)]
+		jobIDs = jobIDs[len(chunk):]
+
+		running, err := s.markZombieChunk(ctx, chunk)
+		s.jobs.invalidate(chunk...)
+		if err != nil {
+			return failed, err
+		}
+		failed = append(failed, running...)
 	}
+	return failed, nil
+}
+
+// markZombieChunk fails the jobs of chunk still RUNNING, locking them first
+// so it knows which ones it changed
+func (s *MySQLStore) markZombieChunk(ctx context.Context, chunk []string) ([]string, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query, args, err := sqlx.In(`
-UPDATE t_algo_jobs SET status = 'FAILED', error_log = 'Task timeout - marked as zombie', finished_at = ?, updated_at = ? 
-WHERE job_id IN (?)`, time.Now(), time.Now(), jobIDs)
+SELECT job_id FROM t_algo_jobs WHERE job_id IN (?) AND status = 'RUNNING' FOR UPDATE`, chunk)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	query = s.db.Rebind(query)
-	_, err = s.db.ExecContext(ctx, query, args...)
-	return err
+	var running []string
+	if err := tx.SelectContext(ctx, &running, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	if len(running) == 0 {
+		return nil, tx.Commit()
+	}
+
+	now := time.Now()
+	query, args, err = sqlx.In(`
+UPDATE t_algo_jobs SET status = 'FAILED', error_log = '`+ZombieErrorLog+`', finished_at = ?, updated_at = ?
+WHERE job_id IN (?)`, now, now, running)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return running, tx.Commit()
 }
 
-// GetStats returns aggregate statistics
-func (s *MySQLStore) GetStats(ctx context.Context) (map[string]any, error) {
+// GetStats returns aggregate statistics for jobs matching the filter
+func (s *MySQLStore) GetStats(ctx context.Context, filter JobFilter) (map[string]any, error) {
 	stats := make(map[string]any)
+	where, args := filter.whereClause()
 
 	// Count by status
-	rows, err := s.db.QueryxContext(ctx, `
-SELECT status, COUNT(*) as count FROM t_algo_jobs GROUP BY status`)
+	var rows *sqlx.Rows
+	err := s.retryRead(ctx, func() (err error) {
+		rows, err = s.db.QueryxContext(ctx, `
+SELECT status, COUNT(*) as count FROM t_algo_jobs `+where+` GROUP BY status`, args...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -217,8 +1033,8 @@ SELECT status, COUNT(*) as count FROM t_algo_jobs GROUP BY status`)
 
 	// Average duration for completed jobs
 	var avgDuration sql.NullFloat64
-	_ = s.db.GetContext(ctx, &avgDuration, `
-SELECT AVG(TIMESTAMPDIFF(SECOND, created_at, finished_at)) FROM t_algo_jobs WHERE status = 'SUCCESS' AND finished_at IS NOT NULL`)
+	_ = s.getRead(ctx, &avgDuration, `
+SELECT AVG(TIMESTAMPDIFF(SECOND, created_at, finished_at)) FROM t_algo_jobs `+where+` AND status = 'SUCCESS' AND finished_at IS NOT NULL`, args...)
 	if avgDuration.Valid {
 		stats["avg_duration_seconds"] = avgDuration.Float64
 	}
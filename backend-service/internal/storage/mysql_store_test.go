@@ -0,0 +1,818 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/electric-power/backend-service/internal/models"
+)
+
+// newMockStore returns a store backed by sqlmock
+func newMockStore(t *testing.T) (*MySQLStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return NewMySQLStoreWithDB(sqlx.NewDb(db, "mysql")), mock
+}
+
+// approxTime matches a time.Time argument within a tolerance of the expected value
+type approxTime struct {
+	want      time.Time
+	tolerance time.Duration
+}
+
+func (a approxTime) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	diff := got.Sub(a.want)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= a.tolerance
+}
+
+func jobColumns() []string {
+	return []string{"job_id", "scheme_code", "user_id", "status", "progress", "data_ref", "params",
+		"result_summary", "error_log", "created_at", "finished_at"}
+}
+
+func TestListJobsWithWindowFilter(t *testing.T) {
+	for _, window := range []time.Duration{time.Hour, 24 * time.Hour} {
+		t.Run(window.String(), func(t *testing.T) {
+			store, mock := newMockStore(t)
+			from := time.Now().Add(-window)
+			matcher := approxTime{want: from, tolerance: time.Second}
+
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND user_id = \? AND created_at >= \?`).
+				WithArgs("user-1", matcher).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND user_id = \? AND created_at >= \? ORDER BY created_at DESC LIMIT \? OFFSET \?`).
+				WithArgs("user-1", matcher, 20, 0).
+				WillReturnRows(sqlmock.NewRows(jobColumns()).
+					AddRow("job-1", "KBM-WF01", "user-1", "SUCCESS", 100, "d", "{}", "", "", time.Now(), time.Now()))
+
+			jobs, total, err := store.ListJobsWithPagination(context.Background(), JobFilter{UserID: "user-1", CreatedFrom: from}, 1, 20)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+			assert.Len(t, jobs, 1)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// TestListModuleJobsCountsPerModule tests that module listing filters by
+// scheme prefix in SQL, so totals count the module's jobs rather than a page
+func TestListModuleJobsCountsPerModule(t *testing.T) {
+	seed := []struct{ jobID, scheme, user, status string }{
+		{"job-1", "KBM-WF01", "user-1", "SUCCESS"},
+		{"job-2", "SCM-WF01", "user-1", "SUCCESS"},
+		{"job-3", "KBM-WF02", "user-2", "FAILED"},
+		{"job-4", "STM-WF01", "user-1", "RUNNING"},
+		{"job-5", "KBM-WF03", "user-1", "SUCCESS"},
+		{"job-6", "KBMX-WF01", "user-1", "SUCCESS"},
+	}
+	tests := []struct {
+		prefix string
+		filter JobFilter
+		where  string
+		args   []driver.Value
+		want   []string
+	}{
+		{"KBM-", JobFilter{}, `WHERE deleted_at IS NULL AND scheme_code LIKE \? ESCAPE '!'`, []driver.Value{"KBM-%"}, []string{"job-1", "job-3", "job-5"}},
+		{"SCM-", JobFilter{}, `WHERE deleted_at IS NULL AND scheme_code LIKE \? ESCAPE '!'`, []driver.Value{"SCM-%"}, []string{"job-2"}},
+		{"KBM-", JobFilter{UserID: "user-1", Status: "SUCCESS"},
+			`WHERE deleted_at IS NULL AND user_id = \? AND status = \? AND scheme_code LIKE \? ESCAPE '!'`,
+			[]driver.Value{"user-1", "SUCCESS", "KBM-%"}, []string{"job-1", "job-5"}},
+	}
+	for _, tt := range tests {
+		store, mock := newMockStore(t)
+		// The database answers with the seeded jobs the query selects
+		rows := sqlmock.NewRows(jobColumns())
+		var matched []string
+		for _, job := range seed {
+			if strings.HasPrefix(job.scheme, tt.prefix) &&
+				(tt.filter.UserID == "" || job.user == tt.filter.UserID) &&
+				(tt.filter.Status == "" || job.status == tt.filter.Status) {
+				matched = append(matched, job.jobID)
+				rows.AddRow(job.jobID, job.scheme, job.user, job.status, 100, "d", "{}", "", "", time.Now(), time.Now())
+			}
+		}
+		require.Equal(t, tt.want, matched)
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs ` + tt.where + `$`).
+			WithArgs(tt.args...).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(matched)))
+		mock.ExpectQuery(`FROM t_algo_jobs ` + tt.where + ` ORDER BY created_at DESC LIMIT \? OFFSET \?`).
+			WithArgs(append(tt.args, 20, 0)...).
+			WillReturnRows(rows)
+
+		jobs, total, err := store.ListModuleJobs(context.Background(), tt.prefix, tt.filter, 1, 20)
+		require.NoError(t, err)
+		assert.Equal(t, len(tt.want), total)
+		got := make([]string, len(jobs))
+		for i, job := range jobs {
+			got[i] = job.JobID
+		}
+		assert.Equal(t, tt.want, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestListJobsHasErrorFilter(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs WHERE deleted_at IS NULL AND user_id = \? AND error_log IS NOT NULL AND error_log != ''`).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE deleted_at IS NULL AND user_id = \? AND error_log IS NOT NULL AND error_log != '' ORDER BY created_at DESC LIMIT \? OFFSET \?`).
+		WithArgs("user-1", 20, 0).
+		WillReturnRows(sqlmock.NewRows(jobColumns()).
+			AddRow("job-1", "KBM-WF01", "user-1", "SUCCESS", 100, "d", "{}", "", "warning: 3 rows skipped", time.Now(), time.Now()).
+			AddRow("job-2", "KBM-WF01", "user-1", "FAILED", 40, "d", "{}", "", "boom", time.Now(), time.Now()))
+
+	jobs, total, err := store.ListJobsWithPagination(context.Background(), JobFilter{UserID: "user-1", HasError: true}, 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "SUCCESS", jobs[0].Status)
+	for _, job := range jobs {
+		assert.NotEmpty(t, job.ErrorLog)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountJobsRunsOnlyCountQuery(t *testing.T) {
+	from := time.Now().Add(-time.Hour)
+	to := from.Add(30 * time.Minute)
+	tests := []struct {
+		filter JobFilter
+		where  string
+		args   []driver.Value
+	}{
+		{JobFilter{}, `WHERE deleted_at IS NULL$`, nil},
+		{JobFilter{Status: "SUCCESS"}, `WHERE deleted_at IS NULL AND status = \?$`, []driver.Value{"SUCCESS"}},
+		{JobFilter{UserID: "u1", Status: "FAILED", CreatedFrom: from}, `WHERE deleted_at IS NULL AND user_id = \? AND status = \? AND created_at >= \?$`, []driver.Value{"u1", "FAILED", from}},
+		{JobFilter{HasError: true}, `WHERE deleted_at IS NULL AND error_log IS NOT NULL AND error_log != ''$`, nil},
+		{JobFilter{Status: "SUCCESS", HasError: true}, `WHERE deleted_at IS NULL AND status = \? AND error_log IS NOT NULL AND error_log != ''$`, []driver.Value{"SUCCESS"}},
+		{JobFilter{SchemeCode: "KBM-WF01", CreatedFrom: from, CreatedTo: to}, `WHERE deleted_at IS NULL AND scheme_code = \? AND created_at >= \? AND created_at < \?$`, []driver.Value{"KBM-WF01", from, to}},
+		{JobFilter{SchemePrefix: "KBM-"}, `WHERE deleted_at IS NULL AND scheme_code LIKE \? ESCAPE '!'$`, []driver.Value{"KBM-%"}},
+		{JobFilter{SchemePrefix: "A_1%!"}, `WHERE deleted_at IS NULL AND scheme_code LIKE \? ESCAPE '!'$`, []driver.Value{"A!_1!%!!%"}},
+	}
+	for _, tt := range tests {
+		store, mock := newMockStore(t)
+		q := mock.ExpectQuery(`^SELECT COUNT\(\*\) FROM t_algo_jobs ` + tt.where)
+		if tt.args != nil {
+			q = q.WithArgs(tt.args...)
+		}
+		q.WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+		n, err := store.CountJobs(context.Background(), tt.filter)
+		require.NoError(t, err)
+		assert.Equal(t, 42, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetStatsWithWindowFilter(t *testing.T) {
+	store, mock := newMockStore(t)
+	from := time.Now().Add(-24 * time.Hour)
+
+	mock.ExpectQuery(`SELECT status, COUNT\(\*\) as count FROM t_algo_jobs WHERE deleted_at IS NULL AND created_at >= \? GROUP BY status`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow("SUCCESS", 3).AddRow("FAILED", 1))
+	mock.ExpectQuery(`SELECT AVG\(.+\) FROM t_algo_jobs WHERE deleted_at IS NULL AND created_at >= \? AND status = 'SUCCESS'`).
+		WithArgs(from).
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(12.5))
+
+	stats, err := store.GetStats(context.Background(), JobFilter{CreatedFrom: from})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"SUCCESS": 3, "FAILED": 1}, stats["status_counts"])
+	assert.Equal(t, 12.5, stats["avg_duration_seconds"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListJobsRejectsOverflowingOffset(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	_, _, err := store.ListJobsWithPagination(context.Background(), JobFilter{}, 2000000000, 100)
+	assert.ErrorIs(t, err, ErrPageOutOfRange)
+
+	_, _, err = store.ListJobsWithPagination(context.Background(), JobFilter{}, math.MaxInt, math.MaxInt)
+	assert.ErrorIs(t, err, ErrPageOutOfRange)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPageOffset(t *testing.T) {
+	offset, err := pageOffset(0, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, offset)
+
+	offset, err = pageOffset(-5, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, offset)
+
+	offset, err = pageOffset(3, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, offset)
+}
+
+func TestAddAndListJobNotes(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO t_job_notes \(job_id, author, note, created_at\)`).
+		WithArgs("job-1", "alice", "first", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	note, err := store.AddJobNote(ctx, "job-1", "alice", "first")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), note.ID)
+	assert.Equal(t, "alice", note.Author)
+
+	t0 := time.Now()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_job_notes WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`ORDER BY created_at ASC, id ASC LIMIT \? OFFSET \?`).
+		WithArgs("job-1", 10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "author", "note", "created_at"}).
+			AddRow(5, "job-1", "alice", "first", t0).
+			AddRow(6, "job-1", "bob", "second", t0.Add(time.Second)))
+
+	notes, total, err := store.ListJobNotes(ctx, "job-1", 2, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, notes, 2)
+	assert.True(t, notes[0].CreatedAt.Before(notes[1].CreatedAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInitSchemaAddsMissingColumns(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	for range schemaStatements {
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "algo_task_id").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN algo_task_id`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "parent_job_id").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "checkpoint_ref").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN checkpoint_ref`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "metadata").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "progress_updated_at").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN progress_updated_at`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "result_bytes").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "algo_target").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN algo_target`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "sla_deadline").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN sla_deadline`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "deleted_at").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "progress_milestones").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN callback_url`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_algo_jobs", "timeout_at").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_algo_jobs ADD COLUMN timeout_at`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+		WithArgs("t_job_callbacks", "milestone").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER TABLE t_job_callbacks ADD COLUMN milestone`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_schema_migrations WHERE name = \?`).
+		WithArgs("unwrap_string_params").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET params = CAST\(JSON_UNQUOTE\(params\) AS JSON\)`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`INSERT INTO t_schema_migrations`).
+		WithArgs("unwrap_string_params", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_schema_migrations WHERE name = \?`).
+		WithArgs("copy_results_to_t_job_results").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`INSERT INTO t_job_results \(job_id, result_json, created_at\)\s+SELECT job_id, result_summary`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO t_schema_migrations`).
+		WithArgs("copy_results_to_t_job_results", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_schema_migrations WHERE name = \?`).
+		WithArgs("clear_copied_result_summary").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE t_algo_jobs j JOIN t_job_results r ON r.job_id = j.job_id\s+SET j.result_summary = NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO t_schema_migrations`).
+		WithArgs("clear_copied_result_summary", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_schema_migrations WHERE name = \?`).
+		WithArgs("add_idx_created_job").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_schema_migrations WHERE name = \?`).
+		WithArgs("scope_upload_checksum_to_uploader").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	require.NoError(t, store.InitSchema(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishJobSavesResultSeparately(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO t_job_results \(job_id, result_json, created_at\) VALUES`).
+		WithArgs("job-1", `{"score":1}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'SUCCESS', result_bytes = \?`).
+		WithArgs(len(`{"score":1}`), sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.FinishJob(ctx, "job-1", `{"score":1}`))
+
+	mock.ExpectQuery(`SELECT result_json FROM t_job_results WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"result_json"}).AddRow(`{"score":1}`))
+	result, err := store.GetResult(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"score":1}`, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetResultFallsBackToLegacyColumn(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT result_json FROM t_job_results WHERE job_id = \?`).
+		WithArgs("job-old").
+		WillReturnRows(sqlmock.NewRows([]string{"result_json"}))
+	mock.ExpectQuery(`SELECT COALESCE\(result_summary, ''\) FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-old").
+		WillReturnRows(sqlmock.NewRows([]string{"result_summary"}).AddRow(`{"legacy":true}`))
+	result, err := store.GetResult(ctx, "job-old")
+	require.NoError(t, err)
+	assert.Equal(t, `{"legacy":true}`, result)
+
+	// A job that exists nowhere is reported as missing
+	mock.ExpectQuery(`SELECT result_json FROM t_job_results WHERE job_id = \?`).
+		WithArgs("job-x").
+		WillReturnRows(sqlmock.NewRows([]string{"result_json"}))
+	mock.ExpectQuery(`SELECT COALESCE\(result_summary, ''\) FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-x").
+		WillReturnRows(sqlmock.NewRows([]string{"result_summary"}))
+	_, err = store.GetResult(ctx, "job-x")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListJobsSkipsResultPayload(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_algo_jobs`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`'' as result_summary,.+FROM t_algo_jobs WHERE deleted_at IS NULL ORDER BY created_at DESC`).
+		WillReturnRows(sqlmock.NewRows(jobColumns()).
+			AddRow("job-1", "KBM-WF01", "u1", "SUCCESS", 100, "d", "{}", "", "", time.Now(), time.Now()))
+	jobs, total, err := store.ListJobsWithPagination(context.Background(), JobFilter{}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, jobs, 1)
+	assert.Empty(t, jobs[0].ResultJSON)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAlgoTaskIDMapping(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET algo_task_id = \? WHERE job_id = \?`).
+		WithArgs("algo-7", "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.SetAlgoTaskID(ctx, "job-1", "algo-7"))
+
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE algo_task_id = \?`).
+		WithArgs("algo-7").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1"))
+	jobID, err := store.GetJobIDByAlgoTaskID(ctx, "algo-7")
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", jobID)
+
+	mock.ExpectQuery(`SELECT algo_task_id FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"algo_task_id"}).AddRow(nil))
+	algoTaskID, err := store.GetAlgoTaskID(ctx, "job-2")
+	require.NoError(t, err)
+	assert.Empty(t, algoTaskID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckpointRef(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET checkpoint_ref = \? WHERE job_id = \?`).
+		WithArgs("s3://ckpt/7", "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.SetCheckpointRef(ctx, "job-1", "s3://ckpt/7"))
+
+	mock.ExpectQuery(`SELECT checkpoint_ref FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"checkpoint_ref"}).AddRow("s3://ckpt/7"))
+	ref, err := store.GetCheckpointRef(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3://ckpt/7", ref)
+
+	mock.ExpectQuery(`SELECT checkpoint_ref FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"checkpoint_ref"}).AddRow(nil))
+	ref, err = store.GetCheckpointRef(ctx, "job-2")
+	require.NoError(t, err)
+	assert.Empty(t, ref)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobMetadata(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`UPDATE t_algo_jobs SET metadata = \? WHERE job_id = \?`).
+		WithArgs(`{"trace_id":"abc"}`, "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.SetJobMetadata(ctx, "job-1", map[string]string{"trace_id": "abc"}))
+
+	mock.ExpectQuery(`SELECT job_id, .+ metadata, .+ FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-1").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "params", "metadata", "created_at"}).
+			AddRow("job-1", "{}", []byte(`{"trace_id":"abc"}`), time.Now()))
+	job, err := store.GetJobTyped(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobMetadata{"trace_id": "abc"}, job.Metadata)
+
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "params", "metadata", "created_at"}).
+			AddRow("job-2", "{}", nil, time.Now()))
+	job, err = store.GetJobTyped(ctx, "job-2")
+	require.NoError(t, err)
+	assert.Nil(t, job.Metadata)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPipelineChildJobs(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO t_algo_jobs \(.+parent_job_id, step_index`).
+		WithArgs("child-1", "KBM-WF01", "user-1", "file:///in.csv", "{}", "parent-1", 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.InsertChildJob(ctx, "child-1", "parent-1", 1, "KBM-WF01", "user-1", "file:///in.csv", "{}"))
+
+	mock.ExpectQuery(`SELECT parent_job_id, step_index FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("child-1").
+		WillReturnRows(sqlmock.NewRows([]string{"parent_job_id", "step_index"}).AddRow("parent-1", 1))
+	parentID, step, err := store.GetParentJob(ctx, "child-1")
+	require.NoError(t, err)
+	assert.Equal(t, "parent-1", parentID)
+	assert.Equal(t, 1, step)
+
+	mock.ExpectQuery(`SELECT parent_job_id, step_index FROM t_algo_jobs WHERE job_id = \?`).
+		WithArgs("job-2").
+		WillReturnRows(sqlmock.NewRows([]string{"parent_job_id", "step_index"}).AddRow(nil, nil))
+	parentID, _, err = store.GetParentJob(ctx, "job-2")
+	require.NoError(t, err)
+	assert.Empty(t, parentID)
+
+	mock.ExpectQuery(`WHERE parent_job_id = \? ORDER BY step_index ASC`).
+		WithArgs("parent-1").
+		WillReturnRows(sqlmock.NewRows(jobColumns()).
+			AddRow("child-0", "KBM-WF01", "user-1", "SUCCESS", 100, "d", "{}", "", "", time.Now(), time.Now()).
+			AddRow("child-1", "KBM-WF02", "user-1", "RUNNING", 40, "d", "{}", "", "", time.Now(), time.Now()))
+	children, err := store.ListChildJobs(ctx, "parent-1")
+	require.NoError(t, err)
+	require.Len(t, children, 2)
+	assert.Equal(t, "child-0", children[0].JobID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// staleness matches a cutoff argument by whether a job last updated at
+// updatedAt falls before it
+type staleness struct {
+	updatedAt time.Time
+	zombie    bool
+}
+
+func (s staleness) Match(v driver.Value) bool {
+	cutoff, ok := v.(time.Time)
+	return ok && s.updatedAt.Before(cutoff) == s.zombie
+}
+
+func TestFindZombieTasksPerSchemeTimeout(t *testing.T) {
+	store, mock := newMockStore(t)
+	now := time.Now()
+
+	// A GPU job quiet for 1h is within its 4h timeout; a CPU job quiet for 45m
+	// exceeds the 30m default
+	mock.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < CASE scheme_code WHEN \? THEN \? WHEN \? THEN \? ELSE \? END AND scheme_code <> \?`).
+		WithArgs(
+			"KBM-WF02", staleness{updatedAt: now.Add(-4 * time.Minute), zombie: true},
+			"SCM-WF01", staleness{updatedAt: now.Add(-time.Hour), zombie: false},
+			staleness{updatedAt: now.Add(-45 * time.Minute), zombie: true},
+			PipelineSchemeCode,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("cpu-job"))
+
+	zombies, err := store.FindZombieTasks(context.Background(), ZombieByUpdatedAt, 30*time.Minute, map[string]time.Duration{
+		"SCM-WF01": 4 * time.Hour,
+		"KBM-WF02": 3 * time.Minute,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu-job"}, zombies)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindZombieTasksDefaultTimeout(t *testing.T) {
+	store, mock := newMockStore(t)
+	now := time.Now()
+
+	mock.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \?`).
+		WithArgs(staleness{updatedAt: now.Add(-time.Hour), zombie: false}, PipelineSchemeCode).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+
+	zombies, err := store.FindZombieTasks(context.Background(), ZombieByUpdatedAt, 2*time.Hour, nil)
+	require.NoError(t, err)
+	assert.Empty(t, zombies)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindZombieTasksAfterPagesOnJobID(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \? ORDER BY job_id LIMIT \?`).
+		WithArgs(sqlmock.AnyArg(), PipelineSchemeCode, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1").AddRow("job-2"))
+	mock.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \? AND job_id > \? ORDER BY job_id LIMIT \?`).
+		WithArgs(sqlmock.AnyArg(), PipelineSchemeCode, "job-2", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-3"))
+
+	ctx := context.Background()
+	zombies, err := store.FindZombieTasksAfter(ctx, ZombieByUpdatedAt, 30*time.Minute, nil, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1", "job-2"}, zombies)
+	zombies, err = store.FindZombieTasksAfter(ctx, ZombieByUpdatedAt, 30*time.Minute, nil, "job-2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-3"}, zombies)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkZombieAsFailedInBoundedChunks(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	jobIDs := make([]string, 2*maxZombieMarkBatch+1)
+	for i := range jobIDs {
+		jobIDs[i] = fmt.Sprintf("job-%d", i)
+	}
+	// Each chunk carries at most maxZombieMarkBatch IDs, plus the two
+	// timestamps in its UPDATE
+	for _, n := range []int{maxZombieMarkBatch, maxZombieMarkBatch, 1} {
+		ids := make([]driver.Value, n)
+		for i := range ids {
+			ids[i] = sqlmock.AnyArg()
+		}
+		rows := sqlmock.NewRows([]string{"job_id"})
+		for i := 0; i < n; i++ {
+			rows.AddRow(fmt.Sprintf("running-%d", i))
+		}
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE job_id IN \(.+\) AND status = 'RUNNING' FOR UPDATE`).
+			WithArgs(ids...).
+			WillReturnRows(rows)
+		mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+			WithArgs(append([]driver.Value{sqlmock.AnyArg(), sqlmock.AnyArg()}, ids...)...).
+			WillReturnResult(sqlmock.NewResult(0, int64(n)))
+		mock.ExpectCommit()
+	}
+
+	failed, err := store.MarkZombieAsFailed(context.Background(), jobIDs)
+	require.NoError(t, err)
+	assert.Len(t, failed, len(jobIDs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkZombieAsFailedSkipsFinishedJobs(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	// job-2 reported its result after the zombie scan found it
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT job_id FROM t_algo_jobs WHERE job_id IN \(\?, \?, \?\) AND status = 'RUNNING' FOR UPDATE`).
+		WithArgs("job-1", "job-2", "job-3").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("job-1").AddRow("job-3"))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET status = 'FAILED'`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "job-1", "job-3").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	failed, err := store.MarkZombieAsFailed(context.Background(), []string{"job-1", "job-2", "job-3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1", "job-3"}, failed)
+
+	// Nothing is updated when every job finished
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).WithArgs("job-2").WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+	mock.ExpectCommit()
+	failed, err = store.MarkZombieAsFailed(context.Background(), []string{"job-2"})
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// A job that heartbeats every minute at 40% for two hours: updated_at is
+// fresh but progress_updated_at is two hours old
+var (
+	heartbeatAt = time.Now().Add(-time.Minute)
+	progressAt  = time.Now().Add(-2 * time.Hour)
+)
+
+func TestFindZombieTasksByUpdatedAtMissesHeartbeatingJob(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectQuery(`WHERE status = 'RUNNING' AND updated_at < \? AND scheme_code <> \?`).
+		WithArgs(staleness{updatedAt: heartbeatAt, zombie: false}, PipelineSchemeCode).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}))
+
+	zombies, err := store.FindZombieTasks(context.Background(), ZombieByUpdatedAt, 30*time.Minute, nil)
+	require.NoError(t, err)
+	assert.Empty(t, zombies)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindZombieTasksByProgressCatchesHeartbeatingJob(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	mock.ExpectQuery(`WHERE status = 'RUNNING' AND COALESCE\(progress_updated_at, updated_at\) < \? AND scheme_code <> \?`).
+		WithArgs(staleness{updatedAt: progressAt, zombie: true}, PipelineSchemeCode).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow("stuck-job"))
+
+	zombies, err := store.FindZombieTasks(context.Background(), ZombieByProgress, 30*time.Minute, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stuck-job"}, zombies)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProgressTracksProgressSeparately(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	// progress_updated_at is assigned before progress so it compares against the old value
+	mock.ExpectExec(`SET progress_updated_at = IF\(progress = \? AND progress_updated_at IS NOT NULL, progress_updated_at, \?\),\s+progress = \?, status = 'RUNNING', updated_at = \?`).
+		WithArgs(40, approxTime{time.Now(), time.Second}, 40, approxTime{time.Now(), time.Second}, "job-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.UpdateProgress(context.Background(), "job-1", 40, "heartbeat"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInitSchemaSkipsAppliedDataMigrations(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	for range schemaStatements {
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	for _, m := range columnMigrations {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.COLUMNS`).
+			WithArgs(m.table, m.column).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	}
+	for _, m := range dataMigrations {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM t_schema_migrations`).
+			WithArgs(m.name).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	}
+
+	require.NoError(t, store.InitSchema(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParamsRoundTripAsObject(t *testing.T) {
+	store, mock := newMockStore(t)
+	ctx := context.Background()
+
+	// A double-encoded object is written as the object itself
+	mock.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs("job-1", "KBM-WF01", "u1", "d", `{"threshold":0.9}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.InsertJob(ctx, "job-1", "KBM-WF01", "u1", "d", `"{\"threshold\":0.9}"`))
+
+	mock.ExpectExec(`INSERT INTO t_algo_jobs`).
+		WithArgs("job-2", "KBM-WF01", "u1", "d", `{}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.InsertJob(ctx, "job-2", "KBM-WF01", "u1", "d", "null"))
+
+	// Legacy rows still holding a quoted string read back as an object
+	for _, stored := range []string{`{"threshold":0.9}`, `"{\"threshold\":0.9}"`} {
+		mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+			WithArgs("job-1").
+			WillReturnRows(sqlmock.NewRows(jobColumns()).
+				AddRow("job-1", "KBM-WF01", "u1", "SUCCESS", 100, "d", stored, "", "", time.Now(), time.Now()))
+		job, err := store.GetJobTyped(ctx, "job-1")
+		require.NoError(t, err)
+		assertParamsObject(t, job)
+
+		mock.ExpectQuery(`FROM t_algo_jobs WHERE job_id = \?`).
+			WithArgs("job-1").
+			WillReturnRows(sqlmock.NewRows([]string{"job_id", "params"}).AddRow("job-1", []byte(stored)))
+		raw, err := store.GetJob(ctx, "job-1")
+		require.NoError(t, err)
+		assert.Equal(t, "job-1", raw["job_id"])
+		assertParamsObject(t, raw)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// assertParamsObject marshals v and checks its params field decodes as an object
+func assertParamsObject(t *testing.T, v any) {
+	t.Helper()
+	body, err := json.Marshal(v)
+	require.NoError(t, err)
+	var decoded struct {
+		Params map[string]any `json:"params"`
+	}
+	require.NoError(t, json.Unmarshal(body, &decoded), string(body))
+	assert.Equal(t, map[string]any{"threshold": 0.9}, decoded.Params)
+}
+
+// TestGetDurationHistogram tests bucketing and percentiles of job durations
+func TestGetDurationHistogram(t *testing.T) {
+	store, mock := newMockStore(t)
+
+	// 100 jobs: 1..90 seconds, plus ten long GPU runs of 1000..1900 seconds
+	rows := sqlmock.NewRows([]string{"duration"})
+	for i := 1; i <= 90; i++ {
+		rows.AddRow(i)
+	}
+	for i := 0; i < 10; i++ {
+		rows.AddRow(1000 + 100*i)
+	}
+	mock.ExpectQuery(`SELECT TIMESTAMPDIFF\(SECOND, created_at, finished_at\) FROM t_algo_jobs WHERE deleted_at IS NULL AND scheme_code = \? AND status = 'SUCCESS' AND finished_at IS NOT NULL\s+ORDER BY finished_at DESC LIMIT \?`).
+		WithArgs("SCM-WF01", 1000).
+		WillReturnRows(rows)
+
+	hist, err := store.GetDurationHistogram(context.Background(), JobFilter{}, "SCM-WF01", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 100, hist.Count)
+	assert.False(t, hist.Truncated)
+	assert.Equal(t, 50.0, hist.P50)
+	assert.Equal(t, 1400.0, hist.P95)
+	assert.Equal(t, 1800.0, hist.P99)
+
+	counts := map[string]int{}
+	for _, b := range hist.Buckets {
+		key := "inf"
+		if b.LeSeconds != nil {
+			key = fmt.Sprint(*b.LeSeconds)
+		}
+		counts[key] = b.Count
+	}
+	assert.Equal(t, map[string]int{
+		"1": 1, "5": 4, "10": 5, "30": 20, "60": 30, "120": 30, "300": 0,
+		"600": 0, "1800": 9, "3600": 1, "7200": 0, "14400": 0, "inf": 0,
+	}, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Hitting the row cap marks the histogram truncated
+	mock.ExpectQuery(`SELECT TIMESTAMPDIFF`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"duration"}).AddRow(3).AddRow(20000))
+	hist, err = store.GetDurationHistogram(context.Background(), JobFilter{}, "", 2)
+	require.NoError(t, err)
+	assert.True(t, hist.Truncated)
+	assert.Equal(t, 1, hist.Buckets[len(hist.Buckets)-1].Count)
+}
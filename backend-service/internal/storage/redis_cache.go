@@ -3,6 +3,10 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -10,13 +14,48 @@ import (
 
 type RedisCache struct {
 	client *redis.Client
+	// keyPrefix namespaces every key and pub/sub channel
+	keyPrefix string
+
+	statusMu sync.RWMutex
+	status   RedisStatus
+
+	monitorOnce sync.Once
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// RedisStatus is the connectivity of the cache as last checked
+type RedisStatus struct {
+	Available bool
+	// LastError is why the last check failed, empty while available
+	LastError string
+	// CheckedAt is zero until the first check
+	CheckedAt time.Time
+	// Since is when the cache last became available or unavailable
+	Since time.Time
+}
+
+// RedisCacheConfig addresses a Redis database
+type RedisCacheConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// KeyPrefix is prepended to every key and pub/sub channel, so
+	// environments sharing one Redis instance do not collide
+	KeyPrefix string
 }
 
 func NewRedisCache(addr, password string, db int) *RedisCache {
+	return NewRedisCacheWithConfig(RedisCacheConfig{Addr: addr, Password: password, DB: db})
+}
+
+// NewRedisCacheWithConfig creates a cache with custom configuration
+func NewRedisCacheWithConfig(cfg RedisCacheConfig) *RedisCache {
 	client := redis.NewClient(&redis.Options{
-		Addr:         addr,
-		Password:     password,
-		DB:           db,
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
 		PoolSize:     100,           // Connection pool for high concurrency
 		MinIdleConns: 10,
 		MaxRetries:   3,
@@ -24,53 +63,254 @@ func NewRedisCache(addr, password string, db int) *RedisCache {
 		ReadTimeout:  3 * time.Second,
 		WriteTimeout: 3 * time.Second,
 	})
-	return &RedisCache{client: client}
+	return &RedisCache{
+		client:    client,
+		keyPrefix: cfg.KeyPrefix,
+		status:    RedisStatus{Available: true, Since: time.Now()},
+		stop:      make(chan struct{}),
+	}
+}
+
+// key namespaces a key or channel with the cache's prefix
+func (r *RedisCache) key(k string) string {
+	return r.keyPrefix + k
 }
 
 func (r *RedisCache) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
+// RoundTrip writes a short-lived key, reads it back and deletes it, proving
+// the configured database accepts writes
+func (r *RedisCache) RoundTrip(ctx context.Context) error {
+	key := r.key(fmt.Sprintf("sys:selftest:%d", time.Now().UnixNano()))
+	want := key
+	if err := r.client.Set(ctx, key, want, time.Minute).Err(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	defer r.client.Del(context.Background(), key)
+
+	got, err := r.client.Get(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("read back %q, wrote %q", got, want)
+	}
+	return nil
+}
+
 func (r *RedisCache) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
 	return r.client.Close()
 }
 
+// CheckHealth pings Redis and records the outcome as the cache's status
+func (r *RedisCache) CheckHealth(ctx context.Context) error {
+	err := r.Ping(ctx)
+	now := time.Now()
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	if r.status.Available != (err == nil) {
+		r.status.Since = now
+	}
+	r.status.Available = err == nil
+	r.status.LastError = ""
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+	r.status.CheckedAt = now
+	return err
+}
+
+// MonitorHealth checks Redis every interval in the background until Close,
+// calling onChange (if set) whenever it becomes available or unavailable.
+// Only the first call starts a monitor; a non-positive interval starts none.
+func (r *RedisCache) MonitorHealth(interval time.Duration, onChange func(RedisStatus)) {
+	if interval <= 0 {
+		return
+	}
+	r.monitorOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-r.stop:
+					return
+				case <-ticker.C:
+				}
+				was := r.IsAvailable()
+				ctx, cancel := context.WithTimeout(context.Background(), min(interval, 2*time.Second))
+				err := r.CheckHealth(ctx)
+				cancel()
+				if onChange != nil && was != (err == nil) {
+					onChange(r.Status())
+				}
+			}
+		}()
+	})
+}
+
+// IsAvailable reports whether the last check reached Redis; a cache that
+// was never checked is assumed available
+func (r *RedisCache) IsAvailable() bool {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+	return r.status.Available
+}
+
+// Status returns the outcome of the last check
+func (r *RedisCache) Status() RedisStatus {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
+	return r.status
+}
+
 func (r *RedisCache) SetJSON(ctx context.Context, key string, value any, ttl time.Duration) error {
 	payload, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, key, payload, ttl).Err()
+	return r.client.Set(ctx, r.key(key), payload, ttl).Err()
 }
 
 func (r *RedisCache) GetJSON(ctx context.Context, key string, out any) error {
-	payload, err := r.client.Get(ctx, key).Bytes()
+	payload, err := r.client.Get(ctx, r.key(key)).Bytes()
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal(payload, out)
 }
 
+// GetBytes returns a key's raw value
+func (r *RedisCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	return r.client.Get(ctx, r.key(key)).Bytes()
+}
+
+// compareAndSwapScript replaces a key's value only while it still holds ARGV[1]
+var compareAndSwapScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+  return 1
+end
+return 0
+`)
+
+// CompareAndSwapJSON stores value under key if the key still holds old, as
+// returned by GetBytes, reporting whether it did. Of concurrent callers
+// swapping the same old value, only one succeeds.
+func (r *RedisCache) CompareAndSwapJSON(ctx context.Context, key string, old []byte, value any, ttl time.Duration) (bool, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	n, err := compareAndSwapScript.Run(ctx, r.client, []string{r.key(key)}, old, payload, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
 func (r *RedisCache) Delete(ctx context.Context, key string) error {
-	return r.client.Del(ctx, key).Err()
+	return r.client.Del(ctx, r.key(key)).Err()
 }
 
 // Incr atomically increments a key and sets expiry if it's new
 func (r *RedisCache) Incr(ctx context.Context, key string, ttl time.Duration) error {
 	pipe := r.client.Pipeline()
-	pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, ttl)
+	pipe.Incr(ctx, r.key(key))
+	pipe.Expire(ctx, r.key(key), ttl)
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
+// incrWindowScript increments a counter, starting its expiry on the first
+// increment only, and returns the new count with the milliseconds left
+var incrWindowScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {n, redis.call('PTTL', KEYS[1])}
+`)
+
+// IncrWindow atomically counts one more event in a fixed window that starts
+// with the first event, returning the count so far and the time until the
+// window resets
+func (r *RedisCache) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	res, err := incrWindowScript.Run(ctx, r.client, []string{r.key(key)}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return res[0], time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// takeTokenScript takes a token from a bucket refilled at ARGV[1] tokens per
+// second up to ARGV[2] tokens, at time ARGV[3] (Unix milliseconds). It
+// returns whether a token was taken, the whole tokens left and the
+// milliseconds until the next one.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1]) / 1000
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local taken = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  taken = 1
+end
+local wait = 0
+if tokens < 1 then
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.6f', tokens), 'ts', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return {taken, math.floor(tokens), wait}
+`)
+
+// TakeToken takes a token from the bucket at key, which holds up to burst
+// tokens and refills at rps tokens per second. It reports whether a token
+// was taken, how many whole tokens are left and how long until the next one.
+// Replicas sharing the bucket should have reasonably synchronized clocks.
+func (r *RedisCache) TakeToken(ctx context.Context, key string, rps float64, burst int) (bool, int, time.Duration, error) {
+	// Plain decimal notation, as not every Lua accepts exponents in tonumber
+	rate := strconv.FormatFloat(rps, 'f', -1, 64)
+	res, err := takeTokenScript.Run(ctx, r.client, []string{r.key(key)}, rate, burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return res[0] == 1, int(res[1]), time.Duration(res[2]) * time.Millisecond, nil
+}
+
 // SetNX sets a key only if it doesn't exist (for distributed locking)
 func (r *RedisCache) SetNX(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
 	payload, err := json.Marshal(value)
 	if err != nil {
 		return false, err
 	}
-	return r.client.SetNX(ctx, key, payload, ttl).Result()
+	return r.client.SetNX(ctx, r.key(key), payload, ttl).Result()
+}
+
+// SAdd adds members to a set and (re)starts its expiry; a non-positive ttl
+// leaves the expiry as is
+func (r *RedisCache) SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(ctx, r.key(key), members)
+	if ttl > 0 {
+		pipe.Expire(ctx, r.key(key), ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SPop removes and returns a random member of a set, redis.Nil when it is empty
+func (r *RedisCache) SPop(ctx context.Context, key string) (string, error) {
+	return r.client.SPop(ctx, r.key(key)).Result()
 }
 
 func (r *RedisCache) Publish(ctx context.Context, channel string, payload any) error {
@@ -78,16 +318,33 @@ func (r *RedisCache) Publish(ctx context.Context, channel string, payload any) e
 	if err != nil {
 		return err
 	}
-	return r.client.Publish(ctx, channel, b).Err()
+	return r.client.Publish(ctx, r.key(channel), b).Err()
 }
 
-// Subscribe subscribes to a channel and returns a channel for messages
+// XAdd appends an entry with values to a stream, trimming it to about
+// maxLen entries when maxLen is positive, and returns the entry ID
+func (r *RedisCache) XAdd(ctx context.Context, stream string, maxLen int64, values map[string]any) (string, error) {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.key(stream),
+		MaxLen: maxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+}
+
+// Subscribe subscribes to a channel and returns a channel for messages;
+// their Channel field carries the prefixed channel name
 func (r *RedisCache) Subscribe(ctx context.Context, channel string) (<-chan *redis.Message, func()) {
-	sub := r.client.Subscribe(ctx, channel)
+	sub := r.client.Subscribe(ctx, r.key(channel))
 	return sub.Channel(), func() { _ = sub.Close() }
 }
 
-// Keys returns all keys matching a pattern (use sparingly)
+// Keys returns all keys matching a pattern (use sparingly), without the
+// cache's prefix
 func (r *RedisCache) Keys(ctx context.Context, pattern string) ([]string, error) {
-	return r.client.Keys(ctx, pattern).Result()
+	keys, err := r.client.Keys(ctx, r.key(pattern)).Result()
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, r.keyPrefix)
+	}
+	return keys, err
 }
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRedisCacheHealthMonitor tests that the monitor notices Redis going
+// down and coming back
+func TestRedisCacheHealthMonitor(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	cache := NewRedisCache(addr, "", 0)
+	t.Cleanup(func() { _ = cache.Close() })
+
+	assert.True(t, cache.IsAvailable())
+	assert.True(t, cache.Status().CheckedAt.IsZero())
+	require.NoError(t, cache.CheckHealth(context.Background()))
+	up := cache.Status()
+	assert.True(t, up.Available)
+	assert.False(t, up.CheckedAt.IsZero())
+
+	changes := make(chan RedisStatus, 4)
+	cache.MonitorHealth(20*time.Millisecond, func(st RedisStatus) { changes <- st })
+
+	mr.Close()
+	select {
+	case st := <-changes:
+		assert.False(t, st.Available)
+		assert.NotEmpty(t, st.LastError)
+		assert.True(t, st.Since.After(up.Since))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Redis going down was not noticed")
+	}
+	assert.False(t, cache.IsAvailable())
+
+	require.NoError(t, mr.StartAddr(addr))
+	select {
+	case st := <-changes:
+		assert.True(t, st.Available)
+		assert.Empty(t, st.LastError)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Redis coming back was not noticed")
+	}
+	assert.True(t, cache.IsAvailable())
+}
+
+// TestRedisCacheKeyPrefix tests that prefixed caches sharing one Redis keep
+// their keys, locks, counters and channels apart
+func TestRedisCacheKeyPrefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newCache := func(prefix string) *RedisCache {
+		cache := NewRedisCacheWithConfig(RedisCacheConfig{Addr: mr.Addr(), KeyPrefix: prefix})
+		t.Cleanup(func() { _ = cache.Close() })
+		return cache
+	}
+	dev, staging := newCache("dev:"), newCache("staging:")
+	ctx := context.Background()
+
+	require.NoError(t, dev.SetJSON(ctx, "sys:algo:schemes", []string{"KBM-WF01"}, time.Minute))
+	require.NoError(t, staging.SetJSON(ctx, "sys:algo:schemes", []string{"SCM-WF01"}, time.Minute))
+	var schemes []string
+	require.NoError(t, dev.GetJSON(ctx, "sys:algo:schemes", &schemes))
+	assert.Equal(t, []string{"KBM-WF01"}, schemes)
+	assert.ElementsMatch(t, []string{"dev:sys:algo:schemes", "staging:sys:algo:schemes"}, mr.Keys())
+
+	keys, err := staging.Keys(ctx, "sys:*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sys:algo:schemes"}, keys)
+
+	for _, cache := range []*RedisCache{dev, staging} {
+		claimed, err := cache.SetNX(ctx, "job:result-seen:task-1", 1, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+		count, _, err := cache.IncrWindow(ctx, "ratelimit:user-1", time.Minute)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+	}
+
+	msgs, unsubscribe := dev.Subscribe(ctx, "job:progress:job-1")
+	defer unsubscribe()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, staging.Publish(ctx, "job:progress:job-1", "staging"))
+	require.NoError(t, dev.Publish(ctx, "job:progress:job-1", "dev"))
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, `"dev"`, msg.Payload)
+		assert.Equal(t, "dev:job:progress:job-1", msg.Channel)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message on the prefixed channel")
+	}
+}
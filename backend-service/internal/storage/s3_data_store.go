@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3DataStoreConfig addresses a bucket of an S3-compatible object store
+type S3DataStoreConfig struct {
+	// Endpoint is the base URL of the object store, e.g. http://minio:9000;
+	// objects are addressed path-style as Endpoint/Bucket/key
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Timeout bounds each upload
+	Timeout time.Duration
+}
+
+// DefaultS3DataStoreConfig returns the default region and timeout
+func DefaultS3DataStoreConfig() S3DataStoreConfig {
+	return S3DataStoreConfig{
+		Region:  "us-east-1",
+		Timeout: 5 * time.Minute,
+	}
+}
+
+// S3DataStore writes data files to an S3-compatible bucket the algorithm
+// service reads from; data_refs are s3://bucket/key URLs
+type S3DataStore struct {
+	cfg      S3DataStoreConfig
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3DataStore checks the endpoint and bucket of cfg
+func NewS3DataStore(cfg S3DataStoreConfig) (*S3DataStore, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is not set")
+	}
+	defaults := DefaultS3DataStoreConfig()
+	if cfg.Region == "" {
+		cfg.Region = defaults.Region
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	return &S3DataStore{cfg: cfg, endpoint: endpoint, client: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+// Put uploads data as the object name in the bucket
+func (s *S3DataStore) Put(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	if name == "" || strings.Contains(name, "/") || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid data file name %q", name)
+	}
+
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.cfg.Bucket + "/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	signS3Request(req, "UNSIGNED-PAYLOAD", s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("S3 upload of %q returned %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return (&url.URL{Scheme: "s3", Host: s.cfg.Bucket, Path: "/" + name}).String(), nil
+}
+
+// signS3Request signs req with AWS Signature Version 4, covering the host
+// and every header already set on req
+func signS3Request(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SLA states of a job with a deadline; a job without one, or still well
+// within it, has no state
+const (
+	SLAAtRisk   = "AT_RISK"
+	SLABreached = "BREACHED"
+)
+
+// SLAJob is an active job flagged against its SLA deadline
+type SLAJob struct {
+	JobID       string    `db:"job_id" json:"job_id"`
+	SchemeCode  string    `db:"scheme_code" json:"scheme_code"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	Status      string    `db:"status" json:"status"`
+	Progress    int       `db:"progress" json:"progress"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	SLADeadline time.Time `db:"sla_deadline" json:"sla_deadline"`
+	SLAState    string    `db:"sla_state" json:"sla_state"`
+}
+
+// SLAState classifies a deadline at now: BREACHED once it has passed,
+// AT_RISK when it is less than warnWindow away, and "" otherwise
+func SLAState(deadline, now time.Time, warnWindow time.Duration) string {
+	switch {
+	case !deadline.After(now):
+		return SLABreached
+	case deadline.Before(now.Add(warnWindow)):
+		return SLAAtRisk
+	}
+	return ""
+}
+
+// SetSLADeadline records the time by which a job is expected to finish
+func (s *MySQLStore) SetSLADeadline(ctx context.Context, jobID string, deadline time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE t_algo_jobs SET sla_deadline = ? WHERE job_id = ?`, deadline, jobID)
+	return err
+}
+
+// FlagSLAJobs moves PENDING and RUNNING jobs whose deadline is less than
+// warnWindow away to AT_RISK, and those whose deadline has passed to
+// BREACHED. Only jobs whose state changed are returned, so each is reported
+// once per state. The jobs themselves are left running.
+func (s *MySQLStore) FlagSLAJobs(ctx context.Context, now time.Time, warnWindow time.Duration) ([]SLAJob, error) {
+	var candidates []SLAJob
+	err := s.selectRead(ctx, &candidates, `
+SELECT job_id, scheme_code, COALESCE(user_id, '') AS user_id, status, progress, created_at, sla_deadline, COALESCE(sla_state, '') AS sla_state
+FROM t_algo_jobs WHERE status IN ('PENDING', 'RUNNING') AND sla_deadline IS NOT NULL AND sla_deadline < ?
+AND (sla_state IS NULL OR (sla_state = ? AND sla_deadline <= ?))
+ORDER BY sla_deadline ASC`, now.Add(warnWindow), SLAAtRisk, now)
+	if err != nil {
+		return nil, err
+	}
+
+	flagged := candidates[:0]
+	byState := make(map[string][]string)
+	for _, job := range candidates {
+		state := SLAState(job.SLADeadline, now, warnWindow)
+		if state == "" || state == job.SLAState {
+			continue
+		}
+		job.SLAState = state
+		flagged = append(flagged, job)
+		byState[state] = append(byState[state], job.JobID)
+	}
+	for _, state := range []string{SLAAtRisk, SLABreached} {
+		if len(byState[state]) == 0 {
+			continue
+		}
+		query, args, err := sqlx.In(`UPDATE t_algo_jobs SET sla_state = ? WHERE job_id IN (?)`, state, byState[state])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.db.ExecContext(ctx, s.db.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+	}
+	return flagged, nil
+}
+
+// ListSLAJobs returns the PENDING and RUNNING jobs flagged with state, or
+// with either state when it is empty, closest deadline first
+func (s *MySQLStore) ListSLAJobs(ctx context.Context, userID, state string, limit int) ([]SLAJob, error) {
+	query := `
+SELECT job_id, scheme_code, COALESCE(user_id, '') AS user_id, status, progress, created_at, sla_deadline, sla_state
+FROM t_algo_jobs WHERE status IN ('PENDING', 'RUNNING') AND sla_state IS NOT NULL`
+	var args []any
+	if state != "" {
+		query += " AND sla_state = ?"
+		args = append(args, state)
+	}
+	if userID != "" {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY sla_deadline ASC LIMIT ?"
+	args = append(args, limit)
+
+	jobs := []SLAJob{}
+	err := s.selectRead(ctx, &jobs, query, args...)
+	return jobs, err
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var slaColumns = []string{"job_id", "scheme_code", "user_id", "status", "progress", "created_at", "sla_deadline", "sla_state"}
+
+func TestSLAState(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	window := 15 * time.Minute
+
+	assert.Equal(t, "", SLAState(now.Add(time.Hour), now, window), "well within the SLA")
+	assert.Equal(t, "", SLAState(now.Add(window), now, window), "exactly the warn window away")
+	assert.Equal(t, SLAAtRisk, SLAState(now.Add(10*time.Minute), now, window), "approaching the SLA")
+	assert.Equal(t, SLABreached, SLAState(now, now, window), "deadline reached")
+	assert.Equal(t, SLABreached, SLAState(now.Add(-time.Minute), now, window), "past the SLA")
+}
+
+func TestFlagSLAJobs(t *testing.T) {
+	store, mock := newMockStore(t)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	window := 15 * time.Minute
+
+	// The query only returns jobs inside the warn window; jobs well within
+	// their SLA are never candidates
+	mock.ExpectQuery(`SELECT job_id, scheme_code, .* FROM t_algo_jobs WHERE status IN \('PENDING', 'RUNNING'\) AND sla_deadline IS NOT NULL`).
+		WithArgs(now.Add(window), SLAAtRisk, now).
+		WillReturnRows(sqlmock.NewRows(slaColumns).
+			AddRow("job-late", "KBM-WF01", "u1", "RUNNING", 80, now.Add(-time.Hour), now.Add(-time.Minute), "AT_RISK").
+			AddRow("job-later", "KBM-WF01", "u1", "PENDING", 0, now.Add(-time.Hour), now.Add(-time.Second), "").
+			AddRow("job-soon", "KBM-WF01", "u2", "RUNNING", 40, now.Add(-time.Hour), now.Add(5*time.Minute), ""))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET sla_state = \? WHERE job_id IN \(\?\)`).
+		WithArgs(SLAAtRisk, "job-soon").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_algo_jobs SET sla_state = \? WHERE job_id IN \(\?, \?\)`).
+		WithArgs(SLABreached, "job-late", "job-later").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	flagged, err := store.FlagSLAJobs(context.Background(), now, window)
+	require.NoError(t, err)
+	require.Len(t, flagged, 3)
+	states := map[string]string{}
+	for _, job := range flagged {
+		states[job.JobID] = job.SLAState
+	}
+	assert.Equal(t, map[string]string{"job-late": SLABreached, "job-later": SLABreached, "job-soon": SLAAtRisk}, states)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFlagSLAJobsNothingDue(t *testing.T) {
+	store, mock := newMockStore(t)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT job_id, scheme_code`).WillReturnRows(sqlmock.NewRows(slaColumns))
+	flagged, err := store.FlagSLAJobs(context.Background(), now, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, flagged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListSLAJobs(t *testing.T) {
+	store, mock := newMockStore(t)
+	now := time.Now()
+
+	mock.ExpectQuery(`FROM t_algo_jobs WHERE status IN \('PENDING', 'RUNNING'\) AND sla_state IS NOT NULL AND sla_state = \? AND user_id = \? ORDER BY sla_deadline ASC LIMIT \?`).
+		WithArgs(SLABreached, "u1", 50).
+		WillReturnRows(sqlmock.NewRows(slaColumns).
+			AddRow("job-late", "KBM-WF01", "u1", "RUNNING", 80, now.Add(-time.Hour), now.Add(-time.Minute), SLABreached))
+
+	jobs, err := store.ListSLAJobs(context.Background(), "u1", SLABreached, 50)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "job-late", jobs[0].JobID)
+	assert.Equal(t, SLABreached, jobs[0].SLAState)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,7 +3,10 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,6 +24,14 @@ const (
 	maxMessageSize = 1024 * 1024 // 1MB
 )
 
+// CloseReconnect is the close code sent when a connection reaches its maximum
+// lifetime; clients should reconnect, possibly landing on another replica
+const CloseReconnect = websocket.CloseServiceRestart
+
+// CloseJobEnded is the close code sent by CloseJob: the job is over and
+// clients should not reconnect to it
+const CloseJobEnded = 4000
+
 // Client represents a WebSocket connection
 type Client struct {
 	hub      *Hub
@@ -28,38 +39,132 @@ type Client struct {
 	send     chan []byte
 	jobID    string
 	userID   string
-	lastPing time.Time
+	lastPing atomic.Int64 // unix nanoseconds of the last pong or client message
+	// closeMsg, when set before send is closed, is the close frame writePump sends
+	closeMsg []byte
+	// fresh is set once a broadcast has been queued for the client
+	fresh atomic.Bool
+}
+
+// touch records that the peer is alive
+func (c *Client) touch() {
+	c.lastPing.Store(time.Now().UnixNano())
+}
+
+// HubConfig holds hub configuration
+type HubConfig struct {
+	// HeartbeatInterval is how often every client receives an application-level
+	// heartbeat message; 0 disables heartbeats
+	HeartbeatInterval time.Duration
+	// MaxConnectionLifetime closes connections with CloseReconnect once they
+	// have been open this long; 0 keeps connections open indefinitely
+	MaxConnectionLifetime time.Duration
+	// MaxClientsPerJob and MaxClients cap the connections per job and in
+	// total; connections beyond them are told to reconnect later (0 means no cap)
+	MaxClientsPerJob int
+	MaxClients       int
+	// ReconnectBackoff is the base delay suggested to clients told to
+	// reconnect; each hint adds up to the same again as jitter
+	ReconnectBackoff time.Duration
+}
+
+// DefaultHubConfig returns the default hub configuration
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		HeartbeatInterval: 30 * time.Second,
+		ReconnectBackoff:  2 * time.Second,
+	}
+}
+
+var (
+	// ErrHubFull is returned by Subscribe when a connection limit is reached
+	ErrHubFull = errors.New("websocket connection limit reached")
+	// ErrHubClosed is returned by Subscribe once the hub is shutting down
+	ErrHubClosed = errors.New("websocket hub is shutting down")
+)
+
+// ReconnectMsg tells a client to reconnect after backoff_ms. It is sent when
+// a connection is refused for capacity and when the server shuts down, so
+// clients spread their reconnects instead of retrying all at once.
+type ReconnectMsg struct {
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	BackoffMs int64  `json:"backoff_ms"`
+}
+
+// reconnectHint builds a reconnect message with a jittered backoff
+func (h *Hub) reconnectHint(reason string) []byte {
+	backoff := h.cfg.ReconnectBackoff
+	if backoff <= 0 {
+		backoff = DefaultHubConfig().ReconnectBackoff
+	}
+	backoff += time.Duration(rand.Int63n(int64(backoff)))
+	payload, _ := json.Marshal(ReconnectMsg{Type: "reconnect", Reason: reason, BackoffMs: backoff.Milliseconds()})
+	return payload
+}
+
+// heartbeatMsg is the application-level heartbeat; server_time is in unix milliseconds
+type heartbeatMsg struct {
+	Type       string `json:"type"`
+	ServerTime int64  `json:"server_time"`
 }
 
 // Hub maintains active WebSocket connections and broadcasts messages
 type Hub struct {
-	mu       sync.RWMutex
-	clients  map[string]map[*Client]struct{}
-	register chan *Client
-	remove   chan *Client
-	logger   *zap.Logger
-	ctx      context.Context
-	cancel   context.CancelFunc
+	mu      sync.RWMutex
+	clients map[string]map[*Client]struct{}
+	remove  chan *Client
+	logger  *zap.Logger
+	cfg     HubConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// topicObserver is told when a topic gains its first or loses its last client
+	topicObserver func(topic string, active bool)
+	// replaySource looks up the message replayed to new job subscribers
+	replaySource ReplayFunc
+}
+
+// SetTopicObserver registers fn to be called, outside the hub lock, when a
+// topic gains its first local client (active) or loses its last one
+func (h *Hub) SetTopicObserver(fn func(topic string, active bool)) {
+	h.mu.Lock()
+	h.topicObserver = fn
+	h.mu.Unlock()
+}
+
+// notifyTopics reports topic changes collected under the lock to observer
+func notifyTopics(observer func(string, bool), topics []string, active bool) {
+	if observer == nil {
+		return
+	}
+	for _, topic := range topics {
+		observer(topic, active)
+	}
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
-	ctx, cancel := context.WithCancel(context.Background())
-	h := &Hub{
-		clients:  make(map[string]map[*Client]struct{}),
-		register: make(chan *Client, 100),
-		remove:   make(chan *Client, 100),
-		ctx:      ctx,
-		cancel:   cancel,
-	}
-	go h.run()
-	return h
+	return NewHubWithConfig(DefaultHubConfig(), nil)
 }
 
 // NewHubWithLogger creates a hub with structured logging
 func NewHubWithLogger(logger *zap.Logger) *Hub {
-	h := NewHub()
-	h.logger = logger
+	return NewHubWithConfig(DefaultHubConfig(), logger)
+}
+
+// NewHubWithConfig creates a hub with custom configuration
+func NewHubWithConfig(cfg HubConfig, logger *zap.Logger) *Hub {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hub{
+		clients: make(map[string]map[*Client]struct{}),
+		remove:  make(chan *Client, 100),
+		logger:  logger,
+		cfg:     cfg,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go h.run()
 	return h
 }
 
@@ -67,25 +172,24 @@ func (h *Hub) run() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	var heartbeat <-chan time.Time
+	if h.cfg.HeartbeatInterval > 0 {
+		heartbeatTicker := time.NewTicker(h.cfg.HeartbeatInterval)
+		defer heartbeatTicker.Stop()
+		heartbeat = heartbeatTicker.C
+	}
+
 	for {
 		select {
 		case <-h.ctx.Done():
 			return
-		case client := <-h.register:
-			h.mu.Lock()
-			if h.clients[client.jobID] == nil {
-				h.clients[client.jobID] = make(map[*Client]struct{})
-			}
-			h.clients[client.jobID][client] = struct{}{}
-			h.mu.Unlock()
 
-			if h.logger != nil {
-				h.logger.Info("WebSocket client connected",
-					zap.String("job_id", client.jobID),
-					zap.String("user_id", client.userID))
-			}
+		case now := <-heartbeat:
+			payload, _ := json.Marshal(heartbeatMsg{Type: "heartbeat", ServerTime: now.UnixMilli()})
+			h.BroadcastAll(payload)
 
 		case client := <-h.remove:
+			var emptied []string
 			h.mu.Lock()
 			if clients, ok := h.clients[client.jobID]; ok {
 				if _, ok := clients[client]; ok {
@@ -94,9 +198,12 @@ func (h *Hub) run() {
 				}
 				if len(clients) == 0 {
 					delete(h.clients, client.jobID)
+					emptied = append(emptied, client.jobID)
 				}
 			}
+			observer := h.topicObserver
 			h.mu.Unlock()
+			notifyTopics(observer, emptied, false)
 
 			if h.logger != nil {
 				h.logger.Info("WebSocket client disconnected",
@@ -111,51 +218,111 @@ func (h *Hub) run() {
 }
 
 func (h *Hub) cleanupStale() {
+	var emptied []string
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	now := time.Now()
 	for jobID, clients := range h.clients {
 		for client := range clients {
-			if now.Sub(client.lastPing) > pongWait*2 {
+			if now.Sub(time.Unix(0, client.lastPing.Load())) > pongWait*2 {
 				delete(clients, client)
 				close(client.send)
 			}
 		}
 		if len(clients) == 0 {
 			delete(h.clients, jobID)
+			emptied = append(emptied, jobID)
 		}
 	}
+	observer := h.topicObserver
+	h.mu.Unlock()
+	notifyTopics(observer, emptied, false)
 }
 
 // Subscribe registers a new client for a job ID (simple interface)
-func (h *Hub) Subscribe(jobID string, conn *websocket.Conn) {
-	h.SubscribeWithUser(jobID, "", conn)
+func (h *Hub) Subscribe(jobID string, conn *websocket.Conn) error {
+	return h.SubscribeWithUser(jobID, "", conn)
 }
 
-// SubscribeWithUser registers a client with user tracking
-func (h *Hub) SubscribeWithUser(jobID, userID string, conn *websocket.Conn) {
+// SubscribeWithUser registers a client with user tracking. When a connection
+// limit is reached or the hub is closing, the client is sent a reconnect hint
+// and closed instead, and ErrHubFull or ErrHubClosed is returned.
+// A registered client is first sent the hub's replay message, if any.
+func (h *Hub) SubscribeWithUser(jobID, userID string, conn *websocket.Conn) error {
 	client := &Client{
-		hub:      h,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		jobID:    jobID,
-		userID:   userID,
-		lastPing: time.Now(),
+		hub:    h,
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		jobID:  jobID,
+		userID: userID,
 	}
+	client.touch()
 
 	conn.SetReadLimit(maxMessageSize)
 	conn.SetReadDeadline(time.Now().Add(pongWait))
 	conn.SetPongHandler(func(string) error {
-		client.lastPing = time.Now()
+		client.touch()
 		conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 
-	h.register <- client
+	h.mu.Lock()
+	err := h.admitLocked(jobID)
+	first := false
+	if err == nil {
+		if h.clients[jobID] == nil {
+			h.clients[jobID] = make(map[*Client]struct{})
+			first = true
+		}
+		h.clients[jobID][client] = struct{}{}
+	}
+	observer := h.topicObserver
+	h.mu.Unlock()
+	if first {
+		notifyTopics(observer, []string{jobID}, true)
+	}
+
+	switch {
+	case errors.Is(err, ErrHubClosed):
+		_ = sendAndClose(conn, h.reconnectHint("server shutting down"), CloseReconnect, "server shutting down")
+		return err
+	case err != nil:
+		_ = sendAndClose(conn, h.reconnectHint(err.Error()), websocket.CloseTryAgainLater, err.Error())
+		if h.logger != nil {
+			h.logger.Warn("WebSocket client refused", zap.String("job_id", jobID), zap.Error(err))
+		}
+		return err
+	}
 
+	if h.logger != nil {
+		h.logger.Info("WebSocket client connected",
+			zap.String("job_id", jobID),
+			zap.String("user_id", userID))
+	}
+	h.replay(client)
 	go h.writePump(client)
 	go h.readPump(client)
+	return nil
+}
+
+// admitLocked checks a new connection to jobID against the hub's state and
+// limits; h.mu must be held
+func (h *Hub) admitLocked(jobID string) error {
+	if h.ctx.Err() != nil {
+		return ErrHubClosed
+	}
+	if h.cfg.MaxClientsPerJob > 0 && len(h.clients[jobID]) >= h.cfg.MaxClientsPerJob {
+		return ErrHubFull
+	}
+	if h.cfg.MaxClients > 0 {
+		total := 0
+		for _, clients := range h.clients {
+			total += len(clients)
+		}
+		if total >= h.cfg.MaxClients {
+			return ErrHubFull
+		}
+	}
+	return nil
 }
 
 func (h *Hub) readPump(client *Client) {
@@ -174,6 +341,9 @@ func (h *Hub) readPump(client *Client) {
 			}
 			return
 		}
+		// Any client message, e.g. a reply to a heartbeat, also keeps the connection alive
+		client.touch()
+		client.conn.SetReadDeadline(time.Now().Add(pongWait))
 	}
 }
 
@@ -184,12 +354,23 @@ func (h *Hub) writePump(client *Client) {
 		client.conn.Close()
 	}()
 
+	var expired <-chan time.Time
+	if h.cfg.MaxConnectionLifetime > 0 {
+		lifetime := time.NewTimer(h.cfg.MaxConnectionLifetime)
+		defer lifetime.Stop()
+		expired = lifetime.C
+	}
+
 	for {
 		select {
 		case message, ok := <-client.send:
 			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMsg := client.closeMsg
+				if closeMsg == nil && h.ctx.Err() != nil {
+					closeMsg = websocket.FormatCloseMessage(CloseReconnect, "server shutting down")
+				}
+				client.conn.WriteMessage(websocket.CloseMessage, closeMsg)
 				return
 			}
 
@@ -202,6 +383,41 @@ func (h *Hub) writePump(client *Client) {
 			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+
+		case <-expired:
+			client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			client.conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(CloseReconnect, "max connection lifetime reached, please reconnect"))
+			if h.logger != nil {
+				h.logger.Info("WebSocket connection reached max lifetime",
+					zap.String("job_id", client.jobID))
+			}
+			return
+		}
+	}
+}
+
+// SendFinal writes payload to a connection that is not registered with the
+// hub, then closes it normally with reason, waiting briefly for the peer to
+// acknowledge the close
+func SendFinal(conn *websocket.Conn, payload []byte, reason string) error {
+	return sendAndClose(conn, payload, websocket.CloseNormalClosure, reason)
+}
+
+// sendAndClose writes payload, then closes the connection with code and reason
+func sendAndClose(conn *websocket.Conn, payload []byte, code int, reason string) error {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason)); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return nil
 		}
 	}
 }
@@ -220,6 +436,7 @@ func (h *Hub) Broadcast(jobID string, payload []byte) {
 		for client := range clients {
 			select {
 			case client.send <- payload:
+				client.fresh.Store(true)
 			default:
 				// Channel full, client too slow
 				go func(c *Client) { h.remove <- c }(client)
@@ -253,6 +470,31 @@ func (h *Hub) BroadcastAll(payload []byte) {
 	}
 }
 
+// CloseJob removes every client of a job and closes their connections with
+// CloseJobEnded and reason. Messages already broadcast to them are written
+// first. It does not go through the run loop, so it is safe to call anywhere.
+func (h *Hub) CloseJob(jobID, reason string) {
+	h.mu.Lock()
+	clients := h.clients[jobID]
+	delete(h.clients, jobID)
+	for client := range clients {
+		client.closeMsg = websocket.FormatCloseMessage(CloseJobEnded, reason)
+		close(client.send)
+	}
+	observer := h.topicObserver
+	h.mu.Unlock()
+	if len(clients) > 0 {
+		notifyTopics(observer, []string{jobID}, false)
+	}
+
+	if h.logger != nil && len(clients) > 0 {
+		h.logger.Info("WebSocket job closed",
+			zap.String("job_id", jobID),
+			zap.Int("clients", len(clients)),
+			zap.String("reason", reason))
+	}
+}
+
 // GetClientCount returns the number of connected clients for a job
 func (h *Hub) GetClientCount(jobID string) int {
 	h.mu.RLock()
@@ -276,7 +518,8 @@ func (h *Hub) GetTotalClients() int {
 	return total
 }
 
-// Close shuts down the hub gracefully
+// Close shuts down the hub gracefully. Every client is sent a reconnect hint,
+// each with its own jitter, before its connection is closed.
 func (h *Hub) Close() {
 	h.cancel()
 	h.mu.Lock()
@@ -284,6 +527,10 @@ func (h *Hub) Close() {
 
 	for _, clients := range h.clients {
 		for client := range clients {
+			select {
+			case client.send <- h.reconnectHint("server shutting down"):
+			default:
+			}
 			close(client.send)
 		}
 	}
@@ -0,0 +1,255 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialHub serves the hub over a test server and returns a client connection subscribed to jobID
+func dialHub(t *testing.T, h *Hub, jobID string) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		h.Subscribe(jobID, conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestHubSendsHeartbeats(t *testing.T) {
+	interval := 50 * time.Millisecond
+	h := NewHubWithConfig(HubConfig{HeartbeatInterval: interval}, nil)
+	t.Cleanup(h.Close)
+	conn := dialHub(t, h, "job-1")
+
+	var times []time.Time
+	for len(times) < 4 {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		var msg heartbeatMsg
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Equal(t, "heartbeat", msg.Type)
+		serverTime := time.UnixMilli(msg.ServerTime)
+		assert.WithinDuration(t, time.Now(), serverTime, time.Second)
+		times = append(times, serverTime)
+	}
+
+	// Heartbeats arrive once per interval; allow slack for a loaded machine
+	for i := 1; i < len(times); i++ {
+		gap := times[i].Sub(times[i-1])
+		assert.GreaterOrEqual(t, gap, interval/2, "gap %d", i)
+		assert.LessOrEqual(t, gap, 3*interval, "gap %d", i)
+	}
+}
+
+func TestHubHeartbeatDisabled(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	conn := dialHub(t, h, "job-1")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(150*time.Millisecond)))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, "no message expected without heartbeats")
+}
+
+func TestClientMessageKeepsConnectionAlive(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	conn := dialHub(t, h, "job-1")
+
+	require.Eventually(t, func() bool { return h.GetClientCount("job-1") == 1 }, time.Second, 5*time.Millisecond)
+	h.mu.RLock()
+	var client *Client
+	for c := range h.clients["job-1"] {
+		client = c
+	}
+	h.mu.RUnlock()
+
+	before := client.lastPing.Load()
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"pong"}`)))
+	assert.Eventually(t, func() bool { return client.lastPing.Load() > before }, time.Second, 5*time.Millisecond)
+}
+
+func TestHubClosesConnectionAfterMaxLifetime(t *testing.T) {
+	lifetime := 100 * time.Millisecond
+	h := NewHubWithConfig(HubConfig{MaxConnectionLifetime: lifetime}, nil)
+	t.Cleanup(h.Close)
+	start := time.Now()
+	conn := dialHub(t, h, "job-1")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseReconnect, closeErr.Code)
+	assert.GreaterOrEqual(t, time.Since(start), lifetime)
+	assert.Eventually(t, func() bool { return h.GetClientCount("job-1") == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestHubSendsReconnectHintWhenJobIsFull(t *testing.T) {
+	backoff := 500 * time.Millisecond
+	h := NewHubWithConfig(HubConfig{MaxClientsPerJob: 1, ReconnectBackoff: backoff}, nil)
+	t.Cleanup(h.Close)
+	dialHub(t, h, "job-1")
+	require.Eventually(t, func() bool { return h.GetClientCount("job-1") == 1 }, time.Second, 5*time.Millisecond)
+
+	conn := dialHub(t, h, "job-1")
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var msg ReconnectMsg
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.Equal(t, "reconnect", msg.Type)
+	assert.Equal(t, ErrHubFull.Error(), msg.Reason)
+	assert.GreaterOrEqual(t, msg.BackoffMs, backoff.Milliseconds())
+	assert.Less(t, msg.BackoffMs, 2*backoff.Milliseconds())
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+	assert.Equal(t, 1, h.GetClientCount("job-1"))
+}
+
+func TestHubSendsReconnectHintOnShutdown(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{ReconnectBackoff: time.Second}, nil)
+	conn := dialHub(t, h, "job-1")
+	require.Eventually(t, func() bool { return h.GetClientCount("job-1") == 1 }, time.Second, 5*time.Millisecond)
+	h.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var msg ReconnectMsg
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.Equal(t, "reconnect", msg.Type)
+	assert.GreaterOrEqual(t, msg.BackoffMs, int64(1000))
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseReconnect, closeErr.Code)
+}
+
+func TestHubCloseJobFlushesThenCloses(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	conn := dialHub(t, h, "job-1")
+	other := dialHub(t, h, "job-2")
+	require.Eventually(t, func() bool { return h.GetTotalClients() == 2 }, time.Second, 5*time.Millisecond)
+
+	h.Broadcast("job-1", []byte(`{"type":"cancelled"}`))
+	h.CloseJob("job-1", "job cancelled")
+	assert.Equal(t, 0, h.GetClientCount("job-1"))
+	assert.Equal(t, 1, h.GetClientCount("job-2"))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"cancelled"}`, string(msg))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseJobEnded, closeErr.Code)
+	assert.Equal(t, "job cancelled", closeErr.Text)
+
+	// The run loop later seeing the client's read pump exit must not double close
+	time.Sleep(50 * time.Millisecond)
+	h.Broadcast("job-2", []byte(`{"type":"progress"}`))
+	require.NoError(t, other.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err = other.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"progress"}`, string(msg))
+}
+
+func TestHubReplaysLastProgressOnSubscribe(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	h.SetReplaySource(func(_ context.Context, jobID string) ([]byte, bool) {
+		if jobID != "job-1" {
+			return nil, false
+		}
+		return []byte(`{"task_id":"job-1","percentage":90}`), false
+	})
+	conn := dialHub(t, h, "job-1")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"task_id":"job-1","percentage":90}`, string(msg))
+
+	h.Broadcast("job-1", []byte(`{"task_id":"job-1","percentage":95}`))
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"task_id":"job-1","percentage":95}`, string(msg))
+
+	// A job without cached progress is sent nothing
+	other := dialHub(t, h, "job-2")
+	require.NoError(t, other.SetReadDeadline(time.Now().Add(150*time.Millisecond)))
+	_, _, err = other.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestHubReplaySkippedAfterNewerBroadcast(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	gate := make(chan struct{})
+	h.SetReplaySource(func(context.Context, string) ([]byte, bool) {
+		<-gate
+		return []byte(`{"percentage":50}`), false
+	})
+	conn := dialHub(t, h, "job-1")
+
+	// The client is registered before the lookup, so an update broadcast
+	// while it runs is delivered, and the older replay is dropped
+	require.Eventually(t, func() bool { return h.GetClientCount("job-1") == 1 }, time.Second, 5*time.Millisecond)
+	h.Broadcast("job-1", []byte(`{"percentage":60}`))
+	close(gate)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"percentage":60}`, string(msg))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(150*time.Millisecond)))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err, "no replay expected after a newer update")
+}
+
+func TestHubReplayFinalClosesClient(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	h.SetReplaySource(func(context.Context, string) ([]byte, bool) {
+		return []byte(`{"type":"terminal","status":"SUCCESS"}`), true
+	})
+	conn := dialHub(t, h, "job-1")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"terminal","status":"SUCCESS"}`, string(msg))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseNormalClosure, closeErr.Code)
+	assert.Equal(t, 0, h.GetClientCount("job-1"))
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// replayTimeout bounds the replay lookup of a new subscription
+const replayTimeout = 2 * time.Second
+
+// ReplayFunc returns the message a new subscriber of jobID should be sent
+// first: the job's last known progress, or, with final set, the outcome of a
+// job that has already finished. A nil payload sends nothing.
+type ReplayFunc func(ctx context.Context, jobID string) (payload []byte, final bool)
+
+// SetReplaySource registers fn to look up the message replayed to each new
+// job subscriber, so clients joining a running job see its progress
+// without waiting for the next update
+func (h *Hub) SetReplaySource(fn ReplayFunc) {
+	h.mu.Lock()
+	h.replaySource = fn
+	h.mu.Unlock()
+}
+
+// replay sends a newly registered client its job's replay message. The
+// lookup runs after the client is registered, so no update broadcast in
+// the meantime is lost; the message is queued only if no update has been,
+// since any such update is at least as recent. A final message is always
+// sent and the client then closed normally.
+func (h *Hub) replay(client *Client) {
+	h.mu.RLock()
+	source := h.replaySource
+	h.mu.RUnlock()
+	if source == nil || client.jobID == SystemTopic {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, replayTimeout)
+	payload, final := source(ctx, client.jobID)
+	cancel()
+	if payload == nil {
+		return
+	}
+
+	emptied := false
+	h.mu.Lock()
+	clients := h.clients[client.jobID]
+	if _, ok := clients[client]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	switch {
+	case final:
+		select {
+		case client.send <- payload:
+		default:
+		}
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.clients, client.jobID)
+			emptied = true
+		}
+		client.closeMsg = websocket.FormatCloseMessage(websocket.CloseNormalClosure, "job already finished")
+		close(client.send)
+	case !client.fresh.Load():
+		select {
+		case client.send <- payload:
+		default:
+		}
+	}
+	observer := h.topicObserver
+	h.mu.Unlock()
+	if emptied {
+		notifyTopics(observer, []string{client.jobID}, false)
+	}
+	if final && h.logger != nil {
+		h.logger.Info("WebSocket client sent final status of finished job", zap.String("job_id", client.jobID))
+	}
+}
@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SystemTopic is the reserved hub topic carrying system-level events for
+// dashboards; it can never be used as a job ID subscription
+const SystemTopic = "__system__"
+
+// System event types
+const (
+	EventJobCreated  = "job.created"
+	EventJobTerminal = "job.terminal"
+	EventAlgoHealth  = "algo.health"
+	EventJobSLA      = "job.sla"
+)
+
+// SystemEvent is a message on the system topic; timestamp is in unix milliseconds
+type SystemEvent struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Data      any    `json:"data,omitempty"`
+}
+
+// PublishSystem broadcasts an event to every client subscribed to SystemTopic
+func (h *Hub) PublishSystem(eventType string, data any) {
+	if h == nil {
+		return
+	}
+	payload, err := json.Marshal(SystemEvent{Type: eventType, Timestamp: time.Now().UnixMilli(), Data: data})
+	if err != nil {
+		return
+	}
+	h.Broadcast(SystemTopic, payload)
+}
@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ThrottleConfig bounds the rate at which a Throttle delivers broadcasts
+type ThrottleConfig struct {
+	// PerSecond is the sustained number of broadcasts delivered per second
+	PerSecond int
+	// Burst is how many broadcasts may be delivered back to back after a
+	// quiet period
+	Burst int
+	// QueueSize bounds the broadcasts waiting for delivery; once it is full,
+	// senders block until there is room
+	QueueSize int
+}
+
+// DefaultThrottleConfig returns the default throttle configuration
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		PerSecond: 200,
+		Burst:     50,
+		QueueSize: 1000,
+	}
+}
+
+// throttled is a broadcast waiting in a Throttle's queue
+type throttled struct {
+	topic   string
+	payload []byte
+}
+
+// Throttle smooths bursts of broadcasts, e.g. when the algorithm service
+// completes many jobs at once, into a steady rate. Broadcasts are delivered in
+// order and never dropped: a full queue blocks the sender instead.
+type Throttle struct {
+	hub      *Hub
+	cfg      ThrottleConfig
+	interval time.Duration
+	queue    chan throttled
+
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewThrottle starts a throttle delivering to hub
+func NewThrottle(hub *Hub, cfg ThrottleConfig) *Throttle {
+	defaults := DefaultThrottleConfig()
+	if cfg.PerSecond <= 0 {
+		cfg.PerSecond = defaults.PerSecond
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaults.QueueSize
+	}
+	t := &Throttle{
+		hub:      hub,
+		cfg:      cfg,
+		interval: time.Second / time.Duration(cfg.PerSecond),
+		queue:    make(chan throttled, cfg.QueueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Broadcast queues payload for the clients of topic, blocking while the
+// queue is full. After Close it is delivered straight away.
+func (t *Throttle) Broadcast(topic string, payload []byte) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		t.hub.Broadcast(topic, payload)
+		return
+	}
+	t.queue <- throttled{topic: topic, payload: payload}
+}
+
+// PublishSystem queues an event for the system topic; the event is
+// timestamped now, not when it is delivered
+func (t *Throttle) PublishSystem(eventType string, data any) {
+	payload, err := json.Marshal(SystemEvent{Type: eventType, Timestamp: time.Now().UnixMilli(), Data: data})
+	if err != nil {
+		return
+	}
+	t.Broadcast(SystemTopic, payload)
+}
+
+// Depth returns the number of broadcasts waiting for delivery
+func (t *Throttle) Depth() int {
+	return len(t.queue)
+}
+
+// run delivers queued broadcasts, spending one token each; tokens refill at
+// the configured rate up to the burst size
+func (t *Throttle) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	tokens := t.cfg.Burst
+	for {
+		if tokens == 0 {
+			select {
+			case <-ticker.C:
+				tokens++
+			case <-t.done:
+				return
+			}
+			continue
+		}
+		select {
+		case msg := <-t.queue:
+			tokens--
+			t.hub.Broadcast(msg.topic, msg.payload)
+		case <-ticker.C:
+			if tokens < t.cfg.Burst {
+				tokens++
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Close stops throttling and delivers every queued broadcast at once
+func (t *Throttle) Close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	t.mu.Unlock()
+
+	close(t.done)
+	<-t.stopped
+	for {
+		select {
+		case msg := <-t.queue:
+			t.hub.Broadcast(msg.topic, msg.payload)
+		default:
+			return
+		}
+	}
+}
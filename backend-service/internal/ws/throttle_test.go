@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleSmoothsMassCompletion(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	conn := dialHub(t, h, SystemTopic)
+	require.Eventually(t, func() bool { return h.GetClientCount(SystemTopic) == 1 }, time.Second, 5*time.Millisecond)
+
+	cfg := ThrottleConfig{PerSecond: 100, Burst: 5, QueueSize: 10}
+	throttle := NewThrottle(h, cfg)
+	t.Cleanup(throttle.Close)
+
+	// Many jobs complete at once; senders beyond the queue size block
+	const completions = 60
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < completions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			throttle.PublishSystem(EventJobTerminal, map[string]string{"job_id": fmt.Sprintf("job-%d", i), "status": "SUCCESS"})
+		}(i)
+	}
+
+	seen := make(map[string]bool)
+	arrivals := make([]time.Duration, 0, completions)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for len(seen) < completions {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err, "received %d of %d terminal events", len(seen), completions)
+		var event struct {
+			Type string            `json:"type"`
+			Data map[string]string `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(data, &event))
+		if event.Type != EventJobTerminal {
+			continue
+		}
+		assert.False(t, seen[event.Data["job_id"]], "duplicate event for %s", event.Data["job_id"])
+		seen[event.Data["job_id"]] = true
+		arrivals = append(arrivals, time.Since(start))
+	}
+	wg.Wait()
+
+	// After the burst, delivery is paced at the configured rate
+	minimum := time.Duration(completions-cfg.Burst) * time.Second / time.Duration(cfg.PerSecond)
+	assert.GreaterOrEqual(t, arrivals[len(arrivals)-1], minimum*8/10)
+	inFirst100ms := 0
+	for _, at := range arrivals {
+		if at < 100*time.Millisecond {
+			inFirst100ms++
+		}
+	}
+	assert.LessOrEqual(t, inFirst100ms, cfg.Burst+12, "delivery was not smoothed")
+	assert.Zero(t, throttle.Depth())
+}
+
+func TestThrottleCloseFlushesQueue(t *testing.T) {
+	h := NewHubWithConfig(HubConfig{}, nil)
+	t.Cleanup(h.Close)
+	conn := dialHub(t, h, "job-1")
+	require.Eventually(t, func() bool { return h.GetClientCount("job-1") == 1 }, time.Second, 5*time.Millisecond)
+
+	// One message a second: all but the first wait in the queue until Close
+	throttle := NewThrottle(h, ThrottleConfig{PerSecond: 1, Burst: 1, QueueSize: 10})
+	for i := 0; i < 5; i++ {
+		throttle.Broadcast("job-1", []byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+	throttle.Close()
+	throttle.Broadcast("job-1", []byte(`{"n":5}`))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(500*time.Millisecond)))
+	for i := 0; i <= 5; i++ {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.JSONEq(t, fmt.Sprintf(`{"n":%d}`, i), string(data))
+	}
+}
@@ -119,7 +119,7 @@ func (x HealthStatus_ServingStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use HealthStatus_ServingStatus.Descriptor instead.
 func (HealthStatus_ServingStatus) EnumDescriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{7, 0}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{8, 0}
 }
 
 type SchemeList struct {
@@ -172,9 +172,10 @@ type TaskRequest struct {
 	SchemeCode     string                 `protobuf:"bytes,2,opt,name=scheme_code,json=schemeCode,proto3" json:"scheme_code,omitempty"`
 	DataRef        string                 `protobuf:"bytes,3,opt,name=data_ref,json=dataRef,proto3" json:"data_ref,omitempty"`
 	ParamsJson     string                 `protobuf:"bytes,4,opt,name=params_json,json=paramsJson,proto3" json:"params_json,omitempty"`
-	Priority       int32                  `protobuf:"varint,5,opt,name=priority,proto3" json:"priority,omitempty"`                                   // Task priority (higher = more urgent)
-	TimeoutSeconds int32                  `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"` // Maximum execution time
-	CallbackUrl    string                 `protobuf:"bytes,7,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`           // Optional HTTP callback URL
+	Priority       int32                  `protobuf:"varint,5,opt,name=priority,proto3" json:"priority,omitempty"`                                                                          // Task priority (higher = more urgent)
+	TimeoutSeconds int32                  `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`                                        // Maximum execution time
+	CallbackUrl    string                 `protobuf:"bytes,7,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`                                                  // Optional HTTP callback URL
+	Metadata       map[string]string      `protobuf:"bytes,8,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Opaque caller context, echoed back in TaskResult
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
@@ -258,12 +259,20 @@ func (x *TaskRequest) GetCallbackUrl() string {
 	return ""
 }
 
+func (x *TaskRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
 type TaskSubmissionResponse struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	Accepted       bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
 	Message        string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	QueuePosition  int32                  `protobuf:"varint,3,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`    // Position in processing queue
 	EstimatedStart int64                  `protobuf:"varint,4,opt,name=estimated_start,json=estimatedStart,proto3" json:"estimated_start,omitempty"` // Estimated start timestamp
+	TaskId         string                 `protobuf:"bytes,5,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`                          // Task ID used by the algorithm service (empty = as submitted)
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
@@ -326,6 +335,13 @@ func (x *TaskSubmissionResponse) GetEstimatedStart() int64 {
 	return 0
 }
 
+func (x *TaskSubmissionResponse) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
 type CancelResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Accepted      bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
@@ -446,6 +462,7 @@ type ProgressUpdate struct {
 	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Stage         string                 `protobuf:"bytes,5,opt,name=stage,proto3" json:"stage,omitempty"`                                                                               // Current processing stage
 	Metrics       map[string]string      `protobuf:"bytes,6,rep,name=metrics,proto3" json:"metrics,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Real-time metrics
+	CheckpointRef string                 `protobuf:"bytes,7,opt,name=checkpoint_ref,json=checkpointRef,proto3" json:"checkpoint_ref,omitempty"`                                          // Latest checkpoint the task can resume from
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -522,6 +539,13 @@ func (x *ProgressUpdate) GetMetrics() map[string]string {
 	return nil
 }
 
+func (x *ProgressUpdate) GetCheckpointRef() string {
+	if x != nil {
+		return x.CheckpointRef
+	}
+	return ""
+}
+
 type TaskResult struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TaskId        string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
@@ -531,6 +555,8 @@ type TaskResult struct {
 	LogPath       string                 `protobuf:"bytes,5,opt,name=log_path,json=logPath,proto3" json:"log_path,omitempty"`
 	DurationMs    int64                  `protobuf:"varint,6,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"` // Actual execution duration
 	Metrics       map[string]string      `protobuf:"bytes,7,rep,name=metrics,proto3" json:"metrics,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CheckpointRef string                 `protobuf:"bytes,8,opt,name=checkpoint_ref,json=checkpointRef,proto3" json:"checkpoint_ref,omitempty"`                                            // Latest checkpoint the task can resume from
+	Metadata      map[string]string      `protobuf:"bytes,9,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Metadata received in the TaskRequest
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -614,6 +640,74 @@ func (x *TaskResult) GetMetrics() map[string]string {
 	return nil
 }
 
+func (x *TaskResult) GetCheckpointRef() string {
+	if x != nil {
+		return x.CheckpointRef
+	}
+	return ""
+}
+
+func (x *TaskResult) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// ResultChunk is one message of a streamed result. The first chunk carries the
+// result with result_json left empty; the chunks' data, in order, make up result_json.
+type ResultChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        *TaskResult            `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"` // First chunk only
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`     // Next piece of result_json
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResultChunk) Reset() {
+	*x = ResultChunk{}
+	mi := &file_proto_algorithm_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResultChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResultChunk) ProtoMessage() {}
+
+func (x *ResultChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_algorithm_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResultChunk.ProtoReflect.Descriptor instead.
+func (*ResultChunk) Descriptor() ([]byte, []int) {
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ResultChunk) GetResult() *TaskResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ResultChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
 // DataChunk for streaming large files
 type HealthStatus struct {
 	state         protoimpl.MessageState     `protogen:"open.v1"`
@@ -630,7 +724,7 @@ type HealthStatus struct {
 
 func (x *HealthStatus) Reset() {
 	*x = HealthStatus{}
-	mi := &file_proto_algorithm_proto_msgTypes[7]
+	mi := &file_proto_algorithm_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -642,7 +736,7 @@ func (x *HealthStatus) String() string {
 func (*HealthStatus) ProtoMessage() {}
 
 func (x *HealthStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[7]
+	mi := &file_proto_algorithm_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -655,7 +749,7 @@ func (x *HealthStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthStatus.ProtoReflect.Descriptor instead.
 func (*HealthStatus) Descriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{7}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *HealthStatus) GetStatus() HealthStatus_ServingStatus {
@@ -715,7 +809,7 @@ type Empty struct {
 
 func (x *Empty) Reset() {
 	*x = Empty{}
-	mi := &file_proto_algorithm_proto_msgTypes[8]
+	mi := &file_proto_algorithm_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -727,7 +821,7 @@ func (x *Empty) String() string {
 func (*Empty) ProtoMessage() {}
 
 func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[8]
+	mi := &file_proto_algorithm_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -740,19 +834,20 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Empty.ProtoReflect.Descriptor instead.
 func (*Empty) Descriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{8}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{9}
 }
 
 type TaskIdentity struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	TaskId        string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	TaskId              string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	ResumeFromTimestamp int64                  `protobuf:"varint,2,opt,name=resume_from_timestamp,json=resumeFromTimestamp,proto3" json:"resume_from_timestamp,omitempty"` // WatchTaskProgress only: skip updates older than this (unix ms)
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
 func (x *TaskIdentity) Reset() {
 	*x = TaskIdentity{}
-	mi := &file_proto_algorithm_proto_msgTypes[9]
+	mi := &file_proto_algorithm_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -764,7 +859,7 @@ func (x *TaskIdentity) String() string {
 func (*TaskIdentity) ProtoMessage() {}
 
 func (x *TaskIdentity) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[9]
+	mi := &file_proto_algorithm_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -777,7 +872,7 @@ func (x *TaskIdentity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TaskIdentity.ProtoReflect.Descriptor instead.
 func (*TaskIdentity) Descriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{9}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *TaskIdentity) GetTaskId() string {
@@ -787,6 +882,13 @@ func (x *TaskIdentity) GetTaskId() string {
 	return ""
 }
 
+func (x *TaskIdentity) GetResumeFromTimestamp() int64 {
+	if x != nil {
+		return x.ResumeFromTimestamp
+	}
+	return 0
+}
+
 type Ack struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -797,7 +899,7 @@ type Ack struct {
 
 func (x *Ack) Reset() {
 	*x = Ack{}
-	mi := &file_proto_algorithm_proto_msgTypes[10]
+	mi := &file_proto_algorithm_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -809,7 +911,7 @@ func (x *Ack) String() string {
 func (*Ack) ProtoMessage() {}
 
 func (x *Ack) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[10]
+	mi := &file_proto_algorithm_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -822,7 +924,7 @@ func (x *Ack) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Ack.ProtoReflect.Descriptor instead.
 func (*Ack) Descriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{10}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *Ack) GetSuccess() bool {
@@ -856,7 +958,7 @@ type TaskStatus struct {
 
 func (x *TaskStatus) Reset() {
 	*x = TaskStatus{}
-	mi := &file_proto_algorithm_proto_msgTypes[11]
+	mi := &file_proto_algorithm_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -868,7 +970,7 @@ func (x *TaskStatus) String() string {
 func (*TaskStatus) ProtoMessage() {}
 
 func (x *TaskStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[11]
+	mi := &file_proto_algorithm_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -881,7 +983,7 @@ func (x *TaskStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TaskStatus.ProtoReflect.Descriptor instead.
 func (*TaskStatus) Descriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{11}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *TaskStatus) GetTaskId() string {
@@ -960,7 +1062,7 @@ type TaskList struct {
 
 func (x *TaskList) Reset() {
 	*x = TaskList{}
-	mi := &file_proto_algorithm_proto_msgTypes[12]
+	mi := &file_proto_algorithm_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -972,7 +1074,7 @@ func (x *TaskList) String() string {
 func (*TaskList) ProtoMessage() {}
 
 func (x *TaskList) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[12]
+	mi := &file_proto_algorithm_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -985,7 +1087,7 @@ func (x *TaskList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TaskList.ProtoReflect.Descriptor instead.
 func (*TaskList) Descriptor() ([]byte, []int) {
-	return file_proto_algorithm_proto_rawDescGZIP(), []int{12}
+	return file_proto_algorithm_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *TaskList) GetTasks() []*TaskStatus {
@@ -1024,21 +1126,22 @@ func (x *TaskList) GetCompleted() int32 {
 }
 
 type SchemeList_Scheme struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	Model          string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
-	Code           string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
-	Name           string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
-	ClassName      string                 `protobuf:"bytes,4,opt,name=class_name,json=className,proto3" json:"class_name,omitempty"`
-	ResourceType   string                 `protobuf:"bytes,5,opt,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
-	Description    string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
-	RequiredParams []string               `protobuf:"bytes,7,rep,name=required_params,json=requiredParams,proto3" json:"required_params,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Model              string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	Code               string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Name               string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	ClassName          string                 `protobuf:"bytes,4,opt,name=class_name,json=className,proto3" json:"class_name,omitempty"`
+	ResourceType       string                 `protobuf:"bytes,5,opt,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
+	Description        string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	RequiredParams     []string               `protobuf:"bytes,7,rep,name=required_params,json=requiredParams,proto3" json:"required_params,omitempty"`
+	SupportsCheckpoint bool                   `protobuf:"varint,8,opt,name=supports_checkpoint,json=supportsCheckpoint,proto3" json:"supports_checkpoint,omitempty"` // Failed/cancelled runs can resume from checkpoint_ref
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *SchemeList_Scheme) Reset() {
 	*x = SchemeList_Scheme{}
-	mi := &file_proto_algorithm_proto_msgTypes[13]
+	mi := &file_proto_algorithm_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1050,7 +1153,7 @@ func (x *SchemeList_Scheme) String() string {
 func (*SchemeList_Scheme) ProtoMessage() {}
 
 func (x *SchemeList_Scheme) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_algorithm_proto_msgTypes[13]
+	mi := &file_proto_algorithm_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1115,14 +1218,21 @@ func (x *SchemeList_Scheme) GetRequiredParams() []string {
 	return nil
 }
 
+func (x *SchemeList_Scheme) GetSupportsCheckpoint() bool {
+	if x != nil {
+		return x.SupportsCheckpoint
+	}
+	return false
+}
+
 var File_proto_algorithm_proto protoreflect.FileDescriptor
 
 const file_proto_algorithm_proto_rawDesc = "" +
 	"\n" +
-	"\x15proto/algorithm.proto\x12\talgorithm\"\x9c\x02\n" +
+	"\x15proto/algorithm.proto\x12\talgorithm\"\xcd\x02\n" +
 	"\n" +
 	"SchemeList\x126\n" +
-	"\aschemes\x18\x01 \x03(\v2\x1c.algorithm.SchemeList.SchemeR\aschemes\x1a\xd5\x01\n" +
+	"\aschemes\x18\x01 \x03(\v2\x1c.algorithm.SchemeList.SchemeR\aschemes\x1a\x86\x02\n" +
 	"\x06Scheme\x12\x14\n" +
 	"\x05model\x18\x01 \x01(\tR\x05model\x12\x12\n" +
 	"\x04code\x18\x02 \x01(\tR\x04code\x12\x12\n" +
@@ -1131,7 +1241,8 @@ const file_proto_algorithm_proto_rawDesc = "" +
 	"class_name\x18\x04 \x01(\tR\tclassName\x12#\n" +
 	"\rresource_type\x18\x05 \x01(\tR\fresourceType\x12 \n" +
 	"\vdescription\x18\x06 \x01(\tR\vdescription\x12'\n" +
-	"\x0frequired_params\x18\a \x03(\tR\x0erequiredParams\"\xeb\x01\n" +
+	"\x0frequired_params\x18\a \x03(\tR\x0erequiredParams\x12/\n" +
+	"\x13supports_checkpoint\x18\b \x01(\bR\x12supportsCheckpoint\"\xea\x02\n" +
 	"\vTaskRequest\x12\x17\n" +
 	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12\x1f\n" +
 	"\vscheme_code\x18\x02 \x01(\tR\n" +
@@ -1141,19 +1252,24 @@ const file_proto_algorithm_proto_rawDesc = "" +
 	"paramsJson\x12\x1a\n" +
 	"\bpriority\x18\x05 \x01(\x05R\bpriority\x12'\n" +
 	"\x0ftimeout_seconds\x18\x06 \x01(\x05R\x0etimeoutSeconds\x12!\n" +
-	"\fcallback_url\x18\a \x01(\tR\vcallbackUrl\"\x9e\x01\n" +
+	"\fcallback_url\x18\a \x01(\tR\vcallbackUrl\x12@\n" +
+	"\bmetadata\x18\b \x03(\v2$.algorithm.TaskRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb7\x01\n" +
 	"\x16TaskSubmissionResponse\x12\x1a\n" +
 	"\baccepted\x18\x01 \x01(\bR\baccepted\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
 	"\x0equeue_position\x18\x03 \x01(\x05R\rqueuePosition\x12'\n" +
-	"\x0festimated_start\x18\x04 \x01(\x03R\x0eestimatedStart\"^\n" +
+	"\x0festimated_start\x18\x04 \x01(\x03R\x0eestimatedStart\x12\x17\n" +
+	"\atask_id\x18\x05 \x01(\tR\x06taskId\"^\n" +
 	"\x0eCancelResponse\x12\x1a\n" +
 	"\baccepted\x18\x01 \x01(\bR\baccepted\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
 	"\x06status\x18\x03 \x01(\tR\x06status\">\n" +
 	"\rCancelRequest\x12\x17\n" +
 	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12\x14\n" +
-	"\x05force\x18\x02 \x01(\bR\x05force\"\x95\x02\n" +
+	"\x05force\x18\x02 \x01(\bR\x05force\"\xbc\x02\n" +
 	"\x0eProgressUpdate\x12\x17\n" +
 	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12\x1e\n" +
 	"\n" +
@@ -1162,10 +1278,11 @@ const file_proto_algorithm_proto_rawDesc = "" +
 	"\amessage\x18\x03 \x01(\tR\amessage\x12\x1c\n" +
 	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x12\x14\n" +
 	"\x05stage\x18\x05 \x01(\tR\x05stage\x12@\n" +
-	"\ametrics\x18\x06 \x03(\v2&.algorithm.ProgressUpdate.MetricsEntryR\ametrics\x1a:\n" +
+	"\ametrics\x18\x06 \x03(\v2&.algorithm.ProgressUpdate.MetricsEntryR\ametrics\x12%\n" +
+	"\x0echeckpoint_ref\x18\a \x01(\tR\rcheckpointRef\x1a:\n" +
 	"\fMetricsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x96\x03\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xbb\x04\n" +
 	"\n" +
 	"TaskResult\x12\x17\n" +
 	"\atask_id\x18\x01 \x01(\tR\x06taskId\x124\n" +
@@ -1176,16 +1293,24 @@ const file_proto_algorithm_proto_rawDesc = "" +
 	"\blog_path\x18\x05 \x01(\tR\alogPath\x12\x1f\n" +
 	"\vduration_ms\x18\x06 \x01(\x03R\n" +
 	"durationMs\x12<\n" +
-	"\ametrics\x18\a \x03(\v2\".algorithm.TaskResult.MetricsEntryR\ametrics\x1a:\n" +
+	"\ametrics\x18\a \x03(\v2\".algorithm.TaskResult.MetricsEntryR\ametrics\x12%\n" +
+	"\x0echeckpoint_ref\x18\b \x01(\tR\rcheckpointRef\x12?\n" +
+	"\bmetadata\x18\t \x03(\v2#.algorithm.TaskResult.MetadataEntryR\bmetadata\x1a:\n" +
 	"\fMetricsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"=\n" +
 	"\x06Status\x12\v\n" +
 	"\aSUCCESS\x10\x00\x12\n" +
 	"\n" +
 	"\x06FAILED\x10\x01\x12\r\n" +
 	"\tCANCELLED\x10\x02\x12\v\n" +
-	"\aTIMEOUT\x10\x03\"\xb0\x03\n" +
+	"\aTIMEOUT\x10\x03\"P\n" +
+	"\vResultChunk\x12-\n" +
+	"\x06result\x18\x01 \x01(\v2\x15.algorithm.TaskResultR\x06result\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"\xb0\x03\n" +
 	"\fHealthStatus\x12=\n" +
 	"\x06status\x18\x01 \x01(\x0e2%.algorithm.HealthStatus.ServingStatusR\x06status\x12>\n" +
 	"\ametrics\x18\x02 \x03(\v2$.algorithm.HealthStatus.MetricsEntryR\ametrics\x12!\n" +
@@ -1201,9 +1326,10 @@ const file_proto_algorithm_proto_rawDesc = "" +
 	"\aUNKNOWN\x10\x00\x12\v\n" +
 	"\aSERVING\x10\x01\x12\x0f\n" +
 	"\vNOT_SERVING\x10\x02\"\a\n" +
-	"\x05Empty\"'\n" +
+	"\x05Empty\"[\n" +
 	"\fTaskIdentity\x12\x17\n" +
-	"\atask_id\x18\x01 \x01(\tR\x06taskId\"9\n" +
+	"\atask_id\x18\x01 \x01(\tR\x06taskId\x122\n" +
+	"\x15resume_from_timestamp\x18\x02 \x01(\x03R\x13resumeFromTimestamp\"9\n" +
 	"\x03Ack\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\"\x9c\x02\n" +
@@ -1239,9 +1365,10 @@ const file_proto_algorithm_proto_rawDesc = "" +
 	"\tListTasks\x12\x10.algorithm.Empty\x1a\x13.algorithm.TaskList\x12?\n" +
 	"\rGetTaskStatus\x12\x17.algorithm.TaskIdentity\x1a\x15.algorithm.TaskStatus\x12A\n" +
 	"\n" +
-	"CancelTask\x12\x18.algorithm.CancelRequest\x1a\x19.algorithm.CancelResponse2N\n" +
+	"CancelTask\x12\x18.algorithm.CancelRequest\x1a\x19.algorithm.CancelResponse2\x8e\x01\n" +
 	"\x15ResultReceiverService\x125\n" +
-	"\fReportResult\x12\x15.algorithm.TaskResult\x1a\x0e.algorithm.AckB7Z5github.com/electric-power/backend-service/proto;protob\x06proto3"
+	"\fReportResult\x12\x15.algorithm.TaskResult\x1a\x0e.algorithm.Ack\x12>\n" +
+	"\x12ReportResultStream\x12\x16.algorithm.ResultChunk\x1a\x0e.algorithm.Ack(\x01B7Z5github.com/electric-power/backend-service/proto;protob\x06proto3"
 
 var (
 	file_proto_algorithm_proto_rawDescOnce sync.Once
@@ -1256,7 +1383,7 @@ func file_proto_algorithm_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_algorithm_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_proto_algorithm_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_proto_algorithm_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_proto_algorithm_proto_goTypes = []any{
 	(TaskResult_Status)(0),          // 0: algorithm.TaskResult.Status
 	(HealthStatus_ServingStatus)(0), // 1: algorithm.HealthStatus.ServingStatus
@@ -1267,46 +1394,54 @@ var file_proto_algorithm_proto_goTypes = []any{
 	(*CancelRequest)(nil),           // 6: algorithm.CancelRequest
 	(*ProgressUpdate)(nil),          // 7: algorithm.ProgressUpdate
 	(*TaskResult)(nil),              // 8: algorithm.TaskResult
-	(*HealthStatus)(nil),            // 9: algorithm.HealthStatus
-	(*Empty)(nil),                   // 10: algorithm.Empty
-	(*TaskIdentity)(nil),            // 11: algorithm.TaskIdentity
-	(*Ack)(nil),                     // 12: algorithm.Ack
-	(*TaskStatus)(nil),              // 13: algorithm.TaskStatus
-	(*TaskList)(nil),                // 14: algorithm.TaskList
-	(*SchemeList_Scheme)(nil),       // 15: algorithm.SchemeList.Scheme
-	nil,                             // 16: algorithm.ProgressUpdate.MetricsEntry
-	nil,                             // 17: algorithm.TaskResult.MetricsEntry
-	nil,                             // 18: algorithm.HealthStatus.MetricsEntry
+	(*ResultChunk)(nil),             // 9: algorithm.ResultChunk
+	(*HealthStatus)(nil),            // 10: algorithm.HealthStatus
+	(*Empty)(nil),                   // 11: algorithm.Empty
+	(*TaskIdentity)(nil),            // 12: algorithm.TaskIdentity
+	(*Ack)(nil),                     // 13: algorithm.Ack
+	(*TaskStatus)(nil),              // 14: algorithm.TaskStatus
+	(*TaskList)(nil),                // 15: algorithm.TaskList
+	(*SchemeList_Scheme)(nil),       // 16: algorithm.SchemeList.Scheme
+	nil,                             // 17: algorithm.TaskRequest.MetadataEntry
+	nil,                             // 18: algorithm.ProgressUpdate.MetricsEntry
+	nil,                             // 19: algorithm.TaskResult.MetricsEntry
+	nil,                             // 20: algorithm.TaskResult.MetadataEntry
+	nil,                             // 21: algorithm.HealthStatus.MetricsEntry
 }
 var file_proto_algorithm_proto_depIdxs = []int32{
-	15, // 0: algorithm.SchemeList.schemes:type_name -> algorithm.SchemeList.Scheme
-	16, // 1: algorithm.ProgressUpdate.metrics:type_name -> algorithm.ProgressUpdate.MetricsEntry
-	0,  // 2: algorithm.TaskResult.status:type_name -> algorithm.TaskResult.Status
-	17, // 3: algorithm.TaskResult.metrics:type_name -> algorithm.TaskResult.MetricsEntry
-	1,  // 4: algorithm.HealthStatus.status:type_name -> algorithm.HealthStatus.ServingStatus
-	18, // 5: algorithm.HealthStatus.metrics:type_name -> algorithm.HealthStatus.MetricsEntry
-	13, // 6: algorithm.TaskList.tasks:type_name -> algorithm.TaskStatus
-	10, // 7: algorithm.AlgoControlService.GetAvailableSchemes:input_type -> algorithm.Empty
-	3,  // 8: algorithm.AlgoControlService.SubmitTask:input_type -> algorithm.TaskRequest
-	10, // 9: algorithm.AlgoControlService.CheckHealth:input_type -> algorithm.Empty
-	11, // 10: algorithm.AlgoControlService.WatchTaskProgress:input_type -> algorithm.TaskIdentity
-	10, // 11: algorithm.AlgoControlService.ListTasks:input_type -> algorithm.Empty
-	11, // 12: algorithm.AlgoControlService.GetTaskStatus:input_type -> algorithm.TaskIdentity
-	6,  // 13: algorithm.AlgoControlService.CancelTask:input_type -> algorithm.CancelRequest
-	8,  // 14: algorithm.ResultReceiverService.ReportResult:input_type -> algorithm.TaskResult
-	2,  // 15: algorithm.AlgoControlService.GetAvailableSchemes:output_type -> algorithm.SchemeList
-	4,  // 16: algorithm.AlgoControlService.SubmitTask:output_type -> algorithm.TaskSubmissionResponse
-	9,  // 17: algorithm.AlgoControlService.CheckHealth:output_type -> algorithm.HealthStatus
-	7,  // 18: algorithm.AlgoControlService.WatchTaskProgress:output_type -> algorithm.ProgressUpdate
-	14, // 19: algorithm.AlgoControlService.ListTasks:output_type -> algorithm.TaskList
-	13, // 20: algorithm.AlgoControlService.GetTaskStatus:output_type -> algorithm.TaskStatus
-	5,  // 21: algorithm.AlgoControlService.CancelTask:output_type -> algorithm.CancelResponse
-	12, // 22: algorithm.ResultReceiverService.ReportResult:output_type -> algorithm.Ack
-	15, // [15:23] is the sub-list for method output_type
-	7,  // [7:15] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	16, // 0: algorithm.SchemeList.schemes:type_name -> algorithm.SchemeList.Scheme
+	17, // 1: algorithm.TaskRequest.metadata:type_name -> algorithm.TaskRequest.MetadataEntry
+	18, // 2: algorithm.ProgressUpdate.metrics:type_name -> algorithm.ProgressUpdate.MetricsEntry
+	0,  // 3: algorithm.TaskResult.status:type_name -> algorithm.TaskResult.Status
+	19, // 4: algorithm.TaskResult.metrics:type_name -> algorithm.TaskResult.MetricsEntry
+	20, // 5: algorithm.TaskResult.metadata:type_name -> algorithm.TaskResult.MetadataEntry
+	8,  // 6: algorithm.ResultChunk.result:type_name -> algorithm.TaskResult
+	1,  // 7: algorithm.HealthStatus.status:type_name -> algorithm.HealthStatus.ServingStatus
+	21, // 8: algorithm.HealthStatus.metrics:type_name -> algorithm.HealthStatus.MetricsEntry
+	14, // 9: algorithm.TaskList.tasks:type_name -> algorithm.TaskStatus
+	11, // 10: algorithm.AlgoControlService.GetAvailableSchemes:input_type -> algorithm.Empty
+	3,  // 11: algorithm.AlgoControlService.SubmitTask:input_type -> algorithm.TaskRequest
+	11, // 12: algorithm.AlgoControlService.CheckHealth:input_type -> algorithm.Empty
+	12, // 13: algorithm.AlgoControlService.WatchTaskProgress:input_type -> algorithm.TaskIdentity
+	11, // 14: algorithm.AlgoControlService.ListTasks:input_type -> algorithm.Empty
+	12, // 15: algorithm.AlgoControlService.GetTaskStatus:input_type -> algorithm.TaskIdentity
+	6,  // 16: algorithm.AlgoControlService.CancelTask:input_type -> algorithm.CancelRequest
+	8,  // 17: algorithm.ResultReceiverService.ReportResult:input_type -> algorithm.TaskResult
+	9,  // 18: algorithm.ResultReceiverService.ReportResultStream:input_type -> algorithm.ResultChunk
+	2,  // 19: algorithm.AlgoControlService.GetAvailableSchemes:output_type -> algorithm.SchemeList
+	4,  // 20: algorithm.AlgoControlService.SubmitTask:output_type -> algorithm.TaskSubmissionResponse
+	10, // 21: algorithm.AlgoControlService.CheckHealth:output_type -> algorithm.HealthStatus
+	7,  // 22: algorithm.AlgoControlService.WatchTaskProgress:output_type -> algorithm.ProgressUpdate
+	15, // 23: algorithm.AlgoControlService.ListTasks:output_type -> algorithm.TaskList
+	14, // 24: algorithm.AlgoControlService.GetTaskStatus:output_type -> algorithm.TaskStatus
+	5,  // 25: algorithm.AlgoControlService.CancelTask:output_type -> algorithm.CancelResponse
+	13, // 26: algorithm.ResultReceiverService.ReportResult:output_type -> algorithm.Ack
+	13, // 27: algorithm.ResultReceiverService.ReportResultStream:output_type -> algorithm.Ack
+	19, // [19:28] is the sub-list for method output_type
+	10, // [10:19] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_proto_algorithm_proto_init() }
@@ -1320,7 +1455,7 @@ func file_proto_algorithm_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_algorithm_proto_rawDesc), len(file_proto_algorithm_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   17,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
@@ -371,7 +371,8 @@ var AlgoControlService_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	ResultReceiverService_ReportResult_FullMethodName = "/algorithm.ResultReceiverService/ReportResult"
+	ResultReceiverService_ReportResult_FullMethodName       = "/algorithm.ResultReceiverService/ReportResult"
+	ResultReceiverService_ReportResultStream_FullMethodName = "/algorithm.ResultReceiverService/ReportResultStream"
 )
 
 // ResultReceiverServiceClient is the client API for ResultReceiverService service.
@@ -382,6 +383,9 @@ const (
 type ResultReceiverServiceClient interface {
 	// ReportResult reports the completion of a task
 	ReportResult(ctx context.Context, in *TaskResult, opts ...grpc.CallOption) (*Ack, error)
+	// ReportResultStream reports a result too large for one message, sending
+	// its result JSON in chunks; the result is acked once complete
+	ReportResultStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ResultChunk, Ack], error)
 }
 
 type resultReceiverServiceClient struct {
@@ -402,6 +406,19 @@ func (c *resultReceiverServiceClient) ReportResult(ctx context.Context, in *Task
 	return out, nil
 }
 
+func (c *resultReceiverServiceClient) ReportResultStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ResultChunk, Ack], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ResultReceiverService_ServiceDesc.Streams[0], ResultReceiverService_ReportResultStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ResultChunk, Ack]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ResultReceiverService_ReportResultStreamClient = grpc.ClientStreamingClient[ResultChunk, Ack]
+
 // ResultReceiverServiceServer is the server API for ResultReceiverService service.
 // All implementations must embed UnimplementedResultReceiverServiceServer
 // for forward compatibility.
@@ -410,6 +427,9 @@ func (c *resultReceiverServiceClient) ReportResult(ctx context.Context, in *Task
 type ResultReceiverServiceServer interface {
 	// ReportResult reports the completion of a task
 	ReportResult(context.Context, *TaskResult) (*Ack, error)
+	// ReportResultStream reports a result too large for one message, sending
+	// its result JSON in chunks; the result is acked once complete
+	ReportResultStream(grpc.ClientStreamingServer[ResultChunk, Ack]) error
 	mustEmbedUnimplementedResultReceiverServiceServer()
 }
 
@@ -423,6 +443,9 @@ type UnimplementedResultReceiverServiceServer struct{}
 func (UnimplementedResultReceiverServiceServer) ReportResult(context.Context, *TaskResult) (*Ack, error) {
 	return nil, status.Error(codes.Unimplemented, "method ReportResult not implemented")
 }
+func (UnimplementedResultReceiverServiceServer) ReportResultStream(grpc.ClientStreamingServer[ResultChunk, Ack]) error {
+	return status.Error(codes.Unimplemented, "method ReportResultStream not implemented")
+}
 func (UnimplementedResultReceiverServiceServer) mustEmbedUnimplementedResultReceiverServiceServer() {}
 func (UnimplementedResultReceiverServiceServer) testEmbeddedByValue()                               {}
 
@@ -462,6 +485,13 @@ func _ResultReceiverService_ReportResult_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ResultReceiverService_ReportResultStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ResultReceiverServiceServer).ReportResultStream(&grpc.GenericServerStream[ResultChunk, Ack]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ResultReceiverService_ReportResultStreamServer = grpc.ClientStreamingServer[ResultChunk, Ack]
+
 // ResultReceiverService_ServiceDesc is the grpc.ServiceDesc for ResultReceiverService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -474,6 +504,12 @@ var ResultReceiverService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ResultReceiverService_ReportResult_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReportResultStream",
+			Handler:       _ResultReceiverService_ReportResultStream_Handler,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "proto/algorithm.proto",
 }